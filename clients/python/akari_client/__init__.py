@@ -0,0 +1,21 @@
+"""Typed Python client for the akari REST API."""
+
+from .client import (
+    AkariClient,
+    AkariError,
+    Evaluation,
+    Explanation,
+    StateEvent,
+    SuspectChange,
+    Violation,
+)
+
+__all__ = [
+    "AkariClient",
+    "AkariError",
+    "Evaluation",
+    "Explanation",
+    "StateEvent",
+    "SuspectChange",
+    "Violation",
+]
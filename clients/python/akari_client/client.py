@@ -0,0 +1,290 @@
+"""Typed wrapper over the akari REST API, using only the standard library.
+
+The models mirror the schemas in api/openapi.yaml. Fields the server adds
+later are kept in ``extra`` rather than failing to decode.
+"""
+
+from __future__ import annotations
+
+import json
+import time
+import urllib.error
+import urllib.parse
+import urllib.request
+from dataclasses import asdict, dataclass, field, fields
+from datetime import datetime
+from typing import Any, Dict, List, Optional, Type, TypeVar
+
+T = TypeVar("T")
+
+DEFAULT_RETRIES = 3
+DEFAULT_BACKOFF = 0.2
+MAX_BACKOFF = 10.0
+
+
+class AkariError(Exception):
+    """A non-2xx response from the server."""
+
+    def __init__(self, status: int, message: str):
+        super().__init__(f"akari: {status}: {message}")
+        self.status = status
+        self.message = message
+
+    @property
+    def retryable(self) -> bool:
+        return self.status == 429 or self.status >= 500
+
+
+def _parse_time(value: Optional[str]) -> Optional[datetime]:
+    if not value or value.startswith("0001-01-01"):
+        return None
+    # Go emits nanoseconds and "Z"; fromisoformat takes microseconds
+    value = value.replace("Z", "+00:00")
+    if "." in value:
+        head, _, tail = value.partition(".")
+        digits = tail[: next((i for i, c in enumerate(tail) if not c.isdigit()), len(tail))]
+        zone = tail[len(digits):]
+        value = f"{head}.{digits[:6].ljust(6, '0')}{zone}"
+    return datetime.fromisoformat(value)
+
+
+def _decode(cls: Type[T], data: Dict[str, Any]) -> T:
+    known = {f.name for f in fields(cls)}
+    kwargs = {k: v for k, v in data.items() if k in known}
+    if "extra" in known:
+        kwargs["extra"] = {k: v for k, v in data.items() if k not in known}
+    return cls(**kwargs)
+
+
+@dataclass
+class SuspectChange:
+    resource_uid: str = ""
+    kind: str = ""
+    namespace: str = ""
+    name: str = ""
+    relation: str = ""
+    actor: str = ""
+    changed_at: Optional[str] = None
+    fields: Dict[str, Any] = field(default_factory=dict)
+    relevance: int = 0
+
+    @property
+    def changed(self) -> Optional[datetime]:
+        return _parse_time(self.changed_at)
+
+
+@dataclass
+class Violation:
+    invariant_id: str = ""
+    invariant_version: int = 0
+    violated: bool = False
+    status: str = ""
+    reason: str = ""
+    responsible_actor: str = ""
+    eliminated_actors: Optional[List[str]] = None
+    affected_resource: str = ""
+    resource_uid: str = ""
+    detected_at: Optional[str] = None
+    severity: str = ""
+    tier: str = ""
+    impact: float = 0.0
+    docs: str = ""
+    runbook_url: str = ""
+    evidence: List[str] = field(default_factory=list)
+    correlated: List[str] = field(default_factory=list)
+    suspect_changes: List[SuspectChange] = field(default_factory=list)
+    extra: Dict[str, Any] = field(default_factory=dict)
+
+    @classmethod
+    def from_json(cls, data: Dict[str, Any]) -> "Violation":
+        violation = _decode(cls, data)
+        violation.suspect_changes = [_decode(SuspectChange, s) for s in data.get("suspect_changes") or []]
+        return violation
+
+    @property
+    def detected(self) -> Optional[datetime]:
+        return _parse_time(self.detected_at)
+
+    @property
+    def namespace(self) -> str:
+        return self.affected_resource.partition("/")[0]
+
+
+@dataclass
+class StateEvent:
+    uid: str
+    kind: str
+    name: str
+    namespace: str = ""
+    labels: Dict[str, str] = field(default_factory=dict)
+    tier: str = ""
+    version: str = ""
+    timestamp: Optional[str] = None
+    creation_timestamp: Optional[str] = None
+    field_diff: Dict[str, Any] = field(default_factory=dict)
+    actor: str = ""
+    full_state: Optional[Dict[str, Any]] = None
+
+    @classmethod
+    def from_json(cls, data: Dict[str, Any]) -> "StateEvent":
+        return _decode(cls, data)
+
+    def to_json(self) -> Dict[str, Any]:
+        return {k: v for k, v in asdict(self).items() if v not in (None, "", {})}
+
+
+@dataclass
+class Explanation:
+    resource: Dict[str, str]
+    violations: List[Violation]
+    explanations: List[str]
+
+
+@dataclass
+class Evaluation:
+    invariants: List[str]
+    results: List[Violation]
+    violations: List[Violation]
+
+
+class AkariClient:
+    """Client for an akari server.
+
+    Every call except record_event only reads, so failed requests are
+    retried with exponential backoff on network errors, 429 and 5xx.
+    """
+
+    def __init__(
+        self,
+        base_url: str = "http://localhost:8080",
+        retries: int = DEFAULT_RETRIES,
+        backoff: float = DEFAULT_BACKOFF,
+        timeout: float = 30.0,
+    ):
+        self.base_url = base_url.rstrip("/")
+        self.retries = retries
+        self.backoff = backoff
+        self.timeout = timeout
+
+    def health(self) -> Dict[str, Any]:
+        return self._request("GET", "/health")
+
+    def violations(
+        self,
+        severity: Optional[str] = None,
+        tags: Optional[List[str]] = None,
+        limit: Optional[int] = None,
+        active: bool = False,
+        sort_by_impact: bool = False,
+    ) -> List[Violation]:
+        """Current violations, or the ones open in the monitor with active."""
+        query: Dict[str, Any] = {}
+        if severity:
+            query["severity"] = severity
+        if tags:
+            query["tags"] = ",".join(tags)
+        if limit:
+            query["limit"] = limit
+        if sort_by_impact:
+            query["sort"] = "impact"
+        path = "/api/v1/violations/active" if active else "/api/v1/violations"
+        return [Violation.from_json(v) for v in self._request("GET", path, query) or []]
+
+    def violations_frame(self, **kwargs: Any):
+        """violations() as a pandas DataFrame, one row per violation."""
+        import pandas as pd
+
+        rows = []
+        for v in self.violations(**kwargs):
+            row = asdict(v)
+            row.pop("extra")
+            row["detected_at"] = v.detected
+            rows.append(row)
+        return pd.DataFrame(rows)
+
+    def explain(self, kind: str, namespace: str, name: str) -> Explanation:
+        data = self._request("POST", "/api/v1/explain", body={"kind": kind, "namespace": namespace, "name": name})
+        return Explanation(
+            resource=data.get("resource") or {},
+            violations=[Violation.from_json(v) for v in data.get("violations") or []],
+            explanations=data.get("explanations") or [],
+        )
+
+    def history(self, uid: str, limit: int = 20) -> List[StateEvent]:
+        """Recorded states of a resource, newest first. Needs PostgreSQL."""
+        data = self._request("GET", "/api/v1/history", {"uid": uid, "limit": limit})
+        return [StateEvent.from_json(e) for e in data or []]
+
+    def resources(
+        self,
+        kind: Optional[str] = None,
+        namespace: Optional[str] = None,
+        name: Optional[str] = None,
+        limit: Optional[int] = None,
+    ) -> List[StateEvent]:
+        """Latest recorded state of resources, every kind when kind is omitted."""
+        query = {k: v for k, v in {"kind": kind, "namespace": namespace, "name": name, "limit": limit}.items() if v}
+        return [StateEvent.from_json(e) for e in self._request("GET", "/api/v1/resources", query) or []]
+
+    def resource(self, uid: str) -> StateEvent:
+        return StateEvent.from_json(self._request("GET", "/api/v1/resources/" + urllib.parse.quote(uid, safe="")))
+
+    def compare(self, start: datetime, end: Optional[datetime] = None) -> Dict[str, Any]:
+        query = {"from": _format_time(start)}
+        if end is not None:
+            query["to"] = _format_time(end)
+        return self._request("GET", "/api/v1/compare", query)
+
+    def evaluate_resource(self, event: StateEvent) -> Evaluation:
+        """Evaluate a resource's state without recording it."""
+        data = self._request("POST", "/api/v1/evaluate/resource", body=event.to_json())
+        return Evaluation(
+            invariants=data.get("invariants") or [],
+            results=[Violation.from_json(v) for v in data.get("results") or []],
+            violations=[Violation.from_json(v) for v in data.get("violations") or []],
+        )
+
+    def record_event(self, event: StateEvent) -> StateEvent:
+        return StateEvent.from_json(self._request("POST", "/api/v1/events", body=event.to_json(), retry=False))
+
+    def invariants(self) -> List[Dict[str, Any]]:
+        return self._request("GET", "/api/v1/invariants")
+
+    def _request(
+        self,
+        method: str,
+        path: str,
+        query: Optional[Dict[str, Any]] = None,
+        body: Any = None,
+        retry: bool = True,
+    ) -> Any:
+        url = self.base_url + path
+        if query:
+            url += "?" + urllib.parse.urlencode(query)
+        data = json.dumps(body).encode() if body is not None else None
+        headers = {"Accept": "application/json"}
+        if data is not None:
+            headers["Content-Type"] = "application/json"
+
+        attempt = 0
+        while True:
+            try:
+                req = urllib.request.Request(url, data=data, method=method, headers=headers)
+                with urllib.request.urlopen(req, timeout=self.timeout) as resp:
+                    payload = resp.read()
+                    return json.loads(payload) if payload else None
+            except urllib.error.HTTPError as e:
+                error: Exception = AkariError(e.code, e.read(512).decode(errors="replace").strip())
+                transient = error.retryable
+            except urllib.error.URLError as e:
+                error, transient = e, True
+            if not retry or not transient or attempt >= self.retries:
+                raise error
+            time.sleep(min(self.backoff * (2**attempt), MAX_BACKOFF))
+            attempt += 1
+
+
+def _format_time(t: datetime) -> str:
+    if t.tzinfo is None:
+        raise ValueError("times must be timezone-aware")
+    return t.isoformat(timespec="seconds").replace("+00:00", "Z")
@@ -0,0 +1,20 @@
+#!/usr/bin/env bash
+# Generates the full low-level Python client from api/openapi.yaml into
+# generated/. The typed wrapper in akari_client covers the common calls;
+# use the generated client for everything else.
+set -euo pipefail
+
+here="$(cd "$(dirname "$0")" && pwd)"
+spec="$here/../../api/openapi.yaml"
+
+if ! command -v openapi-python-client >/dev/null; then
+	echo "openapi-python-client not found; pip install '.[generate]'" >&2
+	exit 1
+fi
+
+openapi-python-client generate \
+	--path "$spec" \
+	--output-path "$here/generated" \
+	--meta setup \
+	--overwrite
+echo "Generated $here/generated; install it with pip install $here/generated"
@@ -0,0 +1,31 @@
+#!/usr/bin/env bash
+# Builds and starts an akari server on an in-memory store, then runs the
+# Python integration tests against it. Set AKARI_URL to test an already
+# running server instead.
+set -euo pipefail
+
+here="$(cd "$(dirname "$0")" && pwd)"
+root="$here/../.."
+
+if [ -z "${AKARI_URL:-}" ]; then
+	port="${AKARI_PORT:-18080}"
+	bin="$(mktemp -d)/akari"
+	(cd "$root" && go build -o "$bin" ./cmd)
+
+	# An unreachable DATABASE_URL makes the server fall back to memory
+	DATABASE_URL="postgres://akari@127.0.0.1:1/akari?sslmode=disable&connect_timeout=1" \
+		API_ADDRESS="127.0.0.1:$port" \
+		EVALUATION_INTERVAL=1s \
+		"$bin" >"$here/server.log" 2>&1 &
+	server=$!
+	trap 'kill $server 2>/dev/null || true' EXIT
+
+	export AKARI_URL="http://127.0.0.1:$port"
+	for _ in $(seq 1 50); do
+		curl -fs "$AKARI_URL/health" >/dev/null && break
+		sleep 0.2
+	done
+fi
+
+cd "$here"
+python3 -m unittest discover -s tests -v
@@ -0,0 +1,103 @@
+"""Integration tests against a running akari server.
+
+Run them with ./run-integration.sh, or set AKARI_URL to an existing server.
+"""
+
+import os
+import unittest
+import uuid
+from datetime import datetime, timedelta, timezone
+
+from akari_client import AkariClient, AkariError, StateEvent
+
+AKARI_URL = os.environ.get("AKARI_URL")
+
+# Built-in pod invariants need related resources this test doesn't record,
+# so it checks readiness with its own invariant
+WEB_READY = {
+    "id": "py_client_web_ready",
+    "description": "Pods labelled app=py-client-web are Ready",
+    "subject": {"kind": "Pod", "selector": {"app": "py-client-web"}},
+    "predicate": {"field": "status.conditions[Ready].status", "operator": "equals", "value": "True"},
+    "responsibility": {"primary": "kubelet"},
+    "severity": "critical",
+}
+
+
+def web_pod(name: str, ready: str) -> StateEvent:
+    return StateEvent(
+        uid=f"py-client-{name}",
+        kind="Pod",
+        namespace="py-client",
+        name=name,
+        labels={"app": "py-client-web"},
+        version=uuid.uuid4().hex,
+        field_diff={"status.conditions[Ready].status": ready},
+        actor="py-client-test",
+    )
+
+
+@unittest.skipUnless(AKARI_URL, "AKARI_URL not set")
+class IntegrationTest(unittest.TestCase):
+    @classmethod
+    def setUpClass(cls):
+        cls.client = AkariClient(AKARI_URL)
+        try:
+            cls.client._request("POST", "/api/v1/invariants", body=WEB_READY, retry=False)
+        except AkariError as e:
+            if e.status != 409:
+                raise
+        cls.client.record_event(web_pod("web-1", "False"))
+
+    def test_health(self):
+        self.assertIsInstance(self.client.health(), dict)
+
+    def test_violations(self):
+        violations = [v for v in self.client.violations(severity="critical") if v.invariant_id == WEB_READY["id"]]
+        self.assertEqual(len(violations), 1)
+        v = violations[0]
+        self.assertEqual(v.affected_resource, "py-client/web-1")
+        self.assertEqual(v.namespace, "py-client")
+        self.assertEqual(v.responsible_actor, "kubelet")
+        self.assertIsNotNone(v.detected)
+
+    def test_explain(self):
+        explanation = self.client.explain("Pod", "py-client", "web-1")
+        self.assertEqual(explanation.resource["uid"], "py-client-web-1")
+        self.assertTrue(any(v.invariant_id == WEB_READY["id"] for v in explanation.violations))
+
+        with self.assertRaises(AkariError) as ctx:
+            self.client.explain("Pod", "py-client", "missing")
+        self.assertEqual(ctx.exception.status, 404)
+
+    def test_evaluate_resource(self):
+        evaluation = self.client.evaluate_resource(web_pod("web-2", "True"))
+        self.assertIn(WEB_READY["id"], evaluation.invariants)
+        self.assertFalse(any(v.invariant_id == WEB_READY["id"] for v in evaluation.violations))
+
+    def test_resources(self):
+        resources = self.client.resources(kind="Pod", namespace="py-client")
+        self.assertIn("py-client-web-1", [r.uid for r in resources])
+
+        resource = self.client.resource("py-client-web-1")
+        self.assertEqual(resource.actor, "py-client-test")
+        self.assertEqual(resource.field_diff["status.conditions[Ready].status"], "False")
+
+        with self.assertRaises(AkariError) as ctx:
+            self.client.resource("py-client-missing")
+        self.assertEqual(ctx.exception.status, 404)
+
+    def test_history(self):
+        try:
+            history = self.client.history("py-client-web-1")
+        except AkariError as e:
+            if e.status == 503:
+                self.skipTest("server has no PostgreSQL history")
+            raise
+        self.assertGreaterEqual(len(history), 1)
+        self.assertEqual(history[0].kind, "Pod")
+
+    def test_compare(self):
+        now = datetime.now(timezone.utc)
+        comparison = self.client.compare(now - timedelta(hours=1), now)
+        self.assertIn("newly_broken", comparison)
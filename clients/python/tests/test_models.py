@@ -0,0 +1,33 @@
+import unittest
+from datetime import timezone
+
+from akari_client import AkariClient, AkariError, Violation
+from akari_client.client import _parse_time
+
+
+class ModelTest(unittest.TestCase):
+    def test_violation_from_json(self):
+        v = Violation.from_json(
+            {
+                "invariant_id": "pod_ready",
+                "affected_resource": "default/api",
+                "detected_at": "2026-01-01T12:00:00.123456789Z",
+                "suspect_changes": [{"kind": "Deployment", "name": "api", "relevance": 5}],
+                "added_later": True,
+            }
+        )
+        self.assertEqual(v.namespace, "default")
+        self.assertEqual(v.detected.microsecond, 123456)
+        self.assertEqual(v.detected.tzinfo, timezone.utc)
+        self.assertEqual(v.suspect_changes[0].relevance, 5)
+        self.assertEqual(v.extra, {"added_later": True})
+
+    def test_parse_time(self):
+        self.assertIsNone(_parse_time("0001-01-01T00:00:00Z"))
+        self.assertEqual(_parse_time("2026-01-01T12:00:00+02:00").hour, 12)
+
+    def test_unreachable_server(self):
+        client = AkariClient("http://127.0.0.1:1", retries=1, backoff=0.01)
+        with self.assertRaises(Exception) as ctx:
+            client.violations()
+        self.assertNotIsInstance(ctx.exception, AkariError)
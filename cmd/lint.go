@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/lint"
+	"github.com/aonescu/akari/internal/scan"
+	"github.com/aonescu/akari/internal/state"
+)
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// runLint evaluates the manifest invariants against the manifests under
+// the given files and directories, and against kustomizations and Helm
+// charts rendered the way they would be applied, without a cluster. Exit
+// codes are as for scan.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: akari lint [flags] [<dir|file>...]")
+		flags.PrintDefaults()
+	}
+	var kustomizations, values, sets, envs stringList
+	flags.Var(&kustomizations, "kustomize", "kustomization directory to render with kustomize build (repeatable)")
+	chart := flags.String("helm", "", "Helm chart to render with helm template")
+	release := flags.String("release", "", "release name for helm template")
+	flags.Var(&values, "values", "value file for the Helm chart (repeatable)")
+	flags.Var(&sets, "set", "key=value override for the Helm chart (repeatable)")
+	flags.Var(&envs, "env", "NAME=FILE[,FILE...] renders the Helm chart once more per environment with these value files over --values (repeatable)")
+	timeout := flags.Duration("timeout", 2*time.Minute, "time allowed for rendering")
+	baseline := flags.String("baseline", "", "JSON report of the base manifests; only violations it doesn't have are reported and fail the lint")
+	namespace := flags.String("namespace", "default", "namespace of manifests that don't declare one")
+	failOn := flags.String("fail-on", "critical", "lowest severity that fails the lint: critical, degraded, warning or none")
+	format := flags.String("format", scan.FormatText, "report format: text, json, junit, sarif or markdown")
+	limit := flags.Int("limit", 0, "violations listed per severity in the text report; 0 lists all")
+	if err := flags.Parse(args); err != nil {
+		return scanError
+	}
+	if flags.NArg() == 0 && len(kustomizations) == 0 && *chart == "" {
+		flags.Usage()
+		return scanError
+	}
+	if len(envs) > 0 && *chart == "" {
+		fmt.Fprintln(os.Stderr, "lint: --env needs --helm")
+		return scanError
+	}
+
+	threshold, err := scan.ParseThreshold(*failOn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lint:", err)
+		return scanError
+	}
+	if !scan.ValidFormat(*format) {
+		fmt.Fprintf(os.Stderr, "lint: invalid format %q, want text, json, junit, sarif or markdown\n", *format)
+		return scanError
+	}
+
+	objects, skipped, err := lint.Load(flags.Args()...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lint:", err)
+		return scanError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	renderer := lint.NewRenderer()
+	for _, dir := range kustomizations {
+		rendered, skips, err := renderer.Kustomization(ctx, dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "lint:", err)
+			return scanError
+		}
+		objects = append(objects, rendered...)
+		skipped = append(skipped, skips...)
+	}
+	if *chart != "" {
+		helmChart := lint.HelmChart{Chart: *chart, Release: *release, Namespace: *namespace, Values: values, Set: sets}
+		environments := []lint.Environment{{}}
+		if len(envs) > 0 {
+			environments = environments[:0]
+			for _, s := range envs {
+				env, err := lint.ParseEnvironment(s)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "lint:", err)
+					return scanError
+				}
+				environments = append(environments, env)
+			}
+		}
+		for _, env := range environments {
+			rendered, skips, err := renderer.Chart(ctx, helmChart, env)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "lint:", err)
+				return scanError
+			}
+			objects = append(objects, rendered...)
+			skipped = append(skipped, skips...)
+		}
+	}
+	for _, s := range skipped {
+		fmt.Fprintf(os.Stderr, "lint: skipping %s in %s: %s\n", s.Kind, s.Source, s.Reason)
+	}
+
+	store := state.NewMemoryStore()
+	report, err := lint.Run(objects, lint.NewEngine(store), store, *namespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lint:", err)
+		return scanError
+	}
+	if *baseline != "" {
+		base, err := scan.ReadReportFile(*baseline)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "lint:", err)
+			return scanError
+		}
+		report.Compare(base)
+	}
+
+	if err := report.Write(os.Stdout, *format, threshold, *limit); err != nil {
+		fmt.Fprintln(os.Stderr, "lint:", err)
+		return scanError
+	}
+
+	if report.Failed(threshold) {
+		return scanViolated
+	}
+	return scanOK
+}
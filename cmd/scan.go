@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/scan"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/watcher"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Exit codes of the scan and lint subcommands
+const (
+	scanOK       = 0
+	scanViolated = 1
+	scanError    = 2
+)
+
+// runScan discovers the cluster once, evaluates every invariant and exits
+// non-zero when violations reach the --fail-on severity, so it can gate a
+// CI pipeline after a deploy
+func runScan(args []string) int {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	kubeconfig := flags.String("kubeconfig", defaultKubeconfig(), "path to the kubeconfig; empty uses the in-cluster configuration")
+	kubeContext := flags.String("context", "", "kubeconfig context to use")
+	namespace := flags.String("namespace", "", "namespace to scan; empty scans all of them")
+	failOn := flags.String("fail-on", "critical", "lowest severity that fails the scan: critical, degraded, warning or none")
+	format := flags.String("format", scan.FormatText, "report format: text, json, junit, sarif or markdown")
+	limit := flags.Int("limit", 5, "violations listed per severity in the text report; 0 lists all")
+	timeout := flags.Duration("timeout", time.Minute, "time allowed for discovery")
+	fieldSpecs := flags.String("field-specs", os.Getenv("FIELD_SPECS_FILE"), "YAML file declaring extra fields to extract per kind")
+	if err := flags.Parse(args); err != nil {
+		return scanError
+	}
+
+	threshold, err := scan.ParseThreshold(*failOn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "scan:", err)
+		return scanError
+	}
+	if !scan.ValidFormat(*format) {
+		fmt.Fprintf(os.Stderr, "scan: invalid format %q, want text, json, junit, sarif or markdown\n", *format)
+		return scanError
+	}
+
+	if *fieldSpecs != "" {
+		specs, err := watcher.LoadFieldSpecs(*fieldSpecs)
+		if err == nil {
+			err = scan.Converters.ApplyFieldSpecs(specs)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "scan:", err)
+			return scanError
+		}
+	}
+
+	config, err := scanConfig(*kubeconfig, *kubeContext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "scan:", err)
+		return scanError
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "scan:", err)
+		return scanError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	store := state.NewMemoryStore()
+	report, err := scan.Run(ctx, client, engine.NewInvariantEngine(store), store, *namespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "scan:", err)
+		return scanError
+	}
+
+	if err := report.Write(os.Stdout, *format, threshold, *limit); err != nil {
+		fmt.Fprintln(os.Stderr, "scan:", err)
+		return scanError
+	}
+
+	if report.Failed(threshold) {
+		return scanViolated
+	}
+	return scanOK
+}
+
+func defaultKubeconfig() string {
+	if path := os.Getenv("KUBECONFIG"); path != "" {
+		return path
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		path := filepath.Join(home, ".kube", "config")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func scanConfig(kubeconfig, kubeContext string) (*rest.Config, error) {
+	if kubeconfig == "" && kubeContext == "" {
+		if config, err := rest.InClusterConfig(); err == nil {
+			return config, nil
+		}
+	}
+	rules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("no Kubernetes configuration: %w", err)
+	}
+	return config, nil
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/rbac"
+	"github.com/aonescu/akari/internal/tenancy"
+)
+
+// reviewTimeout bounds each access review made while serving a request
+const reviewTimeout = 5 * time.Second
+
+// scopedRoutes are the routes whose handlers limit their data to the
+// namespaces of the request's principal. Principals limited to some
+// namespaces get a 403 from every other route, since those report on the
+// cluster as a whole.
+var scopedRoutes = map[string]bool{
+	"/api/v1/violations":                            true,
+	"/api/v1/violations/active":                     true,
+	"/api/v1/explain/resource":                      true,
+	"/api/v1/history":                               true,
+	"/api/v1/deployments/{namespace}/{name}/images": true,
+	"/api/v1/terminations":                          true,
+	"/api/v1/events":                                true,
+	"/api/v1/events/bulk":                           true,
+	"/api/v1/resources":                             true,
+	"/api/v1/resources/{uid}":                       true,
+}
+
+// publicRoutes answer without authentication, for probes
+var publicRoutes = map[string]bool{
+	"/health": true,
+	"/ready":  true,
+}
+
+// principal is who a request was authenticated as: a tenant, or a
+// Kubernetes user whose access follows cluster RBAC
+type principal struct {
+	name string
+	// owns limits the request to some namespaces; nil allows every
+	// namespace and the cluster-wide routes
+	owns func(namespace string) bool
+}
+
+type principalKey struct{}
+
+// SetTenants makes every request identify a tenant, by bearer token or, when
+// trustHeader is set, by the X-Akari-Tenant header of an authenticating
+// proxy. Restricted tenants only see their own namespaces.
+func (api *APIServer) SetTenants(registry *tenancy.Registry, trustHeader bool) {
+	api.tenants = registry
+	api.trustTenantHeader = trustHeader
+}
+
+// SetRBACReviewer makes every request carry a Kubernetes bearer token, such
+// as a service-account token, unless it is a tenant token. Such requests
+// only see namespaces where cluster RBAC lets the user read the reviewed
+// resource.
+func (api *APIServer) SetRBACReviewer(reviewer *rbac.Reviewer) {
+	api.reviewer = reviewer
+}
+
+func (api *APIServer) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if api.tenants == nil && api.reviewer == nil || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		_, pattern := api.mux.Handler(r)
+		route := routePath(pattern)
+		if publicRoutes[route] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p, err := api.authenticate(r)
+		if err != nil {
+			log.Printf("Failed to authenticate request: %v", err)
+			writeProblem(w, http.StatusServiceUnavailable, CodeAuthUnavailable, "Kubernetes access review is unavailable")
+			return
+		}
+		if p == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="akari"`)
+			writeProblem(w, http.StatusUnauthorized, CodeUnauthorized, "A bearer token is required")
+			return
+		}
+		if p.owns != nil && !scopedRoutes[route] {
+			writeProblem(w, http.StatusForbidden, CodeForbidden, p.name+" may not use "+r.URL.Path)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+// authenticate resolves a request to its principal, nil when it carries no
+// credentials the server accepts
+func (api *APIServer) authenticate(r *http.Request) (*principal, error) {
+	token, bearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if api.tenants != nil {
+		if bearer {
+			if tenant, ok := api.tenants.ByToken(token); ok {
+				return tenantPrincipal(tenant), nil
+			}
+		} else if name := r.Header.Get(tenancy.Header); name != "" && api.trustTenantHeader {
+			if tenant, ok := api.tenants.ByName(name); ok {
+				return tenantPrincipal(tenant), nil
+			}
+		}
+	}
+	if api.reviewer == nil || !bearer {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), reviewTimeout)
+	defer cancel()
+	user, ok, err := api.reviewer.Authenticate(ctx, token)
+	if err != nil || !ok {
+		return nil, err
+	}
+	// Users who may read every namespace see the cluster-wide routes too
+	everywhere, err := api.reviewer.CanRead(ctx, user, "")
+	if err != nil {
+		return nil, err
+	}
+	p := &principal{name: "user " + user.Username}
+	if !everywhere {
+		p.owns = func(namespace string) bool {
+			ctx, cancel := context.WithTimeout(context.Background(), reviewTimeout)
+			defer cancel()
+			allowed, err := api.reviewer.CanRead(ctx, user, namespace)
+			if err != nil {
+				log.Printf("Denying %s access to namespace %q: %v", user.Username, namespace, err)
+			}
+			return allowed
+		}
+	}
+	return p, nil
+}
+
+func tenantPrincipal(tenant *tenancy.Tenant) *principal {
+	p := &principal{name: "tenant " + tenant.Name}
+	if !tenant.Unrestricted() {
+		p.owns = tenant.Owns
+	}
+	return p
+}
+
+// routePath strips the method and host of a mux pattern
+func routePath(pattern string) string {
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = path
+	}
+	if i := strings.Index(pattern, "/"); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
+
+// requestPrincipal returns who the request was authenticated as, nil when
+// authentication is disabled
+func requestPrincipal(r *http.Request) *principal {
+	p, _ := r.Context().Value(principalKey{}).(*principal)
+	return p
+}
+
+// scoped reports whether the request is limited to some namespaces
+func scoped(r *http.Request) bool {
+	p := requestPrincipal(r)
+	return p != nil && p.owns != nil
+}
+
+// owns reports whether the request may see namespace
+func owns(r *http.Request, namespace string) bool {
+	p := requestPrincipal(r)
+	return p == nil || p.owns == nil || p.owns(namespace)
+}
+
+// ownsUID reports whether the request may see the resource uid. Resources
+// the store doesn't know belong to no namespace a scoped request may see.
+func (api *APIServer) ownsUID(r *http.Request, uid string) bool {
+	if !scoped(r) {
+		return true
+	}
+	resource, exists := api.store.GetByUID(uid)
+	return exists && owns(r, resource.Namespace)
+}
+
+// scopedViolations drops the violations of resources outside the request's
+// namespaces
+func (api *APIServer) scopedViolations(r *http.Request, violations []*engine.ViolationResult) []*engine.ViolationResult {
+	if !scoped(r) {
+		return violations
+	}
+	return slices.DeleteFunc(violations, func(v *engine.ViolationResult) bool {
+		return !api.ownsUID(r, v.ResourceUID)
+	})
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+)
+
+// runtimeStats is the JSON form of /api/v1/admin/runtime
+type runtimeStats struct {
+	Goroutines int       `json:"goroutines"`
+	CPUs       int       `json:"cpus"`
+	Heap       heapStats `json:"heap"`
+	GC         gcStats   `json:"gc"`
+	// Resources counts the latest state held per kind
+	Resources      map[string]int `json:"resources"`
+	TotalResources int            `json:"total_resources"`
+}
+
+type heapStats struct {
+	AllocBytes  uint64 `json:"alloc_bytes"`
+	InuseBytes  uint64 `json:"inuse_bytes"`
+	ObjectCount uint64 `json:"objects"`
+	SysBytes    uint64 `json:"sys_bytes"`
+	NextGCBytes uint64 `json:"next_gc_bytes"`
+	TotalAlloc  uint64 `json:"total_alloc_bytes"`
+}
+
+type gcStats struct {
+	Count      uint32     `json:"count"`
+	LastGC     *time.Time `json:"last_gc,omitempty"`
+	PauseTotal string     `json:"pause_total"`
+	// RecentPauses are the last pauses, newest first
+	RecentPauses []string `json:"recent_pauses"`
+	CPUFraction  float64  `json:"cpu_fraction"`
+}
+
+// recentPauses is how many GC pauses /api/v1/admin/runtime lists
+const recentPauses = 10
+
+// registerDebugRoutes serves pprof under /debug/pprof, answering 503
+// unless Config.Debug is set
+func (api *APIServer) registerDebugRoutes() {
+	api.mux.HandleFunc("/debug/pprof/", api.debugOnly(pprof.Index))
+	api.mux.HandleFunc("/debug/pprof/cmdline", api.debugOnly(pprof.Cmdline))
+	api.mux.HandleFunc("/debug/pprof/profile", api.debugOnly(pprof.Profile))
+	api.mux.HandleFunc("/debug/pprof/symbol", api.debugOnly(pprof.Symbol))
+	api.mux.HandleFunc("/debug/pprof/trace", api.debugOnly(pprof.Trace))
+	api.mux.HandleFunc("/api/v1/admin/runtime", api.debugOnly(api.handleRuntimeStats))
+}
+
+func (api *APIServer) debugOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !api.config.Debug {
+			writeProblem(w, http.StatusServiceUnavailable, CodeNotEnabled, "Debug endpoints are not enabled")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// GET /api/v1/admin/runtime reports goroutines, heap, GC pauses and the
+// resources held by the store, to tell slow evaluation or ingestion
+// apart from memory pressure
+func (api *APIServer) handleRuntimeStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := runtimeStats{
+		Goroutines: runtime.NumGoroutine(),
+		CPUs:       runtime.GOMAXPROCS(0),
+		Heap: heapStats{
+			AllocBytes:  mem.HeapAlloc,
+			InuseBytes:  mem.HeapInuse,
+			ObjectCount: mem.HeapObjects,
+			SysBytes:    mem.Sys,
+			NextGCBytes: mem.NextGC,
+			TotalAlloc:  mem.TotalAlloc,
+		},
+		GC: gcStats{
+			Count:        mem.NumGC,
+			PauseTotal:   time.Duration(mem.PauseTotalNs).String(),
+			RecentPauses: make([]string, 0, recentPauses),
+			CPUFraction:  mem.GCCPUFraction,
+		},
+		Resources: make(map[string]int),
+	}
+	if mem.LastGC > 0 {
+		last := time.Unix(0, int64(mem.LastGC))
+		stats.GC.LastGC = &last
+	}
+	// PauseNs is a ring buffer, the latest pause at (NumGC+255)%256
+	for i := uint32(0); i < min(mem.NumGC, recentPauses); i++ {
+		pause := mem.PauseNs[(mem.NumGC-1-i)%uint32(len(mem.PauseNs))]
+		stats.GC.RecentPauses = append(stats.GC.RecentPauses, time.Duration(pause).String())
+	}
+
+	if lister, ok := api.store.(state.KindLister); ok {
+		kinds := lister.Kinds()
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			n := len(api.store.GetLatestByKind(kind))
+			stats.Resources[kind] = n
+			stats.TotalResources += n
+		}
+	}
+	api.respondJSON(w, stats)
+}
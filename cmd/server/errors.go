@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ErrorCode is the machine-readable reason carried by every error response
+type ErrorCode string
+
+const (
+	CodeInvalidRequest   ErrorCode = "invalid_request"
+	CodeInvalidInvariant ErrorCode = "invalid_invariant"
+	CodeUnauthorized     ErrorCode = "unauthorized"
+	CodeForbidden        ErrorCode = "forbidden"
+	CodeReadOnly         ErrorCode = "read_only"
+	CodeResourceNotFound ErrorCode = "resource_not_found"
+	CodeMethodNotAllowed ErrorCode = "method_not_allowed"
+	CodeConflict         ErrorCode = "conflict"
+	// CodeIdempotencyKeyReused rejects a key sent with a different request
+	CodeIdempotencyKeyReused ErrorCode = "idempotency_key_reused"
+	CodePayloadTooLarge      ErrorCode = "payload_too_large"
+	CodeInternal             ErrorCode = "internal_error"
+	CodeStorageUnavailable   ErrorCode = "storage_unavailable"
+	CodeNotEnabled           ErrorCode = "not_enabled"
+	// CodeOverloaded sheds evaluation requests beyond the server's limits
+	CodeOverloaded ErrorCode = "overloaded"
+	// CodeAuthUnavailable reports a failed Kubernetes access review
+	CodeAuthUnavailable ErrorCode = "authorization_unavailable"
+)
+
+// statusCodes is the code used for a status when the handler names none
+var statusCodes = map[int]ErrorCode{
+	http.StatusBadRequest:            CodeInvalidRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeResourceNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusServiceUnavailable:    CodeStorageUnavailable,
+}
+
+// Problem is an RFC 7807 problem details body. Type is always about:blank,
+// so Title is the status text; Code tells errors with one status apart.
+type Problem struct {
+	Type   string    `json:"type"`
+	Title  string    `json:"title"`
+	Status int       `json:"status"`
+	Detail string    `json:"detail,omitempty"`
+	Code   ErrorCode `json:"code"`
+	// InvalidParams names the rejected inputs of a 400
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+// writeError replaces http.Error, deriving the code from the status
+func writeError(w http.ResponseWriter, detail string, status int) {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = CodeInternal
+	}
+	writeProblem(w, status, code, detail)
+}
+
+// storageError reports a failed store operation
+func storageError(w http.ResponseWriter, err error) {
+	writeProblem(w, http.StatusInternalServerError, CodeStorageUnavailable, err.Error())
+}
+
+func writeProblem(w http.ResponseWriter, status int, code ErrorCode, detail string) {
+	respondProblem(w, Problem{Status: status, Detail: detail, Code: code})
+}
+
+// writeInvalidParams rejects a request naming every invalid input
+func writeInvalidParams(w http.ResponseWriter, params ...InvalidParam) {
+	detail := params[0].Error()
+	if len(params) > 1 {
+		detail = fmt.Sprintf("%s, and %d more", detail, len(params)-1)
+	}
+	respondProblem(w, Problem{
+		Status:        http.StatusBadRequest,
+		Detail:        detail,
+		Code:          CodeInvalidRequest,
+		InvalidParams: params,
+	})
+}
+
+func respondProblem(w http.ResponseWriter, problem Problem) {
+	problem.Type = "about:blank"
+	problem.Title = http.StatusText(problem.Status)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,361 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/graphql"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// graphqlRequest is the per-request state resolvers share: the HTTP
+// request, for namespace scoping, and the live violations, evaluated at
+// most once however many fields select them
+type graphqlRequest struct {
+	r          *http.Request
+	once       sync.Once
+	violations []*engine.ViolationResult
+}
+
+type graphqlKey struct{}
+
+func graphqlRequestOf(ctx context.Context) *graphqlRequest {
+	return ctx.Value(graphqlKey{}).(*graphqlRequest)
+}
+
+// liveViolations evaluates every invariant on first use
+func (api *APIServer) liveViolations(ctx context.Context) []*engine.ViolationResult {
+	req := graphqlRequestOf(ctx)
+	req.once.Do(func() {
+		req.violations = api.scopedViolations(req.r, engine.FilterByStatus(api.engine.EvaluateAll(), engine.StatusViolated))
+		api.sortViolations(req.violations, engine.SortSeverity)
+	})
+	return req.violations
+}
+
+// GET /graphql?query={violations{invariantId}}
+// POST /graphql {"query": "...", "variables": {...}}
+// Read-only GraphQL over resources, violations, invariants, history and
+// causal chains, for dashboards that want one nested query instead of
+// several REST calls
+func (api *APIServer) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if variables := q.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeError(w, "variables must be a JSON object", http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Query == "" {
+		writeError(w, "query is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), graphqlKey{}, &graphqlRequest{r: r})
+	api.respondJSON(w, api.graphql.Execute(ctx, req))
+}
+
+// graphqlSchema builds the schema the /graphql endpoint answers
+func (api *APIServer) graphqlSchema() *graphql.Schema {
+	resource := &graphql.Object{Name: "Resource"}
+	violation := &graphql.Object{Name: "Violation"}
+	invariant := &graphql.Object{Name: "Invariant"}
+	link := &graphql.Object{Name: "CausalLink", Fields: map[string]*graphql.Field{
+		"invariantId": mapKey("invariant_id"),
+		"description": mapKey("description"),
+		"severity":    mapKey("severity"),
+		"actor":       mapKey("actor"),
+		"relation":    mapKey("relation"),
+	}}
+
+	// resolveResource returns the resource with uid, nil when the request
+	// may not see it
+	resolveResource := func(ctx context.Context, uid string) interface{} {
+		res, exists := api.store.GetByUID(uid)
+		if !exists || !owns(graphqlRequestOf(ctx).r, res.Namespace) {
+			return nil
+		}
+		return res
+	}
+
+	resource.Fields = map[string]*graphql.Field{
+		"uid":           resourceField(func(r types.StateEvent) interface{} { return r.UID }),
+		"kind":          resourceField(func(r types.StateEvent) interface{} { return r.Kind }),
+		"namespace":     resourceField(func(r types.StateEvent) interface{} { return r.Namespace }),
+		"name":          resourceField(func(r types.StateEvent) interface{} { return r.Name }),
+		"labels":        resourceField(func(r types.StateEvent) interface{} { return r.Labels }),
+		"fields":        resourceField(func(r types.StateEvent) interface{} { return r.FieldDiff }),
+		"actor":         resourceField(func(r types.StateEvent) interface{} { return r.Actor }),
+		"version":       resourceField(func(r types.StateEvent) interface{} { return r.Version }),
+		"timestamp":     resourceField(func(r types.StateEvent) interface{} { return r.Timestamp }),
+		"correlationId": resourceField(func(r types.StateEvent) interface{} { return r.CorrelationID }),
+		"history": {
+			Type: graphql.List{Of: resource},
+			Args: map[string]graphql.Type{"limit": graphql.Int},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				reader, ok := api.store.(state.HistoryReader)
+				if !ok {
+					return nil, errors.New("history not supported by this store")
+				}
+				limit, err := graphqlLimit(args, 50)
+				if err != nil {
+					return nil, err
+				}
+				return reader.GetHistory(source.(types.StateEvent).UID, limit)
+			},
+		},
+		"violations": {
+			Type: graphql.List{Of: violation},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				uid := source.(types.StateEvent).UID
+				matched := make([]*engine.ViolationResult, 0)
+				for _, v := range api.liveViolations(ctx) {
+					if v.ResourceUID == uid {
+						matched = append(matched, v)
+					}
+				}
+				return matched, nil
+			},
+		},
+	}
+
+	violation.Fields = map[string]*graphql.Field{
+		"invariantId":      violationField(func(v *engine.ViolationResult) interface{} { return v.InvariantID }),
+		"status":           violationField(func(v *engine.ViolationResult) interface{} { return v.Status }),
+		"reason":           violationField(func(v *engine.ViolationResult) interface{} { return v.Reason }),
+		"severity":         violationField(func(v *engine.ViolationResult) interface{} { return v.Severity }),
+		"affectedResource": violationField(func(v *engine.ViolationResult) interface{} { return v.AffectedResource }),
+		"resourceUid":      violationField(func(v *engine.ViolationResult) interface{} { return v.ResourceUID }),
+		"responsibleActor": violationField(func(v *engine.ViolationResult) interface{} { return v.ResponsibleActor }),
+		"detectedAt":       violationField(func(v *engine.ViolationResult) interface{} { return v.DetectedAt }),
+		"impact":           violationField(func(v *engine.ViolationResult) interface{} { return v.Impact }),
+		"tier":             violationField(func(v *engine.ViolationResult) interface{} { return v.Tier }),
+		"correlationId":    violationField(func(v *engine.ViolationResult) interface{} { return v.CorrelationID }),
+		"invariant": {
+			Type: invariant,
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				if inv, exists := api.engine.GetInvariantByID(source.(*engine.ViolationResult).InvariantID); exists {
+					return inv, nil
+				}
+				return nil, nil
+			},
+		},
+		"resource": {
+			Type: resource,
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				return resolveResource(ctx, source.(*engine.ViolationResult).ResourceUID), nil
+			},
+		},
+	}
+
+	invariant.Fields = map[string]*graphql.Field{
+		"id":          invariantField(func(inv dsl.Invariant) interface{} { return inv.ID }),
+		"version":     invariantField(func(inv dsl.Invariant) interface{} { return inv.Version }),
+		"description": invariantField(func(inv dsl.Invariant) interface{} { return inv.Description }),
+		"severity":    invariantField(func(inv dsl.Invariant) interface{} { return inv.Severity }),
+		"subjectKind": invariantField(func(inv dsl.Invariant) interface{} { return inv.Subject.Kind }),
+		"tags": {
+			Type: graphql.List{Of: graphql.String},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				return source.(dsl.Invariant).Tags, nil
+			},
+		},
+		"violations": {
+			Type: graphql.List{Of: violation},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				id := source.(dsl.Invariant).ID
+				matched := make([]*engine.ViolationResult, 0)
+				for _, v := range api.liveViolations(ctx) {
+					if v.InvariantID == id {
+						matched = append(matched, v)
+					}
+				}
+				return matched, nil
+			},
+		},
+		"causalChain": {
+			Type: graphql.List{Of: link},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				return api.buildCausalChain(source.(dsl.Invariant).ID), nil
+			},
+		},
+	}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"resources": {
+			Type: graphql.List{Of: resource},
+			Args: map[string]graphql.Type{
+				"kind":      graphql.NonNull{Of: graphql.String},
+				"namespace": graphql.String,
+				"limit":     graphql.Int,
+			},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				limit, err := graphqlLimit(args, 100)
+				if err != nil {
+					return nil, err
+				}
+				namespace, _ := args["namespace"].(string)
+				r := graphqlRequestOf(ctx).r
+				matched := make([]types.StateEvent, 0)
+				for _, res := range api.store.GetLatestByKind(args["kind"].(string)) {
+					if len(matched) == limit {
+						break
+					}
+					if (namespace == "" || res.Namespace == namespace) && owns(r, res.Namespace) {
+						matched = append(matched, res)
+					}
+				}
+				return matched, nil
+			},
+		},
+		"resource": {
+			Type: resource,
+			Args: map[string]graphql.Type{"uid": graphql.NonNull{Of: graphql.String}},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				return resolveResource(ctx, args["uid"].(string)), nil
+			},
+		},
+		"violations": {
+			Type: graphql.List{Of: violation},
+			Args: map[string]graphql.Type{
+				"severity":    graphql.String,
+				"invariantId": graphql.String,
+				"namespace":   graphql.String,
+				"limit":       graphql.Int,
+			},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				limit, err := graphqlLimit(args, 100)
+				if err != nil {
+					return nil, err
+				}
+				severity, _ := args["severity"].(string)
+				invariantID, _ := args["invariantId"].(string)
+				namespace, _ := args["namespace"].(string)
+				matched := make([]*engine.ViolationResult, 0)
+				for _, v := range api.liveViolations(ctx) {
+					if len(matched) == limit {
+						break
+					}
+					if severity != "" && string(v.Severity) != severity || invariantID != "" && v.InvariantID != invariantID {
+						continue
+					}
+					if namespace != "" {
+						if res, exists := api.store.GetByUID(v.ResourceUID); !exists || res.Namespace != namespace {
+							continue
+						}
+					}
+					matched = append(matched, v)
+				}
+				return matched, nil
+			},
+		},
+		"invariants": {
+			Type: graphql.List{Of: invariant},
+			Args: map[string]graphql.Type{"tag": graphql.String},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				var tags []string
+				if tag, _ := args["tag"].(string); tag != "" {
+					tags = []string{tag}
+				}
+				matched := make([]dsl.Invariant, 0)
+				for _, inv := range api.engine.GetInvariants() {
+					if inv.HasAnyTag(tags) {
+						matched = append(matched, inv)
+					}
+				}
+				return matched, nil
+			},
+		},
+		"invariant": {
+			Type: invariant,
+			Args: map[string]graphql.Type{"id": graphql.NonNull{Of: graphql.String}},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				if inv, exists := api.engine.GetInvariantByID(args["id"].(string)); exists {
+					return inv, nil
+				}
+				return nil, nil
+			},
+		},
+		"causalChain": {
+			Type: graphql.List{Of: link},
+			Args: map[string]graphql.Type{"invariantId": graphql.NonNull{Of: graphql.String}},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				return api.buildCausalChain(args["invariantId"].(string)), nil
+			},
+		},
+	}}
+	return &graphql.Schema{Query: query}
+}
+
+// graphqlLimit reads a limit argument, bounded like the REST endpoints'
+func graphqlLimit(args map[string]interface{}, def int) (int, error) {
+	limit, ok := args["limit"].(int)
+	if !ok {
+		return def, nil
+	}
+	if limit < 1 || limit > maxLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+	return limit, nil
+}
+
+func resourceField(get func(types.StateEvent) interface{}) *graphql.Field {
+	return &graphql.Field{Type: scalarFor(get(types.StateEvent{})), Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+		return get(source.(types.StateEvent)), nil
+	}}
+}
+
+func violationField(get func(*engine.ViolationResult) interface{}) *graphql.Field {
+	return &graphql.Field{Type: scalarFor(get(&engine.ViolationResult{})), Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+		return get(source.(*engine.ViolationResult)), nil
+	}}
+}
+
+func invariantField(get func(dsl.Invariant) interface{}) *graphql.Field {
+	return &graphql.Field{Type: scalarFor(get(dsl.Invariant{})), Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+		return get(source.(dsl.Invariant)), nil
+	}}
+}
+
+// mapKey reads a field of a map source under its JSON key
+func mapKey(key string) *graphql.Field {
+	return &graphql.Field{Type: graphql.String, Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+		return source.(map[string]interface{})[key], nil
+	}}
+}
+
+// scalarFor picks the scalar type of a field from its zero value
+func scalarFor(zero interface{}) graphql.Type {
+	switch zero.(type) {
+	case int:
+		return graphql.Int
+	case float64:
+		return graphql.Float
+	case bool:
+		return graphql.Boolean
+	case map[string]string, map[string]interface{}:
+		return graphql.JSON
+	}
+	return graphql.String
+}
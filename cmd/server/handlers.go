@@ -1,84 +1,205 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/aonescu/akari/internal/capacity"
+	"github.com/aonescu/akari/internal/causality"
+	"github.com/aonescu/akari/internal/changes"
+	"github.com/aonescu/akari/internal/cloud"
+	"github.com/aonescu/akari/internal/criticality"
 	"github.com/aonescu/akari/internal/db"
+	"github.com/aonescu/akari/internal/diagram"
+	"github.com/aonescu/akari/internal/dsl"
 	"github.com/aonescu/akari/internal/engine"
 	"github.com/aonescu/akari/internal/formatting"
+	"github.com/aonescu/akari/internal/paging"
+	"github.com/aonescu/akari/internal/quality"
+	"github.com/aonescu/akari/internal/slo"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/suggest"
+	"github.com/aonescu/akari/internal/timeline"
+	"github.com/aonescu/akari/internal/topology"
 	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
 )
 
-// GET /api/v1/violations?severity=critical&limit=50
+// GET /api/v1/violations?severity=critical&limit=50&sort=impact&tags=security,cost&logical_resource=Pod/shop/Deployment/api&group_by=namespace,resource
 func (api *APIServer) handleViolations(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-
-	severity := r.URL.Query().Get("severity")
-	limitStr := r.URL.Query().Get("limit")
-	limit := 100
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil {
-			limit = l
-		}
+	params := newQueryParams(r)
+	order := params.sort()
+	severity := params.severity()
+	limit := params.limit(100)
+	groupBy := params.groupBy()
+	if !params.valid(w) {
+		return
 	}
 
+	tags := parseTags(r)
+	logicalResource := r.URL.Query().Get("logical_resource")
 	var violations []*engine.ViolationResult
 
 	if pgStore, ok := api.store.(*db.PostgresStore); ok {
-		// Get from database
-		dbViolations, err := pgStore.GetViolations(severity, limit)
+		// Tenants' violations are filtered after loading, so load the most
+		// allowed and limit them below
+		fetch := limit
+		if scoped(r) {
+			fetch = maxLimit
+		}
+		dbViolations, err := pgStore.QueryViolations(db.ViolationQuery{
+			Severity:        severity,
+			InvariantIDs:    api.taggedInvariants(tags),
+			LogicalResource: logicalResource,
+		}, order, fetch)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			storageError(w, err)
 			return
 		}
-		violations = dbViolations
+		violations = api.scopedViolations(r, dbViolations)
 	} else {
-		// Get from live evaluation
-		violations = api.engine.EvaluateAll()
+		release, ok := api.acquireEvaluation(w, r)
+		if !ok {
+			return
+		}
+		defer release()
+		// Get from live evaluation; unknown and errored results are
+		// reported by /invariants/evaluate and /stats instead
+		violations = api.scopedViolations(r, engine.FilterByStatus(api.engine.EvaluateAll(), engine.StatusViolated))
 
 		// Filter by severity if specified
 		if severity != "" {
 			filtered := make([]*engine.ViolationResult, 0)
 			for _, v := range violations {
-				if v.Violated && string(v.Severity) == severity {
+				if string(v.Severity) == severity {
 					filtered = append(filtered, v)
 				}
 			}
 			violations = filtered
 		}
+	}
 
-		// Limit results
-		if len(violations) > limit {
-			violations = violations[:limit]
-		}
+	// Filter before limiting, so the limit counts only matching violations,
+	// keeping those first in the requested order
+	violations = api.correlate(r, api.engine.FilterByTags(violations, tags))
+	if logicalResource != "" {
+		violations = slices.DeleteFunc(violations, func(v *engine.ViolationResult) bool { return v.LogicalResource != logicalResource })
+	}
+	api.sortViolations(violations, order)
+	if len(violations) > limit {
+		violations = violations[:limit]
 	}
 
+	if len(groupBy) > 0 {
+		api.respondJSON(w, map[string]interface{}{
+			"group_by": groupBy,
+			"total":    len(violations),
+			"groups":   engine.GroupViolations(violations, groupBy),
+		})
+		return
+	}
 	api.respondJSON(w, violations)
 }
 
-// GET /api/v1/violations/active
+// taggedInvariants returns the IDs of the invariants carrying any of tags,
+// nil when no tag is asked for
+func (api *APIServer) taggedInvariants(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	ids := make([]string, 0)
+	for _, inv := range api.engine.GetInvariants() {
+		if inv.HasAnyTag(tags) {
+			ids = append(ids, inv.ID)
+		}
+	}
+	return ids
+}
+
+// correlate folds image pull failures sharing a registry into one
+// registry_unreachable violation, and floods of one invariant into one
+// violation, unless the request asks for ?correlate=false, then rolls pod
+// violations up to their workloads on ?aggregate=workloads
+func (api *APIServer) correlate(r *http.Request, violations []*engine.ViolationResult) []*engine.ViolationResult {
+	if r.URL.Query().Get("correlate") != "false" {
+		violations = api.engine.CollapseFloods(api.engine.CorrelateRegistries(violations))
+	}
+	if r.URL.Query().Get("aggregate") == "workloads" {
+		violations = api.engine.AggregateWorkloads(violations)
+	}
+	return violations
+}
+
+// attachSuspects adds the changes recorded shortly before each violation
+// unless the request opts out with suspects=false
+func (api *APIServer) attachSuspects(r *http.Request, violations []*engine.ViolationResult) {
+	if r.URL.Query().Get("suspects") == "false" {
+		return
+	}
+	api.engine.AttachSuspects(violations)
+}
+
+// sortViolations orders violations in place. Impact is weighed first so
+// violations loaded from the database rank by their resource's tier.
+func (api *APIServer) sortViolations(violations []*engine.ViolationResult, order engine.SortOrder) {
+	if order == engine.SortImpact {
+		api.engine.Weigh(violations)
+	}
+	engine.SortViolations(violations, order)
+}
+
+// GET /api/v1/violations/active?sort=impact&tags=availability&aggregate=workloads&wait=30s
+// With If-Modified-Since, waits up to wait for the violations to change
 func (api *APIServer) handleActiveViolations(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := newQueryParams(r)
+	order := params.sort()
+	wait := params.duration("wait")
+	if wait > maxLongPoll {
+		params.check(invalidParam("wait", fmt.Sprintf("must be at most %s", maxLongPoll)))
+	}
+	if !params.valid(w) {
+		return
+	}
+	if !api.awaitModified(w, r, wait) {
 		return
 	}
 
 	if pgStore, ok := api.store.(*db.PostgresStore); ok {
 		violations, err := pgStore.GetActiveViolations()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			storageError(w, err)
 			return
 		}
+		violations = api.scopedViolations(r, violations)
+		violations = api.correlate(r, api.engine.FilterByTags(violations, parseTags(r)))
+		api.attachSuspects(r, violations)
+		api.sortViolations(violations, order)
 		api.respondJSON(w, violations)
 	} else {
-		// Fall back to current evaluation
+		// Fall back to current evaluation, once any long poll is over
+		release, ok := api.acquireEvaluation(w, r)
+		if !ok {
+			return
+		}
+		defer release()
 		violations := api.engine.EvaluateAll()
 		active := make([]*engine.ViolationResult, 0)
 		for _, v := range violations {
@@ -86,15 +207,247 @@ func (api *APIServer) handleActiveViolations(w http.ResponseWriter, r *http.Requ
 				active = append(active, v)
 			}
 		}
+		active = api.scopedViolations(r, active)
+		active = api.correlate(r, api.engine.FilterByTags(active, parseTags(r)))
+		api.attachSuspects(r, active)
+		api.sortViolations(active, order)
 		api.respondJSON(w, active)
 	}
 }
 
+// maxLongPoll bounds how long a request may wait for violations to change
+const maxLongPoll = 60 * time.Second
+
+// awaitModified answers conditional requests for the active violations,
+// which change with every transition the stream publishes. When nothing
+// changed since If-Modified-Since it waits up to wait for a transition,
+// then responds 304 Not Modified and returns false if none came.
+func (api *APIServer) awaitModified(w http.ResponseWriter, r *http.Request, wait time.Duration) bool {
+	modified, changed := api.streams.lastModified()
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.After(since) {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-changed:
+			modified, _ = api.streams.lastModified()
+		case <-timer.C:
+			w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusNotModified)
+			return false
+		case <-r.Context().Done():
+			return false
+		}
+	}
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	return true
+}
+
+// GET /api/v1/violations/stream
+// Server-sent events, one per transition: "event: opened|resolved" with the
+// violation as JSON data. Comments keep idle connections alive.
+func (api *APIServer) handleViolationStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := api.streams.subscribe()
+	defer api.streams.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case t := <-ch:
+			data, err := json.Marshal(t)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", t.Type, data)
+			flusher.Flush()
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// streamKeepalive is how often idle violation streams send a comment so
+// proxies don't close them
+const streamKeepalive = 15 * time.Second
+
+// POST /api/v1/evaluate/resource
+// Body: a StateEvent. Evaluates the matching invariants against the posted
+// state without recording it, e.g. to check a manifest before applying it.
+func (api *APIServer) handleEvaluateResource(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var resource types.StateEvent
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if resource.Kind == "" {
+		writeError(w, "kind is required", http.StatusBadRequest)
+		return
+	}
+	if resource.Timestamp.IsZero() {
+		resource.Timestamp = time.Now()
+	}
+
+	// Satisfied invariants produce no result, so list what was checked
+	checked := make([]string, 0)
+	for _, inv := range api.engine.GetInvariants() {
+		if api.engine.Matches(inv.Subject, resource) {
+			checked = append(checked, inv.ID)
+		}
+	}
+	sort.Strings(checked)
+
+	results := api.engine.EvaluateResource(resource)
+	api.respondJSON(w, map[string]interface{}{
+		"invariants": checked,
+		"results":    results,
+		"violations": engine.FilterByStatus(results, engine.StatusViolated),
+	})
+}
+
+// POST /api/v1/sandbox/evaluate
+// Body: {"invariant": {...}, "resource": {...}, "related": [...]}. Evaluates
+// an inline invariant against an inline resource in a store of their own,
+// so neither is registered or recorded and recorded state plays no part.
+// Related resources stand in for the ones the invariant requires. Nested
+// full_state fields are flattened into field_diff as dotted paths.
+func (api *APIServer) handleSandboxEvaluate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Invariant dsl.Invariant      `json:"invariant"`
+		Resource  types.StateEvent   `json:"resource"`
+		Related   []types.StateEvent `json:"related"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	invariant, err := api.engine.ResolveExtends(req.Invariant)
+	if err == nil {
+		err = invariant.Validate()
+	}
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, CodeInvalidInvariant, err.Error())
+		return
+	}
+	resources := append([]types.StateEvent{req.Resource}, req.Related...)
+	for i := range resources {
+		resource, err := sandboxResource(resources[i], i)
+		if err != nil {
+			badRequest(w, err)
+			return
+		}
+		resources[i] = resource
+	}
+
+	store := state.NewMemoryStore()
+	for _, resource := range resources {
+		store.Record(resource)
+	}
+	sandbox := engine.NewInvariantEngine(store)
+	inv := sandbox.UpsertInvariant(invariant)
+
+	matched := sandbox.Matches(inv.Subject, resources[0])
+	response := map[string]interface{}{
+		"invariant": inv.ID,
+		"resource":  resources[0],
+		"matched":   matched,
+		"status":    engine.StatusSatisfied,
+	}
+	if !matched {
+		response["status"] = "not_matched"
+		response["reason"] = "The resource is not a subject of the invariant"
+		api.respondJSON(w, response)
+		return
+	}
+	for _, result := range sandbox.Evaluate(inv) {
+		if result != nil && result.ResourceUID == resources[0].UID {
+			response["status"] = result.Status
+			response["reason"] = result.Reason
+			response["result"] = result
+		}
+	}
+	api.respondJSON(w, response)
+}
+
+// sandboxResource fills in the identity of an inline resource, the i-th of
+// a sandbox request, and flattens its full state into its fields
+func sandboxResource(resource types.StateEvent, i int) (types.StateEvent, error) {
+	name := "resource"
+	if i > 0 {
+		name = fmt.Sprintf("related[%d]", i-1)
+	}
+	if resource.Kind == "" {
+		return resource, invalidParam(name+".kind", "is required")
+	}
+	if err := validateKind(name+".kind", resource.Kind); err != nil {
+		return resource, err
+	}
+	if resource.UID == "" {
+		resource.UID = fmt.Sprintf("sandbox-%d", i)
+	}
+	if resource.Name == "" {
+		resource.Name = resource.UID
+	}
+	if resource.Timestamp.IsZero() {
+		resource.Timestamp = time.Now()
+	}
+	fields := make(map[string]interface{}, len(resource.FieldDiff))
+	if full, ok := resource.FullState.(map[string]interface{}); ok {
+		flattenFields("", full, fields)
+	}
+	for path, value := range resource.FieldDiff {
+		fields[path] = value
+	}
+	resource.FieldDiff = fields
+	return resource, nil
+}
+
+// flattenFields adds the leaves of a nested map to fields under their
+// dotted paths
+func flattenFields(prefix string, tree map[string]interface{}, fields map[string]interface{}) {
+	for key, value := range tree {
+		if child, ok := value.(map[string]interface{}); ok {
+			flattenFields(prefix+key+".", child, fields)
+			continue
+		}
+		fields[prefix+key] = value
+	}
+}
+
 // POST /api/v1/explain
 // Body: {"kind": "Pod", "namespace": "default", "name": "api-pod"}
 func (api *APIServer) handleExplain(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -105,7 +458,7 @@ func (api *APIServer) handleExplain(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
@@ -120,7 +473,7 @@ func (api *APIServer) handleExplain(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if target == nil {
-		http.Error(w, "Resource not found", http.StatusNotFound)
+		writeError(w, "Resource not found", http.StatusNotFound)
 		return
 	}
 
@@ -155,16 +508,23 @@ func (api *APIServer) handleExplain(w http.ResponseWriter, r *http.Request) {
 // GET /api/v1/explain/resource?kind=Pod&namespace=default&name=api-pod
 func (api *APIServer) handleExplainResource(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	kind := r.URL.Query().Get("kind")
+	params := newQueryParams(r)
+	kind := params.kind()
 	namespace := r.URL.Query().Get("namespace")
 	name := r.URL.Query().Get("name")
-
+	if !params.valid(w) {
+		return
+	}
 	if kind == "" || name == "" {
-		http.Error(w, "kind and name are required", http.StatusBadRequest)
+		writeError(w, "kind and name are required", http.StatusBadRequest)
+		return
+	}
+	if !owns(r, namespace) {
+		writeError(w, "Resource not found", http.StatusNotFound)
 		return
 	}
 
@@ -179,7 +539,7 @@ func (api *APIServer) handleExplainResource(w http.ResponseWriter, r *http.Reque
 	}
 
 	if target == nil {
-		http.Error(w, "Resource not found", http.StatusNotFound)
+		writeError(w, "Resource not found", http.StatusNotFound)
 		return
 	}
 
@@ -210,165 +570,2654 @@ func (api *APIServer) handleExplainResource(w http.ResponseWriter, r *http.Reque
 	api.respondJSON(w, response)
 }
 
-// GET /api/v1/causal-chain?violation_id=uuid
-func (api *APIServer) handleCausalChain(w http.ResponseWriter, r *http.Request) {
+// GET /api/v1/explain/invariant?invariant_id=pod_ready&uid=pod-123
+// Runs one invariant against one resource and returns the decision trace:
+// the values compared, dependency outcomes and how the responsible actor
+// was chosen, including why a resource is not violating
+func (api *APIServer) handleExplainInvariant(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	invariantID := r.URL.Query().Get("invariant_id")
-	if invariantID == "" {
-		http.Error(w, "invariant_id is required", http.StatusBadRequest)
+	params := newQueryParams(r)
+	invariantID := params.values.Get("invariant_id")
+	uid := params.uid()
+	if !params.valid(w) {
+		return
+	}
+	if invariantID == "" || uid == "" {
+		writeError(w, "invariant_id and uid are required", http.StatusBadRequest)
 		return
 	}
 
-	// Build causal chain
-	chain := api.buildCausalChain(invariantID)
-
-	response := map[string]interface{}{
-		"invariant_id": invariantID,
-		"chain":        chain,
-		"depth":        len(chain),
+	inv, exists := api.engine.GetInvariantByID(invariantID)
+	if !exists {
+		writeError(w, "Invariant not found", http.StatusNotFound)
+		return
+	}
+	resource, exists := api.store.GetByUID(uid)
+	if !exists || !owns(r, resource.Namespace) {
+		writeError(w, "Resource not found", http.StatusNotFound)
+		return
 	}
 
-	api.respondJSON(w, response)
+	api.respondJSON(w, api.engine.Explain(inv, resource))
 }
 
-// GET /api/v1/history?uid=pod-123&limit=20
-func (api *APIServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+// GET /api/v1/graph?namespace=prod&violations=false
+// The object graph of the recorded resources: owner, scheduled-on, selects
+// and mounts edges, with the invariants each resource currently violates
+// unless violations=false
+func (api *APIServer) handleGraph(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-
-	uid := r.URL.Query().Get("uid")
-	if uid == "" {
-		http.Error(w, "uid is required", http.StatusBadRequest)
+	lister, ok := api.store.(state.KindLister)
+	if !ok {
+		writeError(w, "Graph not supported by this store", http.StatusServiceUnavailable)
 		return
 	}
+	namespace := r.URL.Query().Get("namespace")
 
-	limitStr := r.URL.Query().Get("limit")
-	limit := 20
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil {
-			limit = l
+	// Cluster-scoped resources are kept for the edges that reach them,
+	// e.g. the Nodes the namespace's pods run on
+	resources := make([]types.StateEvent, 0)
+	for _, kind := range lister.Kinds() {
+		for _, res := range api.store.GetLatestByKind(kind) {
+			if res.Namespace == "" || namespace == "" || res.Namespace == namespace {
+				resources = append(resources, res)
+			}
 		}
 	}
-
-	if pgStore, ok := api.store.(*db.PostgresStore); ok {
-		history, err := pgStore.GetHistory(uid, limit)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		api.respondJSON(w, history)
-	} else {
-		http.Error(w, "History only available with PostgreSQL storage", http.StatusServiceUnavailable)
+	graph := topology.Build(resources)
+	if namespace != "" {
+		graph.DropUnlinkedClusterScoped()
+	}
+	if r.URL.Query().Get("violations") != "false" {
+		graph.Overlay(engine.FilterByStatus(api.engine.EvaluateAll(), engine.StatusViolated))
 	}
+
+	api.respondJSON(w, graph)
 }
 
-// GET /api/v1/invariants
-func (api *APIServer) handleInvariants(w http.ResponseWriter, r *http.Request) {
+// GET /api/v1/analytics/capacity?window=168h&threshold=0.85&horizon=336h
+// Projects from the recorded node allocatable resources and pod requests
+// when the cluster will run short of CPU or memory to schedule pods on
+func (api *APIServer) handleCapacityForecast(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-
-	invariants := api.engine.GetInvariants()
-	api.respondJSON(w, invariants)
+	params := newQueryParams(r)
+	opts := capacity.Options{
+		Window:  params.duration("window"),
+		Horizon: params.duration("horizon"),
+	}
+	if v := params.values.Get("threshold"); v != "" {
+		threshold, err := strconv.ParseFloat(v, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			params.check(invalidParam("threshold", "must be a number above 0 and at most 1"))
+		}
+		opts.Threshold = threshold
+	}
+	if !params.valid(w) {
+		return
+	}
+	forecast, err := api.capacity.Forecast(time.Now(), opts)
+	if errors.Is(err, capacity.ErrNoHistory) {
+		writeError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	api.respondJSON(w, forecast)
 }
 
-// POST /api/v1/invariants/evaluate
-func (api *APIServer) handleEvaluateInvariants(w http.ResponseWriter, r *http.Request) {
+// POST /api/v1/counterfactual
+// Body: {"violation_id": "pod_ready|prod/api", "change": {"resource_uid": "node-1", "changed_at": "2026-01-01T12:00:00Z"}}
+// Replays the violation's cluster state with the change excluded and
+// reports whether the invariant would still be violated. The change is
+// one of the violation's suspect_changes.
+func (api *APIServer) handleCounterfactual(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	violations := api.engine.EvaluateAll()
-
-	response := map[string]interface{}{
-		"evaluated_at": time.Now(),
-		"total_count":  len(violations),
-		"violations":   violations,
+	var req struct {
+		ViolationID string `json:"violation_id"`
+		Change      struct {
+			ResourceUID string    `json:"resource_uid"`
+			ChangedAt   time.Time `json:"changed_at"`
+		} `json:"change"`
+		// At overrides when to replay, by default when the violation was
+		// detected
+		At *time.Time `json:"at"`
 	}
-
-	api.respondJSON(w, response)
-}
-
-// GET /health
-func (api *APIServer) handleHealth(w http.ResponseWriter, r *http.Request) {
-	health := map[string]interface{}{
-		"status": "healthy",
-		"time":   time.Now(),
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ViolationID == "" || req.Change.ResourceUID == "" || req.Change.ChangedAt.IsZero() {
+		writeError(w, "violation_id, change.resource_uid and change.changed_at are required", http.StatusBadRequest)
+		return
 	}
 
-	// Check database connection if using PostgreSQL
-	if pgStore, ok := api.store.(*db.PostgresStore); ok {
-		if err := pgStore.Ping(); err != nil {
-			health["status"] = "unhealthy"
-			health["database"] = "disconnected"
-			w.WriteHeader(http.StatusServiceUnavailable)
-		} else {
-			health["database"] = "connected"
-		}
+	violation, detectedAt := api.findViolation(req.ViolationID)
+	if violation == nil {
+		writeError(w, "Violation not found", http.StatusNotFound)
+		return
+	}
+	at := detectedAt
+	if req.At != nil {
+		at = *req.At
+	}
+	if req.Change.ChangedAt.After(at) {
+		writeError(w, "change.changed_at must not be after the violation", http.StatusBadRequest)
+		return
 	}
 
-	api.respondJSON(w, health)
+	cf, err := api.engine.Counterfactual(violation, req.Change.ResourceUID, req.Change.ChangedAt, at)
+	switch {
+	case errors.Is(err, engine.ErrNoHistory):
+		writeError(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, engine.ErrChangeNotFound):
+		writeError(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		storageError(w, err)
+	default:
+		api.respondJSON(w, cf)
+	}
 }
 
-// GET /ready
-func (api *APIServer) handleReady(w http.ResponseWriter, r *http.Request) {
-	ready := map[string]interface{}{
-		"ready":           true,
-		"invariants_load": len(api.engine.GetInvariants()) > 0,
+// findViolation looks a violation up by fingerprint among the current
+// violations, then the recorded timeline, returning when it was detected
+func (api *APIServer) findViolation(fingerprint string) (*engine.ViolationResult, time.Time) {
+	now := time.Now()
+	for _, v := range api.engine.EvaluateAll() {
+		if v != nil && v.Violated && v.Fingerprint() == fingerprint {
+			return v, now
+		}
 	}
-	api.respondJSON(w, ready)
+	if api.timeline != nil {
+		if pm, ok := api.timeline.Postmortem(fingerprint, now); ok {
+			return pm.Violation, pm.Start
+		}
+	}
+	return nil, time.Time{}
 }
 
-// GET /api/v1/stats
-func (api *APIServer) handleStats(w http.ResponseWriter, r *http.Request) {
+// GET /api/v1/causal-chain?invariant_id=pod_ready&uid=pod-123&format=json|dot|mermaid
+func (api *APIServer) handleCausalChain(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	violations := api.engine.EvaluateAll()
-
-	stats := map[string]interface{}{
-		"total_invariants": len(api.engine.GetInvariants()),
-		"total_violations": 0,
-		"by_severity": map[string]int{
-			"critical": 0,
-			"degraded": 0,
-			"warning":  0,
-		},
-		"by_actor": make(map[string]int),
+	invariantID := r.URL.Query().Get("invariant_id")
+	if invariantID == "" {
+		writeError(w, "invariant_id is required", http.StatusBadRequest)
+		return
+	}
+	params := newQueryParams(r)
+	format := params.diagramFormat()
+	if !params.valid(w) {
+		return
 	}
 
-	for _, v := range violations {
-		if v.Violated {
-			stats["total_violations"] = stats["total_violations"].(int) + 1
+	// Build causal chain
+	chain := api.buildCausalChain(invariantID)
 
-			// Count by severity
-			severityMap := stats["by_severity"].(map[string]int)
-			severityMap[string(v.Severity)]++
+	response := map[string]interface{}{
+		"invariant_id": invariantID,
+		"chain":        chain,
+		"depth":        len(chain),
+	}
 
-			// Count by actor
-			actorMap := stats["by_actor"].(map[string]int)
-			actorMap[v.ResponsibleActor]++
+	// Link image-related pod failures to the rollout that shipped the image
+	if uid := r.URL.Query().Get("uid"); uid != "" {
+		if err := validateUID("uid", uid); err != nil {
+			badRequest(w, err)
+			return
+		}
+		if changes := api.imageChangesForPod(uid); len(changes) > 0 {
+			response["image_changes"] = changes
+		}
+		// Spot reclaims and host failures explain NotReady nodes and the
+		// pods scheduled on them
+		if events := api.cloudEventsForResource(uid); len(events) > 0 {
+			response["cloud_events"] = events
+		}
+		// Deploys that shipped the images the resource runs
+		if resource, exists := api.store.GetByUID(uid); exists {
+			if deploys := changes.ForResource(api.store, resource); len(deploys) > 0 {
+				response["deploys"] = deploys
+			}
+		}
+		// Evictions trace back to the node condition that forced them. A
+		// recreated pod inherits the cause from the incarnation evicted
+		// before it.
+		previous := slices.DeleteFunc(api.incarnations(uid), func(u string) bool { return u == uid })
+		if len(previous) > 0 {
+			response["incarnations"] = previous
+		}
+		if resolver, ok := api.store.(state.IdentityResolver); ok {
+			if key, ok := resolver.LogicalKey(uid); ok {
+				response["logical_resource"] = key
+			}
+		}
+		slices.Reverse(previous)
+		for _, u := range append([]string{uid}, previous...) {
+			if cause := api.evictionCause(u); cause != nil {
+				response["eviction_cause"] = cause
+				break
+			}
+		}
+		// Changes before the violation ranked by how likely they caused it
+		if causes := api.rankCauses(invariantID, uid); len(causes) > 0 {
+			response["causes"] = causes
+		}
+		// Failing upstream applications declared as dependencies
+		if resource, exists := api.store.GetByUID(uid); exists {
+			if app, ok := api.apps.ApplicationFor(resource); ok {
+				response["application"] = app.Name
+				if causes := api.apps.Analyze(app.Name, api.store, api.engine.EvaluateAll()); len(causes) > 0 {
+					response["dependency_causes"] = causes
+				}
+			}
 		}
 	}
 
-	api.respondJSON(w, stats)
+	if format == diagram.DOT || format == diagram.Mermaid {
+		causes, _ := response["causes"].([]causality.Cause)
+		api.respondDiagram(w, format, api.chainDiagram(invariantID, r.URL.Query().Get("uid"), chain, causes))
+		return
+	}
+	api.respondJSON(w, response)
 }
 
-func (api *APIServer) buildCausalChain(invariantID string) []map[string]interface{} {
-	chain := make([]map[string]interface{}, 0)
+// maxDiagramCauses bounds the ranked causes drawn in causal chain diagrams
+const maxDiagramCauses = 5
 
-	inv, exists := api.engine.GetInvariantByID(invariantID)
-	if !exists {
+// chainDiagram draws a causal chain with arrows from each cause to what it
+// affects: required and blocking invariants to the chain's invariant, and
+// the top ranked changes to it. Invariants currently violated, on uid when
+// given, are highlighted.
+func (api *APIServer) chainDiagram(invariantID, uid string, chain []map[string]interface{}, causes []causality.Cause) *diagram.Diagram {
+	violated := make(map[string]bool)
+	for _, v := range api.engine.EvaluateAll() {
+		if v != nil && v.Violated && (uid == "" || v.ResourceUID == uid) {
+			violated[v.InvariantID] = true
+		}
+	}
+
+	d := diagram.New(invariantID)
+	for i, link := range chain {
+		id, _ := link["invariant_id"].(string)
+		label := id
+		if description, _ := link["description"].(string); description != "" {
+			label += "\n" + description
+		}
+		d.AddNode(diagram.Node{ID: id, Label: label, Highlight: violated[id]})
+		if i == 0 {
+			continue
+		}
+		relation := fmt.Sprint(link["relation"])
+		if relation != "blocks" {
+			relation = "required (" + relation + ")"
+		}
+		d.AddEdge(diagram.Edge{From: id, To: invariantID, Label: relation})
+	}
+	for i, c := range causes {
+		if i == maxDiagramCauses {
+			break
+		}
+		id := fmt.Sprintf("change/%s/%d", c.ResourceUID, c.ChangedAt.UnixNano())
+		resource := strings.TrimPrefix(c.Namespace+"/"+c.Name, "/")
+		d.AddNode(diagram.Node{ID: id, Label: fmt.Sprintf("%s changed %s %s\nat %s", c.Actor, c.Kind, resource, c.ChangedAt.UTC().Format(time.RFC3339))})
+		d.AddEdge(diagram.Edge{From: id, To: invariantID, Label: fmt.Sprintf("score %.2f", c.Score)})
+	}
+	return d
+}
+
+// respondDiagram renders d in format, DOT or Mermaid
+func (api *APIServer) respondDiagram(w http.ResponseWriter, format string, d *diagram.Diagram) {
+	w.Header().Set("Content-Type", diagram.ContentTypes[format])
+	if err := d.Render(w, format); err != nil {
+		log.Printf("Warning: failed to render %s diagram: %v", format, err)
+	}
+}
+
+// rankCauses scores the changes recorded before the invariant's violation
+// on uid, learning co-occurrence from the invariant's past violations
+func (api *APIServer) rankCauses(invariantID, uid string) []causality.Cause {
+	inv, ok := api.engine.GetInvariantByID(invariantID)
+	if !ok {
+		return nil
+	}
+	var violation *engine.ViolationResult
+	at := time.Now()
+	for _, v := range api.engine.EvaluateAll() {
+		if v != nil && v.Violated && v.InvariantID == invariantID && v.ResourceUID == uid {
+			violation = v
+			break
+		}
+	}
+	var spans []timeline.Span
+	if api.timeline != nil {
+		spans = api.timeline.Spans(time.Time{}, at)
+		if violation == nil {
+			// A resolved violation is ranked as of when it started
+			for i := len(spans) - 1; i >= 0; i-- {
+				if v := spans[i].Violation; v.InvariantID == invariantID && v.ResourceUID == uid {
+					violation, at = v, spans[i].Start
+					break
+				}
+			}
+		}
+	}
+	if violation == nil {
+		return nil
+	}
+
+	candidates, err := api.engine.Changes(violation, at.Add(-engine.DefaultSuspectWindow), at)
+	if err != nil {
+		log.Printf("Warning: failed to read changes before %s: %v", violation.Fingerprint(), err)
+		return nil
+	}
+	history := make([][]engine.SuspectChange, 0)
+	for i := len(spans) - 1; i >= 0 && len(history) < causality.MaxHistory; i-- {
+		span := spans[i]
+		if span.Violation.InvariantID != invariantID || !span.Start.Before(at) {
+			continue
+		}
+		changes, err := api.engine.Changes(span.Violation, span.Start.Add(-engine.DefaultSuspectWindow), span.Start)
+		if err != nil {
+			continue
+		}
+		history = append(history, changes)
+	}
+
+	field := ""
+	if inv.Predicate != nil {
+		field = inv.Predicate.Field
+	}
+	scorer := causality.Scorer{HasAuthority: api.engine.Authority().ValidateAuthority}
+	return scorer.Rank(at, field, candidates, history)
+}
+
+// GET /api/v1/causal-path?from_uid=node-1&to_uid=svc-1&limit=5
+// Searches the object graph, with the invariant dependencies and current
+// violations overlaid, for the routes a failure of one resource can take
+// to another
+func (api *APIServer) handleCausalPath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := newQueryParams(r)
+	uids := make(map[string]string, 2)
+	for _, name := range []string{"from_uid", "to_uid"} {
+		uid := params.values.Get(name)
+		if uid == "" {
+			params.check(invalidParam(name, "is required"))
+		} else {
+			params.check(validateUID(name, uid))
+		}
+		uids[name] = uid
+	}
+	limit := params.limit(5)
+	format := params.diagramFormat()
+	if !params.valid(w) {
+		return
+	}
+	lister, ok := api.store.(state.KindLister)
+	if !ok {
+		writeError(w, "Graph not supported by this store", http.StatusServiceUnavailable)
+		return
+	}
+	for _, name := range []string{"from_uid", "to_uid"} {
+		if _, exists := api.store.GetByUID(uids[name]); !exists {
+			writeError(w, "Resource not found: "+uids[name], http.StatusNotFound)
+			return
+		}
+	}
+
+	resources := make([]types.StateEvent, 0)
+	for _, kind := range lister.Kinds() {
+		resources = append(resources, api.store.GetLatestByKind(kind)...)
+	}
+	graph := topology.Build(resources)
+	graph.Overlay(engine.FilterByStatus(api.engine.EvaluateAll(), engine.StatusViolated))
+	paths := graph.CausalPaths(uids["from_uid"], uids["to_uid"], api.engine.GetInvariants(), limit)
+	if format == diagram.DOT || format == diagram.Mermaid {
+		api.respondDiagram(w, format, pathDiagram(uids["from_uid"], uids["to_uid"], paths))
+		return
+	}
+
+	api.respondJSON(w, map[string]interface{}{
+		"from_uid": uids["from_uid"],
+		"to_uid":   uids["to_uid"],
+		"paths":    paths,
+		"count":    len(paths),
+	})
+}
+
+// pathDiagram draws the causal paths between two resources merged into one
+// graph, highlighting the resources that violate an invariant
+func pathDiagram(from, to string, paths []topology.Path) *diagram.Diagram {
+	d := diagram.New(from + " to " + to)
+	for _, p := range paths {
+		for _, n := range p.Resources {
+			label := n.Kind + " " + strings.TrimPrefix(n.Namespace+"/"+n.Name, "/")
+			if len(n.Violations) > 0 {
+				label += "\n" + strings.Join(n.Violations, ", ")
+			}
+			d.AddNode(diagram.Node{ID: n.ID, Label: label, Highlight: len(n.Violations) > 0})
+		}
+		for _, h := range p.Hops {
+			d.AddEdge(diagram.Edge{From: h.From, To: h.To, Label: h.Type})
+		}
+	}
+	return d
+}
+
+// imageFailureReasons are container waiting reasons commonly caused by a
+// bad image rollout
+var imageFailureReasons = map[string]bool{
+	"ErrImagePull":         true,
+	"ImagePullBackOff":     true,
+	"InvalidImageName":     true,
+	"CrashLoopBackOff":     true,
+	"CreateContainerError": true,
+}
+
+func (api *APIServer) imageChangesForPod(uid string) []db.ImageChange {
+	pgStore, ok := api.store.(*db.PostgresStore)
+	if !ok {
+		return nil
+	}
+
+	pod, exists := api.store.GetByUID(uid)
+	if !exists || pod.Kind != "Pod" {
+		return nil
+	}
+	reason, _ := pod.FieldDiff["status.containerStatuses.waiting.reason"].(string)
+	if !imageFailureReasons[reason] {
+		return nil
+	}
+
+	var changes []db.ImageChange
+	for _, image := range db.PodImages(pod) {
+		matches, err := pgStore.GetImageChangesByImage(pod.Namespace, image)
+		if err != nil {
+			log.Printf("Warning: failed to look up image changes for %s: %v", image, err)
+			continue
+		}
+		changes = append(changes, matches...)
+	}
+	return changes
+}
+
+func (api *APIServer) cloudEventsForResource(uid string) []types.StateEvent {
+	resource, exists := api.store.GetByUID(uid)
+	if !exists {
+		return nil
+	}
+
+	if resource.Kind == "Pod" {
+		nodeName, _ := resource.FieldDiff["spec.nodeName"].(string)
+		if nodeName == "" {
+			return nil
+		}
+		for _, node := range api.store.GetLatestByKind("Node") {
+			if node.Name == nodeName {
+				return cloud.EventsForNode(api.store, node)
+			}
+		}
+		// The node may already be gone after a reclaim; match by name
+		return cloud.EventsForNode(api.store, types.StateEvent{Kind: "Node", Name: nodeName})
+	}
+	if resource.Kind == "Node" {
+		return cloud.EventsForNode(api.store, resource)
+	}
+	return nil
+}
+
+// pressureConditions are the node conditions that make the kubelet evict
+var pressureConditions = []string{"MemoryPressure", "DiskPressure", "PIDPressure"}
+
+// evictionCause links an evicted pod to the pressure conditions reported by
+// the node it ran on
+func (api *APIServer) evictionCause(uid string) map[string]interface{} {
+	pod, exists := api.store.GetByUID(uid)
+	if !exists || pod.Kind != "Pod" {
+		return nil
+	}
+	if reason, _ := pod.FieldDiff[watcher.FieldStatusReason].(string); reason != watcher.ReasonEvicted {
+		return nil
+	}
+
+	nodeName, _ := pod.FieldDiff["spec.nodeName"].(string)
+	cause := map[string]interface{}{
+		"node":       nodeName,
+		"conditions": []string{},
+	}
+	if message, _ := pod.FieldDiff[watcher.FieldStatusMessage].(string); message != "" {
+		cause["message"] = message
+	}
+
+	for _, node := range api.store.GetLatestByKind("Node") {
+		if node.Name != nodeName {
+			continue
+		}
+		conditions := make([]string, 0)
+		for _, condition := range pressureConditions {
+			if status, _ := node.FieldDiff[fmt.Sprintf("status.conditions[%s].status", condition)].(string); status == "True" {
+				conditions = append(conditions, condition)
+			}
+		}
+		cause["node_uid"] = node.UID
+		cause["conditions"] = conditions
+	}
+	return cause
+}
+
+// GET /api/v1/terminations?namespace=prod&name=api-7f9c&reason=OOMKilled&limit=50
+func (api *APIServer) handleTerminations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	limit := params.limit(50)
+	if !params.valid(w) {
+		return
+	}
+
+	pgStore, ok := api.store.(*db.PostgresStore)
+	if !ok {
+		writeError(w, "Termination history only available with PostgreSQL storage", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	namespace := query.Get("namespace")
+	if !owns(r, namespace) && namespace != "" {
+		writeError(w, "Namespace not found", http.StatusNotFound)
+		return
+	}
+	fetch := limit
+	if scoped(r) && namespace == "" {
+		fetch = maxLimit
+	}
+	terminations, err := pgStore.GetTerminations(namespace, query.Get("name"), query.Get("reason"), fetch)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	terminations = slices.DeleteFunc(terminations, func(t db.Termination) bool { return !owns(r, t.Namespace) })
+	if len(terminations) > limit {
+		terminations = terminations[:limit]
+	}
+
+	api.respondJSON(w, map[string]interface{}{
+		"terminations": terminations,
+		"count":        len(terminations),
+	})
+}
+
+// GET /api/v1/applications
+func (api *APIServer) handleApplications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	apps := api.apps.List()
+	api.respondJSON(w, map[string]interface{}{
+		"total_count":  len(apps),
+		"applications": apps,
+	})
+}
+
+// GET /api/v1/applications/{name}/causes
+func (api *APIServer) handleApplicationCauses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("name")
+	if _, exists := api.apps.Get(name); !exists {
+		writeError(w, "Application not found", http.StatusNotFound)
+		return
+	}
+
+	causes := api.apps.Analyze(name, api.store, api.engine.EvaluateAll())
+	api.respondJSON(w, map[string]interface{}{
+		"application": name,
+		"total_count": len(causes),
+		"causes":      causes,
+	})
+}
+
+// GET /api/v1/deployments/{namespace}/{name}/images?limit=20
+func (api *APIServer) handleDeploymentImages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	limit := params.limit(20)
+	if !params.valid(w) {
+		return
+	}
+
+	pgStore, ok := api.store.(*db.PostgresStore)
+	if !ok {
+		writeError(w, "Image history only available with PostgreSQL storage", http.StatusServiceUnavailable)
+		return
+	}
+
+	namespace, name := r.PathValue("namespace"), r.PathValue("name")
+	if !owns(r, namespace) {
+		writeError(w, "Deployment not found", http.StatusNotFound)
+		return
+	}
+	history, err := pgStore.GetImageHistory(namespace, name, limit)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+
+	api.respondJSON(w, map[string]interface{}{
+		"namespace": namespace,
+		"name":      name,
+		"images":    history,
+	})
+}
+
+// GET /api/v1/history?uid=pod-123&limit=20
+func (api *APIServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	uid := params.uid()
+	limit := params.limit(20)
+	if !params.valid(w) {
+		return
+	}
+	if uid == "" {
+		badRequest(w, invalidParam("uid", "is required"))
+		return
+	}
+
+	reader, ok := api.store.(state.HistoryReader)
+	if !ok {
+		writeError(w, "History not supported by this store", http.StatusServiceUnavailable)
+		return
+	}
+
+	// follow=true also returns the history of earlier incarnations of the
+	// resource, recreated under new UIDs
+	uids := []string{uid}
+	if r.URL.Query().Get("follow") == "true" {
+		uids = api.incarnations(uid)
+	}
+	uids = slices.DeleteFunc(uids, func(u string) bool { return !api.ownsUID(r, u) })
+	if len(uids) == 0 {
+		writeError(w, "Resource not found", http.StatusNotFound)
+		return
+	}
+
+	history := make([]types.StateEvent, 0)
+	for _, u := range uids {
+		events, err := reader.GetHistory(u, limit)
+		if err != nil {
+			storageError(w, err)
+			return
+		}
+		history = append(history, events...)
+	}
+	sort.SliceStable(history, func(i, j int) bool { return history[i].Timestamp.After(history[j].Timestamp) })
+	if limit > 0 && len(history) > limit {
+		history = history[:limit]
+	}
+	api.respondJSON(w, history)
+}
+
+// incarnations returns every UID sharing uid's logical identity, uid
+// included, or just uid when the store doesn't track identities
+func (api *APIServer) incarnations(uid string) []string {
+	resolver, ok := api.store.(state.IdentityResolver)
+	if !ok {
+		return []string{uid}
+	}
+	key, ok := resolver.LogicalKey(uid)
+	if !ok {
+		return []string{uid}
+	}
+	uids := resolver.UIDsForKey(key)
+	if !slices.Contains(uids, uid) {
+		uids = append(uids, uid)
+	}
+	return uids
+}
+
+// GET /api/v1/compare?from=2025-01-01T10:00:00Z&to=2025-01-01T12:00:00Z
+func (api *APIServer) handleCompare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reader, ok := api.store.(state.SnapshotReader)
+	if !ok {
+		writeError(w, "Comparison not supported by this store", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := time.Parse(time.RFC3339, query.Get("from"))
+	if err != nil {
+		writeError(w, "from must be an RFC3339 timestamp", http.StatusBadRequest)
+		return
+	}
+	to := time.Now()
+	if toStr := query.Get("to"); toStr != "" {
+		if to, err = time.Parse(time.RFC3339, toStr); err != nil {
+			writeError(w, "to must be an RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+	}
+	if !to.After(from) {
+		writeError(w, "to must be after from", http.StatusBadRequest)
+		return
+	}
+
+	before, err := reader.SnapshotAt(from)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	after, err := reader.SnapshotAt(to)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	recorded, err := reader.EventsBetween(from, to)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+
+	violatedAt := func(snapshot []types.StateEvent) map[string]*engine.ViolationResult {
+		results := engine.FilterByStatus(api.engine.EvaluateSnapshot(snapshot), engine.StatusViolated)
+		byFingerprint := make(map[string]*engine.ViolationResult, len(results))
+		for _, v := range results {
+			byFingerprint[v.Fingerprint()] = v
+		}
+		return byFingerprint
+	}
+	was, is := violatedAt(before), violatedAt(after)
+
+	newlyBroken := make([]*engine.ViolationResult, 0)
+	resolved := make([]*engine.ViolationResult, 0)
+	stillBroken := 0
+	affected := make(map[string]bool)
+	for key, v := range is {
+		if _, ok := was[key]; ok {
+			stillBroken++
+			continue
+		}
+		newlyBroken = append(newlyBroken, v)
+		affected[v.ResourceUID] = true
+	}
+	for key, v := range was {
+		if _, ok := is[key]; !ok {
+			resolved = append(resolved, v)
+			affected[v.ResourceUID] = true
+		}
+	}
+	sort.Slice(newlyBroken, func(i, j int) bool { return newlyBroken[i].Fingerprint() < newlyBroken[j].Fingerprint() })
+	sort.Slice(resolved, func(i, j int) bool { return resolved[i].Fingerprint() < resolved[j].Fingerprint() })
+
+	api.respondJSON(w, map[string]interface{}{
+		"from":             from,
+		"to":               to,
+		"newly_broken":     newlyBroken,
+		"resolved":         resolved,
+		"still_broken":     stillBroken,
+		"resource_changes": resourceChanges(before, after, affected),
+		"actors":           changeActors(recorded),
+	})
+}
+
+// resourceChanges diffs the fields of the affected resources between two
+// snapshots
+func resourceChanges(before, after []types.StateEvent, affected map[string]bool) []map[string]interface{} {
+	previous := make(map[string]types.StateEvent, len(before))
+	for _, event := range before {
+		previous[event.UID] = event
+	}
+
+	changes := make([]map[string]interface{}, 0)
+	for _, event := range after {
+		if !affected[event.UID] {
+			continue
+		}
+		diff := state.DiffFields(previous[event.UID].FieldDiff, event.FieldDiff)
+		if len(diff) == 0 {
+			continue
+		}
+		changes = append(changes, map[string]interface{}{
+			"uid":       event.UID,
+			"kind":      event.Kind,
+			"namespace": event.Namespace,
+			"name":      event.Name,
+			"changed":   diff,
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i]["uid"].(string) < changes[j]["uid"].(string) })
+	return changes
+}
+
+// changeActors summarizes who recorded changes, most active first
+func changeActors(events []types.StateEvent) []map[string]interface{} {
+	counts := make(map[string]int)
+	resources := make(map[string]map[string]bool)
+	for _, event := range events {
+		actor := event.Actor
+		if actor == "" {
+			actor = "unknown"
+		}
+		counts[actor]++
+		if resources[actor] == nil {
+			resources[actor] = make(map[string]bool)
+		}
+		resources[actor][event.Kind+"/"+event.Name] = true
+	}
+
+	actors := make([]map[string]interface{}, 0, len(counts))
+	for actor, count := range counts {
+		names := make([]string, 0, len(resources[actor]))
+		for name := range resources[actor] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		actors = append(actors, map[string]interface{}{
+			"actor":     actor,
+			"changes":   count,
+			"resources": names,
+		})
+	}
+	sort.Slice(actors, func(i, j int) bool {
+		if actors[i]["changes"].(int) != actors[j]["changes"].(int) {
+			return actors[i]["changes"].(int) > actors[j]["changes"].(int)
+		}
+		return actors[i]["actor"].(string) < actors[j]["actor"].(string)
+	})
+	return actors
+}
+
+// GET /api/v1/invariants?tags=security
+// POST /api/v1/invariants
+func (api *APIServer) handleInvariants(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tags := parseTags(r)
+		invariants := make([]dsl.Invariant, 0)
+		for _, inv := range api.engine.GetInvariants() {
+			if inv.HasAnyTag(tags) {
+				invariants = append(invariants, inv)
+			}
+		}
+		api.respondJSON(w, invariants)
+
+	case http.MethodPost:
+		var inv dsl.Invariant
+		if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
+			writeProblem(w, http.StatusBadRequest, CodeInvalidInvariant, "Invalid request body")
+			return
+		}
+		inv, err := api.engine.ResolveExtends(inv)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, CodeInvalidInvariant, err.Error())
+			return
+		}
+		if err := inv.Validate(); err != nil {
+			writeProblem(w, http.StatusBadRequest, CodeInvalidInvariant, err.Error())
+			return
+		}
+		if _, exists := api.engine.GetInvariantByID(inv.ID); exists {
+			writeError(w, "Invariant already exists", http.StatusConflict)
+			return
+		}
+
+		inv = api.engine.UpsertInvariant(inv)
+		if err := api.persistInvariant(inv); err != nil {
+			storageError(w, err)
+			return
+		}
+
+		api.respondJSONStatus(w, http.StatusCreated, inv)
+
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/v1/invariants/{id}
+// PUT /api/v1/invariants/{id}
+// DELETE /api/v1/invariants/{id}
+func (api *APIServer) handleInvariant(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	current, exists := api.engine.GetInvariantByID(id)
+	if !exists {
+		writeError(w, "Invariant not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		api.respondJSON(w, current)
+
+	case http.MethodPut:
+		var inv dsl.Invariant
+		if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
+			writeProblem(w, http.StatusBadRequest, CodeInvalidInvariant, "Invalid request body")
+			return
+		}
+		if inv.ID == "" {
+			inv.ID = id
+		}
+		if inv.ID != id {
+			writeError(w, "Invariant ID cannot be changed", http.StatusBadRequest)
+			return
+		}
+		inv, err := api.engine.ResolveExtends(inv)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, CodeInvalidInvariant, err.Error())
+			return
+		}
+		if err := inv.Validate(); err != nil {
+			writeProblem(w, http.StatusBadRequest, CodeInvalidInvariant, err.Error())
+			return
+		}
+
+		inv = api.engine.UpsertInvariant(inv)
+		if err := api.persistInvariant(inv); err != nil {
+			storageError(w, err)
+			return
+		}
+		api.respondJSON(w, inv)
+
+	case http.MethodDelete:
+		deleted, _ := api.engine.DeleteInvariant(id)
+		if pgStore, ok := api.store.(*db.PostgresStore); ok {
+			if err := pgStore.SoftDeleteInvariant(id); err != nil {
+				storageError(w, err)
+				return
+			}
+		}
+		api.respondJSON(w, deleted)
+
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/v1/invariants/{id}/versions
+func (api *APIServer) handleInvariantVersions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	var versions []dsl.Invariant
+	if pgStore, ok := api.store.(*db.PostgresStore); ok {
+		dbVersions, err := pgStore.GetInvariantVersions(id)
+		if err != nil {
+			storageError(w, err)
+			return
+		}
+		versions = dbVersions
+	} else {
+		versions = api.engine.GetInvariantVersions(id)
+	}
+
+	if len(versions) == 0 {
+		writeError(w, "Invariant not found", http.StatusNotFound)
+		return
+	}
+
+	api.respondJSON(w, map[string]interface{}{
+		"invariant_id": id,
+		"versions":     versions,
+	})
+}
+
+// GET /api/v1/invariants/errors
+func (api *APIServer) handleInvariantErrors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	errors := api.engine.GetEvaluationErrors()
+	api.respondJSON(w, map[string]interface{}{
+		"total_count": len(errors),
+		"errors":      errors,
+	})
+}
+
+// GET /api/v1/invariants/flagged
+// Invariants whose violations were collapsed for covering too many
+// resources of a kind
+func (api *APIServer) handleFlaggedInvariants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flags := api.engine.GetCardinalityFlags()
+	api.respondJSON(w, map[string]interface{}{
+		"total_count": len(flags),
+		"flagged":     flags,
+	})
+}
+
+// GET /api/v1/invariants/quality?verdict=flapping
+// Scores invariants by violation volume, resolution time, flap rate and
+// acknowledgment rate, lowest score first
+func (api *APIServer) handleInvariantQuality(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if api.quality == nil {
+		writeProblem(w, http.StatusServiceUnavailable, CodeNotEnabled, "Invariant quality tracking is not enabled")
+		return
+	}
+
+	report := api.quality.Report(api.engine.GetInvariants(), time.Now())
+	if verdict := r.URL.Query().Get("verdict"); verdict != "" {
+		scores := make([]quality.Score, 0)
+		for _, s := range report.Invariants {
+			if string(s.Verdict) == verdict {
+				scores = append(scores, s)
+			}
+		}
+		report.Invariants = scores
+	}
+	api.respondJSON(w, report)
+}
+
+// GET /api/v1/suggestions?window=168h&min_occurrences=3
+// Lists recurring failures in the recorded history that no invariant
+// checks, each with a draft invariant
+func (api *APIServer) handleSuggestions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	suggestions, ok := api.suggestions(w, r)
+	if !ok {
+		return
+	}
+	api.respondJSON(w, map[string]interface{}{
+		"total_count": len(suggestions),
+		"suggestions": suggestions,
+	})
+}
+
+// POST /api/v1/suggestions/{id}/accept?window=168h
+// Activates the draft invariant of a suggestion, found with the same
+// parameters it was listed with
+func (api *APIServer) handleAcceptSuggestion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	suggestions, ok := api.suggestions(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	i := slices.IndexFunc(suggestions, func(s suggest.Suggestion) bool { return s.ID == id })
+	if i < 0 {
+		writeError(w, "Suggestion not found", http.StatusNotFound)
+		return
+	}
+	inv := suggestions[i].Invariant
+	if _, exists := api.engine.GetInvariantByID(inv.ID); exists {
+		writeError(w, "Invariant already exists", http.StatusConflict)
+		return
+	}
+
+	inv = api.engine.UpsertInvariant(inv)
+	if err := api.persistInvariant(inv); err != nil {
+		storageError(w, err)
+		return
+	}
+	api.respondJSONStatus(w, http.StatusCreated, inv)
+}
+
+// suggestions analyzes the history as the request's window and
+// min_occurrences parameters ask, writing the error response on failure
+func (api *APIServer) suggestions(w http.ResponseWriter, r *http.Request) ([]suggest.Suggestion, bool) {
+	params := newQueryParams(r)
+	window := params.duration("window")
+	minOccurrences := 0
+	if v := params.values.Get("min_occurrences"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			params.check(invalidParam("min_occurrences", "must be a positive integer"))
+		}
+		minOccurrences = n
+	}
+	if !params.valid(w) {
+		return nil, false
+	}
+
+	suggestions, err := suggest.Suggest(api.store, api.engine.GetInvariants(), time.Now(), window, minOccurrences)
+	if errors.Is(err, suggest.ErrNoHistory) {
+		writeError(w, err.Error(), http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if err != nil {
+		storageError(w, err)
+		return nil, false
+	}
+	return suggestions, true
+}
+
+// POST /api/v1/invariants/{id}/review
+// Clears the cardinality flag of a reviewed invariant
+func (api *APIServer) handleReviewInvariant(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	if !api.engine.ClearCardinalityFlag(id) {
+		writeError(w, "Invariant is not flagged", http.StatusNotFound)
+		return
+	}
+	api.respondJSON(w, map[string]interface{}{
+		"invariant_id": id,
+		"reviewed":     true,
+	})
+}
+
+// GET /api/v1/evaluations/log?invariant_id=&uid=&since=&until=&limit=
+// Recent evaluation outcomes, newest first, for debugging why an invariant
+// did or didn't fire
+func (api *APIServer) handleEvaluationLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := newQueryParams(r)
+	query := engine.EvaluationLogQuery{
+		InvariantID: q.values.Get("invariant_id"),
+		ResourceUID: q.uid(),
+		Since:       q.time("since"),
+		Until:       q.time("until"),
+		Limit:       q.limit(engine.DefaultEvaluationLogLimit),
+	}
+	if !q.valid(w) {
+		return
+	}
+
+	entries, err := api.engine.EvaluationLog(query)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	api.respondJSON(w, map[string]interface{}{
+		"total_count": len(entries),
+		"entries":     entries,
+	})
+}
+
+func (api *APIServer) persistInvariant(inv dsl.Invariant) error {
+	if pgStore, ok := api.store.(*db.PostgresStore); ok {
+		return pgStore.SaveInvariant(inv)
+	}
+	return nil
+}
+
+func parseTimeParam(v string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// parseTags reads the comma-separated ?tags= filter
+func parseTags(r *http.Request) []string {
+	var tags []string
+	for _, tag := range strings.Split(r.URL.Query().Get("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+const (
+	// maxEventBatch caps the number of events accepted by the bulk endpoint
+	maxEventBatch = 1000
+	// maxEventBytes and maxEventBatchBytes bound the ingestion request
+	// bodies, so an oversized one is rejected before it is fully decoded
+	// into memory. Kubernetes objects stay under 1.5MiB.
+	maxEventBytes      = 4 << 20
+	maxEventBatchBytes = 32 << 20
+)
+
+// decodeBounded decodes the request body into v, answering 413 when it
+// exceeds limit bytes and 400 when it isn't valid JSON
+func decodeBounded(w http.ResponseWriter, r *http.Request, limit int64, v interface{}) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit)).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, fmt.Sprintf("Request body exceeds %d bytes", limit), http.StatusRequestEntityTooLarge)
+			return false
+		}
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// POST /api/v1/events
+func (api *APIServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var event types.StateEvent
+	if !decodeBounded(w, r, maxEventBytes, &event) {
+		return
+	}
+
+	event, err := normalizeEvent(event)
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+	if err := api.mayRecord(r, event); err != nil {
+		writeError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if event.CorrelationID == "" {
+		event.CorrelationID = correlationID(r)
+	}
+	if err := api.store.Record(event); err != nil {
+		storageError(w, err)
+		return
+	}
+
+	api.respondJSONStatus(w, http.StatusCreated, event)
+}
+
+// mayRecord checks the request may record event: it must own the event's
+// namespace and, for a resource already recorded, the resource's own, which
+// a scoped request may not change
+func (api *APIServer) mayRecord(r *http.Request, event types.StateEvent) error {
+	if !owns(r, event.Namespace) {
+		return fmt.Errorf("tenant may not record events for namespace %s", event.Namespace)
+	}
+	if !scoped(r) {
+		return nil
+	}
+	existing, exists := api.store.GetByUID(event.UID)
+	if exists && (!owns(r, existing.Namespace) || existing.Namespace != event.Namespace) {
+		return fmt.Errorf("tenant may not record events for resource %s outside its namespace", event.UID)
+	}
+	return nil
+}
+
+// POST /api/v1/events/bulk
+func (api *APIServer) handleEventsBulk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var events []types.StateEvent
+	if !decodeBounded(w, r, maxEventBatchBytes, &events) {
+		return
+	}
+	if len(events) > maxEventBatch {
+		writeError(w, fmt.Sprintf("At most %d events per request", maxEventBatch), http.StatusRequestEntityTooLarge)
+		return
+	}
+	correlateEvents(r, events)
+
+	// Events are recorded independently; one bad event does not reject the batch
+	recorded := 0
+	failures := make([]map[string]interface{}, 0)
+	for i, event := range events {
+		event, err := normalizeEvent(event)
+		if err == nil {
+			err = api.mayRecord(r, event)
+		}
+		if err == nil {
+			err = api.store.Record(event)
+		}
+		if err != nil {
+			failures = append(failures, map[string]interface{}{
+				"index": i,
+				"uid":   event.UID,
+				"error": err.Error(),
+			})
+			continue
+		}
+		recorded++
+	}
+
+	status := http.StatusOK
+	if recorded == 0 && len(failures) > 0 {
+		status = http.StatusBadRequest
+	}
+	api.respondJSONStatus(w, status, map[string]interface{}{
+		"recorded":       recorded,
+		"failed":         len(failures),
+		"errors":         failures,
+		"correlation_id": correlationID(r),
+	})
+}
+
+// POST /api/v1/cloud/{provider}/events
+func (api *APIServer) handleCloudEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	collector, exists := api.collectors.Get(r.PathValue("provider"))
+	if !exists {
+		writeError(w, "Unknown cloud provider", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if handshaker, ok := collector.(cloud.Handshaker); ok {
+		if response, ok := handshaker.Handshake(body); ok {
+			api.respondJSON(w, response)
+			return
+		}
+	}
+
+	events, err := collector.Decode(body)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	correlateEvents(r, events)
+	for _, event := range events {
+		if err := api.store.Record(event); err != nil {
+			storageError(w, err)
+			return
+		}
+	}
+
+	api.respondJSON(w, map[string]interface{}{
+		"provider": collector.Provider(),
+		"recorded": len(events),
+	})
+}
+
+// POST /api/v1/deploys/{github|gitlab|ci}?image=registry/app:v2&namespace=prod
+// The image and namespace parameters fill in what a webhook doesn't carry
+func (api *APIServer) handleDeploys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	source := r.PathValue("source")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if api.deploySecret != "" && !changes.Verify(source, r.Header, body, api.deploySecret) {
+		writeError(w, "Invalid webhook signature", http.StatusUnauthorized)
+		return
+	}
+
+	deploys, err := changes.Decode(source, body)
+	if errors.Is(err, changes.ErrUnknownSource) {
+		writeError(w, "Unknown deploy source", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	for _, deploy := range deploys {
+		if deploy.Image == "" {
+			deploy.Image = query.Get("image")
+		}
+		if deploy.Namespace == "" {
+			deploy.Namespace = query.Get("namespace")
+		}
+		event := deploy.Event()
+		event.CorrelationID = correlationID(r)
+		if err := api.store.Record(event); err != nil {
+			storageError(w, err)
+			return
+		}
+	}
+
+	api.respondJSON(w, map[string]interface{}{
+		"source":   source,
+		"recorded": len(deploys),
+	})
+}
+
+// normalizeEvent validates an externally produced event and fills in the
+// fields a producer may omit
+func normalizeEvent(event types.StateEvent) (types.StateEvent, error) {
+	if event.UID == "" {
+		return event, invalidParam("uid", "is required")
+	}
+	if err := validateUID("uid", event.UID); err != nil {
+		return event, err
+	}
+	if event.Kind == "" {
+		return event, invalidParam("kind", "is required")
+	}
+	if err := validateKind("kind", event.Kind); err != nil {
+		return event, err
+	}
+	if event.Name == "" {
+		return event, invalidParam("name", "is required")
+	}
+	// External producers must identify themselves so causal chains can
+	// attribute changes to them
+	if event.Actor == "" {
+		return event, invalidParam("actor", "is required")
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Version == "" {
+		event.Version = strconv.FormatInt(event.Timestamp.UnixNano(), 10)
+	}
+	if event.FieldDiff == nil {
+		event.FieldDiff = make(map[string]interface{})
+	}
+	if event.Tier != "" {
+		tier, err := criticality.ParseTier(event.Tier)
+		if err != nil {
+			return event, invalidParam("tier", err.Error())
+		}
+		event.Tier = string(tier)
+	}
+	return event, nil
+}
+
+// POST /api/v1/invariants/evaluate?tags=security
+func (api *APIServer) handleEvaluateInvariants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	results := api.engine.FilterByTags(api.engine.EvaluateAll(), parseTags(r))
+	violations := api.correlate(r, engine.FilterByStatus(results, engine.StatusViolated))
+	api.attachSuspects(r, violations)
+	unknown := engine.FilterByStatus(results, engine.StatusUnknown)
+
+	response := map[string]interface{}{
+		"evaluated_at":  time.Now(),
+		"total_count":   len(violations),
+		"violations":    violations,
+		"unknown_count": len(unknown),
+		"unknown":       unknown,
+		"errors":        engine.FilterByStatus(results, engine.StatusEvaluationError),
+	}
+
+	api.respondJSON(w, response)
+}
+
+// GET /api/v1/resources?kind=Pod&namespace=default&name=web-1&limit=100
+// lists the latest recorded state of resources, every kind when kind is
+// omitted
+func (api *APIServer) handleResources(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	params := newQueryParams(r)
+	kinds := []string{params.kind()}
+	limit := params.limit(100)
+	consistent := params.consistent()
+	if !params.valid(w) {
+		return
+	}
+	if kinds[0] == "" {
+		lister, ok := api.store.(state.KindLister)
+		if !ok {
+			writeError(w, "kind is required", http.StatusBadRequest)
+			return
+		}
+		kinds = lister.Kinds()
+	}
+	namespace, name := query.Get("namespace"), query.Get("name")
+
+	resources := make([]types.StateEvent, 0)
+	for _, kind := range kinds {
+		latest, err := api.latestByKind(kind, consistent)
+		if err != nil {
+			storageError(w, err)
+			return
+		}
+		for _, resource := range latest {
+			if !owns(r, resource.Namespace) {
+				continue
+			}
+			if (namespace == "" || resource.Namespace == namespace) && (name == "" || resource.Name == name) {
+				resources = append(resources, resource)
+			}
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		a, b := resources[i], resources[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	if len(resources) > limit {
+		resources = resources[:limit]
+	}
+
+	api.respondJSON(w, resources)
+}
+
+// GET /api/v1/resources/{uid} returns the latest recorded state of a
+// resource, its fields and the actor that last changed it
+func (api *APIServer) handleResource(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	consistent := params.consistent()
+	if !params.valid(w) {
+		return
+	}
+
+	uid := r.PathValue("uid")
+	resource, exists := api.store.GetByUID(uid)
+	if reader, ok := api.store.(state.ConsistentReader); ok && consistent {
+		var err error
+		if resource, exists, err = reader.ReadByUID(uid); err != nil {
+			storageError(w, err)
+			return
+		}
+	}
+	if !exists || !owns(r, resource.Namespace) {
+		writeError(w, "Resource not found", http.StatusNotFound)
+		return
+	}
+	api.respondJSON(w, resource)
+}
+
+// latestByKind reads the latest state of kind, from durable storage when
+// consistent is set and the store caches reads. Stores without a cache are
+// always consistent.
+func (api *APIServer) latestByKind(kind string, consistent bool) ([]types.StateEvent, error) {
+	if reader, ok := api.store.(state.ConsistentReader); ok && consistent {
+		return reader.ReadLatestByKind(kind)
+	}
+	return api.store.GetLatestByKind(kind), nil
+}
+
+// GET /api/v1/inventory?stale=30m counts the tracked resources by kind and
+// namespace, with the last event per kind, and checks every kind an
+// invariant evaluates is being recorded. With stale, kinds without an event
+// for that long are reported too.
+func (api *APIServer) handleInventory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	lister, ok := api.store.(state.KindLister)
+	if !ok {
+		writeError(w, "Inventory not supported by this store", http.StatusServiceUnavailable)
+		return
+	}
+	var stale time.Duration
+	if v := r.URL.Query().Get("stale"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, "stale must be a positive duration", http.StatusBadRequest)
+			return
+		}
+		stale = d
+	}
+
+	invariantsByKind := make(map[string]int)
+	for _, inv := range api.engine.GetInvariants() {
+		invariantsByKind[inv.Subject.Kind]++
+	}
+
+	now := time.Now()
+	total := 0
+	byNamespace := make(map[string]int)
+	kinds := make(map[string]interface{})
+	staleKinds := make([]string, 0)
+	for _, kind := range lister.Kinds() {
+		resources := api.store.GetLatestByKind(kind)
+		if len(resources) == 0 {
+			continue
+		}
+		namespaces := make(map[string]int)
+		actors := make(map[string]int)
+		var lastEvent time.Time
+		for _, resource := range resources {
+			namespaces[resource.Namespace]++
+			byNamespace[resource.Namespace]++
+			if resource.Actor != "" {
+				actors[resource.Actor]++
+			}
+			if resource.Timestamp.After(lastEvent) {
+				lastEvent = resource.Timestamp
+			}
+		}
+		total += len(resources)
+		kinds[kind] = map[string]interface{}{
+			"count":        len(resources),
+			"by_namespace": namespaces,
+			"by_actor":     actors,
+			"last_event":   lastEvent,
+			"invariants":   invariantsByKind[kind],
+		}
+		if stale > 0 && now.Sub(lastEvent) > stale {
+			staleKinds = append(staleKinds, kind)
+		}
+	}
+
+	// Kinds invariants evaluate but nothing records are never checked
+	expected := make([]string, 0, len(invariantsByKind))
+	missing := make([]string, 0)
+	for kind := range invariantsByKind {
+		expected = append(expected, kind)
+		if _, tracked := kinds[kind]; !tracked {
+			missing = append(missing, kind)
+		}
+	}
+	sort.Strings(expected)
+	sort.Strings(missing)
+	sort.Strings(staleKinds)
+
+	coverage := map[string]interface{}{
+		"expected_kinds": expected,
+		"missing_kinds":  missing,
+		"complete":       len(missing) == 0 && len(staleKinds) == 0,
+	}
+	if stale > 0 {
+		coverage["stale_after"] = stale.String()
+		coverage["stale_kinds"] = staleKinds
+	}
+	api.respondJSON(w, map[string]interface{}{
+		"total":        total,
+		"by_kind":      kinds,
+		"by_namespace": byNamespace,
+		"coverage":     coverage,
+	})
+}
+
+// GET /api/v1/actors lists every known actor with who to contact, the
+// fields it owns, the invariants naming it responsible and the active
+// violations attributed to it, most violations first
+func (api *APIServer) handleActors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authorities := api.engine.Authority()
+	names := make(map[string]bool)
+	for _, name := range authorities.GetAllControllers() {
+		names[name] = true
+	}
+
+	invariantIDs := make(map[string][]string)
+	teams := make(map[string]string)
+	for _, inv := range api.engine.GetInvariants() {
+		for _, name := range []string{inv.Responsibility.Primary, inv.Responsibility.Secondary} {
+			if name == "" {
+				continue
+			}
+			names[name] = true
+			invariantIDs[name] = append(invariantIDs[name], inv.ID)
+			if teams[name] == "" {
+				teams[name] = inv.Responsibility.Team
+			}
+		}
+	}
+
+	violations := make(map[string]int)
+	bySeverity := make(map[string]map[dsl.Severity]int)
+	for _, v := range engine.FilterByStatus(api.engine.EvaluateAll(), engine.StatusViolated) {
+		if v.ResponsibleActor == "" {
+			continue
+		}
+		names[v.ResponsibleActor] = true
+		violations[v.ResponsibleActor]++
+		if bySeverity[v.ResponsibleActor] == nil {
+			bySeverity[v.ResponsibleActor] = make(map[dsl.Severity]int)
+		}
+		bySeverity[v.ResponsibleActor][v.Severity]++
+	}
+
+	actors := make([]map[string]interface{}, 0, len(names))
+	for name := range names {
+		ids := invariantIDs[name]
+		if ids == nil {
+			ids = []string{}
+		}
+		sort.Strings(ids)
+		severities := bySeverity[name]
+		if severities == nil {
+			severities = map[dsl.Severity]int{}
+		}
+		actor := map[string]interface{}{
+			"name":        name,
+			"team":        teams[name],
+			"fields":      authorities.GetOwnedFields(name),
+			"invariants":  ids,
+			"violations":  violations[name],
+			"by_severity": severities,
+		}
+		// Actors only named by invariants have no metadata beyond a team
+		if meta, ok := authorities.GetControllerMetadata(name); ok {
+			actor["description"] = meta.Description
+			actor["team"] = meta.Team
+			actor["contact"] = meta.Contact
+			actor["priority"] = meta.Priority
+		}
+		actors = append(actors, actor)
+	}
+	sort.Slice(actors, func(i, j int) bool {
+		vi, vj := actors[i]["violations"].(int), actors[j]["violations"].(int)
+		if vi != vj {
+			return vi > vj
+		}
+		return actors[i]["name"].(string) < actors[j]["name"].(string)
+	})
+	api.respondJSON(w, actors)
+}
+
+// GET /api/v1/resources/{uid}/tier
+// PUT /api/v1/resources/{uid}/tier
+// DELETE /api/v1/resources/{uid}/tier
+func (api *APIServer) handleResourceTier(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	resource, exists := api.store.GetByUID(uid)
+	if !exists {
+		writeError(w, "Resource not found", http.StatusNotFound)
+		return
+	}
+	tiers := api.engine.Tiers()
+
+	switch r.Method {
+	case http.MethodGet:
+		// Report the effective tier below
+
+	case http.MethodPut:
+		var req struct {
+			Tier string `json:"tier"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		tier, err := criticality.ParseTier(req.Tier)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := tiers.Set(uid, tier); err != nil {
+			storageError(w, err)
+			return
+		}
+
+	case http.MethodDelete:
+		if err := tiers.Set(uid, ""); err != nil {
+			storageError(w, err)
+			return
+		}
+
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, overridden := tiers.Override(uid)
+	tier := tiers.Resolve(resource)
+	api.respondJSON(w, map[string]interface{}{
+		"uid":        uid,
+		"tier":       tier,
+		"weight":     tier.Weight(),
+		"overridden": overridden,
+	})
+}
+
+// GET /api/v1/health-score
+func (api *APIServer) handleHealthScore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	api.respondJSON(w, api.engine.HealthScore(api.engine.EvaluateAll()))
+}
+
+// GET /api/v1/incidents
+func (api *APIServer) handleIncidents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if api.pager == nil {
+		writeProblem(w, http.StatusServiceUnavailable, CodeNotEnabled, "Paging is not enabled")
+		return
+	}
+
+	incidents := api.pager.Open()
+	api.respondJSON(w, map[string]interface{}{
+		"total_count": len(incidents),
+		"incidents":   incidents,
+	})
+}
+
+// POST /api/v1/incidents/acknowledge
+// Body: {"fingerprint": "pod_ready|default/api-pod"}
+func (api *APIServer) handleAcknowledgeIncident(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if api.pager == nil {
+		writeProblem(w, http.StatusServiceUnavailable, CodeNotEnabled, "Paging is not enabled")
+		return
+	}
+
+	var req struct {
+		Fingerprint string `json:"fingerprint"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Fingerprint == "" {
+		writeError(w, "fingerprint is required", http.StatusBadRequest)
+		return
+	}
+	if err := api.pager.Acknowledge(req.Fingerprint); err != nil {
+		writeError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if api.quality != nil {
+		api.quality.Acknowledge(req.Fingerprint)
+	}
+
+	api.respondJSON(w, map[string]interface{}{
+		"fingerprint":  req.Fingerprint,
+		"acknowledged": true,
+	})
+}
+
+// GET /api/v1/incidents/{id}/deliveries
+// id is the violation fingerprint. Lists every attempt to send the
+// incident's trigger, acknowledgement and resolution to each service.
+func (api *APIServer) handleIncidentDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if api.pager == nil {
+		writeProblem(w, http.StatusServiceUnavailable, CodeNotEnabled, "Paging is not enabled")
+		return
+	}
+
+	key := r.PathValue("id")
+	deliveries, err := api.pager.Deliveries(key)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []paging.Delivery{}
+	}
+	api.respondJSON(w, map[string]interface{}{
+		"fingerprint": key,
+		"total_count": len(deliveries),
+		"deliveries":  deliveries,
+	})
+}
+
+// GET /api/v1/notifications/dead-letters
+func (api *APIServer) handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if api.pager == nil {
+		writeProblem(w, http.StatusServiceUnavailable, CodeNotEnabled, "Paging is not enabled")
+		return
+	}
+
+	letters, err := api.pager.DeadLetters()
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	api.respondJSON(w, map[string]interface{}{
+		"total_count":  len(letters),
+		"dead_letters": letters,
+	})
+}
+
+// POST /api/v1/notifications/replay
+// Body: {"ids": [1, 2]}, or no body to replay every dead letter
+func (api *APIServer) handleReplayNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if api.pager == nil {
+		writeProblem(w, http.StatusServiceUnavailable, CodeNotEnabled, "Paging is not enabled")
+		return
+	}
+
+	var req struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	result, err := api.pager.Replay(r.Context(), req.IDs)
+	if errors.Is(err, paging.ErrDeadLetterNotFound) {
+		writeError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	api.respondJSON(w, result)
+}
+
+// GET /api/v1/incidents/{id}/postmortem?lookback=15m&format=markdown|json
+// id is the violation fingerprint, e.g. pod_ready|default%2Fapi-pod. The
+// timeline includes changes from lookback before the first violation.
+func (api *APIServer) handleIncidentPostmortem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !api.timelineEnabled(w) {
+		return
+	}
+	lookback := engine.DefaultSuspectWindow
+	if v := r.URL.Query().Get("lookback"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			writeError(w, "lookback must be a non-negative duration", http.StatusBadRequest)
+			return
+		}
+		lookback = d
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "markdown" && format != "json" {
+		writeError(w, "format must be markdown or json", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	pm, ok := api.timeline.Postmortem(r.PathValue("id"), now)
+	if !ok {
+		writeError(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+	end := pm.End
+	if end.IsZero() {
+		end = now
+	}
+	changes, err := api.engine.Changes(pm.Violation, pm.Start.Add(-lookback), end)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	pm.AddChanges(changes)
+
+	if format == "json" {
+		api.respondJSON(w, pm)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	pm.WriteMarkdown(w)
+}
+
+// GET /api/v1/services/coverage?below=75
+func (api *APIServer) handleServiceCoverage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	coverage := api.engine.ServiceCoverage()
+	if v := r.URL.Query().Get("below"); v != "" {
+		below, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			writeError(w, "below must be a percentage", http.StatusBadRequest)
+			return
+		}
+		coverage = slices.DeleteFunc(coverage, func(c engine.Coverage) bool { return c.Percent >= below })
+	}
+	api.respondJSON(w, coverage)
+}
+
+// GET /api/v1/services/{namespace}/{name}/coverage?from=2025-01-01T10:00:00Z&to=2025-01-01T12:00:00Z
+func (api *APIServer) handleServiceCoverageHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	namespace, name := r.PathValue("namespace"), r.PathValue("name")
+	var current *engine.Coverage
+	for _, c := range api.engine.ServiceCoverage() {
+		if c.Namespace == namespace && c.Name == name {
+			current = &c
+		}
+	}
+	if current == nil {
+		writeError(w, "Service not found or has no selector", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	to := time.Now()
+	from := to.Add(-time.Hour)
+	var err error
+	if v := query.Get("from"); v != "" {
+		if from, err = parseTimeParam(v); err != nil {
+			writeError(w, "from must be RFC3339 or epoch milliseconds", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = parseTimeParam(v); err != nil {
+			writeError(w, "to must be RFC3339 or epoch milliseconds", http.StatusBadRequest)
+			return
+		}
+	}
+
+	history := make([]timeline.CoverageSample, 0)
+	if api.coverage != nil {
+		history = api.coverage.Samples(namespace, name, from, to)
+	}
+	api.respondJSON(w, map[string]interface{}{
+		"current": current,
+		"history": history,
+	})
+}
+
+// Grafana JSON datasource targets
+const (
+	grafanaBySeverity = "violations_by_severity"
+	grafanaTotal      = "violations_total"
+	grafanaActive     = "active_violations"
+)
+
+var grafanaTargets = []string{grafanaBySeverity, grafanaTotal, grafanaActive}
+
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type grafanaQueryRequest struct {
+	Range         grafanaRange `json:"range"`
+	MaxDataPoints int          `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+	} `json:"targets"`
+}
+
+type grafanaSeries struct {
+	Target     string     `json:"target"`
+	Datapoints [][2]int64 `json:"datapoints"`
+}
+
+type grafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type grafanaTable struct {
+	Type    string          `json:"type"`
+	Columns []grafanaColumn `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+type grafanaAnnotation struct {
+	Time    int64    `json:"time"`
+	TimeEnd int64    `json:"timeEnd,omitempty"`
+	Title   string   `json:"title"`
+	Text    string   `json:"text"`
+	Tags    []string `json:"tags"`
+}
+
+// timelineEnabled answers 503 until a timeline recorder is installed
+func (api *APIServer) timelineEnabled(w http.ResponseWriter) bool {
+	if api.timeline == nil {
+		writeProblem(w, http.StatusServiceUnavailable, CodeNotEnabled, "Violation timeline is not enabled")
+		return false
+	}
+	return true
+}
+
+// GET /api/v1/grafana
+// Grafana calls the datasource root to test the connection
+func (api *APIServer) handleGrafanaHealth(w http.ResponseWriter, r *http.Request) {
+	if !api.timelineEnabled(w) {
+		return
+	}
+	api.respondJSON(w, map[string]interface{}{"status": "ok"})
+}
+
+// POST /api/v1/grafana/metrics (JSON datasource) and /search (SimpleJSON)
+func (api *APIServer) handleGrafanaMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !api.timelineEnabled(w) {
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/search") {
+		api.respondJSON(w, grafanaTargets)
+		return
+	}
+	metrics := make([]map[string]string, len(grafanaTargets))
+	for i, target := range grafanaTargets {
+		metrics[i] = map[string]string{"label": target, "value": target}
+	}
+	api.respondJSON(w, metrics)
+}
+
+// POST /api/v1/grafana/query
+func (api *APIServer) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !api.timelineEnabled(w) {
+		return
+	}
+
+	var req grafanaQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Range.To.IsZero() {
+		req.Range.To = time.Now()
+	}
+
+	samples := downsample(api.timeline.Samples(req.Range.From, req.Range.To), req.MaxDataPoints)
+	response := make([]interface{}, 0, len(req.Targets))
+	for _, target := range req.Targets {
+		switch target.Target {
+		case grafanaBySeverity:
+			for _, severity := range []dsl.Severity{dsl.Critical, dsl.Degraded, dsl.Warning} {
+				series := grafanaSeries{Target: string(severity), Datapoints: make([][2]int64, len(samples))}
+				for i, sample := range samples {
+					series.Datapoints[i] = [2]int64{int64(sample.Counts[severity]), sample.At.UnixMilli()}
+				}
+				response = append(response, series)
+			}
+		case grafanaTotal:
+			series := grafanaSeries{Target: "total", Datapoints: make([][2]int64, len(samples))}
+			for i, sample := range samples {
+				series.Datapoints[i] = [2]int64{int64(sample.Total()), sample.At.UnixMilli()}
+			}
+			response = append(response, series)
+		case grafanaActive:
+			response = append(response, activeViolationsTable(api.timeline.Active()))
+		default:
+			writeError(w, fmt.Sprintf("Unknown target %q", target.Target), http.StatusBadRequest)
+			return
+		}
+	}
+	api.respondJSON(w, response)
+}
+
+// POST /api/v1/grafana/annotations
+// The annotation query optionally filters by invariant ID or severity
+func (api *APIServer) handleGrafanaAnnotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !api.timelineEnabled(w) {
+		return
+	}
+
+	var req struct {
+		Range      grafanaRange `json:"range"`
+		Annotation struct {
+			Query string `json:"query"`
+		} `json:"annotation"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Range.To.IsZero() {
+		req.Range.To = time.Now()
+	}
+
+	filter := strings.TrimSpace(req.Annotation.Query)
+	annotations := make([]grafanaAnnotation, 0)
+	for _, span := range api.timeline.Spans(req.Range.From, req.Range.To) {
+		v := span.Violation
+		if filter != "" && filter != v.InvariantID && filter != string(v.Severity) {
+			continue
+		}
+		annotation := grafanaAnnotation{
+			Time:  span.Start.UnixMilli(),
+			Title: fmt.Sprintf("%s on %s", v.InvariantID, v.AffectedResource),
+			Text:  v.Reason,
+			Tags:  timeline.Tags(v),
+		}
+		if !span.End.IsZero() {
+			annotation.TimeEnd = span.End.UnixMilli()
+		}
+		annotations = append(annotations, annotation)
+	}
+	api.respondJSON(w, annotations)
+}
+
+// GET /api/v1/annotations?from=...&to=...&tags=critical,namespace:default
+// from and to are RFC3339 or epoch milliseconds, as in Grafana's ${__from}
+func (api *APIServer) handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !api.timelineEnabled(w) {
+		return
+	}
+
+	query := r.URL.Query()
+	to := time.Now()
+	from := to.Add(-time.Hour)
+	var err error
+	if v := query.Get("from"); v != "" {
+		if from, err = parseTimeParam(v); err != nil {
+			writeError(w, "from must be RFC3339 or epoch milliseconds", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = parseTimeParam(v); err != nil {
+			writeError(w, "to must be RFC3339 or epoch milliseconds", http.StatusBadRequest)
+			return
+		}
+	}
+
+	events := api.timeline.Events(from, to, parseTags(r)...)
+	annotations := make([]map[string]interface{}, len(events))
+	for i, event := range events {
+		v := event.Violation
+		annotations[i] = map[string]interface{}{
+			"time":              event.At.UnixMilli(),
+			"type":              event.Type,
+			"title":             fmt.Sprintf("%s %s on %s", v.InvariantID, event.Type, v.AffectedResource),
+			"text":              v.Reason,
+			"tags":              event.Tags,
+			"invariant_id":      v.InvariantID,
+			"affected_resource": v.AffectedResource,
+			"severity":          v.Severity,
+			"responsible_actor": v.ResponsibleActor,
+		}
+	}
+	api.respondJSON(w, annotations)
+}
+
+func activeViolationsTable(violations []*engine.ViolationResult) grafanaTable {
+	table := grafanaTable{
+		Type: "table",
+		Columns: []grafanaColumn{
+			{Text: "Detected", Type: "time"},
+			{Text: "Invariant", Type: "string"},
+			{Text: "Resource", Type: "string"},
+			{Text: "Severity", Type: "string"},
+			{Text: "Responsible", Type: "string"},
+			{Text: "Tier", Type: "string"},
+			{Text: "Reason", Type: "string"},
+		},
+		Rows: make([][]interface{}, 0, len(violations)),
+	}
+	for _, v := range violations {
+		table.Rows = append(table.Rows, []interface{}{
+			v.DetectedAt.UnixMilli(), v.InvariantID, v.AffectedResource,
+			string(v.Severity), v.ResponsibleActor, string(v.Tier), v.Reason,
+		})
+	}
+	return table
+}
+
+// downsample keeps at most max samples, taking the last of each group so
+// spikes at the end of a group aren't hidden
+func downsample(samples []timeline.Sample, max int) []timeline.Sample {
+	if max <= 0 || len(samples) <= max {
+		return samples
+	}
+	step := (len(samples) + max - 1) / max
+	result := make([]timeline.Sample, 0, max)
+	for i := step - 1; i < len(samples); i += step {
+		result = append(result, samples[i])
+	}
+	if last := samples[len(samples)-1]; result[len(result)-1].At != last.At {
+		result = append(result, last)
+	}
+	return result
+}
+
+// GET /api/v1/slos
+// POST /api/v1/slos
+func (api *APIServer) handleSLOs(w http.ResponseWriter, r *http.Request) {
+	if api.slos == nil {
+		writeProblem(w, http.StatusServiceUnavailable, CodeNotEnabled, "SLO tracking is not enabled")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		statuses := api.slos.Statuses(time.Now())
+		api.respondJSON(w, map[string]interface{}{
+			"total_count": len(statuses),
+			"slos":        statuses,
+		})
+
+	case http.MethodPost:
+		var def slo.SLO
+		if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		def, err := api.slos.Define(def)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		api.respondJSONStatus(w, http.StatusCreated, def)
+
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/v1/slos/{id}
+// DELETE /api/v1/slos/{id}
+func (api *APIServer) handleSLO(w http.ResponseWriter, r *http.Request) {
+	if api.slos == nil {
+		writeProblem(w, http.StatusServiceUnavailable, CodeNotEnabled, "SLO tracking is not enabled")
+		return
+	}
+
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		status, exists := api.slos.Status(id, time.Now())
+		if !exists {
+			writeError(w, "SLO not found", http.StatusNotFound)
+			return
+		}
+		api.respondJSON(w, status)
+
+	case http.MethodDelete:
+		def, exists, err := api.slos.Remove(id)
+		if err != nil {
+			storageError(w, err)
+			return
+		}
+		if !exists {
+			writeError(w, "SLO not found", http.StatusNotFound)
+			return
+		}
+		api.respondJSON(w, def)
+
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /health
+func (api *APIServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	health := map[string]interface{}{
+		"status": "healthy",
+		"time":   time.Now(),
+	}
+
+	// Check database connection if using PostgreSQL. While it is down,
+	// events are buffered in memory and evaluation continues, so akari
+	// reports itself degraded rather than failing liveness probes.
+	if pgStore, ok := api.store.(*db.PostgresStore); ok {
+		outage := pgStore.Outage()
+		health["database"] = "connected"
+		if err := pgStore.Ping(); err != nil {
+			health["database"] = "disconnected"
+		} else if outage.Degraded {
+			health["database"] = "replaying"
+		}
+		if health["database"] != "connected" {
+			health["status"] = "degraded"
+			health["outage"] = outage
+		}
+	}
+
+	api.respondJSON(w, health)
+}
+
+// GET /ready
+func (api *APIServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	ready := map[string]interface{}{
+		"ready":           true,
+		"invariants_load": len(api.engine.GetInvariants()) > 0,
+		"read_only":       api.config.ReadOnly,
+	}
+	api.respondJSON(w, ready)
+}
+
+// GET /api/v1/admin/db reports table and index sizes, replication lag and
+// cache statistics, to show when retention or pruning needs tuning
+func (api *APIServer) handleDatabaseDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pgStore, ok := api.store.(*db.PostgresStore)
+	if !ok {
+		writeError(w, "Database diagnostics only available with PostgreSQL storage", http.StatusServiceUnavailable)
+		return
+	}
+
+	diagnostics, err := pgStore.Diagnostics(r.Context())
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	api.respondJSON(w, diagnostics)
+}
+
+// GET /api/v1/admin/backup streams a backup of the akari tables in the
+// format of akari backup
+func (api *APIServer) handleBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pgStore, ok := api.store.(*db.PostgresStore)
+	if !ok {
+		writeError(w, "Backups only available with PostgreSQL storage", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="akari-%s.ndjson.gz"`, time.Now().UTC().Format("20060102-150405")))
+	// Errors after the first byte can only be logged; the truncated
+	// gzip stream fails to restore
+	if _, err := pgStore.Backup(r.Context(), w); err != nil {
+		log.Printf("Backup failed: %v", err)
+	}
+}
+
+// POST /api/v1/admin/restore?replace=true loads a backup from the request body
+func (api *APIServer) handleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pgStore, ok := api.store.(*db.PostgresStore)
+	if !ok {
+		writeError(w, "Restores only available with PostgreSQL storage", http.StatusServiceUnavailable)
+		return
+	}
+
+	summary, err := pgStore.Restore(r.Context(), r.Body, r.URL.Query().Get("replace") == "true")
+	switch {
+	case errors.Is(err, db.ErrInvalidBackup):
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, db.ErrRestoreTargetNotEmpty):
+		writeError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		storageError(w, err)
+		return
+	}
+	api.respondJSON(w, summary)
+}
+
+// GET /api/v1/stats
+func (api *APIServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	violations := api.engine.EvaluateAll()
+
+	stats := map[string]interface{}{
+		"total_invariants":  len(api.engine.GetInvariants()),
+		"total_violations":  0,
+		"total_unknown":     len(engine.FilterByStatus(violations, engine.StatusUnknown)),
+		"evaluation_errors": len(api.engine.GetEvaluationErrors()),
+		"by_severity": map[string]int{
+			"critical": 0,
+			"degraded": 0,
+			"warning":  0,
+		},
+		"by_actor": make(map[string]int),
+	}
+
+	for _, v := range violations {
+		if v.Violated {
+			stats["total_violations"] = stats["total_violations"].(int) + 1
+
+			// Count by severity
+			severityMap := stats["by_severity"].(map[string]int)
+			severityMap[string(v.Severity)]++
+
+			// Count by actor
+			actorMap := stats["by_actor"].(map[string]int)
+			actorMap[v.ResponsibleActor]++
+		}
+	}
+
+	for name, source := range api.statsSources {
+		stats[name] = source()
+	}
+
+	api.respondJSON(w, stats)
+}
+
+// GET /api/v1/config reports the settings that decide what is recorded and
+// who may read it, so they can be audited without access to the deployment
+func (api *APIServer) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var exclusions *state.FieldExclusions
+	if excluder, ok := api.store.(state.FieldExcluder); ok {
+		exclusions = excluder.FieldExclusions()
+	}
+	tenants := 0
+	if api.tenants != nil {
+		tenants = api.tenants.Len()
+	}
+	api.respondJSON(w, map[string]interface{}{
+		"read_only":        api.config.ReadOnly,
+		"debug":            api.config.Debug,
+		"field_exclusions": exclusions.Patterns(),
+		"authentication": map[string]interface{}{
+			"tenants":             tenants,
+			"trust_tenant_header": api.trustTenantHeader,
+			"kubernetes_rbac":     api.reviewer != nil,
+		},
+	})
+}
+
+func (api *APIServer) buildCausalChain(invariantID string) []map[string]interface{} {
+	chain := make([]map[string]interface{}, 0)
+
+	inv, exists := api.engine.GetInvariantByID(invariantID)
+	if !exists {
 		return chain
 	}
 
@@ -393,6 +3242,20 @@ func (api *APIServer) buildCausalChain(invariantID string) []map[string]interfac
 		}
 	}
 
+	// Invariants that declare they block this one are candidate root causes,
+	// e.g. cluster DNS or CNI failures behind readiness violations
+	for _, upstream := range api.engine.GetInvariants() {
+		if slices.Contains(upstream.Blocks, inv.ID) {
+			chain = append(chain, map[string]interface{}{
+				"invariant_id": upstream.ID,
+				"description":  upstream.Description,
+				"severity":     upstream.Severity,
+				"actor":        upstream.Responsibility.Primary,
+				"relation":     "blocks",
+			})
+		}
+	}
+
 	return chain
 }
 
@@ -401,20 +3264,74 @@ func (api *APIServer) respondJSON(w http.ResponseWriter, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+func (api *APIServer) respondJSONStatus(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// CorrelationHeader carries the correlation ID of a request. Events
+// recorded by the request without one of their own take it.
+const CorrelationHeader = "X-Correlation-ID"
+
+type correlationKey struct{}
+
+// correlationID returns the correlation ID of a request
+func correlationID(r *http.Request) string {
+	id, _ := r.Context().Value(correlationKey{}).(string)
+	return id
+}
+
+// correlateEvents stamps events without a correlation ID with the request's
+func correlateEvents(r *http.Request, events []types.StateEvent) {
+	id := correlationID(r)
+	for i := range events {
+		if events[i].CorrelationID == "" {
+			events[i].CorrelationID = id
+		}
+	}
+}
+
 func (api *APIServer) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		log.Printf("[%s] %s %s", r.Method, r.URL.Path, r.RemoteAddr)
+		id := r.Header.Get(CorrelationHeader)
+		if id == "" {
+			id = state.NewCorrelationID()
+		}
+		w.Header().Set(CorrelationHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), correlationKey{}, id))
+		log.Printf("[%s] %s %s (correlation %s)", r.Method, r.URL.Path, r.RemoteAddr, id)
+		next.ServeHTTP(w, r)
+		log.Printf("[%s] %s completed in %v (correlation %s)", r.Method, r.URL.Path, time.Since(start), id)
+	})
+}
+
+func (api *APIServer) readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if api.config.ReadOnly && isMutatingMethod(r.Method) && !api.queryRoutes[r.URL.Path] {
+			writeProblem(w, http.StatusForbidden, CodeReadOnly, "API is in read-only mode")
+			return
+		}
+
 		next.ServeHTTP(w, r)
-		log.Printf("[%s] %s completed in %v", r.Method, r.URL.Path, time.Since(start))
 	})
 }
 
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
 func (api *APIServer) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, X-Akari-Tenant")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
@@ -2,16 +2,36 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/aonescu/akari/internal/capacity"
+	"github.com/aonescu/akari/internal/causality"
 	"github.com/aonescu/akari/internal/dsl"
 	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/paging"
+	"github.com/aonescu/akari/internal/quality"
+	"github.com/aonescu/akari/internal/rbac"
+	"github.com/aonescu/akari/internal/slo"
 	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/tenancy"
+	"github.com/aonescu/akari/internal/timeline"
+	"github.com/aonescu/akari/internal/topology"
 	"github.com/aonescu/akari/internal/types"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestAPIServer_HandleHealth(t *testing.T) {
@@ -168,6 +188,130 @@ func TestAPIServer_HandleViolations(t *testing.T) {
 	}
 }
 
+func TestAPIServer_HandleViolations_Sort(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False", "status.phase": "Running"},
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/violations", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var violations []*engine.ViolationResult
+	if err := json.NewDecoder(w.Body).Decode(&violations); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	for i := 1; i < len(violations); i++ {
+		if engine.SeverityRank(violations[i-1].Severity) > engine.SeverityRank(violations[i].Severity) {
+			t.Errorf("Expected violations ordered by severity, got %s before %s", violations[i-1].Severity, violations[i].Severity)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/violations/active?sort=name", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown sort order, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_HandleViolations_GroupBy(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	for _, pod := range []struct{ uid, namespace, name string }{{"pod-1", "shop", "web"}, {"pod-2", "shop", "api"}, {"pod-3", "dev", "web"}} {
+		store.Record(types.StateEvent{
+			UID: pod.uid, Kind: "Pod", Namespace: pod.namespace, Name: pod.name, Version: "1", Timestamp: time.Now(),
+			FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False"},
+		})
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/violations?group_by=namespace,resource&correlate=false", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Total  int                     `json:"total"`
+		Groups []engine.ViolationGroup `json:"groups"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Groups) != 2 || response.Groups[0].Key != "shop" || response.Groups[1].Key != "dev" {
+		t.Fatalf("Expected shop then dev, got %+v", response.Groups)
+	}
+	shop := response.Groups[0]
+	if len(shop.Groups) != 2 || shop.Count != response.Total-response.Groups[1].Count {
+		t.Errorf("Expected shop split by its two pods, got %+v", shop)
+	}
+	for _, g := range shop.Groups {
+		if len(g.Violations) != g.Count || g.BySeverity[dsl.Critical] == 0 {
+			t.Errorf("Expected %s to list its violations, got %+v", g.Key, g)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/violations?group_by=team", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown grouping, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_ActiveViolationsLongPoll(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	api := NewAPIServer(store, eng)
+	handler := api.Handler()
+
+	get := func(query, since string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/violations/active"+query, nil)
+		if since != "" {
+			req.Header.Set("If-Modified-Since", since)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("", "")
+	modified := w.Header().Get("Last-Modified")
+	if w.Code != http.StatusOK || modified == "" {
+		t.Fatalf("Expected 200 with Last-Modified, got %d %q", w.Code, modified)
+	}
+	if w := get("", modified); w.Code != http.StatusNotModified || w.Header().Get("Last-Modified") != modified {
+		t.Errorf("Expected 304 while nothing changed, got %d", w.Code)
+	}
+
+	// A transition ends the wait, even within the same second
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		api.PublishTransition(engine.Transition{Type: engine.TransitionOpened, Violation: &engine.ViolationResult{InvariantID: "pod_ready"}, At: time.Now()})
+	}()
+	w = get("?wait=5s", modified)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 once a transition was published, got %d", w.Code)
+	}
+	before, _ := http.ParseTime(modified)
+	after, err := http.ParseTime(w.Header().Get("Last-Modified"))
+	if err != nil || !after.After(before) {
+		t.Errorf("Expected Last-Modified to move past %v, got %q", before, w.Header().Get("Last-Modified"))
+	}
+
+	start := time.Now()
+	if w := get("?wait=100ms", w.Header().Get("Last-Modified")); w.Code != http.StatusNotModified || time.Since(start) < 100*time.Millisecond {
+		t.Errorf("Expected 304 after waiting, got %d after %v", w.Code, time.Since(start))
+	}
+	if w := get("?wait=2m", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a wait over the limit, got %d", w.Code)
+	}
+}
+
 func TestAPIServer_HandleViolations_WithSeverityFilter(t *testing.T) {
 	store := state.NewMemoryStore()
 	eng := engine.NewInvariantEngine(store)
@@ -335,3 +479,2088 @@ func TestAPIServer_CORSMiddleware(t *testing.T) {
 		t.Error("Expected CORS header to be set")
 	}
 }
+
+func TestAPIServer_ReadOnlyMode(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	api := NewAPIServerWithConfig(store, eng, Config{ReadOnly: true})
+	handler := api.Handler()
+
+	// Mutating requests are rejected
+	req := httptest.NewRequest("DELETE", "/api/v1/invariants", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for DELETE in read-only mode, got %d", w.Code)
+	}
+
+	// Read-only POST queries stay available
+	req = httptest.NewRequest("POST", "/api/v1/invariants/evaluate", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for evaluate in read-only mode, got %d", w.Code)
+	}
+
+	// Reads are unaffected
+	req = httptest.NewRequest("GET", "/api/v1/invariants", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for GET in read-only mode, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_InvariantCRUD(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	body := `{"id":"pod_running","subject":{"kind":"Pod"},"severity":"warning",
+		"predicate":{"field":"status.phase","operator":"equals","value":"Running"}}`
+	req := httptest.NewRequest("POST", "/api/v1/invariants", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	body = `{"subject":{"kind":"Pod"},"severity":"critical",
+		"predicate":{"field":"status.phase","operator":"equals","value":"Running"}}`
+	req = httptest.NewRequest("PUT", "/api/v1/invariants/pod_running", bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var updated dsl.Invariant
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if updated.Version != 2 || updated.Severity != dsl.Critical {
+		t.Errorf("Expected version 2 with critical severity, got v%d %s", updated.Version, updated.Severity)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/v1/invariants/pod_running", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/invariants/pod_running/versions", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var history struct {
+		Versions []dsl.Invariant `json:"versions"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(history.Versions) != 2 {
+		t.Errorf("Expected 2 versions, got %d", len(history.Versions))
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/invariants/pod_running", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected deleted invariant to return 404, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_CreateInvariant_Invalid(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	req := httptest.NewRequest("POST", "/api/v1/invariants", bytes.NewBufferString(`{"id":"x","severity":"fatal"}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_CreateInvariant_Extends(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	body := `{"id":"prod_pod_ready","extends":"pod_ready","subject":{"namespaces":["prod"]}}`
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/invariants", bytes.NewBufferString(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created dsl.Invariant
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if created.Subject.Kind != "Pod" || created.Severity != dsl.Critical || created.Predicate == nil || created.Subject.Namespaces[0] != "prod" {
+		t.Errorf("Expected pod_ready's definition scoped to prod, got %+v", created)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/invariants", bytes.NewBufferString(`{"id":"x","extends":"missing"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a missing base, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_ProblemDetails(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+	readOnly := NewAPIServerWithConfig(store, eng, Config{ReadOnly: true}).Handler()
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		method  string
+		path    string
+		body    string
+		status  int
+		code    ErrorCode
+	}{
+		{"missing invariant", handler, "GET", "/api/v1/invariants/nope", "", http.StatusNotFound, CodeResourceNotFound},
+		{"unknown route", handler, "GET", "/api/v1/nope", "", http.StatusNotFound, CodeResourceNotFound},
+		{"wrong method", handler, "PATCH", "/api/v1/violations", "", http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"invalid invariant", handler, "POST", "/api/v1/invariants", `{"id":"x","severity":"fatal"}`, http.StatusBadRequest, CodeInvalidInvariant},
+		{"read-only", readOnly, "DELETE", "/api/v1/invariants", "", http.StatusForbidden, CodeReadOnly},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Expected problem content type, got %q", ct)
+			}
+			var problem Problem
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("Failed to decode problem: %v", err)
+			}
+			if problem.Code != tt.code || problem.Status != tt.status || problem.Title != http.StatusText(tt.status) {
+				t.Errorf("Unexpected problem %+v", problem)
+			}
+		})
+	}
+}
+
+func TestAPIServer_RequestValidation(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		params []string
+	}{
+		{"limit out of range", "GET", "/api/v1/violations?limit=5000", "", []string{"limit"}},
+		{"limit not a number", "GET", "/api/v1/resources?kind=Pod&limit=ten", "", []string{"limit"}},
+		{"unknown severity", "GET", "/api/v1/violations?severity=fatal", "", []string{"severity"}},
+		{"every invalid param", "GET", "/api/v1/violations?severity=fatal&limit=0&sort=name", "", []string{"sort", "severity", "limit"}},
+		{"bad kind", "GET", "/api/v1/explain/resource?kind=pods&name=web", "", []string{"kind"}},
+		{"bad uid", "GET", "/api/v1/history?uid=pod%201", "", []string{"uid"}},
+		{"unknown consistency", "GET", "/api/v1/resources/pod-1?consistency=eventual", "", []string{"consistency"}},
+		{"missing uid", "GET", "/api/v1/history", "", []string{"uid"}},
+		{"event kind", "POST", "/api/v1/events", `{"uid":"pod-1","kind":"pods","name":"web","actor":"ci"}`, []string{"kind"}},
+		{"event actor", "POST", "/api/v1/events", `{"uid":"pod-1","kind":"Pod","name":"web"}`, []string{"actor"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+			var problem Problem
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("Failed to decode problem: %v", err)
+			}
+			names := make([]string, 0, len(problem.InvalidParams))
+			for _, p := range problem.InvalidParams {
+				names = append(names, p.Name)
+			}
+			if !slices.Equal(names, tt.params) {
+				t.Errorf("Expected invalid params %v, got %+v", tt.params, problem.InvalidParams)
+			}
+		})
+	}
+}
+
+func TestAPIServer_IdempotencyKeys(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/invariants", bytes.NewBufferString(body))
+		req.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	body := `{"id":"pod_running","subject":{"kind":"Pod"},"severity":"warning",
+		"predicate":{"field":"status.phase","operator":"equals","value":"Running"}}`
+
+	first := send("retry-1", body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", first.Code, first.Body.String())
+	}
+	// Without the key the duplicate would be rejected as a conflict
+	retry := send("retry-1", body)
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("Expected the first response replayed, got %d: %s", retry.Code, retry.Body.String())
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the replayed body %s, got %s", first.Body.String(), retry.Body.String())
+	}
+	if versions := eng.GetInvariantVersions("pod_running"); len(versions) != 1 {
+		t.Errorf("Expected one version of pod_running, got %d", len(versions))
+	}
+
+	reused := send("retry-1", strings.Replace(body, "warning", "critical", 1))
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status 422 for a reused key, got %d", reused.Code)
+	}
+	var problem Problem
+	json.NewDecoder(reused.Body).Decode(&problem)
+	if problem.Code != CodeIdempotencyKeyReused {
+		t.Errorf("Expected idempotency_key_reused, got %+v", problem)
+	}
+}
+
+func TestAPIServer_Tenancy(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	api := NewAPIServer(store, eng)
+	registry, err := tenancy.NewRegistry([]tenancy.Tenant{
+		{Name: "payments", Namespaces: []string{"payments"}, Tokens: []string{"pay-token"}},
+		{Name: "shop", Namespaces: []string{"shop"}},
+		{Name: "platform", Namespaces: []string{"*"}, Tokens: []string{"ops-token"}},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	api.SetTenants(registry, true)
+	handler := api.Handler()
+
+	for _, ns := range []string{"payments", "shop"} {
+		store.Record(types.StateEvent{UID: ns + "-pod", Kind: "Pod", Namespace: ns, Name: "web", Timestamp: time.Now()})
+	}
+
+	send := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	payments := []string{"Authorization", "Bearer pay-token"}
+
+	if w := send("GET", "/health", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected /health without a token, got %d", w.Code)
+	}
+	if w := send("GET", "/api/v1/resources?kind=Pod", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", w.Code)
+	}
+	if w := send("GET", "/api/v1/resources?kind=Pod", "", "Authorization", "Bearer wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unknown token, got %d", w.Code)
+	}
+
+	w := send("GET", "/api/v1/resources?kind=Pod", "", payments...)
+	var resources []types.StateEvent
+	json.NewDecoder(w.Body).Decode(&resources)
+	if w.Code != http.StatusOK || len(resources) != 1 || resources[0].Namespace != "payments" {
+		t.Errorf("Expected only the payments pod, got %d: %+v", w.Code, resources)
+	}
+	if w := send("GET", "/api/v1/resources/shop-pod", "", payments...); w.Code != http.StatusNotFound {
+		t.Errorf("Expected another tenant's pod to be hidden, got %d", w.Code)
+	}
+	if w := send("GET", "/api/v1/history?uid=shop-pod", "", payments...); w.Code != http.StatusNotFound {
+		t.Errorf("Expected another tenant's history to be hidden, got %d", w.Code)
+	}
+	if w := send("GET", "/api/v1/stats", "", payments...); w.Code != http.StatusForbidden {
+		t.Errorf("Expected cluster-wide endpoints forbidden, got %d", w.Code)
+	}
+	if w := send("GET", "/api/v1/graph?namespace=payments", "", payments...); w.Code != http.StatusForbidden {
+		t.Errorf("Expected the object graph forbidden to tenants, got %d", w.Code)
+	}
+	event := `{"uid":"pod-2","kind":"Pod","namespace":"shop","name":"api","actor":"ci"}`
+	if w := send("POST", "/api/v1/events", event, payments...); w.Code != http.StatusForbidden {
+		t.Errorf("Expected events for another tenant's namespace forbidden, got %d", w.Code)
+	}
+
+	// Another tenant's resource can't be overwritten or moved by reusing its UID
+	hijack := `{"uid":"shop-pod","kind":"Pod","namespace":"payments","name":"web","actor":"ci"}`
+	if w := send("POST", "/api/v1/events", hijack, payments...); w.Code != http.StatusForbidden {
+		t.Errorf("Expected an event for another tenant's UID forbidden, got %d", w.Code)
+	}
+	if w := send("POST", "/api/v1/events/bulk", "["+hijack+"]", payments...); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a bulk event for another tenant's UID rejected, got %d", w.Code)
+	}
+	if victim, _ := store.GetByUID("shop-pod"); victim.Namespace != "shop" || victim.Actor != "" {
+		t.Errorf("Expected the shop pod untouched, got %+v", victim)
+	}
+
+	// The header names the tenant only when trusted
+	w = send("GET", "/api/v1/resources?kind=Pod", "", tenancy.Header, "shop")
+	resources = nil
+	json.NewDecoder(w.Body).Decode(&resources)
+	if len(resources) != 1 || resources[0].Namespace != "shop" {
+		t.Errorf("Expected only the shop pod, got %+v", resources)
+	}
+
+	w = send("GET", "/api/v1/resources?kind=Pod", "", "Authorization", "Bearer ops-token")
+	resources = nil
+	json.NewDecoder(w.Body).Decode(&resources)
+	if len(resources) != 2 {
+		t.Errorf("Expected an unrestricted tenant to see every pod, got %+v", resources)
+	}
+	if w := send("GET", "/api/v1/stats", "", "Authorization", "Bearer ops-token"); w.Code != http.StatusOK {
+		t.Errorf("Expected an unrestricted tenant to reach cluster-wide endpoints, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_KubernetesRBAC(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	api := NewAPIServer(store, eng)
+
+	// "ci-token" belongs to a service account that may read pods in shop
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		review.Status.Authenticated = review.Spec.Token == "ci-token"
+		review.Status.User.Username = "system:serviceaccount:shop:ci"
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		review.Status.Allowed = review.Spec.ResourceAttributes.Namespace == "shop"
+		return true, review, nil
+	})
+	api.SetRBACReviewer(rbac.NewReviewer(client))
+	handler := api.Handler()
+
+	for _, ns := range []string{"payments", "shop"} {
+		store.Record(types.StateEvent{UID: ns + "-pod", Kind: "Pod", Namespace: ns, Name: "web", Timestamp: time.Now()})
+	}
+
+	send := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("/api/v1/resources?kind=Pod", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", w.Code)
+	}
+	if w := send("/api/v1/resources?kind=Pod", "expired"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a token the API server rejects, got %d", w.Code)
+	}
+	w := send("/api/v1/resources?kind=Pod", "ci-token")
+	var resources []types.StateEvent
+	json.NewDecoder(w.Body).Decode(&resources)
+	if w.Code != http.StatusOK || len(resources) != 1 || resources[0].Namespace != "shop" {
+		t.Errorf("Expected only the shop pod, got %d: %+v", w.Code, resources)
+	}
+	if w := send("/api/v1/resources/payments-pod", "ci-token"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a pod the user can't read to be hidden, got %d", w.Code)
+	}
+	if w := send("/api/v1/stats", "ci-token"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected cluster-wide endpoints forbidden, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_IngestEvent(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	body := `{"uid":"deploy-run-1","kind":"Pipeline","namespace":"ci","name":"deploy-web","actor":"github-actions","field_diff":{"status":"succeeded"}}`
+	req := httptest.NewRequest("POST", "/api/v1/events", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	event, exists := store.GetByUID("deploy-run-1")
+	if !exists {
+		t.Fatal("Expected event to be recorded")
+	}
+	if event.Actor != "github-actions" || event.Version == "" || event.Timestamp.IsZero() {
+		t.Errorf("Unexpected recorded event: %+v", event)
+	}
+
+	// Events without an actor are rejected
+	req = httptest.NewRequest("POST", "/api/v1/events", bytes.NewBufferString(`{"uid":"x","kind":"Pipeline","name":"x"}`))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_IngestEventsBulk(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	body := `[
+		{"uid":"lb-1","kind":"LoadBalancer","name":"edge","actor":"aws"},
+		{"uid":"lb-2","kind":"LoadBalancer","actor":"aws"},
+		{"uid":"lb-3","kind":"LoadBalancer","name":"internal","actor":"aws"}
+	]`
+	req := httptest.NewRequest("POST", "/api/v1/events/bulk", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Recorded int `json:"recorded"`
+		Failed   int `json:"failed"`
+		Errors   []struct {
+			Index int `json:"index"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Recorded != 2 || response.Failed != 1 || response.Errors[0].Index != 1 {
+		t.Errorf("Unexpected bulk response: %+v", response)
+	}
+	if len(store.GetLatestByKind("LoadBalancer")) != 2 {
+		t.Error("Expected 2 load balancers to be recorded")
+	}
+}
+
+func TestAPIServer_IngestEventsRejectsOversizedBody(t *testing.T) {
+	store := state.NewMemoryStore()
+	handler := NewAPIServer(store, engine.NewInvariantEngine(store)).Handler()
+
+	body := `[{"uid":"lb-1","kind":"LoadBalancer","name":"` + strings.Repeat("x", maxEventBatchBytes) + `"}]`
+	req := httptest.NewRequest("POST", "/api/v1/events/bulk", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status 413, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), string(CodePayloadTooLarge)) {
+		t.Errorf("Expected %s error code, got %s", CodePayloadTooLarge, w.Body.String())
+	}
+	if len(store.GetLatestByKind("LoadBalancer")) != 0 {
+		t.Error("Expected nothing to be recorded")
+	}
+
+	body = `{"uid":"lb-1","kind":"LoadBalancer","name":"` + strings.Repeat("x", maxEventBytes) + `"}`
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/events", strings.NewReader(body)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for an oversized event, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_CloudEventsInCausalChain(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	store.Record(types.StateEvent{
+		UID:       "node-1",
+		Kind:      "Node",
+		Name:      "ip-10-0-0-1",
+		Version:   "1",
+		Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{
+			"status.conditions[Ready].status": "False",
+			"spec.providerID":                 "aws:///us-east-1a/i-0abc",
+		},
+	})
+
+	body := `{"detail-type":"EC2 Spot Instance Interruption Warning","region":"us-east-1","detail":{"instance-id":"i-0abc","instance-action":"terminate"}}`
+	req := httptest.NewRequest("POST", "/api/v1/cloud/aws/events", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/causal-chain?invariant_id=node_ready&uid=node-1", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var response struct {
+		CloudEvents []types.StateEvent `json:"cloud_events"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.CloudEvents) != 1 || response.CloudEvents[0].Actor != "cloud-provider" {
+		t.Errorf("Expected spot interruption in causal chain, got %+v", response.CloudEvents)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/cloud/oracle/events", bytes.NewBufferString("{}"))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown provider, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_SLOs(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	api := NewAPIServer(store, eng)
+	handler := api.Handler()
+
+	req := httptest.NewRequest("GET", "/api/v1/slos", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a tracker, got %d", w.Code)
+	}
+
+	api.SetSLOTracker(slo.NewTracker(eng, store))
+
+	body := `{"id":"nodes","invariant_id":"node_ready","target":99.9,"window":"720h"}`
+	req = httptest.NewRequest("POST", "/api/v1/slos", bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/slos/nodes", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var status slo.Status
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if status.State != slo.StateNoData || status.ErrorBudget < 0.00099 || status.ErrorBudget > 0.00101 {
+		t.Errorf("Unexpected SLO status: %+v", status)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/v1/slos/nodes", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_ResourceTier(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "shop", Name: "payments", Version: "1", Timestamp: time.Now()})
+
+	req := httptest.NewRequest("PUT", "/api/v1/resources/pod-1/tier", bytes.NewBufferString(`{"tier":"1"}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&response)
+	if response["tier"] != "tier-1" || response["overridden"] != true {
+		t.Errorf("Unexpected response: %v", response)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/v1/resources/pod-1/tier", bytes.NewBufferString(`{"tier":"platinum"}`))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid tier, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/health-score", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var health engine.HealthScore
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if health.Resources != 1 || health.Violating != 1 || health.ByTier["tier-1"] != 1 {
+		t.Errorf("Unexpected health score: %+v", health)
+	}
+}
+
+func TestAPIServer_Resources(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	store.Record(types.StateEvent{UID: "pod-2", Kind: "Pod", Namespace: "shop", Name: "web-2", Actor: "kubelet", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"status.phase": "Running"}})
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "shop", Name: "web-1", Timestamp: time.Now()})
+	store.Record(types.StateEvent{UID: "pod-3", Kind: "Pod", Namespace: "default", Name: "web-3", Timestamp: time.Now()})
+	store.Record(types.StateEvent{UID: "node-1", Kind: "Node", Name: "node-1", Timestamp: time.Now()})
+
+	list := func(query string) []types.StateEvent {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/v1/resources"+query, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %q, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var resources []types.StateEvent
+		if err := json.NewDecoder(w.Body).Decode(&resources); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resources
+	}
+
+	if resources := list("?kind=Pod&namespace=shop"); len(resources) != 2 || resources[0].Name != "web-1" || resources[1].Name != "web-2" {
+		t.Errorf("Expected web-1 and web-2 in order, got %+v", resources)
+	}
+	if resources := list(""); len(resources) != 4 || resources[0].Kind != "Node" {
+		t.Errorf("Expected every kind without a filter, got %+v", resources)
+	}
+	if resources := list("?kind=Pod&limit=1"); len(resources) != 1 {
+		t.Errorf("Expected the limit to apply, got %d resources", len(resources))
+	}
+	if resources := list("?kind=Service"); len(resources) != 0 {
+		t.Errorf("Expected no services, got %+v", resources)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/resources/pod-2", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var resource types.StateEvent
+	if err := json.NewDecoder(w.Body).Decode(&resource); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resource.Actor != "kubelet" || resource.FieldDiff["status.phase"] != "Running" {
+		t.Errorf("Expected the latest state with its actor and fields, got %+v", resource)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/resources/missing", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_Inventory(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "shop", Name: "web-1", Actor: "kubelet", Timestamp: time.Now()})
+	store.Record(types.StateEvent{UID: "pod-2", Kind: "Pod", Namespace: "default", Name: "web-2", Actor: "kubelet", Timestamp: time.Now()})
+	store.Record(types.StateEvent{UID: "node-1", Kind: "Node", Name: "node-1", Timestamp: time.Now().Add(-2 * time.Hour)})
+
+	req := httptest.NewRequest("GET", "/api/v1/inventory?stale=1h", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var inventory struct {
+		Total       int            `json:"total"`
+		ByNamespace map[string]int `json:"by_namespace"`
+		ByKind      map[string]struct {
+			Count       int            `json:"count"`
+			ByNamespace map[string]int `json:"by_namespace"`
+			ByActor     map[string]int `json:"by_actor"`
+			LastEvent   time.Time      `json:"last_event"`
+			Invariants  int            `json:"invariants"`
+		} `json:"by_kind"`
+		Coverage struct {
+			MissingKinds []string `json:"missing_kinds"`
+			StaleKinds   []string `json:"stale_kinds"`
+			Complete     bool     `json:"complete"`
+		} `json:"coverage"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&inventory); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	pods := inventory.ByKind["Pod"]
+	if inventory.Total != 3 || pods.Count != 2 || pods.ByNamespace["shop"] != 1 || pods.ByActor["kubelet"] != 2 || pods.Invariants == 0 {
+		t.Errorf("Unexpected inventory: %+v", inventory)
+	}
+	if inventory.ByNamespace[""] != 1 || inventory.ByNamespace["default"] != 1 {
+		t.Errorf("Unexpected namespace counts: %v", inventory.ByNamespace)
+	}
+	if !slices.Contains(inventory.Coverage.MissingKinds, "Service") || slices.Contains(inventory.Coverage.MissingKinds, "Pod") {
+		t.Errorf("Expected Service but not Pod to be missing, got %v", inventory.Coverage.MissingKinds)
+	}
+	if len(inventory.Coverage.StaleKinds) != 1 || inventory.Coverage.StaleKinds[0] != "Node" || inventory.Coverage.Complete {
+		t.Errorf("Expected Node to be stale, got %+v", inventory.Coverage)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/inventory?stale=soon", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid duration, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_Actors(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	for _, inv := range eng.GetInvariants() {
+		eng.DeleteInvariant(inv.ID)
+	}
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:             "rollout_not_paused",
+		Subject:        dsl.Subject{Kind: "Deployment"},
+		Severity:       dsl.Critical,
+		Predicate:      &dsl.Predicate{Field: "spec.paused", Operator: dsl.Equals, Value: "false"},
+		Responsibility: dsl.Responsibility{Primary: "release-bot", Team: "apps"},
+	})
+	store.Record(types.StateEvent{UID: "deploy-1", Kind: "Deployment", Namespace: "shop", Name: "web", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"spec.paused": "true"}})
+
+	req := httptest.NewRequest("GET", "/api/v1/actors", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var actors []struct {
+		Name       string         `json:"name"`
+		Team       string         `json:"team"`
+		Contact    string         `json:"contact"`
+		Fields     []string       `json:"fields"`
+		Invariants []string       `json:"invariants"`
+		Violations int            `json:"violations"`
+		BySeverity map[string]int `json:"by_severity"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&actors); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(actors) == 0 || actors[0].Name != "release-bot" {
+		t.Fatalf("Expected release-bot first, got %+v", actors)
+	}
+	bot := actors[0]
+	if bot.Team != "apps" || bot.Violations != 1 || bot.BySeverity["critical"] != 1 || len(bot.Invariants) != 1 {
+		t.Errorf("Unexpected release-bot entry: %+v", bot)
+	}
+	for _, actor := range actors {
+		if actor.Name == "kube-scheduler" {
+			if actor.Contact == "" || !slices.Contains(actor.Fields, "spec.nodeName") || actor.Violations != 0 {
+				t.Errorf("Unexpected kube-scheduler entry: %+v", actor)
+			}
+			return
+		}
+	}
+	t.Error("Expected kube-scheduler to be listed")
+}
+
+func TestAPIServer_ServiceCoverage(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	api := NewAPIServer(store, eng)
+	history := timeline.NewCoverageHistory(eng, time.Hour)
+	api.SetCoverageHistory(history)
+	handler := api.Handler()
+
+	store.Record(types.StateEvent{
+		UID: "svc-api", Kind: "Service", Namespace: "shop", Name: "api", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"spec.selector": map[string]string{"app": "api"}},
+	})
+	for i, ready := range []string{"True", "False"} {
+		store.Record(types.StateEvent{
+			UID: fmt.Sprintf("api-%d", i), Kind: "Pod", Namespace: "shop", Name: fmt.Sprintf("api-%d", i), Version: "1", Timestamp: time.Now(),
+			Labels:    map[string]string{"app": "api"},
+			FieldDiff: map[string]interface{}{"status.conditions[Ready].status": ready},
+		})
+	}
+	history.Observe(nil, time.Now())
+
+	req := httptest.NewRequest("GET", "/api/v1/services/coverage?below=75", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var coverage []engine.Coverage
+	if err := json.NewDecoder(w.Body).Decode(&coverage); err != nil {
+		t.Fatalf("Failed to decode coverage: %v", err)
+	}
+	if len(coverage) != 1 || coverage[0].Percent != 50 {
+		t.Errorf("Expected shop/api at 50%%, got %+v", coverage)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/services/shop/api/coverage", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var response struct {
+		Current engine.Coverage           `json:"current"`
+		History []timeline.CoverageSample `json:"history"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Current.Ready != 1 || len(response.History) != 1 {
+		t.Errorf("Expected current coverage and one sample, got %+v", response)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/services/shop/missing/coverage", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown service, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_TagFiltering(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:        "no_privileged_pods",
+		Subject:   dsl.Subject{Kind: "Pod"},
+		Severity:  dsl.Warning,
+		Tags:      []string{dsl.TagSecurity},
+		Predicate: &dsl.Predicate{Field: "spec.privileged", Operator: dsl.NotEquals, Value: "true"},
+	})
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{
+			"spec.privileged":                 "true",
+			"status.conditions[Ready].status": "False",
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/invariants?tags=security", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var invariants []dsl.Invariant
+	json.NewDecoder(w.Body).Decode(&invariants)
+	found := false
+	for _, inv := range invariants {
+		if !inv.HasAnyTag([]string{dsl.TagSecurity}) {
+			t.Errorf("Expected only security invariants, got %s", inv.ID)
+		}
+		found = found || inv.ID == "no_privileged_pods"
+	}
+	if !found {
+		t.Error("Expected no_privileged_pods in the security invariants")
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/violations?tags=security", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var violations []*engine.ViolationResult
+	json.NewDecoder(w.Body).Decode(&violations)
+	if len(violations) != 1 || violations[0].InvariantID != "no_privileged_pods" {
+		t.Errorf("Expected only the security violation, got %+v", violations)
+	}
+
+	// The limit counts matching violations, not those sorted ahead of them
+	req = httptest.NewRequest("GET", "/api/v1/violations?tags=security&limit=1", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	violations = nil
+	json.NewDecoder(w.Body).Decode(&violations)
+	if len(violations) != 1 || violations[0].InvariantID != "no_privileged_pods" {
+		t.Errorf("Expected the security violation behind more severe ones, got %+v", violations)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/invariants/evaluate?tags=availability,cost", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var evaluation struct {
+		Violations []*engine.ViolationResult `json:"violations"`
+	}
+	json.NewDecoder(w.Body).Decode(&evaluation)
+	for _, v := range evaluation.Violations {
+		if v.InvariantID == "no_privileged_pods" {
+			t.Error("Expected security findings to be filtered out")
+		}
+	}
+	if len(evaluation.Violations) == 0 {
+		t.Error("Expected availability violations")
+	}
+}
+
+func TestAPIServer_EvictionCauseInCausalChain(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	store.Record(types.StateEvent{
+		UID: "node-1", Kind: "Node", Name: "worker-1", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{
+			"status.conditions[Ready].status":          "True",
+			"status.conditions[MemoryPressure].status": "True",
+			"status.conditions[DiskPressure].status":   "False",
+		},
+	})
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{
+			"spec.nodeName":  "worker-1",
+			"status.reason":  "Evicted",
+			"status.message": "The node was low on resource: memory.",
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/causal-chain?invariant_id=no_recent_evictions&uid=pod-1", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var response struct {
+		EvictionCause struct {
+			Node       string   `json:"node"`
+			NodeUID    string   `json:"node_uid"`
+			Conditions []string `json:"conditions"`
+		} `json:"eviction_cause"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	cause := response.EvictionCause
+	if cause.NodeUID != "node-1" || len(cause.Conditions) != 1 || cause.Conditions[0] != "MemoryPressure" {
+		t.Errorf("Expected the eviction to trace to MemoryPressure on worker-1, got %+v", cause)
+	}
+
+	violated := false
+	for _, v := range eng.EvaluateAll() {
+		if v.InvariantID == "no_recent_evictions" && v.Violated {
+			violated = true
+		}
+	}
+	if !violated {
+		t.Error("Expected no_recent_evictions to be violated")
+	}
+}
+
+func TestAPIServer_FollowsRecreatedPods(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	now := time.Now()
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "shop", Name: "web-0", Version: "1", Timestamp: now.Add(-time.Hour),
+		FieldDiff: map[string]interface{}{
+			state.FieldController: "StatefulSet/web",
+			"spec.nodeName":       "worker-1",
+			"status.reason":       "Evicted",
+		},
+	})
+	store.Record(types.StateEvent{
+		UID: "pod-2", Kind: "Pod", Namespace: "shop", Name: "web-0", Version: "1", Timestamp: now,
+		FieldDiff: map[string]interface{}{state.FieldController: "StatefulSet/web", "status.phase": "Pending"},
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/history?uid=pod-2&follow=true", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var history []types.StateEvent
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode history: %v", err)
+	}
+	if len(history) != 2 || history[0].UID != "pod-2" || history[1].UID != "pod-1" {
+		t.Errorf("Expected both incarnations newest first, got %+v", history)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/causal-chain?invariant_id=no_recent_evictions&uid=pod-2", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var response struct {
+		LogicalResource string                 `json:"logical_resource"`
+		Incarnations    []string               `json:"incarnations"`
+		EvictionCause   map[string]interface{} `json:"eviction_cause"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.LogicalResource != "Pod/shop/web-0" || len(response.Incarnations) != 1 || response.Incarnations[0] != "pod-1" {
+		t.Errorf("Expected pod-1 as the previous incarnation of Pod/shop/web-0, got %+v", response)
+	}
+	if response.EvictionCause["node"] != "worker-1" {
+		t.Errorf("Expected the eviction of pod-1 to explain pod-2, got %v", response.EvictionCause)
+	}
+}
+
+func TestAPIServer_Compare(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:        "web_ready",
+		Subject:   dsl.Subject{Kind: "Pod"},
+		Severity:  dsl.Critical,
+		Predicate: &dsl.Predicate{Field: "status.conditions[Ready].status", Operator: dsl.Equals, Value: "True"},
+	})
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web", Version: "1", Timestamp: base,
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "True"},
+		Actor:     "kubelet",
+	})
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web", Version: "2", Timestamp: base.Add(time.Hour),
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False"},
+		Actor:     "kubelet",
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/compare?from=2025-01-01T10:30:00Z&to=2025-01-01T12:00:00Z", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		NewlyBroken     []engine.ViolationResult `json:"newly_broken"`
+		Resolved        []engine.ViolationResult `json:"resolved"`
+		ResourceChanges []struct {
+			UID     string                 `json:"uid"`
+			Changed map[string]interface{} `json:"changed"`
+		} `json:"resource_changes"`
+		Actors []struct {
+			Actor   string `json:"actor"`
+			Changes int    `json:"changes"`
+		} `json:"actors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	broke := false
+	for _, v := range response.NewlyBroken {
+		if v.InvariantID == "web_ready" {
+			broke = true
+		}
+	}
+	if !broke {
+		t.Errorf("Expected web_ready among newly broken violations, got %+v", response.NewlyBroken)
+	}
+	if len(response.Resolved) != 0 {
+		t.Errorf("Expected nothing resolved, got %+v", response.Resolved)
+	}
+	if len(response.ResourceChanges) != 1 || response.ResourceChanges[0].Changed["status.conditions[Ready].status"] != "False" {
+		t.Errorf("Expected the Ready change of pod-1, got %+v", response.ResourceChanges)
+	}
+	if len(response.Actors) != 1 || response.Actors[0].Actor != "kubelet" || response.Actors[0].Changes != 1 {
+		t.Errorf("Expected one kubelet change, got %+v", response.Actors)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/compare", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without from, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_Deploys(t *testing.T) {
+	store := state.NewMemoryStore()
+	api := NewAPIServer(store, engine.NewInvariantEngine(store))
+	api.SetDeployWebhookSecret("s3cret")
+	handler := api.Handler()
+
+	body := `{"repository": "acme/api", "commit": "9f2c1e0", "environment": "production", "actor": "alice"}`
+	req := httptest.NewRequest("POST", "/api/v1/deploys/ci?image=acme/api:2&namespace=default", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected unsigned deliveries to be rejected, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/deploys/ci?image=acme/api:2&namespace=default", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "api-1", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"spec.containers[api].image": "acme/api:2"},
+	})
+
+	req = httptest.NewRequest("GET", "/api/v1/causal-chain?invariant_id=containers_running&uid=pod-1", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var response struct {
+		Deploys []types.StateEvent `json:"deploys"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Deploys) != 1 || response.Deploys[0].Actor != "alice" || response.Deploys[0].FieldDiff["change.commit"] != "9f2c1e0" {
+		t.Errorf("Expected alice's deploy of commit 9f2c1e0 in the causal chain, got %+v", response.Deploys)
+	}
+}
+
+func TestAPIServer_IncidentPostmortem(t *testing.T) {
+	store := state.NewMemoryStore()
+	api := NewAPIServer(store, engine.NewInvariantEngine(store))
+	handler := api.Handler()
+	rec := timeline.NewRecorder(time.Hour)
+	api.SetTimeline(rec)
+
+	start := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "api", Timestamp: start.Add(-2 * time.Minute),
+		FieldDiff: map[string]interface{}{"spec.image": "api:v1"}})
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "api", Actor: "alice", Timestamp: start.Add(-time.Minute),
+		FieldDiff: map[string]interface{}{"spec.image": "api:v2"}})
+
+	v := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/api", ResourceUID: "pod-1", Severity: dsl.Critical,
+		Reason: "pod not ready", Status: engine.StatusViolated, Violated: true, DetectedAt: start}
+	spread := &engine.ViolationResult{InvariantID: "service_has_endpoints", AffectedResource: "default/api-svc", Severity: dsl.Critical, Status: engine.StatusViolated, Violated: true}
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: v, At: start})
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: spread, At: start.Add(time.Minute)})
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: v, At: start.Add(5 * time.Minute)})
+
+	req := httptest.NewRequest("GET", "/api/v1/incidents/pod_ready%7Cdefault%2Fapi/postmortem", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	for _, want := range []string{"# Incident: pod_ready on default/api", "**Duration:** 5m0s", "Pod default/api changed by alice: spec.image", "Also violated: service_has_endpoints", "Incident resolved"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in the postmortem:\n%s", want, body)
+		}
+	}
+	if strings.Index(body, "changed by alice") > strings.Index(body, "First violation") {
+		t.Errorf("Expected entries in chronological order:\n%s", body)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/incidents/pod_ready%7Cdefault%2Fmissing/postmortem", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown incident, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_Grafana(t *testing.T) {
+	store := state.NewMemoryStore()
+	api := NewAPIServer(store, engine.NewInvariantEngine(store))
+	handler := api.Handler()
+
+	req := httptest.NewRequest("POST", "/api/v1/grafana/query", bytes.NewBufferString(`{}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 without a timeline, got %d", w.Code)
+	}
+
+	rec := timeline.NewRecorder(time.Hour)
+	api.SetTimeline(rec)
+	now := time.Now().Truncate(time.Millisecond)
+	v := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/api", Severity: dsl.Critical, Status: engine.StatusViolated, Violated: true, DetectedAt: now}
+	rec.Observe([]*engine.ViolationResult{v}, now)
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: v, At: now})
+
+	from, to := now.Add(-time.Minute).Format(time.RFC3339), now.Add(time.Minute).Format(time.RFC3339)
+	body := `{"range": {"from": "` + from + `", "to": "` + to + `"}, "targets": [{"target": "violations_by_severity"}, {"target": "active_violations"}]}`
+	req = httptest.NewRequest("POST", "/api/v1/grafana/query", bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response) != 4 {
+		t.Fatalf("Expected three severity series and a table, got %d entries", len(response))
+	}
+	points, _ := response[0]["datapoints"].([]interface{})
+	if response[0]["target"] != "critical" || len(points) != 1 || points[0].([]interface{})[0] != float64(1) {
+		t.Errorf("Unexpected critical series %v", response[0])
+	}
+	rows, _ := response[3]["rows"].([]interface{})
+	if response[3]["type"] != "table" || len(rows) != 1 || rows[0].([]interface{})[1] != "pod_ready" {
+		t.Errorf("Unexpected active violations table %v", response[3])
+	}
+
+	body = `{"range": {"from": "` + from + `", "to": "` + to + `"}, "annotation": {"query": "critical"}}`
+	req = httptest.NewRequest("POST", "/api/v1/grafana/annotations", bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var annotations []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&annotations); err != nil {
+		t.Fatalf("Failed to decode annotations: %v", err)
+	}
+	if len(annotations) != 1 || annotations[0]["time"] != float64(now.UnixMilli()) {
+		t.Errorf("Expected one annotation at %d, got %v", now.UnixMilli(), annotations)
+	}
+
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: v, At: now.Add(time.Second)})
+	url := fmt.Sprintf("/api/v1/annotations?from=%d&to=%d&tags=critical", now.Add(-time.Minute).UnixMilli(), now.Add(time.Minute).UnixMilli())
+	req = httptest.NewRequest("GET", url, nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var feed []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&feed); err != nil {
+		t.Fatalf("Failed to decode annotations feed: %v", err)
+	}
+	if len(feed) != 2 || feed[0]["type"] != "started" || feed[1]["type"] != "resolved" {
+		t.Errorf("Expected start and resolve events, got %v", feed)
+	}
+}
+
+func TestAPIServer_Config(t *testing.T) {
+	store := state.NewMemoryStore()
+	exclusions, _ := state.NewFieldExclusions([]string{"metadata.labels.owner-email"})
+	store.SetFieldExclusions(exclusions)
+	handler := NewAPIServer(store, engine.NewInvariantEngine(store)).Handler()
+
+	req := httptest.NewRequest("GET", "/api/v1/config", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var config struct {
+		FieldExclusions []string `json:"field_exclusions"`
+	}
+	json.NewDecoder(w.Body).Decode(&config)
+	if !slices.Equal(config.FieldExclusions, []string{"metadata.labels.owner-email"}) {
+		t.Errorf("Expected the exclusions listed, got %v", config.FieldExclusions)
+	}
+}
+
+func TestAPIServer_SandboxEvaluate(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+	builtin := len(eng.GetInvariants())
+
+	invariant := `{"id":"pod_running","subject":{"kind":"Pod"},"severity":"warning",
+		"predicate":{"field":"status.phase","operator":"equals","value":"Running"}}`
+	tests := []struct {
+		name     string
+		resource string
+		status   string
+	}{
+		{"satisfied", `{"kind":"Pod","field_diff":{"status.phase":"Running"}}`, "satisfied"},
+		{"violated", `{"kind":"Pod","field_diff":{"status.phase":"Pending"}}`, "violated"},
+		{"full state", `{"kind":"Pod","full_state":{"status":{"phase":"Pending"}}}`, "violated"},
+		{"not a subject", `{"kind":"Node","field_diff":{"status.phase":"Pending"}}`, "not_matched"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"invariant":%s,"resource":%s}`, invariant, tt.resource)
+			req := httptest.NewRequest("POST", "/api/v1/sandbox/evaluate", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var response map[string]interface{}
+			json.NewDecoder(w.Body).Decode(&response)
+			if response["status"] != tt.status {
+				t.Errorf("Expected status %s, got %v", tt.status, response)
+			}
+		})
+	}
+
+	// Nothing is registered or recorded
+	if len(eng.GetInvariants()) != builtin || len(store.GetLatestByKind("Pod")) != 0 {
+		t.Error("Expected the sandbox to leave the server's engine and store untouched")
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/sandbox/evaluate", strings.NewReader(`{"invariant":{"id":"x"},"resource":{"kind":"Pod"}}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid invariant, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_NotificationReplay(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	store := state.NewMemoryStore()
+	api := NewAPIServer(store, engine.NewInvariantEngine(store))
+	handler := api.Handler()
+	pager := paging.NewPager(nil, &paging.Webhook{Target: "chatops", URL: receiver.URL})
+	pager.SetRetry(1, 0)
+	api.SetPager(pager)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pager.Run(ctx)
+
+	v := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/api", Severity: dsl.Critical, Violated: true}
+	pager.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: v, At: time.Now()})
+	deadline := time.Now().Add(2 * time.Second)
+	for pager.Stats().DeadLettered == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var letters struct {
+		TotalCount  int                 `json:"total_count"`
+		DeadLetters []paging.DeadLetter `json:"dead_letters"`
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/notifications/dead-letters", nil))
+	if err := json.NewDecoder(w.Body).Decode(&letters); err != nil || letters.TotalCount != 1 || letters.DeadLetters[0].Client != "webhook chatops" {
+		t.Fatalf("Expected one dead letter for the webhook, got %d %+v", w.Code, letters)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/notifications/replay", strings.NewReader(`{"ids": [99]}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 replaying an unknown dead letter, got %d", w.Code)
+	}
+
+	fail.Store(false)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/notifications/replay", nil))
+	var result paging.ReplayResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil || result.Replayed != 1 || result.Remaining != 0 {
+		t.Fatalf("Expected the dead letter to be replayed, got %d %+v", w.Code, result)
+	}
+
+	var deliveries struct {
+		TotalCount int               `json:"total_count"`
+		Deliveries []paging.Delivery `json:"deliveries"`
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/incidents/pod_ready%7Cdefault%2Fapi/deliveries", nil))
+	if err := json.NewDecoder(w.Body).Decode(&deliveries); err != nil || deliveries.TotalCount != 2 {
+		t.Fatalf("Expected 2 delivery attempts, got %d %+v", w.Code, deliveries)
+	}
+	if deliveries.Deliveries[0].Error == "" || deliveries.Deliveries[1].Error != "" {
+		t.Errorf("Expected a failed then a replayed attempt, got %+v", deliveries.Deliveries)
+	}
+}
+
+func TestAPIServer_FlaggedInvariants(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	eng.SetCardinalityLimit(50, 1)
+	handler := NewAPIServer(store, eng).Handler()
+
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "api", Timestamp: time.Now()})
+	eng.CollapseFloods([]*engine.ViolationResult{{InvariantID: "pod_ready", Violated: true, ResourceUID: "pod-1", AffectedResource: "default/api"}})
+
+	var flagged struct {
+		TotalCount int                      `json:"total_count"`
+		Flagged    []engine.CardinalityFlag `json:"flagged"`
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/invariants/flagged", nil))
+	if err := json.NewDecoder(w.Body).Decode(&flagged); err != nil || flagged.TotalCount != 1 || flagged.Flagged[0].InvariantID != "pod_ready" {
+		t.Fatalf("Expected pod_ready to be flagged, got %d %+v", w.Code, flagged)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/invariants/pod_ready/review", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/invariants/pod_ready/review", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 reviewing an unflagged invariant, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_CorrelationID(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	body := `{"uid":"run-1","kind":"Pipeline","namespace":"ci","name":"deploy","actor":"ci","field_diff":{"status":"failed"}}`
+	req := httptest.NewRequest("POST", "/api/v1/events", bytes.NewBufferString(body))
+	req.Header.Set(CorrelationHeader, "req-42")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(CorrelationHeader); got != "req-42" {
+		t.Errorf("Expected the correlation ID to be echoed, got %q", got)
+	}
+
+	// The ID follows the event into the violations it causes
+	results := eng.Evaluate(dsl.Invariant{
+		ID:          "pipeline_succeeds",
+		Subject:     dsl.Subject{Kind: "Pipeline"},
+		Predicate:   &dsl.Predicate{Field: "status", Operator: dsl.Equals, Value: "succeeded"},
+		Severity:    dsl.Warning,
+		Description: "Pipelines succeed",
+	})
+	if len(results) != 1 || !results[0].Violated || results[0].CorrelationID != "req-42" {
+		t.Errorf("Expected a violation correlated to req-42, got %+v", results)
+	}
+
+	// Requests without one are assigned an ID
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Header().Get(CorrelationHeader) == "" {
+		t.Error("Expected a generated correlation ID")
+	}
+}
+
+func TestAPIServer_EvaluationLog(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "api", FieldDiff: map[string]interface{}{"status.phase": "Running"}})
+	eng.EvaluateAll()
+
+	req := httptest.NewRequest("GET", "/api/v1/evaluations/log?uid=pod-1&limit=5&since=2020-01-01T00:00:00Z", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		TotalCount int                         `json:"total_count"`
+		Entries    []engine.EvaluationLogEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.TotalCount == 0 || response.TotalCount > 5 {
+		t.Fatalf("Expected between 1 and 5 entries, got %d", response.TotalCount)
+	}
+	for _, entry := range response.Entries {
+		if entry.ResourceUID != "pod-1" {
+			t.Errorf("Expected only pod-1 entries, got %+v", entry)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/evaluations/log?until=yesterday", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid until, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_ExplainInvariant(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "api", FieldDiff: map[string]interface{}{}})
+
+	req := httptest.NewRequest("GET", "/api/v1/explain/invariant?invariant_id=pod_scheduled&uid=pod-1", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var explanation engine.Explanation
+	if err := json.NewDecoder(w.Body).Decode(&explanation); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if explanation.Status != engine.StatusViolated || explanation.Predicate == nil || explanation.Authority == nil {
+		t.Errorf("Expected a violated trace with predicate and authority, got %+v", explanation)
+	}
+
+	for url, status := range map[string]int{
+		"/api/v1/explain/invariant?uid=pod-1":                            http.StatusBadRequest,
+		"/api/v1/explain/invariant?invariant_id=missing&uid=pod-1":       http.StatusNotFound,
+		"/api/v1/explain/invariant?invariant_id=pod_scheduled&uid=pod-2": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != status {
+			t.Errorf("%s: expected status %d, got %d", url, status, w.Code)
+		}
+	}
+}
+
+func TestAPIServer_Graph(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	store.Record(types.StateEvent{UID: "node-1", Kind: "Node", Name: "node-1", FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "True"}})
+	store.Record(types.StateEvent{UID: "node-2", Kind: "Node", Name: "node-2", FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "True"}})
+	store.Record(types.StateEvent{UID: "svc-1", Kind: "Service", Namespace: "prod", Name: "api", FieldDiff: map[string]interface{}{"spec.selector": map[string]interface{}{"app": "api"}}})
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api", Labels: map[string]string{"app": "api"}, FieldDiff: map[string]interface{}{"spec.nodeName": "node-1"}})
+	store.Record(types.StateEvent{UID: "pod-2", Kind: "Pod", Namespace: "dev", Name: "api", Labels: map[string]string{"app": "api"}, FieldDiff: map[string]interface{}{}})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/graph?namespace=prod", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var graph topology.Graph
+	if err := json.NewDecoder(w.Body).Decode(&graph); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	ids := make([]string, 0, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		ids = append(ids, n.ID)
+	}
+	if strings.Join(ids, ",") != "node-1,pod-1,svc-1" {
+		t.Errorf("Expected the prod resources and the node they use, got %v", ids)
+	}
+	if len(graph.Edges) != 2 {
+		t.Errorf("Expected scheduled-on and selects edges, got %+v", graph.Edges)
+	}
+}
+
+func TestAPIServer_CausalPath(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	store.Record(types.StateEvent{UID: "node-1", Kind: "Node", Name: "node-1", FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False"}})
+	store.Record(types.StateEvent{UID: "svc-1", Kind: "Service", Namespace: "prod", Name: "api", FieldDiff: map[string]interface{}{"spec.selector": map[string]interface{}{"app": "api"}}})
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api", Labels: map[string]string{"app": "api"}, FieldDiff: map[string]interface{}{"spec.nodeName": "node-1"}})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/causal-path?from_uid=node-1&to_uid=svc-1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Paths []topology.Path `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Paths) != 1 || len(response.Paths[0].Hops) != 2 {
+		t.Fatalf("Expected one route through pod-1, got %+v", response.Paths)
+	}
+	hops := response.Paths[0].Hops
+	if hops[0].To != "pod-1" || !slices.Contains(hops[0].Invariants, "pod_ready requires node_ready") {
+		t.Errorf("Expected the node hop to be backed by pod_ready, got %+v", hops[0])
+	}
+	if hops[1].Type != topology.EdgeSelects || !slices.Contains(hops[1].Invariants, "pod_ready blocks service_has_endpoints") {
+		t.Errorf("Expected the blocks link to back the selects hop, got %+v", hops[1])
+	}
+	if !slices.Contains(response.Paths[0].Resources[0].Violations, "node_ready") {
+		t.Errorf("Expected node_ready overlaid on node-1, got %+v", response.Paths[0].Resources[0])
+	}
+
+	for _, tc := range []struct {
+		query string
+		code  int
+	}{
+		{"to_uid=svc-1", http.StatusBadRequest},
+		{"from_uid=node-1&to_uid=missing", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/causal-path?"+tc.query, nil))
+		if w.Code != tc.code {
+			t.Errorf("%s: expected status %d, got %d", tc.query, tc.code, w.Code)
+		}
+	}
+}
+
+func TestAPIServer_CausalDiagrams(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	store.Record(types.StateEvent{UID: "node-1", Kind: "Node", Name: "node-1", FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False"}})
+	store.Record(types.StateEvent{UID: "svc-1", Kind: "Service", Namespace: "prod", Name: "api", FieldDiff: map[string]interface{}{"spec.selector": map[string]interface{}{"app": "api"}}})
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api", Labels: map[string]string{"app": "api"}, FieldDiff: map[string]interface{}{"spec.nodeName": "node-1"}})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/causal-chain?invariant_id=pod_ready&format=dot", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/vnd.graphviz") {
+		t.Errorf("Expected a Graphviz content type, got %q", ct)
+	}
+	dot := w.Body.String()
+	if !strings.HasPrefix(dot, `digraph "pod_ready" {`) || !strings.Contains(dot, `"node_ready" -> "pod_ready" [label="required (node)"];`) {
+		t.Errorf("Expected node_ready to point to pod_ready, got:\n%s", dot)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/causal-path?from_uid=node-1&to_uid=svc-1&format=mermaid", nil))
+	mermaid := w.Body.String()
+	if !strings.Contains(mermaid, "flowchart LR") || !strings.Contains(mermaid, `n0 -->|"scheduled-on"| n1`) || !strings.Contains(mermaid, `n1 -->|"selects"| n2`) {
+		t.Errorf("Expected the node, pod and service chained, got:\n%s", mermaid)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/causal-chain?invariant_id=pod_ready&format=svg", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown format, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_GraphQL(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:        "web_ready",
+		Subject:   dsl.Subject{Kind: "Pod"},
+		Severity:  dsl.Critical,
+		Predicate: &dsl.Predicate{Field: "status.conditions[Ready].status", Operator: dsl.Equals, Value: "True"},
+	})
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api", Timestamp: time.Now(), FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False"}})
+	store.Record(types.StateEvent{UID: "pod-2", Kind: "Pod", Namespace: "prod", Name: "web", Timestamp: time.Now(), FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "True"}})
+
+	query := `query Failing($kind: String!) {
+		violations(invariantId: "web_ready") { invariantId resource { name } invariant { severity } }
+		resources(kind: $kind, limit: 5) { uid violations { invariantId } }
+		chain: causalChain(invariantId: "web_ready") { invariantId }
+	}`
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": map[string]interface{}{"kind": "Pod"}})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/graphql", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data struct {
+			Violations []struct {
+				InvariantID string `json:"invariantId"`
+				Resource    struct {
+					Name string `json:"name"`
+				} `json:"resource"`
+				Invariant struct {
+					Severity string `json:"severity"`
+				} `json:"invariant"`
+			} `json:"violations"`
+			Resources []struct {
+				UID        string `json:"uid"`
+				Violations []struct {
+					InvariantID string `json:"invariantId"`
+				} `json:"violations"`
+			} `json:"resources"`
+			Chain []interface{} `json:"chain"`
+		} `json:"data"`
+		Errors []interface{} `json:"errors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %v", resp.Errors)
+	}
+	if len(resp.Data.Violations) != 1 || resp.Data.Violations[0].Resource.Name != "api" || resp.Data.Violations[0].Invariant.Severity != "critical" {
+		t.Errorf("Expected the api pod's violation with its invariant, got %+v", resp.Data.Violations)
+	}
+	if len(resp.Data.Resources) != 2 {
+		t.Fatalf("Expected both pods, got %+v", resp.Data.Resources)
+	}
+	for _, r := range resp.Data.Resources {
+		violated := false
+		for _, v := range r.Violations {
+			violated = violated || v.InvariantID == "web_ready"
+		}
+		if violated != (r.UID == "pod-1") {
+			t.Errorf("Expected only pod-1 to violate web_ready, got %s: %+v", r.UID, r.Violations)
+		}
+	}
+	if len(resp.Data.Chain) != 1 {
+		t.Errorf("Expected a one-link causal chain, got %v", resp.Data.Chain)
+	}
+
+	// GET takes the query as a parameter; errors come back with the data
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ resources { uid } }"), nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `argument \"kind\" of type String! is required`) {
+		t.Errorf("Expected a missing argument error, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAPIServer_CapacityForecast(t *testing.T) {
+	store := state.NewMemoryStore()
+	handler := NewAPIServer(store, engine.NewInvariantEngine(store)).Handler()
+
+	now := time.Now()
+	store.Record(types.StateEvent{UID: "node-1", Kind: "Node", Name: "node-1", Version: "1", Timestamp: now.Add(-2 * time.Hour), FieldDiff: map[string]interface{}{
+		"status.conditions[Ready].status": "True",
+		"status.allocatable.cpuMillis":    int64(4000),
+		"status.allocatable.memoryBytes":  int64(8 << 30),
+	}})
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api", Version: "1", Timestamp: now.Add(-time.Hour), FieldDiff: map[string]interface{}{
+		"spec.nodeName":             "node-1",
+		"status.phase":              "Running",
+		"spec.requests.cpuMillis":   int64(3800),
+		"spec.requests.memoryBytes": int64(1 << 30),
+	}})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/analytics/capacity?window=3h&threshold=0.9", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var forecast capacity.Forecast
+	if err := json.NewDecoder(w.Body).Decode(&forecast); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if forecast.PressureResource != "cpu" || !forecast.WithinHorizon || forecast.Threshold != 0.9 {
+		t.Errorf("Expected CPU pressure above the 90%% threshold, got %+v", forecast)
+	}
+
+	for _, query := range []string{"threshold=1.5", "window=-1h", "horizon=soon"} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/analytics/capacity?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestAPIServer_InvariantQuality(t *testing.T) {
+	store := state.NewMemoryStore()
+	api := NewAPIServer(store, engine.NewInvariantEngine(store))
+	handler := api.Handler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/invariants/quality", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 without a tracker, got %d", w.Code)
+	}
+
+	tracker := quality.NewTracker(time.Hour, time.Minute)
+	api.SetQualityTracker(tracker)
+	v := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/api", Status: engine.StatusViolated, Violated: true}
+	now := time.Now()
+	tracker.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: v, At: now.Add(-2 * time.Minute)})
+	tracker.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: v, At: now.Add(-90 * time.Second)})
+	tracker.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: v, At: now.Add(-time.Minute)})
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/invariants/quality?verdict=flapping", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report quality.Report
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(report.Invariants) != 1 || report.Invariants[0].InvariantID != "pod_ready" || report.Invariants[0].Flaps != 1 {
+		t.Errorf("Expected pod_ready to be flapping, got %+v", report.Invariants)
+	}
+}
+
+func TestAPIServer_Suggestions(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	now := time.Now()
+	for i, uid := range []string{"pod-1", "pod-2", "pod-3"} {
+		store.Record(types.StateEvent{UID: uid, Kind: "Pod", Namespace: "prod", Name: uid, Version: "1", Timestamp: now.Add(time.Duration(i-5) * time.Minute), Actor: "kubelet/node-1",
+			FieldDiff: map[string]interface{}{"status.containerStatuses.waiting.reason": "ImagePullBackOff"}})
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/suggestions?window=1h", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Suggestions []struct {
+			ID          string `json:"id"`
+			Occurrences int    `json:"occurrences"`
+		} `json:"suggestions"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Suggestions) != 1 || resp.Suggestions[0].ID != "pod_no_image_pull_back_off" || resp.Suggestions[0].Occurrences != 3 {
+		t.Fatalf("Expected an ImagePullBackOff suggestion, got %+v", resp.Suggestions)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/suggestions/pod_no_image_pull_back_off/accept?window=1h", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, exists := eng.GetInvariantByID("pod_no_image_pull_back_off"); !exists {
+		t.Error("Expected the accepted draft to be active")
+	}
+
+	// Accepted suggestions are covered and drop out
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/suggestions/pod_no_image_pull_back_off/accept?window=1h", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 once accepted, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/suggestions?min_occurrences=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for min_occurrences=0, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_Counterfactual(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:        "web_ready",
+		Subject:   dsl.Subject{Kind: "Pod"},
+		Severity:  dsl.Critical,
+		Predicate: &dsl.Predicate{Field: "status.conditions[Ready].status", Operator: dsl.Equals, Value: "True"},
+	})
+	changedAt := time.Now().Add(-time.Minute).UTC()
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api", Version: "1", Timestamp: changedAt.Add(-time.Minute), FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "True"}})
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api", Version: "2", Timestamp: changedAt, FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False"}})
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"violation_id": "web_ready|prod/api",
+		"change":       map[string]interface{}{"resource_uid": "pod-1", "changed_at": changedAt},
+	})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/counterfactual", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var cf engine.Counterfactual
+	if err := json.NewDecoder(w.Body).Decode(&cf); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if cf.Verdict != engine.CounterfactualNecessary {
+		t.Errorf("Expected the readiness change to be necessary, got %+v", cf)
+	}
+
+	body, _ = json.Marshal(map[string]interface{}{
+		"violation_id": "web_ready|prod/other",
+		"change":       map[string]interface{}{"resource_uid": "pod-1", "changed_at": changedAt},
+	})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/counterfactual", bytes.NewReader(body)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown violation, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_RanksCausesInCausalChain(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	handler := NewAPIServer(store, eng).Handler()
+
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:        "web_ready",
+		Subject:   dsl.Subject{Kind: "Pod"},
+		Severity:  dsl.Critical,
+		Predicate: &dsl.Predicate{Field: "status.conditions[Ready].status", Operator: dsl.Equals, Value: "True"},
+	})
+	now := time.Now()
+	record := func(version, actor string, at time.Time, fields map[string]interface{}) {
+		store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api", Version: version, Timestamp: at, Actor: actor, FieldDiff: fields})
+	}
+	record("1", "kubelet/node-1", now.Add(-10*time.Minute), map[string]interface{}{"status.conditions[Ready].status": "True"})
+	record("2", "kubelet/node-1", now.Add(-5*time.Minute), map[string]interface{}{"status.conditions[Ready].status": "False"})
+	record("3", "kubectl", now.Add(-time.Minute), map[string]interface{}{"status.conditions[Ready].status": "False", "metadata.labels.team": "web"})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/causal-chain?invariant_id=web_ready&uid=pod-1", nil))
+	var response struct {
+		Causes []causality.Cause `json:"causes"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Causes) != 3 {
+		t.Fatalf("Expected 3 ranked causes, got %+v", response.Causes)
+	}
+	top := response.Causes[0]
+	if top.Actor != "kubelet/node-1" || top.Fields["status.conditions[Ready].status"] != "False" || top.Authority != 1 {
+		t.Errorf("Expected the kubelet readiness change first, got %+v", top)
+	}
+	// The later label change is closer, but doesn't touch readiness
+	if last := response.Causes[2]; last.Actor != "kubectl" || last.Authority != 0 || last.Proximity <= top.Proximity {
+		t.Errorf("Expected the label change ranked last, got %+v", last)
+	}
+}
+
+func TestAPIServer_EvaluationJobs(t *testing.T) {
+	store := state.NewMemoryStore()
+	handler := NewAPIServer(store, engine.NewInvariantEngine(store)).Handler()
+	for i := 0; i < 3; i++ {
+		store.Record(types.StateEvent{UID: fmt.Sprintf("pod-%d", i), Kind: "Pod", Name: fmt.Sprintf("api-%d", i), Namespace: "default",
+			Timestamp: time.Now(), FieldDiff: map[string]interface{}{"status.phase": "Pending"}})
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/evaluations/jobs?chunk_size=1", nil))
+	if w.Code != http.StatusAccepted || w.Header().Get("Location") == "" {
+		t.Fatalf("Expected 202 with a Location, got %d: %s", w.Code, w.Body.String())
+	}
+	location := w.Header().Get("Location")
+
+	var job evaluationJobView
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != JobSucceeded && time.Now().Before(deadline) {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", location, nil))
+		job = evaluationJobView{}
+		if err := json.NewDecoder(w.Body).Decode(&job); err != nil {
+			t.Fatalf("Failed to decode job: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if job.Status != JobSucceeded || job.Progress.Evaluated != job.Progress.Total || job.Percent != 100 {
+		t.Fatalf("Expected the job to finish every resource, got %+v", job)
+	}
+	if job.Violated == 0 || len(job.Violations) != job.Violated {
+		t.Errorf("Expected the pending pods' violations, got %d of %d", len(job.Violations), job.Violated)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/evaluations/jobs", nil))
+	var list struct {
+		Jobs []evaluationJobView `json:"jobs"`
+	}
+	if json.NewDecoder(w.Body).Decode(&list); len(list.Jobs) != 1 || list.Jobs[0].ID != job.ID {
+		t.Errorf("Expected the job listed, got %+v", list)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/stats", nil))
+	var stats map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&stats)
+	if _, ok := stats["evaluation_jobs"]; !ok {
+		t.Errorf("Expected evaluation_jobs in stats, got %v", stats)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/evaluations/jobs?chunk_size=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for chunk_size=0, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/evaluations/jobs/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown job, got %d", w.Code)
+	}
+}
+
+func TestAPIServer_DebugEndpoints(t *testing.T) {
+	store := state.NewMemoryStore()
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Name: "api", Namespace: "default", Timestamp: time.Now()})
+
+	disabled := NewAPIServer(store, engine.NewInvariantEngine(store)).Handler()
+	for _, path := range []string{"/debug/pprof/", "/api/v1/admin/runtime"} {
+		w := httptest.NewRecorder()
+		disabled.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), string(CodeNotEnabled)) {
+			t.Errorf("Expected %s disabled by default, got %d", path, w.Code)
+		}
+	}
+
+	handler := NewAPIServerWithConfig(store, engine.NewInvariantEngine(store), Config{Debug: true}).Handler()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/goroutine?debug=1", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine profile") {
+		t.Errorf("Expected a goroutine profile, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/admin/runtime", nil))
+	var stats runtimeStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode runtime stats: %v", err)
+	}
+	if stats.Goroutines == 0 || stats.Heap.AllocBytes == 0 {
+		t.Errorf("Expected goroutine and heap figures, got %+v", stats)
+	}
+	if stats.Resources["Pod"] != 1 || stats.TotalResources != 1 {
+		t.Errorf("Expected the recorded pod counted, got %v", stats.Resources)
+	}
+}
+
+func TestAPIServer_LoadShedding(t *testing.T) {
+	store := state.NewMemoryStore()
+	api := NewAPIServer(store, engine.NewInvariantEngine(store))
+	api.SetEvaluationLimits(1, 0)
+	handler := api.Handler()
+
+	// Hold the only slot, as a long evaluation would
+	if !api.load.acquire(context.Background()) {
+		t.Fatal("Expected the first evaluation to get a slot")
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/invariants/evaluate", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" || !strings.Contains(w.Body.String(), string(CodeOverloaded)) {
+		t.Errorf("Expected a 503 with Retry-After while busy, got %d %v", w.Code, w.Header())
+	}
+	for _, path := range []string{"/api/v1/violations", "/api/v1/violations/active", "/api/v1/graph", "/api/v1/health-score", "/api/v1/stats", "/api/v1/causal-path", "/api/v1/causal-chain"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected %s shed while busy, got %d", path, w.Code)
+		}
+	}
+	for _, path := range []string{"/api/v1/evaluate/resource", "/api/v1/sandbox/evaluate"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader("{}")))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected %s shed while busy, got %d", path, w.Code)
+		}
+	}
+
+	// Ingestion isn't limited
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/events", strings.NewReader(`{"uid":"pod-1","kind":"Pod","name":"api","namespace":"default","actor":"kubelet"}`)))
+	if w.Code >= 300 {
+		t.Errorf("Expected events accepted while evaluations are shed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A queued request takes the slot once it is released
+	api.SetEvaluationLimits(1, 1)
+	api.load.acquire(context.Background())
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/invariants/evaluate", nil))
+		done <- w.Code
+	}()
+	for api.load.stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	api.load.release()
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("Expected the queued request served, got %d", code)
+	}
+	if stats := api.load.stats(); stats.InFlight != 0 || stats.Queued != 0 {
+		t.Errorf("Expected the slot released, got %+v", stats)
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/idempotency"
+)
+
+// maxIdempotencyKey bounds the length of Idempotency-Key headers
+const maxIdempotencyKey = 255
+
+// idempotentRequests replays the first response to mutating requests that
+// carry an Idempotency-Key, so automation retries don't create duplicate
+// rules or fire remediations twice
+type idempotentRequests struct {
+	store     idempotency.Store
+	retention time.Duration
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+}
+
+func newIdempotentRequests() *idempotentRequests {
+	return &idempotentRequests{
+		store:     idempotency.NewMemoryStore(),
+		retention: idempotency.DefaultRetention,
+		inFlight:  make(map[string]bool),
+	}
+}
+
+// begin claims key for one request at a time in this process
+func (i *idempotentRequests) begin(key string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.inFlight[key] {
+		return false
+	}
+	i.inFlight[key] = true
+	return true
+}
+
+func (i *idempotentRequests) end(key string) {
+	i.mu.Lock()
+	delete(i.inFlight, key)
+	i.mu.Unlock()
+}
+
+// SetIdempotencyStore replaces the in-process store of keyed responses,
+// so keys are shared across replicas and survive restarts
+func (api *APIServer) SetIdempotencyStore(store idempotency.Store) {
+	api.idempotency.store = store
+}
+
+// capturingWriter records the response it passes through
+type capturingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *capturingWriter) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *capturingWriter) Write(b []byte) (int, error) {
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+func (api *APIServer) idempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotency.Header)
+		if key == "" || !isMutatingMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			writeInvalidParams(w, InvalidParam{Name: idempotency.Header, Reason: "must be at most 255 characters"})
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := idempotency.Fingerprint(r.Method, r.URL.RequestURI(), body)
+
+		// Clients choose keys independently, so keys are scoped to who sent them
+		if p := requestPrincipal(r); p != nil {
+			key = p.name + "/" + key
+		}
+		requests := api.idempotency
+		if !requests.begin(key) {
+			writeProblem(w, http.StatusConflict, CodeConflict, "A request with this Idempotency-Key is in progress")
+			return
+		}
+		defer requests.end(key)
+
+		stored, found, err := requests.store.GetIdempotentResponse(key, requests.retention)
+		if err != nil {
+			storageError(w, err)
+			return
+		}
+		if found {
+			if stored.Fingerprint != fingerprint {
+				writeProblem(w, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused,
+					"Idempotency-Key was already used for a different request")
+				return
+			}
+			if stored.ContentType != "" {
+				w.Header().Set("Content-Type", stored.ContentType)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		capture := &capturingWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(capture, r)
+		// Server errors are not recorded so a retry runs the request again
+		if capture.status >= http.StatusInternalServerError {
+			return
+		}
+		err = requests.store.SaveIdempotentResponse(idempotency.Response{
+			Key:         key,
+			Fingerprint: fingerprint,
+			Status:      capture.status,
+			ContentType: capture.Header().Get("Content-Type"),
+			Body:        capture.body.Bytes(),
+			CreatedAt:   time.Now(),
+		}, requests.retention)
+		if err != nil {
+			log.Printf("Failed to record idempotency key: %v", err)
+		}
+	})
+}
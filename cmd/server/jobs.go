@@ -0,0 +1,284 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+)
+
+const (
+	// maxRunningJobs bounds the evaluation jobs running at once
+	maxRunningJobs = 2
+	// maxRetainedJobs bounds the jobs kept, finished ones dropped oldest
+	// first
+	maxRetainedJobs = 20
+)
+
+// Evaluation job statuses
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobCanceled  = "canceled"
+)
+
+// evaluationJob is a full evaluation running in the background, its
+// violations growing chunk by chunk
+type evaluationJob struct {
+	id     string
+	cancel context.CancelFunc
+	// done is closed once the job has finished
+	done chan struct{}
+
+	mu         sync.Mutex
+	status     string
+	progress   engine.EvaluationProgress
+	violations []*engine.ViolationResult
+	unknown    int
+	errors     int
+	startedAt  time.Time
+	finishedAt time.Time
+}
+
+// evaluationJobView is the JSON form of a job
+type evaluationJobView struct {
+	ID         string                    `json:"id"`
+	Status     string                    `json:"status"`
+	Progress   engine.EvaluationProgress `json:"progress"`
+	Percent    float64                   `json:"percent"`
+	StartedAt  time.Time                 `json:"started_at"`
+	FinishedAt *time.Time                `json:"finished_at,omitempty"`
+	Violated   int                       `json:"total_violations"`
+	Unknown    int                       `json:"total_unknown"`
+	Errors     int                       `json:"evaluation_errors"`
+	Violations []*engine.ViolationResult `json:"violations,omitempty"`
+}
+
+func (j *evaluationJob) observe(results []*engine.ViolationResult, progress engine.EvaluationProgress) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.progress = progress
+	j.violations = append(j.violations, engine.FilterByStatus(results, engine.StatusViolated)...)
+	j.unknown += len(engine.FilterByStatus(results, engine.StatusUnknown))
+	j.errors += len(engine.FilterByStatus(results, engine.StatusEvaluationError))
+}
+
+func (j *evaluationJob) finish(status string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status = status
+	j.finishedAt = time.Now()
+	close(j.done)
+}
+
+func (j *evaluationJob) running() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status == JobRunning
+}
+
+// view snapshots the job; violations are copied so callers may filter
+// them while evaluation continues
+func (j *evaluationJob) view(withViolations bool) evaluationJobView {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	v := evaluationJobView{
+		ID:        j.id,
+		Status:    j.status,
+		Progress:  j.progress,
+		StartedAt: j.startedAt,
+		Violated:  len(j.violations),
+		Unknown:   j.unknown,
+		Errors:    j.errors,
+	}
+	if j.progress.Total > 0 {
+		v.Percent = float64(j.progress.Evaluated*1000/j.progress.Total) / 10
+	} else if j.status != JobRunning {
+		v.Percent = 100
+	}
+	if !j.finishedAt.IsZero() {
+		finished := j.finishedAt
+		v.FinishedAt = &finished
+	}
+	if withViolations {
+		v.Violations = slices.Clone(j.violations)
+	}
+	return v
+}
+
+// evaluationJobs tracks the background evaluations started through the API
+type evaluationJobs struct {
+	mu    sync.Mutex
+	jobs  map[string]*evaluationJob
+	order []string // oldest first
+}
+
+func newEvaluationJobs() *evaluationJobs {
+	return &evaluationJobs{jobs: make(map[string]*evaluationJob)}
+}
+
+// start runs a chunked evaluation in the background. It fails when
+// maxRunningJobs are already running.
+func (js *evaluationJobs) start(eng *engine.InvariantEngine, chunkSize int) (*evaluationJob, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	running := 0
+	for _, j := range js.jobs {
+		if j.running() {
+			running++
+		}
+	}
+	if running >= maxRunningJobs {
+		return nil, fmt.Errorf("%d evaluation jobs are already running", running)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &evaluationJob{id: newJobID(), cancel: cancel, done: make(chan struct{}), status: JobRunning, startedAt: time.Now()}
+	js.jobs[job.id] = job
+	js.order = append(js.order, job.id)
+	js.evict()
+
+	go func() {
+		defer cancel()
+		if err := eng.EvaluateChunked(ctx, chunkSize, job.observe); err != nil {
+			job.finish(JobCanceled)
+			return
+		}
+		job.finish(JobSucceeded)
+	}()
+	return job, nil
+}
+
+// evict drops the oldest finished jobs beyond maxRetainedJobs. Callers
+// hold js.mu.
+func (js *evaluationJobs) evict() {
+	for i := 0; len(js.order) > maxRetainedJobs && i < len(js.order); {
+		id := js.order[i]
+		if js.jobs[id].running() {
+			i++
+			continue
+		}
+		delete(js.jobs, id)
+		js.order = slices.Delete(js.order, i, i+1)
+	}
+}
+
+func (js *evaluationJobs) get(id string) (*evaluationJob, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	job, ok := js.jobs[id]
+	return job, ok
+}
+
+// list returns the jobs, newest first
+func (js *evaluationJobs) list() []*evaluationJob {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	jobs := make([]*evaluationJob, 0, len(js.order))
+	for i := len(js.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, js.jobs[js.order[i]])
+	}
+	return jobs
+}
+
+// stats reports the progress of running jobs for /api/v1/stats
+func (js *evaluationJobs) stats() interface{} {
+	running := make([]evaluationJobView, 0)
+	for _, j := range js.list() {
+		if j.running() {
+			running = append(running, j.view(false))
+		}
+	}
+	return map[string]interface{}{"running": running}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// GET, POST /api/v1/evaluations/jobs
+// POST starts a full evaluation in the background, chunk_size resources
+// at a time; GET lists the jobs, newest first
+func (api *APIServer) handleEvaluationJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		views := make([]evaluationJobView, 0)
+		for _, j := range api.jobs.list() {
+			views = append(views, j.view(false))
+		}
+		api.respondJSON(w, map[string]interface{}{"jobs": views})
+
+	case http.MethodPost:
+		params := newQueryParams(r)
+		chunkSize := params.chunkSize()
+		if !params.valid(w) {
+			return
+		}
+		job, err := api.jobs.start(api.engine, chunkSize)
+		if err != nil {
+			writeProblem(w, http.StatusConflict, CodeConflict, err.Error())
+			return
+		}
+		w.Header().Set("Location", "/api/v1/evaluations/jobs/"+job.id)
+		api.respondJSONStatus(w, http.StatusAccepted, job.view(false))
+
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET, DELETE /api/v1/evaluations/jobs/{id}
+// GET returns the job's progress and the violations found so far;
+// DELETE cancels it, keeping its partial results
+func (api *APIServer) handleEvaluationJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := api.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, "Evaluation job not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		params := newQueryParams(r)
+		order := params.sort()
+		severity := params.severity()
+		limit := params.limit(100)
+		if !params.valid(w) {
+			return
+		}
+		view := job.view(true)
+		violations := api.scopedViolations(r, view.Violations)
+		view.Violated = len(violations)
+		if severity != "" {
+			violations = slices.DeleteFunc(violations, func(v *engine.ViolationResult) bool { return string(v.Severity) != severity })
+		}
+		api.sortViolations(violations, order)
+		if len(violations) > limit {
+			violations = violations[:limit]
+		}
+		view.Violations = violations
+		api.respondJSON(w, view)
+
+	case http.MethodDelete:
+		// The evaluation stops at the end of the chunk in progress
+		job.cancel()
+		select {
+		case <-job.done:
+		case <-r.Context().Done():
+			return
+		}
+		api.respondJSON(w, job.view(false))
+
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
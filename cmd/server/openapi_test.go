@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+type openAPISpec struct {
+	Paths      map[string]map[string]interface{} `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPISpec(t *testing.T) openAPISpec {
+	t.Helper()
+	data, err := os.ReadFile("../../api/openapi.yaml")
+	if err != nil {
+		t.Fatalf("Failed to read spec: %v", err)
+	}
+	var spec openAPISpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	return spec
+}
+
+// The generated clients are only as good as the spec, so every documented
+// route must exist and the schemas must match the Go types
+func TestOpenAPISpec_MatchesServer(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	store := state.NewMemoryStore()
+	handler := NewAPIServer(store, engine.NewInvariantEngine(store)).Handler()
+
+	for path, methods := range spec.Paths {
+		for method := range methods {
+			url := strings.ReplaceAll(path, "{id}", "pod_ready")
+			req := httptest.NewRequest(strings.ToUpper(method), url, strings.NewReader("{}"))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code == http.StatusMethodNotAllowed || strings.Contains(w.Body.String(), "No route for") {
+				t.Errorf("%s %s is documented but not served (%d)", strings.ToUpper(method), path, w.Code)
+			}
+		}
+	}
+
+	for schema, typ := range map[string]reflect.Type{
+		"Violation":     reflect.TypeOf(engine.ViolationResult{}),
+		"SuspectChange": reflect.TypeOf(engine.SuspectChange{}),
+		"StateEvent":    reflect.TypeOf(types.StateEvent{}),
+		"EvaluationJob": reflect.TypeOf(evaluationJobView{}),
+	} {
+		documented := make([]string, 0)
+		for name := range spec.Components.Schemas[schema].Properties {
+			documented = append(documented, name)
+		}
+		sort.Strings(documented)
+		if fields := jsonFields(typ); !reflect.DeepEqual(documented, fields) {
+			t.Errorf("Schema %s documents %v, %s encodes %v", schema, documented, typ.Name(), fields)
+		}
+	}
+}
+
+func jsonFields(typ reflect.Type) []string {
+	fields := make([]string, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -3,23 +3,156 @@ package server
 import (
 	"log"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/aonescu/akari/internal/appdeps"
+	"github.com/aonescu/akari/internal/capacity"
+	"github.com/aonescu/akari/internal/cloud"
 	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/graphql"
+	"github.com/aonescu/akari/internal/paging"
+	"github.com/aonescu/akari/internal/quality"
+	"github.com/aonescu/akari/internal/rbac"
+	"github.com/aonescu/akari/internal/slo"
 	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/tenancy"
+	"github.com/aonescu/akari/internal/timeline"
 )
 
 type APIServer struct {
 	store  state.StateStore
 	engine *engine.InvariantEngine
 	mux    *http.ServeMux
+	config Config
+	// queryRoutes are POST endpoints that only read state and therefore
+	// stay available in read-only mode
+	queryRoutes map[string]bool
+	// statsSources contribute subsystem metrics to /api/v1/stats
+	statsSources map[string]func() interface{}
+	collectors   *cloud.Registry
+	slos         *slo.Tracker
+	capacity     *capacity.Forecaster
+	apps         *appdeps.Graph
+	// deploySecret verifies CI/CD deploy webhooks when set
+	deploySecret string
+	pager        *paging.Pager
+	timeline     *timeline.Recorder
+	coverage     *timeline.CoverageHistory
+	quality      *quality.Tracker
+	streams      *transitionHub
+	idempotency  *idempotentRequests
+	jobs         *evaluationJobs
+	load         *loadShedder
+	// tenants and reviewer authenticate every request when either is set
+	tenants           *tenancy.Registry
+	trustTenantHeader bool
+	reviewer          *rbac.Reviewer
+	graphql           *graphql.Schema
+}
+
+// transitionHub fans monitor transitions out to streaming clients
+type transitionHub struct {
+	mu          sync.Mutex
+	subscribers map[chan engine.Transition]bool
+	// modified is when the last transition was published, to the second
+	// as HTTP dates carry it. served marks it handed out as Last-Modified,
+	// after which a transition in the same second moves it on a second so
+	// the client doesn't miss it.
+	modified time.Time
+	served   bool
+	// changed is closed by the next publish, waking long polls
+	changed chan struct{}
+}
+
+func newTransitionHub() *transitionHub {
+	return &transitionHub{
+		subscribers: make(map[chan engine.Transition]bool),
+		modified:    time.Now().Truncate(time.Second),
+		changed:     make(chan struct{}),
+	}
+}
+
+func (h *transitionHub) subscribe() chan engine.Transition {
+	ch := make(chan engine.Transition, 64)
+	h.mu.Lock()
+	h.subscribers[ch] = true
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *transitionHub) unsubscribe(ch chan engine.Transition) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+// publish never blocks; a client too slow to drain its buffer misses
+// transitions rather than stalling evaluation
+func (h *transitionHub) publish(t engine.Transition) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- t:
+		default:
+		}
+	}
+
+	modified := time.Now().Truncate(time.Second)
+	if !modified.After(h.modified) {
+		modified = h.modified
+		if h.served {
+			modified = modified.Add(time.Second)
+		}
+	}
+	h.modified, h.served = modified, false
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// lastModified returns when the last transition was published, and a
+// channel closed by the next one
+func (h *transitionHub) lastModified() (time.Time, <-chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.served = true
+	return h.modified, h.changed
+}
+
+// Config holds optional API server behaviour
+type Config struct {
+	// ReadOnly rejects every mutating request, for replicas that only
+	// serve dashboards and queries against a shared database
+	ReadOnly bool
+	// Debug serves pprof and runtime statistics, which expose the
+	// server's internals to anyone allowed cluster-wide routes
+	Debug bool
 }
 
 func NewAPIServer(store state.StateStore, eng *engine.InvariantEngine) *APIServer {
+	return NewAPIServerWithConfig(store, eng, Config{})
+}
+
+func NewAPIServerWithConfig(store state.StateStore, eng *engine.InvariantEngine, config Config) *APIServer {
 	api := &APIServer{
-		store:  store,
-		engine: eng,
-		mux:    http.NewServeMux(),
+		store:        store,
+		engine:       eng,
+		mux:          http.NewServeMux(),
+		config:       config,
+		queryRoutes:  make(map[string]bool),
+		statsSources: make(map[string]func() interface{}),
+		collectors:   cloud.DefaultRegistry(),
+		apps:         appdeps.NewGraph(),
+		capacity:     capacity.NewForecaster(store, capacity.Options{}),
+		streams:      newTransitionHub(),
+		idempotency:  newIdempotentRequests(),
+		jobs:         newEvaluationJobs(),
+		load:         newLoadShedder(DefaultEvaluationConcurrency, DefaultEvaluationQueue),
 	}
+	api.statsSources["evaluation_jobs"] = api.jobs.stats
+	api.statsSources["evaluation_load"] = func() interface{} { return api.load.stats() }
+	api.graphql = api.graphqlSchema()
 	api.registerRoutes()
 	return api
 }
@@ -28,34 +161,184 @@ func (api *APIServer) registerRoutes() {
 	// Violations endpoints
 	api.mux.HandleFunc("/api/v1/violations", api.handleViolations)
 	api.mux.HandleFunc("/api/v1/violations/active", api.handleActiveViolations)
+	api.mux.HandleFunc("/api/v1/violations/stream", api.handleViolationStream)
 
 	// Explanation endpoints
-	api.mux.HandleFunc("/api/v1/explain", api.handleExplain)
-	api.mux.HandleFunc("/api/v1/explain/resource", api.handleExplainResource)
+	api.registerQuery("/api/v1/explain", api.limitEvaluation(api.handleExplain))
+	api.mux.HandleFunc("/api/v1/explain/resource", api.limitEvaluation(api.handleExplainResource))
+	api.mux.HandleFunc("/api/v1/explain/invariant", api.handleExplainInvariant)
+	api.mux.HandleFunc("/api/v1/graph", api.limitEvaluation(api.handleGraph))
+	api.registerQuery("/graphql", api.limitEvaluation(api.handleGraphQL))
+	api.mux.HandleFunc("/api/v1/analytics/capacity", api.handleCapacityForecast)
 
 	// Causality graph endpoints
-	api.mux.HandleFunc("/api/v1/causal-chain", api.handleCausalChain)
+	api.mux.HandleFunc("/api/v1/causal-chain", api.limitEvaluation(api.handleCausalChain))
+	api.mux.HandleFunc("/api/v1/causal-path", api.limitEvaluation(api.handleCausalPath))
+
+	// Application dependencies
+	api.mux.HandleFunc("/api/v1/applications", api.handleApplications)
+	api.mux.HandleFunc("/api/v1/applications/{name}/causes", api.limitEvaluation(api.handleApplicationCauses))
 
 	// Resource history
 	api.mux.HandleFunc("/api/v1/history", api.handleHistory)
+	api.mux.HandleFunc("/api/v1/deployments/{namespace}/{name}/images", api.handleDeploymentImages)
+	api.mux.HandleFunc("/api/v1/terminations", api.handleTerminations)
+	api.mux.HandleFunc("/api/v1/compare", api.limitEvaluation(api.handleCompare))
+
+	// External event ingestion
+	api.mux.HandleFunc("/api/v1/events", api.handleEvents)
+	api.mux.HandleFunc("/api/v1/events/bulk", api.handleEventsBulk)
+	api.mux.HandleFunc("/api/v1/cloud/{provider}/events", api.handleCloudEvents)
+	api.mux.HandleFunc("/api/v1/deploys/{source}", api.handleDeploys)
 
 	// Invariants
 	api.mux.HandleFunc("/api/v1/invariants", api.handleInvariants)
-	api.mux.HandleFunc("/api/v1/invariants/evaluate", api.handleEvaluateInvariants)
+	api.mux.HandleFunc("/api/v1/invariants/errors", api.handleInvariantErrors)
+	api.mux.HandleFunc("/api/v1/invariants/flagged", api.handleFlaggedInvariants)
+	api.mux.HandleFunc("/api/v1/invariants/quality", api.handleInvariantQuality)
+	api.mux.HandleFunc("/api/v1/suggestions", api.handleSuggestions)
+	api.mux.HandleFunc("/api/v1/suggestions/{id}/accept", api.handleAcceptSuggestion)
+	api.mux.HandleFunc("/api/v1/invariants/{id}", api.handleInvariant)
+	api.mux.HandleFunc("/api/v1/invariants/{id}/versions", api.handleInvariantVersions)
+	api.mux.HandleFunc("/api/v1/invariants/{id}/review", api.handleReviewInvariant)
+	api.mux.HandleFunc("/api/v1/evaluations/log", api.handleEvaluationLog)
+	api.registerQuery("/api/v1/evaluations/jobs", api.handleEvaluationJobs)
+	api.mux.HandleFunc("/api/v1/evaluations/jobs/{id}", api.handleEvaluationJob)
+	api.registerQuery("/api/v1/invariants/evaluate", api.limitEvaluation(api.handleEvaluateInvariants))
+	api.registerQuery("/api/v1/evaluate/resource", api.limitEvaluation(api.handleEvaluateResource))
+	api.registerQuery("/api/v1/sandbox/evaluate", api.limitEvaluation(api.handleSandboxEvaluate))
+	api.registerQuery("/api/v1/counterfactual", api.limitEvaluation(api.handleCounterfactual))
+
+	// Resource criticality
+	api.mux.HandleFunc("/api/v1/resources", api.handleResources)
+	api.mux.HandleFunc("/api/v1/inventory", api.handleInventory)
+	api.mux.HandleFunc("/api/v1/actors", api.limitEvaluation(api.handleActors))
+	api.mux.HandleFunc("/api/v1/resources/{uid}", api.handleResource)
+	api.mux.HandleFunc("/api/v1/resources/{uid}/tier", api.handleResourceTier)
+	api.mux.HandleFunc("/api/v1/health-score", api.limitEvaluation(api.handleHealthScore))
+
+	// Endpoint coverage of Services
+	api.mux.HandleFunc("/api/v1/services/coverage", api.handleServiceCoverage)
+	api.mux.HandleFunc("/api/v1/services/{namespace}/{name}/coverage", api.handleServiceCoverageHistory)
+
+	// Incidents paged to PagerDuty or Opsgenie
+	api.mux.HandleFunc("/api/v1/incidents", api.handleIncidents)
+	api.mux.HandleFunc("/api/v1/incidents/acknowledge", api.handleAcknowledgeIncident)
+	api.mux.HandleFunc("/api/v1/incidents/{id}/postmortem", api.handleIncidentPostmortem)
+	api.mux.HandleFunc("/api/v1/incidents/{id}/deliveries", api.handleIncidentDeliveries)
+	api.mux.HandleFunc("/api/v1/notifications/dead-letters", api.handleDeadLetters)
+	api.mux.HandleFunc("/api/v1/notifications/replay", api.handleReplayNotifications)
+
+	// Grafana JSON datasource
+	api.mux.HandleFunc("/api/v1/grafana", api.handleGrafanaHealth)
+	api.mux.HandleFunc("/api/v1/grafana/{$}", api.handleGrafanaHealth)
+	api.registerQuery("/api/v1/grafana/metrics", api.handleGrafanaMetrics)
+	api.registerQuery("/api/v1/grafana/search", api.handleGrafanaMetrics)
+	api.registerQuery("/api/v1/grafana/query", api.handleGrafanaQuery)
+	api.registerQuery("/api/v1/grafana/annotations", api.handleGrafanaAnnotations)
+	api.mux.HandleFunc("/api/v1/annotations", api.handleAnnotations)
+
+	// Service-level objectives
+	api.mux.HandleFunc("/api/v1/slos", api.handleSLOs)
+	api.mux.HandleFunc("/api/v1/slos/{id}", api.handleSLO)
 
 	// Health check
 	api.mux.HandleFunc("/health", api.handleHealth)
 	api.mux.HandleFunc("/ready", api.handleReady)
 
+	// Unmatched paths get a problem body like every other error
+	api.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, "No route for "+r.URL.Path, http.StatusNotFound)
+	})
+
 	// Metrics/stats
-	api.mux.HandleFunc("/api/v1/stats", api.handleStats)
+	api.mux.HandleFunc("/api/v1/stats", api.limitEvaluation(api.handleStats))
+	api.mux.HandleFunc("/api/v1/config", api.handleConfig)
+
+	// Administration
+	api.mux.HandleFunc("/api/v1/admin/db", api.handleDatabaseDiagnostics)
+	api.mux.HandleFunc("/api/v1/admin/backup", api.handleBackup)
+	api.mux.HandleFunc("/api/v1/admin/restore", api.handleRestore)
+	api.registerDebugRoutes()
 }
 
-func (api *APIServer) Start(addr string) error {
-	log.Printf("Starting API server on %s", addr)
+// AddStatsSource includes the value returned by source under name in the
+// /api/v1/stats response, e.g. watcher queue depth and drop counters
+func (api *APIServer) AddStatsSource(name string, source func() interface{}) {
+	api.statsSources[name] = source
+}
+
+// RegisterCollector adds or replaces the cloud collector for its provider
+func (api *APIServer) RegisterCollector(c cloud.Collector) {
+	api.collectors.Register(c)
+}
+
+// SetDeployWebhookSecret makes /api/v1/deploys reject deliveries not
+// signed with secret
+func (api *APIServer) SetDeployWebhookSecret(secret string) {
+	api.deploySecret = secret
+}
+
+// SetPager enables the /api/v1/incidents endpoints
+func (api *APIServer) SetPager(pager *paging.Pager) {
+	api.pager = pager
+}
 
+// PublishTransition is a Monitor subscriber feeding
+// /api/v1/violations/stream
+func (api *APIServer) PublishTransition(t engine.Transition) {
+	api.streams.publish(t)
+}
+
+// SetTimeline enables the Grafana datasource endpoints
+func (api *APIServer) SetTimeline(recorder *timeline.Recorder) {
+	api.timeline = recorder
+}
+
+// SetCoverageHistory enables per-Service coverage history
+func (api *APIServer) SetCoverageHistory(history *timeline.CoverageHistory) {
+	api.coverage = history
+}
+
+// SetQualityTracker enables /api/v1/invariants/quality
+func (api *APIServer) SetQualityTracker(tracker *quality.Tracker) {
+	api.quality = tracker
+}
+
+// SetSLOTracker enables the /api/v1/slos endpoints
+func (api *APIServer) SetSLOTracker(tracker *slo.Tracker) {
+	api.slos = tracker
+}
+
+// SetCapacityForecaster sets the defaults of /api/v1/analytics/capacity
+func (api *APIServer) SetCapacityForecaster(forecaster *capacity.Forecaster) {
+	api.capacity = forecaster
+}
+
+// SetApplicationGraph installs declared application dependencies for
+// causal analysis
+func (api *APIServer) SetApplicationGraph(graph *appdeps.Graph) {
+	api.apps = graph
+}
+
+// registerQuery registers a POST endpoint that computes results without
+// changing any state, so read-only mode leaves it enabled.
+func (api *APIServer) registerQuery(pattern string, handler http.HandlerFunc) {
+	api.queryRoutes[pattern] = true
+	api.mux.HandleFunc(pattern, handler)
+}
+
+// Handler returns the routed handler wrapped in the server middleware
+func (api *APIServer) Handler() http.Handler {
 	// Add CORS middleware
-	handler := api.corsMiddleware(api.loggingMiddleware(api.mux))
+	return api.corsMiddleware(api.loggingMiddleware(api.authMiddleware(api.readOnlyMiddleware(api.idempotencyMiddleware(api.mux)))))
+}
+
+func (api *APIServer) Start(addr string) error {
+	log.Printf("Starting API server on %s", addr)
+	if api.config.ReadOnly {
+		log.Println("API server running in read-only mode")
+	}
 
-	return http.ListenAndServe(addr, handler)
+	return http.ListenAndServe(addr, api.Handler())
 }
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultEvaluationConcurrency bounds the evaluation-triggering
+	// requests served at once
+	DefaultEvaluationConcurrency = 4
+	// DefaultEvaluationQueue bounds the requests waiting for one of them
+	DefaultEvaluationQueue = 16
+	// evaluationQueueTimeout is how long a queued request waits before it
+	// is shed
+	evaluationQueueTimeout = 5 * time.Second
+	// shedRetryAfter is the Retry-After of shed requests, in seconds
+	shedRetryAfter = 2
+)
+
+// loadShedder limits concurrent evaluation-triggering requests, queueing a
+// few and rejecting the rest, so dashboards polling expensive endpoints
+// can't starve ingestion of CPU and engine locks
+type loadShedder struct {
+	slots    chan struct{}
+	maxQueue int64
+	timeout  time.Duration
+
+	queued atomic.Int64
+	shed   atomic.Uint64
+}
+
+// loadStats is the evaluation_load entry of /api/v1/stats
+type loadStats struct {
+	Concurrency int    `json:"concurrency"`
+	InFlight    int    `json:"in_flight"`
+	MaxQueue    int64  `json:"max_queue"`
+	Queued      int64  `json:"queued"`
+	Shed        uint64 `json:"shed"`
+}
+
+func newLoadShedder(concurrency, queue int) *loadShedder {
+	return &loadShedder{slots: make(chan struct{}, max(concurrency, 1)), maxQueue: int64(max(queue, 0)), timeout: evaluationQueueTimeout}
+}
+
+// acquire takes a slot, waiting in the queue while there is room in it. It
+// reports false when the request is shed.
+func (l *loadShedder) acquire(ctx context.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.queued.Add(1) > l.maxQueue {
+		l.queued.Add(-1)
+		l.shed.Add(1)
+		return false
+	}
+	defer l.queued.Add(-1)
+
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	l.shed.Add(1)
+	return false
+}
+
+func (l *loadShedder) release() {
+	<-l.slots
+}
+
+func (l *loadShedder) stats() loadStats {
+	return loadStats{
+		Concurrency: cap(l.slots),
+		InFlight:    len(l.slots),
+		MaxQueue:    l.maxQueue,
+		Queued:      l.queued.Load(),
+		Shed:        l.shed.Load(),
+	}
+}
+
+// SetEvaluationLimits bounds the evaluation-triggering requests served at
+// once and those queued for them; requests beyond both get a 503
+func (api *APIServer) SetEvaluationLimits(concurrency, queue int) {
+	api.load = newLoadShedder(concurrency, queue)
+}
+
+// limitEvaluation sheds requests to handler beyond the evaluation limits
+// with a 503 and Retry-After
+func (api *APIServer) limitEvaluation(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		release, ok := api.acquireEvaluation(w, r)
+		if !ok {
+			return
+		}
+		defer release()
+		handler(w, r)
+	}
+}
+
+// acquireEvaluation takes an evaluation slot for handlers that only
+// evaluate on some paths, such as after a long poll or without Postgres.
+// When shed it writes the 503 and reports false.
+func (api *APIServer) acquireEvaluation(w http.ResponseWriter, r *http.Request) (release func(), ok bool) {
+	load := api.load
+	if !load.acquire(r.Context()) {
+		w.Header().Set("Retry-After", strconv.Itoa(shedRetryAfter))
+		writeProblem(w, http.StatusServiceUnavailable, CodeOverloaded, "Too many evaluations in progress, retry later")
+		return nil, false
+	}
+	return load.release, true
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/aonescu/akari/internal/diagram"
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+)
+
+// maxLimit bounds the limit parameter of every list endpoint
+const maxLimit = 1000
+
+var (
+	// kindFormat accepts Kubernetes-style kind names, custom kinds included
+	kindFormat = regexp.MustCompile(`^[A-Z][A-Za-z0-9]{0,62}$`)
+	// uidFormat accepts Kubernetes UIDs and the prefixed IDs of external
+	// producers, such as cloud:aws:<arn>
+	uidFormat = regexp.MustCompile(`^[^\s\x00-\x1f\x7f]{1,512}$`)
+)
+
+// InvalidParam names one rejected input and why it was rejected
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func (p *InvalidParam) Error() string {
+	return p.Name + " " + p.Reason
+}
+
+func invalidParam(name, reason string) error {
+	return &InvalidParam{Name: name, Reason: reason}
+}
+
+func validateKind(name, kind string) error {
+	if !kindFormat.MatchString(kind) {
+		return invalidParam(name, "must be a kind name such as Pod or Deployment")
+	}
+	return nil
+}
+
+func validateUID(name, uid string) error {
+	if !uidFormat.MatchString(uid) {
+		return invalidParam(name, "must be 1 to 512 characters without whitespace")
+	}
+	return nil
+}
+
+func validateSeverity(name string, severity dsl.Severity) error {
+	switch severity {
+	case dsl.Critical, dsl.Degraded, dsl.Warning:
+		return nil
+	}
+	return invalidParam(name, "must be one of critical, degraded, warning")
+}
+
+// queryParams reads and checks query parameters, collecting every invalid
+// one so a single 400 reports them all
+type queryParams struct {
+	values  url.Values
+	invalid []InvalidParam
+}
+
+func newQueryParams(r *http.Request) *queryParams {
+	return &queryParams{values: r.URL.Query()}
+}
+
+func (q *queryParams) check(err error) {
+	var param *InvalidParam
+	if errors.As(err, &param) {
+		q.invalid = append(q.invalid, *param)
+	}
+}
+
+// limit reads the limit parameter, between 1 and maxLimit
+func (q *queryParams) limit(def int) int {
+	v := q.values.Get("limit")
+	if v == "" {
+		return def
+	}
+	l, err := strconv.Atoi(v)
+	if err != nil || l < 1 || l > maxLimit {
+		q.check(invalidParam("limit", fmt.Sprintf("must be an integer between 1 and %d", maxLimit)))
+		return def
+	}
+	return l
+}
+
+// maxChunkSize bounds the chunk_size parameter of evaluation jobs
+const maxChunkSize = 100000
+
+// chunkSize reads the chunk_size parameter of evaluation jobs
+func (q *queryParams) chunkSize() int {
+	v := q.values.Get("chunk_size")
+	if v == "" {
+		return engine.DefaultChunkSize
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxChunkSize {
+		q.check(invalidParam("chunk_size", fmt.Sprintf("must be an integer between 1 and %d", maxChunkSize)))
+		return engine.DefaultChunkSize
+	}
+	return n
+}
+
+// sort reads the sort parameter of violation lists
+func (q *queryParams) sort() engine.SortOrder {
+	order, err := engine.ParseSortOrder(q.values.Get("sort"))
+	if err != nil {
+		q.check(invalidParam("sort", "must be one of severity, detected_at, resource, impact"))
+		return engine.SortSeverity
+	}
+	return order
+}
+
+// groupBy reads the group_by parameter of violation lists
+func (q *queryParams) groupBy() []engine.GroupBy {
+	by, err := engine.ParseGroupBy(q.values.Get("group_by"))
+	if err != nil {
+		q.check(invalidParam("group_by", "must be a comma separated list of resource, invariant, actor, namespace"))
+	}
+	return by
+}
+
+// severity reads the optional severity parameter
+func (q *queryParams) severity() string {
+	v := q.values.Get("severity")
+	if v != "" {
+		q.check(validateSeverity("severity", dsl.Severity(v)))
+	}
+	return v
+}
+
+// kind reads the optional kind parameter
+func (q *queryParams) kind() string {
+	v := q.values.Get("kind")
+	if v != "" {
+		q.check(validateKind("kind", v))
+	}
+	return v
+}
+
+// uid reads the optional uid parameter
+func (q *queryParams) uid() string {
+	v := q.values.Get("uid")
+	if v != "" {
+		q.check(validateUID("uid", v))
+	}
+	return v
+}
+
+// time reads an optional RFC3339 or epoch milliseconds parameter
+func (q *queryParams) time(name string) time.Time {
+	v := q.values.Get(name)
+	if v == "" {
+		return time.Time{}
+	}
+	t, err := parseTimeParam(v)
+	if err != nil {
+		q.check(invalidParam(name, "must be RFC3339 or epoch milliseconds"))
+	}
+	return t
+}
+
+// duration reads an optional positive duration parameter such as 168h
+func (q *queryParams) duration(name string) time.Duration {
+	v := q.values.Get(name)
+	if v == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		q.check(invalidParam(name, "must be a positive duration such as 24h"))
+		return 0
+	}
+	return d
+}
+
+// diagramFormat reads the format parameter of graph endpoints, json or a
+// diagram format
+func (q *queryParams) diagramFormat() string {
+	switch v := q.values.Get("format"); v {
+	case "", "json", diagram.DOT, diagram.Mermaid:
+		return v
+	}
+	q.check(invalidParam("format", "must be one of json, dot, mermaid"))
+	return ""
+}
+
+// consistent reads the consistency parameter, reporting whether reads must
+// bypass the store's cache
+func (q *queryParams) consistent() bool {
+	switch q.values.Get("consistency") {
+	case "", "cached":
+		return false
+	case "strong":
+		return true
+	}
+	q.check(invalidParam("consistency", "must be one of cached, strong"))
+	return false
+}
+
+// valid writes a 400 naming every invalid parameter unless there are none
+func (q *queryParams) valid(w http.ResponseWriter) bool {
+	if len(q.invalid) == 0 {
+		return true
+	}
+	writeInvalidParams(w, q.invalid...)
+	return false
+}
+
+// badRequest writes a 400 for err, naming the invalid field when err is
+// an *InvalidParam
+func badRequest(w http.ResponseWriter, err error) {
+	var param *InvalidParam
+	if errors.As(err, &param) {
+		writeInvalidParams(w, *param)
+		return
+	}
+	writeError(w, err.Error(), http.StatusBadRequest)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/invtest"
+)
+
+// runTest runs the golden-file invariant tests under the given files and
+// directories. It exits 1 when a case fails and 2 when the tests can't be
+// loaded.
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: akari test [flags] <dir|file>...")
+		flags.PrintDefaults()
+	}
+	var invariantPaths stringList
+	flags.Var(&invariantPaths, "invariants", "file or directory declaring invariants under test in every case (repeatable)")
+	builtin := flags.Bool("builtin", false, "put the builtin invariants under test too")
+	if err := flags.Parse(args); err != nil {
+		return scanError
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return scanError
+	}
+
+	var opts invtest.Options
+	opts.Builtin = *builtin
+	if len(invariantPaths) > 0 {
+		files, err := invtest.Load(invariantPaths...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "test:", err)
+			return scanError
+		}
+		opts.Invariants = sharedInvariants(files)
+	}
+	files, err := invtest.Load(flags.Args()...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "test:", err)
+		return scanError
+	}
+
+	results := invtest.Run(files, opts)
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "test: no test cases found")
+		return scanError
+	}
+	invtest.WriteText(os.Stdout, results)
+	if invtest.Failed(results) {
+		return scanViolated
+	}
+	return scanOK
+}
+
+// sharedInvariants collects the invariants the files declare
+func sharedInvariants(files []invtest.File) []dsl.Invariant {
+	var invariants []dsl.Invariant
+	for _, f := range files {
+		invariants = append(invariants, f.Invariants...)
+	}
+	return invariants
+}
@@ -0,0 +1,267 @@
+package appdeps
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"sigs.k8s.io/yaml"
+)
+
+// Application groups the resources of one app, identified by labels
+type Application struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"`
+	Selector  map[string]string `json:"selector"`
+	DependsOn []Dependency      `json:"dependsOn,omitempty"`
+}
+
+// Dependency points at another declared application or at an external
+// resource whose state is ingested through /api/v1/events
+type Dependency struct {
+	Application string       `json:"application,omitempty"`
+	External    *ExternalRef `json:"external,omitempty"`
+}
+
+type ExternalRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+func (r ExternalRef) String() string {
+	if r.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+	}
+	return fmt.Sprintf("%s %s", r.Kind, r.Name)
+}
+
+// Config is the dependency declaration file
+type Config struct {
+	Applications []Application `json:"applications"`
+}
+
+// Graph holds the declared applications and their dependencies
+type Graph struct {
+	mu   sync.RWMutex
+	apps map[string]Application
+}
+
+func NewGraph() *Graph {
+	return &Graph{apps: make(map[string]Application)}
+}
+
+// LoadFile reads a YAML or JSON dependency declaration file
+func LoadFile(path string) (*Graph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid dependency file %s: %w", path, err)
+	}
+	graph := NewGraph()
+	if err := graph.Replace(config.Applications); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// Replace validates and installs a new set of applications
+func (g *Graph) Replace(apps []Application) error {
+	byName := make(map[string]Application, len(apps))
+	for _, app := range apps {
+		if app.Name == "" {
+			return fmt.Errorf("application name is required")
+		}
+		if len(app.Selector) == 0 {
+			return fmt.Errorf("application %s needs a selector", app.Name)
+		}
+		if _, dup := byName[app.Name]; dup {
+			return fmt.Errorf("application %s declared twice", app.Name)
+		}
+		byName[app.Name] = app
+	}
+	for _, app := range apps {
+		for _, dep := range app.DependsOn {
+			switch {
+			case dep.Application != "" && dep.External != nil:
+				return fmt.Errorf("application %s: dependency must name an application or an external resource, not both", app.Name)
+			case dep.Application != "":
+				if _, exists := byName[dep.Application]; !exists {
+					return fmt.Errorf("application %s depends on undeclared application %s", app.Name, dep.Application)
+				}
+			case dep.External != nil:
+				if dep.External.Kind == "" || dep.External.Name == "" {
+					return fmt.Errorf("application %s: external dependency needs kind and name", app.Name)
+				}
+			default:
+				return fmt.Errorf("application %s has an empty dependency", app.Name)
+			}
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.apps = byName
+	return nil
+}
+
+func (g *Graph) Get(name string) (Application, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	app, exists := g.apps[name]
+	return app, exists
+}
+
+func (g *Graph) List() []Application {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	apps := make([]Application, 0, len(g.apps))
+	for _, app := range g.apps {
+		apps = append(apps, app)
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+	return apps
+}
+
+// Owns reports whether a resource belongs to the application
+func (app Application) Owns(resource types.StateEvent) bool {
+	if app.Namespace != "" && resource.Namespace != app.Namespace {
+		return false
+	}
+	for k, v := range app.Selector {
+		if resource.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplicationFor returns the application a resource belongs to
+func (g *Graph) ApplicationFor(resource types.StateEvent) (Application, bool) {
+	for _, app := range g.List() {
+		if app.Owns(resource) {
+			return app, true
+		}
+	}
+	return Application{}, false
+}
+
+// Cause is a failing dependency reached from the analyzed application
+type Cause struct {
+	// Path lists the applications traversed, starting with the analyzed one
+	Path       []string                  `json:"path"`
+	Dependency string                    `json:"dependency"`
+	External   bool                      `json:"external"`
+	Violations []*engine.ViolationResult `json:"violations"`
+	Summary    string                    `json:"summary"`
+}
+
+// Analyze walks the dependencies of app breadth-first and reports every
+// dependency with violations. Nearer dependencies come first; cycles are
+// visited once.
+func (g *Graph) Analyze(appName string, store state.StateStore, results []*engine.ViolationResult) []Cause {
+	violated := engine.FilterByStatus(results, engine.StatusViolated)
+	causes := make([]Cause, 0)
+
+	type step struct {
+		app  string
+		path []string
+	}
+	visited := map[string]bool{appName: true}
+	queue := []step{{app: appName, path: []string{appName}}}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		app, exists := g.Get(current.app)
+		if !exists {
+			continue
+		}
+
+		for _, dep := range app.DependsOn {
+			if dep.External != nil {
+				key := "external:" + dep.External.String()
+				if visited[key] {
+					continue
+				}
+				visited[key] = true
+				if matches := externalViolations(*dep.External, store, violated); len(matches) > 0 {
+					causes = append(causes, newCause(current.path, dep.External.String(), true, matches))
+				}
+				continue
+			}
+
+			if visited[dep.Application] {
+				continue
+			}
+			visited[dep.Application] = true
+			depApp, _ := g.Get(dep.Application)
+			if matches := appViolations(depApp, store, violated); len(matches) > 0 {
+				causes = append(causes, newCause(current.path, depApp.Name, false, matches))
+			}
+			path := append(append([]string{}, current.path...), depApp.Name)
+			queue = append(queue, step{app: depApp.Name, path: path})
+		}
+	}
+	return causes
+}
+
+func appViolations(app Application, store state.StateStore, violated []*engine.ViolationResult) []*engine.ViolationResult {
+	var matches []*engine.ViolationResult
+	for _, v := range violated {
+		resource, exists := store.GetByUID(v.ResourceUID)
+		if exists && app.Owns(resource) {
+			matches = append(matches, v)
+		}
+	}
+	return matches
+}
+
+func externalViolations(ref ExternalRef, store state.StateStore, violated []*engine.ViolationResult) []*engine.ViolationResult {
+	var matches []*engine.ViolationResult
+	for _, v := range violated {
+		resource, exists := store.GetByUID(v.ResourceUID)
+		if exists && resource.Kind == ref.Kind && resource.Name == ref.Name &&
+			(ref.Namespace == "" || resource.Namespace == ref.Namespace) {
+			matches = append(matches, v)
+		}
+	}
+	return matches
+}
+
+func newCause(path []string, dependency string, external bool, violations []*engine.ViolationResult) Cause {
+	invariants := make(map[string]int)
+	for _, v := range violations {
+		invariants[v.InvariantID]++
+	}
+	ids := make([]string, 0, len(invariants))
+	for id := range invariants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	failing := make([]string, 0, len(ids))
+	for _, id := range ids {
+		failing = append(failing, fmt.Sprintf("%s on %d resource(s)", id, invariants[id]))
+	}
+
+	via := ""
+	if len(path) > 1 {
+		via = fmt.Sprintf(" (via %s)", strings.Join(path[1:], " -> "))
+	}
+	return Cause{
+		Path:       path,
+		Dependency: dependency,
+		External:   external,
+		Violations: violations,
+		Summary: fmt.Sprintf("%s is failing because %s%s has violations: %s",
+			path[0], dependency, via, strings.Join(failing, ", ")),
+	}
+}
@@ -0,0 +1,129 @@
+package appdeps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+const testConfig = `
+applications:
+  - name: checkout
+    namespace: shop
+    selector: {app: checkout}
+    dependsOn:
+      - application: inventory
+      - external: {kind: Database, name: orders-db}
+  - name: inventory
+    namespace: shop
+    selector: {app: inventory}
+    dependsOn:
+      - application: warehouse
+  - name: warehouse
+    namespace: shop
+    selector: {app: warehouse}
+    dependsOn:
+      - application: inventory
+`
+
+func loadTestGraph(t *testing.T) *Graph {
+	path := filepath.Join(t.TempDir(), "deps.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	graph, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	return graph
+}
+
+func pod(uid, app string) types.StateEvent {
+	return types.StateEvent{
+		UID:       uid,
+		Kind:      "Pod",
+		Namespace: "shop",
+		Name:      uid,
+		Labels:    map[string]string{"app": app},
+		Version:   "1",
+		Timestamp: time.Now(),
+	}
+}
+
+func violation(uid string) *engine.ViolationResult {
+	return &engine.ViolationResult{InvariantID: "pod_ready", ResourceUID: uid, Status: engine.StatusViolated}
+}
+
+func TestLoadFile_Validation(t *testing.T) {
+	graph := loadTestGraph(t)
+	if len(graph.List()) != 3 {
+		t.Errorf("Expected 3 applications, got %d", len(graph.List()))
+	}
+
+	err := graph.Replace([]Application{{
+		Name:      "checkout",
+		Selector:  map[string]string{"app": "checkout"},
+		DependsOn: []Dependency{{Application: "payments"}},
+	}})
+	if err == nil {
+		t.Error("Expected error for undeclared dependency")
+	}
+}
+
+func TestGraph_Analyze(t *testing.T) {
+	graph := loadTestGraph(t)
+	store := state.NewMemoryStore()
+	store.Record(pod("checkout-1", "checkout"))
+	store.Record(pod("inventory-1", "inventory"))
+	store.Record(pod("inventory-2", "inventory"))
+	store.Record(pod("warehouse-1", "warehouse"))
+	store.Record(types.StateEvent{UID: "db-1", Kind: "Database", Name: "orders-db", Actor: "rds", Version: "1"})
+
+	results := []*engine.ViolationResult{
+		violation("checkout-1"),
+		violation("inventory-1"),
+		violation("inventory-2"),
+		violation("warehouse-1"),
+		violation("db-1"),
+	}
+
+	causes := graph.Analyze("checkout", store, results)
+	if len(causes) != 3 {
+		t.Fatalf("Expected inventory, orders-db and warehouse causes, got %d", len(causes))
+	}
+	if causes[0].Dependency != "inventory" || len(causes[0].Violations) != 2 {
+		t.Errorf("Expected inventory with 2 violations first, got %+v", causes[0])
+	}
+	if !strings.Contains(causes[0].Summary, "checkout is failing because inventory") {
+		t.Errorf("Unexpected summary: %s", causes[0].Summary)
+	}
+	if !causes[1].External {
+		t.Errorf("Expected external orders-db cause, got %+v", causes[1])
+	}
+	// warehouse is reached through inventory; the inventory <-> warehouse
+	// cycle is visited once
+	if causes[2].Dependency != "warehouse" || strings.Join(causes[2].Path, ",") != "checkout,inventory" {
+		t.Errorf("Unexpected transitive cause: %+v", causes[2])
+	}
+}
+
+func TestGraph_ApplicationFor(t *testing.T) {
+	graph := loadTestGraph(t)
+
+	app, ok := graph.ApplicationFor(pod("checkout-1", "checkout"))
+	if !ok || app.Name != "checkout" {
+		t.Errorf("Expected checkout, got %v", app.Name)
+	}
+
+	other := pod("x", "checkout")
+	other.Namespace = "staging"
+	if _, ok := graph.ApplicationFor(other); ok {
+		t.Error("Expected no application outside the declared namespace")
+	}
+}
@@ -1,6 +1,9 @@
 package authority
 
-import "strings"
+import (
+	"sort"
+	"strings"
+)
 
 type ControllerAuthorityMap struct {
 	mappings map[string][]string // field -> []controllers
@@ -53,6 +56,7 @@ func (cam *ControllerAuthorityMap) initializeAuthorities() {
 	cam.addAuthority("status.allocatable", []string{"kubelet"})
 	cam.addAuthority("status.capacity", []string{"kubelet"})
 	cam.addAuthority("status.addresses", []string{"kubelet", "cloud-controller-manager"})
+	cam.addAuthority("version.", []string{"cluster-upgrade"})
 
 	// Volume authorities
 	cam.addAuthority("status.phase", []string{"pv-controller", "pvc-protection-controller"})
@@ -102,6 +106,15 @@ func (cam *ControllerAuthorityMap) initializeAuthorities() {
 		Priority:    1,
 	}
 
+	// Node pool and control plane upgrades, whoever runs them
+	cam.metadata["cluster-upgrade"] = ControllerMetadata{
+		Name:        "cluster-upgrade",
+		Description: "Upgrades the control plane and node pools within the supported version skew",
+		Team:        "infrastructure",
+		Contact:     "infra-team@company.com",
+		Priority:    1,
+	}
+
 	cam.metadata["service-controller"] = ControllerMetadata{
 		Name:        "service-controller",
 		Description: "Manages service endpoints and load balancers",
@@ -163,6 +176,22 @@ func (cam *ControllerAuthorityMap) GetAllControllers() []string {
 	return controllers
 }
 
+// GetOwnedFields returns the fields controller is authorized to change,
+// sorted. Wildcard authorities are left out.
+func (cam *ControllerAuthorityMap) GetOwnedFields(controller string) []string {
+	fields := make([]string, 0)
+	for field, ctrls := range cam.mappings {
+		for _, ctrl := range ctrls {
+			if ctrl == controller {
+				fields = append(fields, field)
+				break
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
 func (cam *ControllerAuthorityMap) GetControllerMetadata(controller string) (ControllerMetadata, bool) {
 	metadata, exists := cam.metadata[controller]
 	return metadata, exists
@@ -134,6 +134,21 @@ func TestControllerAuthorityMap_PrefixMatching(t *testing.T) {
 	}
 }
 
+func TestGetOwnedFields(t *testing.T) {
+	cam := NewControllerAuthorityMap()
+
+	fields := cam.GetOwnedFields("kube-scheduler")
+	if len(fields) != 1 || fields[0] != "spec.nodeName" {
+		t.Errorf("Expected kube-scheduler to own spec.nodeName only, got %v", fields)
+	}
+	if fields := cam.GetOwnedFields("garbage-collector"); contains(fields, "metadata.finalizers") {
+		t.Errorf("Expected wildcard fields to be left out, got %v", fields)
+	}
+	if fields := cam.GetOwnedFields("nobody"); len(fields) != 0 {
+		t.Errorf("Expected no fields for an unknown controller, got %v", fields)
+	}
+}
+
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
 		if s == item {
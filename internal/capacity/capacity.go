@@ -0,0 +1,339 @@
+package capacity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aonescu/akari/internal/scheduling"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+const (
+	// Actor is recorded on synthetic capacity events
+	Actor = "akari-capacity"
+	// Kind of the synthetic resource holding the cluster forecast
+	Kind = "ClusterCapacity"
+	// UID of the cluster forecast resource
+	UID = "capacity:cluster"
+
+	DefaultInterval = 15 * time.Minute
+	// DefaultWindow is how much history the trend is fitted to
+	DefaultWindow = 7 * 24 * time.Hour
+	// DefaultThreshold is the share of allocatable resources requested at
+	// which pods start failing to schedule
+	DefaultThreshold = 0.85
+	// DefaultHorizon is how soon forecast pressure raises a violation
+	DefaultHorizon = 14 * 24 * time.Hour
+
+	// samplePoints is how many snapshots the window is sampled at
+	samplePoints = 24
+	// maxForecastDays bounds projections; slower growth counts as none
+	maxForecastDays = 3650
+)
+
+// Fields recorded on the ClusterCapacity resource
+const (
+	FieldCPUUtilization    = "forecast.cpuUtilization"
+	FieldMemoryUtilization = "forecast.memoryUtilization"
+	// FieldPressureAt is when the first resource is forecast to reach the
+	// threshold, RFC3339, omitted when neither is growing towards it
+	FieldPressureAt = "forecast.pressureAt"
+	// FieldPressureResource is cpu or memory, whichever reaches it first
+	FieldPressureResource = "forecast.pressureResource"
+	// FieldPressureWithinHorizon is "True" when pressure is forecast within
+	// the horizon
+	FieldPressureWithinHorizon = "forecast.pressureWithinHorizon"
+)
+
+// Sample is the share of the schedulable nodes' allocatable resources
+// requested by the pods running on them at one time
+type Sample struct {
+	At                time.Time `json:"at"`
+	CPUUtilization    float64   `json:"cpu_utilization"`
+	MemoryUtilization float64   `json:"memory_utilization"`
+	Nodes             int       `json:"nodes"`
+	Pods              int       `json:"pods"`
+}
+
+// ResourceForecast is the fitted trend of one resource
+type ResourceForecast struct {
+	// Utilization is the latest sample's
+	Utilization float64 `json:"utilization"`
+	// GrowthPerDay is the fitted change in utilization per day
+	GrowthPerDay float64 `json:"growth_per_day"`
+	// PressureAt is when the trend reaches the threshold, nil when it
+	// isn't growing towards it
+	PressureAt *time.Time `json:"pressure_at,omitempty"`
+}
+
+// Forecast projects when the cluster will run short of CPU or memory to
+// schedule pods on
+type Forecast struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Window      string           `json:"window"`
+	Threshold   float64          `json:"threshold"`
+	Horizon     string           `json:"horizon"`
+	CPU         ResourceForecast `json:"cpu"`
+	Memory      ResourceForecast `json:"memory"`
+	// PressureAt is the earlier of the CPU and memory pressure times
+	PressureAt       *time.Time `json:"pressure_at,omitempty"`
+	PressureResource string     `json:"pressure_resource,omitempty"`
+	WithinHorizon    bool       `json:"within_horizon"`
+	Samples          []Sample   `json:"samples"`
+}
+
+// Options tune a forecast; zero values take the defaults
+type Options struct {
+	Window    time.Duration
+	Threshold float64
+	Horizon   time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.Window <= 0 {
+		o.Window = DefaultWindow
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = DefaultThreshold
+	}
+	if o.Horizon <= 0 {
+		o.Horizon = DefaultHorizon
+	}
+	return o
+}
+
+// ErrNoHistory is returned by stores that can't reconstruct past state
+var ErrNoHistory = errors.New("capacity forecasting needs a store that keeps history")
+
+// Measure computes the utilization of one snapshot. Only Ready,
+// schedulable nodes count, and only pods scheduled on them that haven't
+// terminated.
+func Measure(resources []types.StateEvent, at time.Time) Sample {
+	sample := Sample{At: at}
+	nodes := make(map[string]bool)
+	var cpuAllocatable, memoryAllocatable, cpuRequested, memoryRequested float64
+	for _, r := range resources {
+		if r.Kind != "Node" || r.FieldDiff["status.conditions[Ready].status"] != "True" {
+			continue
+		}
+		if unschedulable, _ := r.FieldDiff[scheduling.FieldUnschedulable].(bool); unschedulable {
+			continue
+		}
+		nodes[r.Name] = true
+		sample.Nodes++
+		cpuAllocatable += number(r.FieldDiff[watcher.FieldAllocatableCPUMillis])
+		memoryAllocatable += number(r.FieldDiff[watcher.FieldAllocatableMemoryBytes])
+	}
+	for _, r := range resources {
+		if r.Kind != "Pod" {
+			continue
+		}
+		node, _ := r.FieldDiff[watcher.FieldNodeName].(string)
+		phase, _ := r.FieldDiff[watcher.FieldPhase].(string)
+		if !nodes[node] || phase == "Succeeded" || phase == "Failed" {
+			continue
+		}
+		if _, deleting := r.FieldDiff[watcher.FieldDeletionTimestamp]; deleting {
+			continue
+		}
+		sample.Pods++
+		cpuRequested += number(r.FieldDiff[watcher.FieldCPURequestMillis])
+		memoryRequested += number(r.FieldDiff[watcher.FieldMemoryRequestBytes])
+	}
+	if cpuAllocatable > 0 {
+		sample.CPUUtilization = cpuRequested / cpuAllocatable
+	}
+	if memoryAllocatable > 0 {
+		sample.MemoryUtilization = memoryRequested / memoryAllocatable
+	}
+	return sample
+}
+
+// Project fits a line to samples, oldest first, and forecasts when each
+// resource reaches the threshold
+func Project(samples []Sample, now time.Time, opts Options) *Forecast {
+	opts = opts.withDefaults()
+	forecast := &Forecast{
+		GeneratedAt: now,
+		Window:      opts.Window.String(),
+		Threshold:   opts.Threshold,
+		Horizon:     opts.Horizon.String(),
+		Samples:     samples,
+	}
+	if len(samples) == 0 {
+		return forecast
+	}
+	forecast.CPU = project(samples, now, opts.Threshold, func(s Sample) float64 { return s.CPUUtilization })
+	forecast.Memory = project(samples, now, opts.Threshold, func(s Sample) float64 { return s.MemoryUtilization })
+
+	if forecast.CPU.PressureAt != nil {
+		forecast.PressureAt, forecast.PressureResource = forecast.CPU.PressureAt, "cpu"
+	}
+	if at := forecast.Memory.PressureAt; at != nil && (forecast.PressureAt == nil || at.Before(*forecast.PressureAt)) {
+		forecast.PressureAt, forecast.PressureResource = at, "memory"
+	}
+	forecast.WithinHorizon = forecast.PressureAt != nil && !forecast.PressureAt.After(now.Add(opts.Horizon))
+	return forecast
+}
+
+// project fits utilization = a + b*t by least squares, t in days
+func project(samples []Sample, now time.Time, threshold float64, value func(Sample) float64) ResourceForecast {
+	latest := samples[len(samples)-1]
+	f := ResourceForecast{Utilization: value(latest)}
+	if f.Utilization >= threshold {
+		f.PressureAt = &now
+		return f
+	}
+	if len(samples) < 2 {
+		return f
+	}
+
+	origin := samples[0].At
+	var n, sumT, sumV, sumTT, sumTV float64
+	for _, s := range samples {
+		t := s.At.Sub(origin).Hours() / 24
+		v := value(s)
+		n++
+		sumT += t
+		sumV += v
+		sumTT += t * t
+		sumTV += t * v
+	}
+	denominator := n*sumTT - sumT*sumT
+	if denominator == 0 {
+		return f
+	}
+	slope := (n*sumTV - sumT*sumV) / denominator
+	intercept := (sumV - slope*sumT) / n
+	f.GrowthPerDay = slope
+	if slope <= 0 {
+		return f
+	}
+
+	days := (threshold - intercept) / slope
+	if days > maxForecastDays {
+		return f
+	}
+	at := origin.Add(time.Duration(days * 24 * float64(time.Hour)))
+	if at.Before(now) {
+		at = now
+	}
+	f.PressureAt = &at
+	return f
+}
+
+// Forecaster samples the store's history and projects the cluster's
+// capacity
+type Forecaster struct {
+	store state.StateStore
+	opts  Options
+}
+
+func NewForecaster(store state.StateStore, opts Options) *Forecaster {
+	return &Forecaster{store: store, opts: opts.withDefaults()}
+}
+
+// Forecast samples the window ending at now; opts override the
+// forecaster's own where set
+func (f *Forecaster) Forecast(now time.Time, opts Options) (*Forecast, error) {
+	if opts.Window <= 0 {
+		opts.Window = f.opts.Window
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = f.opts.Threshold
+	}
+	if opts.Horizon <= 0 {
+		opts.Horizon = f.opts.Horizon
+	}
+	reader, ok := f.store.(state.SnapshotReader)
+	if !ok {
+		return nil, ErrNoHistory
+	}
+
+	samples := make([]Sample, 0, samplePoints)
+	step := opts.Window / (samplePoints - 1)
+	for i := 0; i < samplePoints; i++ {
+		at := now.Add(-opts.Window + time.Duration(i)*step)
+		snapshot, err := reader.SnapshotAt(at)
+		if err != nil {
+			return nil, err
+		}
+		// Skip the time before any node was recorded, e.g. before akari
+		// started watching
+		if sample := Measure(snapshot, at); sample.Nodes > 0 {
+			samples = append(samples, sample)
+		}
+	}
+	return Project(samples, now, opts), nil
+}
+
+// Event is the ClusterCapacity resource recording a forecast for the
+// cluster_capacity_headroom invariant
+func (f *Forecast) Event() types.StateEvent {
+	event := types.StateEvent{
+		UID:       UID,
+		Kind:      Kind,
+		Name:      "cluster",
+		Version:   strconv.FormatInt(f.GeneratedAt.UnixNano(), 10),
+		Timestamp: f.GeneratedAt,
+		Actor:     Actor,
+		FieldDiff: map[string]interface{}{
+			FieldCPUUtilization:        f.CPU.Utilization,
+			FieldMemoryUtilization:     f.Memory.Utilization,
+			FieldPressureWithinHorizon: "False",
+		},
+	}
+	if f.PressureAt != nil {
+		event.FieldDiff[FieldPressureAt] = f.PressureAt.UTC().Format(time.RFC3339)
+		event.FieldDiff[FieldPressureResource] = f.PressureResource
+	}
+	if f.WithinHorizon {
+		event.FieldDiff[FieldPressureWithinHorizon] = "True"
+	}
+	return event
+}
+
+// Run records a forecast on every interval until ctx is cancelled
+func (f *Forecaster) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		forecast, err := f.Forecast(time.Now(), Options{})
+		if err == nil && len(forecast.Samples) > 0 {
+			err = f.store.Record(forecast.Event())
+		}
+		if err != nil {
+			log.Printf("Capacity forecast failed: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func number(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	}
+	return 0
+}
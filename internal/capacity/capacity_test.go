@@ -0,0 +1,148 @@
+package capacity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/scheduling"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+func node(name string, cpuMillis, memoryBytes int64, fields map[string]interface{}) types.StateEvent {
+	diff := map[string]interface{}{
+		"status.conditions[Ready].status":   "True",
+		watcher.FieldAllocatableCPUMillis:   cpuMillis,
+		watcher.FieldAllocatableMemoryBytes: memoryBytes,
+	}
+	for k, v := range fields {
+		diff[k] = v
+	}
+	return types.StateEvent{UID: "node-" + name, Kind: "Node", Name: name, FieldDiff: diff}
+}
+
+func pod(uid, nodeName string, cpuMillis, memoryBytes int64, at time.Time) types.StateEvent {
+	return types.StateEvent{
+		UID: uid, Kind: "Pod", Namespace: "prod", Name: uid, Version: at.String(), Timestamp: at,
+		FieldDiff: map[string]interface{}{
+			watcher.FieldNodeName:           nodeName,
+			watcher.FieldPhase:              "Running",
+			watcher.FieldCPURequestMillis:   cpuMillis,
+			watcher.FieldMemoryRequestBytes: memoryBytes,
+		},
+	}
+}
+
+func TestMeasure_CountsSchedulableNodesOnly(t *testing.T) {
+	now := time.Now()
+	resources := []types.StateEvent{
+		node("a", 2000, 4<<30, nil),
+		node("b", 2000, 4<<30, nil),
+		node("cordoned", 2000, 4<<30, map[string]interface{}{scheduling.FieldUnschedulable: true}),
+		node("notready", 2000, 4<<30, map[string]interface{}{"status.conditions[Ready].status": "False"}),
+		pod("p1", "a", 1000, 1<<30, now),
+		pod("p2", "b", 1000, 1<<30, now),
+		pod("p3", "cordoned", 1000, 1<<30, now),
+	}
+	done := pod("p4", "a", 1000, 1<<30, now)
+	done.FieldDiff[watcher.FieldPhase] = "Succeeded"
+	resources = append(resources, done)
+
+	sample := Measure(resources, now)
+	if sample.Nodes != 2 || sample.Pods != 2 {
+		t.Fatalf("Expected 2 nodes and 2 pods, got %+v", sample)
+	}
+	if sample.CPUUtilization != 0.5 || sample.MemoryUtilization != 0.25 {
+		t.Errorf("Expected 50%% CPU and 25%% memory, got %+v", sample)
+	}
+}
+
+func TestProject_ForecastsPressure(t *testing.T) {
+	now := time.Now()
+	var samples []Sample
+	for day := 0; day <= 6; day++ {
+		samples = append(samples, Sample{
+			At:                now.Add(time.Duration(day-6) * 24 * time.Hour),
+			CPUUtilization:    0.5 + 0.05*float64(day),
+			MemoryUtilization: 0.3,
+		})
+	}
+
+	forecast := Project(samples, now, Options{Threshold: 0.9, Horizon: 7 * 24 * time.Hour})
+	if forecast.PressureResource != "cpu" || forecast.PressureAt == nil {
+		t.Fatalf("Expected CPU pressure, got %+v", forecast)
+	}
+	// 0.8 today growing 0.05 a day reaches 0.9 in two days
+	if want := now.Add(48 * time.Hour); forecast.PressureAt.Sub(want).Abs() > time.Minute {
+		t.Errorf("Expected pressure at %v, got %v", want, forecast.PressureAt)
+	}
+	if !forecast.WithinHorizon || forecast.Memory.PressureAt != nil {
+		t.Errorf("Expected CPU pressure within the horizon only, got %+v", forecast)
+	}
+	if forecast.Event().FieldDiff[FieldPressureWithinHorizon] != "True" {
+		t.Errorf("Expected the event to record pressure within the horizon")
+	}
+
+	beyond := Project(samples, now, Options{Threshold: 0.9, Horizon: 24 * time.Hour})
+	if beyond.WithinHorizon || beyond.Event().FieldDiff[FieldPressureWithinHorizon] != "False" {
+		t.Errorf("Expected pressure beyond a one day horizon, got %+v", beyond)
+	}
+}
+
+func TestProject_FlatUsageNeverReachesThreshold(t *testing.T) {
+	now := time.Now()
+	samples := []Sample{
+		{At: now.Add(-48 * time.Hour), CPUUtilization: 0.6, MemoryUtilization: 0.5},
+		{At: now.Add(-24 * time.Hour), CPUUtilization: 0.6, MemoryUtilization: 0.4},
+		{At: now, CPUUtilization: 0.6, MemoryUtilization: 0.3},
+	}
+	forecast := Project(samples, now, Options{})
+	if forecast.PressureAt != nil || forecast.WithinHorizon {
+		t.Errorf("Expected no pressure, got %+v", forecast)
+	}
+	if _, ok := forecast.Event().FieldDiff[FieldPressureAt]; ok {
+		t.Errorf("Expected no pressure time on the event")
+	}
+}
+
+func TestForecaster_SamplesHistory(t *testing.T) {
+	store := state.NewMemoryStore()
+	now := time.Now()
+	start := now.Add(-4 * 24 * time.Hour)
+
+	n := node("a", 10000, 10<<30, nil)
+	n.Version, n.Timestamp = "1", start
+	store.Record(n)
+	// One more 1 CPU pod every day
+	for day := 0; day < 4; day++ {
+		at := start.Add(time.Duration(day)*24*time.Hour + time.Hour)
+		store.Record(pod("p"+string(rune('a'+day)), "a", 1000, 1<<30, at))
+	}
+
+	forecast, err := NewForecaster(store, Options{Window: 5 * 24 * time.Hour}).Forecast(now, Options{Threshold: 0.6})
+	if err != nil {
+		t.Fatalf("Forecast failed: %v", err)
+	}
+	if len(forecast.Samples) == 0 || forecast.Samples[0].Nodes != 1 {
+		t.Fatalf("Expected samples from when the node was recorded, got %+v", forecast.Samples)
+	}
+	if forecast.CPU.Utilization != 0.4 || forecast.CPU.GrowthPerDay <= 0 {
+		t.Errorf("Expected growing CPU utilization at 40%%, got %+v", forecast.CPU)
+	}
+	if forecast.PressureResource != "cpu" || !forecast.WithinHorizon {
+		t.Errorf("Expected CPU pressure within the default horizon, got %+v", forecast)
+	}
+}
+
+func TestForecaster_NeedsHistory(t *testing.T) {
+	forecaster := NewForecaster(historylessStore{state.NewMemoryStore()}, Options{})
+	if _, err := forecaster.Forecast(time.Now(), Options{}); err != ErrNoHistory {
+		t.Errorf("Expected ErrNoHistory, got %v", err)
+	}
+}
+
+// historylessStore hides the memory store's SnapshotReader
+type historylessStore struct {
+	state.StateStore
+}
@@ -0,0 +1,128 @@
+package causality
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+)
+
+const (
+	// Weights of the score components, summing to 1
+	ProximityWeight    = 0.4
+	AuthorityWeight    = 0.4
+	CoOccurrenceWeight = 0.2
+
+	// DefaultHalfLife is how long before a violation a change counts half
+	// as close as one made the moment before
+	DefaultHalfLife = 5 * time.Minute
+	// MaxHistory bounds the past violations co-occurrence is learnt from
+	MaxHistory = 20
+)
+
+// Cause is a candidate cause of a violation with its score, from 0 to 1,
+// and the components it weighs
+type Cause struct {
+	engine.SuspectChange
+	Score float64 `json:"score"`
+	// Proximity decays with the time between the change and the violation
+	Proximity float64 `json:"proximity"`
+	// Authority is half for touching the violated field and half for being
+	// made by an actor with authority over it
+	Authority float64 `json:"authority"`
+	// CoOccurrence is the share of the invariant's past violations preceded
+	// by a change of the same actor to the same kind
+	CoOccurrence float64 `json:"co_occurrence"`
+}
+
+// Scorer ranks the candidate causes of violations
+type Scorer struct {
+	HalfLife time.Duration
+	// HasAuthority reports whether actor may set field, e.g. through
+	// authority.ControllerAuthorityMap.ValidateAuthority
+	HasAuthority func(actor, field string) bool
+}
+
+// Rank scores candidates, the changes recorded before a violation detected
+// at detectedAt of an invariant checking field, highest score first.
+// history holds the changes that preceded the invariant's past violations.
+func (s Scorer) Rank(detectedAt time.Time, field string, candidates []engine.SuspectChange, history [][]engine.SuspectChange) []Cause {
+	halfLife := s.HalfLife
+	if halfLife <= 0 {
+		halfLife = DefaultHalfLife
+	}
+
+	seen := make(map[string]int)
+	for _, changes := range history {
+		keys := make(map[string]bool)
+		for _, c := range changes {
+			keys[key(c)] = true
+		}
+		for k := range keys {
+			seen[k]++
+		}
+	}
+
+	causes := make([]Cause, 0, len(candidates))
+	for _, c := range candidates {
+		cause := Cause{SuspectChange: c}
+		if age := detectedAt.Sub(c.ChangedAt); age >= 0 {
+			cause.Proximity = math.Pow(0.5, float64(age)/float64(halfLife))
+		}
+		if field != "" {
+			if touches(c, field) {
+				cause.Authority += 0.5
+			}
+			if s.authorized(c.Actor, field) {
+				cause.Authority += 0.5
+			}
+		}
+		if len(history) > 0 {
+			cause.CoOccurrence = float64(seen[key(c)]) / float64(len(history))
+		}
+		cause.Score = round(ProximityWeight*cause.Proximity + AuthorityWeight*cause.Authority + CoOccurrenceWeight*cause.CoOccurrence)
+		cause.Proximity = round(cause.Proximity)
+		cause.CoOccurrence = round(cause.CoOccurrence)
+		causes = append(causes, cause)
+	}
+
+	sort.SliceStable(causes, func(i, j int) bool {
+		if causes[i].Score != causes[j].Score {
+			return causes[i].Score > causes[j].Score
+		}
+		return causes[i].ChangedAt.After(causes[j].ChangedAt)
+	})
+	return causes
+}
+
+// authorized checks the actor as recorded and without its instance, e.g.
+// kubelet for kubelet/node-1
+func (s Scorer) authorized(actor, field string) bool {
+	if s.HasAuthority == nil || actor == "" {
+		return false
+	}
+	base, _, _ := strings.Cut(actor, "/")
+	return s.HasAuthority(actor, field) || s.HasAuthority(base, field)
+}
+
+func touches(c engine.SuspectChange, field string) bool {
+	for changed := range c.Fields {
+		if strings.HasPrefix(changed, field) || strings.HasPrefix(field, changed) {
+			return true
+		}
+	}
+	return false
+}
+
+// key groups changes for co-occurrence by actor, without its instance,
+// and kind
+func key(c engine.SuspectChange) string {
+	actor, _, _ := strings.Cut(c.Actor, "/")
+	return actor + "|" + c.Kind
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
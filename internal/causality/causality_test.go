@@ -0,0 +1,59 @@
+package causality
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+)
+
+func TestRank(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	candidates := []engine.SuspectChange{
+		// Recent, but an unrelated field by an actor without authority
+		{ResourceUID: "cm-1", Kind: "ConfigMap", Actor: "kubectl", ChangedAt: at.Add(-time.Minute), Fields: map[string]interface{}{"data.mode": "fast"}},
+		// Older, touching the violated field with authority
+		{ResourceUID: "pod-1", Kind: "Pod", Actor: "kubelet/node-1", ChangedAt: at.Add(-10 * time.Minute), Fields: map[string]interface{}{"status.phase": "Failed"}},
+		// Made after the violation
+		{ResourceUID: "pod-1", Kind: "Pod", Actor: "kubectl", ChangedAt: at.Add(time.Minute), Fields: map[string]interface{}{"metadata.labels.app": "api"}},
+	}
+	history := [][]engine.SuspectChange{
+		{{Kind: "Pod", Actor: "kubelet/node-2"}},
+		{{Kind: "Pod", Actor: "kubelet/node-1"}, {Kind: "Pod", Actor: "kubelet/node-3"}},
+		{{Kind: "ConfigMap", Actor: "kubectl"}},
+		{},
+	}
+	scorer := Scorer{HasAuthority: func(actor, field string) bool { return actor == "kubelet" && field == "status.phase" }}
+
+	causes := scorer.Rank(at, "status.phase", candidates, history)
+	if len(causes) != 3 {
+		t.Fatalf("Expected 3 causes, got %d", len(causes))
+	}
+	top := causes[0]
+	if top.ResourceUID != "pod-1" || top.Authority != 1 || top.Proximity != 0.25 || top.CoOccurrence != 0.5 {
+		t.Errorf("Expected the kubelet phase change first, got %+v", top)
+	}
+	if top.Score != 0.6 {
+		t.Errorf("Expected score 0.6, got %v", top.Score)
+	}
+	if c := causes[1]; c.ResourceUID != "cm-1" || c.Authority != 0 || c.CoOccurrence != 0.25 {
+		t.Errorf("Expected the config change second, got %+v", c)
+	}
+	if last := causes[2]; last.Proximity != 0 || last.Score != 0 {
+		t.Errorf("Expected the later change to score nothing, got %+v", last)
+	}
+}
+
+func TestRank_WithoutHistoryOrAuthority(t *testing.T) {
+	at := time.Now()
+	causes := Scorer{HalfLife: time.Minute}.Rank(at, "", []engine.SuspectChange{
+		{Actor: "a", ChangedAt: at.Add(-2 * time.Minute)},
+		{Actor: "b", ChangedAt: at},
+	}, nil)
+	if causes[0].Actor != "b" || causes[0].Score != ProximityWeight {
+		t.Errorf("Expected the immediate change first, got %+v", causes[0])
+	}
+	if causes[1].Proximity != 0.25 {
+		t.Errorf("Expected proximity to halve per half-life, got %+v", causes[1])
+	}
+}
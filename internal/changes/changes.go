@@ -0,0 +1,138 @@
+package changes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// Kind of the synthetic resources describing CI/CD deploys. Each
+// repository and environment pair is one resource; every deploy to it is
+// a new version.
+const Kind = "ChangeEvent"
+
+// Fields set on ChangeEvents
+const (
+	FieldSource      = "change.source"
+	FieldRepository  = "change.repository"
+	FieldCommit      = "change.commit"
+	FieldRef         = "change.ref"
+	FieldImage       = "change.image"
+	FieldEnvironment = "change.environment"
+	FieldURL         = "change.url"
+)
+
+const (
+	templateImagePrefix = "spec.template.spec.containers["
+	podImagePrefix      = "spec.containers["
+	imageSuffix         = "].image"
+)
+
+// Deploy is a deploy notification from a CI/CD system
+type Deploy struct {
+	Source      string    `json:"source"`
+	Repository  string    `json:"repository"`
+	Commit      string    `json:"commit"`
+	Ref         string    `json:"ref,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	Environment string    `json:"environment,omitempty"`
+	Namespace   string    `json:"namespace,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	Actor       string    `json:"actor,omitempty"`
+	At          time.Time `json:"timestamp,omitempty"`
+}
+
+// Event converts the deploy into a ChangeEvent. The actor is whoever
+// triggered the deploy, falling back to the CI system itself.
+func (d Deploy) Event() types.StateEvent {
+	at := d.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	actor := d.Actor
+	if actor == "" {
+		actor = d.Source
+	}
+
+	fields := map[string]interface{}{
+		FieldSource:     d.Source,
+		FieldRepository: d.Repository,
+		FieldCommit:     d.Commit,
+	}
+	for field, value := range map[string]string{
+		FieldRef:         d.Ref,
+		FieldImage:       d.Image,
+		FieldEnvironment: d.Environment,
+		FieldURL:         d.URL,
+	} {
+		if value != "" {
+			fields[field] = value
+		}
+	}
+
+	return types.StateEvent{
+		UID:       fmt.Sprintf("change:%s:%s:%s", d.Source, d.Repository, d.Environment),
+		Kind:      Kind,
+		Namespace: d.Namespace,
+		Name:      d.Repository,
+		Version:   strconv.FormatInt(at.UnixNano(), 10),
+		Timestamp: at,
+		FieldDiff: fields,
+		Actor:     actor,
+	}
+}
+
+// ImagesOf returns the container images recorded on a Pod or on the pod
+// template of a workload
+func ImagesOf(event types.StateEvent) []string {
+	seen := make(map[string]bool)
+	for field, value := range event.FieldDiff {
+		image, ok := value.(string)
+		if !ok || image == "" || !strings.HasSuffix(field, imageSuffix) {
+			continue
+		}
+		if strings.HasPrefix(field, podImagePrefix) || strings.HasPrefix(field, templateImagePrefix) {
+			seen[image] = true
+		}
+	}
+	images := make([]string, 0, len(seen))
+	for image := range seen {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	return images
+}
+
+// ForResource returns the latest ChangeEvents that shipped an image the
+// resource runs, newest first
+func ForResource(store state.StateStore, resource types.StateEvent) []types.StateEvent {
+	images := ImagesOf(resource)
+	if len(images) == 0 {
+		return nil
+	}
+	wanted := make(map[string]bool, len(images))
+	for _, image := range images {
+		wanted[image] = true
+	}
+
+	var matches []types.StateEvent
+	for _, event := range store.GetLatestByKind(Kind) {
+		image, _ := event.FieldDiff[FieldImage].(string)
+		if !wanted[image] {
+			continue
+		}
+		if event.Namespace != "" && resource.Namespace != "" && event.Namespace != resource.Namespace {
+			continue
+		}
+		matches = append(matches, event)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Timestamp.After(matches[j].Timestamp)
+	})
+	return matches
+}
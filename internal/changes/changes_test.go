@@ -0,0 +1,127 @@
+package changes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestDecode_GitHubDeploymentStatus(t *testing.T) {
+	body := []byte(`{
+		"deployment_status": {"state": "success", "target_url": "https://github.com/acme/api/actions/runs/1", "created_at": "2025-01-01T10:00:00Z"},
+		"deployment": {
+			"sha": "9f2c1e0", "ref": "main", "environment": "production",
+			"payload": "{\"image\": \"ghcr.io/acme/api:1.4.0\", \"namespace\": \"prod\"}",
+			"creator": {"login": "alice"}
+		},
+		"repository": {"full_name": "acme/api"}
+	}`)
+
+	deploys, err := Decode(SourceGitHub, body)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(deploys) != 1 {
+		t.Fatalf("Expected one deploy, got %d", len(deploys))
+	}
+	d := deploys[0]
+	if d.Repository != "acme/api" || d.Commit != "9f2c1e0" || d.Image != "ghcr.io/acme/api:1.4.0" || d.Namespace != "prod" || d.Actor != "alice" {
+		t.Errorf("Unexpected deploy %+v", d)
+	}
+
+	event := d.Event()
+	if event.Kind != Kind || event.UID != "change:github:acme/api:production" || event.Actor != "alice" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if !event.Timestamp.Equal(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the status time, got %v", event.Timestamp)
+	}
+
+	pending := []byte(`{"deployment_status": {"state": "pending"}, "deployment": {"sha": "9f2c1e0"}, "repository": {"full_name": "acme/api"}}`)
+	if deploys, err := Decode(SourceGitHub, pending); err != nil || len(deploys) != 0 {
+		t.Errorf("Expected pending deployments to be ignored, got %v, %v", deploys, err)
+	}
+}
+
+func TestDecode_GitLabDeploymentHook(t *testing.T) {
+	body := []byte(`{
+		"object_kind": "deployment", "status": "success", "status_changed_at": "2025-01-01 11:00:00 +0100",
+		"environment": "staging", "short_sha": "279484c0", "ref": "main",
+		"commit_url": "https://gitlab.com/acme/web/-/commit/279484c09fbe69ededfced8c1bb6e6d24616b468",
+		"project": {"path_with_namespace": "acme/web"}, "user": {"username": "bob"}
+	}`)
+
+	deploys, err := Decode(SourceGitLab, body)
+	if err != nil || len(deploys) != 1 {
+		t.Fatalf("Expected one deploy, got %v, %v", deploys, err)
+	}
+	d := deploys[0]
+	if d.Commit != "279484c09fbe69ededfced8c1bb6e6d24616b468" || d.Repository != "acme/web" || d.Actor != "bob" {
+		t.Errorf("Unexpected deploy %+v", d)
+	}
+	if !d.At.Equal(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the status change time, got %v", d.At)
+	}
+}
+
+func TestDecode_CI(t *testing.T) {
+	if _, err := Decode(SourceCI, []byte(`{"repository": "acme/api"}`)); err == nil {
+		t.Error("Expected a deploy without commit to be rejected")
+	}
+	if _, err := Decode("jenkins", []byte(`{}`)); err != ErrUnknownSource {
+		t.Errorf("Expected ErrUnknownSource, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"zen": "ping"}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+
+	header := http.Header{}
+	header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	if !Verify(SourceGitHub, header, body, "s3cret") {
+		t.Error("Expected a valid GitHub signature to verify")
+	}
+	if Verify(SourceGitHub, header, body, "other") {
+		t.Error("Expected a signature with another secret to fail")
+	}
+
+	header = http.Header{}
+	header.Set("X-Gitlab-Token", "s3cret")
+	if !Verify(SourceGitLab, header, body, "s3cret") {
+		t.Error("Expected a matching GitLab token to verify")
+	}
+
+	header = http.Header{}
+	header.Set("Authorization", "Bearer s3cret")
+	if !Verify(SourceCI, header, body, "s3cret") || Verify(SourceCI, http.Header{}, body, "s3cret") {
+		t.Error("Expected only the bearer token to verify CI deploys")
+	}
+}
+
+func TestForResource(t *testing.T) {
+	store := state.NewMemoryStore()
+	store.Record(Deploy{Source: SourceCI, Repository: "acme/api", Commit: "abc", Image: "acme/api:2", Namespace: "prod"}.Event())
+	store.Record(Deploy{Source: SourceCI, Repository: "acme/web", Commit: "def", Image: "acme/web:7"}.Event())
+
+	pod := types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api-1",
+		FieldDiff: map[string]interface{}{"spec.containers[api].image": "acme/api:2"},
+	}
+	deploys := ForResource(store, pod)
+	if len(deploys) != 1 || deploys[0].FieldDiff[FieldCommit] != "abc" {
+		t.Errorf("Expected the acme/api deploy, got %+v", deploys)
+	}
+
+	pod.Namespace = "staging"
+	if deploys := ForResource(store, pod); len(deploys) != 0 {
+		t.Errorf("Expected deploys to another namespace not to match, got %+v", deploys)
+	}
+}
@@ -0,0 +1,203 @@
+package changes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sources of deploy notifications
+const (
+	SourceGitHub = "github"
+	SourceGitLab = "gitlab"
+	// SourceCI accepts the Deploy JSON shape directly, for pipelines that
+	// post a notification as their last step
+	SourceCI = "ci"
+)
+
+// ErrUnknownSource is returned for sources without a decoder
+var ErrUnknownSource = errors.New("unknown deploy source")
+
+// gitlabTimeLayout is the format of GitLab's status_changed_at
+const gitlabTimeLayout = "2006-01-02 15:04:05 -0700"
+
+// Sources lists the supported deploy sources
+func Sources() []string {
+	return []string{SourceCI, SourceGitHub, SourceGitLab}
+}
+
+// Decode parses a webhook delivery. Deliveries that don't describe a
+// successful deploy, such as GitHub pings or failed GitLab deployments,
+// yield no deploys and no error.
+func Decode(source string, body []byte) ([]Deploy, error) {
+	switch source {
+	case SourceGitHub:
+		return decodeGitHub(body)
+	case SourceGitLab:
+		return decodeGitLab(body)
+	case SourceCI:
+		return decodeCI(body)
+	}
+	return nil, ErrUnknownSource
+}
+
+// Verify checks a delivery against the shared secret the way each source
+// signs it: GitHub's X-Hub-Signature-256 HMAC, GitLab's X-Gitlab-Token and
+// a bearer token for plain CI notifications
+func Verify(source string, header http.Header, body []byte, secret string) bool {
+	switch source {
+	case SourceGitHub:
+		signature, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+		if !ok {
+			return false
+		}
+		got, err := hex.DecodeString(signature)
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(got, mac.Sum(nil))
+	case SourceGitLab:
+		return equalToken(header.Get("X-Gitlab-Token"), secret)
+	case SourceCI:
+		token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer ")
+		return ok && equalToken(token, secret)
+	}
+	return false
+}
+
+func equalToken(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// githubDelivery is a deployment_status webhook
+type githubDelivery struct {
+	Deployment *struct {
+		SHA         string          `json:"sha"`
+		Ref         string          `json:"ref"`
+		Environment string          `json:"environment"`
+		Payload     json.RawMessage `json:"payload"`
+		Creator     struct {
+			Login string `json:"login"`
+		} `json:"creator"`
+	} `json:"deployment"`
+	DeploymentStatus *struct {
+		State     string    `json:"state"`
+		TargetURL string    `json:"target_url"`
+		CreatedAt time.Time `json:"created_at"`
+	} `json:"deployment_status"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// deployPayload is the custom payload a workflow attaches to a GitHub
+// deployment to name what it ships
+type deployPayload struct {
+	Image     string `json:"image"`
+	Namespace string `json:"namespace"`
+}
+
+func decodeGitHub(body []byte) ([]Deploy, error) {
+	var delivery githubDelivery
+	if err := json.Unmarshal(body, &delivery); err != nil {
+		return nil, fmt.Errorf("invalid GitHub payload: %w", err)
+	}
+	if delivery.Deployment == nil || delivery.DeploymentStatus == nil || delivery.DeploymentStatus.State != "success" {
+		return nil, nil
+	}
+
+	d := delivery.Deployment
+	var payload deployPayload
+	if len(d.Payload) > 0 {
+		// The deployment API keeps a string payload as a string
+		raw := d.Payload
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			raw = []byte(s)
+		}
+		json.Unmarshal(raw, &payload)
+	}
+
+	return []Deploy{{
+		Source:      SourceGitHub,
+		Repository:  delivery.Repository.FullName,
+		Commit:      d.SHA,
+		Ref:         d.Ref,
+		Image:       payload.Image,
+		Environment: d.Environment,
+		Namespace:   payload.Namespace,
+		URL:         delivery.DeploymentStatus.TargetURL,
+		Actor:       d.Creator.Login,
+		At:          delivery.DeploymentStatus.CreatedAt,
+	}}, nil
+}
+
+// gitlabDelivery is a Deployment Hook
+type gitlabDelivery struct {
+	ObjectKind      string `json:"object_kind"`
+	Status          string `json:"status"`
+	StatusChangedAt string `json:"status_changed_at"`
+	Environment     string `json:"environment"`
+	Ref             string `json:"ref"`
+	ShortSHA        string `json:"short_sha"`
+	CommitURL       string `json:"commit_url"`
+	DeployableURL   string `json:"deployable_url"`
+	Project         struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+}
+
+func decodeGitLab(body []byte) ([]Deploy, error) {
+	var delivery gitlabDelivery
+	if err := json.Unmarshal(body, &delivery); err != nil {
+		return nil, fmt.Errorf("invalid GitLab payload: %w", err)
+	}
+	if delivery.ObjectKind != "deployment" || delivery.Status != "success" {
+		return nil, nil
+	}
+
+	// The hook carries only the short SHA; the commit URL ends in the full one
+	commit := delivery.ShortSHA
+	if i := strings.LastIndex(delivery.CommitURL, "/"); i >= 0 && i < len(delivery.CommitURL)-1 {
+		commit = delivery.CommitURL[i+1:]
+	}
+	at, _ := time.Parse(gitlabTimeLayout, delivery.StatusChangedAt)
+
+	return []Deploy{{
+		Source:      SourceGitLab,
+		Repository:  delivery.Project.PathWithNamespace,
+		Commit:      commit,
+		Ref:         delivery.Ref,
+		Environment: delivery.Environment,
+		URL:         delivery.DeployableURL,
+		Actor:       delivery.User.Username,
+		At:          at,
+	}}, nil
+}
+
+func decodeCI(body []byte) ([]Deploy, error) {
+	var d Deploy
+	if err := json.Unmarshal(body, &d); err != nil {
+		return nil, fmt.Errorf("invalid deploy payload: %w", err)
+	}
+	if d.Repository == "" {
+		return nil, fmt.Errorf("repository is required")
+	}
+	if d.Commit == "" {
+		return nil, fmt.Errorf("commit is required")
+	}
+	d.Source = SourceCI
+	return []Deploy{d}, nil
+}
@@ -0,0 +1,120 @@
+package cloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+// AWSCollector decodes Amazon EventBridge events, delivered either one per
+// request or as a JSON array
+type AWSCollector struct{}
+
+type eventBridgeEvent struct {
+	DetailType string          `json:"detail-type"`
+	Source     string          `json:"source"`
+	Time       time.Time       `json:"time"`
+	Region     string          `json:"region"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+type ec2Detail struct {
+	InstanceID     string `json:"instance-id"`
+	InstanceAction string `json:"instance-action"`
+	State          string `json:"state"`
+}
+
+type awsHealthDetail struct {
+	Service           string `json:"service"`
+	EventTypeCode     string `json:"eventTypeCode"`
+	EventTypeCategory string `json:"eventTypeCategory"`
+	StatusCode        string `json:"statusCode"`
+	AffectedEntities  []struct {
+		EntityValue string `json:"entityValue"`
+	} `json:"affectedEntities"`
+}
+
+func (AWSCollector) Provider() string { return "aws" }
+
+func (c AWSCollector) Decode(body []byte) ([]types.StateEvent, error) {
+	var envelopes []eventBridgeEvent
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &envelopes); err != nil {
+			return nil, fmt.Errorf("invalid EventBridge payload: %w", err)
+		}
+	} else {
+		var single eventBridgeEvent
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return nil, fmt.Errorf("invalid EventBridge payload: %w", err)
+		}
+		envelopes = []eventBridgeEvent{single}
+	}
+
+	var events []types.StateEvent
+	for _, envelope := range envelopes {
+		decoded, err := c.decodeOne(envelope)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, decoded...)
+	}
+	return events, nil
+}
+
+func (c AWSCollector) decodeOne(e eventBridgeEvent) ([]types.StateEvent, error) {
+	switch e.DetailType {
+	case "EC2 Spot Instance Interruption Warning",
+		"EC2 Instance Rebalance Recommendation",
+		"EC2 Instance State-change Notification":
+		var detail ec2Detail
+		if err := json.Unmarshal(e.Detail, &detail); err != nil || detail.InstanceID == "" {
+			return nil, fmt.Errorf("%s event without instance-id", e.DetailType)
+		}
+		event := newEvent(c.Provider(), KindInstance, detail.InstanceID, e.Region, e.Time)
+		event.FieldDiff[FieldInstanceID] = detail.InstanceID
+		switch e.DetailType {
+		case "EC2 Spot Instance Interruption Warning":
+			event.FieldDiff[FieldLifecycleEvent] = "spot-interruption"
+			event.FieldDiff[FieldLifecycleNote] = detail.InstanceAction
+		case "EC2 Instance Rebalance Recommendation":
+			event.FieldDiff[FieldLifecycleEvent] = "rebalance-recommendation"
+		default:
+			event.FieldDiff[FieldInstanceState] = detail.State
+		}
+		return []types.StateEvent{event}, nil
+
+	case "AWS Health Event":
+		var detail awsHealthDetail
+		if err := json.Unmarshal(e.Detail, &detail); err != nil {
+			return nil, fmt.Errorf("invalid AWS Health event: %w", err)
+		}
+		kind := KindInstance
+		if strings.HasPrefix(detail.Service, "ELASTICLOADBALANCING") {
+			kind = KindLoadBalancer
+		} else if detail.Service != "EC2" {
+			return nil, nil
+		}
+
+		var events []types.StateEvent
+		for _, entity := range detail.AffectedEntities {
+			event := newEvent(c.Provider(), kind, entity.EntityValue, e.Region, e.Time)
+			event.FieldDiff[FieldHealthEvent] = detail.EventTypeCode
+			event.FieldDiff[FieldHealthStatus] = detail.StatusCode
+			if kind == KindInstance {
+				event.FieldDiff[FieldInstanceID] = entity.EntityValue
+				event.FieldDiff[FieldLifecycleEvent] = strings.ToLower(detail.EventTypeCategory)
+			}
+			events = append(events, event)
+		}
+		return events, nil
+
+	default:
+		// Other event types are not relevant to cluster state
+		return nil, nil
+	}
+}
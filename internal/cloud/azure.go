@@ -0,0 +1,118 @@
+package cloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+// AzureCollector decodes Event Grid deliveries from the Resource
+// Notifications health resources system topic
+type AzureCollector struct{}
+
+type eventGridEvent struct {
+	EventType string          `json:"eventType"`
+	Subject   string          `json:"subject"`
+	EventTime time.Time       `json:"eventTime"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type azureHealthData struct {
+	ValidationCode string `json:"validationCode"`
+	ResourceInfo   struct {
+		Properties struct {
+			TargetResourceType string `json:"targetResourceType"`
+			AvailabilityState  string `json:"availabilityState"`
+			ReasonType         string `json:"reasonType"`
+			AnnotationName     string `json:"annotationName"`
+			Summary            string `json:"summary"`
+		} `json:"properties"`
+	} `json:"resourceInfo"`
+}
+
+const (
+	azureValidationEvent   = "Microsoft.EventGrid.SubscriptionValidationEvent"
+	azureAvailabilityEvent = "Microsoft.ResourceNotifications.HealthResources.AvailabilityStatusChanged"
+	azureAnnotatedEvent    = "Microsoft.ResourceNotifications.HealthResources.ResourceAnnotated"
+)
+
+func (AzureCollector) Provider() string { return "azure" }
+
+// Handshake answers the Event Grid subscription validation request
+func (AzureCollector) Handshake(body []byte) (interface{}, bool) {
+	var deliveries []eventGridEvent
+	if err := json.Unmarshal(body, &deliveries); err != nil || len(deliveries) == 0 {
+		return nil, false
+	}
+	if deliveries[0].EventType != azureValidationEvent {
+		return nil, false
+	}
+	var data azureHealthData
+	if err := json.Unmarshal(deliveries[0].Data, &data); err != nil {
+		return nil, false
+	}
+	return map[string]string{"validationResponse": data.ValidationCode}, true
+}
+
+func (c AzureCollector) Decode(body []byte) ([]types.StateEvent, error) {
+	var deliveries []eventGridEvent
+	if err := json.Unmarshal(body, &deliveries); err != nil {
+		return nil, fmt.Errorf("invalid Event Grid payload: %w", err)
+	}
+
+	var events []types.StateEvent
+	for _, delivery := range deliveries {
+		if delivery.EventType != azureAvailabilityEvent && delivery.EventType != azureAnnotatedEvent {
+			continue
+		}
+		var data azureHealthData
+		if err := json.Unmarshal(delivery.Data, &data); err != nil {
+			return nil, fmt.Errorf("invalid %s data: %w", delivery.EventType, err)
+		}
+		props := data.ResourceInfo.Properties
+
+		// Subjects are resource IDs:
+		// /subscriptions/<id>/resourceGroups/<rg>/providers/<type>/<name>
+		name := delivery.Subject[strings.LastIndex(delivery.Subject, "/")+1:]
+		group := resourceGroup(delivery.Subject)
+
+		var event types.StateEvent
+		switch {
+		case strings.EqualFold(props.TargetResourceType, "Microsoft.Compute/virtualMachines"):
+			event = newEvent(c.Provider(), KindInstance, name, group, delivery.EventTime)
+			event.FieldDiff[FieldInstanceID] = name
+			if props.AvailabilityState != "" {
+				event.FieldDiff[FieldInstanceState] = props.AvailabilityState
+			}
+			if props.AnnotationName != "" {
+				event.FieldDiff[FieldLifecycleEvent] = props.AnnotationName
+			} else if props.ReasonType != "" {
+				event.FieldDiff[FieldLifecycleEvent] = strings.ToLower(props.ReasonType)
+			}
+		case strings.EqualFold(props.TargetResourceType, "Microsoft.Network/loadBalancers"):
+			event = newEvent(c.Provider(), KindLoadBalancer, name, group, delivery.EventTime)
+			event.FieldDiff[FieldHealthStatus] = props.AvailabilityState
+			event.FieldDiff[FieldHealthEvent] = props.AnnotationName
+		default:
+			continue
+		}
+		if props.Summary != "" {
+			event.FieldDiff[FieldLifecycleNote] = props.Summary
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func resourceGroup(resourceID string) string {
+	parts := strings.Split(resourceID, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if strings.EqualFold(parts[i], "resourceGroups") {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+package cloud
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// Actor is recorded on every event produced by a cloud collector
+const Actor = "cloud-provider"
+
+// Kinds of StateEvents produced by collectors
+const (
+	KindInstance     = "CloudInstance"
+	KindLoadBalancer = "CloudLoadBalancer"
+)
+
+// Fields set on collected events
+const (
+	FieldProvider       = "provider"
+	FieldInstanceID     = "instance.id"
+	FieldInstanceState  = "instance.state"
+	FieldLifecycleEvent = "lifecycle.event"
+	FieldLifecycleNote  = "lifecycle.detail"
+	FieldHealthStatus   = "health.status"
+	FieldHealthEvent    = "health.event"
+	FieldHealthTarget   = "health.target"
+
+	// nodeProviderIDField is set on Node events from spec.providerID
+	nodeProviderIDField = "spec.providerID"
+)
+
+// Collector turns provider notifications (EventBridge events, Pub/Sub
+// pushes, Event Grid deliveries) into StateEvents
+type Collector interface {
+	Provider() string
+	Decode(body []byte) ([]types.StateEvent, error)
+}
+
+// Handshaker is implemented by collectors whose delivery channel requires
+// answering a subscription validation request
+type Handshaker interface {
+	Handshake(body []byte) (response interface{}, ok bool)
+}
+
+// Registry holds the collectors available to the ingestion endpoint
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+}
+
+func NewRegistry(collectors ...Collector) *Registry {
+	r := &Registry{collectors: make(map[string]Collector)}
+	for _, c := range collectors {
+		r.Register(c)
+	}
+	return r
+}
+
+// DefaultRegistry contains the AWS, GCP and Azure collectors
+func DefaultRegistry() *Registry {
+	return NewRegistry(AWSCollector{}, GCPCollector{}, AzureCollector{})
+}
+
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors[c.Provider()] = c
+}
+
+func (r *Registry) Get(provider string) (Collector, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, exists := r.collectors[provider]
+	return c, exists
+}
+
+func (r *Registry) Providers() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	providers := make([]string, 0, len(r.collectors))
+	for p := range r.collectors {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// newEvent builds a collector event. Every notification is a new version
+// of the cloud resource it describes.
+func newEvent(provider, kind, id, namespace string, at time.Time) types.StateEvent {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return types.StateEvent{
+		UID:       fmt.Sprintf("cloud:%s:%s", provider, id),
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      id,
+		Version:   strconv.FormatInt(at.UnixNano(), 10),
+		Timestamp: at,
+		FieldDiff: map[string]interface{}{FieldProvider: provider},
+		Actor:     Actor,
+	}
+}
+
+// InstanceID extracts the instance identifier from a Node providerID, e.g.
+// aws:///us-east-1a/i-0abc, gce://project/zone/name or
+// azure:///subscriptions/.../virtualMachines/name
+func InstanceID(providerID string) string {
+	if providerID == "" {
+		return ""
+	}
+	return providerID[strings.LastIndex(providerID, "/")+1:]
+}
+
+// EventsForNode returns the latest cloud instance events describing the
+// machine behind node, matched by providerID or, failing that, node name
+func EventsForNode(store state.StateStore, node types.StateEvent) []types.StateEvent {
+	candidates := map[string]bool{node.Name: true}
+	if providerID, ok := node.FieldDiff[nodeProviderIDField].(string); ok {
+		if id := InstanceID(providerID); id != "" {
+			candidates[id] = true
+		}
+	}
+
+	var matches []types.StateEvent
+	for _, event := range store.GetLatestByKind(KindInstance) {
+		id, _ := event.FieldDiff[FieldInstanceID].(string)
+		if candidates[id] {
+			matches = append(matches, event)
+		}
+	}
+	return matches
+}
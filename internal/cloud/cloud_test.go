@@ -0,0 +1,135 @@
+package cloud
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestAWSCollector_SpotInterruption(t *testing.T) {
+	body := `{
+		"detail-type": "EC2 Spot Instance Interruption Warning",
+		"source": "aws.ec2",
+		"time": "2026-03-01T12:00:00Z",
+		"region": "us-east-1",
+		"detail": {"instance-id": "i-0abc", "instance-action": "terminate"}
+	}`
+
+	events, err := AWSCollector{}.Decode([]byte(body))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+
+	event := events[0]
+	if event.UID != "cloud:aws:i-0abc" || event.Kind != KindInstance || event.Actor != Actor {
+		t.Errorf("Unexpected event identity: %+v", event)
+	}
+	if event.FieldDiff[FieldLifecycleEvent] != "spot-interruption" || event.FieldDiff[FieldLifecycleNote] != "terminate" {
+		t.Errorf("Unexpected fields: %v", event.FieldDiff)
+	}
+}
+
+func TestAWSCollector_LoadBalancerHealth(t *testing.T) {
+	body := `[{
+		"detail-type": "AWS Health Event",
+		"source": "aws.health",
+		"region": "eu-west-1",
+		"detail": {
+			"service": "ELASTICLOADBALANCING",
+			"eventTypeCode": "AWS_ELASTICLOADBALANCING_OPERATIONAL_ISSUE",
+			"statusCode": "open",
+			"affectedEntities": [{"entityValue": "web-alb"}, {"entityValue": "api-alb"}]
+		}
+	}]`
+
+	events, err := AWSCollector{}.Decode([]byte(body))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(events) != 2 || events[0].Kind != KindLoadBalancer || events[1].Name != "api-alb" {
+		t.Errorf("Unexpected events: %+v", events)
+	}
+}
+
+func TestGCPCollector_Preempted(t *testing.T) {
+	entry := `{
+		"timestamp": "2026-03-01T12:00:00Z",
+		"resource": {"type": "gce_instance", "labels": {"zone": "us-central1-a"}},
+		"protoPayload": {
+			"methodName": "compute.instances.preempted",
+			"resourceName": "projects/p/zones/us-central1-a/instances/gke-pool-1-abcd"
+		}
+	}`
+	push, _ := json.Marshal(map[string]interface{}{
+		"message": map[string]string{"data": base64.StdEncoding.EncodeToString([]byte(entry))},
+	})
+
+	events, err := GCPCollector{}.Decode(push)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(events) != 1 || events[0].FieldDiff[FieldInstanceID] != "gke-pool-1-abcd" || events[0].FieldDiff[FieldLifecycleEvent] != "preempted" {
+		t.Errorf("Unexpected events: %+v", events)
+	}
+}
+
+func TestAzureCollector(t *testing.T) {
+	validation := `[{"eventType": "Microsoft.EventGrid.SubscriptionValidationEvent", "data": {"validationCode": "abc"}}]`
+	response, ok := AzureCollector{}.Handshake([]byte(validation))
+	if !ok || response.(map[string]string)["validationResponse"] != "abc" {
+		t.Errorf("Expected validation handshake, got %v", response)
+	}
+
+	body := `[{
+		"eventType": "Microsoft.ResourceNotifications.HealthResources.AvailabilityStatusChanged",
+		"subject": "/subscriptions/s/resourceGroups/aks-nodes/providers/Microsoft.Compute/virtualMachines/aks-spot-0",
+		"eventTime": "2026-03-01T12:00:00Z",
+		"data": {"resourceInfo": {"properties": {
+			"targetResourceType": "Microsoft.Compute/virtualMachines",
+			"availabilityState": "Unavailable",
+			"reasonType": "Unplanned"
+		}}}
+	}]`
+	if _, ok := (AzureCollector{}).Handshake([]byte(body)); ok {
+		t.Error("Expected no handshake for regular deliveries")
+	}
+
+	events, err := AzureCollector{}.Decode([]byte(body))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Namespace != "aks-nodes" || events[0].FieldDiff[FieldInstanceState] != "Unavailable" {
+		t.Errorf("Unexpected events: %+v", events)
+	}
+}
+
+func TestEventsForNode(t *testing.T) {
+	store := state.NewMemoryStore()
+	reclaim := newEvent("aws", KindInstance, "i-0abc", "us-east-1", time.Now())
+	reclaim.FieldDiff[FieldInstanceID] = "i-0abc"
+	store.Record(reclaim)
+
+	node := types.StateEvent{
+		UID:  "node-1",
+		Kind: "Node",
+		Name: "ip-10-0-0-1.ec2.internal",
+		FieldDiff: map[string]interface{}{
+			"spec.providerID": "aws:///us-east-1a/i-0abc",
+		},
+	}
+	if matches := EventsForNode(store, node); len(matches) != 1 {
+		t.Errorf("Expected providerID match, got %d events", len(matches))
+	}
+
+	other := types.StateEvent{Kind: "Node", Name: "ip-10-0-0-2.ec2.internal"}
+	if matches := EventsForNode(store, other); len(matches) != 0 {
+		t.Errorf("Expected no match, got %d events", len(matches))
+	}
+}
@@ -0,0 +1,100 @@
+package cloud
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+// GCPCollector decodes Cloud Logging entries delivered by a Pub/Sub push
+// subscription attached to a log sink
+type GCPCollector struct{}
+
+type pubSubPush struct {
+	Message struct {
+		Data []byte `json:"data"`
+	} `json:"message"`
+}
+
+type logEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Resource  struct {
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels"`
+	} `json:"resource"`
+	ProtoPayload struct {
+		MethodName   string `json:"methodName"`
+		ResourceName string `json:"resourceName"`
+	} `json:"protoPayload"`
+	JSONPayload struct {
+		HealthCheckProbeResult *struct {
+			HealthState         string `json:"healthState"`
+			PreviousHealthState string `json:"previousHealthState"`
+			IPAddress           string `json:"ipAddress"`
+		} `json:"healthCheckProbeResult"`
+	} `json:"jsonPayload"`
+}
+
+// gceLifecycleMethods maps audit log methods to lifecycle events
+var gceLifecycleMethods = map[string]string{
+	"compute.instances.preempted":                  "preempted",
+	"compute.instances.hostError":                  "host-error",
+	"compute.instances.automaticRestart":           "automatic-restart",
+	"compute.instances.terminateOnHostMaintenance": "host-maintenance-termination",
+	"compute.instances.guestTerminate":             "guest-terminate",
+}
+
+func (GCPCollector) Provider() string { return "gcp" }
+
+func (c GCPCollector) Decode(body []byte) ([]types.StateEvent, error) {
+	var push pubSubPush
+	if err := json.Unmarshal(body, &push); err != nil {
+		return nil, fmt.Errorf("invalid Pub/Sub push payload: %w", err)
+	}
+	// encoding/json already base64-decodes []byte; tolerate double encoding
+	data := push.Message.Data
+	if decoded, err := base64.StdEncoding.DecodeString(string(data)); err == nil {
+		data = decoded
+	}
+
+	var entry logEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid log entry: %w", err)
+	}
+
+	zone := entry.Resource.Labels["zone"]
+
+	if lifecycle, ok := gceLifecycleMethods[entry.ProtoPayload.MethodName]; ok {
+		name := entry.ProtoPayload.ResourceName
+		name = name[strings.LastIndex(name, "/")+1:]
+		if name == "" {
+			return nil, fmt.Errorf("%s entry without resource name", entry.ProtoPayload.MethodName)
+		}
+		// GKE node names match instance names
+		event := newEvent(c.Provider(), KindInstance, name, zone, entry.Timestamp)
+		event.FieldDiff[FieldInstanceID] = name
+		event.FieldDiff[FieldLifecycleEvent] = lifecycle
+		return []types.StateEvent{event}, nil
+	}
+
+	if probe := entry.JSONPayload.HealthCheckProbeResult; probe != nil {
+		name := entry.Resource.Labels["backend_service_name"]
+		if name == "" {
+			name = entry.Resource.Labels["instance_group_name"]
+		}
+		if name == "" {
+			return nil, fmt.Errorf("health check entry without backend")
+		}
+		event := newEvent(c.Provider(), KindLoadBalancer, name, zone, entry.Timestamp)
+		event.FieldDiff[FieldHealthStatus] = probe.HealthState
+		event.FieldDiff[FieldHealthEvent] = probe.PreviousHealthState + "->" + probe.HealthState
+		event.FieldDiff[FieldHealthTarget] = probe.IPAddress
+		return []types.StateEvent{event}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,235 @@
+package coldstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+const (
+	manifestKey = "manifest.json"
+	// manifestTTL is how long a read manifest is reused; the writer's own
+	// updates are seen immediately
+	manifestTTL = time.Minute
+)
+
+// Segment is one archive file: gzip-compressed NDJSON of the state events
+// recorded between From and To
+type Segment struct {
+	Key       string    `json:"key"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Events    int       `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Manifest indexes the segments of an archive, oldest first. Readers only
+// fetch the segments overlapping the range they query.
+type Manifest struct {
+	// ArchivedBefore is the latest cutoff history was archived up to.
+	// Queries of later times never need the archive.
+	ArchivedBefore time.Time `json:"archived_before"`
+	Segments       []Segment `json:"segments"`
+}
+
+// Archive reads and writes history segments under a prefix of a bucket
+type Archive struct {
+	bucket Bucket
+	prefix string
+
+	// mu serializes manifest updates from this process; a single writer
+	// is expected per archive
+	mu sync.Mutex
+
+	cacheMu   sync.Mutex
+	cached    *Manifest
+	fetchedAt time.Time
+}
+
+func NewArchive(bucket Bucket, prefix string) *Archive {
+	return &Archive{bucket: bucket, prefix: prefix}
+}
+
+// Open opens the archive at an s3://, gs:// or file:// URL
+func Open(rawURL string) (*Archive, error) {
+	bucket, prefix, err := OpenBucket(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewArchive(bucket, prefix), nil
+}
+
+// Manifest returns the archive's manifest, empty for a new archive. The
+// result is shared and must not be modified.
+func (a *Archive) Manifest(ctx context.Context) (*Manifest, error) {
+	a.cacheMu.Lock()
+	defer a.cacheMu.Unlock()
+	if a.cached != nil && time.Since(a.fetchedAt) < manifestTTL {
+		return a.cached, nil
+	}
+	m, err := a.fetchManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.cached, a.fetchedAt = m, time.Now()
+	return m, nil
+}
+
+func (a *Archive) fetchManifest(ctx context.Context) (*Manifest, error) {
+	data, err := a.bucket.Get(ctx, a.key(manifestKey))
+	if errors.Is(err, ErrNotFound) {
+		return &Manifest{Segments: []Segment{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid archive manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// Write stores events recorded before cutoff as a new segment and adds it
+// to the manifest. The segment is written first, so a failed manifest
+// update leaves an unreferenced file rather than a dangling entry.
+func (a *Archive) Write(ctx context.Context, events []types.StateEvent, cutoff time.Time) (Segment, error) {
+	if len(events) == 0 {
+		return Segment{}, errors.New("no events to archive")
+	}
+	from, to := events[0].Timestamp, events[0].Timestamp
+	for _, e := range events {
+		if e.Timestamp.Before(from) {
+			from = e.Timestamp
+		}
+		if e.Timestamp.After(to) {
+			to = e.Timestamp
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return Segment{}, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return Segment{}, err
+	}
+
+	now := time.Now().UTC()
+	segment := Segment{
+		Key: fmt.Sprintf("history/%s/%s-%s.ndjson.gz", from.UTC().Format("2006/01/02"),
+			from.UTC().Format("20060102T150405Z"), now.Format("20060102T150405.000000000Z")),
+		From:      from,
+		To:        to,
+		Events:    len(events),
+		CreatedAt: now,
+	}
+	if err := a.bucket.Put(ctx, a.key(segment.Key), buf.Bytes()); err != nil {
+		return Segment{}, fmt.Errorf("failed to write archive segment: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m, err := a.fetchManifest(ctx)
+	if err != nil {
+		return Segment{}, err
+	}
+	m.Segments = append(m.Segments, segment)
+	if cutoff.After(m.ArchivedBefore) {
+		m.ArchivedBefore = cutoff
+	}
+	sort.SliceStable(m.Segments, func(i, j int) bool { return m.Segments[i].From.Before(m.Segments[j].From) })
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return Segment{}, err
+	}
+	if err := a.bucket.Put(ctx, a.key(manifestKey), data); err != nil {
+		return Segment{}, fmt.Errorf("failed to update archive manifest: %w", err)
+	}
+
+	a.cacheMu.Lock()
+	a.cached, a.fetchedAt = m, time.Now()
+	a.cacheMu.Unlock()
+	return segment, nil
+}
+
+// Read returns the archived events recorded after from and at or before
+// to, oldest first, optionally only those of the given UIDs. A zero from
+// reads from the start of the archive.
+func (a *Archive) Read(ctx context.Context, from, to time.Time, uids ...string) ([]types.StateEvent, error) {
+	m, err := a.Manifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		wanted[uid] = true
+	}
+
+	events := make([]types.StateEvent, 0)
+	seen := make(map[string]bool)
+	for _, s := range m.Segments {
+		if s.From.After(to) || (!from.IsZero() && !s.To.After(from)) {
+			continue
+		}
+		segment, err := a.readSegment(ctx, s.Key)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range segment {
+			if (len(wanted) > 0 && !wanted[e.UID]) || e.Timestamp.After(to) || (!from.IsZero() && !e.Timestamp.After(from)) {
+				continue
+			}
+			// A segment retried after a failed delete repeats versions
+			if id := e.UID + "@" + e.Version; !seen[id] {
+				seen[id] = true
+				events = append(events, e)
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+	return events, nil
+}
+
+func (a *Archive) readSegment(ctx context.Context, key string) ([]types.StateEvent, error) {
+	data, err := a.bucket.Get(ctx, a.key(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive segment %s: %w", key, err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive segment %s: %w", key, err)
+	}
+	defer zr.Close()
+
+	var events []types.StateEvent
+	dec := json.NewDecoder(zr)
+	for dec.More() {
+		var e types.StateEvent
+		if err := dec.Decode(&e); err != nil {
+			return nil, fmt.Errorf("invalid archive segment %s: %w", key, err)
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func (a *Archive) key(name string) string {
+	if a.prefix == "" {
+		return name
+	}
+	return path.Join(a.prefix, name)
+}
@@ -0,0 +1,105 @@
+package coldstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func event(uid, version string, at time.Time) types.StateEvent {
+	return types.StateEvent{UID: uid, Kind: "Pod", Namespace: "default", Name: uid, Version: version, Timestamp: at,
+		FieldDiff: map[string]interface{}{"status.phase": "Running"}}
+}
+
+func TestArchive_WriteRead(t *testing.T) {
+	ctx := context.Background()
+	archive := NewArchive(DirBucket(t.TempDir()), "prod")
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	m, err := archive.Manifest(ctx)
+	if err != nil || len(m.Segments) != 0 || !m.ArchivedBefore.IsZero() {
+		t.Fatalf("Expected an empty manifest for a new archive, got %+v (%v)", m, err)
+	}
+
+	first := []types.StateEvent{event("pod-1", "1", start), event("pod-2", "1", start.Add(time.Minute))}
+	second := []types.StateEvent{event("pod-1", "2", start.Add(time.Hour)), event("pod-1", "1", start)}
+	if _, err := archive.Write(ctx, first, start.Add(30*time.Minute)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	segment, err := archive.Write(ctx, second, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !segment.From.Equal(start) || !segment.To.Equal(start.Add(time.Hour)) || segment.Events != 2 {
+		t.Errorf("Unexpected segment %+v", segment)
+	}
+
+	// A second reader sees what the writer stored
+	reader := NewArchive(archive.bucket, "prod")
+	m, err = reader.Manifest(ctx)
+	if err != nil || len(m.Segments) != 2 || !m.ArchivedBefore.Equal(start.Add(2*time.Hour)) {
+		t.Fatalf("Unexpected manifest %+v (%v)", m, err)
+	}
+
+	events, err := reader.Read(ctx, time.Time{}, start.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(events) != 3 || events[0].UID != "pod-1" || events[2].Version != "2" {
+		t.Errorf("Expected 3 deduplicated events oldest first, got %+v", events)
+	}
+	if events[0].FieldDiff["status.phase"] != "Running" {
+		t.Errorf("Expected fields to survive the archive, got %v", events[0].FieldDiff)
+	}
+
+	events, _ = reader.Read(ctx, start, start.Add(3*time.Hour), "pod-1")
+	if len(events) != 1 || events[0].Version != "2" {
+		t.Errorf("Expected only pod-1's version after the start, got %+v", events)
+	}
+}
+
+type fakeSource struct {
+	versions []types.StateEvent
+	deleted  int
+}
+
+func (f *fakeSource) AgedVersions(cutoff time.Time, limit int) ([]types.StateEvent, error) {
+	var aged []types.StateEvent
+	for _, e := range f.versions {
+		if e.Timestamp.Before(cutoff) && len(aged) < limit {
+			aged = append(aged, e)
+		}
+	}
+	return aged, nil
+}
+
+func (f *fakeSource) DeleteVersions(events []types.StateEvent) error {
+	f.versions = f.versions[len(events):]
+	f.deleted += len(events)
+	return nil
+}
+
+func TestArchiver_ArchiveBefore(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	source := &fakeSource{}
+	for i := 0; i < 5; i++ {
+		source.versions = append(source.versions, event("pod-1", string(rune('a'+i)), start.Add(time.Duration(i)*time.Minute)))
+	}
+	archive := NewArchive(DirBucket(t.TempDir()), "")
+	archiver := &Archiver{Archive: archive, Source: source, BatchSize: 2}
+
+	n, err := archiver.ArchiveBefore(ctx, start.Add(4*time.Minute))
+	if err != nil {
+		t.Fatalf("ArchiveBefore failed: %v", err)
+	}
+	if n != 4 || source.deleted != 4 || len(source.versions) != 1 {
+		t.Errorf("Expected 4 versions moved, got %d (%d deleted, %d left)", n, source.deleted, len(source.versions))
+	}
+	m, _ := archive.Manifest(ctx)
+	if len(m.Segments) != 2 {
+		t.Errorf("Expected one segment per batch, got %d", len(m.Segments))
+	}
+}
@@ -0,0 +1,85 @@
+package coldstore
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+const (
+	// DefaultArchiveInterval is how often Archiver.Run moves aged history
+	DefaultArchiveInterval = time.Hour
+	// DefaultArchiveAge is how old history must be to be archived
+	DefaultArchiveAge = 30 * 24 * time.Hour
+	// DefaultBatchSize caps the events written to one segment
+	DefaultBatchSize = 10000
+)
+
+// Source is a history store whose aged versions can be moved out
+type Source interface {
+	// AgedVersions returns up to limit versions recorded before cutoff,
+	// oldest first, leaving out every object's latest version before it
+	AgedVersions(cutoff time.Time, limit int) ([]types.StateEvent, error)
+	// DeleteVersions removes versions once they are archived
+	DeleteVersions(events []types.StateEvent) error
+}
+
+// Archiver moves history older than a cutoff from a Source to an Archive,
+// keeping the hot database small
+type Archiver struct {
+	Archive   *Archive
+	Source    Source
+	BatchSize int
+}
+
+// ArchiveBefore archives every version recorded before cutoff, one segment
+// per batch, and returns how many were moved. A version deleted only after
+// its segment is written is never lost; at worst it is archived twice,
+// which readers deduplicate.
+func (a *Archiver) ArchiveBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	size := a.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	moved := 0
+	for ctx.Err() == nil {
+		events, err := a.Source.AgedVersions(cutoff, size)
+		if err != nil || len(events) == 0 {
+			return moved, err
+		}
+		if _, err := a.Archive.Write(ctx, events, cutoff); err != nil {
+			return moved, err
+		}
+		if err := a.Source.DeleteVersions(events); err != nil {
+			return moved, err
+		}
+		moved += len(events)
+		if len(events) < size {
+			break
+		}
+	}
+	return moved, ctx.Err()
+}
+
+// Run archives history older than age every interval until ctx is
+// cancelled
+func (a *Archiver) Run(ctx context.Context, interval, age time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		cutoff := time.Now().Add(-age)
+		if n, err := a.ArchiveBefore(ctx, cutoff); err != nil {
+			log.Printf("Failed to archive history: %v", err)
+		} else if n > 0 {
+			log.Printf("Archived %d object versions recorded before %s", n, cutoff.Format(time.RFC3339))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package coldstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned by Bucket.Get for a missing key
+var ErrNotFound = errors.New("object not found")
+
+// Bucket stores archive files and the manifest indexing them
+type Bucket interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// OpenBucket opens the bucket and key prefix named by rawURL:
+//
+//	s3://bucket/prefix   AWS S3, or any S3 API at AWS_ENDPOINT_URL
+//	gs://bucket/prefix   Google Cloud Storage through its S3-compatible API
+//	file:///dir/prefix   a local or mounted directory
+//
+// S3 credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN and AWS_REGION; GCS takes HMAC keys from
+// GCS_HMAC_ACCESS_ID and GCS_HMAC_SECRET.
+func OpenBucket(rawURL string) (Bucket, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid archive URL: %w", err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+
+	switch u.Scheme {
+	case "s3":
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		endpoint := os.Getenv("AWS_ENDPOINT_URL")
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+		return &S3Bucket{
+			Endpoint:     endpoint,
+			Region:       region,
+			Bucket:       u.Host,
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}, prefix, nil
+	case "gs":
+		return &S3Bucket{
+			Endpoint:  "https://storage.googleapis.com",
+			Region:    "auto",
+			Bucket:    u.Host,
+			AccessKey: os.Getenv("GCS_HMAC_ACCESS_ID"),
+			SecretKey: os.Getenv("GCS_HMAC_SECRET"),
+		}, prefix, nil
+	case "file":
+		return DirBucket(filepath.Join(u.Host, u.Path)), "", nil
+	}
+	return nil, "", fmt.Errorf("unsupported archive URL scheme %q, want s3, gs or file", u.Scheme)
+}
+
+// DirBucket stores archive files under a directory
+type DirBucket string
+
+func (d DirBucket) Put(ctx context.Context, key string, data []byte) error {
+	path := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write and rename so readers never see a partial manifest
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (d DirBucket) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(string(d), filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
@@ -0,0 +1,135 @@
+package criticality
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// Tier ranks how business-critical a workload is
+type Tier string
+
+const (
+	Tier1 Tier = "tier-1"
+	Tier2 Tier = "tier-2"
+	Tier3 Tier = "tier-3"
+
+	// DefaultTier applies to resources nobody has classified
+	DefaultTier = Tier3
+
+	// AnnotationKey marks a workload's tier, e.g. akari.io/tier: tier-1.
+	// The watcher copies it onto StateEvent.Tier; it is also honoured as a label.
+	AnnotationKey = "akari.io/tier"
+)
+
+var tierWeights = map[Tier]float64{
+	Tier1: 10,
+	Tier2: 3,
+	Tier3: 1,
+}
+
+var severityWeights = map[dsl.Severity]float64{
+	dsl.Critical: 3,
+	dsl.Degraded: 2,
+	dsl.Warning:  1,
+}
+
+// ParseTier accepts "tier-1", "tier1" and "1"
+func ParseTier(s string) (Tier, error) {
+	normalized := strings.ToLower(strings.TrimSpace(s))
+	normalized = strings.TrimPrefix(strings.TrimPrefix(normalized, "tier"), "-")
+	tier := Tier("tier-" + normalized)
+	if _, ok := tierWeights[tier]; !ok {
+		return "", fmt.Errorf("invalid tier %q: must be one of tier-1, tier-2, tier-3", s)
+	}
+	return tier, nil
+}
+
+// Weight is how much a violation on a resource of this tier counts
+func (t Tier) Weight() float64 {
+	if w, ok := tierWeights[t]; ok {
+		return w
+	}
+	return tierWeights[DefaultTier]
+}
+
+// Impact scores a violation by severity and tier, for ranking incidents
+func Impact(severity dsl.Severity, tier Tier) float64 {
+	w, ok := severityWeights[severity]
+	if !ok {
+		w = severityWeights[dsl.Warning]
+	}
+	return w * tier.Weight()
+}
+
+// Persister stores tier overrides set through the API
+type Persister interface {
+	SaveTierOverride(uid string, tier Tier) error
+}
+
+// Registry resolves the tier of a resource. API overrides take precedence
+// over the tier recorded on the event, which takes precedence over labels.
+type Registry struct {
+	mu        sync.RWMutex
+	overrides map[string]Tier
+	persister Persister
+}
+
+func NewRegistry() *Registry {
+	return &Registry{overrides: make(map[string]Tier)}
+}
+
+func (r *Registry) SetPersister(p Persister) {
+	r.persister = p
+}
+
+// Set overrides the tier of a resource; an empty tier clears the override
+func (r *Registry) Set(uid string, tier Tier) error {
+	if r.persister != nil {
+		if err := r.persister.SaveTierOverride(uid, tier); err != nil {
+			return err
+		}
+	}
+	r.Restore(map[string]Tier{uid: tier})
+	return nil
+}
+
+// Restore loads overrides without persisting them
+func (r *Registry) Restore(overrides map[string]Tier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for uid, tier := range overrides {
+		if tier == "" {
+			delete(r.overrides, uid)
+		} else {
+			r.overrides[uid] = tier
+		}
+	}
+}
+
+// Override returns the API-set tier of a resource, if any
+func (r *Registry) Override(uid string) (Tier, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tier, exists := r.overrides[uid]
+	return tier, exists
+}
+
+// Resolve returns the effective tier of a resource
+func (r *Registry) Resolve(resource types.StateEvent) Tier {
+	if tier, exists := r.Override(resource.UID); exists {
+		return tier
+	}
+	for _, value := range []string{resource.Tier, resource.Labels[AnnotationKey]} {
+		if value == "" {
+			continue
+		}
+		if tier, err := ParseTier(value); err == nil {
+			return tier
+		}
+	}
+	return DefaultTier
+}
@@ -0,0 +1,57 @@
+package criticality
+
+import (
+	"testing"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestParseTier(t *testing.T) {
+	for _, input := range []string{"tier-1", "tier1", "1", " Tier-1 "} {
+		if tier, err := ParseTier(input); err != nil || tier != Tier1 {
+			t.Errorf("ParseTier(%q) = %q, %v; want tier-1", input, tier, err)
+		}
+	}
+	if _, err := ParseTier("gold"); err == nil {
+		t.Error("Expected error for unknown tier")
+	}
+}
+
+func TestImpact(t *testing.T) {
+	payment := Impact(dsl.Critical, Tier1)
+	canary := Impact(dsl.Critical, Tier3)
+	if payment <= canary {
+		t.Errorf("Expected tier-1 impact %f to exceed tier-3 impact %f", payment, canary)
+	}
+	if Impact(dsl.Warning, Tier1) <= Impact(dsl.Critical, Tier3) {
+		t.Error("Expected a tier-1 warning to outrank a tier-3 critical")
+	}
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	registry := NewRegistry()
+	resource := types.StateEvent{
+		UID:    "pod-1",
+		Labels: map[string]string{AnnotationKey: "tier-2"},
+	}
+
+	if tier := registry.Resolve(resource); tier != Tier2 {
+		t.Errorf("Expected tier from label, got %s", tier)
+	}
+
+	resource.Tier = "1"
+	if tier := registry.Resolve(resource); tier != Tier1 {
+		t.Errorf("Expected tier from event, got %s", tier)
+	}
+
+	registry.Set("pod-1", Tier3)
+	if tier := registry.Resolve(resource); tier != Tier3 {
+		t.Errorf("Expected override to win, got %s", tier)
+	}
+
+	registry.Set("pod-1", "")
+	if tier := registry.Resolve(types.StateEvent{UID: "pod-1"}); tier != DefaultTier {
+		t.Errorf("Expected default tier after clearing override, got %s", tier)
+	}
+}
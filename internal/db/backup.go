@@ -0,0 +1,237 @@
+package db
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+)
+
+// SchemaVersion is bumped whenever initSchema changes the akari tables in
+// a way older releases can't read. Migrations are additive, so a backup
+// restores into any schema at least as new as the one it was taken from.
+const SchemaVersion = 1
+
+var (
+	// ErrInvalidBackup reports a backup that can't be restored into this
+	// database: unreadable, corrupt or from a newer schema
+	ErrInvalidBackup = errors.New("invalid backup")
+	// ErrRestoreTargetNotEmpty reports akari tables that already hold rows
+	ErrRestoreTargetNotEmpty = errors.New("restore target is not empty")
+)
+
+// backupFormat identifies the header line of a backup
+const backupFormat = "akari-backup"
+
+// backupTables are the akari-owned tables, in restore order. Idempotency
+// keys, notification deliveries and the evaluation log are short-lived and
+// left out.
+var backupTables = []string{
+	"objects",
+	"object_versions",
+	"field_diffs",
+	"invariants",
+	"invariant_versions",
+	"invariant_evaluations",
+	"violations",
+	"violations_archive",
+	"deployment_images",
+	"slos",
+	"slo_buckets",
+	"pod_terminations",
+}
+
+// serialTables have a SERIAL id whose sequence is advanced past restored rows
+var serialTables = []string{"field_diffs", "violations_archive", "deployment_images", "pod_terminations"}
+
+// BackupHeader is the first line of a backup
+type BackupHeader struct {
+	Format        string    `json:"format"`
+	SchemaVersion int       `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// BackupSummary counts the rows written or restored per table
+type BackupSummary struct {
+	SchemaVersion int            `json:"schema_version"`
+	Rows          map[string]int `json:"rows"`
+}
+
+// backupRow is one line after the header
+type backupRow struct {
+	Table string          `json:"table"`
+	Row   json.RawMessage `json:"row"`
+}
+
+func (s *PostgresStore) recordSchemaVersion() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS akari_schema (
+			id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+			version INT NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO akari_schema (version) VALUES ($1)
+		ON CONFLICT (id) DO UPDATE SET version = GREATEST(akari_schema.version, EXCLUDED.version)
+	`, SchemaVersion)
+	return err
+}
+
+// DatabaseSchemaVersion returns the schema version recorded in the
+// database, 0 for databases created before versions were recorded
+func (s *PostgresStore) DatabaseSchemaVersion() int {
+	var version int
+	// Read-only replicas may run against a database that predates the table
+	s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM akari_schema`).Scan(&version)
+	return version
+}
+
+// Backup writes every akari-owned table to w as gzip-compressed JSON lines:
+// a BackupHeader, then one line per row
+func (s *PostgresStore) Backup(ctx context.Context, w io.Writer) (BackupSummary, error) {
+	version := s.DatabaseSchemaVersion()
+	summary := BackupSummary{SchemaVersion: version, Rows: make(map[string]int)}
+
+	// One repeatable-read transaction gives a consistent snapshot
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return summary, fmt.Errorf("failed to start backup: %w", err)
+	}
+	defer tx.Rollback()
+
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	if err := enc.Encode(BackupHeader{Format: backupFormat, SchemaVersion: version, CreatedAt: time.Now().UTC()}); err != nil {
+		return summary, err
+	}
+	for _, table := range backupTables {
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT row_to_json(t) FROM %s t`, table))
+		if err != nil {
+			return summary, fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		for rows.Next() {
+			var row json.RawMessage
+			if err := rows.Scan(&row); err != nil {
+				rows.Close()
+				return summary, err
+			}
+			if err := enc.Encode(backupRow{Table: table, Row: row}); err != nil {
+				rows.Close()
+				return summary, err
+			}
+			summary.Rows[table]++
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return summary, fmt.Errorf("failed to read %s: %w", table, err)
+		}
+	}
+	return summary, zw.Close()
+}
+
+// Restore loads a backup written by Backup in one transaction. The target
+// tables must be empty unless replace is set, which truncates them first.
+// Backups from a newer schema are refused. The cache is reloaded, but
+// objects dropped by a replacing restore stay cached until restart.
+func (s *PostgresStore) Restore(ctx context.Context, r io.Reader, replace bool) (BackupSummary, error) {
+	if s.readOnly {
+		return BackupSummary{}, state.ErrReadOnly
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return BackupSummary{}, fmt.Errorf("%w: not gzip-compressed: %v", ErrInvalidBackup, err)
+	}
+	dec := json.NewDecoder(bufio.NewReader(zr))
+
+	var header BackupHeader
+	if err := dec.Decode(&header); err != nil || header.Format != backupFormat {
+		return BackupSummary{}, fmt.Errorf("%w: missing akari backup header", ErrInvalidBackup)
+	}
+	if current := s.DatabaseSchemaVersion(); header.SchemaVersion > current {
+		return BackupSummary{}, fmt.Errorf("%w: schema version %d is newer than the database's %d; upgrade akari before restoring",
+			ErrInvalidBackup, header.SchemaVersion, current)
+	}
+	summary := BackupSummary{SchemaVersion: header.SchemaVersion, Rows: make(map[string]int)}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return summary, err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		for _, table := range backupTables {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`TRUNCATE %s`, table)); err != nil {
+				return summary, fmt.Errorf("failed to truncate %s: %w", table, err)
+			}
+		}
+	} else {
+		for _, table := range backupTables {
+			var exists bool
+			if err := tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s)`, table)).Scan(&exists); err != nil {
+				return summary, err
+			}
+			if exists {
+				return summary, fmt.Errorf("%w: table %s has rows; restore with replace to overwrite them", ErrRestoreTargetNotEmpty, table)
+			}
+		}
+	}
+
+	known := make(map[string]bool, len(backupTables))
+	for _, table := range backupTables {
+		known[table] = true
+	}
+	for {
+		var line backupRow
+		if err := dec.Decode(&line); err == io.EOF {
+			break
+		} else if err != nil {
+			return summary, fmt.Errorf("%w: corrupt after %d rows: %v", ErrInvalidBackup, summary.total(), err)
+		}
+		if !known[line.Table] {
+			return summary, fmt.Errorf("%w: unknown table %q", ErrInvalidBackup, line.Table)
+		}
+		// Columns missing from backups of older schemas are left NULL
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(
+			`INSERT INTO %[1]s SELECT * FROM json_populate_record(NULL::%[1]s, $1)`, line.Table), []byte(line.Row))
+		if err != nil {
+			return summary, fmt.Errorf("failed to restore %s row: %w", line.Table, err)
+		}
+		summary.Rows[line.Table]++
+	}
+
+	for _, table := range serialTables {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(
+			`SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %[1]s`, table))
+		if err != nil {
+			return summary, fmt.Errorf("failed to reset %s sequence: %w", table, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return summary, err
+	}
+
+	if err := s.loadCache(); err != nil {
+		return summary, fmt.Errorf("restored, but failed to reload cache: %w", err)
+	}
+	return summary, nil
+}
+
+func (b BackupSummary) total() int {
+	total := 0
+	for _, n := range b.Rows {
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestRestore_RejectsInvalidBackups(t *testing.T) {
+	store := &PostgresStore{}
+	if _, err := store.Restore(context.Background(), bytes.NewBufferString("not gzip"), false); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("Expected ErrInvalidBackup for plain text, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"format":"pg_dump"}` + "\n"))
+	zw.Close()
+	if _, err := store.Restore(context.Background(), &buf, false); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("Expected ErrInvalidBackup for a foreign header, got %v", err)
+	}
+}
+
+// TestBackupRestore tests that a backup restores every row it holds
+func TestBackupRestore(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	if store == nil {
+		return
+	}
+	defer cleanup()
+
+	event := types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api", Version: "1",
+		Timestamp: time.Now(), Actor: "kubelet",
+		FieldDiff: map[string]interface{}{"status.phase": "Running"},
+	}
+	if err := store.Record(event); err != nil {
+		t.Fatalf("Failed to record event: %v", err)
+	}
+
+	var backup bytes.Buffer
+	summary, err := store.Backup(context.Background(), &backup)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if summary.SchemaVersion != SchemaVersion || summary.Rows["objects"] != 1 || summary.Rows["object_versions"] != 1 {
+		t.Fatalf("Unexpected backup summary %+v", summary)
+	}
+
+	data := backup.Bytes()
+	if _, err := store.Restore(context.Background(), bytes.NewReader(data), false); !errors.Is(err, ErrRestoreTargetNotEmpty) {
+		t.Fatalf("Expected ErrRestoreTargetNotEmpty, got %v", err)
+	}
+	restored, err := store.Restore(context.Background(), bytes.NewReader(data), true)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if restored.Rows["objects"] != 1 || restored.Rows["field_diffs"] != summary.Rows["field_diffs"] {
+		t.Errorf("Expected the backed up rows restored, got %+v", restored)
+	}
+	if _, exists := store.GetByUID("pod-1"); !exists {
+		t.Error("Expected pod-1 in the cache after restoring")
+	}
+}
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"github.com/aonescu/akari/internal/types"
+)
+
+// ReadLatestByKind bypasses the cache, returning the latest recorded
+// version of every object of kind not yet forgotten. Events buffered during
+// an outage are not included until replayed.
+func (s *PostgresStore) ReadLatestByKind(kind string) ([]types.StateEvent, error) {
+	return s.queryVersions(`(
+		SELECT DISTINCT ON (v.uid) v.uid, v.resource_version, v.timestamp, v.actor
+		FROM object_versions v
+		JOIN objects o ON o.uid = v.uid
+		WHERE o.kind = $1 AND o.deleted_at IS NULL
+		ORDER BY v.uid, v.timestamp DESC
+	)`, `ORDER BY o.namespace, o.name`, kind)
+}
+
+// ReadByUID bypasses the cache, returning the latest recorded version of
+// uid unless it was forgotten
+func (s *PostgresStore) ReadByUID(uid string) (types.StateEvent, bool, error) {
+	events, err := s.queryVersions(`(
+		SELECT v.uid, v.resource_version, v.timestamp, v.actor
+		FROM object_versions v
+		JOIN objects o ON o.uid = v.uid
+		WHERE v.uid = $1 AND o.deleted_at IS NULL
+		ORDER BY v.timestamp DESC
+		LIMIT 1
+	)`, "", uid)
+	if err != nil || len(events) == 0 {
+		return types.StateEvent{}, false, err
+	}
+	return events[0], true, nil
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestConsistentReads(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	if store == nil {
+		return
+	}
+	defer cleanup()
+
+	now := time.Now()
+	for i, phase := range []string{"Pending", "Running"} {
+		event := types.StateEvent{
+			UID:       "pod-consistent",
+			Kind:      "Pod",
+			Namespace: "default",
+			Name:      "web",
+			Version:   string(rune('1' + i)),
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+			FieldDiff: map[string]interface{}{"status.phase": phase},
+			Actor:     "kubelet",
+		}
+		if err := store.Record(event); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+
+	pods, err := store.ReadLatestByKind("Pod")
+	if err != nil {
+		t.Fatalf("ReadLatestByKind failed: %v", err)
+	}
+	if len(pods) != 1 || pods[0].Version != "2" || pods[0].FieldDiff["status.phase"] != "Running" {
+		t.Errorf("Expected the latest version of the pod, got %+v", pods)
+	}
+
+	pod, exists, err := store.ReadByUID("pod-consistent")
+	if err != nil || !exists || pod.Version != "2" {
+		t.Errorf("Expected version 2 of the pod, got %+v (exists %v, err %v)", pod, exists, err)
+	}
+	if _, exists, err := store.ReadByUID("non-existent"); err != nil || exists {
+		t.Errorf("Expected no pod, got exists %v, err %v", exists, err)
+	}
+}
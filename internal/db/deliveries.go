@@ -0,0 +1,126 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/aonescu/akari/internal/paging"
+	"github.com/aonescu/akari/internal/state"
+)
+
+// RecordDelivery stores an attempt to send an incident action and drops
+// attempts older than retention
+func (s *PostgresStore) RecordDelivery(d paging.Delivery, retention time.Duration) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+	if _, err := s.db.Exec(`DELETE FROM notification_deliveries WHERE attempted_at < $1`, time.Now().Add(-retention)); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO notification_deliveries (key, client, action, attempt, error, attempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, d.Key, d.Client, d.Action, d.Attempt, nullString(d.Error), d.At)
+	return err
+}
+
+// Deliveries returns the attempts for an incident key, oldest first
+func (s *PostgresStore) Deliveries(key string) ([]paging.Delivery, error) {
+	rows, err := s.db.Query(`
+		SELECT key, client, action, attempt, error, attempted_at
+		FROM notification_deliveries
+		WHERE key = $1
+		ORDER BY attempted_at, id
+	`, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []paging.Delivery
+	for rows.Next() {
+		var d paging.Delivery
+		var deliveryErr sql.NullString
+		if err := rows.Scan(&d.Key, &d.Client, &d.Action, &d.Attempt, &deliveryErr, &d.At); err != nil {
+			return nil, err
+		}
+		d.Error = deliveryErr.String
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// SaveDeadLetter inserts a new dead letter, or updates the attempts of an
+// existing one
+func (s *PostgresStore) SaveDeadLetter(letter paging.DeadLetter) (paging.DeadLetter, error) {
+	if s.readOnly {
+		return letter, state.ErrReadOnly
+	}
+	incident, err := json.Marshal(letter.Incident)
+	if err != nil {
+		return letter, err
+	}
+	if letter.ID != 0 {
+		res, err := s.db.Exec(`
+			UPDATE notification_dead_letters
+			SET attempts = $2, last_error = $3, failed_at = $4
+			WHERE id = $1
+		`, letter.ID, letter.Attempts, letter.LastError, letter.FailedAt)
+		if err != nil {
+			return letter, err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return letter, paging.ErrDeadLetterNotFound
+		}
+		return letter, nil
+	}
+	err = s.db.QueryRow(`
+		INSERT INTO notification_dead_letters (client, action, incident_key, incident, attempts, last_error, failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, letter.Client, letter.Action, letter.Incident.Key, incident, letter.Attempts, letter.LastError, letter.FailedAt).Scan(&letter.ID)
+	return letter, err
+}
+
+// DeadLetters returns the dead letters, oldest first
+func (s *PostgresStore) DeadLetters() ([]paging.DeadLetter, error) {
+	rows, err := s.db.Query(`
+		SELECT id, client, action, incident, attempts, last_error, failed_at
+		FROM notification_dead_letters
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := []paging.DeadLetter{}
+	for rows.Next() {
+		var letter paging.DeadLetter
+		var incident []byte
+		if err := rows.Scan(&letter.ID, &letter.Client, &letter.Action, &incident, &letter.Attempts, &letter.LastError, &letter.FailedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(incident, &letter.Incident); err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+// DeleteDeadLetter removes a delivered dead letter
+func (s *PostgresStore) DeleteDeadLetter(id int64) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+	res, err := s.db.Exec(`DELETE FROM notification_dead_letters WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return paging.ErrDeadLetterNotFound
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/paging"
+)
+
+// TestDeliveries tests that attempts are listed per incident and dead
+// letters are kept until deleted
+func TestDeliveries(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	if store == nil {
+		return
+	}
+	defer cleanup()
+
+	now := time.Now().Truncate(time.Millisecond)
+	for i, err := range []string{"503", ""} {
+		d := paging.Delivery{Key: "pod_ready|default/api", Client: "pagerduty", Action: "trigger", Attempt: i + 1, Error: err, At: now.Add(time.Duration(i) * time.Second)}
+		if err := store.RecordDelivery(d, time.Hour); err != nil {
+			t.Fatalf("RecordDelivery failed: %v", err)
+		}
+	}
+	deliveries, err := store.Deliveries("pod_ready|default/api")
+	if err != nil || len(deliveries) != 2 {
+		t.Fatalf("Expected 2 deliveries, got %+v, %v", deliveries, err)
+	}
+	if deliveries[0].Error != "503" || deliveries[1].Error != "" || deliveries[1].Attempt != 2 {
+		t.Errorf("Expected a failed then a successful attempt, got %+v", deliveries)
+	}
+
+	letter, err := store.SaveDeadLetter(paging.DeadLetter{
+		Client: "pagerduty", Action: "trigger", Attempts: 3, LastError: "503", FailedAt: now,
+		Incident: paging.Incident{Key: "pod_ready|default/api", Summary: "pod not ready"},
+	})
+	if err != nil || letter.ID == 0 {
+		t.Fatalf("SaveDeadLetter failed: %+v, %v", letter, err)
+	}
+	letter.Attempts = 4
+	if _, err := store.SaveDeadLetter(letter); err != nil {
+		t.Fatalf("SaveDeadLetter update failed: %v", err)
+	}
+	letters, err := store.DeadLetters()
+	if err != nil || len(letters) != 1 || letters[0].Attempts != 4 || letters[0].Incident.Summary != "pod not ready" {
+		t.Fatalf("Expected the updated dead letter, got %+v, %v", letters, err)
+	}
+	if err := store.DeleteDeadLetter(letter.ID); err != nil {
+		t.Fatalf("DeleteDeadLetter failed: %v", err)
+	}
+	if err := store.DeleteDeadLetter(letter.ID); err != paging.ErrDeadLetterNotFound {
+		t.Errorf("Expected ErrDeadLetterNotFound, got %v", err)
+	}
+}
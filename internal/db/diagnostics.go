@@ -0,0 +1,259 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/lib/pq"
+
+	"github.com/aonescu/akari/internal/state"
+)
+
+// btreeFillFactor and btreeTupleOverhead approximate the size of a freshly
+// built btree index, the baseline of the bloat estimate
+const (
+	btreeFillFactor    = 0.9
+	btreeTupleOverhead = 16 // item pointer and index tuple header
+	pageSize           = 8192
+)
+
+// TableStats describes the size of one akari table. Row counts are the
+// planner's estimates, which are cheap on large tables.
+type TableStats struct {
+	Name           string  `json:"name"`
+	TotalBytes     int64   `json:"total_bytes"`
+	TableBytes     int64   `json:"table_bytes"`
+	IndexBytes     int64   `json:"index_bytes"`
+	Rows           int64   `json:"rows"`
+	DeadRows       int64   `json:"dead_rows"`
+	DeadRowRatio   float64 `json:"dead_row_ratio"`
+	LastAutovacuum *string `json:"last_autovacuum,omitempty"`
+}
+
+// IndexStats describes one index of an akari table. EstimatedBloat is the
+// share of the index a fresh build would not need, from column widths in
+// pg_stats; it is zero until the table has been analyzed.
+type IndexStats struct {
+	Name           string  `json:"name"`
+	Table          string  `json:"table"`
+	Bytes          int64   `json:"bytes"`
+	Scans          int64   `json:"scans"`
+	EstimatedBloat float64 `json:"estimated_bloat"`
+}
+
+// ReplicaStats is the replay lag of one streaming replica
+type ReplicaStats struct {
+	Name             string   `json:"name"`
+	State            string   `json:"state"`
+	ReplayLagSeconds *float64 `json:"replay_lag_seconds,omitempty"`
+}
+
+// ReplicationStats reports the lag behind the primary when connected to a
+// standby, or the lag of each replica the role may see when connected to
+// a primary
+type ReplicationStats struct {
+	InRecovery bool           `json:"in_recovery"`
+	LagSeconds *float64       `json:"lag_seconds,omitempty"`
+	Replicas   []ReplicaStats `json:"replicas"`
+}
+
+// Diagnostics is the health of the akari database and its in-memory cache
+type Diagnostics struct {
+	SchemaVersion int              `json:"schema_version"`
+	DatabaseBytes int64            `json:"database_bytes"`
+	Tables        []TableStats     `json:"tables"`
+	Indexes       []IndexStats     `json:"indexes"`
+	Replication   ReplicationStats `json:"replication"`
+	Cache         state.IndexStats `json:"cache"`
+	Warnings      []string         `json:"warnings"`
+}
+
+// diagnosedTables are the tables whose size is reported; the partitions
+// of violations are summed into it
+var diagnosedTables = append(append([]string{}, backupTables...), "idempotency_keys")
+
+// Diagnostics reports table and index sizes, replication lag and cache
+// statistics. Statistics a role may not read are left out with a warning.
+func (s *PostgresStore) Diagnostics(ctx context.Context) (Diagnostics, error) {
+	diag := Diagnostics{
+		SchemaVersion: s.DatabaseSchemaVersion(),
+		Cache:         s.cache.Stats(),
+		Warnings:      make([]string, 0),
+	}
+
+	if err := s.db.QueryRowContext(ctx, `SELECT pg_database_size(current_database())`).Scan(&diag.DatabaseBytes); err != nil {
+		return diag, fmt.Errorf("failed to read database size: %w", err)
+	}
+
+	tables, err := s.tableStats(ctx)
+	if err != nil {
+		return diag, err
+	}
+	diag.Tables = tables
+
+	indexes, err := s.indexStats(ctx)
+	if err != nil {
+		return diag, err
+	}
+	diag.Indexes = indexes
+
+	diag.Replication, err = s.replicationStats(ctx)
+	if err != nil {
+		diag.Warnings = append(diag.Warnings, fmt.Sprintf("replication statistics unavailable: %v", err))
+	}
+
+	for _, t := range diag.Tables {
+		if t.DeadRowRatio > 0.2 && t.DeadRows > 10000 {
+			diag.Warnings = append(diag.Warnings, fmt.Sprintf("%s is %.0f%% dead rows; check autovacuum and retention", t.Name, 100*t.DeadRowRatio))
+		}
+	}
+	for _, i := range diag.Indexes {
+		if i.EstimatedBloat > 0.5 && i.Bytes > 100*pageSize {
+			diag.Warnings = append(diag.Warnings, fmt.Sprintf("index %s is an estimated %.0f%% bloat; consider REINDEX", i.Name, 100*i.EstimatedBloat))
+		}
+	}
+	return diag, nil
+}
+
+func (s *PostgresStore) tableStats(ctx context.Context) ([]TableStats, error) {
+	// Partitions are summed into their parent so violations reports as one table
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT COALESCE(parent.relname, c.relname) AS name,
+			SUM(pg_total_relation_size(c.oid))::BIGINT,
+			SUM(pg_relation_size(c.oid))::BIGINT,
+			SUM(pg_indexes_size(c.oid))::BIGINT,
+			SUM(COALESCE(st.n_live_tup, 0))::BIGINT,
+			SUM(COALESCE(st.n_dead_tup, 0))::BIGINT,
+			MAX(GREATEST(st.last_autovacuum, st.last_vacuum))::TEXT
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_inherits inh ON inh.inhrelid = c.oid
+		LEFT JOIN pg_class parent ON parent.oid = inh.inhparent
+		LEFT JOIN pg_stat_user_tables st ON st.relid = c.oid
+		WHERE n.nspname = current_schema()
+			AND c.relkind IN ('r', 'p')
+			AND COALESCE(parent.relname, c.relname) = ANY($1)
+		GROUP BY 1
+	`, pq.Array(diagnosedTables))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table sizes: %w", err)
+	}
+	defer rows.Close()
+
+	tables := make([]TableStats, 0)
+	for rows.Next() {
+		var t TableStats
+		var vacuumed sql.NullString
+		if err := rows.Scan(&t.Name, &t.TotalBytes, &t.TableBytes, &t.IndexBytes, &t.Rows, &t.DeadRows, &vacuumed); err != nil {
+			return nil, err
+		}
+		if vacuumed.Valid {
+			t.LastAutovacuum = &vacuumed.String
+		}
+		if total := t.Rows + t.DeadRows; total > 0 {
+			t.DeadRowRatio = float64(t.DeadRows) / float64(total)
+		}
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].TotalBytes > tables[j].TotalBytes })
+	return tables, rows.Err()
+}
+
+// indexStats lists the btree indexes of the akari tables, those of each
+// violations partition included
+func (s *PostgresStore) indexStats(ctx context.Context) ([]IndexStats, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT i.relname, t.relname, pg_relation_size(i.oid), COALESCE(st.idx_scan, 0), i.reltuples,
+			COALESCE((
+				SELECT SUM(ps.avg_width)
+				FROM pg_attribute a
+				JOIN pg_stats ps ON ps.schemaname = n.nspname AND ps.tablename = t.relname AND ps.attname = a.attname
+				WHERE a.attrelid = t.oid AND a.attnum = ANY(ix.indkey)
+			), 0)
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN pg_am am ON am.oid = i.relam
+		LEFT JOIN pg_inherits inh ON inh.inhrelid = t.oid
+		LEFT JOIN pg_class parent ON parent.oid = inh.inhparent
+		LEFT JOIN pg_stat_user_indexes st ON st.indexrelid = ix.indexrelid
+		WHERE n.nspname = current_schema()
+			AND COALESCE(parent.relname, t.relname) = ANY($1)
+			AND i.relkind = 'i' AND am.amname = 'btree'
+	`, pq.Array(diagnosedTables))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index sizes: %w", err)
+	}
+	defer rows.Close()
+
+	indexes := make([]IndexStats, 0)
+	for rows.Next() {
+		var i IndexStats
+		var tuples, keyWidth float64
+		if err := rows.Scan(&i.Name, &i.Table, &i.Bytes, &i.Scans, &tuples, &keyWidth); err != nil {
+			return nil, err
+		}
+		i.EstimatedBloat = estimateBloat(i.Bytes, tuples, keyWidth)
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(a, b int) bool { return indexes[a].Bytes > indexes[b].Bytes })
+	return indexes, rows.Err()
+}
+
+// estimateBloat compares an index's size with the size of a fresh btree
+// holding the same tuples
+func estimateBloat(bytes int64, tuples, keyWidth float64) float64 {
+	if bytes <= pageSize || tuples <= 0 || keyWidth <= 0 {
+		return 0
+	}
+	// One metapage, then leaf pages filled to the fill factor
+	perPage := math.Floor(pageSize * btreeFillFactor / (keyWidth + btreeTupleOverhead))
+	expected := (1 + math.Ceil(tuples/perPage)) * pageSize
+	bloat := 1 - expected/float64(bytes)
+	return math.Max(0, math.Round(bloat*100)/100)
+}
+
+func (s *PostgresStore) replicationStats(ctx context.Context) (ReplicationStats, error) {
+	stats := ReplicationStats{Replicas: make([]ReplicaStats, 0)}
+	if err := s.db.QueryRowContext(ctx, `SELECT pg_is_in_recovery()`).Scan(&stats.InRecovery); err != nil {
+		return stats, err
+	}
+
+	if stats.InRecovery {
+		var lag sql.NullFloat64
+		err := s.db.QueryRowContext(ctx, `SELECT EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())`).Scan(&lag)
+		if err != nil {
+			return stats, err
+		}
+		if lag.Valid {
+			stats.LagSeconds = &lag.Float64
+		}
+		return stats, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT COALESCE(application_name, ''), COALESCE(state, ''), EXTRACT(EPOCH FROM replay_lag)
+		FROM pg_stat_replication
+		ORDER BY application_name
+	`)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r ReplicaStats
+		var lag sql.NullFloat64
+		if err := rows.Scan(&r.Name, &r.State, &lag); err != nil {
+			return stats, err
+		}
+		if lag.Valid {
+			r.ReplayLagSeconds = &lag.Float64
+		}
+		stats.Replicas = append(stats.Replicas, r)
+	}
+	return stats, rows.Err()
+}
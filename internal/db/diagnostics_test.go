@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestEstimateBloat(t *testing.T) {
+	// 10000 tuples of 8-byte keys fit in a metapage and 33 leaf pages
+	if bloat := estimateBloat(34*pageSize, 10000, 8); bloat != 0 {
+		t.Errorf("Expected a fresh index to have no bloat, got %v", bloat)
+	}
+	if bloat := estimateBloat(136*pageSize, 10000, 8); bloat < 0.74 || bloat > 0.76 {
+		t.Errorf("Expected a fourfold index to be about 75%% bloat, got %v", bloat)
+	}
+	if bloat := estimateBloat(136*pageSize, 10000, 0); bloat != 0 {
+		t.Errorf("Expected no estimate before the table is analyzed, got %v", bloat)
+	}
+}
+
+// TestDiagnostics tests that every akari table is reported
+func TestDiagnostics(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	if store == nil {
+		return
+	}
+	defer cleanup()
+
+	event := types.StateEvent{UID: "pod-1", Kind: "Pod", Name: "api", Version: "1", Timestamp: time.Now(), Actor: "kubelet"}
+	if err := store.Record(event); err != nil {
+		t.Fatalf("Failed to record event: %v", err)
+	}
+	store.GetByUID("pod-1")
+
+	diag, err := store.Diagnostics(context.Background())
+	if err != nil {
+		t.Fatalf("Diagnostics failed: %v", err)
+	}
+	if diag.SchemaVersion != SchemaVersion || diag.DatabaseBytes == 0 {
+		t.Errorf("Unexpected database summary %+v", diag)
+	}
+	reported := make(map[string]bool)
+	for _, table := range diag.Tables {
+		reported[table.Name] = true
+	}
+	for _, table := range diagnosedTables {
+		if !reported[table] {
+			t.Errorf("Expected %s in the diagnostics", table)
+		}
+	}
+	if diag.Cache.ByKind["Pod"] != 1 || diag.Cache.Hits == 0 {
+		t.Errorf("Expected the cached pod and its lookup, got %+v", diag.Cache)
+	}
+}
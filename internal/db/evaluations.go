@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+)
+
+// RecordEvaluations stores evaluation log entries and drops those older
+// than retention
+func (s *PostgresStore) RecordEvaluations(entries []engine.EvaluationLogEntry, retention time.Duration) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM evaluation_log WHERE evaluated_at < $1`, time.Now().Add(-retention)); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO evaluation_log (invariant_id, uid, status, reason, correlation_id, evaluated_at, duration_ns)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, entry := range entries {
+		if _, err := stmt.Exec(entry.InvariantID, entry.ResourceUID, string(entry.Status), entry.Reason,
+			nullString(entry.CorrelationID), entry.Timestamp, int64(entry.Duration)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// QueryEvaluations returns the selected evaluation log entries, newest first
+func (s *PostgresStore) QueryEvaluations(q engine.EvaluationLogQuery) ([]engine.EvaluationLogEntry, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if q.InvariantID != "" {
+		where("invariant_id = $%d", q.InvariantID)
+	}
+	if q.ResourceUID != "" {
+		where("uid = $%d", q.ResourceUID)
+	}
+	if !q.Since.IsZero() {
+		where("evaluated_at >= $%d", q.Since)
+	}
+	if !q.Until.IsZero() {
+		where("evaluated_at <= $%d", q.Until)
+	}
+	query := `SELECT invariant_id, uid, status, COALESCE(reason, ''), COALESCE(correlation_id, ''), evaluated_at, duration_ns
+		FROM evaluation_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, q.Limit)
+	query += fmt.Sprintf(" ORDER BY evaluated_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]engine.EvaluationLogEntry, 0)
+	for rows.Next() {
+		var entry engine.EvaluationLogEntry
+		var status string
+		var duration int64
+		if err := rows.Scan(&entry.InvariantID, &entry.ResourceUID, &status, &entry.Reason,
+			&entry.CorrelationID, &entry.Timestamp, &duration); err != nil {
+			return nil, err
+		}
+		entry.Status = engine.EvaluationStatus(status)
+		entry.Result = entry.Status != engine.StatusViolated
+		entry.Duration = time.Duration(duration)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+)
+
+// TestEvaluationLog tests that evaluation log entries are filtered by
+// invariant, resource and time, newest first
+func TestEvaluationLog(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	if store == nil {
+		return
+	}
+	defer cleanup()
+
+	now := time.Now().Truncate(time.Millisecond)
+	entries := []engine.EvaluationLogEntry{
+		{InvariantID: "pod_ready", ResourceUID: "pod-1", Status: engine.StatusViolated, Reason: "not ready", CorrelationID: "req-1", Timestamp: now.Add(-2 * time.Minute)},
+		{InvariantID: "pod_ready", ResourceUID: "pod-1", Status: engine.StatusSatisfied, Reason: "satisfied", Timestamp: now.Add(-time.Minute)},
+		{InvariantID: "pod_ready", ResourceUID: "pod-2", Status: engine.StatusSatisfied, Reason: "satisfied", Timestamp: now},
+		{InvariantID: "pod_scheduled", ResourceUID: "pod-1", Status: engine.StatusSatisfied, Reason: "satisfied", Timestamp: now},
+	}
+	if err := store.RecordEvaluations(entries, time.Hour); err != nil {
+		t.Fatalf("RecordEvaluations failed: %v", err)
+	}
+
+	got, err := store.QueryEvaluations(engine.EvaluationLogQuery{InvariantID: "pod_ready", ResourceUID: "pod-1", Limit: 10})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Expected 2 entries, got %+v, %v", got, err)
+	}
+	if got[0].Status != engine.StatusSatisfied || got[1].CorrelationID != "req-1" || got[1].Result {
+		t.Errorf("Expected the newest entry first, got %+v", got)
+	}
+
+	got, err = store.QueryEvaluations(engine.EvaluationLogQuery{Since: now.Add(-30 * time.Second), Limit: 10})
+	if err != nil || len(got) != 2 {
+		t.Errorf("Expected 2 entries since 30s ago, got %+v, %v", got, err)
+	}
+}
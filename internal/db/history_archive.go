@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/aonescu/akari/internal/coldstore"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/lib/pq"
+)
+
+// SetColdArchive makes time-travel queries reaching past the archive's
+// horizon read the versions moved there back in
+func (s *PostgresStore) SetColdArchive(archive *coldstore.Archive) {
+	s.cold = archive
+}
+
+// AgedVersions returns up to limit object versions recorded before cutoff,
+// oldest first. Every object's latest version before the cutoff stays hot,
+// so snapshots of any later time never need the archive.
+func (s *PostgresStore) AgedVersions(cutoff time.Time, limit int) ([]types.StateEvent, error) {
+	return s.queryVersions(`(
+		SELECT o.uid, o.resource_version, o.timestamp, o.actor
+		FROM object_versions o
+		WHERE o.timestamp < $1
+		  AND EXISTS (SELECT 1 FROM object_versions n WHERE n.uid = o.uid AND n.timestamp > o.timestamp AND n.timestamp < $1)
+		ORDER BY o.timestamp ASC
+		LIMIT $2
+	)`, "ORDER BY v.timestamp ASC", cutoff, limit)
+}
+
+// DeleteVersions removes archived object versions and their field diffs
+func (s *PostgresStore) DeleteVersions(events []types.StateEvent) error {
+	uids := make([]string, len(events))
+	versions := make([]string, len(events))
+	for i, e := range events {
+		uids[i], versions[i] = e.UID, e.Version
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"field_diffs", "object_versions"} {
+		if _, err := tx.Exec(`
+			DELETE FROM `+table+` WHERE (uid, resource_version) IN (
+				SELECT * FROM unnest($1::text[], $2::text[])
+			)`, pq.Array(uids), pq.Array(versions)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// archivedBefore returns the cutoff history was archived up to, zero when
+// no archive is set. Queries of earlier times read the archive too.
+func (s *PostgresStore) archivedBefore() (time.Time, error) {
+	if s.cold == nil {
+		return time.Time{}, nil
+	}
+	m, err := s.cold.Manifest(context.Background())
+	if err != nil {
+		return time.Time{}, err
+	}
+	return m.ArchivedBefore, nil
+}
+
+// mergeVersions combines archived and hot versions, oldest first, dropping
+// versions present in both
+func mergeVersions(cold, hot []types.StateEvent) []types.StateEvent {
+	if len(cold) == 0 {
+		return hot
+	}
+	seen := make(map[string]bool, len(hot))
+	for _, e := range hot {
+		seen[e.UID+"@"+e.Version] = true
+	}
+	merged := make([]types.StateEvent, 0, len(cold)+len(hot))
+	for _, e := range cold {
+		if !seen[e.UID+"@"+e.Version] {
+			merged = append(merged, e)
+		}
+	}
+	merged = append(merged, hot...)
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Timestamp.Before(merged[j].Timestamp) })
+	return merged
+}
+
+// mergeSnapshots keeps the newest version of every object from an
+// archived and a hot snapshot
+func mergeSnapshots(cold, hot []types.StateEvent) []types.StateEvent {
+	if len(cold) == 0 {
+		return hot
+	}
+	latest := make(map[string]types.StateEvent, len(hot))
+	for _, e := range cold {
+		if current, ok := latest[e.UID]; !ok || e.Timestamp.After(current.Timestamp) {
+			latest[e.UID] = e
+		}
+	}
+	for _, e := range hot {
+		if current, ok := latest[e.UID]; !ok || !e.Timestamp.Before(current.Timestamp) {
+			latest[e.UID] = e
+		}
+	}
+	merged := make([]types.StateEvent, 0, len(latest))
+	for _, e := range latest {
+		merged = append(merged, e)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].UID < merged[j].UID })
+	return merged
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/coldstore"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestMergeVersions(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cold := []types.StateEvent{
+		{UID: "pod-1", Version: "1", Timestamp: start},
+		{UID: "pod-1", Version: "2", Timestamp: start.Add(time.Minute)},
+	}
+	hot := []types.StateEvent{
+		{UID: "pod-1", Version: "2", Timestamp: start.Add(time.Minute)},
+		{UID: "pod-1", Version: "3", Timestamp: start.Add(2 * time.Minute)},
+	}
+
+	merged := mergeVersions(cold, hot)
+	if len(merged) != 3 || merged[0].Version != "1" || merged[1].Version != "2" || merged[2].Version != "3" {
+		t.Errorf("Expected versions 1-3 once each, oldest first, got %+v", merged)
+	}
+	if got := mergeVersions(nil, hot); len(got) != 2 {
+		t.Errorf("Expected hot versions unchanged without an archive, got %+v", got)
+	}
+}
+
+func TestMergeSnapshots(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cold := []types.StateEvent{
+		{UID: "pod-1", Version: "1", Timestamp: start},
+		{UID: "pod-1", Version: "2", Timestamp: start.Add(time.Minute)},
+		{UID: "pod-2", Version: "1", Timestamp: start},
+	}
+	hot := []types.StateEvent{
+		{UID: "pod-2", Version: "2", Timestamp: start.Add(time.Minute)},
+	}
+
+	merged := mergeSnapshots(cold, hot)
+	if len(merged) != 2 || merged[0].UID != "pod-1" || merged[0].Version != "2" || merged[1].Version != "2" {
+		t.Errorf("Expected the newest version of each object, got %+v", merged)
+	}
+}
+
+func TestColdArchive_TimeTravel(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	if store == nil {
+		return
+	}
+	defer cleanup()
+
+	start := time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Second)
+	for i, phase := range []string{"Pending", "Running", "Failed"} {
+		store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "api", Version: string(rune('1' + i)),
+			Timestamp: start.Add(time.Duration(i) * time.Hour), FieldDiff: map[string]interface{}{"status.phase": phase}})
+	}
+	archive := coldstore.NewArchive(coldstore.DirBucket(t.TempDir()), "")
+	store.SetColdArchive(archive)
+	archiver := &coldstore.Archiver{Archive: archive, Source: store}
+
+	n, err := archiver.ArchiveBefore(context.Background(), start.Add(90*time.Minute))
+	if err != nil {
+		t.Fatalf("ArchiveBefore failed: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Expected only the version superseded before the cutoff archived, got %d", n)
+	}
+
+	snapshot, err := store.SnapshotAt(start.Add(time.Minute), "pod-1")
+	if err != nil || len(snapshot) != 1 || snapshot[0].FieldDiff["status.phase"] != "Pending" {
+		t.Errorf("Expected the archived Pending version, got %+v (%v)", snapshot, err)
+	}
+	events, err := store.EventsBetween(start.Add(-time.Minute), start.Add(3*time.Hour), "pod-1")
+	if err != nil || len(events) != 3 {
+		t.Errorf("Expected all 3 versions across archive and database, got %+v (%v)", events, err)
+	}
+}
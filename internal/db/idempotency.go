@@ -0,0 +1,46 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/aonescu/akari/internal/idempotency"
+	"github.com/aonescu/akari/internal/state"
+)
+
+// GetIdempotentResponse returns the response recorded for key within the
+// retention
+func (s *PostgresStore) GetIdempotentResponse(key string, retention time.Duration) (*idempotency.Response, bool, error) {
+	resp := idempotency.Response{Key: key}
+	var contentType sql.NullString
+	err := s.db.QueryRow(`
+		SELECT fingerprint, status, content_type, body, created_at
+		FROM idempotency_keys
+		WHERE key = $1 AND created_at > $2
+	`, key, time.Now().Add(-retention)).Scan(&resp.Fingerprint, &resp.Status, &contentType, &resp.Body, &resp.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	resp.ContentType = contentType.String
+	return &resp, true, nil
+}
+
+// SaveIdempotentResponse records the first response to a key and drops
+// expired keys. A key recorded concurrently by another replica wins.
+func (s *PostgresStore) SaveIdempotentResponse(resp idempotency.Response, retention time.Duration) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+	if _, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE created_at < $1`, time.Now().Add(-retention)); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO idempotency_keys (key, fingerprint, status, content_type, body, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (key) DO NOTHING
+	`, resp.Key, resp.Fingerprint, resp.Status, nullString(resp.ContentType), resp.Body, resp.CreatedAt)
+	return err
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/idempotency"
+)
+
+// TestIdempotentResponses tests that the first response to a key is kept
+// until it expires
+func TestIdempotentResponses(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	if store == nil {
+		return
+	}
+	defer cleanup()
+
+	resp := idempotency.Response{
+		Key: "retry-1", Fingerprint: "abc", Status: 201,
+		ContentType: "application/json", Body: []byte(`{"id":"x"}`), CreatedAt: time.Now(),
+	}
+	if err := store.SaveIdempotentResponse(resp, time.Hour); err != nil {
+		t.Fatalf("SaveIdempotentResponse failed: %v", err)
+	}
+	resp.Status = 500
+	if err := store.SaveIdempotentResponse(resp, time.Hour); err != nil {
+		t.Fatalf("SaveIdempotentResponse failed: %v", err)
+	}
+
+	stored, found, err := store.GetIdempotentResponse("retry-1", time.Hour)
+	if err != nil || !found {
+		t.Fatalf("Expected the stored response, got %v %v", found, err)
+	}
+	if stored.Status != 201 || string(stored.Body) != `{"id":"x"}` || stored.ContentType != "application/json" {
+		t.Errorf("Expected the first response to win, got %+v", stored)
+	}
+
+	if _, found, _ := store.GetIdempotentResponse("retry-1", time.Nanosecond); found {
+		t.Error("Expected an expired key to be ignored")
+	}
+}
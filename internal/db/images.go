@@ -0,0 +1,143 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+const (
+	templateImagePrefix = "spec.template.spec.containers["
+	podImagePrefix      = "spec.containers["
+	imageSuffix         = "].image"
+)
+
+// ImageChange is one container image rollout of a Deployment
+type ImageChange struct {
+	DeploymentUID string    `json:"deployment_uid"`
+	Namespace     string    `json:"namespace"`
+	Name          string    `json:"name"`
+	Container     string    `json:"container"`
+	Image         string    `json:"image"`
+	PreviousImage string    `json:"previous_image,omitempty"`
+	Version       string    `json:"version"`
+	Actor         string    `json:"actor"`
+	ChangedAt     time.Time `json:"changed_at"`
+}
+
+// containerImages extracts container name -> image from fields shaped like
+// <prefix><container>].image, e.g. spec.template.spec.containers[api].image
+func containerImages(fieldDiff map[string]interface{}, prefix string) map[string]string {
+	images := make(map[string]string)
+	for field, value := range fieldDiff {
+		if !strings.HasPrefix(field, prefix) || !strings.HasSuffix(field, imageSuffix) {
+			continue
+		}
+		container := strings.TrimSuffix(strings.TrimPrefix(field, prefix), imageSuffix)
+		if image, ok := value.(string); ok && container != "" {
+			images[container] = image
+		}
+	}
+	return images
+}
+
+// PodImages returns the container images recorded on a Pod event
+func PodImages(event types.StateEvent) map[string]string {
+	return containerImages(event.FieldDiff, podImagePrefix)
+}
+
+// recordImageChanges appends a deployment_images row for every container
+// whose template image differs from the last recorded one
+func (s *PostgresStore) recordImageChanges(tx *sql.Tx, event types.StateEvent) error {
+	if event.Kind != "Deployment" {
+		return nil
+	}
+
+	images := containerImages(event.FieldDiff, templateImagePrefix)
+	containers := make([]string, 0, len(images))
+	for container := range images {
+		containers = append(containers, container)
+	}
+	sort.Strings(containers)
+
+	for _, container := range containers {
+		image := images[container]
+
+		var previous sql.NullString
+		err := tx.QueryRow(`
+			SELECT image FROM deployment_images
+			WHERE uid = $1 AND container = $2
+			ORDER BY changed_at DESC, id DESC
+			LIMIT 1
+		`, event.UID, container).Scan(&previous)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to read previous image: %w", err)
+		}
+		if previous.Valid && previous.String == image {
+			continue
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO deployment_images (
+				uid, namespace, name, container, image, previous_image,
+				resource_version, actor, changed_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, event.UID, event.Namespace, event.Name, container, image, previous,
+			event.Version, event.Actor, event.Timestamp)
+		if err != nil {
+			return fmt.Errorf("failed to insert image change: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetImageHistory returns the image rollouts of a Deployment, newest first
+func (s *PostgresStore) GetImageHistory(namespace, name string, limit int) ([]ImageChange, error) {
+	return s.queryImageChanges(`
+		SELECT uid, namespace, name, container, image, COALESCE(previous_image, ''),
+		       COALESCE(resource_version, ''), COALESCE(actor, ''), changed_at
+		FROM deployment_images
+		WHERE namespace = $1 AND name = $2
+		ORDER BY changed_at DESC, id DESC
+		LIMIT $3
+	`, namespace, name, limit)
+}
+
+// GetImageChangesByImage returns the rollouts in a namespace that introduced
+// the given image, newest first
+func (s *PostgresStore) GetImageChangesByImage(namespace, image string) ([]ImageChange, error) {
+	return s.queryImageChanges(`
+		SELECT uid, namespace, name, container, image, COALESCE(previous_image, ''),
+		       COALESCE(resource_version, ''), COALESCE(actor, ''), changed_at
+		FROM deployment_images
+		WHERE namespace = $1 AND image = $2
+		ORDER BY changed_at DESC, id DESC
+	`, namespace, image)
+}
+
+func (s *PostgresStore) queryImageChanges(query string, args ...interface{}) ([]ImageChange, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]ImageChange, 0)
+	for rows.Next() {
+		var c ImageChange
+		if err := rows.Scan(
+			&c.DeploymentUID, &c.Namespace, &c.Name, &c.Container, &c.Image,
+			&c.PreviousImage, &c.Version, &c.Actor, &c.ChangedAt,
+		); err != nil {
+			continue
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestContainerImages(t *testing.T) {
+	fieldDiff := map[string]interface{}{
+		"spec.template.spec.containers[api].image":     "registry.local/api:v2",
+		"spec.template.spec.containers[sidecar].image": "envoy:1.29",
+		"spec.replicas": 3,
+	}
+
+	images := containerImages(fieldDiff, templateImagePrefix)
+	if len(images) != 2 {
+		t.Fatalf("Expected 2 images, got %d", len(images))
+	}
+	if images["api"] != "registry.local/api:v2" {
+		t.Errorf("Unexpected api image: %s", images["api"])
+	}
+
+	pod := types.StateEvent{FieldDiff: map[string]interface{}{"spec.containers[api].image": "registry.local/api:v2"}}
+	if PodImages(pod)["api"] != "registry.local/api:v2" {
+		t.Error("Expected pod image to be extracted")
+	}
+}
+
+// TestDeploymentImageHistory tests that only image changes are recorded
+func TestDeploymentImageHistory(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	if store == nil {
+		return
+	}
+	defer cleanup()
+
+	record := func(version, image string, offset time.Duration) {
+		event := types.StateEvent{
+			UID:       "deploy-1",
+			Kind:      "Deployment",
+			Namespace: "prod",
+			Name:      "api",
+			Version:   version,
+			Timestamp: time.Now().Add(offset),
+			FieldDiff: map[string]interface{}{"spec.template.spec.containers[api].image": image},
+			Actor:     "ci-pipeline",
+		}
+		if err := store.Record(event); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+
+	record("1", "api:v1", 0)
+	record("2", "api:v1", time.Second) // resync, no change
+	record("3", "api:v2", 2*time.Second)
+
+	history, err := store.GetImageHistory("prod", "api", 10)
+	if err != nil {
+		t.Fatalf("Failed to get image history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 image changes, got %d", len(history))
+	}
+	if history[0].Image != "api:v2" || history[0].PreviousImage != "api:v1" {
+		t.Errorf("Unexpected latest change: %+v", history[0])
+	}
+
+	changes, err := store.GetImageChangesByImage("prod", "api:v2")
+	if err != nil {
+		t.Fatalf("Failed to look up changes by image: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Version != "3" {
+		t.Errorf("Expected the v2 rollout, got %+v", changes)
+	}
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+const (
+	// recordTimeout bounds the transaction of one recorded event
+	recordTimeout = 10 * time.Second
+	// DefaultOutageBufferSize is how many events are held while the
+	// database is unreachable; the oldest are dropped beyond it
+	DefaultOutageBufferSize = 100000
+
+	outageRetryMin = time.Second
+	outageRetryMax = time.Minute
+	pingTimeout    = 3 * time.Second
+)
+
+// OutageStatus reports whether writes are being buffered because the
+// database is unreachable
+type OutageStatus struct {
+	Degraded bool       `json:"degraded"`
+	Since    *time.Time `json:"since,omitempty"`
+	Buffered int        `json:"buffered_events"`
+	Dropped  uint64     `json:"dropped_events"`
+	// Replayed counts buffered events written since startup
+	Replayed uint64 `json:"replayed_events"`
+}
+
+// outageBuffer is a circuit breaker around event writes. A write failing
+// while the database doesn't answer a ping opens it: later events are
+// buffered in memory without touching the database, a background loop
+// pings with backoff, and once the database answers the buffer is
+// replayed in order before the breaker closes.
+type outageBuffer struct {
+	ping  func(ctx context.Context) error
+	size  int
+	retry time.Duration // first reconnection delay, doubling up to outageRetryMax
+
+	mu       sync.Mutex
+	open     bool
+	since    time.Time
+	events   []types.StateEvent
+	dropped  uint64
+	replayed uint64
+}
+
+func newOutageBuffer(ping func(ctx context.Context) error) *outageBuffer {
+	return &outageBuffer{ping: ping, size: DefaultOutageBufferSize, retry: outageRetryMin}
+}
+
+// record writes event, or buffers it while the breaker is open. Errors of
+// a reachable database, such as constraint violations, are returned.
+func (b *outageBuffer) record(event types.StateEvent, write func(types.StateEvent) error) error {
+	b.mu.Lock()
+	if b.open {
+		b.buffer(event)
+		b.mu.Unlock()
+		return nil
+	}
+	b.mu.Unlock()
+
+	err := write(event)
+	if err == nil || b.reachable() {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buffer(event)
+	if !b.open {
+		b.open = true
+		b.since = time.Now()
+		log.Printf("Database unreachable, buffering events in memory: %v", err)
+		go b.recover(write)
+	}
+	return nil
+}
+
+// buffer appends event, dropping the oldest beyond the buffer size.
+// Callers hold b.mu.
+func (b *outageBuffer) buffer(event types.StateEvent) {
+	if len(b.events) >= b.size {
+		b.events = b.events[1:]
+		b.dropped++
+	}
+	b.events = append(b.events, event)
+}
+
+func (b *outageBuffer) reachable() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	return b.ping(ctx) == nil
+}
+
+// recover waits for the database to answer, replays the buffer and
+// closes the breaker
+func (b *outageBuffer) recover(write func(types.StateEvent) error) {
+	delay := b.retry
+	for {
+		time.Sleep(delay)
+		delay = min(2*delay, outageRetryMax)
+		if !b.reachable() {
+			continue
+		}
+		if b.replay(write) {
+			return
+		}
+	}
+}
+
+// replay writes the buffered events oldest first, closing the breaker once
+// the buffer is empty. It stops at the first failure, keeping the rest.
+func (b *outageBuffer) replay(write func(types.StateEvent) error) bool {
+	for {
+		b.mu.Lock()
+		if len(b.events) == 0 {
+			b.open = false
+			outage := time.Since(b.since).Round(time.Second)
+			b.mu.Unlock()
+			log.Printf("Database reachable again after %s, buffered events replayed", outage)
+			return true
+		}
+		event := b.events[0]
+		b.mu.Unlock()
+
+		err := write(event)
+		if err != nil && !b.reachable() {
+			return false
+		}
+
+		b.mu.Lock()
+		b.events = b.events[1:]
+		if err != nil {
+			// The event itself is bad; replaying it again won't help
+			log.Printf("Dropping buffered event %s: %v", event.UID, err)
+			b.dropped++
+		} else {
+			b.replayed++
+		}
+		b.mu.Unlock()
+	}
+}
+
+func (b *outageBuffer) status() OutageStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := OutageStatus{Degraded: b.open, Buffered: len(b.events), Dropped: b.dropped, Replayed: b.replayed}
+	if b.open {
+		since := b.since
+		status.Since = &since
+	}
+	return status
+}
+
+// Outage reports whether events are being buffered because the database
+// is unreachable
+func (s *PostgresStore) Outage() OutageStatus {
+	return s.outage.status()
+}
+
+func (s *PostgresStore) pingContext(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+// fakeDatabase fails writes and pings while down
+type fakeDatabase struct {
+	down    atomic.Bool
+	mu      sync.Mutex
+	written []string
+}
+
+func (d *fakeDatabase) ping(context.Context) error {
+	if d.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (d *fakeDatabase) write(event types.StateEvent) error {
+	if d.down.Load() {
+		return errors.New("connection refused")
+	}
+	if event.Name == "" {
+		return errors.New("name is required")
+	}
+	d.mu.Lock()
+	d.written = append(d.written, event.UID)
+	d.mu.Unlock()
+	return nil
+}
+
+func TestOutageBuffer(t *testing.T) {
+	database := &fakeDatabase{}
+	buffer := newOutageBuffer(database.ping)
+	buffer.retry = 5 * time.Millisecond
+	buffer.size = 3
+
+	// A reachable database's errors are the caller's
+	if err := buffer.record(types.StateEvent{UID: "bad"}, database.write); err == nil {
+		t.Error("Expected the write error of a reachable database")
+	}
+
+	database.down.Store(true)
+	for _, uid := range []string{"a", "b", "c", "d"} {
+		if err := buffer.record(types.StateEvent{UID: uid, Name: uid}, database.write); err != nil {
+			t.Fatalf("Expected %s buffered, got %v", uid, err)
+		}
+	}
+	status := buffer.status()
+	if !status.Degraded || status.Since == nil || status.Buffered != 3 || status.Dropped != 1 {
+		t.Fatalf("Expected three buffered events and the oldest dropped, got %+v", status)
+	}
+
+	database.down.Store(false)
+	deadline := time.Now().Add(2 * time.Second)
+	for buffer.status().Degraded && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	status = buffer.status()
+	if status.Degraded || status.Buffered != 0 || status.Replayed != 3 {
+		t.Fatalf("Expected the buffer replayed and the breaker closed, got %+v", status)
+	}
+	database.mu.Lock()
+	defer database.mu.Unlock()
+	if len(database.written) != 3 || database.written[0] != "b" || database.written[2] != "d" {
+		t.Errorf("Expected b, c and d replayed in order, got %v", database.written)
+	}
+}
@@ -6,21 +6,51 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"sync"
+	"slices"
+	"sync/atomic"
+	"time"
 
+	"github.com/aonescu/akari/internal/coldstore"
+	"github.com/aonescu/akari/internal/criticality"
+	"github.com/aonescu/akari/internal/dsl"
 	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
 	"github.com/aonescu/akari/internal/types"
+	"github.com/lib/pq"
 )
 
 type PostgresStore struct {
 	db *sql.DB
-	mu sync.RWMutex
-	// In-memory cache for fast reads
-	latestByUID map[string]types.StateEvent
-	uidsByKind  map[string][]string
+	// In-memory cache for fast reads, sharded by kind
+	cache    *state.LatestIndex
+	readOnly bool
+	// cold holds history archived out of object_versions, if any
+	cold       *coldstore.Archive
+	identities *state.IdentityIndex
+	// outage buffers events while the database is unreachable
+	outage *outageBuffer
+	// recording holds a UID's lock across the write and the cache update,
+	// so the cache keeps the version committed last
+	recording state.UIDLocks
+
+	skipUnchanged atomic.Bool
+	skipped       atomic.Uint64
+	exclusions    atomic.Pointer[state.FieldExclusions]
+
+	state.RecordHooks
 }
 
 func NewPostgresStore(connStr string) (*PostgresStore, error) {
+	return openPostgresStore(connStr, false)
+}
+
+// NewReadOnlyPostgresStore connects to an existing akari database without
+// touching the schema; every write returns state.ErrReadOnly.
+func NewReadOnlyPostgresStore(connStr string) (*PostgresStore, error) {
+	return openPostgresStore(connStr, true)
+}
+
+func openPostgresStore(connStr string, readOnly bool) (*PostgresStore, error) {
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
@@ -31,13 +61,17 @@ func NewPostgresStore(connStr string) (*PostgresStore, error) {
 	}
 
 	store := &PostgresStore{
-		db:          db,
-		latestByUID: make(map[string]types.StateEvent),
-		uidsByKind:  make(map[string][]string),
+		db:         db,
+		cache:      state.NewLatestIndex(),
+		identities: state.NewIdentityIndex(),
+		readOnly:   readOnly,
 	}
+	store.outage = newOutageBuffer(store.pingContext)
 
-	if err := store.initSchema(); err != nil {
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	if !readOnly {
+		if err := store.initSchema(); err != nil {
+			return nil, fmt.Errorf("failed to initialize schema: %w", err)
+		}
 	}
 
 	// Load recent state into cache
@@ -115,52 +149,224 @@ func (s *PostgresStore) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_evaluations_status ON invariant_evaluations(status);
 	CREATE INDEX IF NOT EXISTS idx_evaluations_timestamp ON invariant_evaluations(last_evaluated DESC);
 
-	-- Violations: active failures
-	CREATE TABLE IF NOT EXISTS violations (
-		violation_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	-- Invariant versions: every definition ever registered
+	CREATE TABLE IF NOT EXISTS invariant_versions (
 		invariant_id TEXT NOT NULL,
+		version INT NOT NULL,
+		definition JSONB NOT NULL,
+		created_at TIMESTAMP DEFAULT NOW(),
+		PRIMARY KEY (invariant_id, version)
+	);
+
+	-- Deployment images: container image rollout history
+	CREATE TABLE IF NOT EXISTS deployment_images (
+		id SERIAL PRIMARY KEY,
 		uid TEXT NOT NULL,
-		resource_kind TEXT NOT NULL,
-		resource_name TEXT NOT NULL,
 		namespace TEXT,
-		detected_at TIMESTAMP NOT NULL,
-		responsible_actor TEXT,
-		eliminated_actors JSONB,
+		name TEXT NOT NULL,
+		container TEXT NOT NULL,
+		image TEXT NOT NULL,
+		previous_image TEXT,
+		resource_version TEXT,
+		actor TEXT,
+		changed_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_deployment_images_name ON deployment_images(namespace, name, changed_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_deployment_images_image ON deployment_images(namespace, image);
+
+	-- SLOs: compliance objectives on top of invariants
+	CREATE TABLE IF NOT EXISTS slos (
+		id TEXT PRIMARY KEY,
+		invariant_id TEXT NOT NULL,
+		definition JSONB NOT NULL,
+		created_at TIMESTAMP DEFAULT NOW(),
+		updated_at TIMESTAMP DEFAULT NOW()
+	);
+
+	-- SLO buckets: per-invariant compliance samples in 5 minute intervals
+	CREATE TABLE IF NOT EXISTS slo_buckets (
+		invariant_id TEXT NOT NULL,
+		bucket_start TIMESTAMP NOT NULL,
+		good BIGINT NOT NULL,
+		total BIGINT NOT NULL,
+		PRIMARY KEY (invariant_id, bucket_start)
+	);
+
+	-- Pod terminations: evictions and OOM kills with their reasons
+	CREATE TABLE IF NOT EXISTS pod_terminations (
+		id SERIAL PRIMARY KEY,
+		uid TEXT NOT NULL,
+		namespace TEXT,
+		name TEXT NOT NULL,
+		node TEXT,
+		container TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL,
+		message TEXT,
+		restart_count INT,
+		actor TEXT,
+		occurred_at TIMESTAMP NOT NULL,
+		UNIQUE (uid, container, reason, occurred_at)
+	);
+	CREATE INDEX IF NOT EXISTS idx_pod_terminations_ns ON pod_terminations(namespace, occurred_at DESC);
+
+	-- Idempotency keys: the first response to each keyed mutating request
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		key TEXT PRIMARY KEY,
+		fingerprint TEXT NOT NULL,
+		status INT NOT NULL,
+		content_type TEXT,
+		body BYTEA,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at);
+
+	-- Evaluation log: sampled evaluation outcomes for debugging invariants
+	CREATE TABLE IF NOT EXISTS evaluation_log (
+		id BIGSERIAL PRIMARY KEY,
+		invariant_id TEXT NOT NULL,
+		uid TEXT NOT NULL,
+		status TEXT NOT NULL,
 		reason TEXT,
-		severity TEXT,
-		resolved_at TIMESTAMP,
-		resolution_reason TEXT
+		correlation_id TEXT,
+		evaluated_at TIMESTAMP NOT NULL,
+		duration_ns BIGINT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_evaluation_log_invariant ON evaluation_log(invariant_id, evaluated_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_evaluation_log_uid ON evaluation_log(uid, evaluated_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_evaluation_log_at ON evaluation_log(evaluated_at);
+
+	-- Notification deliveries: every attempt to page an incident, and the
+	-- actions every attempt failed for, kept for replay
+	CREATE TABLE IF NOT EXISTS notification_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		key TEXT NOT NULL,
+		client TEXT NOT NULL,
+		action TEXT NOT NULL,
+		attempt INT NOT NULL,
+		error TEXT,
+		attempted_at TIMESTAMP NOT NULL
 	);
-	CREATE INDEX IF NOT EXISTS idx_violations_invariant ON violations(invariant_id);
-	CREATE INDEX IF NOT EXISTS idx_violations_detected ON violations(detected_at DESC);
-	CREATE INDEX IF NOT EXISTS idx_violations_active ON violations(resolved_at) WHERE resolved_at IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_notification_deliveries_key ON notification_deliveries(key, attempted_at);
+	CREATE INDEX IF NOT EXISTS idx_notification_deliveries_at ON notification_deliveries(attempted_at);
+
+	CREATE TABLE IF NOT EXISTS notification_dead_letters (
+		id BIGSERIAL PRIMARY KEY,
+		client TEXT NOT NULL,
+		action TEXT NOT NULL,
+		incident_key TEXT NOT NULL,
+		incident JSONB NOT NULL,
+		attempts INT NOT NULL,
+		last_error TEXT NOT NULL,
+		failed_at TIMESTAMP NOT NULL
+	);
+
+	-- Migrations for databases created by earlier releases
+	ALTER TABLE invariants ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+	ALTER TABLE objects ADD COLUMN IF NOT EXISTS resource_created_at TIMESTAMP;
+	ALTER TABLE objects ADD COLUMN IF NOT EXISTS tier TEXT;
+	ALTER TABLE objects ADD COLUMN IF NOT EXISTS tier_override TEXT;
+	ALTER TABLE objects ADD COLUMN IF NOT EXISTS logical_key TEXT;
+	CREATE INDEX IF NOT EXISTS idx_objects_logical_key ON objects(logical_key);
+	ALTER TABLE objects ADD COLUMN IF NOT EXISTS correlation_id TEXT;
+	ALTER TABLE object_versions ADD COLUMN IF NOT EXISTS correlation_id TEXT;
+	ALTER TABLE objects ADD COLUMN IF NOT EXISTS owner_uid TEXT;
+	ALTER TABLE objects ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
 	`
 
-	_, err := s.db.Exec(schema)
-	return err
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+	if err := s.initViolationsSchema(time.Now()); err != nil {
+		return err
+	}
+	return s.recordSchemaVersion()
+}
+
+// SetSkipUnchanged enables dropping events whose fields are identical to
+// the latest cached state of the same UID, so resyncs don't grow
+// object_versions
+func (s *PostgresStore) SetSkipUnchanged(enabled bool) {
+	s.skipUnchanged.Store(enabled)
+}
+
+// SkippedUnchanged returns how many events were dropped as unchanged
+func (s *PostgresStore) SkippedUnchanged() uint64 {
+	return s.skipped.Load()
+}
+
+// SetFieldExclusions keeps the matching fields of every event recorded from
+// now on out of field_diffs, object versions and the cache
+func (s *PostgresStore) SetFieldExclusions(exclusions *state.FieldExclusions) {
+	s.exclusions.Store(exclusions)
+}
+
+// FieldExclusions returns the fields dropped before recording
+func (s *PostgresStore) FieldExclusions() *state.FieldExclusions {
+	return s.exclusions.Load()
 }
 
 func (s *PostgresStore) Record(event types.StateEvent) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+	event = s.exclusions.Load().Apply(event)
+	if event.CorrelationID == "" {
+		event.CorrelationID = state.NewCorrelationID()
+	}
 
-	ctx := context.Background()
+	unlock := s.recording.Lock(event.UID)
+	if s.skipUnchanged.Load() {
+		if latest, exists := s.cache.Get(event.UID); exists && state.Unchanged(latest, event) {
+			unlock()
+			s.skipped.Add(1)
+			return nil
+		}
+	}
+	if err := s.outage.record(event, s.write); err != nil {
+		unlock()
+		return err
+	}
+	// Update in-memory cache
+	s.cache.Put(event)
+	unlock()
+
+	s.NotifyRecorded(event)
+
+	return nil
+}
+
+// write persists one event. The transaction is bounded by recordTimeout so
+// an unresponsive database fails the write instead of blocking the watcher.
+func (s *PostgresStore) write(event types.StateEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	var labelsJSON []byte
+	if len(event.Labels) > 0 {
+		labelsJSON, _ = json.Marshal(event.Labels)
+	}
+
 	// Upsert object
 	_, err = tx.Exec(`
-		INSERT INTO objects (uid, kind, namespace, name, labels)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO objects (uid, kind, namespace, name, labels, resource_created_at, tier, logical_key, correlation_id, owner_uid)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		ON CONFLICT (uid) DO UPDATE SET
 			updated_at = NOW(),
 			name = EXCLUDED.name,
 			namespace = EXCLUDED.namespace,
-			labels = EXCLUDED.labels
-	`, event.UID, event.Kind, event.Namespace, event.Name, nil)
+			labels = EXCLUDED.labels,
+			resource_created_at = COALESCE(EXCLUDED.resource_created_at, objects.resource_created_at),
+			tier = EXCLUDED.tier,
+			logical_key = EXCLUDED.logical_key,
+			correlation_id = EXCLUDED.correlation_id,
+			owner_uid = EXCLUDED.owner_uid,
+			deleted_at = NULL
+	`, event.UID, event.Kind, event.Namespace, event.Name, labelsJSON, nullTime(event.CreationTimestamp), nullString(event.Tier), s.identities.Observe(event), nullString(event.CorrelationID), nullString(event.OwnerUID))
 	if err != nil {
 		return fmt.Errorf("failed to upsert object: %w", err)
 	}
@@ -175,10 +381,10 @@ func (s *PostgresStore) Record(event types.StateEvent) error {
 
 	// Insert object version (append-only)
 	_, err = tx.Exec(`
-		INSERT INTO object_versions (uid, resource_version, timestamp, spec, status, actor, event_type)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO object_versions (uid, resource_version, timestamp, spec, status, actor, event_type, correlation_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		ON CONFLICT (uid, resource_version) DO NOTHING
-	`, event.UID, event.Version, event.Timestamp, specJSON, statusJSON, event.Actor, "UPDATE")
+	`, event.UID, event.Version, event.Timestamp, specJSON, statusJSON, event.Actor, "UPDATE", nullString(event.CorrelationID))
 	if err != nil {
 		return fmt.Errorf("failed to insert object version: %w", err)
 	}
@@ -196,50 +402,58 @@ func (s *PostgresStore) Record(event types.StateEvent) error {
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	if err := s.recordImageChanges(tx, event); err != nil {
+		return err
 	}
 
-	// Update in-memory cache
-	s.latestByUID[event.UID] = event
+	if err := s.recordTerminations(tx, event); err != nil {
+		return err
+	}
 
-	found := false
-	for _, uid := range s.uidsByKind[event.Kind] {
-		if uid == event.UID {
-			found = true
-			break
-		}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
-	if !found {
-		s.uidsByKind[event.Kind] = append(s.uidsByKind[event.Kind], event.UID)
+	return nil
+}
+
+// Forget marks uid deleted and drops it from the cache, so it is neither
+// listed nor evaluated again, nor reloaded on restart. Its versions are
+// kept.
+func (s *PostgresStore) Forget(uid string) error {
+	if s.readOnly {
+		return state.ErrReadOnly
 	}
+	unlock := s.recording.Lock(uid)
+	defer unlock()
 
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+	if _, err := s.db.ExecContext(ctx, `UPDATE objects SET deleted_at = NOW(), updated_at = NOW() WHERE uid = $1`, uid); err != nil {
+		return fmt.Errorf("failed to mark object deleted: %w", err)
+	}
+	s.cache.Remove(uid)
 	return nil
 }
 
 func (s *PostgresStore) GetLatestByKind(kind string) []types.StateEvent {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return s.cache.LatestByKind(kind)
+}
 
-	var results []types.StateEvent
-	for _, uid := range s.uidsByKind[kind] {
-		if event, exists := s.latestByUID[uid]; exists {
-			results = append(results, event)
-		}
-	}
-	return results
+func (s *PostgresStore) GetBySelector(kind string, selector map[string]string) []types.StateEvent {
+	return s.cache.BySelector(kind, selector)
 }
 
 func (s *PostgresStore) GetByUID(uid string) (types.StateEvent, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	event, exists := s.latestByUID[uid]
-	return event, exists
+	return s.cache.Get(uid)
+}
+
+func (s *PostgresStore) Kinds() []string {
+	return s.cache.Kinds()
 }
 
 func (s *PostgresStore) GetHistory(uid string, limit int) ([]types.StateEvent, error) {
 	rows, err := s.db.Query(`
-		SELECT uid, resource_version, timestamp, actor
+		SELECT uid, resource_version, timestamp, actor, COALESCE(correlation_id, '')
 		FROM object_versions
 		WHERE uid = $1
 		ORDER BY timestamp DESC
@@ -253,7 +467,7 @@ func (s *PostgresStore) GetHistory(uid string, limit int) ([]types.StateEvent, e
 	var events []types.StateEvent
 	for rows.Next() {
 		var event types.StateEvent
-		if err := rows.Scan(&event.UID, &event.Version, &event.Timestamp, &event.Actor); err != nil {
+		if err := rows.Scan(&event.UID, &event.Version, &event.Timestamp, &event.Actor, &event.CorrelationID); err != nil {
 			continue
 		}
 		events = append(events, event)
@@ -263,36 +477,99 @@ func (s *PostgresStore) GetHistory(uid string, limit int) ([]types.StateEvent, e
 }
 
 func (s *PostgresStore) RecordViolation(violation *engine.ViolationResult) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+
 	eliminatedJSON, _ := json.Marshal(violation.EliminatedActors)
 
+	uid := violation.ResourceUID
+	if uid == "" {
+		uid = "unknown"
+	}
+
 	_, err := s.db.Exec(`
 		INSERT INTO violations (
 			invariant_id, uid, resource_kind, resource_name, namespace,
-			detected_at, responsible_actor, eliminated_actors, reason, severity
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`, violation.InvariantID, "unknown", // UID extraction needed
+			detected_at, responsible_actor, eliminated_actors, reason, severity,
+			invariant_version, tier, logical_resource, correlation_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`, violation.InvariantID, uid,
 		violation.InvariantID, violation.AffectedResource, "",
 		violation.DetectedAt, violation.ResponsibleActor,
-		eliminatedJSON, violation.Reason, violation.Severity)
+		eliminatedJSON, violation.Reason, violation.Severity,
+		violation.InvariantVersion, nullString(string(violation.Tier)), nullString(violation.LogicalResource),
+		nullString(violation.CorrelationID))
 
 	return err
 }
 
-func (s *PostgresStore) GetViolations(severity string, limit int) ([]*engine.ViolationResult, error) {
+// violationOrders are the ORDER BY clauses for each sort order. Impact is
+// computed after loading, so it is fetched most severe first.
+var violationOrders = map[engine.SortOrder]string{
+	engine.SortSeverity:   severityOrder + ", detected_at DESC",
+	engine.SortDetectedAt: "detected_at DESC",
+	engine.SortResource:   "resource_name, invariant_id",
+	engine.SortImpact:     severityOrder + ", detected_at DESC",
+}
+
+const severityOrder = `CASE severity WHEN 'critical' THEN 0 WHEN 'degraded' THEN 1 WHEN 'warning' THEN 2 ELSE 3 END`
+
+// ViolationQuery narrows the violations QueryViolations returns. Empty
+// fields match every violation.
+type ViolationQuery struct {
+	Severity string
+	// InvariantIDs, when not nil, keeps the violations of these invariants
+	InvariantIDs    []string
+	LogicalResource string
+}
+
+func (q ViolationQuery) matches(v *engine.ViolationResult) bool {
+	return (q.Severity == "" || string(v.Severity) == q.Severity) &&
+		(q.InvariantIDs == nil || slices.Contains(q.InvariantIDs, v.InvariantID)) &&
+		(q.LogicalResource == "" || v.LogicalResource == q.LogicalResource)
+}
+
+// GetViolations returns up to limit violations in the given order, the
+// default being most severe first
+func (s *PostgresStore) GetViolations(severity string, order engine.SortOrder, limit int) ([]*engine.ViolationResult, error) {
+	return s.QueryViolations(ViolationQuery{Severity: severity}, order, limit)
+}
+
+// QueryViolations returns up to limit violations matching q in the given
+// order, filtering in the database so the limit counts only matches
+func (s *PostgresStore) QueryViolations(q ViolationQuery, order engine.SortOrder, limit int) ([]*engine.ViolationResult, error) {
+	if q.InvariantIDs != nil && len(q.InvariantIDs) == 0 {
+		return nil, nil
+	}
+	orderBy, ok := violationOrders[order]
+	if !ok {
+		orderBy = violationOrders[engine.SortSeverity]
+	}
+
 	query := `
-		SELECT invariant_id, resource_name, detected_at, responsible_actor,
-		       eliminated_actors, reason, severity, resolved_at
+		SELECT invariant_id, COALESCE(invariant_version, 0), resource_name, detected_at,
+		       responsible_actor, eliminated_actors, reason, severity, resolved_at,
+		       NULLIF(uid, 'unknown'), tier, COALESCE(logical_resource, ''), COALESCE(correlation_id, '')
 		FROM violations
 		WHERE 1=1
 	`
 	args := make([]interface{}, 0)
 
-	if severity != "" {
-		query += " AND severity = $1"
-		args = append(args, severity)
+	if q.Severity != "" {
+		args = append(args, q.Severity)
+		query += fmt.Sprintf(" AND severity = $%d", len(args))
+	}
+	if q.InvariantIDs != nil {
+		args = append(args, pq.Array(q.InvariantIDs))
+		query += fmt.Sprintf(" AND invariant_id = ANY($%d)", len(args))
+	}
+	if q.LogicalResource != "" {
+		args = append(args, q.LogicalResource)
+		query += fmt.Sprintf(" AND logical_resource = $%d", len(args))
 	}
 
-	query += " ORDER BY detected_at DESC LIMIT $" + fmt.Sprintf("%d", len(args)+1)
+	query += " ORDER BY " + orderBy + " LIMIT $" + fmt.Sprintf("%d", len(args)+1)
 	args = append(args, limit)
 
 	rows, err := s.db.Query(query, args...)
@@ -306,32 +583,67 @@ func (s *PostgresStore) GetViolations(severity string, limit int) ([]*engine.Vio
 		var v engine.ViolationResult
 		var eliminatedJSON []byte
 		var resolvedAt sql.NullTime
+		var uid, tier sql.NullString
 
 		if err := rows.Scan(
-			&v.InvariantID, &v.AffectedResource, &v.DetectedAt,
+			&v.InvariantID, &v.InvariantVersion, &v.AffectedResource, &v.DetectedAt,
 			&v.ResponsibleActor, &eliminatedJSON, &v.Reason, &v.Severity,
-			&resolvedAt,
+			&resolvedAt, &uid, &tier, &v.LogicalResource, &v.CorrelationID,
 		); err != nil {
 			continue
 		}
+		v.ResourceUID = uid.String
+		v.Tier = criticality.Tier(tier.String)
 
 		json.Unmarshal(eliminatedJSON, &v.EliminatedActors)
 		v.Violated = !resolvedAt.Valid
 		violations = append(violations, &v)
 	}
 
+	// Violations archived out of the partitions are merged back in
+	if len(violations) < limit {
+		archived, err := s.archivedViolations(q, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(archived) > 0 {
+			violations = append(violations, archived...)
+			engine.SortViolations(violations, order)
+			if len(violations) > limit {
+				violations = violations[:limit]
+			}
+		}
+	}
+
 	return violations, nil
 }
 
 func (s *PostgresStore) GetActiveViolations() ([]*engine.ViolationResult, error) {
-	rows, err := s.db.Query(`
-		SELECT invariant_id, resource_name, detected_at, responsible_actor,
-		       eliminated_actors, reason, severity
+	return s.activeViolations(100)
+}
+
+// OpenViolations returns every violation not yet resolved, for the monitor
+// to carry over across a restart
+func (s *PostgresStore) OpenViolations() ([]*engine.ViolationResult, error) {
+	return s.activeViolations(0)
+}
+
+// activeViolations returns up to limit unresolved violations, most severe
+// first; 0 returns all of them
+func (s *PostgresStore) activeViolations(limit int) ([]*engine.ViolationResult, error) {
+	query := `
+		SELECT invariant_id, COALESCE(invariant_version, 0), resource_name, detected_at,
+		       responsible_actor, eliminated_actors, reason, severity,
+		       NULLIF(uid, 'unknown'), tier, COALESCE(logical_resource, ''), COALESCE(correlation_id, '')
 		FROM violations
 		WHERE resolved_at IS NULL
-		ORDER BY detected_at DESC
-		LIMIT 100
-	`)
+		ORDER BY ` + severityOrder + `, detected_at DESC`
+	args := make([]interface{}, 0, 1)
+	if limit > 0 {
+		query += " LIMIT $1"
+		args = append(args, limit)
+	}
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -341,16 +653,21 @@ func (s *PostgresStore) GetActiveViolations() ([]*engine.ViolationResult, error)
 	for rows.Next() {
 		var v engine.ViolationResult
 		var eliminatedJSON []byte
+		var uid, tier sql.NullString
 
 		if err := rows.Scan(
-			&v.InvariantID, &v.AffectedResource, &v.DetectedAt,
+			&v.InvariantID, &v.InvariantVersion, &v.AffectedResource, &v.DetectedAt,
 			&v.ResponsibleActor, &eliminatedJSON, &v.Reason, &v.Severity,
+			&uid, &tier, &v.LogicalResource, &v.CorrelationID,
 		); err != nil {
 			continue
 		}
+		v.ResourceUID = uid.String
+		v.Tier = criticality.Tier(tier.String)
 
 		json.Unmarshal(eliminatedJSON, &v.EliminatedActors)
 		v.Violated = true
+		v.Status = engine.StatusViolated
 		violations = append(violations, &v)
 	}
 
@@ -358,6 +675,10 @@ func (s *PostgresStore) GetActiveViolations() ([]*engine.ViolationResult, error)
 }
 
 func (s *PostgresStore) UpdateInvariantEvaluation(invID, uid, status, reason string) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+
 	_, err := s.db.Exec(`
 		INSERT INTO invariant_evaluations (invariant_id, uid, status, reason, last_evaluated)
 		VALUES ($1, $2, $3, $4, NOW())
@@ -369,11 +690,174 @@ func (s *PostgresStore) UpdateInvariantEvaluation(invID, uid, status, reason str
 	return err
 }
 
+// SaveInvariant stores inv as the current definition of its invariant and
+// appends it to the version history
+func (s *PostgresStore) SaveInvariant(inv dsl.Invariant) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+
+	definition, err := json.Marshal(inv)
+	if err != nil {
+		return fmt.Errorf("failed to marshal invariant: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO invariants (id, version, definition, severity)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET
+			version = EXCLUDED.version,
+			definition = EXCLUDED.definition,
+			severity = EXCLUDED.severity,
+			updated_at = NOW(),
+			deleted_at = NULL
+	`, inv.ID, inv.Version, definition, inv.Severity)
+	if err != nil {
+		return fmt.Errorf("failed to upsert invariant: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO invariant_versions (invariant_id, version, definition)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (invariant_id, version) DO NOTHING
+	`, inv.ID, inv.Version, definition)
+	if err != nil {
+		return fmt.Errorf("failed to insert invariant version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// SoftDeleteInvariant marks an invariant deleted while keeping its history
+func (s *PostgresStore) SoftDeleteInvariant(id string) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE invariants SET deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id)
+	return err
+}
+
+// LoadInvariants returns the current definition of every active invariant
+// and the IDs of soft-deleted ones
+func (s *PostgresStore) LoadInvariants() ([]dsl.Invariant, []string, error) {
+	rows, err := s.db.Query(`SELECT id, definition, deleted_at FROM invariants`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var active []dsl.Invariant
+	var deleted []string
+	for rows.Next() {
+		var id string
+		var definition []byte
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&id, &definition, &deletedAt); err != nil {
+			continue
+		}
+		if deletedAt.Valid {
+			deleted = append(deleted, id)
+			continue
+		}
+
+		var inv dsl.Invariant
+		if err := json.Unmarshal(definition, &inv); err != nil {
+			log.Printf("Warning: skipping invariant %s with unreadable definition: %v", id, err)
+			continue
+		}
+		active = append(active, inv)
+	}
+
+	return active, deleted, nil
+}
+
+// GetInvariantVersions returns every stored definition of an invariant,
+// oldest first
+func (s *PostgresStore) GetInvariantVersions(id string) ([]dsl.Invariant, error) {
+	rows, err := s.db.Query(`
+		SELECT v.definition, v.version = i.version, i.deleted_at
+		FROM invariant_versions v
+		JOIN invariants i ON i.id = v.invariant_id
+		WHERE v.invariant_id = $1
+		ORDER BY v.version ASC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []dsl.Invariant
+	for rows.Next() {
+		var definition []byte
+		var current bool
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&definition, &current, &deletedAt); err != nil {
+			continue
+		}
+
+		var inv dsl.Invariant
+		if err := json.Unmarshal(definition, &inv); err != nil {
+			continue
+		}
+		if current && deletedAt.Valid {
+			inv.DeletedAt = &deletedAt.Time
+		}
+		versions = append(versions, inv)
+	}
+
+	return versions, nil
+}
+
+// SyncInvariants reconciles the engine's registry with the database:
+// persisted definitions replace the built-in ones, soft-deleted invariants
+// are removed, and definitions the database has never seen are saved.
+func (s *PostgresStore) SyncInvariants(eng *engine.InvariantEngine) error {
+	active, deleted, err := s.LoadInvariants()
+	if err != nil {
+		return fmt.Errorf("failed to load invariants: %w", err)
+	}
+
+	persisted := make(map[string]bool)
+	for _, inv := range active {
+		eng.RestoreInvariant(inv)
+		persisted[inv.ID] = true
+	}
+	for _, id := range deleted {
+		eng.DeleteInvariant(id)
+		persisted[id] = true
+	}
+
+	if s.readOnly {
+		return nil
+	}
+	for _, inv := range eng.GetInvariants() {
+		if persisted[inv.ID] {
+			continue
+		}
+		if err := s.SaveInvariant(inv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *PostgresStore) loadCache() error {
 	rows, err := s.db.Query(`
 		SELECT DISTINCT ON (uid)
-			uid, kind, namespace, name
+			uid, kind, namespace, name, labels, resource_created_at, COALESCE(tier, ''), COALESCE(logical_key, ''),
+			COALESCE(correlation_id, ''), COALESCE(owner_uid, '')
 		FROM objects
+		WHERE deleted_at IS NULL
 		ORDER BY uid, updated_at DESC
 	`)
 	if err != nil {
@@ -383,18 +867,52 @@ func (s *PostgresStore) loadCache() error {
 
 	for rows.Next() {
 		var event types.StateEvent
-		if err := rows.Scan(&event.UID, &event.Kind, &event.Namespace, &event.Name); err != nil {
+		var labelsJSON []byte
+		var createdAt sql.NullTime
+		var logicalKey string
+		if err := rows.Scan(&event.UID, &event.Kind, &event.Namespace, &event.Name, &labelsJSON, &createdAt, &event.Tier, &logicalKey, &event.CorrelationID, &event.OwnerUID); err != nil {
 			continue
 		}
+		if logicalKey != "" {
+			s.identities.Set(event.UID, logicalKey)
+		}
+		if createdAt.Valid {
+			event.CreationTimestamp = createdAt.Time
+		}
+		if len(labelsJSON) > 0 {
+			json.Unmarshal(labelsJSON, &event.Labels)
+		}
 
-		s.latestByUID[event.UID] = event
-		s.uidsByKind[event.Kind] = append(s.uidsByKind[event.Kind], event.UID)
+		s.cache.Put(event)
 	}
 
-	log.Printf("Loaded %d objects into cache", len(s.latestByUID))
+	log.Printf("Loaded %d objects into cache", s.cache.Len())
 	return nil
 }
 
+func (s *PostgresStore) LogicalKey(uid string) (string, bool) {
+	return s.identities.LogicalKey(uid)
+}
+
+func (s *PostgresStore) UIDsForKey(key string) []string {
+	return s.identities.UIDsForKey(key)
+}
+
+// ReadOnly reports whether the store rejects writes
+func (s *PostgresStore) ReadOnly() bool {
+	return s.readOnly
+}
+
+// nullTime maps the zero time to SQL NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// nullString maps the empty string to SQL NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
 func (s *PostgresStore) Close() error {
 	return s.db.Close()
 }
@@ -34,7 +34,7 @@ func setupTestDB(t *testing.T) (*PostgresStore, func()) {
 	// Cleanup function
 	cleanup := func() {
 		// Drop all data
-		store.db.Exec("TRUNCATE objects, object_versions, field_diffs, invariants, invariant_evaluations, violations CASCADE")
+		store.db.Exec("TRUNCATE objects, object_versions, field_diffs, invariants, invariant_versions, invariant_evaluations, violations, violations_archive, deployment_images, slos, slo_buckets, pod_terminations, idempotency_keys, notification_deliveries, notification_dead_letters, evaluation_log CASCADE")
 		store.Close()
 	}
 
@@ -136,7 +136,7 @@ func TestRecordStateEvent(t *testing.T) {
 	}
 
 	// Verify in-memory cache was updated
-	if _, exists := store.latestByUID[event.UID]; !exists {
+	if _, exists := store.cache.Get(event.UID); !exists {
 		t.Error("Event not found in in-memory cache")
 	}
 }
@@ -376,7 +376,7 @@ func TestGetViolations(t *testing.T) {
 	}
 
 	// Get all violations
-	all, err := store.GetViolations("", 100)
+	all, err := store.GetViolations("", engine.SortSeverity, 100)
 	if err != nil {
 		t.Fatalf("Failed to get violations: %v", err)
 	}
@@ -385,7 +385,7 @@ func TestGetViolations(t *testing.T) {
 	}
 
 	// Get critical violations only
-	critical, err := store.GetViolations("critical", 100)
+	critical, err := store.GetViolations("critical", engine.SortSeverity, 100)
 	if err != nil {
 		t.Fatalf("Failed to get critical violations: %v", err)
 	}
@@ -394,13 +394,26 @@ func TestGetViolations(t *testing.T) {
 	}
 
 	// Test limit
-	limited, err := store.GetViolations("", 1)
+	limited, err := store.GetViolations("", engine.SortSeverity, 1)
 	if err != nil {
 		t.Fatalf("Failed to get limited violations: %v", err)
 	}
 	if len(limited) != 1 {
 		t.Errorf("Expected 1 violation with limit, got %d", len(limited))
 	}
+
+	// The limit counts only the violations matching the query, even those
+	// sorted after others
+	matching, err := store.QueryViolations(ViolationQuery{InvariantIDs: []string{"no_crashloop"}}, engine.SortSeverity, 1)
+	if err != nil {
+		t.Fatalf("Failed to query violations: %v", err)
+	}
+	if len(matching) != 1 || matching[0].InvariantID != "no_crashloop" {
+		t.Errorf("Expected the no_crashloop violation, got %+v", matching)
+	}
+	if none, _ := store.QueryViolations(ViolationQuery{InvariantIDs: []string{}}, engine.SortSeverity, 10); len(none) != 0 {
+		t.Errorf("Expected no violations for no invariants, got %d", len(none))
+	}
 }
 
 // TestGetActiveViolations tests retrieving only unresolved violations
@@ -451,6 +464,35 @@ func TestGetActiveViolations(t *testing.T) {
 	}
 }
 
+// TestOpenViolations tests that every unresolved violation is loaded for a
+// warm start, beyond the active listing's limit
+func TestOpenViolations(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	if store == nil {
+		return
+	}
+	defer cleanup()
+
+	for i := 0; i < 105; i++ {
+		v := &engine.ViolationResult{
+			InvariantID: "pod_ready", Violated: true, Reason: "Pod not ready", EliminatedActors: []string{},
+			AffectedResource: fmt.Sprintf("default/pod-%d", i), DetectedAt: time.Now(), Severity: "critical",
+		}
+		if err := store.RecordViolation(v); err != nil {
+			t.Fatalf("Failed to record violation: %v", err)
+		}
+	}
+	store.ResolveViolation(&engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/pod-0"}, time.Now())
+
+	open, err := store.OpenViolations()
+	if err != nil {
+		t.Fatalf("OpenViolations failed: %v", err)
+	}
+	if len(open) != 104 || open[0].Status != engine.StatusViolated {
+		t.Errorf("Expected 104 open violations, got %d", len(open))
+	}
+}
+
 // TestUpdateInvariantEvaluation tests caching evaluation results
 func TestUpdateInvariantEvaluation(t *testing.T) {
 	store, cleanup := setupTestDB(t)
@@ -599,8 +641,8 @@ func TestLoadCache(t *testing.T) {
 	defer newStore.Close()
 
 	// Verify cache was loaded
-	if len(newStore.latestByUID) != 3 {
-		t.Errorf("Expected 3 items in cache, got %d", len(newStore.latestByUID))
+	if newStore.cache.Len() != 3 {
+		t.Errorf("Expected 3 items in cache, got %d", newStore.cache.Len())
 	}
 
 	pods := newStore.GetLatestByKind("Pod")
@@ -717,3 +759,35 @@ func BenchmarkGetLatestByKind(b *testing.B) {
 		_ = store.GetLatestByKind("Pod")
 	}
 }
+
+// TestForget tests that forgotten objects leave the cache and aren't reloaded
+func TestForget(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	if store == nil {
+		return
+	}
+	defer cleanup()
+
+	pod := types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web", Version: "1", Timestamp: time.Now()}
+	if err := store.Record(pod); err != nil {
+		t.Fatalf("Failed to record: %v", err)
+	}
+	if err := store.Forget("pod-1"); err != nil {
+		t.Fatalf("Failed to forget: %v", err)
+	}
+	if _, exists := store.GetByUID("pod-1"); exists {
+		t.Error("Expected a forgotten object dropped from the cache")
+	}
+
+	reopened, err := NewPostgresStore(getTestDBConnString())
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+	defer reopened.Close()
+	if len(reopened.GetLatestByKind("Pod")) != 0 {
+		t.Error("Expected a forgotten object not reloaded")
+	}
+	if history, _ := reopened.GetHistory("pod-1", 10); len(history) != 1 {
+		t.Errorf("Expected the history kept, got %d versions", len(history))
+	}
+}
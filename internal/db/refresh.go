@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// DefaultCacheRefreshInterval is how often read-only stores reload the
+// objects the primary wrote, and so about how stale a replica reads
+const DefaultCacheRefreshInterval = 15 * time.Second
+
+// refreshOverlap re-reads objects updated shortly before the last refresh,
+// as a write committed late carries the updated_at of its transaction's
+// start
+const refreshOverlap = recordTimeout
+
+// RunCacheRefresh reloads, every interval until ctx is cancelled, the
+// latest version of the objects updated since the previous refresh, the
+// first time every object. Read-only replicas record nothing themselves,
+// so without it their cache would stay as it was when they started.
+func (s *PostgresStore) RunCacheRefresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var since time.Time
+	for {
+		if refreshed, n, err := s.refreshCache(since); err != nil {
+			log.Printf("Failed to refresh the cache: %v", err)
+		} else {
+			if since.IsZero() {
+				log.Printf("Loaded the latest state of %d objects", n)
+			}
+			since = refreshed
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refreshCache puts the latest version of every object updated after since
+// into the cache and drops those deleted, returning the newest updated_at
+// seen and the objects reloaded
+func (s *PostgresStore) refreshCache(since time.Time) (time.Time, int, error) {
+	var newest time.Time
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(updated_at), $1) FROM objects`, since).Scan(&newest); err != nil {
+		return since, 0, err
+	}
+	from := since
+	if !since.IsZero() {
+		from = since.Add(-refreshOverlap)
+	}
+	events, err := s.queryVersions(`(
+		SELECT DISTINCT ON (v.uid) v.uid, v.resource_version, v.timestamp, v.actor
+		FROM object_versions v
+		JOIN objects o ON o.uid = v.uid
+		WHERE o.updated_at > $1 AND o.deleted_at IS NULL
+		ORDER BY v.uid, v.timestamp DESC
+	)`, "", from)
+	if err != nil {
+		return since, 0, err
+	}
+	deleted, err := s.deletedSince(from)
+	if err != nil {
+		return since, 0, err
+	}
+	for _, event := range events {
+		s.cache.Put(event)
+	}
+	for _, uid := range deleted {
+		s.cache.Remove(uid)
+	}
+	return newest, len(events), nil
+}
+
+// deletedSince lists the objects the primary forgot after since
+func (s *PostgresStore) deletedSince(since time.Time) ([]string, error) {
+	rows, err := s.db.Query(`SELECT uid FROM objects WHERE deleted_at IS NOT NULL AND updated_at > $1`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			return nil, err
+		}
+		uids = append(uids, uid)
+	}
+	return uids, rows.Err()
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aonescu/akari/internal/slo"
+	"github.com/aonescu/akari/internal/state"
+)
+
+// SaveSLO upserts an SLO definition
+func (s *PostgresStore) SaveSLO(def slo.SLO) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+
+	definition, err := json.Marshal(def)
+	if err != nil {
+		return fmt.Errorf("failed to marshal slo: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO slos (id, invariant_id, definition)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET
+			invariant_id = EXCLUDED.invariant_id,
+			definition = EXCLUDED.definition,
+			updated_at = NOW()
+	`, def.ID, def.InvariantID, definition)
+	if err != nil {
+		return fmt.Errorf("failed to upsert slo: %w", err)
+	}
+	return nil
+}
+
+// DeleteSLO removes an SLO definition; its invariant's samples are kept
+// for other SLOs on the same invariant
+func (s *PostgresStore) DeleteSLO(id string) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+	_, err := s.db.Exec(`DELETE FROM slos WHERE id = $1`, id)
+	return err
+}
+
+// SaveBucket upserts the compliance counts of one sample interval
+func (s *PostgresStore) SaveBucket(b slo.Bucket) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO slo_buckets (invariant_id, bucket_start, good, total)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (invariant_id, bucket_start) DO UPDATE SET
+			good = EXCLUDED.good,
+			total = EXCLUDED.total
+	`, b.InvariantID, b.Start, int64(b.Good), int64(b.Total))
+	return err
+}
+
+// LoadSLOs returns every stored SLO definition
+func (s *PostgresStore) LoadSLOs() ([]slo.SLO, error) {
+	rows, err := s.db.Query(`SELECT definition FROM slos ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slos []slo.SLO
+	for rows.Next() {
+		var definition []byte
+		if err := rows.Scan(&definition); err != nil {
+			continue
+		}
+		var def slo.SLO
+		if err := json.Unmarshal(definition, &def); err != nil {
+			continue
+		}
+		slos = append(slos, def)
+	}
+	return slos, nil
+}
+
+// LoadSLOBuckets returns compliance samples recorded since the given time
+func (s *PostgresStore) LoadSLOBuckets(since time.Time) ([]slo.Bucket, error) {
+	rows, err := s.db.Query(`
+		SELECT invariant_id, bucket_start, good, total
+		FROM slo_buckets
+		WHERE bucket_start >= $1
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []slo.Bucket
+	for rows.Next() {
+		var b slo.Bucket
+		var good, total int64
+		if err := rows.Scan(&b.InvariantID, &b.Start, &good, &total); err != nil {
+			continue
+		}
+		b.Good, b.Total = uint64(good), uint64(total)
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
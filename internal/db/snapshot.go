@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+	"github.com/lib/pq"
+)
+
+// versionQuery selects object versions with the fields recorded for each.
+// Labels, tier and owner come from objects and therefore reflect the latest
+// recorded state rather than the state at the version's time.
+const versionQuery = `
+	SELECT v.uid, v.resource_version, v.timestamp, COALESCE(v.actor, ''),
+	       o.kind, COALESCE(o.namespace, ''), o.name, o.labels, o.resource_created_at, COALESCE(o.tier, ''),
+	       COALESCE(o.owner_uid, ''),
+	       COALESCE((
+	           SELECT jsonb_object_agg(d.field_path, d.new_value)
+	           FROM field_diffs d
+	           WHERE d.uid = v.uid AND d.resource_version = v.resource_version
+	       ), '{}'::jsonb)
+	FROM %s v
+	JOIN objects o ON o.uid = v.uid
+`
+
+// SnapshotAt reconstructs the latest version of every object recorded at
+// or before t, or of the given objects only
+func (s *PostgresStore) SnapshotAt(t time.Time, uids ...string) ([]types.StateEvent, error) {
+	hot, err := s.queryVersions(`(
+		SELECT DISTINCT ON (uid) uid, resource_version, timestamp, actor
+		FROM object_versions
+		WHERE timestamp <= $1 AND (cardinality($2::text[]) = 0 OR uid = ANY($2::text[]))
+		ORDER BY uid, timestamp DESC
+	)`, "", t, uidArray(uids))
+	if err != nil {
+		return nil, err
+	}
+	archived, err := s.archivedBefore()
+	if err != nil || !t.Before(archived) {
+		return hot, err
+	}
+	cold, err := s.cold.Read(context.Background(), time.Time{}, t, uids...)
+	if err != nil {
+		return nil, err
+	}
+	return mergeSnapshots(cold, hot), nil
+}
+
+// EventsBetween returns the object versions recorded in (from, to], oldest
+// first
+func (s *PostgresStore) EventsBetween(from, to time.Time, uids ...string) ([]types.StateEvent, error) {
+	hot, err := s.queryVersions("object_versions", `
+		WHERE v.timestamp > $1 AND v.timestamp <= $2
+		  AND (cardinality($3::text[]) = 0 OR v.uid = ANY($3::text[]))
+		ORDER BY v.timestamp ASC
+	`, from, to, uidArray(uids))
+	if err != nil {
+		return nil, err
+	}
+	archived, err := s.archivedBefore()
+	if err != nil || !from.Before(archived) {
+		return hot, err
+	}
+	cold, err := s.cold.Read(context.Background(), from, to, uids...)
+	if err != nil {
+		return nil, err
+	}
+	return mergeVersions(cold, hot), nil
+}
+
+func (s *PostgresStore) queryVersions(source, where string, args ...interface{}) ([]types.StateEvent, error) {
+	rows, err := s.db.Query(fmt.Sprintf(versionQuery, source)+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]types.StateEvent, 0)
+	for rows.Next() {
+		var event types.StateEvent
+		var labelsJSON, fieldsJSON []byte
+		var createdAt sql.NullTime
+		if err := rows.Scan(&event.UID, &event.Version, &event.Timestamp, &event.Actor,
+			&event.Kind, &event.Namespace, &event.Name, &labelsJSON, &createdAt, &event.Tier,
+			&event.OwnerUID, &fieldsJSON); err != nil {
+			return nil, err
+		}
+		if createdAt.Valid {
+			event.CreationTimestamp = createdAt.Time
+		}
+		if len(labelsJSON) > 0 {
+			json.Unmarshal(labelsJSON, &event.Labels)
+		}
+		json.Unmarshal(fieldsJSON, &event.FieldDiff)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// uidArray encodes a UID filter; a nil slice would become NULL rather than
+// the empty array meaning "every UID"
+func uidArray(uids []string) interface{} {
+	if uids == nil {
+		uids = []string{}
+	}
+	return pq.Array(uids)
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+// Termination is one eviction or OOM kill of a pod
+type Termination struct {
+	UID          string    `json:"uid"`
+	Namespace    string    `json:"namespace"`
+	Name         string    `json:"name"`
+	Node         string    `json:"node,omitempty"`
+	Container    string    `json:"container,omitempty"`
+	Reason       string    `json:"reason"`
+	Message      string    `json:"message,omitempty"`
+	RestartCount int       `json:"restart_count"`
+	Actor        string    `json:"actor"`
+	OccurredAt   time.Time `json:"occurred_at"`
+}
+
+// Terminations extracts the eviction and OOM kill carried by a Pod event.
+// An eviction has no container and is dated by the event that reported it.
+func Terminations(event types.StateEvent) []Termination {
+	if event.Kind != "Pod" {
+		return nil
+	}
+
+	base := Termination{
+		UID:       event.UID,
+		Namespace: event.Namespace,
+		Name:      event.Name,
+		Actor:     event.Actor,
+	}
+	base.Node, _ = event.FieldDiff["spec.nodeName"].(string)
+	if restarts, ok := event.FieldDiff[watcher.FieldRestartCount].(int); ok {
+		base.RestartCount = restarts
+	} else if restarts, ok := event.FieldDiff[watcher.FieldRestartCount].(float64); ok {
+		base.RestartCount = int(restarts)
+	}
+
+	var terminations []Termination
+	if reason, _ := event.FieldDiff[watcher.FieldStatusReason].(string); reason == watcher.ReasonEvicted {
+		t := base
+		t.Reason = reason
+		t.Message, _ = event.FieldDiff[watcher.FieldStatusMessage].(string)
+		t.OccurredAt = event.Timestamp
+		terminations = append(terminations, t)
+	}
+	if reason, _ := event.FieldDiff[watcher.FieldLastTerminationReason].(string); reason == watcher.ReasonOOMKilled {
+		t := base
+		t.Reason = reason
+		t.Container, _ = event.FieldDiff[watcher.FieldLastTerminationContainer].(string)
+		t.OccurredAt = event.Timestamp
+		if at, ok := event.FieldDiff[watcher.FieldLastTerminationAt].(string); ok {
+			if parsed, err := time.Parse(time.RFC3339, at); err == nil {
+				t.OccurredAt = parsed
+			}
+		}
+		terminations = append(terminations, t)
+	}
+	return terminations
+}
+
+// recordTerminations appends new evictions and OOM kills to
+// pod_terminations. Resyncs of the same pod state are ignored: an eviction
+// is recorded once per pod and an OOM kill once per container and time.
+func (s *PostgresStore) recordTerminations(tx *sql.Tx, event types.StateEvent) error {
+	for _, t := range Terminations(event) {
+		if t.Reason == watcher.ReasonEvicted {
+			var recorded bool
+			err := tx.QueryRow(`
+				SELECT EXISTS (SELECT 1 FROM pod_terminations WHERE uid = $1 AND reason = $2)
+			`, t.UID, t.Reason).Scan(&recorded)
+			if err != nil {
+				return fmt.Errorf("failed to check previous eviction: %w", err)
+			}
+			if recorded {
+				continue
+			}
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO pod_terminations (
+				uid, namespace, name, node, container, reason, message,
+				restart_count, actor, occurred_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (uid, container, reason, occurred_at) DO NOTHING
+		`, t.UID, t.Namespace, t.Name, nullString(t.Node), t.Container, t.Reason,
+			nullString(t.Message), t.RestartCount, t.Actor, t.OccurredAt)
+		if err != nil {
+			return fmt.Errorf("failed to insert pod termination: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetTerminations returns recorded terminations, newest first. Empty
+// namespace, name or reason match everything.
+func (s *PostgresStore) GetTerminations(namespace, name, reason string, limit int) ([]Termination, error) {
+	rows, err := s.db.Query(`
+		SELECT uid, COALESCE(namespace, ''), name, COALESCE(node, ''), container, reason,
+		       COALESCE(message, ''), COALESCE(restart_count, 0), COALESCE(actor, ''), occurred_at
+		FROM pod_terminations
+		WHERE ($1 = '' OR namespace = $1) AND ($2 = '' OR name = $2) AND ($3 = '' OR reason = $3)
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $4
+	`, namespace, name, reason, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	terminations := make([]Termination, 0)
+	for rows.Next() {
+		var t Termination
+		if err := rows.Scan(
+			&t.UID, &t.Namespace, &t.Name, &t.Node, &t.Container, &t.Reason,
+			&t.Message, &t.RestartCount, &t.Actor, &t.OccurredAt,
+		); err != nil {
+			continue
+		}
+		terminations = append(terminations, t)
+	}
+	return terminations, nil
+}
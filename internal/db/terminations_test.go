@@ -0,0 +1,81 @@
+package db
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestTerminations(t *testing.T) {
+	finished := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	event := types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api", Timestamp: time.Now(), Actor: "kubelet/node-1",
+		FieldDiff: map[string]interface{}{
+			"spec.nodeName":  "node-1",
+			"status.reason":  "Evicted",
+			"status.message": "The node was low on resource: memory.",
+			"status.containerStatuses.lastTermination.reason":     "OOMKilled",
+			"status.containerStatuses.lastTermination.container":  "app",
+			"status.containerStatuses.lastTermination.finishedAt": finished.Format(time.RFC3339),
+			"status.containerStatuses.restartCount":               float64(7),
+		},
+	}
+
+	terminations := Terminations(event)
+	if len(terminations) != 2 {
+		t.Fatalf("Expected an eviction and an OOM kill, got %d", len(terminations))
+	}
+	if terminations[0].Reason != "Evicted" || terminations[0].Node != "node-1" || terminations[0].Message == "" {
+		t.Errorf("Unexpected eviction: %+v", terminations[0])
+	}
+	oom := terminations[1]
+	if oom.Container != "app" || !oom.OccurredAt.Equal(finished) || oom.RestartCount != 7 {
+		t.Errorf("Unexpected OOM kill: %+v", oom)
+	}
+
+	event.Kind = "Node"
+	if len(Terminations(event)) != 0 {
+		t.Error("Expected only pods to carry terminations")
+	}
+}
+
+// TestTerminationHistory tests that resyncs don't duplicate terminations
+func TestTerminationHistory(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	if store == nil {
+		return
+	}
+	defer cleanup()
+
+	finished := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	for i, reason := range []string{"Evicted", "Evicted"} {
+		event := types.StateEvent{
+			UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api",
+			Version: strconv.Itoa(i + 1), Timestamp: time.Now(), Actor: "kubelet",
+			FieldDiff: map[string]interface{}{
+				"status.reason": reason,
+				"status.containerStatuses.lastTermination.reason":     "OOMKilled",
+				"status.containerStatuses.lastTermination.container":  "app",
+				"status.containerStatuses.lastTermination.finishedAt": finished.Format(time.RFC3339),
+			},
+		}
+		if err := store.Record(event); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+
+	all, err := store.GetTerminations("prod", "", "", 10)
+	if err != nil {
+		t.Fatalf("Failed to get terminations: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("Expected one eviction and one OOM kill, got %d", len(all))
+	}
+
+	ooms, _ := store.GetTerminations("", "api", "OOMKilled", 10)
+	if len(ooms) != 1 || ooms[0].Container != "app" {
+		t.Errorf("Expected the OOM kill, got %+v", ooms)
+	}
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"github.com/aonescu/akari/internal/criticality"
+	"github.com/aonescu/akari/internal/state"
+)
+
+// SaveTierOverride stores the API-set tier of an object; an empty tier
+// clears the override
+func (s *PostgresStore) SaveTierOverride(uid string, tier criticality.Tier) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+	_, err := s.db.Exec(`
+		UPDATE objects SET tier_override = $2 WHERE uid = $1
+	`, uid, nullString(string(tier)))
+	return err
+}
+
+// LoadTierOverrides returns every API-set tier by object UID
+func (s *PostgresStore) LoadTierOverrides() (map[string]criticality.Tier, error) {
+	rows, err := s.db.Query(`SELECT uid, tier_override FROM objects WHERE tier_override IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := make(map[string]criticality.Tier)
+	for rows.Next() {
+		var uid, tier string
+		if err := rows.Scan(&uid, &tier); err != nil {
+			continue
+		}
+		overrides[uid] = criticality.Tier(tier)
+	}
+	return overrides, nil
+}
@@ -0,0 +1,458 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/criticality"
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+)
+
+// DefaultMaintenanceInterval is how often RunViolationMaintenance creates
+// upcoming partitions and archives old violations
+const DefaultMaintenanceInterval = 6 * time.Hour
+
+// violationsSchema partitions violations by the month they were detected.
+// Rows outside every monthly partition land in violations_default.
+const violationsSchema = `
+	-- Violations: active failures
+	CREATE TABLE IF NOT EXISTS violations (
+		violation_id UUID NOT NULL DEFAULT gen_random_uuid(),
+		invariant_id TEXT NOT NULL,
+		uid TEXT NOT NULL,
+		resource_kind TEXT NOT NULL,
+		resource_name TEXT NOT NULL,
+		namespace TEXT,
+		detected_at TIMESTAMP NOT NULL,
+		responsible_actor TEXT,
+		eliminated_actors JSONB,
+		reason TEXT,
+		severity TEXT,
+		resolved_at TIMESTAMP,
+		resolution_reason TEXT,
+		invariant_version INT,
+		tier TEXT,
+		logical_resource TEXT,
+		PRIMARY KEY (violation_id, detected_at)
+	) PARTITION BY RANGE (detected_at);
+	CREATE TABLE IF NOT EXISTS violations_default PARTITION OF violations DEFAULT;
+	CREATE INDEX IF NOT EXISTS idx_violations_invariant ON violations(invariant_id);
+	CREATE INDEX IF NOT EXISTS idx_violations_detected ON violations(detected_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_violations_active ON violations(resolved_at) WHERE resolved_at IS NULL;
+	ALTER TABLE violations ADD COLUMN IF NOT EXISTS logical_resource TEXT;
+	ALTER TABLE violations ADD COLUMN IF NOT EXISTS correlation_id TEXT;
+
+	-- Archived violations: resolved violations moved out of the partitions,
+	-- one gzip-compressed batch of JSON lines per detection month
+	CREATE TABLE IF NOT EXISTS violations_archive (
+		id SERIAL PRIMARY KEY,
+		month DATE NOT NULL,
+		row_count INT NOT NULL,
+		oldest TIMESTAMP NOT NULL,
+		newest TIMESTAMP NOT NULL,
+		payload BYTEA NOT NULL,
+		archived_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_violations_archive_newest ON violations_archive(newest DESC);
+`
+
+// violationColumns are copied when converting an unpartitioned table
+const violationColumns = `violation_id, invariant_id, uid, resource_kind, resource_name, namespace,
+	detected_at, responsible_actor, eliminated_actors, reason, severity,
+	resolved_at, resolution_reason, invariant_version, tier`
+
+// archivedViolation is one line of an archive batch
+type archivedViolation struct {
+	*engine.ViolationResult
+	ResolvedAt       time.Time `json:"resolved_at"`
+	ResolutionReason string    `json:"resolution_reason,omitempty"`
+}
+
+// initViolationsSchema creates the partitioned violations table, converting
+// the unpartitioned one earlier releases created, and the partitions for
+// the current and next month
+func (s *PostgresStore) initViolationsSchema(now time.Time) error {
+	var kind sql.NullString
+	if err := s.db.QueryRow(`SELECT relkind::text FROM pg_class WHERE oid = to_regclass('violations')`).Scan(&kind); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if kind.String == "r" {
+		if err := s.partitionViolations(now); err != nil {
+			return fmt.Errorf("failed to partition violations: %w", err)
+		}
+	} else if _, err := s.db.Exec(violationsSchema); err != nil {
+		return err
+	}
+	return s.EnsureViolationPartitions(now)
+}
+
+// partitionViolations moves the rows of an unpartitioned violations table
+// into a partitioned one in a single transaction
+func (s *PostgresStore) partitionViolations(now time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		ALTER TABLE violations ADD COLUMN IF NOT EXISTS invariant_version INT;
+		ALTER TABLE violations ADD COLUMN IF NOT EXISTS tier TEXT;
+		DROP INDEX IF EXISTS idx_violations_invariant, idx_violations_detected, idx_violations_active;
+		ALTER TABLE violations RENAME CONSTRAINT violations_pkey TO violations_unpartitioned_pkey;
+		ALTER TABLE violations RENAME TO violations_unpartitioned;
+	`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(violationsSchema); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT DISTINCT date_trunc('month', detected_at) FROM violations_unpartitioned`)
+	if err != nil {
+		return err
+	}
+	var months []time.Time
+	for rows.Next() {
+		var month time.Time
+		if err := rows.Scan(&month); err != nil {
+			rows.Close()
+			return err
+		}
+		months = append(months, month)
+	}
+	rows.Close()
+	for _, month := range append(months, now) {
+		if _, err := tx.Exec(partitionDDL(month)); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`INSERT INTO violations (` + violationColumns + `) SELECT ` + violationColumns + ` FROM violations_unpartitioned`)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DROP TABLE violations_unpartitioned`); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	moved, _ := result.RowsAffected()
+	log.Printf("Partitioned violations by month, moved %d rows", moved)
+	return nil
+}
+
+// EnsureViolationPartitions creates the partitions for now's month and the
+// next, so inserts never fall through to the default partition
+func (s *PostgresStore) EnsureViolationPartitions(now time.Time) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+	month := monthStart(now)
+	for _, m := range []time.Time{month, month.AddDate(0, 1, 0)} {
+		if _, err := s.db.Exec(partitionDDL(m)); err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", partitionName(m), err)
+		}
+	}
+	return nil
+}
+
+// ResolveViolation marks the open violation of v's invariant and resource
+// resolved at the given time
+func (s *PostgresStore) ResolveViolation(v *engine.ViolationResult, at time.Time) error {
+	if s.readOnly {
+		return state.ErrReadOnly
+	}
+	_, err := s.db.Exec(`
+		UPDATE violations SET resolved_at = $3, resolution_reason = 'no longer violated'
+		WHERE invariant_id = $1 AND resource_name = $2 AND resolved_at IS NULL
+	`, v.InvariantID, v.AffectedResource, at)
+	return err
+}
+
+// HandleTransition persists opened violations and resolves closed ones, so
+// the violations table holds their full history
+func (s *PostgresStore) HandleTransition(t engine.Transition) {
+	var err error
+	switch t.Type {
+	case engine.TransitionOpened:
+		v := *t.Violation
+		if v.DetectedAt.IsZero() {
+			v.DetectedAt = t.At
+		}
+		err = s.RecordViolation(&v)
+	case engine.TransitionResolved:
+		err = s.ResolveViolation(t.Violation, t.At)
+	}
+	if err != nil {
+		log.Printf("Failed to persist %s violation %s: %v", t.Type, t.Violation.Fingerprint(), err)
+	}
+}
+
+// ArchiveViolations moves violations resolved before cutoff into
+// violations_archive, one compressed batch per detection month, and drops
+// the monthly partitions left empty. It returns the number of violations
+// archived.
+func (s *PostgresStore) ArchiveViolations(cutoff time.Time) (int, error) {
+	if s.readOnly {
+		return 0, state.ErrReadOnly
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		DELETE FROM violations WHERE resolved_at < $1
+		RETURNING invariant_id, COALESCE(invariant_version, 0), resource_name, detected_at,
+		          responsible_actor, eliminated_actors, reason, severity, resolved_at,
+		          COALESCE(resolution_reason, ''), NULLIF(uid, 'unknown'), tier, COALESCE(logical_resource, '')
+	`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	byMonth := make(map[time.Time][]archivedViolation)
+	archived := 0
+	for rows.Next() {
+		var v engine.ViolationResult
+		var a archivedViolation
+		var eliminatedJSON []byte
+		var actor, reason, severity, uid, tier sql.NullString
+		if err := rows.Scan(
+			&v.InvariantID, &v.InvariantVersion, &v.AffectedResource, &v.DetectedAt,
+			&actor, &eliminatedJSON, &reason, &severity, &a.ResolvedAt,
+			&a.ResolutionReason, &uid, &tier, &v.LogicalResource,
+		); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		v.ResponsibleActor = actor.String
+		v.Reason = reason.String
+		v.Severity = dsl.Severity(severity.String)
+		v.ResourceUID = uid.String
+		v.Tier = criticality.Tier(tier.String)
+		json.Unmarshal(eliminatedJSON, &v.EliminatedActors)
+		a.ViolationResult = &v
+
+		month := monthStart(v.DetectedAt)
+		byMonth[month] = append(byMonth[month], a)
+		archived++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for month, batch := range byMonth {
+		payload, err := encodeArchive(batch)
+		if err != nil {
+			return 0, err
+		}
+		oldest, newest := batch[0].DetectedAt, batch[0].DetectedAt
+		for _, a := range batch {
+			if a.DetectedAt.Before(oldest) {
+				oldest = a.DetectedAt
+			}
+			if a.DetectedAt.After(newest) {
+				newest = a.DetectedAt
+			}
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO violations_archive (month, row_count, oldest, newest, payload)
+			VALUES ($1, $2, $3, $4, $5)
+		`, month, len(batch), oldest, newest, payload); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if err := s.dropEmptyPartitions(cutoff); err != nil {
+		log.Printf("Failed to drop archived violation partitions: %v", err)
+	}
+	return archived, nil
+}
+
+// dropEmptyPartitions drops the monthly partitions ending before cutoff's
+// month that archiving emptied
+func (s *PostgresStore) dropEmptyPartitions(cutoff time.Time) error {
+	rows, err := s.db.Query(`
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'violations'::regclass
+	`)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+
+	current := monthStart(cutoff)
+	for _, name := range names {
+		month, ok := parsePartitionName(name)
+		if !ok || !month.Before(current) {
+			continue
+		}
+		var empty bool
+		if err := s.db.QueryRow(`SELECT NOT EXISTS (SELECT 1 FROM ` + name + `)`).Scan(&empty); err != nil {
+			return err
+		}
+		if empty {
+			if _, err := s.db.Exec(`DROP TABLE ` + name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// archivedViolations returns up to limit archived violations matching q,
+// newest first. Batches are read newest first until no older batch can change the
+// result.
+func (s *PostgresStore) archivedViolations(q ViolationQuery, limit int) ([]*engine.ViolationResult, error) {
+	rows, err := s.db.Query(`SELECT newest, payload FROM violations_archive ORDER BY newest DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	violations := make([]*engine.ViolationResult, 0)
+	for rows.Next() {
+		var newest time.Time
+		var payload []byte
+		if err := rows.Scan(&newest, &payload); err != nil {
+			return nil, err
+		}
+		if len(violations) >= limit && newest.Before(violations[limit-1].DetectedAt) {
+			break
+		}
+		batch, err := decodeArchive(payload)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range batch {
+			if q.matches(a.ViolationResult) {
+				violations = append(violations, a.ViolationResult)
+			}
+		}
+		sortNewestFirst(violations)
+	}
+	if len(violations) > limit {
+		violations = violations[:limit]
+	}
+	return violations, rows.Err()
+}
+
+// RunViolationMaintenance creates upcoming partitions and, when archiveAfter
+// is positive, archives violations resolved longer ago than that, every
+// interval until ctx is cancelled
+func (s *PostgresStore) RunViolationMaintenance(ctx context.Context, interval, archiveAfter time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		if err := s.EnsureViolationPartitions(now); err != nil {
+			log.Printf("Failed to create violation partitions: %v", err)
+		}
+		if archiveAfter > 0 {
+			if n, err := s.ArchiveViolations(now.Add(-archiveAfter)); err != nil {
+				log.Printf("Failed to archive violations: %v", err)
+			} else if n > 0 {
+				log.Printf("Archived %d violations resolved before %s", n, now.Add(-archiveAfter).Format(time.RFC3339))
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// encodeArchive gzips a batch as JSON lines
+func encodeArchive(batch []archivedViolation) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, a := range batch {
+		if err := enc.Encode(a); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeArchive(payload []byte) ([]archivedViolation, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive batch: %w", err)
+	}
+	defer zr.Close()
+
+	var batch []archivedViolation
+	dec := json.NewDecoder(zr)
+	for dec.More() {
+		a := archivedViolation{ViolationResult: &engine.ViolationResult{}}
+		if err := dec.Decode(&a); err != nil {
+			return nil, fmt.Errorf("invalid archive batch: %w", err)
+		}
+		batch = append(batch, a)
+	}
+	return batch, nil
+}
+
+func sortNewestFirst(violations []*engine.ViolationResult) {
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].DetectedAt.After(violations[j].DetectedAt)
+	})
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// partitionName names the partition holding month's violations, such as
+// violations_2026_10
+func partitionName(month time.Time) string {
+	return fmt.Sprintf("violations_%04d_%02d", month.Year(), month.Month())
+}
+
+func parsePartitionName(name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, "violations_")
+	if !ok {
+		return time.Time{}, false
+	}
+	month, err := time.Parse("2006_01", suffix)
+	return month, err == nil
+}
+
+func partitionDDL(month time.Time) string {
+	month = monthStart(month)
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF violations FOR VALUES FROM ('%s') TO ('%s')`,
+		partitionName(month), month.Format(time.DateOnly), month.AddDate(0, 1, 0).Format(time.DateOnly))
+}
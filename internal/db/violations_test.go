@@ -0,0 +1,111 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+)
+
+func TestPartitionNames(t *testing.T) {
+	month := time.Date(2026, 12, 17, 9, 30, 0, 0, time.UTC)
+	if name := partitionName(monthStart(month)); name != "violations_2026_12" {
+		t.Errorf("Expected violations_2026_12, got %s", name)
+	}
+	ddl := partitionDDL(month)
+	if !strings.Contains(ddl, "violations_2026_12 PARTITION OF violations FOR VALUES FROM ('2026-12-01') TO ('2027-01-01')") {
+		t.Errorf("Unexpected partition DDL: %s", ddl)
+	}
+
+	parsed, ok := parsePartitionName("violations_2026_12")
+	if !ok || !parsed.Equal(time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected December 2026, got %v (%v)", parsed, ok)
+	}
+	for _, name := range []string{"violations_default", "violations_archive", "objects"} {
+		if _, ok := parsePartitionName(name); ok {
+			t.Errorf("Expected %s not to be a monthly partition", name)
+		}
+	}
+}
+
+func TestArchiveEncoding(t *testing.T) {
+	detected := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	batch := []archivedViolation{{
+		ViolationResult: &engine.ViolationResult{
+			InvariantID:      "pod_ready",
+			AffectedResource: "default/api",
+			ResponsibleActor: "kubelet",
+			Severity:         dsl.Critical,
+			DetectedAt:       detected,
+		},
+		ResolvedAt:       detected.Add(time.Hour),
+		ResolutionReason: "no longer violated",
+	}}
+
+	payload, err := encodeArchive(batch)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	decoded, err := decodeArchive(payload)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if len(decoded) != 1 {
+		t.Fatalf("Expected 1 archived violation, got %d", len(decoded))
+	}
+	got := decoded[0]
+	if got.InvariantID != "pod_ready" || got.Severity != dsl.Critical || !got.DetectedAt.Equal(detected) || !got.ResolvedAt.Equal(detected.Add(time.Hour)) {
+		t.Errorf("Unexpected archived violation: %+v %+v", got, got.ViolationResult)
+	}
+
+	if _, err := decodeArchive([]byte("not gzip")); err == nil {
+		t.Error("Expected an error for a corrupt batch")
+	}
+}
+
+func TestArchiveViolations(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	if store == nil {
+		return
+	}
+	defer cleanup()
+
+	old := time.Now().AddDate(0, -3, 0).UTC().Truncate(time.Second)
+	if err := store.EnsureViolationPartitions(old); err != nil {
+		t.Fatalf("Failed to create partitions: %v", err)
+	}
+	resolved := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/old", Severity: dsl.Critical, Violated: true, DetectedAt: old}
+	active := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/new", Severity: dsl.Critical, Violated: true, DetectedAt: time.Now()}
+	store.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: resolved, At: old})
+	store.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: resolved, At: old.Add(time.Minute)})
+	store.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: active, At: time.Now()})
+
+	n, err := store.ArchiveViolations(time.Now().AddDate(0, -1, 0))
+	if err != nil {
+		t.Fatalf("Failed to archive: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1 violation archived, got %d", n)
+	}
+
+	var live int
+	store.db.QueryRow("SELECT COUNT(*) FROM violations").Scan(&live)
+	if live != 1 {
+		t.Errorf("Expected only the active violation to stay partitioned, got %d", live)
+	}
+	var partitions int
+	store.db.QueryRow("SELECT COUNT(*) FROM pg_class WHERE relname = $1", partitionName(old)).Scan(&partitions)
+	if partitions != 0 {
+		t.Errorf("Expected the emptied partition %s to be dropped", partitionName(old))
+	}
+
+	violations, err := store.GetViolations("", engine.SortDetectedAt, 10)
+	if err != nil {
+		t.Fatalf("Failed to get violations: %v", err)
+	}
+	if len(violations) != 2 || violations[0].AffectedResource != "default/new" || violations[1].AffectedResource != "default/old" || violations[1].Violated {
+		t.Errorf("Expected the archived violation merged after the active one, got %+v", violations)
+	}
+}
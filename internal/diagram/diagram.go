@@ -0,0 +1,146 @@
+package diagram
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Formats a diagram renders to
+const (
+	DOT     = "dot"
+	Mermaid = "mermaid"
+)
+
+// Content types of the rendered formats
+var ContentTypes = map[string]string{
+	DOT:     "text/vnd.graphviz; charset=utf-8",
+	Mermaid: "text/plain; charset=utf-8",
+}
+
+// Node is a box in the diagram. Highlighted nodes are drawn as failing.
+type Node struct {
+	ID        string
+	Label     string
+	Highlight bool
+}
+
+// Edge is an arrow between two nodes by ID
+type Edge struct {
+	From  string
+	To    string
+	Label string
+}
+
+// Diagram is a directed graph to render. Nodes and edges are drawn in the
+// order added; adding a node or edge again is a no-op.
+type Diagram struct {
+	Title string
+	nodes []Node
+	edges []Edge
+	ids   map[string]int
+	seen  map[Edge]bool
+}
+
+// New returns an empty diagram
+func New(title string) *Diagram {
+	return &Diagram{Title: title, ids: make(map[string]int), seen: make(map[Edge]bool)}
+}
+
+// AddNode adds a node, highlighting it if any addition highlights it
+func (d *Diagram) AddNode(n Node) {
+	if i, ok := d.ids[n.ID]; ok {
+		d.nodes[i].Highlight = d.nodes[i].Highlight || n.Highlight
+		return
+	}
+	d.ids[n.ID] = len(d.nodes)
+	d.nodes = append(d.nodes, n)
+}
+
+// AddEdge adds an edge between nodes already added, ignoring edges to
+// unknown nodes
+func (d *Diagram) AddEdge(e Edge) {
+	_, from := d.ids[e.From]
+	_, to := d.ids[e.To]
+	if !from || !to || d.seen[e] {
+		return
+	}
+	d.seen[e] = true
+	d.edges = append(d.edges, e)
+}
+
+// Render writes the diagram in format, DOT or Mermaid
+func (d *Diagram) Render(w io.Writer, format string) error {
+	switch format {
+	case DOT:
+		return d.WriteDOT(w)
+	case Mermaid:
+		return d.WriteMermaid(w)
+	}
+	return fmt.Errorf("unknown diagram format %q", format)
+}
+
+// WriteDOT writes the diagram as a Graphviz digraph
+func (d *Diagram) WriteDOT(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "digraph %s {\n", dotQuote(d.Title))
+	fmt.Fprintln(b, "  rankdir=LR;")
+	fmt.Fprintln(b, "  node [shape=box];")
+	for _, n := range d.nodes {
+		style := ""
+		if n.Highlight {
+			style = ", color=red, fontcolor=red"
+		}
+		fmt.Fprintf(b, "  %s [label=%s%s];\n", dotQuote(n.ID), dotQuote(n.Label), style)
+	}
+	for _, e := range d.edges {
+		label := ""
+		if e.Label != "" {
+			label = " [label=" + dotQuote(e.Label) + "]"
+		}
+		fmt.Fprintf(b, "  %s -> %s%s;\n", dotQuote(e.From), dotQuote(e.To), label)
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
+// WriteMermaid writes the diagram as a Mermaid flowchart. Node IDs are
+// replaced with n0, n1, ... since Mermaid only accepts plain identifiers.
+func (d *Diagram) WriteMermaid(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	if d.Title != "" {
+		fmt.Fprintf(b, "---\ntitle: %s\n---\n", mermaidEscape(d.Title))
+	}
+	fmt.Fprintln(b, "flowchart LR")
+	var highlighted []string
+	for i, n := range d.nodes {
+		fmt.Fprintf(b, "  n%d[\"%s\"]\n", i, mermaidEscape(n.Label))
+		if n.Highlight {
+			highlighted = append(highlighted, fmt.Sprintf("n%d", i))
+		}
+	}
+	for _, e := range d.edges {
+		from, to := d.ids[e.From], d.ids[e.To]
+		if e.Label != "" {
+			fmt.Fprintf(b, "  n%d -->|\"%s\"| n%d\n", from, mermaidEscape(e.Label), to)
+		} else {
+			fmt.Fprintf(b, "  n%d --> n%d\n", from, to)
+		}
+	}
+	if len(highlighted) > 0 {
+		fmt.Fprintln(b, "  classDef failing stroke:#d00,color:#d00")
+		fmt.Fprintf(b, "  class %s failing\n", strings.Join(highlighted, ","))
+	}
+	return b.Flush()
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// mermaidEscape replaces the characters that end a quoted Mermaid label
+// with their entity codes, and newlines with line breaks
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "\n", "<br>", "|", "#124;").Replace(s)
+}
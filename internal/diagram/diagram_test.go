@@ -0,0 +1,58 @@
+package diagram
+
+import (
+	"strings"
+	"testing"
+)
+
+func sample() *Diagram {
+	d := New("pod_ready")
+	d.AddNode(Node{ID: "node_ready", Label: `Node "ready"`})
+	d.AddNode(Node{ID: "pod_ready", Label: "pod_ready\nPod should be ready"})
+	d.AddNode(Node{ID: "pod_ready", Highlight: true})
+	d.AddEdge(Edge{From: "node_ready", To: "pod_ready", Label: "node"})
+	d.AddEdge(Edge{From: "node_ready", To: "pod_ready", Label: "node"})
+	d.AddEdge(Edge{From: "missing", To: "pod_ready"})
+	return d
+}
+
+func TestWriteDOT(t *testing.T) {
+	var b strings.Builder
+	if err := sample().Render(&b, DOT); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	want := `digraph "pod_ready" {
+  rankdir=LR;
+  node [shape=box];
+  "node_ready" [label="Node \"ready\""];
+  "pod_ready" [label="pod_ready\nPod should be ready", color=red, fontcolor=red];
+  "node_ready" -> "pod_ready" [label="node"];
+}
+`
+	if b.String() != want {
+		t.Errorf("Unexpected DOT:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestWriteMermaid(t *testing.T) {
+	var b strings.Builder
+	if err := sample().Render(&b, Mermaid); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	want := `---
+title: pod_ready
+---
+flowchart LR
+  n0["Node #quot;ready#quot;"]
+  n1["pod_ready<br>Pod should be ready"]
+  n0 -->|"node"| n1
+  classDef failing stroke:#d00,color:#d00
+  class n1 failing
+`
+	if b.String() != want {
+		t.Errorf("Unexpected Mermaid:\n%s\nwant:\n%s", b.String(), want)
+	}
+	if err := sample().Render(&b, "svg"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,248 @@
+package drift
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+const (
+	// Actor is recorded on synthetic drift events
+	Actor = "akari-drift"
+	// Kind of the synthetic resources describing Deployment drift
+	Kind = "DeploymentDrift"
+
+	DefaultInterval = 30 * time.Second
+	// DefaultThreshold is how long pods may differ from their Deployment
+	// before it counts as drift; a normal rollout converges well within it
+	DefaultThreshold = 10 * time.Minute
+
+	historyDepth = 20
+)
+
+// Fields recorded on DeploymentDrift resources
+const (
+	FieldInSync      = "status.inSync"
+	FieldDriftedPods = "status.driftedPods"
+	FieldStalePods   = "status.stalePods"
+	FieldEditedPods  = "status.editedPods"
+	FieldDriftSince  = "status.driftSince"
+	FieldReason      = "status.driftReason"
+	FieldEditedBy    = "status.editedBy"
+
+	// ReasonStaleTemplate means pods of an older ReplicaSet are still
+	// running, typically a stuck rollout
+	ReasonStaleTemplate = "stale_template"
+	// ReasonPodEdited means a pod of the current template runs different
+	// images, i.e. someone changed the pod directly
+	ReasonPodEdited = "pod_edited"
+)
+
+const (
+	templateImagePrefix = "spec.template.spec.containers["
+	podImagePrefix      = "spec.containers["
+	imageSuffix         = "].image"
+)
+
+// Analyzer compares every Deployment's template with the pods it selects
+// and records a DeploymentDrift resource per Deployment, which the
+// deployment_in_sync invariant evaluates
+type Analyzer struct {
+	store     state.StateStore
+	threshold time.Duration
+
+	mu    sync.Mutex
+	since map[string]time.Time // Deployment UID -> first drifted analysis
+}
+
+func NewAnalyzer(store state.StateStore, threshold time.Duration) *Analyzer {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	return &Analyzer{
+		store:     store,
+		threshold: threshold,
+		since:     make(map[string]time.Time),
+	}
+}
+
+// Run analyzes on every interval until ctx is cancelled
+func (a *Analyzer) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, event := range a.AnalyzeOnce(time.Now()) {
+			if err := a.store.Record(event); err != nil {
+				log.Printf("Failed to record drift of %s/%s: %v", event.Namespace, event.Name, err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// AnalyzeOnce returns a DeploymentDrift event for every Deployment whose
+// current template hash is known
+func (a *Analyzer) AnalyzeOnce(now time.Time) []types.StateEvent {
+	pods := a.store.GetLatestByKind("Pod")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var events []types.StateEvent
+	for _, d := range a.store.GetLatestByKind("Deployment") {
+		hash, _ := d.FieldDiff[watcher.FieldTemplateHash].(string)
+		selector := watcher.Selector(d.FieldDiff)
+		if hash == "" || len(selector) == 0 {
+			continue
+		}
+		seen[d.UID] = true
+		events = append(events, a.analyze(d, hash, selector, pods, now))
+	}
+
+	for uid := range a.since {
+		if !seen[uid] {
+			delete(a.since, uid)
+		}
+	}
+	return events
+}
+
+func (a *Analyzer) analyze(d types.StateEvent, hash string, selector map[string]string, pods []types.StateEvent, now time.Time) types.StateEvent {
+	event := types.StateEvent{
+		UID:       "drift:" + d.UID,
+		Kind:      Kind,
+		Name:      d.Name,
+		Namespace: d.Namespace,
+		Labels:    d.Labels,
+		Tier:      d.Tier,
+		Version:   strconv.FormatInt(now.UnixNano(), 10),
+		Timestamp: now,
+		FieldDiff: map[string]interface{}{FieldInSync: "True"},
+		Actor:     Actor,
+	}
+
+	template := images(d.FieldDiff, templateImagePrefix)
+	var stale, edited []types.StateEvent
+	for _, pod := range pods {
+		if pod.Namespace != d.Namespace || !state.MatchesSelector(selector, pod.Labels) {
+			continue
+		}
+		podHash, labelled := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+		switch {
+		case labelled && podHash != hash:
+			stale = append(stale, pod)
+		case imagesDiffer(template, images(pod.FieldDiff, podImagePrefix)):
+			edited = append(edited, pod)
+		}
+	}
+
+	if len(stale) == 0 && len(edited) == 0 {
+		delete(a.since, d.UID)
+		return event
+	}
+
+	since, drifting := a.since[d.UID]
+	if !drifting {
+		since = now
+		a.since[d.UID] = since
+	}
+
+	drifted := make([]string, 0, len(stale)+len(edited))
+	for _, pod := range append(stale, edited...) {
+		drifted = append(drifted, pod.Name)
+	}
+	sort.Strings(drifted)
+
+	event.FieldDiff[FieldDriftedPods] = drifted
+	event.FieldDiff[FieldStalePods] = len(stale)
+	event.FieldDiff[FieldEditedPods] = len(edited)
+	event.FieldDiff[FieldDriftSince] = since.UTC().Format(time.RFC3339)
+	event.FieldDiff[FieldReason] = ReasonStaleTemplate
+	if len(edited) > 0 {
+		event.FieldDiff[FieldReason] = ReasonPodEdited
+		if editor := a.editor(edited); editor != "" {
+			event.FieldDiff[FieldEditedBy] = editor
+		}
+	}
+	if now.Sub(since) >= a.threshold {
+		event.FieldDiff[FieldInSync] = "False"
+	}
+	return event
+}
+
+// editor returns the most recent non-system actor found in the history of
+// the edited pods
+func (a *Analyzer) editor(pods []types.StateEvent) string {
+	var actor string
+	var latest time.Time
+	for _, pod := range pods {
+		history := []types.StateEvent{pod}
+		if reader, ok := a.store.(state.HistoryReader); ok {
+			if h, err := reader.GetHistory(pod.UID, historyDepth); err == nil {
+				history = h
+			}
+		}
+		for _, event := range history {
+			if isSystemActor(event.Actor) || !event.Timestamp.After(latest) {
+				continue
+			}
+			actor, latest = event.Actor, event.Timestamp
+		}
+	}
+	return actor
+}
+
+// isSystemActor reports whether an actor is a cluster component rather
+// than a person or pipeline that could have edited a pod
+func isSystemActor(actor string) bool {
+	switch {
+	case actor == "", actor == Actor:
+		return true
+	case strings.HasPrefix(actor, "kubelet"), strings.HasPrefix(actor, "kube-"), strings.HasPrefix(actor, "system:"):
+		return true
+	case strings.HasSuffix(actor, "-controller"):
+		return true
+	}
+	return false
+}
+
+// imagesDiffer reports whether any container of the template runs a
+// different image in the pod
+func imagesDiffer(template, pod map[string]string) bool {
+	for container, image := range template {
+		if running, ok := pod[container]; ok && running != image {
+			return true
+		}
+	}
+	return false
+}
+
+func images(fields map[string]interface{}, prefix string) map[string]string {
+	result := make(map[string]string)
+	for field, value := range fields {
+		image, ok := value.(string)
+		if !ok || !strings.HasPrefix(field, prefix) || !strings.HasSuffix(field, imageSuffix) {
+			continue
+		}
+		result[strings.TrimSuffix(strings.TrimPrefix(field, prefix), imageSuffix)] = image
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package drift
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func recordDeployment(store state.StateStore) {
+	store.Record(types.StateEvent{
+		UID: "deploy-1", Kind: "Deployment", Namespace: "prod", Name: "api", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{
+			"status.templateHash":                      "v2hash",
+			"spec.selector":                            map[string]interface{}{"app": "api"},
+			"spec.template.spec.containers[api].image": "api:v2",
+		},
+		Actor: "deployment-controller",
+	})
+}
+
+func recordPod(store state.StateStore, uid, hash, image, actor string, at time.Time) {
+	store.Record(types.StateEvent{
+		UID: uid, Kind: "Pod", Namespace: "prod", Name: uid, Version: at.String(), Timestamp: at,
+		Labels:    map[string]string{"app": "api", "pod-template-hash": hash},
+		FieldDiff: map[string]interface{}{"spec.containers[api].image": image},
+		Actor:     actor,
+	})
+}
+
+func TestAnalyzer_InSync(t *testing.T) {
+	store := state.NewMemoryStore()
+	recordDeployment(store)
+	recordPod(store, "api-a", "v2hash", "api:v2", "kubelet/node-1", time.Now())
+
+	events := NewAnalyzer(store, time.Minute).AnalyzeOnce(time.Now())
+	if len(events) != 1 || events[0].FieldDiff[FieldInSync] != "True" {
+		t.Fatalf("Expected an in-sync deployment, got %+v", events)
+	}
+}
+
+func TestAnalyzer_StaleTemplateAfterThreshold(t *testing.T) {
+	store := state.NewMemoryStore()
+	recordDeployment(store)
+	recordPod(store, "api-old", "v1hash", "api:v1", "kubelet/node-1", time.Now())
+
+	a := NewAnalyzer(store, 10*time.Minute)
+	start := time.Now()
+
+	first := a.AnalyzeOnce(start)[0]
+	if first.FieldDiff[FieldInSync] != "True" || first.FieldDiff[FieldReason] != ReasonStaleTemplate {
+		t.Errorf("Expected drift within the threshold to be tolerated, got %v", first.FieldDiff)
+	}
+
+	later := a.AnalyzeOnce(start.Add(11 * time.Minute))[0]
+	if later.FieldDiff[FieldInSync] != "False" {
+		t.Errorf("Expected drift beyond the threshold to be flagged, got %v", later.FieldDiff)
+	}
+	if later.FieldDiff[FieldDriftSince] != start.UTC().Format(time.RFC3339) {
+		t.Errorf("Expected drift to be dated from its first observation, got %v", later.FieldDiff[FieldDriftSince])
+	}
+}
+
+func TestAnalyzer_EditedPodBlamesEditor(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	recordDeployment(store)
+
+	created := time.Now().Add(-time.Hour)
+	recordPod(store, "api-a", "v2hash", "api:v2", "replicaset-controller", created)
+	recordPod(store, "api-a", "v2hash", "api:debug", "kubectl-edit", created.Add(time.Minute))
+	recordPod(store, "api-a", "v2hash", "api:debug", "kubelet/node-1", created.Add(2*time.Minute))
+
+	a := NewAnalyzer(store, time.Nanosecond)
+	a.AnalyzeOnce(time.Now().Add(-time.Second))
+	for _, event := range a.AnalyzeOnce(time.Now()) {
+		store.Record(event)
+	}
+
+	event, _ := store.GetByUID("drift:deploy-1")
+	if event.FieldDiff[FieldReason] != ReasonPodEdited || event.FieldDiff[FieldEditedBy] != "kubectl-edit" {
+		t.Errorf("Expected the edit to be attributed to kubectl-edit, got %v", event.FieldDiff)
+	}
+
+	var result *engine.ViolationResult
+	for _, v := range eng.EvaluateAll() {
+		if v.InvariantID == "deployment_in_sync" {
+			result = v
+		}
+	}
+	if result == nil || result.ResponsibleActor != "kubectl-edit" {
+		t.Errorf("Expected deployment_in_sync to blame kubectl-edit, got %+v", result)
+	}
+}
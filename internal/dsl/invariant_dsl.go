@@ -1,19 +1,36 @@
 package dsl
 
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+)
+
 type Operator string
 
 const (
-	Equals      Operator = "equals"
-	NotEquals   Operator = "not_equals"
-	Exists      Operator = "exists"
-	NotExists   Operator = "not_exists"
-	GreaterThan Operator = "gt"
-	LessThan    Operator = "lt"
-	Contains    Operator = "contains"
-	AnyTrue     Operator = "any_true"
-	AllTrue     Operator = "all_true"
+	Equals         Operator = "equals"
+	NotEquals      Operator = "not_equals"
+	Exists         Operator = "exists"
+	NotExists      Operator = "not_exists"
+	GreaterThan    Operator = "gt"
+	GreaterOrEqual Operator = "gte"
+	LessThan       Operator = "lt"
+	LessOrEqual    Operator = "lte"
+	Contains       Operator = "contains"
+	AnyTrue        Operator = "any_true"
+	AllTrue        Operator = "all_true"
+	OlderThan      Operator = "older_than"
+	NewerThan      Operator = "newer_than"
 )
 
+// CreationTimestampField resolves to the resource's creation time, so
+// predicates can reason about resource age
+const CreationTimestampField = "metadata.creationTimestamp"
+
 type Relation string
 
 const (
@@ -21,6 +38,9 @@ const (
 	Owner    Relation = "owner"
 	Selector Relation = "selector"
 	Node     Relation = "node"
+	// Any relates the subject to every resource of the target kind, e.g. a
+	// workload to the cluster's DNS pods
+	Any Relation = "any"
 )
 
 type Severity string
@@ -31,14 +51,147 @@ const (
 	Warning  Severity = "warning"
 )
 
+// Urgency decides how a violation pages: high urgency notifies on-call
+// immediately, low urgency waits for working hours, none doesn't page
+type Urgency string
+
+const (
+	UrgencyHigh Urgency = "high"
+	UrgencyLow  Urgency = "low"
+	UrgencyNone Urgency = "none"
+)
+
+// Well-known invariant tags. Tags are free-form; these classify findings
+// for the teams that own them.
+const (
+	TagAvailability = "availability"
+	TagSecurity     = "security"
+	TagCost         = "cost"
+	TagBestPractice = "best-practice"
+	// TagManifest marks invariants decidable from a resource's spec alone,
+	// which akari lint evaluates against manifests offline
+	TagManifest = "manifest"
+)
+
 type Predicate struct {
 	Field    string      `json:"field"`
 	Operator Operator    `json:"operator"`
 	Value    interface{} `json:"value,omitempty"`
+	// ValueFrom compares against another field instead of Value, e.g.
+	// status.readyReplicas gte spec.replicas
+	ValueFrom *FieldRef `json:"value_from,omitempty"`
+}
+
+// FieldRef names a field on the subject itself or, through an owner or
+// node relation, on a related resource
+type FieldRef struct {
+	Field    string   `json:"field"`
+	Relation Relation `json:"relation,omitempty"`
+	// Percent scales a numeric field, e.g. 80 compares against 80% of
+	// spec.replicas. Zero uses the field as is.
+	Percent float64 `json:"percent,omitempty"`
 }
 
+// Scope picks the resources a requirement is checked on, relative to the
+// subject: the subject itself, its owner, the node it runs on, the
+// resources its selector matches, or any resource of a kind
 type Scope struct {
 	Relation Relation `json:"relation"`
+	// Kind is the kind of the required resources, defaulting to the
+	// required invariant's subject kind
+	Kind string `json:"kind,omitempty"`
+	// Namespace decides where namespaced resources are looked up for the
+	// selector and any relations. Cluster-scoped ones always qualify.
+	Namespace NamespacePolicy `json:"namespace,omitempty"`
+	// Namespaces lists the namespaces looked in, in place of a policy
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Selector narrows the related resources to those carrying every label
+	Selector map[string]string `json:"selector,omitempty"`
+	// Args parameterize the relation, see ArgField and ArgMatch
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// NamespacePolicy is where a requirement looks for related resources
+type NamespacePolicy string
+
+const (
+	// NamespaceSame looks in the subject's namespace, the default
+	NamespaceSame NamespacePolicy = "same"
+	NamespaceAny  NamespacePolicy = "any"
+)
+
+// Arguments of requirement relations
+const (
+	// ArgField names the field holding the reference the relation follows,
+	// in place of spec.nodeName for node, the controller owner reference
+	// for owner and spec.selector for selector
+	ArgField = "field"
+	// ArgMatch is all, the default, when every related resource must
+	// satisfy the required invariant, or any when one is enough
+	ArgMatch = "match"
+)
+
+// Values of ArgMatch
+const (
+	MatchAll = "all"
+	MatchAny = "any"
+)
+
+// Match returns how many related resources must satisfy the requirement
+func (s Scope) Match() string {
+	if s.Args[ArgMatch] == MatchAny {
+		return MatchAny
+	}
+	return MatchAll
+}
+
+// InNamespace reports whether a resource in namespace qualifies for a
+// subject in subjectNamespace
+func (s Scope) InNamespace(namespace, subjectNamespace string) bool {
+	switch {
+	case namespace == "":
+		return true
+	case len(s.Namespaces) > 0:
+		return slices.Contains(s.Namespaces, namespace)
+	case s.Namespace == NamespaceAny:
+		return true
+	}
+	return namespace == subjectNamespace
+}
+
+func (s Scope) validate() error {
+	switch s.Relation {
+	case Same, Owner, Node, Selector, Any:
+	default:
+		return fmt.Errorf("relation must be one of same, owner, node, selector, any")
+	}
+	switch s.Namespace {
+	case "", NamespaceAny:
+	case NamespaceSame:
+		if len(s.Namespaces) > 0 {
+			return fmt.Errorf("namespace same cannot be combined with namespaces")
+		}
+	default:
+		return fmt.Errorf("namespace must be one of same, any")
+	}
+	if s.Relation == Same && (s.Kind != "" || s.Namespace != "" || len(s.Namespaces) > 0 || len(s.Selector) > 0 || len(s.Args) > 0) {
+		return fmt.Errorf("relation same takes no kind, namespaces, selector or args")
+	}
+	for key, value := range s.Args {
+		switch key {
+		case ArgField:
+			if s.Relation == Any || value == "" {
+				return fmt.Errorf("args.field must name a field of the subject and needs relation owner, node or selector")
+			}
+		case ArgMatch:
+			if value != MatchAll && value != MatchAny {
+				return fmt.Errorf("args.match must be one of all, any")
+			}
+		default:
+			return fmt.Errorf("unknown argument %q, expected field or match", key)
+		}
+	}
+	return nil
 }
 
 type Requirement struct {
@@ -46,21 +199,83 @@ type Requirement struct {
 	Scope     Scope  `json:"scope"`
 }
 
+// SubjectScope limits a subject to cluster-scoped or namespaced resources
+type SubjectScope string
+
+const (
+	ScopeAny        SubjectScope = ""
+	ScopeCluster    SubjectScope = "cluster"
+	ScopeNamespaced SubjectScope = "namespaced"
+)
+
+// Subject selects the resources an invariant applies to. The namespace
+// filters only ever match namespaced resources, except ExcludeNamespaces,
+// which leaves cluster-scoped ones alone.
 type Subject struct {
 	Kind      string            `json:"kind"`
 	Namespace string            `json:"namespace,omitempty"`
 	Selector  map[string]string `json:"selector,omitempty"`
+	Scope     SubjectScope      `json:"scope,omitempty"`
+	// Namespaces lists the namespaces to include, in addition to Namespace
+	Namespaces        []string `json:"namespaces,omitempty"`
+	ExcludeNamespaces []string `json:"exclude_namespaces,omitempty"`
+	// NamespaceSelector matches the labels of the resource's namespace
+	NamespaceSelector map[string]string `json:"namespace_selector,omitempty"`
+}
+
+// MatchesNamespace reports whether a resource in namespace, "" for a
+// cluster-scoped one, falls within the subject's scope and namespace
+// filters. labels are the namespace's labels.
+func (s Subject) MatchesNamespace(namespace string, labels map[string]string) bool {
+	switch s.Scope {
+	case ScopeCluster:
+		return namespace == ""
+	case ScopeNamespaced:
+		if namespace == "" {
+			return false
+		}
+	}
+	if namespace == "" {
+		return !s.filtersNamespaces()
+	}
+
+	if slices.Contains(s.ExcludeNamespaces, namespace) {
+		return false
+	}
+	if s.Namespace != "" || len(s.Namespaces) > 0 {
+		if namespace != s.Namespace && !slices.Contains(s.Namespaces, namespace) {
+			return false
+		}
+	}
+	for key, value := range s.NamespaceSelector {
+		if actual, ok := labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// filtersNamespaces reports whether the subject restricts which
+// namespaces match, which no cluster-scoped resource can satisfy
+func (s Subject) filtersNamespaces() bool {
+	return s.Namespace != "" || len(s.Namespaces) > 0 || len(s.NamespaceSelector) > 0
 }
 
 type Responsibility struct {
 	Primary   string `json:"primary"`
 	Secondary string `json:"secondary,omitempty"`
 	Team      string `json:"team,omitempty"`
+	// ActorField names a resource field holding the actor to blame, such
+	// as whoever deployed the workload. Primary is used when it is unset.
+	ActorField string `json:"actor_field,omitempty"`
 }
 
 type Invariant struct {
-	ID             string         `json:"id"`
-	Version        int            `json:"version"`
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+	// Extends names the invariant this one inherits the fields it leaves
+	// unset from, see Inherit
+	Extends        string         `json:"extends,omitempty"`
 	Description    string         `json:"description"`
 	Subject        Subject        `json:"subject"`
 	Predicate      *Predicate     `json:"predicate,omitempty"`
@@ -68,4 +283,188 @@ type Invariant struct {
 	Blocks         []string       `json:"blocks,omitempty"`
 	Responsibility Responsibility `json:"responsibility"`
 	Severity       Severity       `json:"severity"`
+	// Urgency overrides the pager's severity-to-urgency mapping
+	Urgency     Urgency    `json:"urgency,omitempty"`
+	Docs        string     `json:"docs,omitempty"`
+	RunbookURL  string     `json:"runbook_url,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Timeout     Duration   `json:"timeout,omitempty"`
+	GracePeriod Duration   `json:"grace_period,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// HasAnyTag reports whether the invariant carries at least one of tags.
+// An empty tag list matches every invariant.
+func (inv Invariant) HasAnyTag(tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, want := range tags {
+		for _, have := range inv.Tags {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Inherit fills the fields the invariant leaves unset from base, the
+// definition it extends, so it only needs to spell out what differs, such
+// as the predicate or the namespaces. Subject and responsibility are
+// inherited field by field; the ID, version and deletion are its own.
+func (inv Invariant) Inherit(base Invariant) Invariant {
+	s, b := &inv.Subject, base.Subject
+	fill(&s.Kind, b.Kind)
+	fill(&s.Namespace, b.Namespace)
+	fill(&s.Scope, b.Scope)
+	fillMap(&s.Selector, b.Selector)
+	fillNil(&s.Namespaces, b.Namespaces)
+	fillNil(&s.ExcludeNamespaces, b.ExcludeNamespaces)
+	fillMap(&s.NamespaceSelector, b.NamespaceSelector)
+
+	r, br := &inv.Responsibility, base.Responsibility
+	fill(&r.Primary, br.Primary)
+	fill(&r.Secondary, br.Secondary)
+	fill(&r.Team, br.Team)
+	fill(&r.ActorField, br.ActorField)
+
+	if inv.Predicate == nil && base.Predicate != nil {
+		pred := *base.Predicate
+		inv.Predicate = &pred
+	}
+	fillNil(&inv.Requires, base.Requires)
+	fillNil(&inv.Blocks, base.Blocks)
+	fillNil(&inv.Tags, base.Tags)
+	fill(&inv.Description, base.Description)
+	fill(&inv.Severity, base.Severity)
+	fill(&inv.Urgency, base.Urgency)
+	fill(&inv.Docs, base.Docs)
+	fill(&inv.RunbookURL, base.RunbookURL)
+	fill(&inv.Timeout, base.Timeout)
+	fill(&inv.GracePeriod, base.GracePeriod)
+	return inv
+}
+
+func fill[T comparable](field *T, base T) {
+	var zero T
+	if *field == zero {
+		*field = base
+	}
+}
+
+func fillNil[T any](field *[]T, base []T) {
+	if *field == nil {
+		*field = slices.Clone(base)
+	}
+}
+
+func fillMap(field *map[string]string, base map[string]string) {
+	if *field == nil {
+		*field = maps.Clone(base)
+	}
+}
+
+// Validate checks that the invariant can be registered
+func (inv Invariant) Validate() error {
+	if inv.ID == "" {
+		return fmt.Errorf("invariant id is required")
+	}
+	if inv.Subject.Kind == "" {
+		return fmt.Errorf("subject.kind is required")
+	}
+	switch inv.Subject.Scope {
+	case ScopeAny, ScopeNamespaced:
+	case ScopeCluster:
+		if inv.Subject.filtersNamespaces() || len(inv.Subject.ExcludeNamespaces) > 0 {
+			return fmt.Errorf("subject.scope cluster cannot be combined with namespace filters")
+		}
+	default:
+		return fmt.Errorf("subject.scope must be one of cluster, namespaced")
+	}
+	switch inv.Severity {
+	case Critical, Degraded, Warning:
+	default:
+		return fmt.Errorf("severity must be one of critical, degraded, warning")
+	}
+	switch inv.Urgency {
+	case "", UrgencyHigh, UrgencyLow, UrgencyNone:
+	default:
+		return fmt.Errorf("urgency must be one of high, low, none")
+	}
+	if inv.Predicate == nil && len(inv.Requires) == 0 {
+		return fmt.Errorf("invariant needs a predicate or at least one requirement")
+	}
+	for i, req := range inv.Requires {
+		if req.Invariant == "" {
+			return fmt.Errorf("requires[%d].invariant is required", i)
+		}
+		if err := req.Scope.validate(); err != nil {
+			return fmt.Errorf("requires[%d].scope: %w", i, err)
+		}
+	}
+	if pred := inv.Predicate; pred != nil && pred.ValueFrom != nil {
+		if pred.ValueFrom.Field == "" {
+			return fmt.Errorf("predicate.value_from.field is required")
+		}
+		if pred.Value != nil {
+			return fmt.Errorf("predicate cannot set both value and value_from")
+		}
+		if pred.ValueFrom.Percent < 0 {
+			return fmt.Errorf("predicate.value_from.percent cannot be negative")
+		}
+		switch pred.ValueFrom.Relation {
+		case "", Same, Owner, Node:
+		default:
+			return fmt.Errorf("predicate.value_from.relation must be one of same, owner, node")
+		}
+	}
+	return nil
+}
+
+// Duration is a time.Duration that encodes to JSON as a Go duration string
+// ("30s", "5m") and also accepts a plain number of seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	switch v := raw.(type) {
+	case float64:
+		*d = Duration(time.Duration(v * float64(time.Second)))
+	case string:
+		parsed, err := ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		*d = Duration(parsed)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("invalid duration: %s", string(data))
+	}
+	return nil
+}
+
+// ParseDuration parses a Go duration string, also accepting whole days
+// and weeks such as "7d" or "2w" on their own
+func ParseDuration(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if n := len(s); n > 1 {
+		if unit, ok := units[s[n-1]]; ok {
+			count, err := strconv.Atoi(s[:n-1])
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return time.ParseDuration(s)
 }
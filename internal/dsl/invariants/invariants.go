@@ -1,6 +1,55 @@
 package invariants
 
-import "github.com/aonescu/akari/internal/dsl"
+import (
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+)
+
+// rolloutGracePeriod covers normal image pulls and readiness probes of a
+// freshly created pod
+const rolloutGracePeriod = dsl.Duration(60 * time.Second)
+
+// replicaGracePeriod lets a rollout or scale-up bring new replicas up
+// before missing ones count
+const replicaGracePeriod = dsl.Duration(5 * time.Minute)
+
+// idleGracePeriod gives new workloads and claims time to be wired up
+// before they count as waste
+const idleGracePeriod = dsl.Duration(time.Hour)
+
+// overProvisionedRatio is the share of its requests a pod must use at peak
+const overProvisionedRatio = 0.2
+
+// securityResponsibility attributes configuration findings to whoever
+// deployed the workload; kubelet only runs what it was given
+var securityResponsibility = dsl.Responsibility{
+	Primary:    "workload-owner",
+	Team:       "security",
+	ActorField: "metadata.deployedBy",
+}
+
+// manifestResponsibility attributes manifest findings to their author
+var manifestResponsibility = dsl.Responsibility{
+	Primary:    "workload-owner",
+	Team:       "platform",
+	ActorField: "metadata.deployedBy",
+}
+
+// costResponsibility sends efficiency findings to the workload's owner
+var costResponsibility = dsl.Responsibility{
+	Primary:    "workload-owner",
+	Team:       "finops",
+	ActorField: "metadata.deployedBy",
+}
+
+// upgradeResponsibility sends version skew findings to the infrastructure
+// team, who upgrade the control plane and node pools
+var upgradeResponsibility = dsl.Responsibility{
+	Primary:   "cluster-upgrade",
+	Secondary: "node-controller",
+	Team:      "infrastructure",
+}
 
 // GetMVPInvariants returns the minimum viable set of invariants for Kubernetes resources
 func GetMVPInvariants() []dsl.Invariant {
@@ -19,6 +68,7 @@ func GetMVPInvariants() []dsl.Invariant {
 				Team:    "platform",
 			},
 			Severity: dsl.Critical,
+			Tags:     []string{dsl.TagAvailability},
 		},
 		{
 			ID:          "pod_scheduled",
@@ -34,6 +84,26 @@ func GetMVPInvariants() []dsl.Invariant {
 				Team:    "platform",
 			},
 			Severity: dsl.Critical,
+			Tags:     []string{dsl.TagAvailability},
+		},
+		{
+			ID:          "no_scheduling_failure",
+			Version:     1,
+			Description: "Pod should not be rejected by the scheduler",
+			Subject:     dsl.Subject{Kind: "Pod"},
+			Predicate: &dsl.Predicate{
+				Field:    "status.conditions[PodScheduled].reason",
+				Operator: dsl.NotEquals,
+				Value:    "Unschedulable",
+			},
+			Blocks: []string{"pod_scheduled"},
+			Responsibility: dsl.Responsibility{
+				Primary: "kube-scheduler",
+				Team:    "platform",
+			},
+			Severity: dsl.Critical,
+			Docs:     "No node can take the pod. The evidence quotes the scheduler's per-node breakdown; add capacity, lower requests or relax the node constraints it names.",
+			Tags:     []string{dsl.TagAvailability},
 		},
 		{
 			ID:          "node_ready",
@@ -51,6 +121,37 @@ func GetMVPInvariants() []dsl.Invariant {
 				Team:      "infrastructure",
 			},
 			Severity: dsl.Critical,
+			Tags:     []string{dsl.TagAvailability},
+		},
+		{
+			ID:          "node_version_skew_supported",
+			Version:     1,
+			Description: "Node kubelet should be within the supported version skew of the control plane",
+			Subject:     dsl.Subject{Kind: "Node"},
+			Predicate: &dsl.Predicate{
+				Field:    "version.skewSupported",
+				Operator: dsl.Equals,
+				Value:    "True",
+			},
+			Responsibility: upgradeResponsibility,
+			Severity:       dsl.Degraded,
+			Docs:           "The kubelet is newer than the control plane or trails it by more than three minor versions, which Kubernetes doesn't support. Upgrade the node pool, or finish the control plane upgrade first.",
+			Tags:           []string{dsl.TagAvailability, dsl.TagBestPractice},
+		},
+		{
+			ID:          "node_pool_minor_version_consistent",
+			Version:     1,
+			Description: "Node should run the same kubelet minor version as the rest of its pool",
+			Subject:     dsl.Subject{Kind: "Node"},
+			Predicate: &dsl.Predicate{
+				Field:    "version.poolMinorConsistent",
+				Operator: dsl.Equals,
+				Value:    "True",
+			},
+			Responsibility: upgradeResponsibility,
+			Severity:       dsl.Warning,
+			Docs:           "Other nodes of the pool run a newer kubelet minor version; the pool is mixed, usually after a stalled or partial upgrade. Finish rolling the pool to the new version.",
+			Tags:           []string{dsl.TagBestPractice},
 		},
 		{
 			ID:          "containers_running",
@@ -66,7 +167,47 @@ func GetMVPInvariants() []dsl.Invariant {
 				Primary: "kubelet",
 				Team:    "platform-node",
 			},
+			Severity:    dsl.Critical,
+			Tags:        []string{dsl.TagAvailability},
+			GracePeriod: rolloutGracePeriod,
+		},
+		{
+			ID:          "no_oom_killed",
+			Version:     1,
+			Description: "Pod containers should not have been OOM killed recently",
+			Subject:     dsl.Subject{Kind: "Pod"},
+			Predicate: &dsl.Predicate{
+				Field:    "status.containerStatuses.lastTermination.reason",
+				Operator: dsl.NotEquals,
+				Value:    "OOMKilled",
+			},
+			Blocks: []string{"containers_running", "pod_ready"},
+			Responsibility: dsl.Responsibility{
+				Primary: "kubelet",
+				Team:    "platform-node",
+			},
 			Severity: dsl.Critical,
+			Docs:     "The kernel killed a container for exceeding its memory limit. Raise resources.limits.memory or find the leak; repeated restarts point at the latter.",
+			Tags:     []string{dsl.TagAvailability},
+		},
+		{
+			ID:          "no_recent_evictions",
+			Version:     1,
+			Description: "Pod should not have been evicted from its node",
+			Subject:     dsl.Subject{Kind: "Pod"},
+			Predicate: &dsl.Predicate{
+				Field:    "status.reason",
+				Operator: dsl.NotEquals,
+				Value:    "Evicted",
+			},
+			Responsibility: dsl.Responsibility{
+				Primary:   "kubelet",
+				Secondary: "node-controller",
+				Team:      "platform-node",
+			},
+			Severity: dsl.Degraded,
+			Docs:     "The kubelet evicted the pod to relieve memory, disk or PID pressure on its node. The causal chain names the pressure condition.",
+			Tags:     []string{dsl.TagAvailability},
 		},
 		{
 			ID:          "pod_ready",
@@ -93,7 +234,9 @@ func GetMVPInvariants() []dsl.Invariant {
 				Primary: "kubelet",
 				Team:    "platform-node",
 			},
-			Severity: dsl.Critical,
+			Severity:    dsl.Critical,
+			Tags:        []string{dsl.TagAvailability},
+			GracePeriod: rolloutGracePeriod,
 		},
 		{
 			ID:          "service_has_endpoints",
@@ -107,7 +250,8 @@ func GetMVPInvariants() []dsl.Invariant {
 			Requires: []dsl.Requirement{
 				{
 					Invariant: "pod_ready",
-					Scope:     dsl.Scope{Relation: dsl.Selector},
+					// One ready pod is enough to serve
+					Scope: dsl.Scope{Relation: dsl.Selector, Args: map[string]string{dsl.ArgMatch: dsl.MatchAny}},
 				},
 			},
 			Responsibility: dsl.Responsibility{
@@ -116,7 +260,332 @@ func GetMVPInvariants() []dsl.Invariant {
 				Team:      "platform",
 			},
 			Severity: dsl.Critical,
+			Tags:     []string{dsl.TagAvailability},
+		},
+		{
+			ID:          "deployment_in_sync",
+			Version:     1,
+			Description: "Running pods should match their Deployment's current template",
+			Subject:     dsl.Subject{Kind: "DeploymentDrift"},
+			Predicate: &dsl.Predicate{
+				Field:    "status.inSync",
+				Operator: dsl.Equals,
+				Value:    "True",
+			},
+			Responsibility: dsl.Responsibility{
+				Primary:    "deployment-controller",
+				Team:       "platform",
+				ActorField: "status.editedBy",
+			},
+			Severity: dsl.Degraded,
+			Docs:     "Pods have differed from the Deployment template for longer than the drift threshold. stale_template points at a stuck rollout; pod_edited means a pod was changed directly and will be reverted on its next restart.",
+			Tags:     []string{dsl.TagAvailability},
+		},
+		{
+			ID:          "cluster_dns_resolving",
+			Version:     1,
+			Description: "Cluster DNS should resolve in-cluster service names",
+			Subject: dsl.Subject{
+				Kind:     "NetworkProbe",
+				Selector: map[string]string{"akari.io/probe": "dns"},
+			},
+			Predicate: &dsl.Predicate{
+				Field:    "status.resolving",
+				Operator: dsl.Equals,
+				Value:    "True",
+			},
+			// Readiness probes and service discovery fail across many
+			// services when DNS is down
+			Blocks: []string{"pod_ready", "service_has_endpoints"},
+			Responsibility: dsl.Responsibility{
+				Primary: "coredns",
+				Team:    "platform-network",
+			},
+			Severity: dsl.Critical,
+			Tags:     []string{dsl.TagAvailability},
+		},
+		{
+			ID:          "cni_pods_ready",
+			Version:     1,
+			Description: "CNI node agents should be ready on every node",
+			Subject: dsl.Subject{
+				Kind:     "NetworkProbe",
+				Selector: map[string]string{"akari.io/probe": "cni"},
+			},
+			Predicate: &dsl.Predicate{
+				Field:    "status.ready",
+				Operator: dsl.Equals,
+				Value:    "True",
+			},
+			Blocks: []string{"pod_ready", "service_has_endpoints"},
+			Responsibility: dsl.Responsibility{
+				Primary: "cni-plugin",
+				Team:    "platform-network",
+			},
+			Severity: dsl.Critical,
+			Tags:     []string{dsl.TagAvailability},
+		},
+		{
+			ID:          "external_service_reachable",
+			Version:     1,
+			Description: "External dependency should answer its health probe",
+			Subject:     dsl.Subject{Kind: "ExternalService"},
+			Predicate: &dsl.Predicate{
+				Field:    "status.reachable",
+				Operator: dsl.Equals,
+				Value:    "True",
+			},
+			Responsibility: dsl.Responsibility{
+				Primary: "external-provider",
+				Team:    "platform",
+			},
+			Severity: dsl.Critical,
+			Tags:     []string{dsl.TagAvailability},
+		},
+		{
+			ID:          "pod_runs_as_non_root",
+			Version:     1,
+			Description: "Pod containers should not run as root",
+			Subject:     dsl.Subject{Kind: "Pod"},
+			Predicate: &dsl.Predicate{
+				Field:    "security.runsAsRoot",
+				Operator: dsl.Equals,
+				Value:    "False",
+			},
+			Responsibility: securityResponsibility,
+			Severity:       dsl.Degraded,
+			Docs:           "Set runAsNonRoot or a non-zero runAsUser in the pod or container securityContext.",
+			Tags:           []string{dsl.TagSecurity, dsl.TagManifest},
+		},
+		{
+			ID:          "pod_not_privileged",
+			Version:     1,
+			Description: "Pod containers should not run privileged",
+			Subject:     dsl.Subject{Kind: "Pod"},
+			Predicate: &dsl.Predicate{
+				Field:    "security.privileged",
+				Operator: dsl.Equals,
+				Value:    "False",
+			},
+			Responsibility: securityResponsibility,
+			Severity:       dsl.Critical,
+			Docs:           "Privileged containers have full access to the host. Drop securityContext.privileged and grant only the capabilities the workload needs.",
+			Tags:           []string{dsl.TagSecurity, dsl.TagManifest},
+		},
+		{
+			ID:          "pod_resource_limits_set",
+			Version:     1,
+			Description: "Pod containers should declare CPU and memory limits",
+			Subject:     dsl.Subject{Kind: "Pod"},
+			Predicate: &dsl.Predicate{
+				Field:    "security.missingResourceLimits",
+				Operator: dsl.Equals,
+				Value:    "False",
+			},
+			Responsibility: securityResponsibility,
+			Severity:       dsl.Warning,
+			Docs:           "Containers without limits can starve other workloads on the node. Set resources.limits.cpu and resources.limits.memory.",
+			Tags:           []string{dsl.TagSecurity, dsl.TagManifest},
+		},
+		{
+			ID:          "pod_no_host_path",
+			Version:     1,
+			Description: "Pod should not mount hostPath volumes",
+			Subject:     dsl.Subject{Kind: "Pod"},
+			Predicate: &dsl.Predicate{
+				Field:    "security.hostPathMounts",
+				Operator: dsl.Equals,
+				Value:    "False",
+			},
+			Responsibility: securityResponsibility,
+			Severity:       dsl.Degraded,
+			Docs:           "hostPath volumes expose the node filesystem to the pod. Use a PersistentVolumeClaim, configMap or emptyDir instead.",
+			Tags:           []string{dsl.TagSecurity, dsl.TagManifest},
+		},
+		{
+			ID:          "pod_image_pinned",
+			Version:     1,
+			Description: "Pod images should be pinned to a tag or digest",
+			Subject:     dsl.Subject{Kind: "Pod"},
+			Predicate: &dsl.Predicate{
+				Field:    "security.unpinnedImages",
+				Operator: dsl.Equals,
+				Value:    "False",
+			},
+			Responsibility: securityResponsibility,
+			Severity:       dsl.Warning,
+			Docs:           "Images without a tag or tagged :latest change under the workload on every push. Reference a versioned tag or a digest.",
+			Tags:           []string{dsl.TagSecurity, dsl.TagManifest},
+		},
+		{
+			ID:          "pod_cpu_request_utilized",
+			Version:     1,
+			Description: "Pod should use a meaningful share of its CPU request",
+			Subject:     dsl.Subject{Kind: "PodUsage"},
+			Predicate: &dsl.Predicate{
+				Field:    "usage.cpuRequestRatio",
+				Operator: dsl.GreaterThan,
+				Value:    overProvisionedRatio,
+			},
+			Responsibility: costResponsibility,
+			Severity:       dsl.Warning,
+			Docs:           "Peak CPU usage over the last hour stayed below 20% of the request. Lower resources.requests.cpu so the scheduler can pack the node.",
+			Tags:           []string{dsl.TagCost},
+		},
+		{
+			ID:          "pod_memory_request_utilized",
+			Version:     1,
+			Description: "Pod should use a meaningful share of its memory request",
+			Subject:     dsl.Subject{Kind: "PodUsage"},
+			Predicate: &dsl.Predicate{
+				Field:    "usage.memoryRequestRatio",
+				Operator: dsl.GreaterThan,
+				Value:    overProvisionedRatio,
+			},
+			Responsibility: costResponsibility,
+			Severity:       dsl.Warning,
+			Docs:           "Peak memory usage over the last hour stayed below 20% of the request. Lower resources.requests.memory so the scheduler can pack the node.",
+			Tags:           []string{dsl.TagCost},
+		},
+		{
+			ID:          "cluster_capacity_headroom",
+			Version:     1,
+			Description: "Cluster should not be forecast to run out of schedulable capacity within the horizon",
+			Subject:     dsl.Subject{Kind: "ClusterCapacity"},
+			Predicate: &dsl.Predicate{
+				Field:    "forecast.pressureWithinHorizon",
+				Operator: dsl.Equals,
+				Value:    "False",
+			},
+			Responsibility: dsl.Responsibility{
+				Primary:   "cluster-autoscaler",
+				Secondary: "capacity-planning",
+				Team:      "infrastructure",
+			},
+			Severity: dsl.Degraded,
+			Docs:     "At the recent growth of pod requests, forecast.pressureResource will reach the threshold share of allocatable node resources at forecast.pressureAt, after which new pods stay Pending. Add nodes, raise the autoscaler's limits or lower requests; GET /api/v1/analytics/capacity shows the trend.",
+			Tags:     []string{dsl.TagAvailability, dsl.TagCost},
+		},
+		{
+			ID:          "deployment_replicas_available",
+			Version:     1,
+			Description: "Deployment should have all its desired replicas available",
+			Subject:     dsl.Subject{Kind: "Deployment"},
+			Predicate: &dsl.Predicate{
+				Field:     "status.availableReplicas",
+				Operator:  dsl.GreaterOrEqual,
+				ValueFrom: &dsl.FieldRef{Field: "spec.replicas"},
+			},
+			Responsibility: dsl.Responsibility{
+				Primary:   "deployment-controller",
+				Secondary: "kubelet",
+				Team:      "platform",
+			},
+			Severity:    dsl.Degraded,
+			Tags:        []string{dsl.TagAvailability},
+			GracePeriod: replicaGracePeriod,
+		},
+		{
+			ID:          "deployment_receives_traffic",
+			Version:     1,
+			Description: "Deployment scaled above zero should be routed traffic",
+			Subject:     dsl.Subject{Kind: "Deployment"},
+			Predicate: &dsl.Predicate{
+				Field:    "efficiency.idle",
+				Operator: dsl.Equals,
+				Value:    "False",
+			},
+			Responsibility: costResponsibility,
+			Severity:       dsl.Warning,
+			Docs:           "No Service with ready endpoints selects this Deployment's pods. Scale it to zero or delete it if it is no longer used.",
+			Tags:           []string{dsl.TagCost},
+			GracePeriod:    idleGracePeriod,
+		},
+		{
+			ID:          "pvc_in_use",
+			Version:     1,
+			Description: "PersistentVolumeClaim should be mounted by a pod",
+			Subject:     dsl.Subject{Kind: "PersistentVolumeClaim"},
+			Predicate: &dsl.Predicate{
+				Field:    "efficiency.orphaned",
+				Operator: dsl.Equals,
+				Value:    "False",
+			},
+			Responsibility: costResponsibility,
+			Severity:       dsl.Warning,
+			Docs:           "No pod mounts this claim, but its volume is still billed. Delete the claim if the data is no longer needed.",
+			Tags:           []string{dsl.TagCost},
+			GracePeriod:    idleGracePeriod,
 		},
 		// Add more invariants as needed - this is a minimal set
 	}
 }
+
+// GetManifestInvariants returns invariants only akari lint evaluates: they
+// read fields derived from a set of manifests, which a live cluster answers
+// through its own invariants (an unmatched selector shows up as a service
+// without endpoints)
+func GetManifestInvariants() []dsl.Invariant {
+	return []dsl.Invariant{
+		{
+			ID:          "pod_image_reference_valid",
+			Version:     1,
+			Description: "Pod images should be valid image references",
+			Subject:     dsl.Subject{Kind: "Pod"},
+			Predicate: &dsl.Predicate{
+				Field:    "spec.invalidImages",
+				Operator: dsl.Equals,
+				Value:    "False",
+			},
+			Responsibility: manifestResponsibility,
+			Severity:       dsl.Critical,
+			Docs:           "The kubelet cannot pull a malformed image reference and leaves the pod in InvalidImageName. Check the registry, repository and tag for typos and uppercase letters.",
+			Tags:           []string{dsl.TagBestPractice, dsl.TagManifest},
+		},
+		{
+			ID:          "pod_readiness_probe_set",
+			Version:     1,
+			Description: "Long-running pod containers should declare a readiness probe",
+			Subject:     dsl.Subject{Kind: "Pod"},
+			Predicate: &dsl.Predicate{
+				Field:    "spec.missingReadinessProbes",
+				Operator: dsl.Equals,
+				Value:    "False",
+			},
+			Responsibility: manifestResponsibility,
+			Severity:       dsl.Warning,
+			Docs:           "Without a readiness probe a container receives traffic as soon as it starts. Add readinessProbe to every container serving requests.",
+			Tags:           []string{dsl.TagBestPractice, dsl.TagManifest},
+		},
+		{
+			ID:          "deployment_selector_matches_template",
+			Version:     1,
+			Description: "Deployment selector should match its pod template labels",
+			Subject:     dsl.Subject{Kind: "Deployment"},
+			Predicate: &dsl.Predicate{
+				Field:    "spec.selectorMatchesTemplate",
+				Operator: dsl.Equals,
+				Value:    "True",
+			},
+			Responsibility: manifestResponsibility,
+			Severity:       dsl.Critical,
+			Docs:           "The API server rejects a Deployment whose spec.selector does not match spec.template.metadata.labels. Make the template carry every selector label.",
+			Tags:           []string{dsl.TagAvailability, dsl.TagManifest},
+		},
+		{
+			ID:          "service_selector_matches_workload",
+			Version:     1,
+			Description: "Service selector should match the pods of a workload",
+			Subject:     dsl.Subject{Kind: "Service"},
+			Predicate: &dsl.Predicate{
+				Field:    "spec.selectorMatchesPods",
+				Operator: dsl.Equals,
+				Value:    "True",
+			},
+			Responsibility: manifestResponsibility,
+			Severity:       dsl.Degraded,
+			Docs:           "No pod or pod template in the same namespace carries every label of spec.selector, so the Service will have no endpoints. Fix the selector or the workload's labels.",
+			Tags:           []string{dsl.TagAvailability, dsl.TagManifest},
+		},
+	}
+}
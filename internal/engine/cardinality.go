@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultCardinalityPercent is the share of the resources of its
+	// subject kind an invariant may violate before its violations are
+	// collapsed into one
+	DefaultCardinalityPercent = 50.0
+	// DefaultCardinalityMinimum is the fewest violations ever collapsed, so
+	// small clusters keep reporting them individually
+	DefaultCardinalityMinimum = 20
+
+	// maxCollapsedListed bounds the resources a collapsed violation names
+	maxCollapsedListed = 50
+)
+
+// CardinalityFlag marks an invariant whose violations were collapsed for
+// covering too many resources of a kind, which usually means a bad rule or
+// a cluster-wide event. It stays until the invariant is reviewed or changed.
+type CardinalityFlag struct {
+	InvariantID string    `json:"invariant_id"`
+	Kind        string    `json:"kind"`
+	Violations  int       `json:"violations"`
+	Resources   int       `json:"resources"`
+	Percent     float64   `json:"percent"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// SetCardinalityLimit sets the percentage of a kind's resources, and the
+// number of violations, an invariant must reach before CollapseFloods
+// collapses its violations. A zero percentage disables collapsing.
+func (e *InvariantEngine) SetCardinalityLimit(percent float64, minimum int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cardinalityPercent = percent
+	e.cardinalityMinimum = minimum
+}
+
+// CollapseFloods replaces the violations of each invariant violated on at
+// least the cardinality limit of the resources of its subject kind with a
+// single aggregate violation, and flags the invariant for review. Other
+// results pass through.
+func (e *InvariantEngine) CollapseFloods(results []*ViolationResult) []*ViolationResult {
+	e.mu.RLock()
+	percent, minimum := e.cardinalityPercent, e.cardinalityMinimum
+	e.mu.RUnlock()
+	if percent <= 0 {
+		return results
+	}
+
+	byInvariant := make(map[string][]*ViolationResult)
+	for _, r := range results {
+		if r != nil && r.Violated && r.ResourceUID != "" {
+			byInvariant[r.InvariantID] = append(byInvariant[r.InvariantID], r)
+		}
+	}
+
+	collapsed := make(map[string]*ViolationResult)
+	now := time.Now()
+	for id, violations := range byInvariant {
+		if len(violations) < minimum {
+			continue
+		}
+		inv, ok := e.GetInvariantByID(id)
+		if !ok {
+			continue
+		}
+		resources := len(e.store.GetLatestByKind(inv.Subject.Kind))
+		share := 100 * float64(len(violations)) / float64(max(resources, len(violations)))
+		if share < percent {
+			continue
+		}
+		collapsed[id] = floodViolation(inv.Subject.Kind, violations, resources, share)
+		e.flag(CardinalityFlag{
+			InvariantID: id,
+			Kind:        inv.Subject.Kind,
+			Violations:  len(violations),
+			Resources:   resources,
+			Percent:     share,
+		}, now)
+	}
+	if len(collapsed) == 0 {
+		return results
+	}
+
+	kept := make([]*ViolationResult, 0, len(results))
+	for _, r := range results {
+		if r != nil && r.Violated && r.ResourceUID != "" && collapsed[r.InvariantID] != nil {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	ids := make([]string, 0, len(collapsed))
+	for id := range collapsed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		kept = append(kept, collapsed[id])
+	}
+	return kept
+}
+
+// floodViolation is the aggregate of an invariant's violations, blamed on
+// the actor of the first of them
+func floodViolation(kind string, violations []*ViolationResult, resources int, share float64) *ViolationResult {
+	first := violations[0]
+	aggregate := &ViolationResult{
+		InvariantID:      first.InvariantID,
+		InvariantVersion: first.InvariantVersion,
+		Violated:         true,
+		Status:           StatusViolated,
+		ResponsibleActor: first.ResponsibleActor,
+		EliminatedActors: first.EliminatedActors,
+		AffectedResource: "*/" + kind,
+		ResourceUID:      "flood:" + first.InvariantID,
+		Severity:         first.Severity,
+		Docs:             first.Docs,
+		RunbookURL:       first.RunbookURL,
+		Correlated:       make([]string, 0, min(len(violations), maxCollapsedListed)),
+		Evidence: []string{
+			fmt.Sprintf("Invariant %s is flagged for review: a bad rule or a cluster-wide event is likely", first.InvariantID),
+			fmt.Sprintf("First violation: %s", first.Reason),
+		},
+	}
+	for _, v := range violations {
+		aggregate.absorb(v)
+	}
+	sort.Strings(aggregate.Correlated)
+	if len(aggregate.Correlated) > maxCollapsedListed {
+		aggregate.Correlated = aggregate.Correlated[:maxCollapsedListed]
+	}
+	aggregate.Reason = fmt.Sprintf("%d of %d %s resources (%.0f%%) violate %s", len(violations), resources, kind, share, first.InvariantID)
+	return aggregate
+}
+
+// flag records or refreshes the cardinality flag of an invariant
+func (e *InvariantEngine) flag(f CardinalityFlag, now time.Time) {
+	e.flagsMu.Lock()
+	defer e.flagsMu.Unlock()
+	f.FirstSeen = now
+	if existing, ok := e.cardinalityFlags[f.InvariantID]; ok {
+		f.FirstSeen = existing.FirstSeen
+	}
+	f.LastSeen = now
+	e.cardinalityFlags[f.InvariantID] = f
+}
+
+// GetCardinalityFlags returns the invariants flagged for review
+func (e *InvariantEngine) GetCardinalityFlags() []CardinalityFlag {
+	e.flagsMu.RLock()
+	defer e.flagsMu.RUnlock()
+
+	flags := make([]CardinalityFlag, 0, len(e.cardinalityFlags))
+	for _, f := range e.cardinalityFlags {
+		flags = append(flags, f)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].InvariantID < flags[j].InvariantID
+	})
+	return flags
+}
+
+// ClearCardinalityFlag marks a flagged invariant reviewed. It reports
+// whether the invariant was flagged.
+func (e *InvariantEngine) ClearCardinalityFlag(id string) bool {
+	e.flagsMu.Lock()
+	defer e.flagsMu.Unlock()
+	_, ok := e.cardinalityFlags[id]
+	delete(e.cardinalityFlags, id)
+	return ok
+}
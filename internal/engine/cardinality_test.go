@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestCollapseFloods(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	eng.SetCardinalityLimit(50, 3)
+
+	var results []*ViolationResult
+	for i := 0; i < 5; i++ {
+		uid := fmt.Sprintf("pod-%d", i)
+		store.Record(types.StateEvent{UID: uid, Kind: "Pod", Namespace: "default", Name: uid, Version: "1", Timestamp: time.Now()})
+		if i < 4 {
+			results = append(results, &ViolationResult{InvariantID: "pod_ready", Violated: true, Status: StatusViolated,
+				ResourceUID: uid, AffectedResource: "default/" + uid, Severity: dsl.Critical, Reason: "Pod not ready"})
+		}
+	}
+	other := &ViolationResult{InvariantID: "pod_scheduled", Violated: true, Status: StatusViolated, ResourceUID: "pod-0", AffectedResource: "default/pod-0"}
+	results = append(results, other)
+
+	collapsed := eng.CollapseFloods(results)
+	if len(collapsed) != 2 || collapsed[0] != other {
+		t.Fatalf("Expected pod_scheduled and one aggregate, got %+v", collapsed)
+	}
+	aggregate := collapsed[1]
+	if aggregate.InvariantID != "pod_ready" || aggregate.AffectedResource != "*/Pod" || len(aggregate.Correlated) != 4 {
+		t.Errorf("Expected an aggregate of 4 pods, got %+v", aggregate)
+	}
+	if aggregate.Reason != "4 of 5 Pod resources (80%) violate pod_ready" {
+		t.Errorf("Unexpected reason %q", aggregate.Reason)
+	}
+
+	flags := eng.GetCardinalityFlags()
+	if len(flags) != 1 || flags[0].InvariantID != "pod_ready" || flags[0].Violations != 4 || flags[0].Resources != 5 {
+		t.Fatalf("Expected pod_ready to be flagged, got %+v", flags)
+	}
+
+	// Below the minimum nothing collapses
+	if kept := eng.CollapseFloods(results[2:]); len(kept) != 3 {
+		t.Errorf("Expected 2 violations under the minimum to pass through, got %d results", len(kept))
+	}
+
+	if !eng.ClearCardinalityFlag("pod_ready") || eng.ClearCardinalityFlag("pod_ready") {
+		t.Error("Expected the flag to clear once")
+	}
+	eng.CollapseFloods(results)
+	inv, _ := eng.GetInvariantByID("pod_ready")
+	eng.UpsertInvariant(inv)
+	if flags := eng.GetCardinalityFlags(); len(flags) != 0 {
+		t.Errorf("Expected changing the invariant to clear its flag, got %+v", flags)
+	}
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+// DefaultChunkSize is how many resources EvaluateChunked evaluates between
+// progress reports
+const DefaultChunkSize = 1000
+
+// EvaluationProgress counts the resources a chunked evaluation has gone
+// through out of those recorded when it started
+type EvaluationProgress struct {
+	Evaluated int `json:"evaluated"`
+	Total     int `json:"total"`
+}
+
+// EvaluateChunked evaluates like EvaluateAll, chunkSize resources at a
+// time, handing each chunk's results and the progress so far to onChunk,
+// so callers on huge clusters can surface partial results. Resources are
+// those recorded when it starts; invariants are read afresh for every
+// chunk. It stops between chunks once ctx is done, returning its error.
+// Coverage findings arrive with the last chunk.
+func (e *InvariantEngine) EvaluateChunked(ctx context.Context, chunkSize int, onChunk func([]*ViolationResult, EvaluationProgress)) error {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	defer e.flushEvaluationLog()
+
+	kinds := e.evalEngine.SubjectKinds()
+	sort.Strings(kinds)
+	subjects := make(map[string][]types.StateEvent, len(kinds))
+	var progress EvaluationProgress
+	for _, kind := range kinds {
+		subjects[kind] = e.store.GetLatestByKind(kind)
+		progress.Total += len(subjects[kind])
+	}
+
+	for _, kind := range kinds {
+		all := subjects[kind]
+		for start := 0; start < len(all); start += chunkSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			chunk := all[start:min(start+chunkSize, len(all))]
+
+			e.mu.RLock()
+			var results []*ViolationResult
+			for _, inv := range e.evalEngine.InvariantsForKind(kind) {
+				results = append(results, e.evaluateIsolated(inv, chunk)...)
+			}
+			progress.Evaluated += len(chunk)
+			if progress.Evaluated == progress.Total {
+				results = append(results, e.coverageViolations()...)
+			}
+			e.mu.RUnlock()
+			onChunk(results, progress)
+		}
+	}
+	if progress.Total == 0 {
+		e.mu.RLock()
+		results := e.coverageViolations()
+		e.mu.RUnlock()
+		onChunk(results, progress)
+	}
+	return nil
+}
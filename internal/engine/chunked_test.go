@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestEvaluateChunked_Progress(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	eng.UpsertInvariant(dsl.Invariant{ID: "widget_ready", Subject: dsl.Subject{Kind: "Widget"}, Severity: dsl.Critical,
+		Predicate: &dsl.Predicate{Field: "x.ready", Operator: dsl.Equals, Value: true}})
+	for i := 0; i < 5; i++ {
+		store.Record(types.StateEvent{UID: fmt.Sprintf("w-%d", i), Kind: "Widget", Name: fmt.Sprintf("w-%d", i), Namespace: "default",
+			Timestamp: time.Now(), FieldDiff: map[string]interface{}{"x.ready": i%2 == 0}})
+	}
+	total := 0
+	for _, kind := range eng.evalEngine.SubjectKinds() {
+		total += len(store.GetLatestByKind(kind))
+	}
+
+	var progress []EvaluationProgress
+	violated := 0
+	err := eng.EvaluateChunked(context.Background(), 2, func(results []*ViolationResult, p EvaluationProgress) {
+		progress = append(progress, p)
+		for _, r := range FilterByStatus(results, StatusViolated) {
+			if r.InvariantID == "widget_ready" {
+				violated++
+			}
+		}
+	})
+	if err != nil {
+		t.Fatalf("EvaluateChunked failed: %v", err)
+	}
+	if len(progress) != 3 || progress[0] != (EvaluationProgress{Evaluated: 2, Total: total}) {
+		t.Errorf("Expected three chunks of the %d resources, got %+v", total, progress)
+	}
+	if last := progress[len(progress)-1]; last.Evaluated != total {
+		t.Errorf("Expected every resource evaluated, got %+v", last)
+	}
+	if violated != 2 {
+		t.Errorf("Expected the 2 unready widgets violated across chunks, got %d", violated)
+	}
+}
+
+func TestEvaluateChunked_Canceled(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	for i := 0; i < 3; i++ {
+		store.Record(types.StateEvent{UID: fmt.Sprintf("p-%d", i), Kind: "Pod", Name: fmt.Sprintf("p-%d", i), Namespace: "default", Timestamp: time.Now()})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks := 0
+	err := eng.EvaluateChunked(ctx, 1, func([]*ViolationResult, EvaluationProgress) {
+		chunks++
+		cancel()
+	})
+	if err != context.Canceled || chunks != 1 {
+		t.Errorf("Expected evaluation to stop after the first chunk, got %v after %d chunks", err, chunks)
+	}
+}
@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aonescu/akari/internal/dsl"
+)
+
+const (
+	// RegistryUnreachableID identifies the synthetic finding that replaces
+	// per-pod image pull failures sharing a registry
+	RegistryUnreachableID = "registry_unreachable"
+
+	// DefaultRegistryThreshold is how many pods must fail to pull from the
+	// same registry before their violations are folded together
+	DefaultRegistryThreshold = 2
+
+	defaultRegistry = "docker.io"
+)
+
+// imagePullReasons are container waiting reasons caused by the image
+// registry rather than by the image itself
+var imagePullReasons = map[string]bool{
+	"ErrImagePull":     true,
+	"ImagePullBackOff": true,
+}
+
+// SetRegistryThreshold sets how many pods must fail to pull from one
+// registry before CorrelateRegistries folds them. Zero disables folding.
+func (e *InvariantEngine) SetRegistryThreshold(n int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.registryThreshold = n
+}
+
+// CorrelateRegistries replaces the violations of pods stuck pulling images
+// from the same registry with a single registry_unreachable violation that
+// names the registry as the responsible actor. Other results pass through.
+func (e *InvariantEngine) CorrelateRegistries(results []*ViolationResult) []*ViolationResult {
+	e.mu.RLock()
+	threshold := e.registryThreshold
+	e.mu.RUnlock()
+	if threshold <= 0 {
+		return results
+	}
+
+	// registry host -> the pod UIDs failing to pull from it
+	pods := make(map[string]map[string]bool)
+	hostsByPod := make(map[string][]string)
+	for _, r := range results {
+		if r == nil || !r.Violated || r.ResourceUID == "" {
+			continue
+		}
+		if _, seen := hostsByPod[r.ResourceUID]; seen {
+			continue
+		}
+		hosts := e.pullFailureRegistries(r.ResourceUID)
+		hostsByPod[r.ResourceUID] = hosts
+		for _, host := range hosts {
+			if pods[host] == nil {
+				pods[host] = make(map[string]bool)
+			}
+			pods[host][r.ResourceUID] = true
+		}
+	}
+
+	folded := make(map[string]*ViolationResult)
+	for host, uids := range pods {
+		if len(uids) >= threshold {
+			folded[host] = registryViolation(host)
+		}
+	}
+	if len(folded) == 0 {
+		return results
+	}
+
+	correlated := make([]*ViolationResult, 0, len(results))
+	for _, r := range results {
+		var absorbed bool
+		if r != nil && r.Violated {
+			for _, host := range hostsByPod[r.ResourceUID] {
+				if summary, ok := folded[host]; ok {
+					summary.absorb(r)
+					absorbed = true
+				}
+			}
+		}
+		if !absorbed {
+			correlated = append(correlated, r)
+		}
+	}
+
+	hosts := make([]string, 0, len(folded))
+	for host := range folded {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		summary := folded[host]
+		sort.Strings(summary.Correlated)
+		summary.Reason = fmt.Sprintf("%d pods cannot pull images from registry %s", len(summary.Correlated), host)
+		correlated = append(correlated, summary)
+	}
+	return correlated
+}
+
+func registryViolation(host string) *ViolationResult {
+	return &ViolationResult{
+		InvariantID:      RegistryUnreachableID,
+		InvariantVersion: 1,
+		Violated:         true,
+		Status:           StatusViolated,
+		ResponsibleActor: host,
+		EliminatedActors: []string{"kubelet"},
+		AffectedResource: host,
+		ResourceUID:      "registry:" + host,
+		Severity:         dsl.Critical,
+		Correlated:       make([]string, 0),
+	}
+}
+
+// absorb folds a pod violation into the registry finding, keeping the
+// earliest detection and the heaviest tier
+func (r *ViolationResult) absorb(v *ViolationResult) {
+	if !contains(r.Correlated, v.AffectedResource) {
+		r.Correlated = append(r.Correlated, v.AffectedResource)
+	}
+	if r.DetectedAt.IsZero() || v.DetectedAt.Before(r.DetectedAt) {
+		r.DetectedAt = v.DetectedAt
+	}
+	if v.Impact > r.Impact {
+		r.Impact = v.Impact
+		r.Tier = v.Tier
+	}
+}
+
+// pullFailureRegistries returns the registry hosts of a pod stuck pulling
+// its images, or nil when the pod is not failing on an image pull
+func (e *InvariantEngine) pullFailureRegistries(uid string) []string {
+	pod, exists := e.store.GetByUID(uid)
+	if !exists || pod.Kind != "Pod" {
+		return nil
+	}
+	reason, _ := pod.FieldDiff["status.containerStatuses.waiting.reason"].(string)
+	if !imagePullReasons[reason] {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var hosts []string
+	for field, value := range pod.FieldDiff {
+		image, ok := value.(string)
+		if !ok || !strings.HasPrefix(field, "spec.containers[") || !strings.HasSuffix(field, "].image") {
+			continue
+		}
+		if host := RegistryHost(image); !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// RegistryHost returns the registry an image reference pulls from. Like
+// the container runtime, the first path component is only a host when it
+// looks like one; everything else comes from Docker Hub.
+func RegistryHost(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if !found {
+		return defaultRegistry
+	}
+	if strings.ContainsAny(first, ".:") || first == "localhost" {
+		return first
+	}
+	return defaultRegistry
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func recordPullingPod(store state.StateStore, uid, name, image, reason string) {
+	store.Record(types.StateEvent{
+		UID: uid, Kind: "Pod", Namespace: "default", Name: name, Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{
+			"spec.containers[app].image":              image,
+			"status.containerStatuses.waiting.reason": reason,
+		},
+	})
+}
+
+func TestRegistryHost(t *testing.T) {
+	cases := map[string]string{
+		"nginx":                          "docker.io",
+		"library/nginx:1.27":             "docker.io",
+		"ghcr.io/acme/api:v2":            "ghcr.io",
+		"localhost/app":                  "localhost",
+		"registry.internal:5000/app:1.0": "registry.internal:5000",
+	}
+	for image, want := range cases {
+		if got := RegistryHost(image); got != want {
+			t.Errorf("RegistryHost(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
+
+func TestCorrelateRegistries(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	recordPullingPod(store, "pod-1", "api", "ghcr.io/acme/api:v2", "ImagePullBackOff")
+	recordPullingPod(store, "pod-2", "worker", "ghcr.io/acme/worker:v2", "ErrImagePull")
+	recordPullingPod(store, "pod-3", "web", "nginx:1.27", "ImagePullBackOff")
+	recordPullingPod(store, "pod-4", "job", "ghcr.io/acme/job:v2", "CrashLoopBackOff")
+
+	earliest := time.Now().Add(-time.Minute)
+	results := []*ViolationResult{
+		{InvariantID: "containers_running", Violated: true, Status: StatusViolated, ResourceUID: "pod-1", AffectedResource: "default/api", DetectedAt: time.Now()},
+		{InvariantID: "pod_ready", Violated: true, Status: StatusViolated, ResourceUID: "pod-1", AffectedResource: "default/api", DetectedAt: time.Now()},
+		{InvariantID: "containers_running", Violated: true, Status: StatusViolated, ResourceUID: "pod-2", AffectedResource: "default/worker", DetectedAt: earliest},
+		{InvariantID: "containers_running", Violated: true, Status: StatusViolated, ResourceUID: "pod-3", AffectedResource: "default/web"},
+		{InvariantID: "containers_running", Violated: true, Status: StatusViolated, ResourceUID: "pod-4", AffectedResource: "default/job"},
+	}
+
+	correlated := eng.CorrelateRegistries(results)
+	if len(correlated) != 3 {
+		t.Fatalf("Expected the docker.io and crashing pods plus one registry finding, got %d results", len(correlated))
+	}
+
+	registry := correlated[len(correlated)-1]
+	if registry.InvariantID != RegistryUnreachableID || registry.ResponsibleActor != "ghcr.io" {
+		t.Errorf("Expected ghcr.io to be blamed, got %+v", registry)
+	}
+	if len(registry.Correlated) != 2 || registry.Correlated[0] != "default/api" || registry.Correlated[1] != "default/worker" {
+		t.Errorf("Expected both ghcr.io pods to be folded in, got %v", registry.Correlated)
+	}
+	if !registry.DetectedAt.Equal(earliest) {
+		t.Errorf("Expected the earliest detection time, got %v", registry.DetectedAt)
+	}
+
+	eng.SetRegistryThreshold(0)
+	if len(eng.CorrelateRegistries(results)) != len(results) {
+		t.Error("Expected a zero threshold to disable correlation")
+	}
+}
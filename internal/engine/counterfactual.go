@@ -0,0 +1,186 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// Counterfactual verdicts
+const (
+	// CounterfactualNecessary means the violation disappears without the
+	// change, which therefore caused it
+	CounterfactualNecessary = "necessary"
+	// CounterfactualNotNecessary means the violation persists without the
+	// change
+	CounterfactualNotNecessary = "not_necessary"
+	// CounterfactualInconclusive means the replay couldn't decide, e.g.
+	// because the recorded state doesn't reproduce the violation
+	CounterfactualInconclusive = "inconclusive"
+)
+
+// ErrNoHistory is returned for counterfactuals against stores that don't
+// keep history
+var ErrNoHistory = errors.New("counterfactual analysis needs a store that keeps history")
+
+// ErrChangeNotFound is returned when no change was recorded to the resource
+// at the given time
+var ErrChangeNotFound = errors.New("no change recorded to the resource at that time")
+
+// Counterfactual is the outcome of replaying a violation's cluster state
+// with one recorded change excluded
+type Counterfactual struct {
+	InvariantID      string        `json:"invariant_id"`
+	AffectedResource string        `json:"affected_resource"`
+	ResourceUID      string        `json:"resource_uid"`
+	At               time.Time     `json:"at"`
+	Change           SuspectChange `json:"change"`
+	// Reverted lists the fields of the change set back to their previous
+	// values; fields changed again later keep their later value
+	Reverted []string `json:"reverted"`
+	// RemovedResource is set when the change created the resource, which
+	// the replay leaves out entirely
+	RemovedResource bool `json:"removed_resource,omitempty"`
+
+	Factual       EvaluationStatus `json:"factual"`
+	WithoutChange EvaluationStatus `json:"without_change"`
+	Reason        string           `json:"reason,omitempty"`
+	Verdict       string           `json:"verdict"`
+	Explanation   string           `json:"explanation"`
+}
+
+// Counterfactual replays the cluster at at, as recorded and with the change
+// made to resourceUID at changedAt excluded, and reports whether the
+// violation would still have occurred
+func (e *InvariantEngine) Counterfactual(violation *ViolationResult, resourceUID string, changedAt, at time.Time) (*Counterfactual, error) {
+	reader, ok := e.store.(state.SnapshotReader)
+	if !ok {
+		return nil, ErrNoHistory
+	}
+	inv, ok := e.GetInvariantByID(violation.InvariantID)
+	if !ok {
+		return nil, fmt.Errorf("invariant %s not found", violation.InvariantID)
+	}
+
+	// The change is the event recorded at changedAt, diffed against the
+	// state before it
+	events, err := reader.EventsBetween(changedAt.Add(-time.Nanosecond), changedAt, resourceUID)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrChangeNotFound
+	}
+	changed := events[len(events)-1]
+	before, err := reader.SnapshotAt(changedAt.Add(-time.Nanosecond), resourceUID)
+	if err != nil {
+		return nil, err
+	}
+	var previous map[string]interface{}
+	if len(before) > 0 {
+		previous = before[0].FieldDiff
+	}
+	diff := state.DiffFields(previous, changed.FieldDiff)
+
+	cf := &Counterfactual{
+		InvariantID:      violation.InvariantID,
+		AffectedResource: violation.AffectedResource,
+		ResourceUID:      violation.ResourceUID,
+		At:               at,
+		Change: SuspectChange{
+			ResourceUID: changed.UID,
+			Kind:        changed.Kind,
+			Namespace:   changed.Namespace,
+			Name:        changed.Name,
+			Actor:       changed.Actor,
+			ChangedAt:   changed.Timestamp,
+			Fields:      diff,
+		},
+		Reverted: make([]string, 0),
+	}
+
+	factual, err := reader.SnapshotAt(at)
+	if err != nil {
+		return nil, err
+	}
+	without := make([]types.StateEvent, 0, len(factual))
+	for _, event := range factual {
+		if event.UID != resourceUID {
+			without = append(without, event)
+			continue
+		}
+		if len(before) == 0 {
+			cf.RemovedResource = true
+			continue
+		}
+		without = append(without, cf.revert(event, previous, changed.FieldDiff, diff))
+	}
+
+	cf.Factual, _ = e.replay(inv, violation.ResourceUID, factual)
+	cf.WithoutChange, cf.Reason = e.replay(inv, violation.ResourceUID, without)
+	cf.decide()
+	return cf, nil
+}
+
+// revert sets the fields the change touched back to their previous values
+// in the resource's state at the replay time, unless a later change set
+// them again
+func (cf *Counterfactual) revert(event types.StateEvent, previous, changed, diff map[string]interface{}) types.StateEvent {
+	fields := make(map[string]interface{}, len(event.FieldDiff))
+	for k, v := range event.FieldDiff {
+		fields[k] = v
+	}
+	for field := range diff {
+		current, exists := fields[field]
+		value, had := changed[field]
+		if exists != had || !reflect.DeepEqual(current, value) {
+			continue
+		}
+		if old, ok := previous[field]; ok {
+			fields[field] = old
+		} else {
+			delete(fields, field)
+		}
+		cf.Reverted = append(cf.Reverted, field)
+	}
+	event.FieldDiff = fields
+	return event
+}
+
+// replay evaluates inv against uid within snapshot. Satisfied results
+// aren't reported, so a missing result means satisfied.
+func (e *InvariantEngine) replay(inv dsl.Invariant, uid string, snapshot []types.StateEvent) (EvaluationStatus, string) {
+	for _, result := range e.snapshotEngine(snapshot).Evaluate(inv) {
+		if result.ResourceUID == uid {
+			return result.Status, result.Reason
+		}
+	}
+	for _, event := range snapshot {
+		if event.UID == uid {
+			return StatusSatisfied, ""
+		}
+	}
+	return StatusSatisfied, "The resource would not exist"
+}
+
+func (cf *Counterfactual) decide() {
+	switch {
+	case cf.Factual != StatusViolated:
+		cf.Verdict = CounterfactualInconclusive
+		cf.Explanation = fmt.Sprintf("The recorded state at %s doesn't reproduce the violation (%s)", cf.At.UTC().Format(time.RFC3339), cf.Factual)
+	case cf.WithoutChange == StatusViolated:
+		cf.Verdict = CounterfactualNotNecessary
+		cf.Explanation = "The invariant is still violated without the change, so it isn't what caused the violation"
+	case cf.WithoutChange == StatusSatisfied:
+		cf.Verdict = CounterfactualNecessary
+		cf.Explanation = "The invariant holds without the change, so the change was necessary for the violation"
+	default:
+		cf.Verdict = CounterfactualInconclusive
+		cf.Explanation = fmt.Sprintf("Without the change the invariant evaluates %s", cf.WithoutChange)
+	}
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestCounterfactual(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:        "widget_running",
+		Subject:   dsl.Subject{Kind: "Widget"},
+		Severity:  dsl.Critical,
+		Predicate: &dsl.Predicate{Field: "status.phase", Operator: dsl.Equals, Value: "Running"},
+	})
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	record := func(version string, at time.Time, fields map[string]interface{}) {
+		store.Record(types.StateEvent{UID: "widget-1", Kind: "Widget", Namespace: "prod", Name: "api", Version: version, Timestamp: at, Actor: "operator", FieldDiff: fields})
+	}
+	created, broke, relabelled := start, start.Add(time.Minute), start.Add(2*time.Minute)
+	record("1", created, map[string]interface{}{"status.phase": "Running", "spec.size": 1})
+	record("2", broke, map[string]interface{}{"status.phase": "Failed", "spec.size": 1})
+	record("3", relabelled, map[string]interface{}{"status.phase": "Failed", "spec.size": 2})
+
+	at := start.Add(5 * time.Minute)
+	violation := &ViolationResult{InvariantID: "widget_running", ResourceUID: "widget-1", AffectedResource: "prod/api", Violated: true}
+
+	cf, err := eng.Counterfactual(violation, "widget-1", broke, at)
+	if err != nil {
+		t.Fatalf("Counterfactual failed: %v", err)
+	}
+	if cf.Factual != StatusViolated || cf.WithoutChange != StatusSatisfied || cf.Verdict != CounterfactualNecessary {
+		t.Errorf("Expected the phase change to be necessary, got %+v", cf)
+	}
+	if len(cf.Reverted) != 1 || cf.Reverted[0] != "status.phase" || cf.Change.Fields["status.phase"] != "Failed" {
+		t.Errorf("Expected status.phase to be reverted, got %+v", cf)
+	}
+
+	cf, err = eng.Counterfactual(violation, "widget-1", relabelled, at)
+	if err != nil {
+		t.Fatalf("Counterfactual failed: %v", err)
+	}
+	if cf.WithoutChange != StatusViolated || cf.Verdict != CounterfactualNotNecessary {
+		t.Errorf("Expected the size change not to matter, got %+v", cf)
+	}
+
+	// Excluding the creation leaves the resource out of the replay
+	cf, err = eng.Counterfactual(violation, "widget-1", created, at)
+	if err != nil {
+		t.Fatalf("Counterfactual failed: %v", err)
+	}
+	if !cf.RemovedResource || cf.Verdict != CounterfactualNecessary {
+		t.Errorf("Expected the creation to be necessary, got %+v", cf)
+	}
+
+	// Before the violation the replay can't reproduce it
+	cf, err = eng.Counterfactual(violation, "widget-1", created, start.Add(30*time.Second))
+	if err != nil || cf.Verdict != CounterfactualInconclusive {
+		t.Errorf("Expected an inconclusive replay, got %+v, %v", cf, err)
+	}
+
+	if _, err := eng.Counterfactual(violation, "widget-1", start.Add(90*time.Second), at); !errors.Is(err, ErrChangeNotFound) {
+		t.Errorf("Expected ErrChangeNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+const (
+	// EndpointCoverageID identifies the synthetic finding raised for a
+	// Service whose selected pods are mostly not ready
+	EndpointCoverageID = "service_endpoint_coverage"
+
+	// DefaultCoverageThreshold is the percentage of ready pods below which
+	// a Service is reported degraded
+	DefaultCoverageThreshold = 75.0
+
+	fieldPodReady = "status.conditions[Ready].status"
+)
+
+// Coverage is the share of the pods selected by a Service that are ready
+// to receive traffic
+type Coverage struct {
+	UID       string  `json:"uid"`
+	Namespace string  `json:"namespace"`
+	Name      string  `json:"name"`
+	Pods      int     `json:"pods"`
+	Ready     int     `json:"ready"`
+	Percent   float64 `json:"percent"`
+}
+
+// SetCoverageThreshold sets the endpoint coverage percentage below which
+// EvaluateAll reports a Service degraded. Zero disables the check.
+func (e *InvariantEngine) SetCoverageThreshold(percent float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.coverageThreshold = percent
+}
+
+// ServiceCoverage computes the endpoint coverage of every Service with a
+// selector, ordered by namespace and name. Terminating pods don't count.
+// A Service selecting no pods has zero coverage.
+func (e *InvariantEngine) ServiceCoverage() []Coverage {
+	pods := e.store.GetLatestByKind("Pod")
+	coverage := make([]Coverage, 0)
+	for _, svc := range e.store.GetLatestByKind("Service") {
+		selector := watcher.Selector(svc.FieldDiff)
+		if len(selector) == 0 {
+			continue
+		}
+		c := Coverage{UID: svc.UID, Namespace: svc.Namespace, Name: svc.Name}
+		for _, pod := range pods {
+			if pod.Namespace != svc.Namespace || !state.MatchesSelector(selector, pod.Labels) {
+				continue
+			}
+			if _, deleting := pod.FieldDiff[watcher.FieldDeletionTimestamp]; deleting {
+				continue
+			}
+			c.Pods++
+			if ready, _ := pod.FieldDiff[fieldPodReady].(string); ready == "True" {
+				c.Ready++
+			}
+		}
+		if c.Pods > 0 {
+			c.Percent = 100 * float64(c.Ready) / float64(c.Pods)
+		}
+		coverage = append(coverage, c)
+	}
+	sort.Slice(coverage, func(i, j int) bool {
+		if coverage[i].Namespace != coverage[j].Namespace {
+			return coverage[i].Namespace < coverage[j].Namespace
+		}
+		return coverage[i].Name < coverage[j].Name
+	})
+	return coverage
+}
+
+// coverageViolations reports the Services whose selected pods are below
+// the coverage threshold. Services selecting no pods are left to the
+// selector lint. Callers hold e.mu.
+func (e *InvariantEngine) coverageViolations() []*ViolationResult {
+	if e.coverageThreshold <= 0 {
+		return nil
+	}
+	var violations []*ViolationResult
+	for _, c := range e.ServiceCoverage() {
+		if c.Pods == 0 || c.Percent >= e.coverageThreshold {
+			continue
+		}
+		svc, exists := e.store.GetByUID(c.UID)
+		if !exists {
+			continue
+		}
+		violations = append(violations, e.coverageViolation(c, svc))
+	}
+	return violations
+}
+
+func (e *InvariantEngine) coverageViolation(c Coverage, svc types.StateEvent) *ViolationResult {
+	result := &ViolationResult{
+		InvariantID:      EndpointCoverageID,
+		InvariantVersion: 1,
+		Violated:         true,
+		Status:           StatusViolated,
+		Reason: fmt.Sprintf("%d/%d pods ready behind Service %s/%s (%.0f%%), below the %.0f%% threshold",
+			c.Ready, c.Pods, c.Namespace, c.Name, c.Percent, e.coverageThreshold),
+		ResponsibleActor: "kubelet",
+		EliminatedActors: []string{"endpoints-controller"},
+		AffectedResource: c.Namespace + "/" + c.Name,
+		DetectedAt:       time.Now(),
+		Severity:         dsl.Degraded,
+	}
+	e.weigh(result, svc)
+	return result
+}
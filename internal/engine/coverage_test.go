@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func recordBackend(store state.StateStore, uid, app, ready string) {
+	store.Record(types.StateEvent{
+		UID: uid, Kind: "Pod", Namespace: "shop", Name: uid, Version: "1", Timestamp: time.Now(),
+		Labels:    map[string]string{"app": app},
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": ready},
+	})
+}
+
+func TestServiceCoverage(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	for _, inv := range eng.GetInvariants() {
+		eng.DeleteInvariant(inv.ID)
+	}
+
+	store.Record(types.StateEvent{
+		UID: "svc-api", Kind: "Service", Namespace: "shop", Name: "api", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"spec.selector": map[string]string{"app": "api"}},
+	})
+	store.Record(types.StateEvent{
+		UID: "svc-web", Kind: "Service", Namespace: "shop", Name: "web", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"spec.selector": map[string]interface{}{"app": "web"}},
+	})
+	recordBackend(store, "api-1", "api", "True")
+	recordBackend(store, "api-2", "api", "False")
+	recordBackend(store, "api-3", "api", "False")
+	recordBackend(store, "api-4", "api", "True")
+	recordBackend(store, "web-1", "web", "True")
+
+	coverage := eng.ServiceCoverage()
+	if len(coverage) != 2 || coverage[0].Name != "api" || coverage[0].Ready != 2 || coverage[0].Pods != 4 || coverage[0].Percent != 50 {
+		t.Fatalf("Expected api at 2/4 ready, got %+v", coverage)
+	}
+	if coverage[1].Percent != 100 {
+		t.Errorf("Expected web fully covered, got %+v", coverage[1])
+	}
+
+	violations := eng.EvaluateAll()
+	if len(violations) != 1 || violations[0].InvariantID != EndpointCoverageID || violations[0].AffectedResource != "shop/api" {
+		t.Fatalf("Expected a coverage violation for shop/api, got %+v", violations)
+	}
+	if violations[0].Severity != dsl.Degraded {
+		t.Errorf("Expected degraded severity, got %s", violations[0].Severity)
+	}
+
+	eng.SetCoverageThreshold(50)
+	if violations := eng.EvaluateAll(); len(violations) != 0 {
+		t.Errorf("Expected 50%% coverage to meet a 50%% threshold, got %+v", violations)
+	}
+}
@@ -8,28 +8,94 @@ import (
 	"time"
 
 	"github.com/aonescu/akari/internal/authority"
+	"github.com/aonescu/akari/internal/criticality"
 	"github.com/aonescu/akari/internal/dsl"
 	"github.com/aonescu/akari/internal/dsl/invariants"
 	"github.com/aonescu/akari/internal/state"
 	"github.com/aonescu/akari/internal/types"
 )
 
+// EvaluationStatus is the outcome of evaluating an invariant on a resource
+type EvaluationStatus string
+
+const (
+	StatusSatisfied       EvaluationStatus = "satisfied"
+	StatusViolated        EvaluationStatus = "violated"
+	StatusUnknown         EvaluationStatus = "unknown"
+	StatusEvaluationError EvaluationStatus = "evaluation_error"
+)
+
 type ViolationResult struct {
-	InvariantID      string       `json:"invariant_id"`
-	Violated         bool         `json:"violated"`
-	Reason           string       `json:"reason"`
-	ResponsibleActor string       `json:"responsible_actor"`
-	EliminatedActors []string     `json:"eliminated_actors"`
-	AffectedResource string       `json:"affected_resource"`
-	DetectedAt       time.Time    `json:"detected_at"`
-	Severity         dsl.Severity `json:"severity"`
+	InvariantID      string           `json:"invariant_id"`
+	InvariantVersion int              `json:"invariant_version"`
+	Violated         bool             `json:"violated"`
+	Status           EvaluationStatus `json:"status,omitempty"`
+	Reason           string           `json:"reason"`
+	ResponsibleActor string           `json:"responsible_actor"`
+	EliminatedActors []string         `json:"eliminated_actors"`
+	AffectedResource string           `json:"affected_resource"`
+	ResourceUID      string           `json:"resource_uid,omitempty"`
+	DetectedAt       time.Time        `json:"detected_at"`
+	Severity         dsl.Severity     `json:"severity"`
+	Tier             criticality.Tier `json:"tier,omitempty"`
+	Impact           float64          `json:"impact,omitempty"`
+	Docs             string           `json:"docs,omitempty"`
+	RunbookURL       string           `json:"runbook_url,omitempty"`
+	// Evidence holds findings that explain the violation beyond its reason,
+	// e.g. the node constraint an Unschedulable pod cannot meet
+	Evidence []string `json:"evidence,omitempty"`
+	// Correlated lists the resources whose violations were folded into
+	// this one, such as the pods of a registry_unreachable finding
+	Correlated []string `json:"correlated,omitempty"`
+	// SuspectChanges are the changes recorded shortly before the violation
+	// to its resource and the resources it depends on
+	SuspectChanges []SuspectChange `json:"suspect_changes,omitempty"`
+	// CorrelationID is that of the event the resource was last recorded
+	// with, tracing the violation back to it
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// LogicalResource identifies the resource across recreation, e.g.
+	// Pod/shop/Deployment/api for every pod the Deployment ever ran
+	LogicalResource string `json:"logical_resource,omitempty"`
+}
+
+// FilterByStatus returns the results carrying the given status
+func FilterByStatus(results []*ViolationResult, status EvaluationStatus) []*ViolationResult {
+	filtered := make([]*ViolationResult, 0)
+	for _, r := range results {
+		if r != nil && r.Status == status {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
 }
 
 type InvariantEngine struct {
 	mu         sync.RWMutex
 	invariants map[string]dsl.Invariant
+	versions   map[string][]dsl.Invariant // invariant ID -> every definition, oldest first
 	store      state.StateStore
 	evalEngine *EvaluationEngine
+
+	evaluationTimeout time.Duration
+	errorsMu          sync.RWMutex
+	evaluationErrors  map[string]EvaluationError // invariant ID -> latest failure
+
+	tiers *criticality.Registry
+
+	registryThreshold  int
+	coverageThreshold  float64
+	cardinalityPercent float64
+	cardinalityMinimum int
+	flagsMu            sync.RWMutex
+	cardinalityFlags   map[string]CardinalityFlag // invariant ID -> flag awaiting review
+	evidence           map[string]EvidenceFunc    // invariant ID -> provider
+	suspectWindow      time.Duration
+
+	logStore     EvaluationLogStore
+	logRetention time.Duration
+
+	seenMu sync.Mutex
+	seen   map[string]seenState // resource UID -> state last seen by EvaluateEvent
 }
 
 func NewInvariantEngine(store state.StateStore) *InvariantEngine {
@@ -38,12 +104,59 @@ func NewInvariantEngine(store state.StateStore) *InvariantEngine {
 
 	engine := &InvariantEngine{
 		invariants: evalEngine.invariants,
+		versions:   make(map[string][]dsl.Invariant),
 		store:      store,
 		evalEngine: evalEngine,
+
+		evaluationTimeout: DefaultEvaluationTimeout,
+		evaluationErrors:  make(map[string]EvaluationError),
+
+		tiers: criticality.NewRegistry(),
+
+		registryThreshold:  DefaultRegistryThreshold,
+		coverageThreshold:  DefaultCoverageThreshold,
+		cardinalityPercent: DefaultCardinalityPercent,
+		cardinalityMinimum: DefaultCardinalityMinimum,
+		cardinalityFlags:   make(map[string]CardinalityFlag),
+		suspectWindow:      DefaultSuspectWindow,
+		seen:               make(map[string]seenState),
+		evidence: map[string]EvidenceFunc{
+			"pod_scheduled":         scheduleEvidence,
+			"no_scheduling_failure": scheduleEvidence,
+		},
+	}
+	for id, inv := range engine.invariants {
+		engine.versions[id] = []dsl.Invariant{inv}
 	}
 	return engine
 }
 
+// FilterByTags keeps the results whose invariant carries any of tags. An
+// empty tag list keeps everything.
+func (e *InvariantEngine) FilterByTags(results []*ViolationResult, tags []string) []*ViolationResult {
+	if len(tags) == 0 {
+		return results
+	}
+	filtered := make([]*ViolationResult, 0)
+	for _, r := range results {
+		if inv, exists := e.GetInvariantByID(r.InvariantID); exists && inv.HasAnyTag(tags) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// Tiers returns the registry used to weight results by resource criticality
+func (e *InvariantEngine) Tiers() *criticality.Registry {
+	return e.tiers
+}
+
+// Authority returns the controller authority map responsibility is
+// assigned from
+func (e *InvariantEngine) Authority() *authority.ControllerAuthorityMap {
+	return e.evalEngine.authorityMap
+}
+
 func (e *InvariantEngine) GetInvariants() []dsl.Invariant {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
@@ -63,33 +176,188 @@ func (e *InvariantEngine) GetInvariantByID(id string) (dsl.Invariant, bool) {
 	return inv, exists
 }
 
+// UpsertInvariant registers a new invariant or replaces an existing one.
+// Replacing bumps the version; prior definitions stay in the version history.
+// A changed invariant counts as reviewed and loses its cardinality flag.
+func (e *InvariantEngine) UpsertInvariant(inv dsl.Invariant) dsl.Invariant {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ClearCardinalityFlag(inv.ID)
+
+	inv.DeletedAt = nil
+	if history := e.versions[inv.ID]; len(history) > 0 {
+		inv.Version = history[len(history)-1].Version + 1
+	} else if inv.Version == 0 {
+		inv.Version = 1
+	}
+
+	e.register(inv)
+	e.versions[inv.ID] = append(e.versions[inv.ID], inv)
+	return inv
+}
+
+// ResolveExtends returns inv with the fields it leaves unset inherited from
+// the registered invariant it extends. The result stands alone: changing
+// the base later doesn't change it.
+func (e *InvariantEngine) ResolveExtends(inv dsl.Invariant) (dsl.Invariant, error) {
+	if inv.Extends == "" {
+		return inv, nil
+	}
+	if inv.Extends == inv.ID {
+		return inv, fmt.Errorf("invariant %s cannot extend itself", inv.ID)
+	}
+	base, ok := e.GetInvariantByID(inv.Extends)
+	if !ok {
+		return inv, fmt.Errorf("extends: invariant %s not found", inv.Extends)
+	}
+	return inv.Inherit(base), nil
+}
+
+// RestoreInvariant registers a persisted definition as-is, without bumping
+// its version. Used when loading invariants from storage at startup.
+func (e *InvariantEngine) RestoreInvariant(inv dsl.Invariant) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.register(inv)
+	history := e.versions[inv.ID]
+	if len(history) == 0 || history[len(history)-1].Version != inv.Version {
+		e.versions[inv.ID] = append(history, inv)
+	}
+}
+
+// DeleteInvariant soft-deletes an invariant: it stops being evaluated but its
+// definitions remain available through GetInvariantVersions.
+func (e *InvariantEngine) DeleteInvariant(id string) (dsl.Invariant, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	inv, exists := e.invariants[id]
+	if !exists {
+		return dsl.Invariant{}, false
+	}
+
+	e.evalEngine.mu.Lock()
+	e.evalEngine.unindexInvariant(inv)
+	delete(e.invariants, id)
+	e.evalEngine.mu.Unlock()
+
+	now := time.Now()
+	if history := e.versions[id]; len(history) > 0 {
+		history[len(history)-1].DeletedAt = &now
+	}
+	inv.DeletedAt = &now
+	return inv, true
+}
+
+// GetInvariantVersions returns every known definition of an invariant,
+// oldest first, including soft-deleted ones
+func (e *InvariantEngine) GetInvariantVersions(id string) []dsl.Invariant {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	history := e.versions[id]
+	versions := make([]dsl.Invariant, len(history))
+	copy(versions, history)
+	return versions
+}
+
+// register stores inv in the shared invariant map. Callers must hold e.mu.
+func (e *InvariantEngine) register(inv dsl.Invariant) {
+	e.evalEngine.mu.Lock()
+	defer e.evalEngine.mu.Unlock()
+	e.evalEngine.registerInvariant(inv)
+}
+
 func (e *InvariantEngine) EvaluateAll() []*ViolationResult {
+	defer e.flushEvaluationLog()
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
 	var violations []*ViolationResult
-	for _, inv := range e.invariants {
-		results := e.Evaluate(inv)
-		violations = append(violations, results...)
+	// Walk the kind index so each kind's subjects are fetched once and
+	// only invariants that can apply to them are evaluated.
+	for _, kind := range e.evalEngine.SubjectKinds() {
+		subjects := e.store.GetLatestByKind(kind)
+		if len(subjects) == 0 {
+			continue
+		}
+		for _, inv := range e.evalEngine.InvariantsForKind(kind) {
+			violations = append(violations, e.evaluateIsolated(inv, subjects)...)
+		}
 	}
-	return violations
+	return append(violations, e.coverageViolations()...)
 }
 
 func (e *InvariantEngine) Evaluate(inv dsl.Invariant) []*ViolationResult {
-	var violations []*ViolationResult
-
 	subjects := e.store.GetLatestByKind(inv.Subject.Kind)
+	return e.evaluateIsolated(inv, subjects)
+}
+
+// EvaluateResource evaluates the invariants whose subject matches resource
+// against the given state instead of the recorded one. Related resources
+// are still read from the store.
+func (e *InvariantEngine) EvaluateResource(resource types.StateEvent) []*ViolationResult {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	results := make([]*ViolationResult, 0)
+	for _, inv := range e.evalEngine.InvariantsForKind(resource.Kind) {
+		results = append(results, e.evaluateIsolated(inv, []types.StateEvent{resource})...)
+	}
+	return results
+}
+
+// evaluateSubjects evaluates inv against the matching subjects until the
+// timeout passes. The deadline is only checked between subjects: the one
+// evaluating when it passes runs to completion and keeps its result, and
+// each matching subject after it gets an evaluation_error naming it.
+func (e *InvariantEngine) evaluateSubjects(inv dsl.Invariant, subjects []types.StateEvent, timeout time.Duration) []*ViolationResult {
+	var violations []*ViolationResult
 
+	deadline := time.Now().Add(timeout)
+	namespaceLabels := e.namespaceLabels(inv.Subject)
+	var overran string
 	for _, subject := range subjects {
-		violation := e.evaluateSubject(inv, subject)
-		if violation != nil {
+		if !SubjectMatches(inv.Subject, subject, namespaceLabels) {
+			continue
+		}
+		if overran != "" {
+			timedOut := e.errorResult(inv, subject, fmt.Sprintf("evaluation timed out after %v, at %s", timeout, overran))
+			timedOut.CorrelationID = subject.CorrelationID
+			violations = append(violations, timedOut)
+			continue
+		}
+		if violation := e.evaluateSubjectRecovering(inv, subject); violation != nil {
 			violations = append(violations, violation)
 		}
+		if time.Now().After(deadline) {
+			overran = subject.Namespace + "/" + subject.Name
+		}
 	}
 
 	return violations
 }
 
+// Matches reports whether resource falls within subject, reading the
+// labels of its namespace from the recorded Namespace objects
+func (e *InvariantEngine) Matches(subject dsl.Subject, resource types.StateEvent) bool {
+	return SubjectMatches(subject, resource, e.namespaceLabels(subject))
+}
+
+// namespaceLabels returns a lookup of the recorded namespace labels, or
+// nil when subject doesn't select on them
+func (e *InvariantEngine) namespaceLabels(subject dsl.Subject) func(string) map[string]string {
+	if len(subject.NamespaceSelector) == 0 {
+		return nil
+	}
+	labels := make(map[string]map[string]string)
+	for _, ns := range e.store.GetLatestByKind("Namespace") {
+		labels[ns.Name] = ns.Labels
+	}
+	return func(name string) map[string]string { return labels[name] }
+}
+
 func (e *InvariantEngine) evaluateSubject(inv dsl.Invariant, subject types.StateEvent) *ViolationResult {
 	ctx := types.EvaluationContext{
 		Resource:      subject,
@@ -97,7 +365,28 @@ func (e *InvariantEngine) evaluateSubject(inv dsl.Invariant, subject types.State
 		Timestamp:     time.Now(),
 	}
 
-	return e.evalEngine.EvaluateWithContext(inv, ctx)
+	result := e.evalEngine.EvaluateWithContext(inv, ctx)
+	if result != nil {
+		e.weigh(result, subject)
+		if provide, ok := e.evidence[inv.ID]; ok && result.Violated {
+			result.Evidence = provide(subject, e.store)
+		}
+	}
+	return result
+}
+
+// weigh attaches the resource's logical identity, its tier and the
+// resulting impact score
+func (e *InvariantEngine) weigh(result *ViolationResult, subject types.StateEvent) {
+	result.ResourceUID = subject.UID
+	result.LogicalResource = state.LogicalKey(subject)
+	if resolver, ok := e.store.(state.IdentityResolver); ok {
+		if key, ok := resolver.LogicalKey(subject.UID); ok {
+			result.LogicalResource = key
+		}
+	}
+	result.Tier = e.tiers.Resolve(subject)
+	result.Impact = criticality.Impact(result.Severity, result.Tier)
 }
 
 func (e *InvariantEngine) evaluatePredicate(pred dsl.Predicate, subject types.StateEvent) bool {
@@ -111,24 +400,35 @@ func (e *InvariantEngine) eliminateActors(field string, primary string) []string
 
 type EvaluationEngine struct {
 	invariants    map[string]dsl.Invariant
+	byKind        map[string][]string // subject kind -> invariant IDs
+	index         *fieldIndex         // built on first use, reset when invariants change
 	store         state.StateStore
 	authorityMap  *authority.ControllerAuthorityMap
 	evaluationLog []EvaluationLogEntry
 	mu            sync.RWMutex
+
+	// pending holds the entries sampled for the evaluation log store until
+	// the next flush
+	persistLog bool
+	pending    []EvaluationLogEntry
+	logSample  float64
 }
 
 type EvaluationLogEntry struct {
-	InvariantID string
-	ResourceUID string
-	Result      bool
-	Reason      string
-	Timestamp   time.Time
-	Duration    time.Duration
+	InvariantID   string           `json:"invariant_id"`
+	ResourceUID   string           `json:"uid"`
+	Result        bool             `json:"result"`
+	Status        EvaluationStatus `json:"status"`
+	Reason        string           `json:"reason"`
+	CorrelationID string           `json:"correlation_id,omitempty"`
+	Timestamp     time.Time        `json:"timestamp"`
+	Duration      time.Duration    `json:"duration_ns"`
 }
 
 func NewEvaluationEngine(store state.StateStore, authorityMap *authority.ControllerAuthorityMap) *EvaluationEngine {
 	engine := &EvaluationEngine{
 		invariants:    make(map[string]dsl.Invariant),
+		byKind:        make(map[string][]string),
 		store:         store,
 		authorityMap:  authorityMap,
 		evaluationLog: make([]EvaluationLogEntry, 0),
@@ -142,11 +442,88 @@ func (e *EvaluationEngine) LoadDefaultInvariants() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	for _, inv := range invariants {
-		e.invariants[inv.ID] = inv
+		e.registerInvariant(inv)
 	}
 	log.Printf("Loaded %d invariants into evaluation engine", len(invariants))
 }
 
+// registerInvariant stores inv and keeps the kind index in sync.
+// Callers must hold e.mu.
+func (e *EvaluationEngine) registerInvariant(inv dsl.Invariant) {
+	if old, exists := e.invariants[inv.ID]; exists {
+		e.unindexInvariant(old)
+	}
+	e.invariants[inv.ID] = inv
+	e.byKind[inv.Subject.Kind] = append(e.byKind[inv.Subject.Kind], inv.ID)
+	e.index = nil
+}
+
+// unindexInvariant removes inv from the kind index. Callers must hold e.mu.
+func (e *EvaluationEngine) unindexInvariant(inv dsl.Invariant) {
+	e.index = nil
+	ids := e.byKind[inv.Subject.Kind]
+	for i, id := range ids {
+		if id == inv.ID {
+			ids = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(e.byKind, inv.Subject.Kind)
+		return
+	}
+	e.byKind[inv.Subject.Kind] = ids
+}
+
+// SubjectKinds returns the kinds targeted by at least one invariant
+func (e *EvaluationEngine) SubjectKinds() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	kinds := make([]string, 0, len(e.byKind))
+	for kind := range e.byKind {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// InvariantsForKind returns the invariants whose subject is the given kind
+func (e *EvaluationEngine) InvariantsForKind(kind string) []dsl.Invariant {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ids := e.byKind[kind]
+	invariants := make([]dsl.Invariant, 0, len(ids))
+	for _, id := range ids {
+		invariants = append(invariants, e.invariants[id])
+	}
+	return invariants
+}
+
+// SubjectMatches reports whether a resource falls within an invariant's
+// subject: same kind, within its scope and namespace filters, and carrying
+// every label in the selector. namespaceLabels looks up the labels of the
+// resource's namespace for a namespace selector; nil treats every
+// namespace as unlabelled.
+func SubjectMatches(subject dsl.Subject, resource types.StateEvent, namespaceLabels func(string) map[string]string) bool {
+	if subject.Kind != resource.Kind {
+		return false
+	}
+	var labels map[string]string
+	if namespaceLabels != nil && len(subject.NamespaceSelector) > 0 {
+		labels = namespaceLabels(resource.Namespace)
+	}
+	if !subject.MatchesNamespace(resource.Namespace, labels) {
+		return false
+	}
+	for key, value := range subject.Selector {
+		if actual, ok := resource.Labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
 func (e *EvaluationEngine) evaluateDependency(
 	req dsl.Requirement,
 	ctx types.EvaluationContext,
@@ -161,27 +538,50 @@ func (e *EvaluationEngine) evaluateDependency(
 		}
 	}
 
-	switch req.Scope.Relation {
-
-	case dsl.Same:
+	if req.Scope.Relation == dsl.Same {
 		// Evaluate on the same resource
 		return e.EvaluateWithContext(reqInv, ctx)
+	}
 
-	case dsl.Owner, dsl.Node, dsl.Selector:
-		// Not yet supported by StateStore
+	targets := e.dependencyTargets(req.Scope, reqInv.Subject.Kind, ctx.Resource)
+	if len(targets) == 0 {
 		return &ViolationResult{
 			InvariantID: reqInv.ID,
-			Violated:    true,
-			Reason:      "Dependency relation not supported by StateStore",
+			Status:      StatusUnknown,
+			Reason:      noTargetsReason(req.Scope, reqInv, ctx.Resource),
 		}
+	}
 
-	default:
-		return &ViolationResult{
-			InvariantID: reqInv.ID,
-			Violated:    true,
-			Reason:      "Unknown dependency relation",
+	// With match all the first violation decides; with match any the
+	// first satisfied resource does. Unknown results only decide when
+	// nothing else does.
+	var violated, unknown *ViolationResult
+	for _, target := range targets {
+		targetCtx := ctx
+		targetCtx.Resource = target
+		result := e.EvaluateWithContext(reqInv, targetCtx)
+		switch {
+		case result == nil:
+			if req.Scope.Match() == dsl.MatchAny {
+				return nil
+			}
+		case result.Status == StatusUnknown:
+			if unknown == nil {
+				unknown = onTarget(result, target)
+			}
+		default:
+			if req.Scope.Match() == dsl.MatchAll {
+				return onTarget(result, target)
+			}
+			if violated == nil {
+				violated = onTarget(result, target)
+			}
 		}
 	}
+	if unknown != nil {
+		return unknown
+	}
+	return violated
 }
 
 // EvaluateWithContext performs evaluation with full context
@@ -190,17 +590,29 @@ func (e *EvaluationEngine) EvaluateWithContext(inv dsl.Invariant, ctx types.Eval
 
 	result := &ViolationResult{
 		InvariantID:      inv.ID,
+		InvariantVersion: inv.Version,
 		Violated:         false,
 		AffectedResource: fmt.Sprintf("%s/%s", ctx.Resource.Namespace, ctx.Resource.Name),
 		DetectedAt:       ctx.Timestamp,
 		Severity:         inv.Severity,
+		Docs:             inv.Docs,
+		RunbookURL:       inv.RunbookURL,
+	}
+
+	// Newly created resources get time to converge before they can fail
+	if inWarmup(inv, ctx) {
+		e.logEvaluation(inv.ID, ctx.Resource.UID, ctx.Resource.CorrelationID, StatusSatisfied, "within grace period", time.Since(startTime))
+		return nil
 	}
 
 	// Step 1: Evaluate predicate if present
+	var unknownReason string
 	if inv.Predicate != nil {
-		satisfied, reason := e.evaluatePredicateWithReason(*inv.Predicate, ctx.Resource)
-		if !satisfied {
+		outcome, reason := e.evaluatePredicateOutcome(*inv.Predicate, ctx.Resource)
+		switch outcome {
+		case predicateViolated:
 			result.Violated = true
+			result.Status = StatusViolated
 			result.Reason = reason
 
 			// Step 2: Determine responsibility through authority analysis
@@ -208,8 +620,13 @@ func (e *EvaluationEngine) EvaluateWithContext(inv dsl.Invariant, ctx types.Eval
 			result.EliminatedActors = e.eliminateActors(inv.Predicate.Field, result.ResponsibleActor)
 
 			// Log evaluation
-			e.logEvaluation(inv.ID, ctx.Resource.UID, false, reason, time.Since(startTime))
+			e.logEvaluation(inv.ID, ctx.Resource.UID, ctx.Resource.CorrelationID, StatusViolated, reason, time.Since(startTime))
 			return result
+
+		case predicateUnknown:
+			// Keep checking dependencies: a failed dependency is still a
+			// definite violation even when our own field is missing
+			unknownReason = reason
 		}
 	}
 
@@ -221,169 +638,257 @@ func (e *EvaluationEngine) EvaluateWithContext(inv dsl.Invariant, ctx types.Eval
 		}
 
 		depViolation := e.evaluateDependency(req, ctx)
-		if depViolation != nil {
-			result.Violated = true
-			result.Reason = fmt.Sprintf(
-				"Dependency %s failed: %s",
-				reqInv.ID,
-				depViolation.Reason,
-			)
-			result.ResponsibleActor = depViolation.ResponsibleActor
-			result.EliminatedActors = depViolation.EliminatedActors
-
-			e.logEvaluation(inv.ID, ctx.Resource.UID, false, result.Reason, time.Since(startTime))
-			return result
+		if depViolation == nil {
+			continue
 		}
+		if depViolation.Status == StatusUnknown {
+			if unknownReason == "" {
+				unknownReason = fmt.Sprintf("Dependency %s unknown: %s", reqInv.ID, depViolation.Reason)
+			}
+			continue
+		}
+
+		result.Violated = true
+		result.Status = StatusViolated
+		result.Reason = fmt.Sprintf(
+			"Dependency %s failed: %s",
+			reqInv.ID,
+			depViolation.Reason,
+		)
+		result.ResponsibleActor = depViolation.ResponsibleActor
+		result.EliminatedActors = depViolation.EliminatedActors
+
+		e.logEvaluation(inv.ID, ctx.Resource.UID, ctx.Resource.CorrelationID, StatusViolated, result.Reason, time.Since(startTime))
+		return result
+	}
+
+	if unknownReason != "" {
+		result.Status = StatusUnknown
+		result.Reason = unknownReason
+		e.logEvaluation(inv.ID, ctx.Resource.UID, ctx.Resource.CorrelationID, StatusUnknown, unknownReason, time.Since(startTime))
+		return result
 	}
 
 	// All checks passed
-	e.logEvaluation(inv.ID, ctx.Resource.UID, true, "satisfied", time.Since(startTime))
+	e.logEvaluation(inv.ID, ctx.Resource.UID, ctx.Resource.CorrelationID, StatusSatisfied, "satisfied", time.Since(startTime))
 	return nil
 }
 
+// inWarmup reports whether the resource is still inside the invariant's
+// grace period. Resources without a known creation time are never exempt.
+func inWarmup(inv dsl.Invariant, ctx types.EvaluationContext) bool {
+	if inv.GracePeriod <= 0 || ctx.Resource.CreationTimestamp.IsZero() {
+		return false
+	}
+	return ctx.Timestamp.Sub(ctx.Resource.CreationTimestamp) < time.Duration(inv.GracePeriod)
+}
+
 func (e *EvaluationEngine) evaluatePredicateWithReason(pred dsl.Predicate, subject types.StateEvent) (bool, string) {
+	outcome, reason := e.evaluatePredicateOutcome(pred, subject)
+	return outcome == predicateSatisfied, reason
+}
+
+// predicateOutcome separates "the field holds a bad value" from "the field
+// has not been observed", so missing data is reported as unknown instead of
+// paging as a violation.
+type predicateOutcome int
+
+const (
+	predicateSatisfied predicateOutcome = iota
+	predicateViolated
+	predicateUnknown
+)
+
+func (e *EvaluationEngine) evaluatePredicateOutcome(pred dsl.Predicate, subject types.StateEvent) (predicateOutcome, string) {
 	value, exists := subject.FieldDiff[pred.Field]
+	if !exists && pred.Field == dsl.CreationTimestampField && !subject.CreationTimestamp.IsZero() {
+		value, exists = subject.CreationTimestamp, true
+	}
+
+	// Only existence checks can be decided without a value
+	if !exists && pred.Operator != dsl.Exists && pred.Operator != dsl.NotExists && pred.Operator != dsl.NotEquals {
+		return predicateUnknown, fmt.Sprintf("Field %s has not been observed", pred.Field)
+	}
+
+	// A value taken from another field is unknown until that field is seen
+	if pred.ValueFrom != nil {
+		resolved, found, reason := e.resolveValueFrom(*pred.ValueFrom, subject)
+		if !found {
+			return predicateUnknown, reason
+		}
+		pred.Value = resolved
+	}
 
 	switch pred.Operator {
 	case dsl.Exists:
 		if !exists {
-			return false, fmt.Sprintf("Field %s does not exist", pred.Field)
+			return predicateViolated, fmt.Sprintf("Field %s does not exist", pred.Field)
 		}
-		return true, ""
+		return predicateSatisfied, ""
 
 	case dsl.NotExists:
 		if exists {
-			return false, fmt.Sprintf("Field %s exists but should not (value: %v)", pred.Field, value)
+			return predicateViolated, fmt.Sprintf("Field %s exists but should not (value: %v)", pred.Field, value)
 		}
-		return true, ""
+		return predicateSatisfied, ""
 
 	case dsl.Equals:
-		if !exists {
-			return false, fmt.Sprintf("Field %s does not exist (expected: %v)", pred.Field, pred.Value)
-		}
-		if value != pred.Value {
-			return false, fmt.Sprintf("Field %s is '%v' (expected: %v)", pred.Field, value, pred.Value)
+		if !valuesEqual(value, pred.Value) {
+			return predicateViolated, fmt.Sprintf("Field %s is '%v' (expected: %v%s)", pred.Field, value, pred.Value, valueSource(pred))
 		}
-		return true, ""
+		return predicateSatisfied, ""
 
 	case dsl.NotEquals:
 		if !exists {
-			return true, ""
+			return predicateSatisfied, ""
 		}
-		if value == pred.Value {
-			return false, fmt.Sprintf("Field %s is '%v' (must not equal: %v)", pred.Field, value, pred.Value)
-		}
-		return true, ""
-
-	case dsl.GreaterThan:
-		if !exists {
-			return false, fmt.Sprintf("Field %s does not exist", pred.Field)
+		if valuesEqual(value, pred.Value) {
+			return predicateViolated, fmt.Sprintf("Field %s is '%v' (must not equal: %v%s)", pred.Field, value, pred.Value, valueSource(pred))
 		}
+		return predicateSatisfied, ""
 
+	case dsl.GreaterThan, dsl.GreaterOrEqual, dsl.LessThan, dsl.LessOrEqual:
 		numValue, ok := toNumber(value)
 		if !ok {
-			return false, fmt.Sprintf("Field %s is not numeric: %v", pred.Field, value)
+			return predicateViolated, fmt.Sprintf("Field %s is not numeric: %v", pred.Field, value)
 		}
 
 		expectedNum, ok := toNumber(pred.Value)
 		if !ok {
-			return false, "Comparison value is not numeric"
+			return predicateViolated, "Comparison value is not numeric"
 		}
 
-		if numValue <= expectedNum {
-			return false, fmt.Sprintf("Field %s is %v (must be > %v)", pred.Field, numValue, expectedNum)
-		}
-		return true, ""
-
-	case dsl.LessThan:
-		if !exists {
-			return false, fmt.Sprintf("Field %s does not exist", pred.Field)
+		if !compareNumbers(pred.Operator, numValue, expectedNum) {
+			return predicateViolated, fmt.Sprintf("Field %s is %v (must be %s %v%s)", pred.Field, numValue, comparisonSymbols[pred.Operator], expectedNum, valueSource(pred))
 		}
-
-		numValue, ok := toNumber(value)
-		if !ok {
-			return false, fmt.Sprintf("Field %s is not numeric: %v", pred.Field, value)
-		}
-
-		expectedNum, ok := toNumber(pred.Value)
-		if !ok {
-			return false, "Comparison value is not numeric"
-		}
-
-		if numValue >= expectedNum {
-			return false, fmt.Sprintf("Field %s is %v (must be < %v)", pred.Field, numValue, expectedNum)
-		}
-		return true, ""
+		return predicateSatisfied, ""
 
 	case dsl.AnyTrue:
 		// For array fields: check if any element is truthy
-		if !exists {
-			return false, fmt.Sprintf("Field %s does not exist", pred.Field)
-		}
-
 		// Handle array values
 		if arr, ok := value.([]interface{}); ok {
 			for _, item := range arr {
 				if isTruthy(item) {
-					return true, ""
+					return predicateSatisfied, ""
 				}
 			}
-			return false, fmt.Sprintf("Field %s has no truthy elements", pred.Field)
+			return predicateViolated, fmt.Sprintf("Field %s has no truthy elements", pred.Field)
 		}
 
 		// Handle single value
 		if isTruthy(value) {
-			return true, ""
+			return predicateSatisfied, ""
 		}
-		return false, fmt.Sprintf("Field %s is not true", pred.Field)
+		return predicateViolated, fmt.Sprintf("Field %s is not true", pred.Field)
 
 	case dsl.AllTrue:
 		// For array fields: check if all elements are truthy
-		if !exists {
-			return false, fmt.Sprintf("Field %s does not exist", pred.Field)
-		}
-
 		// Handle array values
 		if arr, ok := value.([]interface{}); ok {
 			if len(arr) == 0 {
-				return false, fmt.Sprintf("Field %s is empty array", pred.Field)
+				return predicateViolated, fmt.Sprintf("Field %s is empty array", pred.Field)
 			}
 			for _, item := range arr {
 				if !isTruthy(item) {
-					return false, fmt.Sprintf("Field %s has non-truthy element: %v", pred.Field, item)
+					return predicateViolated, fmt.Sprintf("Field %s has non-truthy element: %v", pred.Field, item)
 				}
 			}
-			return true, ""
+			return predicateSatisfied, ""
 		}
 
 		// Handle single value
 		if isTruthy(value) {
-			return true, ""
+			return predicateSatisfied, ""
 		}
-		return false, fmt.Sprintf("Field %s is not true", pred.Field)
+		return predicateViolated, fmt.Sprintf("Field %s is not true", pred.Field)
 
 	case dsl.Contains:
 		// Check if array contains a value
-		if !exists {
-			return false, fmt.Sprintf("Field %s does not exist", pred.Field)
-		}
-
 		if arr, ok := value.([]interface{}); ok {
 			for _, item := range arr {
 				if item == pred.Value {
-					return true, ""
+					return predicateSatisfied, ""
 				}
 			}
-			return false, fmt.Sprintf("Field %s does not contain %v", pred.Field, pred.Value)
+			return predicateViolated, fmt.Sprintf("Field %s does not contain %v", pred.Field, pred.Value)
+		}
+
+		return predicateViolated, fmt.Sprintf("Field %s is not an array", pred.Field)
+
+	case dsl.OlderThan, dsl.NewerThan:
+		timestamp, ok := toTime(value)
+		if !ok {
+			return predicateViolated, fmt.Sprintf("Field %s is not a timestamp: %v", pred.Field, value)
+		}
+
+		threshold, ok := toDuration(pred.Value)
+		if !ok {
+			return predicateViolated, "Comparison value is not a duration"
 		}
 
-		return false, fmt.Sprintf("Field %s is not an array", pred.Field)
+		age := time.Since(timestamp)
+		if pred.Operator == dsl.OlderThan && age <= threshold {
+			return predicateViolated, fmt.Sprintf("Field %s is %v old (must be older than %v)", pred.Field, age.Round(time.Second), threshold)
+		}
+		if pred.Operator == dsl.NewerThan && age >= threshold {
+			return predicateViolated, fmt.Sprintf("Field %s is %v old (must be newer than %v)", pred.Field, age.Round(time.Second), threshold)
+		}
+		return predicateSatisfied, ""
 
 	default:
-		return false, fmt.Sprintf("Unknown operator: %s", pred.Operator)
+		return predicateViolated, fmt.Sprintf("Unknown operator: %s", pred.Operator)
 	}
 }
 
+var comparisonSymbols = map[dsl.Operator]string{
+	dsl.GreaterThan:    ">",
+	dsl.GreaterOrEqual: ">=",
+	dsl.LessThan:       "<",
+	dsl.LessOrEqual:    "<=",
+}
+
+func compareNumbers(op dsl.Operator, value, expected float64) bool {
+	switch op {
+	case dsl.GreaterThan:
+		return value > expected
+	case dsl.GreaterOrEqual:
+		return value >= expected
+	case dsl.LessThan:
+		return value < expected
+	default:
+		return value <= expected
+	}
+}
+
+// valuesEqual compares numbers by value, so an int recorded by the watcher
+// equals the float64 decoded from JSON
+func valuesEqual(a, b interface{}) bool {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			return x == y
+		}
+	}
+	return a == b
+}
+
+// valueSource names the field a comparison value was read from, for
+// violation reasons
+func valueSource(pred dsl.Predicate) string {
+	ref := pred.ValueFrom
+	if ref == nil {
+		return ""
+	}
+	source := " from "
+	if ref.Percent != 0 {
+		source += fmt.Sprintf("%g%% of ", ref.Percent)
+	}
+	source += ref.Field
+	if ref.Relation != "" && ref.Relation != dsl.Same {
+		source += " of the " + string(ref.Relation)
+	}
+	return source
+}
+
 func isTruthy(value interface{}) bool {
 	switch v := value.(type) {
 	case bool:
@@ -402,6 +907,14 @@ func isTruthy(value interface{}) bool {
 }
 
 func (e *EvaluationEngine) determineResponsibility(inv dsl.Invariant, resource types.StateEvent) string {
+	// Findings about how a workload was configured belong to whoever
+	// deployed it, not to the controller that last touched the object
+	if field := inv.Responsibility.ActorField; field != "" {
+		if actor, ok := resource.FieldDiff[field].(string); ok && actor != "" {
+			return actor
+		}
+	}
+
 	// Use authority map to determine which controller is responsible
 	if inv.Predicate != nil {
 		authorizedControllers := e.authorityMap.GetAuthorizedControllers(inv.Predicate.Field)
@@ -435,20 +948,23 @@ func (e *EvaluationEngine) eliminateActors(field string, primary string) []strin
 	return eliminated
 }
 
-func (e *EvaluationEngine) logEvaluation(invID, resourceUID string, result bool, reason string, duration time.Duration) {
+func (e *EvaluationEngine) logEvaluation(invID, resourceUID, correlationID string, status EvaluationStatus, reason string, duration time.Duration) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	entry := EvaluationLogEntry{
-		InvariantID: invID,
-		ResourceUID: resourceUID,
-		Result:      result,
-		Reason:      reason,
-		Timestamp:   time.Now(),
-		Duration:    duration,
+		InvariantID:   invID,
+		ResourceUID:   resourceUID,
+		Result:        status != StatusViolated,
+		Status:        status,
+		Reason:        reason,
+		CorrelationID: correlationID,
+		Timestamp:     time.Now(),
+		Duration:      duration,
 	}
 
 	e.evaluationLog = append(e.evaluationLog, entry)
+	e.sample(entry)
 
 	// Keep only last 1000 entries
 	if len(e.evaluationLog) > 1000 {
@@ -462,12 +978,16 @@ func (e *EvaluationEngine) GetEvaluationStats() map[string]interface{} {
 
 	totalEvaluations := len(e.evaluationLog)
 	violations := 0
+	unknown := 0
 	var totalDuration time.Duration
 
 	for _, entry := range e.evaluationLog {
 		if !entry.Result {
 			violations++
 		}
+		if entry.Status == StatusUnknown {
+			unknown++
+		}
 		totalDuration += entry.Duration
 	}
 
@@ -479,6 +999,7 @@ func (e *EvaluationEngine) GetEvaluationStats() map[string]interface{} {
 	return map[string]interface{}{
 		"total_evaluations": totalEvaluations,
 		"violations_found":  violations,
+		"unknown_found":     unknown,
 		"avg_duration_ms":   avgDuration.Milliseconds(),
 		"total_invariants":  len(e.invariants),
 	}
@@ -501,6 +1022,43 @@ func toNumber(value interface{}) (float64, bool) {
 	}
 }
 
+func toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, false
+		}
+		return *v, !v.IsZero()
+	case string:
+		// Timestamps decoded from JSON or recorded by the watcher
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil && !t.IsZero()
+	default:
+		return time.Time{}, false
+	}
+}
+
+// toDuration accepts duration strings ("5m", "7d") and numbers of seconds
+func toDuration(value interface{}) (time.Duration, bool) {
+	switch v := value.(type) {
+	case string:
+		d, err := dsl.ParseDuration(v)
+		return d, err == nil
+	case time.Duration:
+		return v, true
+	case dsl.Duration:
+		return time.Duration(v), true
+	}
+
+	seconds, ok := toNumber(value)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
 		if s == item {
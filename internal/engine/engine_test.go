@@ -1,12 +1,16 @@
 package engine
 
 import (
+	"context"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aonescu/akari/internal/dsl"
 	"github.com/aonescu/akari/internal/state"
 	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
 )
 
 func TestInvariantEngine_EvaluateAll(t *testing.T) {
@@ -124,7 +128,18 @@ func TestInvariantEngine_EvaluateWithDependencies(t *testing.T) {
 	store := state.NewMemoryStore()
 	eng := NewInvariantEngine(store)
 
-	// Add a pod that's not scheduled (should violate pod_scheduled)
+	// A ready pod on a node that isn't: pod_ready fails through its node
+	store.Record(types.StateEvent{
+		UID:       "node-1",
+		Kind:      "Node",
+		Name:      "node-1",
+		Version:   "1",
+		Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{
+			"status.conditions[Ready].status": "False",
+		},
+		Actor: "node-controller",
+	})
 	pod := types.StateEvent{
 		UID:       "pod-1",
 		Kind:      "Pod",
@@ -133,42 +148,668 @@ func TestInvariantEngine_EvaluateWithDependencies(t *testing.T) {
 		Version:   "1",
 		Timestamp: time.Now(),
 		FieldDiff: map[string]interface{}{
-			"spec.nodeName": nil, // Not scheduled
+			"spec.nodeName":                             "node-1",
+			"status.conditions[Ready].status":           "True",
+			"status.containerStatuses[*].state.running": []interface{}{true},
 		},
-		Actor: "kube-scheduler",
+		Actor: "kubelet",
 	}
 
 	store.Record(pod)
 
-	// Evaluate pod_ready which requires pod_scheduled
-	invariants := eng.GetInvariants()
-	var podReadyInv dsl.Invariant
-	for _, inv := range invariants {
-		if inv.ID == "pod_ready" {
-			podReadyInv = inv
-			break
+	podReadyInv, ok := eng.GetInvariantByID("pod_ready")
+	if !ok {
+		t.Fatal("pod_ready invariant not found")
+	}
+
+	violations := eng.Evaluate(podReadyInv)
+	if len(violations) != 1 || !violations[0].Violated {
+		t.Fatalf("Expected pod_ready violated through its node, got %+v", violations)
+	}
+	if want := "Dependency node_ready failed: Node node-1:"; !strings.HasPrefix(violations[0].Reason, want) {
+		t.Errorf("Expected reason starting %q, got %q", want, violations[0].Reason)
+	}
+	if violations[0].ResponsibleActor != "node-controller" {
+		t.Errorf("Expected node-controller blamed, got %q", violations[0].ResponsibleActor)
+	}
+
+	// Unscheduled, the node is unknown rather than failed
+	pod.Version = "2"
+	pod.FieldDiff = map[string]interface{}{
+		"status.conditions[Ready].status":           "True",
+		"status.containerStatuses[*].state.running": []interface{}{true},
+	}
+	store.Record(pod)
+	violations = eng.Evaluate(podReadyInv)
+	if len(violations) != 1 || violations[0].Violated || violations[0].Status != StatusUnknown {
+		t.Fatalf("Expected pod_ready unknown without a node, got %+v", violations)
+	}
+}
+
+func TestInvariantEngine_ServiceEndpointsFollowSelectedPods(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	store.Record(types.StateEvent{UID: "node-1", Kind: "Node", Name: "node-1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "True"}})
+	recordPod := func(uid, app, ready string) {
+		store.Record(types.StateEvent{UID: uid, Kind: "Pod", Name: uid, Namespace: "default", Timestamp: time.Now(),
+			Labels: map[string]string{"app": app},
+			FieldDiff: map[string]interface{}{
+				"spec.nodeName":                             "node-1",
+				"status.conditions[Ready].status":           ready,
+				"status.containerStatuses[*].state.running": []interface{}{true},
+			}})
+	}
+	recordPod("web-1", "web", "False")
+	recordPod("web-2", "web", "True")
+	// Ready, but not selected by the Service
+	recordPod("worker-1", "worker", "True")
+	store.Record(types.StateEvent{UID: "svc-1", Kind: "Service", Name: "web", Namespace: "default", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{
+			"spec.selector":          map[string]interface{}{"app": "web"},
+			"endpoints[*].addresses": []interface{}{true},
+		}})
+
+	inv, _ := eng.GetInvariantByID("service_has_endpoints")
+	if results := eng.Evaluate(inv); len(results) != 0 {
+		t.Fatalf("Expected one ready selected pod to satisfy the Service, got %+v", results)
+	}
+
+	recordPod("web-2", "web", "False")
+	results := eng.Evaluate(inv)
+	if len(results) != 1 || !results[0].Violated {
+		t.Fatalf("Expected the Service violated once no selected pod is ready, got %+v", results)
+	}
+	if !strings.Contains(results[0].Reason, "Dependency pod_ready failed: Pod default/web-1") {
+		t.Errorf("Expected the reason to name a selected pod, got %q", results[0].Reason)
+	}
+}
+
+func TestInvariantEngine_EvaluateFiltersBySubject(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	inv := dsl.Invariant{
+		ID:          "prod_web_ready",
+		Description: "Production web pods should be Ready",
+		Subject: dsl.Subject{
+			Kind:      "Pod",
+			Namespace: "prod",
+			Selector:  map[string]string{"app": "web"},
+		},
+		Predicate: &dsl.Predicate{
+			Field:    "status.conditions[Ready].status",
+			Operator: dsl.Equals,
+			Value:    "True",
+		},
+		Responsibility: dsl.Responsibility{Primary: "kubelet"},
+		Severity:       dsl.Critical,
+	}
+
+	notReady := map[string]interface{}{"status.conditions[Ready].status": "False"}
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "web-1",
+		Labels: map[string]string{"app": "web"}, FieldDiff: notReady})
+	store.Record(types.StateEvent{UID: "pod-2", Kind: "Pod", Namespace: "staging", Name: "web-2",
+		Labels: map[string]string{"app": "web"}, FieldDiff: notReady})
+	store.Record(types.StateEvent{UID: "pod-3", Kind: "Pod", Namespace: "prod", Name: "db-1",
+		Labels: map[string]string{"app": "db"}, FieldDiff: notReady})
+
+	violations := eng.Evaluate(inv)
+	if len(violations) != 1 {
+		t.Fatalf("Expected 1 violation, got %d", len(violations))
+	}
+	if violations[0].AffectedResource != "prod/web-1" {
+		t.Errorf("Expected violation for prod/web-1, got %s", violations[0].AffectedResource)
+	}
+}
+
+func TestInvariantEngine_NamespaceFilters(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	store.Record(types.StateEvent{UID: "ns-shop", Kind: "Namespace", Name: "shop", Labels: map[string]string{"env": "prod"}})
+	store.Record(types.StateEvent{UID: "ns-dev", Kind: "Namespace", Name: "dev", Labels: map[string]string{"env": "dev"}})
+	for _, ns := range []string{"shop", "dev", "kube-system", ""} {
+		store.Record(types.StateEvent{UID: "cm-" + ns, Kind: "ConfigMap", Namespace: ns, Name: "cm-" + ns})
+	}
+
+	affected := func(subject dsl.Subject) []string {
+		subject.Kind = "ConfigMap"
+		var resources []string
+		for _, resource := range store.GetLatestByKind("ConfigMap") {
+			if eng.Matches(subject, resource) {
+				resources = append(resources, resource.Namespace)
+			}
 		}
+		slices.Sort(resources)
+		return resources
 	}
 
-	if podReadyInv.ID == "" {
-		t.Fatal("pod_ready invariant not found")
+	cases := []struct {
+		name    string
+		subject dsl.Subject
+		want    []string
+	}{
+		{"all", dsl.Subject{}, []string{"", "dev", "kube-system", "shop"}},
+		{"exclude", dsl.Subject{ExcludeNamespaces: []string{"kube-system"}}, []string{"", "dev", "shop"}},
+		{"namespaced", dsl.Subject{Scope: dsl.ScopeNamespaced, ExcludeNamespaces: []string{"kube-system"}}, []string{"dev", "shop"}},
+		{"cluster", dsl.Subject{Scope: dsl.ScopeCluster}, []string{""}},
+		{"include", dsl.Subject{Namespace: "dev", Namespaces: []string{"shop"}}, []string{"dev", "shop"}},
+		{"namespace selector", dsl.Subject{NamespaceSelector: map[string]string{"env": "prod"}}, []string{"shop"}},
+	}
+	for _, tc := range cases {
+		if got := affected(tc.subject); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: expected namespaces %q, got %q", tc.name, tc.want, got)
+		}
 	}
 
-	violations := eng.Evaluate(podReadyInv)
-	if len(violations) == 0 {
-		t.Fatal("Expected violations for unscheduled pod")
+	invalid := dsl.Invariant{
+		ID: "cluster_cm", Subject: dsl.Subject{Kind: "ConfigMap", Scope: dsl.ScopeCluster, Namespaces: []string{"shop"}},
+		Predicate: &dsl.Predicate{Field: "data", Operator: dsl.Exists}, Severity: dsl.Warning,
+	}
+	if err := invalid.Validate(); err == nil {
+		t.Error("Expected cluster scope with a namespace filter to be rejected")
 	}
+}
 
-	// Should have violation due to dependency failure
-	foundDependencyViolation := false
-	for _, v := range violations {
-		if v.Violated && (v.InvariantID == "pod_ready" || v.Reason != "") {
-			foundDependencyViolation = true
-			break
+func TestInvariantEngine_FieldToFieldPredicates(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	for _, inv := range eng.GetInvariants() {
+		eng.DeleteInvariant(inv.ID)
+	}
+
+	replicasMatch := dsl.Invariant{
+		ID: "replicas_available", Subject: dsl.Subject{Kind: "Deployment"}, Severity: dsl.Degraded,
+		Predicate: &dsl.Predicate{Field: "status.availableReplicas", Operator: dsl.GreaterOrEqual, ValueFrom: &dsl.FieldRef{Field: "spec.replicas"}},
+	}
+	podsOnNode := dsl.Invariant{
+		ID: "node_has_room", Subject: dsl.Subject{Kind: "Pod"}, Severity: dsl.Warning,
+		Predicate: &dsl.Predicate{Field: "spec.containers[app].image", Operator: dsl.NotEquals, ValueFrom: &dsl.FieldRef{Field: "status.images.blocked", Relation: dsl.Node}},
+	}
+	ownerReplicas := dsl.Invariant{
+		ID: "under_owner_replicas", Subject: dsl.Subject{Kind: "Pod"}, Severity: dsl.Warning,
+		Predicate: &dsl.Predicate{Field: "index", Operator: dsl.LessThan, ValueFrom: &dsl.FieldRef{Field: "spec.replicas", Relation: dsl.Owner}},
+	}
+	for _, inv := range []dsl.Invariant{replicasMatch, podsOnNode, ownerReplicas} {
+		if err := inv.Validate(); err != nil {
+			t.Fatalf("Validate(%s) failed: %v", inv.ID, err)
+		}
+		eng.UpsertInvariant(inv)
+	}
+
+	store.Record(types.StateEvent{UID: "d-1", Kind: "Deployment", Namespace: "shop", Name: "api", Version: "1",
+		FieldDiff: map[string]interface{}{"spec.replicas": 3, "status.availableReplicas": 2}})
+	store.Record(types.StateEvent{UID: "d-2", Kind: "Deployment", Namespace: "shop", Name: "web", Version: "1",
+		FieldDiff: map[string]interface{}{"spec.replicas": 2, "status.availableReplicas": float64(2)}})
+	store.Record(types.StateEvent{UID: "node-1", Kind: "Node", Name: "worker-1", Version: "1",
+		FieldDiff: map[string]interface{}{"status.images.blocked": "evil:latest"}})
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "shop", Name: "api-7f9c6d-a", Version: "1",
+		Labels: map[string]string{"pod-template-hash": "7f9c6d"},
+		FieldDiff: map[string]interface{}{
+			state.FieldController:        "ReplicaSet/api-7f9c6d",
+			"spec.nodeName":              "worker-1",
+			"spec.containers[app].image": "evil:latest",
+			"index":                      4,
+		}})
+
+	byID := make(map[string]*ViolationResult)
+	for _, v := range FilterByStatus(eng.EvaluateAll(), StatusViolated) {
+		byID[v.InvariantID+"|"+v.AffectedResource] = v
+	}
+	if len(byID) != 3 {
+		t.Fatalf("Expected 3 violations, got %v", byID)
+	}
+	if v := byID["replicas_available|shop/api"]; v == nil || v.Reason != "Field status.availableReplicas is 2 (must be >= 3 from spec.replicas)" {
+		t.Errorf("Unexpected replica violation %+v", v)
+	}
+	if byID["node_has_room|shop/api-7f9c6d-a"] == nil {
+		t.Error("Expected the pod to be compared against its node")
+	}
+	if byID["under_owner_replicas|shop/api-7f9c6d-a"] == nil {
+		t.Error("Expected the pod to be compared against its Deployment")
+	}
+
+	invalid := replicasMatch
+	invalid.Predicate = &dsl.Predicate{Field: "a", Operator: dsl.Equals, Value: 1, ValueFrom: &dsl.FieldRef{Field: "b"}}
+	if err := invalid.Validate(); err == nil {
+		t.Error("Expected value and value_from together to be rejected")
+	}
+}
+
+func TestInvariantEngine_PercentThresholds(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	for _, inv := range eng.GetInvariants() {
+		eng.DeleteInvariant(inv.ID)
+	}
+	eng.UpsertInvariant(dsl.Invariant{
+		ID: "mostly_available", Subject: dsl.Subject{Kind: "Deployment"}, Severity: dsl.Degraded,
+		Predicate: &dsl.Predicate{
+			Field:     "status.availableReplicas",
+			Operator:  dsl.GreaterOrEqual,
+			ValueFrom: &dsl.FieldRef{Field: "spec.replicas", Percent: 80},
+		},
+	})
+
+	record := func(name string, replicas, available int) {
+		store.Record(types.StateEvent{UID: name, Kind: "Deployment", Namespace: "shop", Name: name, Version: "1",
+			FieldDiff: map[string]interface{}{"spec.replicas": replicas, "status.availableReplicas": available}})
+	}
+	record("small", 5, 4)   // 80% of 5 is 4
+	record("large", 50, 39) // 80% of 50 is 40
+	record("scaling", 10, 8)
+
+	violations := FilterByStatus(eng.EvaluateAll(), StatusViolated)
+	if len(violations) != 1 || violations[0].AffectedResource != "shop/large" {
+		t.Fatalf("Expected only shop/large below 80%%, got %+v", violations)
+	}
+	if want := "Field status.availableReplicas is 39 (must be >= 40 from 80% of spec.replicas)"; violations[0].Reason != want {
+		t.Errorf("Expected reason %q, got %q", want, violations[0].Reason)
+	}
+}
+
+func TestEvaluationEngine_KindIndex(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	for _, inv := range eng.evalEngine.InvariantsForKind("Node") {
+		if inv.Subject.Kind != "Node" {
+			t.Errorf("Invariant %s indexed under Node but targets %s", inv.ID, inv.Subject.Kind)
+		}
+	}
+
+	if len(eng.evalEngine.InvariantsForKind("Node")) == 0 {
+		t.Error("Expected node invariants to be indexed")
+	}
+
+	if len(eng.evalEngine.InvariantsForKind("ConfigMap")) != 0 {
+		t.Error("Expected no invariants indexed for ConfigMap")
+	}
+
+	// Evaluating only nodes must not produce pod or service results
+	store.Record(types.StateEvent{
+		UID:       "node-1",
+		Kind:      "Node",
+		Name:      "node-1",
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False"},
+	})
+
+	for _, v := range eng.EvaluateAll() {
+		if inv, _ := eng.GetInvariantByID(v.InvariantID); inv.Subject.Kind != "Node" {
+			t.Errorf("Unexpected result for invariant %s", v.InvariantID)
+		}
+	}
+}
+
+func TestInvariantEngine_UpsertAndDeleteKeepVersions(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	inv, _ := eng.GetInvariantByID("pod_ready")
+	inv.Description = "Pod should be Ready (tightened)"
+
+	updated := eng.UpsertInvariant(inv)
+	if updated.Version != 2 {
+		t.Fatalf("Expected version 2 after update, got %d", updated.Version)
+	}
+
+	store.Record(types.StateEvent{
+		UID:       "pod-1",
+		Kind:      "Pod",
+		Name:      "test-pod",
+		Namespace: "default",
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False"},
+	})
+	for _, v := range eng.Evaluate(updated) {
+		if v.InvariantVersion != 2 {
+			t.Errorf("Expected violation from version 2, got %d", v.InvariantVersion)
+		}
+	}
+
+	if _, ok := eng.DeleteInvariant("pod_ready"); !ok {
+		t.Fatal("Expected pod_ready to be deleted")
+	}
+	if _, exists := eng.GetInvariantByID("pod_ready"); exists {
+		t.Error("Deleted invariant should not be active")
+	}
+	for _, kindInv := range eng.evalEngine.InvariantsForKind("Pod") {
+		if kindInv.ID == "pod_ready" {
+			t.Error("Deleted invariant should be removed from the kind index")
+		}
+	}
+
+	versions := eng.GetInvariantVersions("pod_ready")
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 versions in history, got %d", len(versions))
+	}
+	if versions[0].Version != 1 || versions[1].Version != 2 {
+		t.Errorf("Unexpected version order: %d, %d", versions[0].Version, versions[1].Version)
+	}
+	if versions[1].DeletedAt == nil {
+		t.Error("Expected latest version to be marked deleted")
+	}
+}
+
+func TestInvariantEngine_ResolveExtends(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:             "prod_pod_ready",
+		Subject:        dsl.Subject{Kind: "Pod", Namespaces: []string{"prod"}, Selector: map[string]string{"tier": "web"}},
+		Predicate:      &dsl.Predicate{Field: "status.conditions[Ready].status", Operator: dsl.Equals, Value: "True"},
+		Responsibility: dsl.Responsibility{Primary: "kubelet", Team: "web"},
+		Severity:       dsl.Critical,
+		Tags:           []string{dsl.TagAvailability},
+	})
+
+	inv, err := eng.ResolveExtends(dsl.Invariant{
+		ID:        "prod_pod_running",
+		Extends:   "prod_pod_ready",
+		Subject:   dsl.Subject{Namespaces: []string{"prod", "payments"}},
+		Predicate: &dsl.Predicate{Field: "status.phase", Operator: dsl.Equals, Value: "Running"},
+	})
+	if err != nil {
+		t.Fatalf("ResolveExtends failed: %v", err)
+	}
+	if err := inv.Validate(); err != nil {
+		t.Fatalf("Expected the resolved invariant to be valid, got %v", err)
+	}
+	if inv.Subject.Kind != "Pod" || inv.Subject.Selector["tier"] != "web" || len(inv.Subject.Namespaces) != 2 {
+		t.Errorf("Expected the subject inherited with its namespaces overridden, got %+v", inv.Subject)
+	}
+	if inv.Predicate.Field != "status.phase" || inv.Severity != dsl.Critical || inv.Responsibility.Team != "web" || inv.Tags[0] != dsl.TagAvailability {
+		t.Errorf("Expected the predicate kept and the rest inherited, got %+v", inv)
+	}
+	if inv.Extends != "prod_pod_ready" || inv.ID != "prod_pod_running" {
+		t.Errorf("Expected the invariant to keep its identity, got %+v", inv)
+	}
+
+	// The copy stands alone
+	inv.Subject.Selector["tier"] = "api"
+	if base, _ := eng.GetInvariantByID("prod_pod_ready"); base.Subject.Selector["tier"] != "web" {
+		t.Error("Expected the base's selector to be left alone")
+	}
+
+	if _, err := eng.ResolveExtends(dsl.Invariant{ID: "a", Extends: "missing"}); err == nil {
+		t.Error("Expected an error for a missing base")
+	}
+	if _, err := eng.ResolveExtends(dsl.Invariant{ID: "pod_ready", Extends: "pod_ready"}); err == nil {
+		t.Error("Expected an error for an invariant extending itself")
+	}
+}
+
+func TestInvariantEngine_GracePeriod(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	podReady, _ := eng.GetInvariantByID("pod_ready")
+	if podReady.GracePeriod == 0 {
+		t.Fatal("Expected pod_ready to define a grace period")
+	}
+
+	notReady := map[string]interface{}{"status.conditions[Ready].status": "False"}
+	store.Record(types.StateEvent{
+		UID:               "pod-new",
+		Kind:              "Pod",
+		Namespace:         "default",
+		Name:              "new-pod",
+		CreationTimestamp: time.Now().Add(-5 * time.Second),
+		FieldDiff:         notReady,
+	})
+	store.Record(types.StateEvent{
+		UID:               "pod-old",
+		Kind:              "Pod",
+		Namespace:         "default",
+		Name:              "old-pod",
+		CreationTimestamp: time.Now().Add(-10 * time.Minute),
+		FieldDiff:         notReady,
+	})
+
+	for _, v := range eng.Evaluate(podReady) {
+		if v.AffectedResource == "default/new-pod" {
+			t.Errorf("Pod inside grace period should not be reported, got %s", v.Reason)
+		}
+	}
+
+	found := false
+	for _, v := range eng.Evaluate(podReady) {
+		if v.AffectedResource == "default/old-pod" && v.Violated {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected violation for pod past its grace period")
+	}
+}
+
+func TestInvariantEngine_TierWeighting(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:        "widget_ready",
+		Subject:   dsl.Subject{Kind: "Widget"},
+		Severity:  dsl.Critical,
+		Predicate: &dsl.Predicate{Field: "status.ready", Operator: dsl.Equals, Value: "True"},
+	})
+
+	record := func(uid, tier, ready string) {
+		store.Record(types.StateEvent{
+			UID:       uid,
+			Kind:      "Widget",
+			Namespace: "shop",
+			Name:      uid,
+			Tier:      tier,
+			Version:   "1",
+			Timestamp: time.Now(),
+			FieldDiff: map[string]interface{}{"status.ready": ready},
+		})
+	}
+	record("payments", "tier-1", "False")
+	record("canary", "tier-3", "False")
+	record("catalog", "tier-1", "True")
+
+	inv, _ := eng.GetInvariantByID("widget_ready")
+	ranked := RankByImpact(eng.Evaluate(inv))
+	if len(ranked) != 2 || ranked[0].ResourceUID != "payments" {
+		t.Fatalf("Expected payments to rank first, got %+v", ranked)
+	}
+	if ranked[0].Impact <= ranked[1].Impact {
+		t.Errorf("Expected tier-1 impact %f above tier-3 impact %f", ranked[0].Impact, ranked[1].Impact)
+	}
+	if ranked[0].LogicalResource != "Widget/shop/payments" {
+		t.Errorf("Expected logical resource Widget/shop/payments, got %q", ranked[0].LogicalResource)
+	}
+
+	// Weights 10 (payments, violating) + 1 (canary, violating) + 10 (catalog)
+	health := eng.HealthScore(eng.Evaluate(inv))
+	if health.Resources != 3 || health.Violating != 2 {
+		t.Errorf("Expected 2 of 3 resources violating, got %+v", health)
+	}
+	want := 100 * (1 - 11.0/21.0)
+	if health.Score < want-0.01 || health.Score > want+0.01 {
+		t.Errorf("Expected score %f, got %f", want, health.Score)
+	}
+}
+
+func TestInvariantEngine_ResultsCarryRunbook(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:         "widget_ready",
+		Subject:    dsl.Subject{Kind: "Widget"},
+		Severity:   dsl.Warning,
+		Predicate:  &dsl.Predicate{Field: "status.ready", Operator: dsl.Equals, Value: "True"},
+		Docs:       "Widgets recover after a restart.",
+		RunbookURL: "https://runbooks.example.com/widgets",
+		Tags:       []string{"availability"},
+	})
+	store.Record(types.StateEvent{
+		UID: "w-1", Kind: "Widget", Name: "w", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"status.ready": "False"},
+	})
+
+	inv, _ := eng.GetInvariantByID("widget_ready")
+	results := eng.Evaluate(inv)
+	if len(results) != 1 || results[0].RunbookURL != "https://runbooks.example.com/widgets" || results[0].Docs == "" {
+		t.Errorf("Expected runbook and docs on result, got %+v", results)
+	}
+}
+
+func TestInvariantEngine_SecurityFindingsBlameDeployer(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{
+			"security.privileged": "True",
+			"metadata.deployedBy": "argocd-controller",
+			"spec.nodeName":       "node-1",
+		},
+		Actor: "kubelet",
+	})
+
+	var result *ViolationResult
+	for _, v := range eng.EvaluateAll() {
+		if v.InvariantID == "pod_not_privileged" {
+			result = v
 		}
 	}
+	if result == nil {
+		t.Fatal("Expected pod_not_privileged to be violated")
+	}
+	if result.ResponsibleActor != "argocd-controller" {
+		t.Errorf("Expected the deploying actor to be responsible, got %s", result.ResponsibleActor)
+	}
+	if !contains(result.EliminatedActors, "kubelet") {
+		t.Errorf("Expected kubelet to be eliminated, got %v", result.EliminatedActors)
+	}
+}
 
-	if !foundDependencyViolation {
-		t.Error("Expected violation due to dependency failure")
+func TestInvariantEngine_UnschedulableEvidence(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	store.Record(types.StateEvent{
+		UID: "node-1", Kind: "Node", Name: "worker-1", Version: "1", Timestamp: time.Now(),
+		Labels:    map[string]string{"kubernetes.io/arch": "amd64"},
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "True"},
+	})
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "api", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{
+			"spec.nodeSelector":                      map[string]string{"kubernetes.io/arch": "arm64"},
+			"status.conditions[PodScheduled].reason": "Unschedulable",
+		},
+	})
+
+	for _, v := range eng.EvaluateAll() {
+		if v.InvariantID != "pod_scheduled" {
+			continue
+		}
+		if len(v.Evidence) != 1 || v.Evidence[0] != "no node matches kubernetes.io/arch=arm64" {
+			t.Errorf("Unexpected evidence: %v", v.Evidence)
+		}
+		return
+	}
+	t.Error("Expected pod_scheduled to be violated")
+}
+
+func TestInvariantEngine_SchedulingFailureQuotesScheduler(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "data-science", Name: "ml-training-job", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{
+			"status.conditions[PodScheduled].reason":  "Unschedulable",
+			"status.conditions[PodScheduled].message": "0/2 nodes are available: 2 Insufficient cpu.",
+		},
+	})
+
+	for _, v := range eng.EvaluateAll() {
+		if v.InvariantID != "no_scheduling_failure" {
+			continue
+		}
+		if v.ResponsibleActor != "kube-scheduler" {
+			t.Errorf("Expected kube-scheduler to be responsible, got %s", v.ResponsibleActor)
+		}
+		if len(v.Evidence) < 2 || v.Evidence[1] != "scheduler: 2 node(s) rejected: Insufficient cpu" {
+			t.Errorf("Expected the scheduler's breakdown as evidence, got %v", v.Evidence)
+		}
+		return
+	}
+	t.Error("Expected no_scheduling_failure to be violated")
+}
+
+func TestInvariantEngine_EvaluateSnapshot(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:        "web_ready",
+		Subject:   dsl.Subject{Kind: "Pod"},
+		Severity:  dsl.Critical,
+		Predicate: &dsl.Predicate{Field: "status.conditions[Ready].status", Operator: dsl.Equals, Value: "True"},
+	})
+
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Name: "web", Namespace: "default", Version: "2", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "True"},
+	})
+	past := []types.StateEvent{{
+		UID: "pod-1", Kind: "Pod", Name: "web", Namespace: "default", Version: "1", Timestamp: time.Now().Add(-time.Hour),
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False"},
+	}}
+
+	violatedWeb := func(results []*ViolationResult) bool {
+		for _, v := range FilterByStatus(results, StatusViolated) {
+			if v.InvariantID == "web_ready" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !violatedWeb(eng.EvaluateSnapshot(past)) {
+		t.Error("Expected web_ready to be violated in the past snapshot")
+	}
+	if violatedWeb(eng.EvaluateAll()) {
+		t.Error("Expected the live store to be unaffected by the snapshot")
+	}
+}
+
+func TestInvariantEngine_DeletedPodNoLongerViolates(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	queue := watcher.NewEventQueue(0)
+
+	// A terminating pod is still evaluated, and pod_exists fires
+	pod := types.StateEvent{UID: "pod-1", Kind: "Pod", Name: "web", Namespace: "default", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{watcher.FieldDeletionTimestamp: time.Now().UTC().Format(time.RFC3339)}}
+	store.Record(pod)
+	if !podExistsViolated(eng.EvaluateAll()) {
+		t.Fatal("Expected pod_exists violated while the pod is terminating")
+	}
+
+	pod.Version = "2"
+	queue.EnqueueDeleted(pod)
+	queue.Close()
+	queue.Run(context.Background(), store)
+	if podExistsViolated(eng.EvaluateAll()) {
+		t.Error("Expected no pod_exists violation once the pod is deleted")
+	}
+	if _, exists := store.GetByUID("pod-1"); exists {
+		t.Error("Expected the deleted pod forgotten")
+	}
+}
+
+func podExistsViolated(results []*ViolationResult) bool {
+	for _, r := range FilterByStatus(results, StatusViolated) {
+		if r.InvariantID == "pod_exists" {
+			return true
+		}
 	}
+	return false
 }
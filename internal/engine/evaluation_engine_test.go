@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -436,3 +437,227 @@ func TestEvaluateWithContext_Satisfied(t *testing.T) {
 		t.Errorf("Expected nil result for satisfied invariant, got violation: %+v", result)
 	}
 }
+
+func TestEvaluateWithContext_UnknownWhenFieldMissing(t *testing.T) {
+	store := state.NewMemoryStore()
+	authorityMap := authority.NewControllerAuthorityMap()
+	eng := NewEvaluationEngine(store, authorityMap)
+
+	inv := dsl.Invariant{
+		ID:      "node_ready",
+		Subject: dsl.Subject{Kind: "Node"},
+		Predicate: &dsl.Predicate{
+			Field:    "status.conditions[Ready].status",
+			Operator: dsl.Equals,
+			Value:    "True",
+		},
+		Severity: dsl.Critical,
+	}
+
+	ctx := types.EvaluationContext{
+		Resource: types.StateEvent{
+			UID:       "node-1",
+			Kind:      "Node",
+			Name:      "node-1",
+			FieldDiff: map[string]interface{}{},
+		},
+		Timestamp: time.Now(),
+	}
+
+	result := eng.EvaluateWithContext(inv, ctx)
+	if result == nil {
+		t.Fatal("Expected an unknown result for a missing field")
+	}
+	if result.Violated {
+		t.Error("Missing field must not be reported as a violation")
+	}
+	if result.Status != StatusUnknown {
+		t.Errorf("Expected status unknown, got %s", result.Status)
+	}
+
+	stats := eng.GetEvaluationStats()
+	if stats["unknown_found"] != 1 {
+		t.Errorf("Expected 1 unknown evaluation in stats, got %v", stats["unknown_found"])
+	}
+	if stats["violations_found"] != 0 {
+		t.Errorf("Expected 0 violations in stats, got %v", stats["violations_found"])
+	}
+}
+
+func TestEvaluatePredicate_OlderThan(t *testing.T) {
+	store := state.NewMemoryStore()
+	authorityMap := authority.NewControllerAuthorityMap()
+	eng := NewEvaluationEngine(store, authorityMap)
+
+	pred := dsl.Predicate{
+		Field:    dsl.CreationTimestampField,
+		Operator: dsl.OlderThan,
+		Value:    "5m",
+	}
+
+	old := types.StateEvent{
+		UID:               "pod-1",
+		Kind:              "Pod",
+		CreationTimestamp: time.Now().Add(-10 * time.Minute),
+		FieldDiff:         map[string]interface{}{},
+	}
+	if satisfied, reason := eng.evaluatePredicateWithReason(pred, old); !satisfied {
+		t.Errorf("Expected 10m old pod to be older than 5m, got reason: %s", reason)
+	}
+
+	young := types.StateEvent{
+		UID:               "pod-2",
+		Kind:              "Pod",
+		CreationTimestamp: time.Now().Add(-1 * time.Minute),
+		FieldDiff:         map[string]interface{}{},
+	}
+	if satisfied, _ := eng.evaluatePredicateWithReason(pred, young); satisfied {
+		t.Error("Expected 1m old pod not to be older than 5m")
+	}
+
+	// Numeric values are seconds
+	pred.Value = 30
+	if satisfied, _ := eng.evaluatePredicateWithReason(pred, young); !satisfied {
+		t.Error("Expected 1m old pod to be older than 30 seconds")
+	}
+
+	// Without a creation time the result is unknown, not a violation
+	unknownAge := types.StateEvent{UID: "pod-3", Kind: "Pod", FieldDiff: map[string]interface{}{}}
+	if outcome, _ := eng.evaluatePredicateOutcome(pred, unknownAge); outcome != predicateUnknown {
+		t.Errorf("Expected unknown outcome without creation timestamp, got %v", outcome)
+	}
+}
+
+func TestEvaluatePredicate_NewerThan(t *testing.T) {
+	store := state.NewMemoryStore()
+	authorityMap := authority.NewControllerAuthorityMap()
+	eng := NewEvaluationEngine(store, authorityMap)
+
+	// A condition stuck for longer than the threshold violates
+	pred := dsl.Predicate{
+		Field:    "status.conditions[PodScheduled].lastTransitionTime",
+		Operator: dsl.NewerThan,
+		Value:    "5m",
+	}
+	stuck := types.StateEvent{
+		UID: "pod-1", Kind: "Pod",
+		FieldDiff: map[string]interface{}{
+			"status.conditions[PodScheduled].lastTransitionTime": time.Now().Add(-10 * time.Minute).UTC().Format(time.RFC3339),
+		},
+	}
+	if satisfied, _ := eng.evaluatePredicateWithReason(pred, stuck); satisfied {
+		t.Error("Expected a transition 10m ago not to be newer than 5m")
+	}
+
+	recent := types.StateEvent{
+		UID: "pod-2", Kind: "Pod",
+		FieldDiff: map[string]interface{}{
+			"status.conditions[PodScheduled].lastTransitionTime": time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano),
+		},
+	}
+	if satisfied, reason := eng.evaluatePredicateWithReason(pred, recent); !satisfied {
+		t.Errorf("Expected a transition 1m ago to be newer than 5m, got reason: %s", reason)
+	}
+
+	// Days are accepted alongside Go durations
+	pred.Value = "1d"
+	if satisfied, reason := eng.evaluatePredicateWithReason(pred, stuck); !satisfied {
+		t.Errorf("Expected a transition 10m ago to be newer than 1d, got reason: %s", reason)
+	}
+}
+
+func TestEvaluateDependency_Scopes(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewEvaluationEngine(store, authority.NewControllerAuthorityMap())
+	eng.registerInvariant(dsl.Invariant{
+		ID:        "ready",
+		Subject:   dsl.Subject{Kind: "Pod"},
+		Predicate: &dsl.Predicate{Field: "ready", Operator: dsl.Equals, Value: true},
+		Severity:  dsl.Critical,
+	})
+	eng.registerInvariant(dsl.Invariant{
+		ID:        "available",
+		Subject:   dsl.Subject{Kind: "Deployment"},
+		Predicate: &dsl.Predicate{Field: "available", Operator: dsl.Equals, Value: true},
+		Severity:  dsl.Critical,
+	})
+
+	pod := func(uid, namespace string, labels map[string]string, ready bool) types.StateEvent {
+		return types.StateEvent{
+			UID: uid, Kind: "Pod", Name: uid, Namespace: namespace, Labels: labels,
+			FieldDiff: map[string]interface{}{"ready": ready, state.FieldController: "ReplicaSet/web-1"},
+		}
+	}
+	store.Record(pod("web-a", "default", map[string]string{"app": "web"}, true))
+	store.Record(pod("web-b", "default", map[string]string{"app": "web"}, false))
+	store.Record(pod("dns-a", "kube-system", map[string]string{"k8s-app": "dns"}, true))
+	store.Record(types.StateEvent{UID: "rs-1", Kind: "ReplicaSet", Name: "web-1", Namespace: "default",
+		FieldDiff: map[string]interface{}{state.FieldController: "Deployment/web"}})
+	store.Record(types.StateEvent{UID: "deploy-1", Kind: "Deployment", Name: "web", Namespace: "default",
+		FieldDiff: map[string]interface{}{"available": false}})
+	// Owners recorded by UID only, as for an ingested event
+	store.Record(types.StateEvent{UID: "rs-2", Kind: "ReplicaSet", Name: "api-1", Namespace: "default", OwnerUID: "deploy-2"})
+	store.Record(types.StateEvent{UID: "deploy-2", Kind: "Deployment", Name: "api", Namespace: "default",
+		FieldDiff: map[string]interface{}{"available": true}})
+	reowned := pod("api-a", "default", nil, true)
+	reowned.OwnerUID = "rs-2"
+	svc := types.StateEvent{UID: "svc-1", Kind: "Service", Name: "web", Namespace: "default",
+		FieldDiff: map[string]interface{}{"spec.selector": map[string]string{"app": "web"}}}
+
+	tests := []struct {
+		name    string
+		subject types.StateEvent
+		scope   dsl.Scope
+		req     string
+		status  EvaluationStatus
+		reason  string
+	}{
+		{"selector all", svc, dsl.Scope{Relation: dsl.Selector}, "ready", StatusViolated, "Pod default/web-b: "},
+		{"selector any", svc, dsl.Scope{Relation: dsl.Selector, Args: map[string]string{dsl.ArgMatch: dsl.MatchAny}}, "ready", StatusSatisfied, ""},
+		{"selector narrowed", svc, dsl.Scope{Relation: dsl.Selector, Selector: map[string]string{"app": "api"}}, "ready", StatusUnknown, "No Pod related to Service default/web through selector"},
+		{"any same namespace", svc, dsl.Scope{Relation: dsl.Any, Selector: map[string]string{"k8s-app": "dns"}}, "ready", StatusUnknown, "No Pod has been observed"},
+		{"any listed namespace", svc, dsl.Scope{Relation: dsl.Any, Namespaces: []string{"kube-system"}, Selector: map[string]string{"k8s-app": "dns"}}, "ready", StatusSatisfied, ""},
+		{"owner walks to kind", pod("web-c", "default", nil, true), dsl.Scope{Relation: dsl.Owner}, "available", StatusViolated, "Deployment default/web: "},
+		{"owner by uid", reowned, dsl.Scope{Relation: dsl.Owner}, "available", StatusSatisfied, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := eng.evaluateDependency(dsl.Requirement{Invariant: tt.req, Scope: tt.scope}, types.EvaluationContext{Resource: tt.subject, Timestamp: time.Now()})
+			status := StatusSatisfied
+			if result != nil {
+				status = StatusViolated
+				if result.Status == StatusUnknown {
+					status = StatusUnknown
+				}
+			}
+			if status != tt.status {
+				t.Fatalf("Expected %s, got %s: %+v", tt.status, status, result)
+			}
+			if result != nil && !strings.HasPrefix(result.Reason, tt.reason) {
+				t.Errorf("Expected reason starting %q, got %q", tt.reason, result.Reason)
+			}
+		})
+	}
+}
+
+func TestScopeValidation(t *testing.T) {
+	base := dsl.Invariant{ID: "x", Subject: dsl.Subject{Kind: "Pod"}, Severity: dsl.Warning}
+	for _, scope := range []dsl.Scope{
+		{},
+		{Relation: "sibling"},
+		{Relation: dsl.Same, Kind: "Node"},
+		{Relation: dsl.Any, Args: map[string]string{dsl.ArgField: "spec.x"}},
+		{Relation: dsl.Selector, Args: map[string]string{dsl.ArgMatch: "most"}},
+		{Relation: dsl.Selector, Namespace: dsl.NamespaceSame, Namespaces: []string{"a"}},
+	} {
+		inv := base
+		inv.Requires = []dsl.Requirement{{Invariant: "y", Scope: scope}}
+		if err := inv.Validate(); err == nil {
+			t.Errorf("Expected scope %+v to be rejected", scope)
+		}
+	}
+	base.Requires = []dsl.Requirement{{Invariant: "y", Scope: dsl.Scope{Relation: dsl.Node, Args: map[string]string{dsl.ArgField: "spec.targetNode", dsl.ArgMatch: dsl.MatchAny}}}}
+	if err := base.Validate(); err != nil {
+		t.Errorf("Expected node scope with args to validate: %v", err)
+	}
+}
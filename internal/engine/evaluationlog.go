@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"log"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// DefaultEvaluationLogRetention is how long persisted evaluation log
+	// entries are kept
+	DefaultEvaluationLogRetention = 24 * time.Hour
+	// DefaultEvaluationLogLimit is how many entries a query returns unless
+	// it asks otherwise
+	DefaultEvaluationLogLimit = 100
+
+	// maxPendingEntries bounds the entries held between flushes
+	maxPendingEntries = 100000
+)
+
+// EvaluationLogQuery selects evaluation log entries; empty fields match
+// every entry
+type EvaluationLogQuery struct {
+	InvariantID string
+	ResourceUID string
+	Since       time.Time
+	Until       time.Time
+	Limit       int
+}
+
+// Matches reports whether an entry is selected by the query, ignoring Limit
+func (q EvaluationLogQuery) Matches(entry EvaluationLogEntry) bool {
+	if q.InvariantID != "" && entry.InvariantID != q.InvariantID {
+		return false
+	}
+	if q.ResourceUID != "" && entry.ResourceUID != q.ResourceUID {
+		return false
+	}
+	if !q.Since.IsZero() && entry.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && entry.Timestamp.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// EvaluationLogStore persists evaluation log entries so they outlive the
+// in-memory log of the last 1000
+type EvaluationLogStore interface {
+	// RecordEvaluations stores entries and drops those older than retention
+	RecordEvaluations(entries []EvaluationLogEntry, retention time.Duration) error
+	// QueryEvaluations returns the selected entries, newest first
+	QueryEvaluations(q EvaluationLogQuery) ([]EvaluationLogEntry, error)
+}
+
+// SetEvaluationLog persists evaluation log entries to store after every
+// EvaluateAll. Entries that aren't satisfied are always kept; satisfied
+// ones are kept at sampleRate, between 0 and 1.
+func (e *InvariantEngine) SetEvaluationLog(store EvaluationLogStore, sampleRate float64, retention time.Duration) {
+	e.mu.Lock()
+	e.logStore = store
+	e.logRetention = retention
+	e.mu.Unlock()
+
+	e.evalEngine.mu.Lock()
+	defer e.evalEngine.mu.Unlock()
+	e.evalEngine.persistLog = store != nil
+	e.evalEngine.logSample = sampleRate
+	if store == nil {
+		e.evalEngine.pending = nil
+	}
+}
+
+// EvaluationLog returns the selected evaluation log entries, newest first,
+// from the store when one is set and from memory otherwise
+func (e *InvariantEngine) EvaluationLog(q EvaluationLogQuery) ([]EvaluationLogEntry, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultEvaluationLogLimit
+	}
+	e.mu.RLock()
+	store := e.logStore
+	e.mu.RUnlock()
+	if store != nil {
+		e.flushEvaluationLog()
+		return store.QueryEvaluations(q)
+	}
+
+	e.evalEngine.mu.RLock()
+	defer e.evalEngine.mu.RUnlock()
+	entries := make([]EvaluationLogEntry, 0)
+	for i := len(e.evalEngine.evaluationLog) - 1; i >= 0 && len(entries) < q.Limit; i-- {
+		if entry := e.evalEngine.evaluationLog[i]; q.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// flushEvaluationLog writes the pending entries to the store
+func (e *InvariantEngine) flushEvaluationLog() {
+	e.mu.RLock()
+	store, retention := e.logStore, e.logRetention
+	e.mu.RUnlock()
+	if store == nil {
+		return
+	}
+
+	e.evalEngine.mu.Lock()
+	pending := e.evalEngine.pending
+	e.evalEngine.pending = nil
+	e.evalEngine.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	if err := store.RecordEvaluations(pending, retention); err != nil {
+		log.Printf("Failed to persist %d evaluation log entries: %v", len(pending), err)
+	}
+}
+
+// sample queues an entry for the store. Callers hold e.mu.
+func (e *EvaluationEngine) sample(entry EvaluationLogEntry) {
+	if !e.persistLog || len(e.pending) >= maxPendingEntries {
+		return
+	}
+	if entry.Status == StatusSatisfied && rand.Float64() >= e.logSample {
+		return
+	}
+	e.pending = append(e.pending, entry)
+}
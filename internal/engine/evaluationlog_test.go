@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+type recordingLogStore struct {
+	entries []EvaluationLogEntry
+}
+
+func (s *recordingLogStore) RecordEvaluations(entries []EvaluationLogEntry, retention time.Duration) error {
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func (s *recordingLogStore) QueryEvaluations(q EvaluationLogQuery) ([]EvaluationLogEntry, error) {
+	var entries []EvaluationLogEntry
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if q.Matches(s.entries[i]) {
+			entries = append(entries, s.entries[i])
+		}
+	}
+	return entries, nil
+}
+
+func TestEvaluationLog(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	for _, inv := range eng.GetInvariants() {
+		eng.DeleteInvariant(inv.ID)
+	}
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:          "pipeline_succeeds",
+		Version:     1,
+		Subject:     dsl.Subject{Kind: "Pipeline"},
+		Predicate:   &dsl.Predicate{Field: "status", Operator: dsl.Equals, Value: "succeeded"},
+		Severity:    dsl.Warning,
+		Description: "Pipelines succeed",
+	})
+	store.Record(types.StateEvent{UID: "run-1", Kind: "Pipeline", Name: "a", CorrelationID: "req-1", FieldDiff: map[string]interface{}{"status": "failed"}})
+	store.Record(types.StateEvent{UID: "run-2", Kind: "Pipeline", Name: "b", FieldDiff: map[string]interface{}{"status": "succeeded"}})
+
+	// Without a store, queries read the in-memory log
+	eng.EvaluateAll()
+	entries, err := eng.EvaluationLog(EvaluationLogQuery{ResourceUID: "run-1"})
+	if err != nil || len(entries) != 1 || entries[0].Status != StatusViolated || entries[0].CorrelationID != "req-1" {
+		t.Fatalf("Expected the violated evaluation of run-1, got %+v, %v", entries, err)
+	}
+
+	// Satisfied evaluations are sampled out at rate 0; violations are kept
+	logStore := &recordingLogStore{}
+	eng.SetEvaluationLog(logStore, 0, time.Hour)
+	eng.EvaluateAll()
+	if len(logStore.entries) != 1 || logStore.entries[0].ResourceUID != "run-1" {
+		t.Errorf("Expected only the violation to be persisted, got %+v", logStore.entries)
+	}
+	entries, _ = eng.EvaluationLog(EvaluationLogQuery{InvariantID: "pipeline_succeeds"})
+	if len(entries) != 1 {
+		t.Errorf("Expected queries to read the store, got %+v", entries)
+	}
+}
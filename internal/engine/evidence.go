@@ -0,0 +1,30 @@
+package engine
+
+import (
+	"github.com/aonescu/akari/internal/scheduling"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// EvidenceFunc inspects a violating resource and its surroundings and
+// returns findings explaining the violation
+type EvidenceFunc func(resource types.StateEvent, store state.StateStore) []string
+
+// SetEvidenceProvider attaches fn to violations of an invariant, replacing
+// any provider already set. A nil fn removes it.
+func (e *InvariantEngine) SetEvidenceProvider(invariantID string, fn EvidenceFunc) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if fn == nil {
+		delete(e.evidence, invariantID)
+		return
+	}
+	e.evidence[invariantID] = fn
+}
+
+// scheduleEvidence gives the scheduler's own breakdown of why an
+// Unschedulable pod fits no node, then names the nodeSelector, affinity or
+// taint that keeps it off the recorded nodes
+func scheduleEvidence(pod types.StateEvent, store state.StateStore) []string {
+	return scheduling.FailureEvidence(pod, store.GetLatestByKind("Node"))
+}
@@ -0,0 +1,265 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// Explanation is the decision trace of one invariant against one
+// resource: what was read, how each check came out, and who was blamed
+type Explanation struct {
+	InvariantID      string            `json:"invariant_id"`
+	InvariantVersion int               `json:"invariant_version"`
+	ResourceUID      string            `json:"uid"`
+	Resource         string            `json:"resource"`
+	SubjectMatches   bool              `json:"subject_matches"`
+	InGracePeriod    bool              `json:"in_grace_period"`
+	Predicate        *PredicateTrace   `json:"predicate,omitempty"`
+	Dependencies     []DependencyTrace `json:"dependencies,omitempty"`
+	Authority        *AuthorityTrace   `json:"authority,omitempty"`
+	Status           EvaluationStatus  `json:"status"`
+	Reason           string            `json:"reason,omitempty"`
+	ResponsibleActor string            `json:"responsible_actor,omitempty"`
+	EliminatedActors []string          `json:"eliminated_actors,omitempty"`
+}
+
+// PredicateTrace shows the values a predicate compared
+type PredicateTrace struct {
+	Field    string       `json:"field"`
+	Operator dsl.Operator `json:"operator"`
+	Observed bool         `json:"observed"`
+	Value    interface{}  `json:"value,omitempty"`
+	// Expected is the comparison value, resolved from ExpectedFrom when
+	// the predicate reads it from another field
+	Expected     interface{}      `json:"expected,omitempty"`
+	ExpectedFrom string           `json:"expected_from,omitempty"`
+	Outcome      EvaluationStatus `json:"outcome"`
+	Reason       string           `json:"reason,omitempty"`
+}
+
+// DependencyTrace shows how a required invariant came out on the resource
+type DependencyTrace struct {
+	InvariantID string           `json:"invariant_id"`
+	Relation    dsl.Relation     `json:"relation"`
+	Outcome     EvaluationStatus `json:"outcome"`
+	Reason      string           `json:"reason,omitempty"`
+	// Targets lists the related resources the invariant was checked on
+	Targets []string     `json:"targets,omitempty"`
+	Trace   *Explanation `json:"trace,omitempty"`
+}
+
+// AuthorityTrace shows how the responsible actor was chosen. Rule is one of
+// actor_field, sole_authority, primary_authority or primary.
+type AuthorityTrace struct {
+	Field                 string   `json:"field,omitempty"`
+	AuthorizedControllers []string `json:"authorized_controllers"`
+	ActorField            string   `json:"actor_field,omitempty"`
+	Primary               string   `json:"primary"`
+	Rule                  string   `json:"rule"`
+}
+
+// Explain evaluates inv against resource like the monitor does and
+// returns the full decision trace, without logging the evaluation
+func (e *InvariantEngine) Explain(inv dsl.Invariant, resource types.StateEvent) (explanation *Explanation) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	explanation = &Explanation{
+		InvariantID:      inv.ID,
+		InvariantVersion: inv.Version,
+		ResourceUID:      resource.UID,
+		Resource:         fmt.Sprintf("%s/%s", resource.Namespace, resource.Name),
+		SubjectMatches:   SubjectMatches(inv.Subject, resource, e.namespaceLabels(inv.Subject)),
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			explanation.Status = StatusEvaluationError
+			explanation.Reason = fmt.Sprintf("evaluation panicked: %v", r)
+		}
+	}()
+
+	ctx := types.EvaluationContext{
+		Resource:      resource,
+		RelatedStates: make(map[string]types.StateEvent),
+		Timestamp:     time.Now(),
+	}
+	e.evalEngine.explain(inv, ctx, explanation)
+	return explanation
+}
+
+// explain mirrors EvaluateWithContext, recording each step
+func (e *EvaluationEngine) explain(inv dsl.Invariant, ctx types.EvaluationContext, x *Explanation) {
+	x.Status = StatusSatisfied
+	if inWarmup(inv, ctx) {
+		x.InGracePeriod = true
+		x.Reason = "within grace period"
+		return
+	}
+
+	if inv.Predicate != nil {
+		x.Predicate = e.tracePredicate(*inv.Predicate, ctx.Resource)
+		switch x.Predicate.Outcome {
+		case StatusViolated:
+			x.Status = StatusViolated
+			x.Reason = x.Predicate.Reason
+			x.Authority = e.traceAuthority(inv, ctx.Resource)
+			x.ResponsibleActor = e.determineResponsibility(inv, ctx.Resource)
+			x.EliminatedActors = e.eliminateActors(inv.Predicate.Field, x.ResponsibleActor)
+			return
+		case StatusUnknown:
+			x.Status = StatusUnknown
+			x.Reason = x.Predicate.Reason
+		}
+	}
+
+	for _, req := range inv.Requires {
+		dep := DependencyTrace{InvariantID: req.Invariant, Relation: req.Scope.Relation}
+		reqInv, exists := e.invariants[req.Invariant]
+		switch {
+		case !exists:
+			// EvaluateWithContext skips unregistered requirements
+			dep.Outcome = StatusSatisfied
+			dep.Reason = "Required invariant not registered; skipped"
+		default:
+			e.explainDependency(req.Scope, reqInv, ctx, &dep)
+		}
+		x.Dependencies = append(x.Dependencies, dep)
+
+		if dep.Outcome == StatusUnknown && x.Status != StatusViolated && x.Reason == "" {
+			x.Status = StatusUnknown
+			x.Reason = fmt.Sprintf("Dependency %s unknown: %s", dep.InvariantID, dep.Reason)
+		}
+		if dep.Outcome == StatusViolated {
+			x.Status = StatusViolated
+			x.Reason = fmt.Sprintf("Dependency %s failed: %s", dep.InvariantID, dep.Reason)
+			if dep.Trace != nil {
+				x.Authority = dep.Trace.Authority
+				x.ResponsibleActor = dep.Trace.ResponsibleActor
+				x.EliminatedActors = dep.Trace.EliminatedActors
+			}
+			return
+		}
+	}
+}
+
+// tracePredicate evaluates a predicate and records the values it compared
+func (e *EvaluationEngine) tracePredicate(pred dsl.Predicate, subject types.StateEvent) *PredicateTrace {
+	trace := &PredicateTrace{Field: pred.Field, Operator: pred.Operator, Expected: pred.Value}
+	trace.Value, trace.Observed = subject.FieldDiff[pred.Field]
+	if !trace.Observed && pred.Field == dsl.CreationTimestampField && !subject.CreationTimestamp.IsZero() {
+		trace.Value, trace.Observed = subject.CreationTimestamp, true
+	}
+	if pred.ValueFrom != nil {
+		trace.ExpectedFrom = strings.TrimPrefix(valueSource(pred), " from ")
+		if resolved, found, _ := e.resolveValueFrom(*pred.ValueFrom, subject); found {
+			trace.Expected = resolved
+		}
+	}
+
+	outcome, reason := e.evaluatePredicateOutcome(pred, subject)
+	trace.Reason = reason
+	switch outcome {
+	case predicateViolated:
+		trace.Outcome = StatusViolated
+	case predicateUnknown:
+		trace.Outcome = StatusUnknown
+	default:
+		trace.Outcome = StatusSatisfied
+	}
+	return trace
+}
+
+// traceAuthority records which rule of determineResponsibility chose the
+// responsible actor
+func (e *EvaluationEngine) traceAuthority(inv dsl.Invariant, resource types.StateEvent) *AuthorityTrace {
+	trace := &AuthorityTrace{
+		ActorField:            inv.Responsibility.ActorField,
+		Primary:               inv.Responsibility.Primary,
+		AuthorizedControllers: []string{},
+		Rule:                  "primary",
+	}
+	if inv.Predicate != nil {
+		trace.Field = inv.Predicate.Field
+		trace.AuthorizedControllers = append(trace.AuthorizedControllers, e.authorityMap.GetAuthorizedControllers(inv.Predicate.Field)...)
+	}
+
+	if field := inv.Responsibility.ActorField; field != "" {
+		if actor, ok := resource.FieldDiff[field].(string); ok && actor != "" {
+			trace.Rule = "actor_field"
+			return trace
+		}
+	}
+	if len(trace.AuthorizedControllers) == 1 {
+		trace.Rule = "sole_authority"
+		return trace
+	}
+	for _, controller := range trace.AuthorizedControllers {
+		if controller == inv.Responsibility.Primary {
+			trace.Rule = "primary_authority"
+			break
+		}
+	}
+	return trace
+}
+
+// explainDependency mirrors evaluateDependency, keeping the trace of the
+// related resource that decided the outcome
+func (e *EvaluationEngine) explainDependency(scope dsl.Scope, reqInv dsl.Invariant, ctx types.EvaluationContext, dep *DependencyTrace) {
+	targets := e.dependencyTargets(scope, reqInv.Subject.Kind, ctx.Resource)
+	if len(targets) == 0 {
+		dep.Outcome = StatusUnknown
+		dep.Reason = noTargetsReason(scope, reqInv, ctx.Resource)
+		return
+	}
+
+	var first, decided, unknown *Explanation
+	for _, target := range targets {
+		targetCtx := ctx
+		targetCtx.Resource = target
+		trace := &Explanation{
+			InvariantID:      reqInv.ID,
+			InvariantVersion: reqInv.Version,
+			ResourceUID:      target.UID,
+			Resource:         fmt.Sprintf("%s/%s", target.Namespace, target.Name),
+			SubjectMatches:   true,
+		}
+		e.explain(reqInv, targetCtx, trace)
+		dep.Targets = append(dep.Targets, target.Kind+" "+resourceName(target))
+		if first == nil {
+			first = trace
+		}
+		if trace.Status != StatusSatisfied {
+			trace.Reason = fmt.Sprintf("%s %s: %s", target.Kind, resourceName(target), trace.Reason)
+		}
+
+		switch trace.Status {
+		case StatusSatisfied:
+			if scope.Match() == dsl.MatchAny && decided == nil {
+				decided = trace
+			}
+		case StatusUnknown:
+			if unknown == nil {
+				unknown = trace
+			}
+		default:
+			if scope.Match() == dsl.MatchAll && decided == nil {
+				decided = trace
+			}
+		}
+	}
+	switch {
+	case decided != nil:
+		dep.Trace = decided
+	case unknown != nil:
+		dep.Trace = unknown
+	default:
+		// Every resource came out the same, satisfied under match all or
+		// violated under match any; the first stands for them
+		dep.Trace = first
+	}
+	dep.Outcome, dep.Reason = dep.Trace.Status, dep.Trace.Reason
+}
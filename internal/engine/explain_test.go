@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// Explain must reach the same decision as evaluation, for every outcome
+func TestExplain_MatchesEvaluation(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	pods := []types.StateEvent{
+		{UID: "pod-unscheduled", Kind: "Pod", Namespace: "default", Name: "a", FieldDiff: map[string]interface{}{"spec.nodeName": ""}},
+		{UID: "pod-unobserved", Kind: "Pod", Namespace: "default", Name: "b", FieldDiff: map[string]interface{}{}},
+		{UID: "pod-ready", Kind: "Pod", Namespace: "default", Name: "c", FieldDiff: map[string]interface{}{
+			"spec.nodeName":                             "node-1",
+			"status.conditions[Ready].status":           "True",
+			"status.containerStatuses[*].state.running": true,
+		}},
+	}
+	for _, pod := range pods {
+		store.Record(pod)
+	}
+
+	for _, id := range []string{"pod_scheduled", "pod_ready"} {
+		inv, _ := eng.GetInvariantByID(id)
+		results := make(map[string]*ViolationResult)
+		for _, r := range eng.Evaluate(inv) {
+			results[r.ResourceUID] = r
+		}
+		for _, pod := range pods {
+			x := eng.Explain(inv, pod)
+			want, reason := StatusSatisfied, ""
+			if r := results[pod.UID]; r != nil {
+				want, reason = r.Status, r.Reason
+			}
+			if x.Status != want || x.Reason != reason {
+				t.Errorf("%s on %s: explained %s (%q), evaluated %s (%q)", id, pod.UID, x.Status, x.Reason, want, reason)
+			}
+		}
+	}
+
+	inv, _ := eng.GetInvariantByID("pod_scheduled")
+	x := eng.Explain(inv, pods[1])
+	if x.Predicate == nil || x.Predicate.Observed || x.Status != StatusViolated {
+		t.Errorf("Expected the missing nodeName in the trace, got %+v", x.Predicate)
+	}
+	if x.Authority == nil || x.ResponsibleActor != "kube-scheduler" {
+		t.Errorf("Expected kube-scheduler to be chosen, got %q from %+v", x.ResponsibleActor, x.Authority)
+	}
+
+	inv, _ = eng.GetInvariantByID("pod_ready")
+	x = eng.Explain(inv, pods[2])
+	if len(x.Dependencies) == 0 || x.Dependencies[0].Trace == nil || x.Dependencies[0].Outcome != StatusSatisfied {
+		t.Errorf("Expected the satisfied containers_running dependency in the trace, got %+v", x.Dependencies)
+	}
+}
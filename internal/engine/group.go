@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aonescu/akari/internal/dsl"
+)
+
+// GroupBy is a key violation lists are grouped by
+type GroupBy string
+
+const (
+	GroupResource  GroupBy = "resource"
+	GroupInvariant GroupBy = "invariant"
+	GroupActor     GroupBy = "actor"
+	// GroupNamespace keys cluster-scoped resources by the empty namespace
+	GroupNamespace GroupBy = "namespace"
+)
+
+// ViolationGroup is the violations sharing a key. Nested groupings split
+// the violations into Groups; the innermost lists them.
+type ViolationGroup struct {
+	Key        string               `json:"key"`
+	Count      int                  `json:"count"`
+	BySeverity map[dsl.Severity]int `json:"by_severity"`
+	Groups     []ViolationGroup     `json:"groups,omitempty"`
+	Violations []*ViolationResult   `json:"violations,omitempty"`
+}
+
+// ParseGroupBy accepts the group_by parameter of violation lists, a comma
+// separated list of keys, outermost first. An empty parameter doesn't group.
+func ParseGroupBy(s string) ([]GroupBy, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var keys []GroupBy
+	for _, part := range strings.Split(s, ",") {
+		switch key := GroupBy(strings.TrimSpace(part)); key {
+		case GroupResource, GroupInvariant, GroupActor, GroupNamespace:
+			for _, k := range keys {
+				if k == key {
+					return nil, fmt.Errorf("group_by repeats %s", key)
+				}
+			}
+			keys = append(keys, key)
+		default:
+			return nil, fmt.Errorf("group_by must be a comma separated list of resource, invariant, actor, namespace")
+		}
+	}
+	return keys, nil
+}
+
+// GroupViolations groups violations by the first key, each group by the
+// next, and so on. Groups are largest first; violations keep their order,
+// as do groups of the same size.
+func GroupViolations(violations []*ViolationResult, by []GroupBy) []ViolationGroup {
+	if len(by) == 0 {
+		return nil
+	}
+	groups := make([]ViolationGroup, 0)
+	index := make(map[string]int)
+	for _, v := range violations {
+		key := groupKey(v, by[0])
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, ViolationGroup{Key: key, BySeverity: make(map[dsl.Severity]int)})
+		}
+		g := &groups[i]
+		g.Count++
+		g.BySeverity[v.Severity]++
+		g.Violations = append(g.Violations, v)
+	}
+	for i := range groups {
+		if len(by) > 1 {
+			groups[i].Groups = GroupViolations(groups[i].Violations, by[1:])
+			groups[i].Violations = nil
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Count > groups[j].Count })
+	return groups
+}
+
+func groupKey(v *ViolationResult, by GroupBy) string {
+	switch by {
+	case GroupResource:
+		return v.AffectedResource
+	case GroupInvariant:
+		return v.InvariantID
+	case GroupActor:
+		return v.ResponsibleActor
+	case GroupNamespace:
+		if namespace, _, ok := strings.Cut(v.AffectedResource, "/"); ok && namespace != "*" {
+			return namespace
+		}
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/aonescu/akari/internal/dsl"
+)
+
+func TestGroupViolations(t *testing.T) {
+	violations := []*ViolationResult{
+		{InvariantID: "pod_ready", AffectedResource: "shop/web", ResponsibleActor: "kubelet", Severity: dsl.Critical},
+		{InvariantID: "node_ready", AffectedResource: "/node-1", ResponsibleActor: "node-controller", Severity: dsl.Critical},
+		{InvariantID: "pod_ready", AffectedResource: "shop/api", ResponsibleActor: "kubelet", Severity: dsl.Critical},
+		{InvariantID: "no_oom_killed", AffectedResource: "shop/api", ResponsibleActor: "kubelet", Severity: dsl.Warning},
+	}
+
+	by, err := ParseGroupBy("namespace, invariant")
+	if err != nil {
+		t.Fatalf("ParseGroupBy failed: %v", err)
+	}
+	groups := GroupViolations(violations, by)
+	if len(groups) != 2 || groups[0].Key != "shop" || groups[0].Count != 3 || groups[1].Key != "" {
+		t.Fatalf("Expected shop then the cluster-scoped group, got %+v", groups)
+	}
+	shop := groups[0]
+	if shop.BySeverity[dsl.Critical] != 2 || shop.BySeverity[dsl.Warning] != 1 || shop.Violations != nil {
+		t.Errorf("Expected severity counts and no violations on the outer group, got %+v", shop)
+	}
+	if len(shop.Groups) != 2 || shop.Groups[0].Key != "pod_ready" || len(shop.Groups[0].Violations) != 2 || shop.Groups[1].Key != "no_oom_killed" {
+		t.Errorf("Expected pod_ready then no_oom_killed within shop, got %+v", shop.Groups)
+	}
+
+	byResource := GroupViolations(violations, []GroupBy{GroupResource})
+	if byResource[0].Key != "shop/api" || byResource[0].Count != 2 || byResource[1].Key != "shop/web" {
+		t.Errorf("Expected shop/api first, then the rest in order, got %+v", byResource)
+	}
+	if byActor := GroupViolations(violations, []GroupBy{GroupActor}); len(byActor) != 2 || byActor[0].Count != 3 {
+		t.Errorf("Expected kubelet's three violations first, got %+v", byActor)
+	}
+}
+
+func TestParseGroupBy(t *testing.T) {
+	if by, err := ParseGroupBy(""); err != nil || by != nil {
+		t.Errorf("Expected no grouping, got %v, %v", by, err)
+	}
+	for _, bad := range []string{"team", "actor,actor", "resource,"} {
+		if _, err := ParseGroupBy(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
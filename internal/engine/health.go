@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/aonescu/akari/internal/criticality"
+)
+
+// HealthScore is the tier-weighted share of resources without violations:
+// a broken tier-1 service costs ten times as much as a tier-3 canary
+type HealthScore struct {
+	Score       float64            `json:"score"`
+	Resources   int                `json:"resources"`
+	Violating   int                `json:"violating"`
+	ByNamespace map[string]float64 `json:"by_namespace"`
+	ByTier      map[string]int     `json:"violating_by_tier"`
+}
+
+type weightTotals struct {
+	total, violating float64
+}
+
+func (w weightTotals) score() float64 {
+	if w.total == 0 {
+		return 100
+	}
+	return 100 * (1 - w.violating/w.total)
+}
+
+// HealthScore weighs results against every resource subject to an invariant
+func (e *InvariantEngine) HealthScore(results []*ViolationResult) HealthScore {
+	violating := make(map[string]bool)
+	for _, r := range FilterByStatus(results, StatusViolated) {
+		violating[r.ResourceUID] = true
+	}
+
+	health := HealthScore{
+		ByNamespace: make(map[string]float64),
+		ByTier:      make(map[string]int),
+	}
+	var cluster weightTotals
+	namespaces := make(map[string]*weightTotals)
+
+	for _, kind := range e.evalEngine.SubjectKinds() {
+		for _, resource := range e.store.GetLatestByKind(kind) {
+			tier := e.tiers.Resolve(resource)
+			weight := tier.Weight()
+
+			ns := namespaces[resource.Namespace]
+			if ns == nil {
+				ns = &weightTotals{}
+				namespaces[resource.Namespace] = ns
+			}
+			cluster.total += weight
+			ns.total += weight
+			health.Resources++
+
+			if violating[resource.UID] {
+				cluster.violating += weight
+				ns.violating += weight
+				health.Violating++
+				health.ByTier[string(tier)]++
+			}
+		}
+	}
+
+	health.Score = cluster.score()
+	for name, totals := range namespaces {
+		health.ByNamespace[name] = totals.score()
+	}
+	return health
+}
+
+// RankByImpact orders results by impact, most severe on the most critical
+// resources first
+func RankByImpact(results []*ViolationResult) []*ViolationResult {
+	ranked := make([]*ViolationResult, len(results))
+	copy(ranked, results)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Impact > ranked[j].Impact
+	})
+	return ranked
+}
+
+// weighTier recomputes tier and impact for results loaded without them
+func (e *InvariantEngine) weighTier(result *ViolationResult) {
+	if result.ResourceUID == "" {
+		return
+	}
+	if resource, exists := e.store.GetByUID(result.ResourceUID); exists {
+		e.weigh(result, resource)
+	} else if result.Tier != "" {
+		result.Impact = criticality.Impact(result.Severity, result.Tier)
+	}
+}
+
+// Weigh fills in tier and impact on results, e.g. those read from history
+func (e *InvariantEngine) Weigh(results []*ViolationResult) {
+	for _, r := range results {
+		if r != nil {
+			e.weighTier(r)
+		}
+	}
+}
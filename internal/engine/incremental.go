@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"maps"
+	"reflect"
+	"sort"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+// EventEvaluation is what EvaluateEvent re-checked and what it found
+type EventEvaluation struct {
+	// Evaluated holds the fingerprints of the invariant/resource pairs
+	// re-checked; those without a result are satisfied
+	Evaluated []string
+	// ChangedFields are the fields that differ from the state last seen
+	// for the resource, nil when it was evaluated in full
+	ChangedFields []string
+	Results       []*ViolationResult
+}
+
+// fieldIndex maps field paths to the invariants whose predicates read them
+type fieldIndex struct {
+	// direct maps subject kind, then field, to invariants reading the
+	// field on the subject itself
+	direct map[string]map[string][]string
+	// remote maps a field to invariants reading it on an owner or node
+	// through value_from, whatever its kind
+	remote map[string][]string
+	// requiredBy maps an invariant to those requiring it
+	requiredBy map[string][]requirer
+}
+
+type requirer struct {
+	id string
+	// same requirements are checked on the requirer's own subject
+	same bool
+}
+
+// seenState is what EvaluateEvent last saw of a resource
+type seenState struct {
+	namespace string
+	owner     string
+	labels    map[string]string
+	fields    map[string]interface{}
+}
+
+// buildFieldIndex indexes the registered invariants. Callers must hold
+// e.mu.
+func (e *EvaluationEngine) buildFieldIndex() *fieldIndex {
+	idx := &fieldIndex{
+		direct:     make(map[string]map[string][]string),
+		remote:     make(map[string][]string),
+		requiredBy: make(map[string][]requirer),
+	}
+	for id, inv := range e.invariants {
+		if pred := inv.Predicate; pred != nil {
+			idx.addDirect(inv.Subject.Kind, pred.Field, id)
+			if ref := pred.ValueFrom; ref != nil {
+				if ref.Relation == "" || ref.Relation == dsl.Same {
+					idx.addDirect(inv.Subject.Kind, ref.Field, id)
+				} else {
+					idx.remote[ref.Field] = append(idx.remote[ref.Field], id)
+				}
+			}
+		}
+		for _, req := range inv.Requires {
+			idx.requiredBy[req.Invariant] = append(idx.requiredBy[req.Invariant], requirer{id: id, same: req.Scope.Relation == dsl.Same})
+		}
+	}
+	return idx
+}
+
+func (idx *fieldIndex) addDirect(kind, field, id string) {
+	if idx.direct[kind] == nil {
+		idx.direct[kind] = make(map[string][]string)
+	}
+	idx.direct[kind][field] = append(idx.direct[kind][field], id)
+}
+
+// fields returns the index, rebuilding it after invariants changed
+func (e *EvaluationEngine) fields() *fieldIndex {
+	e.mu.RLock()
+	idx := e.index
+	e.mu.RUnlock()
+	if idx != nil {
+		return idx
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.index == nil {
+		e.index = e.buildFieldIndex()
+	}
+	return e.index
+}
+
+// EvaluateEvent re-evaluates only the invariant/resource pairs a recorded
+// event can change: the invariants whose predicates read a changed field
+// of the resource, and through requires those depending on them, on the
+// same resource or, for related resources, on all their subjects. A
+// resource seen for the first time, or whose labels or namespace changed,
+// is evaluated against every invariant of its kind. Call it once the
+// event is recorded, so related resources read the new state. Time-based
+// predicates and coverage findings still need EvaluateAll.
+//
+// Results are returned to the caller only; no Monitor transition follows
+// from them. Embedders reach it through akari.Engine.RecordAndEvaluate,
+// while the server finds transitions in its periodic full passes, where
+// floods and registry outages are collapsed.
+func (e *InvariantEngine) EvaluateEvent(event types.StateEvent) EventEvaluation {
+	defer e.flushEvaluationLog()
+	changed, full := e.observe(event)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	idx := e.evalEngine.fields()
+
+	// Invariants to re-check on the event's resource, and those whose
+	// every subject needs re-checking because a related resource changed
+	onResource := make(map[string]bool)
+	everywhere := make(map[string]bool)
+	if full {
+		for _, inv := range e.evalEngine.InvariantsForKind(event.Kind) {
+			onResource[inv.ID] = true
+		}
+		// A new or relabelled resource can join another's selector or any
+		// requirement
+		for _, inv := range e.invariants {
+			for _, req := range inv.Requires {
+				if req.Scope.Relation != dsl.Same {
+					everywhere[inv.ID] = true
+				}
+			}
+		}
+	}
+	for _, field := range changed {
+		for _, id := range idx.direct[event.Kind][field] {
+			onResource[id] = true
+		}
+		for _, id := range idx.remote[field] {
+			everywhere[id] = true
+		}
+	}
+	propagate(idx, onResource, everywhere)
+
+	result := EventEvaluation{ChangedFields: changed}
+	ids := make([]string, 0, len(onResource)+len(everywhere))
+	for id := range onResource {
+		ids = append(ids, id)
+	}
+	for id := range everywhere {
+		if !onResource[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		inv, exists := e.invariants[id]
+		if !exists {
+			continue
+		}
+		var subjects []types.StateEvent
+		if everywhere[id] {
+			for _, subject := range e.store.GetLatestByKind(inv.Subject.Kind) {
+				if subject.UID != event.UID {
+					subjects = append(subjects, subject)
+				}
+			}
+		}
+		if inv.Subject.Kind == event.Kind {
+			subjects = append(subjects, event)
+		}
+		namespaceLabels := e.namespaceLabels(inv.Subject)
+		for _, subject := range subjects {
+			if SubjectMatches(inv.Subject, subject, namespaceLabels) {
+				result.Evaluated = append(result.Evaluated, inv.ID+"|"+subject.Namespace+"/"+subject.Name)
+			}
+		}
+		result.Results = append(result.Results, e.evaluateIsolated(inv, subjects)...)
+	}
+	return result
+}
+
+// propagate adds the invariants requiring those already selected: on the
+// same resource through the same relation, on every subject otherwise
+func propagate(idx *fieldIndex, onResource, everywhere map[string]bool) {
+	queue := make([]string, 0, len(onResource)+len(everywhere))
+	for id := range onResource {
+		queue = append(queue, id)
+	}
+	for id := range everywhere {
+		queue = append(queue, id)
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, r := range idx.requiredBy[id] {
+			if r.same && !everywhere[id] {
+				if !onResource[r.id] {
+					onResource[r.id] = true
+					queue = append(queue, r.id)
+				}
+				continue
+			}
+			if !everywhere[r.id] {
+				everywhere[r.id] = true
+				queue = append(queue, r.id)
+			}
+		}
+	}
+}
+
+// observe remembers the event's state and returns the fields that changed
+// since the last one seen for its UID. full is set when the resource is
+// new, moved, relabelled or reowned, so every invariant of its kind applies.
+// A resource being deleted is forgotten, so churned UIDs do not accumulate.
+func (e *InvariantEngine) observe(event types.StateEvent) (changed []string, full bool) {
+	e.seenMu.Lock()
+	defer e.seenMu.Unlock()
+
+	last, seen := e.seen[event.UID]
+	if _, deleting := event.FieldDiff[watcher.FieldDeletionTimestamp]; deleting {
+		delete(e.seen, event.UID)
+	} else {
+		e.seen[event.UID] = seenState{namespace: event.Namespace, owner: event.OwnerUID, labels: maps.Clone(event.Labels), fields: maps.Clone(event.FieldDiff)}
+	}
+	if !seen || last.namespace != event.Namespace || last.owner != event.OwnerUID || !maps.Equal(last.labels, event.Labels) {
+		return nil, true
+	}
+	for field, value := range event.FieldDiff {
+		if previous, ok := last.fields[field]; !ok || !reflect.DeepEqual(previous, value) {
+			changed = append(changed, field)
+		}
+	}
+	for field := range last.fields {
+		if _, ok := event.FieldDiff[field]; !ok {
+			changed = append(changed, field)
+		}
+	}
+	sort.Strings(changed)
+	return changed, false
+}
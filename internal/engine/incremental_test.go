@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+func TestEvaluateEvent_OnlyAffectedPairs(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	for _, inv := range []dsl.Invariant{
+		{ID: "widget_ready", Subject: dsl.Subject{Kind: "Pod"}, Severity: dsl.Critical,
+			Predicate: &dsl.Predicate{Field: "x.ready", Operator: dsl.Equals, Value: true}},
+		{ID: "widget_counted", Subject: dsl.Subject{Kind: "Pod"}, Severity: dsl.Warning,
+			Predicate: &dsl.Predicate{Field: "x.count", Operator: dsl.Exists}},
+		{ID: "widget_serving", Subject: dsl.Subject{Kind: "Pod"}, Severity: dsl.Critical,
+			Requires: []dsl.Requirement{{Invariant: "widget_ready", Scope: dsl.Scope{Relation: dsl.Same}}}},
+		{ID: "fleet_ready", Subject: dsl.Subject{Kind: "Fleet"}, Severity: dsl.Critical,
+			Requires: []dsl.Requirement{{Invariant: "widget_ready", Scope: dsl.Scope{Relation: dsl.Any, Namespace: dsl.NamespaceAny}}}},
+	} {
+		eng.UpsertInvariant(inv)
+	}
+
+	store.Record(types.StateEvent{UID: "fleet-1", Kind: "Fleet", Name: "fleet", Timestamp: time.Now()})
+	pod := types.StateEvent{UID: "pod-1", Kind: "Pod", Name: "w", Namespace: "default", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"x.ready": true, "x.count": 1}}
+	store.Record(pod)
+	first := eng.EvaluateEvent(pod)
+	if first.ChangedFields != nil || !slices.Contains(first.Evaluated, "widget_counted|default/w") {
+		t.Fatalf("Expected a new resource evaluated in full, got %+v", first)
+	}
+
+	pod.Version = "2"
+	pod.FieldDiff = map[string]interface{}{"x.ready": false, "x.count": 1}
+	store.Record(pod)
+	got := eng.EvaluateEvent(pod)
+	if !slices.Equal(got.ChangedFields, []string{"x.ready"}) {
+		t.Errorf("Expected x.ready changed, got %v", got.ChangedFields)
+	}
+	want := []string{"fleet_ready|/fleet", "widget_ready|default/w", "widget_serving|default/w"}
+	if evaluated := slices.Sorted(slices.Values(got.Evaluated)); !slices.Equal(evaluated, want) {
+		t.Errorf("Expected %v re-evaluated, got %v", want, evaluated)
+	}
+	violated := make(map[string]bool)
+	for _, r := range FilterByStatus(got.Results, StatusViolated) {
+		violated[r.InvariantID] = true
+	}
+	if len(violated) != 3 {
+		t.Errorf("Expected the readiness failure to reach every dependent, got %v", violated)
+	}
+
+	pod.Version = "3"
+	pod.FieldDiff = map[string]interface{}{"x.ready": false, "x.count": 2}
+	store.Record(pod)
+	if got := eng.EvaluateEvent(pod); !slices.Equal(got.Evaluated, []string{"widget_counted|default/w"}) {
+		t.Errorf("Expected only widget_counted re-evaluated, got %v", got.Evaluated)
+	}
+
+	if got := eng.EvaluateEvent(pod); len(got.Evaluated) != 0 || len(got.Results) != 0 {
+		t.Errorf("Expected an unchanged event to evaluate nothing, got %+v", got)
+	}
+}
+
+func TestEvaluateEvent_ForgetsDeletedResources(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	eng.UpsertInvariant(dsl.Invariant{ID: "widget_ready", Subject: dsl.Subject{Kind: "Pod"}, Severity: dsl.Critical,
+		Predicate: &dsl.Predicate{Field: "x.ready", Operator: dsl.Equals, Value: true}})
+
+	pod := types.StateEvent{UID: "pod-1", Kind: "Pod", Name: "w", Namespace: "default", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"x.ready": true}}
+	store.Record(pod)
+	eng.EvaluateEvent(pod)
+
+	pod.Version = "2"
+	pod.FieldDiff = map[string]interface{}{"x.ready": true, watcher.FieldDeletionTimestamp: time.Now().UTC().Format(time.RFC3339)}
+	store.Record(pod)
+	if got := eng.EvaluateEvent(pod); !slices.Equal(got.ChangedFields, []string{watcher.FieldDeletionTimestamp}) {
+		t.Errorf("Expected the deletion diffed against the last state, got %v", got.ChangedFields)
+	}
+
+	eng.seenMu.Lock()
+	_, remembered := eng.seen["pod-1"]
+	eng.seenMu.Unlock()
+	if remembered {
+		t.Error("Expected a deleted resource to be forgotten")
+	}
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// DefaultEvaluationTimeout bounds how long a single invariant may spend
+// evaluating all of its subjects when the invariant sets no timeout itself.
+// It is checked between subjects, so it can't interrupt one that stalls.
+const DefaultEvaluationTimeout = 5 * time.Second
+
+// EvaluationError records the most recent failure to evaluate an invariant
+type EvaluationError struct {
+	InvariantID      string    `json:"invariant_id"`
+	AffectedResource string    `json:"affected_resource,omitempty"`
+	Reason           string    `json:"reason"`
+	Count            int       `json:"count"`
+	FirstSeen        time.Time `json:"first_seen"`
+	LastSeen         time.Time `json:"last_seen"`
+}
+
+// SetEvaluationTimeout changes the default per-invariant timeout
+func (e *InvariantEngine) SetEvaluationTimeout(timeout time.Duration) {
+	e.errorsMu.Lock()
+	defer e.errorsMu.Unlock()
+	e.evaluationTimeout = timeout
+}
+
+// GetEvaluationErrors returns the invariants whose last evaluation failed
+func (e *InvariantEngine) GetEvaluationErrors() []EvaluationError {
+	e.errorsMu.RLock()
+	defer e.errorsMu.RUnlock()
+
+	errors := make([]EvaluationError, 0, len(e.evaluationErrors))
+	for _, evalErr := range e.evaluationErrors {
+		errors = append(errors, evalErr)
+	}
+	sort.Slice(errors, func(i, j int) bool {
+		return errors[i].InvariantID < errors[j].InvariantID
+	})
+	return errors
+}
+
+// evaluateIsolated evaluates inv against subjects so that a predicate that
+// panics can't crash the caller, and one that is slow on many subjects
+// stops at the invariant's timeout. Evaluation stays on the caller's
+// goroutine, under its locks, and nothing preempts it: the timeout only
+// applies between subjects, so a single stalled predicate or store lookup
+// still holds the caller until it returns. The subjects left once the
+// timeout passes are reported as evaluation errors.
+func (e *InvariantEngine) evaluateIsolated(inv dsl.Invariant, subjects []types.StateEvent) []*ViolationResult {
+	results := e.evaluateSubjects(inv, subjects, e.timeoutFor(inv))
+	e.trackErrors(inv.ID, results)
+	return results
+}
+
+// evaluateSubjectRecovering converts a panic while evaluating one subject
+// into an evaluation_error result for that subject
+func (e *InvariantEngine) evaluateSubjectRecovering(inv dsl.Invariant, subject types.StateEvent) (result *ViolationResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered panic evaluating %s on %s (correlation %s): %v", inv.ID, subject.UID, subject.CorrelationID, r)
+			result = e.errorResult(inv, subject, fmt.Sprintf("evaluation panicked: %v", r))
+		}
+		if result != nil {
+			result.CorrelationID = subject.CorrelationID
+		}
+	}()
+	return e.evaluateSubject(inv, subject)
+}
+
+func (e *InvariantEngine) timeoutFor(inv dsl.Invariant) time.Duration {
+	if inv.Timeout > 0 {
+		return time.Duration(inv.Timeout)
+	}
+	e.errorsMu.RLock()
+	defer e.errorsMu.RUnlock()
+	return e.evaluationTimeout
+}
+
+func (e *InvariantEngine) errorResult(inv dsl.Invariant, subject types.StateEvent, reason string) *ViolationResult {
+	result := &ViolationResult{
+		InvariantID:      inv.ID,
+		InvariantVersion: inv.Version,
+		Status:           StatusEvaluationError,
+		Reason:           reason,
+		DetectedAt:       time.Now(),
+		Severity:         inv.Severity,
+		Docs:             inv.Docs,
+		RunbookURL:       inv.RunbookURL,
+	}
+	if subject.UID != "" {
+		result.AffectedResource = fmt.Sprintf("%s/%s", subject.Namespace, subject.Name)
+	}
+	return result
+}
+
+// trackErrors records the first evaluation_error in results for the
+// invariant, or clears its entry when the evaluation succeeded
+func (e *InvariantEngine) trackErrors(invariantID string, results []*ViolationResult) {
+	var failed *ViolationResult
+	for _, result := range results {
+		if result != nil && result.Status == StatusEvaluationError {
+			failed = result
+			break
+		}
+	}
+
+	e.errorsMu.Lock()
+	defer e.errorsMu.Unlock()
+
+	if failed == nil {
+		delete(e.evaluationErrors, invariantID)
+		return
+	}
+
+	now := time.Now()
+	entry, exists := e.evaluationErrors[invariantID]
+	if !exists {
+		entry = EvaluationError{InvariantID: invariantID, FirstSeen: now}
+	}
+	entry.AffectedResource = failed.AffectedResource
+	entry.Reason = failed.Reason
+	entry.Count++
+	entry.LastSeen = now
+	e.evaluationErrors[invariantID] = entry
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestInvariantEngine_PanicIsolation(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	// Comparing a slice with == panics at runtime
+	inv := dsl.Invariant{
+		ID:          "labels_equal",
+		Version:     1,
+		Subject:     dsl.Subject{Kind: "ConfigMap"},
+		Predicate:   &dsl.Predicate{Field: "data.items", Operator: dsl.Equals, Value: []interface{}{"a"}},
+		Severity:    dsl.Warning,
+		Description: "Pathological predicate",
+	}
+
+	store.Record(types.StateEvent{
+		UID:       "cm-1",
+		Kind:      "ConfigMap",
+		Namespace: "default",
+		Name:      "broken",
+		FieldDiff: map[string]interface{}{"data.items": []interface{}{"a"}},
+	})
+	store.Record(types.StateEvent{
+		UID:       "cm-2",
+		Kind:      "ConfigMap",
+		Namespace: "default",
+		Name:      "fine",
+		FieldDiff: map[string]interface{}{},
+	})
+
+	results := eng.Evaluate(inv)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	var errored, unknown int
+	for _, r := range results {
+		switch r.Status {
+		case StatusEvaluationError:
+			errored++
+			if r.Violated {
+				t.Error("Evaluation errors must not be reported as violations")
+			}
+		case StatusUnknown:
+			unknown++
+		}
+	}
+	if errored != 1 || unknown != 1 {
+		t.Errorf("Expected 1 error and 1 unknown result, got %d and %d", errored, unknown)
+	}
+
+	evalErrors := eng.GetEvaluationErrors()
+	if len(evalErrors) != 1 || evalErrors[0].InvariantID != "labels_equal" {
+		t.Fatalf("Expected labels_equal to be reported as erroring, got %+v", evalErrors)
+	}
+
+	// A clean evaluation clears the error
+	store.Record(types.StateEvent{
+		UID:       "cm-1",
+		Kind:      "ConfigMap",
+		Namespace: "default",
+		Name:      "broken",
+		FieldDiff: map[string]interface{}{},
+	})
+	eng.Evaluate(inv)
+	if len(eng.GetEvaluationErrors()) != 0 {
+		t.Error("Expected evaluation error to clear after a successful evaluation")
+	}
+}
+
+func TestInvariantEngine_TimeoutBlamesSubject(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	inv := dsl.Invariant{
+		ID:        "widget_ready",
+		Subject:   dsl.Subject{Kind: "Widget"},
+		Predicate: &dsl.Predicate{Field: "ready", Operator: dsl.Equals, Value: true},
+		Severity:  dsl.Warning,
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		store.Record(types.StateEvent{UID: name, Kind: "Widget", Namespace: "default", Name: name,
+			FieldDiff: map[string]interface{}{"ready": false}})
+	}
+
+	if results := eng.Evaluate(inv); len(results) != 3 {
+		t.Fatalf("Expected every widget evaluated within the timeout, got %+v", results)
+	}
+
+	// The first widget already takes the invariant past its deadline; its
+	// violation is kept and the others time out
+	eng.SetEvaluationTimeout(time.Nanosecond)
+	results := eng.Evaluate(inv)
+	if len(results) != 3 || results[0].Status != StatusViolated || results[0].AffectedResource != "default/a" {
+		t.Fatalf("Expected the first widget's violation kept, got %+v", results)
+	}
+	for _, r := range results[1:] {
+		if r.Status != StatusEvaluationError || !strings.Contains(r.Reason, "timed out") || !strings.Contains(r.Reason, "at default/a") {
+			t.Errorf("Expected the remaining widgets timed out at default/a, got %+v", r)
+		}
+	}
+	if errs := eng.GetEvaluationErrors(); len(errs) != 1 || errs[0].AffectedResource != "default/b" {
+		t.Errorf("Expected the timeout tracked, got %+v", errs)
+	}
+}
@@ -0,0 +1,207 @@
+package engine
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultEvaluationInterval is how often the Monitor re-evaluates all invariants
+const DefaultEvaluationInterval = 30 * time.Second
+
+// TransitionType describes how a violation changed between evaluation passes
+type TransitionType string
+
+const (
+	TransitionOpened   TransitionType = "opened"
+	TransitionResolved TransitionType = "resolved"
+)
+
+// Transition is emitted when a violation appears or disappears
+type Transition struct {
+	Type      TransitionType   `json:"type"`
+	Violation *ViolationResult `json:"violation"`
+	At        time.Time        `json:"at"`
+}
+
+// Fingerprint identifies a violation across evaluation passes
+func (v *ViolationResult) Fingerprint() string {
+	return v.InvariantID + "|" + v.AffectedResource
+}
+
+// TransitionTracker remembers the active violation set between passes
+type TransitionTracker struct {
+	mu     sync.Mutex
+	active map[string]*ViolationResult
+	// restored is set until the first Update after Restore
+	restored bool
+}
+
+func NewTransitionTracker() *TransitionTracker {
+	return &TransitionTracker{
+		active: make(map[string]*ViolationResult),
+	}
+}
+
+// Restore makes violations active as if a previous pass had opened them,
+// so a restarted process doesn't report them as new. The next Update
+// reconciles them: those still violated stay open without a transition,
+// those satisfied resolve, and those the pass can only report unknown or
+// failing to evaluate stay open.
+func (t *TransitionTracker) Restore(violations []*ViolationResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, v := range violations {
+		t.active[v.Fingerprint()] = v
+	}
+	t.restored = true
+}
+
+// Update replaces the active set with violations and returns what opened
+// and resolved since the previous call. Results that are not violations
+// (satisfied, unknown, evaluation errors) are ignored.
+func (t *TransitionTracker) Update(results []*ViolationResult, now time.Time) []Transition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current := make(map[string]*ViolationResult)
+	if t.restored {
+		t.restored = false
+		for _, v := range results {
+			if v.Status != StatusUnknown && v.Status != StatusEvaluationError {
+				continue
+			}
+			if restored, ok := t.active[v.Fingerprint()]; ok {
+				current[v.Fingerprint()] = restored
+			}
+		}
+	}
+	var transitions []Transition
+	for _, v := range FilterByStatus(results, StatusViolated) {
+		key := v.Fingerprint()
+		if _, seen := current[key]; seen {
+			continue
+		}
+		current[key] = v
+		if _, wasActive := t.active[key]; !wasActive {
+			transitions = append(transitions, Transition{Type: TransitionOpened, Violation: v, At: now})
+		}
+	}
+
+	for key, v := range t.active {
+		if _, stillActive := current[key]; !stillActive {
+			transitions = append(transitions, Transition{Type: TransitionResolved, Violation: v, At: now})
+		}
+	}
+
+	t.active = current
+	return transitions
+}
+
+// Active returns the violations that were open after the last Update
+func (t *TransitionTracker) Active() []*ViolationResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	results := make([]*ViolationResult, 0, len(t.active))
+	for _, v := range t.active {
+		results = append(results, v)
+	}
+	return results
+}
+
+// Monitor periodically evaluates every invariant and reports violation
+// transitions to its subscribers
+type Monitor struct {
+	engine   *InvariantEngine
+	interval time.Duration
+	tracker  *TransitionTracker
+
+	mu          sync.RWMutex
+	subscribers []func(Transition)
+	observers   []func([]*ViolationResult, time.Time)
+}
+
+func NewMonitor(eng *InvariantEngine, interval time.Duration) *Monitor {
+	if interval <= 0 {
+		interval = DefaultEvaluationInterval
+	}
+	return &Monitor{
+		engine:   eng,
+		interval: interval,
+		tracker:  NewTransitionTracker(),
+	}
+}
+
+// Restore carries violations that were active before a restart over into
+// the next pass; see TransitionTracker.Restore
+func (m *Monitor) Restore(violations []*ViolationResult) {
+	m.tracker.Restore(violations)
+}
+
+// Subscribe registers fn to receive every transition
+func (m *Monitor) Subscribe(fn func(Transition)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribers = append(m.subscribers, fn)
+}
+
+// OnEvaluation registers fn to receive the full results of every pass
+func (m *Monitor) OnEvaluation(fn func(results []*ViolationResult, at time.Time)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observers = append(m.observers, fn)
+}
+
+// Tick runs a single evaluation pass and dispatches its transitions
+func (m *Monitor) Tick() []Transition {
+	now := time.Now()
+	results := m.engine.EvaluateAll()
+	// Subscribers hear about one unreachable registry, not every pod behind
+	// it, and about one flood rather than every resource it covers
+	transitions := m.tracker.Update(m.engine.CollapseFloods(m.engine.CorrelateRegistries(results)), now)
+
+	opened := make([]*ViolationResult, 0)
+	for _, t := range transitions {
+		if t.Violation.CorrelationID != "" {
+			log.Printf("Violation %s %s on %s (correlation %s)", t.Violation.InvariantID, t.Type, t.Violation.AffectedResource, t.Violation.CorrelationID)
+		}
+		if t.Type == TransitionOpened {
+			opened = append(opened, t.Violation)
+		}
+	}
+	m.engine.AttachSuspects(opened)
+
+	m.mu.RLock()
+	subscribers := m.subscribers
+	observers := m.observers
+	m.mu.RUnlock()
+
+	for _, fn := range observers {
+		fn(results, now)
+	}
+
+	for _, t := range transitions {
+		for _, fn := range subscribers {
+			fn(t)
+		}
+	}
+	return transitions
+}
+
+// Run evaluates on every interval until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Tick()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
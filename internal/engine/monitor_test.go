@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestTransitionTracker_Update(t *testing.T) {
+	tracker := NewTransitionTracker()
+	now := time.Now()
+
+	a := &ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/a", Status: StatusViolated}
+	b := &ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/b", Status: StatusViolated}
+	unknown := &ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/c", Status: StatusUnknown}
+
+	transitions := tracker.Update([]*ViolationResult{a, b, unknown}, now)
+	if len(transitions) != 2 {
+		t.Fatalf("Expected 2 opened transitions, got %d", len(transitions))
+	}
+	for _, tr := range transitions {
+		if tr.Type != TransitionOpened {
+			t.Errorf("Expected opened transition, got %s", tr.Type)
+		}
+	}
+
+	// Same set again: nothing changes
+	if transitions := tracker.Update([]*ViolationResult{a, b}, now); len(transitions) != 0 {
+		t.Errorf("Expected no transitions for unchanged set, got %d", len(transitions))
+	}
+
+	transitions = tracker.Update([]*ViolationResult{a}, now)
+	if len(transitions) != 1 || transitions[0].Type != TransitionResolved || transitions[0].Violation != b {
+		t.Errorf("Expected b to resolve, got %+v", transitions)
+	}
+
+	if active := tracker.Active(); len(active) != 1 {
+		t.Errorf("Expected 1 active violation, got %d", len(active))
+	}
+}
+
+func TestTransitionTracker_Restore(t *testing.T) {
+	tracker := NewTransitionTracker()
+	now := time.Now()
+
+	still := &ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/a", Status: StatusViolated}
+	cleared := &ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/b", Status: StatusViolated}
+	unclear := &ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/c", Status: StatusViolated}
+	tracker.Restore([]*ViolationResult{still, cleared, unclear})
+
+	// The first pass reopens nothing, resolves what cleared and keeps what
+	// it can't evaluate
+	unknown := &ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/c", Status: StatusUnknown}
+	transitions := tracker.Update([]*ViolationResult{still, unknown}, now)
+	if len(transitions) != 1 || transitions[0].Type != TransitionResolved || transitions[0].Violation != cleared {
+		t.Fatalf("Expected only b to resolve, got %+v", transitions)
+	}
+	if active := tracker.Active(); len(active) != 2 {
+		t.Errorf("Expected 2 active violations, got %d", len(active))
+	}
+
+	// Later passes resolve unknown results as usual
+	transitions = tracker.Update([]*ViolationResult{still, unknown}, now)
+	if len(transitions) != 1 || transitions[0].Violation != unclear {
+		t.Errorf("Expected c to resolve, got %+v", transitions)
+	}
+}
+
+func TestMonitor_Tick(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:       "widget_ready",
+		Subject:  dsl.Subject{Kind: "Widget"},
+		Severity: dsl.Warning,
+		Predicate: &dsl.Predicate{
+			Field:    "status.ready",
+			Operator: dsl.Equals,
+			Value:    "True",
+		},
+	})
+	monitor := NewMonitor(eng, time.Minute)
+
+	var received []Transition
+	monitor.Subscribe(func(tr Transition) {
+		if tr.Violation.InvariantID == "widget_ready" {
+			received = append(received, tr)
+		}
+	})
+
+	widget := types.StateEvent{
+		UID:       "widget-1",
+		Kind:      "Widget",
+		Name:      "w",
+		Namespace: "default",
+		Version:   "1",
+		Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"status.ready": "False"},
+	}
+	store.Record(widget)
+
+	monitor.Tick()
+	if len(received) != 1 || received[0].Type != TransitionOpened {
+		t.Fatalf("Expected one opened transition, got %+v", received)
+	}
+
+	// A second pass over the same state reports nothing new
+	received = nil
+	monitor.Tick()
+	if len(received) != 0 {
+		t.Fatalf("Expected no transitions, got %+v", received)
+	}
+
+	widget.Version = "2"
+	widget.FieldDiff = map[string]interface{}{"status.ready": "True"}
+	store.Record(widget)
+
+	monitor.Tick()
+	if len(received) != 1 || received[0].Type != TransitionResolved {
+		t.Errorf("Expected one resolved transition, got %+v", received)
+	}
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aonescu/akari/internal/dsl"
+)
+
+// SortOrder is how violation lists are ordered
+type SortOrder string
+
+const (
+	// SortSeverity puts the most severe first, newest first within a
+	// severity. It is the default.
+	SortSeverity SortOrder = "severity"
+	// SortDetectedAt puts the newest first
+	SortDetectedAt SortOrder = "detected_at"
+	// SortResource orders by affected resource, then invariant
+	SortResource SortOrder = "resource"
+	// SortImpact puts the most severe on the most critical resources first
+	SortImpact SortOrder = "impact"
+)
+
+var severityRanks = map[dsl.Severity]int{
+	dsl.Critical: 0,
+	dsl.Degraded: 1,
+	dsl.Warning:  2,
+}
+
+// SeverityRank orders severities, lowest most severe. Unknown severities
+// rank after warning.
+func SeverityRank(severity dsl.Severity) int {
+	if rank, ok := severityRanks[severity]; ok {
+		return rank
+	}
+	return len(severityRanks)
+}
+
+// ParseSortOrder accepts the sort parameter of list endpoints, defaulting
+// to SortSeverity
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch order := SortOrder(s); order {
+	case "":
+		return SortSeverity, nil
+	case SortSeverity, SortDetectedAt, SortResource, SortImpact:
+		return order, nil
+	default:
+		return "", fmt.Errorf("sort must be one of severity, detected_at, resource, impact")
+	}
+}
+
+// SortViolations orders results in place. SortImpact compares the impact
+// already weighed onto the results.
+func SortViolations(results []*ViolationResult, order SortOrder) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		switch order {
+		case SortDetectedAt:
+			return a.DetectedAt.After(b.DetectedAt)
+		case SortResource:
+			if a.AffectedResource != b.AffectedResource {
+				return a.AffectedResource < b.AffectedResource
+			}
+			return a.InvariantID < b.InvariantID
+		case SortImpact:
+			return a.Impact > b.Impact
+		default:
+			if ra, rb := SeverityRank(a.Severity), SeverityRank(b.Severity); ra != rb {
+				return ra < rb
+			}
+			return a.DetectedAt.After(b.DetectedAt)
+		}
+	})
+}
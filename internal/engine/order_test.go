@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+)
+
+func TestSortViolations(t *testing.T) {
+	now := time.Now()
+	results := func() []*ViolationResult {
+		return []*ViolationResult{
+			{InvariantID: "a", AffectedResource: "shop/web", Severity: dsl.Warning, DetectedAt: now, Impact: 3},
+			{InvariantID: "b", AffectedResource: "shop/api", Severity: dsl.Critical, DetectedAt: now.Add(-time.Hour), Impact: 1},
+			{InvariantID: "c", AffectedResource: "shop/db", Severity: dsl.Critical, DetectedAt: now.Add(-time.Minute), Impact: 30},
+			{InvariantID: "d", AffectedResource: "shop/api", Severity: dsl.Degraded, DetectedAt: now.Add(-2 * time.Hour), Impact: 2},
+		}
+	}
+	ids := func(results []*ViolationResult) string {
+		var s string
+		for _, r := range results {
+			s += r.InvariantID
+		}
+		return s
+	}
+
+	cases := map[string]string{
+		"":            "cbda",
+		"severity":    "cbda",
+		"detected_at": "acbd",
+		"resource":    "bdca",
+		"impact":      "cadb",
+	}
+	for param, want := range cases {
+		order, err := ParseSortOrder(param)
+		if err != nil {
+			t.Fatalf("ParseSortOrder(%q) failed: %v", param, err)
+		}
+		sorted := results()
+		SortViolations(sorted, order)
+		if got := ids(sorted); got != want {
+			t.Errorf("sort=%q: expected %s, got %s", param, want, got)
+		}
+	}
+
+	if _, err := ParseSortOrder("name"); err == nil {
+		t.Error("Expected an unknown sort order to be rejected")
+	}
+}
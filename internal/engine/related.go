@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+// resolveValueFrom reads the field ref points to. The reason explains a
+// value that can't be found.
+func (e *EvaluationEngine) resolveValueFrom(ref dsl.FieldRef, subject types.StateEvent) (interface{}, bool, string) {
+	resource := subject
+	if ref.Relation != "" && ref.Relation != dsl.Same {
+		related, found := e.relatedResource(ref.Relation, subject)
+		if !found {
+			return nil, false, fmt.Sprintf("No %s of %s/%s has been observed", ref.Relation, subject.Namespace, subject.Name)
+		}
+		resource = related
+	}
+
+	value, exists := resource.FieldDiff[ref.Field]
+	if !exists {
+		return nil, false, fmt.Sprintf("Field %s of %s %s has not been observed", ref.Field, resource.Kind, resource.Name)
+	}
+	if ref.Percent == 0 {
+		return value, true, ""
+	}
+	// Scale at evaluation time so the threshold follows the field
+	num, ok := toNumber(value)
+	if !ok {
+		return nil, false, fmt.Sprintf("Field %s of %s %s is not numeric: %v", ref.Field, resource.Kind, resource.Name, value)
+	}
+	return num * ref.Percent / 100, true, ""
+}
+
+// relatedResource finds the resource subject relates to: its controller
+// for owner, standing in the Deployment for an unrecorded ReplicaSet, or
+// the node it runs on
+func (e *EvaluationEngine) relatedResource(relation dsl.Relation, subject types.StateEvent) (types.StateEvent, bool) {
+	switch relation {
+	case dsl.Owner:
+		return e.controllerOf(subject, "")
+	case dsl.Node:
+		if nodeName, _ := subject.FieldDiff[watcher.FieldNodeName].(string); nodeName != "" {
+			return e.findResource("Node", "", nodeName)
+		}
+	}
+	return types.StateEvent{}, false
+}
+
+// maxOwnerDepth bounds the controllers walked up for an owner requirement
+const maxOwnerDepth = 3
+
+// controllerOf finds the controller named by field, the controller owner
+// reference when empty, standing in the Deployment for an unrecorded
+// ReplicaSet. The owner reference is followed by UID when the subject
+// carries one, by kind and name otherwise.
+func (e *EvaluationEngine) controllerOf(subject types.StateEvent, field string) (types.StateEvent, bool) {
+	if field == "" {
+		if subject.OwnerUID != "" {
+			if owner, found := e.store.GetByUID(subject.OwnerUID); found {
+				return owner, true
+			}
+		}
+		field = state.FieldController
+	}
+	ref, _ := subject.FieldDiff[field].(string)
+	kind, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		return types.StateEvent{}, false
+	}
+	if owner, found := e.findResource(kind, subject.Namespace, name); found {
+		return owner, true
+	}
+	if w, ok := WorkloadOf(subject); ok && kind == "ReplicaSet" {
+		return e.findResource(w.Kind, w.Namespace, w.Name)
+	}
+	return types.StateEvent{}, false
+}
+
+func (e *EvaluationEngine) findResource(kind, namespace, name string) (types.StateEvent, bool) {
+	for _, resource := range e.store.GetLatestByKind(kind) {
+		if resource.Namespace == namespace && resource.Name == name {
+			return resource, true
+		}
+	}
+	return types.StateEvent{}, false
+}
+
+// dependencyTargets finds the resources a requirement of subject is checked
+// on. Kind defaults to the subject kind of the required invariant.
+func (e *EvaluationEngine) dependencyTargets(scope dsl.Scope, kind string, subject types.StateEvent) []types.StateEvent {
+	if scope.Kind != "" {
+		kind = scope.Kind
+	}
+	field := scope.Args[dsl.ArgField]
+
+	var candidates []types.StateEvent
+	switch scope.Relation {
+	case dsl.Same:
+		return []types.StateEvent{subject}
+	case dsl.Owner:
+		// Walk up the controllers to the target kind, e.g. from a pod
+		// through its ReplicaSet to the Deployment
+		resource := subject
+		for range maxOwnerDepth {
+			owner, found := e.controllerOf(resource, field)
+			if !found {
+				break
+			}
+			if owner.Kind == kind {
+				candidates = append(candidates, owner)
+				break
+			}
+			resource, field = owner, ""
+		}
+	case dsl.Node:
+		if field == "" {
+			field = watcher.FieldNodeName
+		}
+		if nodeName, _ := subject.FieldDiff[field].(string); nodeName != "" {
+			if node, found := e.findResource("Node", "", nodeName); found {
+				candidates = append(candidates, node)
+			}
+		}
+	case dsl.Selector:
+		if field == "" {
+			field = watcher.FieldSelector
+		}
+		// A selector without labels selects nothing, as for Services
+		selector := watcher.Selector(map[string]interface{}{watcher.FieldSelector: subject.FieldDiff[field]})
+		for _, resource := range state.GetBySelector(e.store, kind, selector) {
+			if resource.UID != subject.UID {
+				candidates = append(candidates, resource)
+			}
+		}
+	case dsl.Any:
+		for _, resource := range e.store.GetLatestByKind(kind) {
+			if resource.UID != subject.UID {
+				candidates = append(candidates, resource)
+			}
+		}
+	}
+
+	targets := candidates[:0]
+	for _, resource := range candidates {
+		if resource.Kind == kind && scope.InNamespace(resource.Namespace, subject.Namespace) && state.MatchesSelector(scope.Selector, resource.Labels) {
+			targets = append(targets, resource)
+		}
+	}
+	return targets
+}
+
+// onTarget names the related resource in the reason of its result
+func onTarget(result *ViolationResult, target types.StateEvent) *ViolationResult {
+	result.Reason = fmt.Sprintf("%s %s: %s", target.Kind, resourceName(target), result.Reason)
+	return result
+}
+
+// noTargetsReason explains a requirement whose related resources haven't
+// been observed
+func noTargetsReason(scope dsl.Scope, reqInv dsl.Invariant, subject types.StateEvent) string {
+	kind := reqInv.Subject.Kind
+	if scope.Kind != "" {
+		kind = scope.Kind
+	}
+	if scope.Relation == dsl.Any {
+		return fmt.Sprintf("No %s has been observed", kind)
+	}
+	return fmt.Sprintf("No %s related to %s %s through %s has been observed", kind, subject.Kind, resourceName(subject), scope.Relation)
+}
+
+func resourceName(resource types.StateEvent) string {
+	if resource.Namespace == "" {
+		return resource.Name
+	}
+	return resource.Namespace + "/" + resource.Name
+}
@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// EvaluateSnapshot evaluates the current invariant definitions against a
+// past state of the cluster, such as one returned by
+// state.SnapshotReader.SnapshotAt. The live store is not touched.
+func (e *InvariantEngine) EvaluateSnapshot(events []types.StateEvent) []*ViolationResult {
+	return e.snapshotEngine(events).EvaluateAll()
+}
+
+// snapshotEngine copies the engine's invariants onto a store holding only
+// events
+func (e *InvariantEngine) snapshotEngine(events []types.StateEvent) *InvariantEngine {
+	snapshot := state.NewMemoryStore()
+	for _, event := range events {
+		snapshot.Record(event)
+	}
+
+	e.mu.RLock()
+	evalEngine := &EvaluationEngine{
+		invariants:    make(map[string]dsl.Invariant, len(e.invariants)),
+		byKind:        make(map[string][]string),
+		store:         snapshot,
+		authorityMap:  e.evalEngine.authorityMap,
+		evaluationLog: make([]EvaluationLogEntry, 0),
+	}
+	for _, inv := range e.invariants {
+		evalEngine.registerInvariant(inv)
+	}
+	evidence := make(map[string]EvidenceFunc, len(e.evidence))
+	for id, fn := range e.evidence {
+		evidence[id] = fn
+	}
+	clone := &InvariantEngine{
+		invariants: evalEngine.invariants,
+		versions:   make(map[string][]dsl.Invariant),
+		store:      snapshot,
+		evalEngine: evalEngine,
+
+		evaluationTimeout: e.evaluationTimeout,
+		evaluationErrors:  make(map[string]EvaluationError),
+
+		tiers: e.tiers,
+
+		registryThreshold: e.registryThreshold,
+		evidence:          evidence,
+		suspectWindow:     e.suspectWindow,
+	}
+	e.mu.RUnlock()
+	return clone
+}
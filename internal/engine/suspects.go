@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/changes"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+const (
+	// DefaultSuspectWindow is how far before a violation changes are
+	// considered suspects
+	DefaultSuspectWindow = 15 * time.Minute
+
+	maxSuspects = 10
+)
+
+// Relations of a suspect change to the violating resource
+const (
+	SuspectSelf     = "self"
+	SuspectNode     = "node"
+	SuspectWorkload = "workload"
+	// SuspectDeploy is a CI/CD deploy that shipped an image the resource
+	// runs
+	SuspectDeploy = "deploy"
+)
+
+// SuspectChange is a recorded change to the violating resource or a
+// resource it depends on that happened shortly before the violation
+type SuspectChange struct {
+	ResourceUID string                 `json:"resource_uid"`
+	Kind        string                 `json:"kind"`
+	Namespace   string                 `json:"namespace,omitempty"`
+	Name        string                 `json:"name"`
+	Relation    string                 `json:"relation"`
+	Actor       string                 `json:"actor"`
+	ChangedAt   time.Time              `json:"changed_at"`
+	Fields      map[string]interface{} `json:"fields"`
+	// Relevance ranks the change: touching the invariant's field counts
+	// most, then being made by an actor with authority over it, then being
+	// a change to the violating resource itself or the deploy of its image
+	Relevance int `json:"relevance"`
+}
+
+// SetSuspectWindow sets how far before a violation AttachSuspects looks
+// for changes. Zero disables suspect changes.
+func (e *InvariantEngine) SetSuspectWindow(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.suspectWindow = d
+}
+
+// AttachSuspects fills SuspectChanges of every violation with the changes
+// recorded to its resource, the resource's node and the workloads
+// selecting it during the suspect window, most relevant first. It does
+// nothing for stores that don't keep history.
+func (e *InvariantEngine) AttachSuspects(results []*ViolationResult) {
+	reader, ok := e.store.(state.SnapshotReader)
+	e.mu.RLock()
+	window := e.suspectWindow
+	e.mu.RUnlock()
+	if !ok || window <= 0 {
+		return
+	}
+
+	for _, result := range results {
+		if result == nil || !result.Violated || result.ResourceUID == "" || result.SuspectChanges != nil {
+			continue
+		}
+		suspects, err := e.suspects(reader, result, window)
+		if err != nil {
+			log.Printf("Failed to load suspect changes for %s: %v", result.AffectedResource, err)
+			continue
+		}
+		result.SuspectChanges = suspects
+	}
+}
+
+func (e *InvariantEngine) suspects(reader state.SnapshotReader, result *ViolationResult, window time.Duration) ([]SuspectChange, error) {
+	subject, ok := e.store.GetByUID(result.ResourceUID)
+	if !ok {
+		return nil, nil
+	}
+	related := e.relatedResources(subject)
+
+	to := result.DetectedAt
+	if to.IsZero() {
+		to = time.Now()
+	}
+	suspects, err := changesBetween(reader, to.Add(-window), to, related)
+	if err != nil {
+		return nil, err
+	}
+
+	var field string
+	e.mu.RLock()
+	if inv, ok := e.invariants[result.InvariantID]; ok && inv.Predicate != nil {
+		field = inv.Predicate.Field
+	}
+	e.mu.RUnlock()
+	for i := range suspects {
+		suspects[i].Relevance = e.relevance(suspects[i], field, result.ResponsibleActor)
+	}
+
+	sort.SliceStable(suspects, func(i, j int) bool {
+		if suspects[i].Relevance != suspects[j].Relevance {
+			return suspects[i].Relevance > suspects[j].Relevance
+		}
+		return suspects[i].ChangedAt.After(suspects[j].ChangedAt)
+	})
+	if len(suspects) > maxSuspects {
+		suspects = suspects[:maxSuspects]
+	}
+	return suspects, nil
+}
+
+// Changes returns the changes recorded between from and to to a violation's
+// resource and the resources related to it, oldest first. It returns nil
+// for stores that don't keep history.
+func (e *InvariantEngine) Changes(result *ViolationResult, from, to time.Time) ([]SuspectChange, error) {
+	reader, ok := e.store.(state.SnapshotReader)
+	if !ok || result.ResourceUID == "" {
+		return nil, nil
+	}
+	subject, ok := e.store.GetByUID(result.ResourceUID)
+	if !ok {
+		return nil, nil
+	}
+	return changesBetween(reader, from, to, e.relatedResources(subject))
+}
+
+// changesBetween diffs every event recorded to the related UIDs between
+// from and to against the state before it, oldest first
+func changesBetween(reader state.SnapshotReader, from, to time.Time, related map[string]string) ([]SuspectChange, error) {
+	uids := make([]string, 0, len(related))
+	for uid := range related {
+		uids = append(uids, uid)
+	}
+	baseline, err := reader.SnapshotAt(from, uids...)
+	if err != nil {
+		return nil, err
+	}
+	events, err := reader.EventsBetween(from, to, uids...)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make(map[string]map[string]interface{}, len(baseline))
+	for _, event := range baseline {
+		previous[event.UID] = event.FieldDiff
+	}
+
+	changes := make([]SuspectChange, 0)
+	for _, event := range events {
+		diff := state.DiffFields(previous[event.UID], event.FieldDiff)
+		previous[event.UID] = event.FieldDiff
+		if len(diff) == 0 {
+			continue
+		}
+		changes = append(changes, SuspectChange{
+			ResourceUID: event.UID,
+			Kind:        event.Kind,
+			Namespace:   event.Namespace,
+			Name:        event.Name,
+			Relation:    related[event.UID],
+			Actor:       event.Actor,
+			ChangedAt:   event.Timestamp,
+			Fields:      diff,
+		})
+	}
+	return changes, nil
+}
+
+// relatedResources maps the UIDs worth inspecting for a violation of
+// subject to their relation: the subject, the node a pod runs on, the
+// Deployments whose selector matches it and the deploys of its images
+func (e *InvariantEngine) relatedResources(subject types.StateEvent) map[string]string {
+	related := map[string]string{subject.UID: SuspectSelf}
+
+	if nodeName, _ := subject.FieldDiff["spec.nodeName"].(string); nodeName != "" {
+		for _, node := range e.store.GetLatestByKind("Node") {
+			if node.Name == nodeName {
+				related[node.UID] = SuspectNode
+			}
+		}
+	}
+
+	if len(subject.Labels) > 0 {
+		for _, d := range e.store.GetLatestByKind("Deployment") {
+			selector := watcher.Selector(d.FieldDiff)
+			if d.Namespace == subject.Namespace && len(selector) > 0 && state.MatchesSelector(selector, subject.Labels) {
+				related[d.UID] = SuspectWorkload
+			}
+		}
+	}
+
+	for _, deploy := range changes.ForResource(e.store, subject) {
+		related[deploy.UID] = SuspectDeploy
+	}
+	return related
+}
+
+func (e *InvariantEngine) relevance(suspect SuspectChange, field, responsible string) int {
+	score := 0
+	if field != "" {
+		for changed := range suspect.Fields {
+			if strings.HasPrefix(changed, field) || strings.HasPrefix(field, changed) {
+				score += 4
+				break
+			}
+		}
+	}
+	if suspect.Actor != "" && (suspect.Actor == responsible ||
+		(field != "" && e.evalEngine.authorityMap.ValidateAuthority(suspect.Actor, field))) {
+		score += 2
+	}
+	if suspect.Relation == SuspectSelf || suspect.Relation == SuspectDeploy {
+		score++
+	}
+	return score
+}
@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/changes"
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestInvariantEngine_AttachSuspects(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:             "web_ready",
+		Subject:        dsl.Subject{Kind: "Pod"},
+		Severity:       dsl.Critical,
+		Predicate:      &dsl.Predicate{Field: "status.conditions[Ready].status", Operator: dsl.Equals, Value: "True"},
+		Responsibility: dsl.Responsibility{Primary: "kubelet"},
+	})
+
+	now := time.Now()
+	labels := map[string]string{"app": "web"}
+	pod := func(version string, at time.Time, ready string) types.StateEvent {
+		return types.StateEvent{
+			UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web-1", Labels: labels,
+			Version: version, Timestamp: at, Actor: "kubelet",
+			FieldDiff: map[string]interface{}{
+				"spec.nodeName":                   "worker-1",
+				"status.conditions[Ready].status": ready,
+			},
+		}
+	}
+	deployment := func(version string, at time.Time, image string) types.StateEvent {
+		return types.StateEvent{
+			UID: "deploy-1", Kind: "Deployment", Namespace: "default", Name: "web",
+			Version: version, Timestamp: at, Actor: "alice",
+			FieldDiff: map[string]interface{}{
+				"spec.selector": map[string]string{"app": "web"},
+				"spec.template.spec.containers[app].image": image,
+			},
+		}
+	}
+
+	store.Record(pod("1", now.Add(-time.Hour), "True"))
+	store.Record(deployment("1", now.Add(-time.Hour), "web:1"))
+	store.Record(types.StateEvent{UID: "node-1", Kind: "Node", Name: "worker-1", Version: "1", Timestamp: now.Add(-time.Hour)})
+	store.Record(types.StateEvent{UID: "node-2", Kind: "Node", Name: "worker-2", Version: "1", Timestamp: now.Add(-time.Hour)})
+	// Outside the window: not a suspect
+	store.Record(deployment("2", now.Add(-30*time.Minute), "web:2"))
+	// Inside the window
+	store.Record(deployment("3", now.Add(-5*time.Minute), "web:3"))
+	store.Record(types.StateEvent{UID: "node-2", Kind: "Node", Name: "worker-2", Version: "2", Timestamp: now.Add(-4 * time.Minute), Actor: "bob",
+		FieldDiff: map[string]interface{}{"spec.unschedulable": true}})
+	store.Record(pod("2", now.Add(-time.Minute), "False"))
+	// A deploy of another service is no suspect
+	store.Record(changes.Deploy{Source: changes.SourceCI, Repository: "acme/db", Commit: "abc", Image: "db:9", At: now.Add(-2 * time.Minute)}.Event())
+
+	results := []*ViolationResult{{
+		InvariantID: "web_ready", Violated: true, Status: StatusViolated,
+		ResourceUID: "pod-1", AffectedResource: "default/web-1", ResponsibleActor: "kubelet", DetectedAt: now,
+	}}
+	eng.AttachSuspects(results)
+
+	suspects := results[0].SuspectChanges
+	if len(suspects) != 2 {
+		t.Fatalf("Expected the pod and deployment changes as suspects, got %+v", suspects)
+	}
+	if suspects[0].ResourceUID != "pod-1" || suspects[0].Relation != SuspectSelf {
+		t.Errorf("Expected the Ready change to rank first, got %+v", suspects[0])
+	}
+	if suspects[1].ResourceUID != "deploy-1" || suspects[1].Relation != SuspectWorkload || suspects[1].Actor != "alice" {
+		t.Errorf("Expected alice's rollout second, got %+v", suspects[1])
+	}
+	if suspects[1].Fields["spec.template.spec.containers[app].image"] != "web:3" || len(suspects[1].Fields) != 1 {
+		t.Errorf("Expected only the image change of the rollout, got %v", suspects[1].Fields)
+	}
+	if suspects[0].Relevance <= suspects[1].Relevance {
+		t.Errorf("Expected the change to the violated field to be more relevant, got %d and %d", suspects[0].Relevance, suspects[1].Relevance)
+	}
+
+	eng.SetSuspectWindow(0)
+	results[0].SuspectChanges = nil
+	eng.AttachSuspects(results)
+	if results[0].SuspectChanges != nil {
+		t.Error("Expected a zero window to disable suspect changes")
+	}
+}
+
+func TestInvariantEngine_SuspectDeploys(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	now := time.Now()
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "api-1", Version: "1", Timestamp: now.Add(-time.Hour),
+		FieldDiff: map[string]interface{}{"spec.containers[api].image": "acme/api:2"},
+	})
+	store.Record(changes.Deploy{
+		Source: changes.SourceGitHub, Repository: "acme/api", Commit: "9f2c1e0", Image: "acme/api:2",
+		Actor: "alice", At: now.Add(-3 * time.Minute),
+	}.Event())
+
+	results := []*ViolationResult{{
+		InvariantID: "containers_running", Violated: true, Status: StatusViolated,
+		ResourceUID: "pod-1", AffectedResource: "default/api-1", DetectedAt: now,
+	}}
+	eng.AttachSuspects(results)
+
+	suspects := results[0].SuspectChanges
+	if len(suspects) != 1 || suspects[0].Relation != SuspectDeploy || suspects[0].Actor != "alice" {
+		t.Fatalf("Expected alice's deploy as the suspect, got %+v", suspects)
+	}
+	if suspects[0].Fields[changes.FieldCommit] != "9f2c1e0" {
+		t.Errorf("Expected the suspect to name the commit, got %v", suspects[0].Fields)
+	}
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+// workloadKinds are the controllers pod violations roll up to
+var workloadKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"DaemonSet":   true,
+}
+
+// Workload identifies the controller running a set of pods
+type Workload struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+func (w Workload) String() string {
+	return w.Kind + " " + w.Namespace + "/" + w.Name
+}
+
+// WorkloadOf returns the Deployment, StatefulSet or DaemonSet controlling
+// pod. A ReplicaSet stands in for the Deployment it was created by, which
+// is named after it minus the pod-template-hash suffix.
+func WorkloadOf(pod types.StateEvent) (Workload, bool) {
+	ref, _ := pod.FieldDiff[state.FieldController].(string)
+	kind, name, ok := strings.Cut(ref, "/")
+	if !ok || pod.Kind != "Pod" {
+		return Workload{}, false
+	}
+	if kind == "ReplicaSet" {
+		hash := pod.Labels["pod-template-hash"]
+		deployment, found := strings.CutSuffix(name, "-"+hash)
+		if hash == "" || !found {
+			return Workload{}, false
+		}
+		kind, name = "Deployment", deployment
+	}
+	if !workloadKinds[kind] {
+		return Workload{}, false
+	}
+	return Workload{Kind: kind, Namespace: pod.Namespace, Name: name}, true
+}
+
+// AggregateWorkloads replaces the pod violations of each workload with one
+// violation per invariant, e.g. "3/5 replicas of Deployment shop/api fail
+// pod_ready", listing the member pods as Correlated. Violations of pods
+// without a workload and of other kinds pass through.
+func (e *InvariantEngine) AggregateWorkloads(results []*ViolationResult) []*ViolationResult {
+	// workload -> its current pods, counted as the replicas
+	replicas := make(map[Workload]int)
+	workloadUIDs := make(map[Workload]string)
+	for _, pod := range e.store.GetLatestByKind("Pod") {
+		if _, deleting := pod.FieldDiff[watcher.FieldDeletionTimestamp]; deleting {
+			continue
+		}
+		if w, ok := WorkloadOf(pod); ok {
+			replicas[w]++
+		}
+	}
+	for kind := range workloadKinds {
+		for _, event := range e.store.GetLatestByKind(kind) {
+			workloadUIDs[Workload{Kind: kind, Namespace: event.Namespace, Name: event.Name}] = event.UID
+		}
+	}
+
+	type group struct {
+		workload Workload
+		summary  *ViolationResult
+		pods     map[string]bool
+	}
+	groups := make(map[string]*group)
+	var order []string
+	aggregated := make([]*ViolationResult, 0, len(results))
+	for _, r := range results {
+		if r == nil || !r.Violated || r.ResourceUID == "" {
+			aggregated = append(aggregated, r)
+			continue
+		}
+		pod, exists := e.store.GetByUID(r.ResourceUID)
+		w, ok := WorkloadOf(pod)
+		if !exists || !ok {
+			aggregated = append(aggregated, r)
+			continue
+		}
+
+		key := r.InvariantID + "|" + w.String()
+		g, seen := groups[key]
+		if !seen {
+			g = &group{workload: w, summary: workloadViolation(r, w, workloadUIDs[w]), pods: make(map[string]bool)}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.pods[r.ResourceUID] = true
+		if r.Impact > g.summary.Impact {
+			g.summary.Severity = r.Severity
+		}
+		g.summary.absorb(r)
+	}
+
+	for _, key := range order {
+		g := groups[key]
+		total := max(replicas[g.workload], len(g.pods))
+		sort.Strings(g.summary.Correlated)
+		g.summary.Reason = fmt.Sprintf("%d/%d replicas of %s fail %s", len(g.pods), total, g.workload, g.summary.InvariantID)
+		aggregated = append(aggregated, g.summary)
+	}
+	return aggregated
+}
+
+func workloadViolation(member *ViolationResult, w Workload, uid string) *ViolationResult {
+	if uid == "" {
+		uid = "workload:" + w.Kind + "/" + w.Namespace + "/" + w.Name
+	}
+	return &ViolationResult{
+		InvariantID:      member.InvariantID,
+		InvariantVersion: member.InvariantVersion,
+		Violated:         true,
+		Status:           StatusViolated,
+		ResponsibleActor: member.ResponsibleActor,
+		EliminatedActors: member.EliminatedActors,
+		AffectedResource: w.Namespace + "/" + w.Name,
+		ResourceUID:      uid,
+		Severity:         member.Severity,
+		Docs:             member.Docs,
+		RunbookURL:       member.RunbookURL,
+		Correlated:       make([]string, 0),
+		LogicalResource:  w.Kind + "/" + w.Namespace + "/" + w.Name,
+	}
+}
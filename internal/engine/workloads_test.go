@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func recordReplica(store state.StateStore, uid, name, controller string) {
+	store.Record(types.StateEvent{
+		UID: uid, Kind: "Pod", Namespace: "shop", Name: name, Version: "1", Timestamp: time.Now(),
+		Labels:    map[string]string{"pod-template-hash": "7f9c6d"},
+		FieldDiff: map[string]interface{}{state.FieldController: controller},
+	})
+}
+
+func TestWorkloadOf(t *testing.T) {
+	pod := types.StateEvent{
+		Kind: "Pod", Namespace: "shop", Name: "api-7f9c6d-x2k4p",
+		Labels:    map[string]string{"pod-template-hash": "7f9c6d"},
+		FieldDiff: map[string]interface{}{state.FieldController: "ReplicaSet/api-7f9c6d"},
+	}
+	if w, ok := WorkloadOf(pod); !ok || w != (Workload{Kind: "Deployment", Namespace: "shop", Name: "api"}) {
+		t.Errorf("Expected Deployment shop/api, got %+v", w)
+	}
+
+	pod.FieldDiff[state.FieldController] = "Job/migrate"
+	if _, ok := WorkloadOf(pod); ok {
+		t.Error("Expected Job pods not to roll up")
+	}
+}
+
+func TestAggregateWorkloads(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := NewInvariantEngine(store)
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		recordReplica(store, "api-"+name, "api-7f9c6d-"+name, "ReplicaSet/api-7f9c6d")
+	}
+	recordReplica(store, "db-0", "db-0", "StatefulSet/db")
+	store.Record(types.StateEvent{UID: "svc-1", Kind: "Service", Namespace: "shop", Name: "api", Version: "1", Timestamp: time.Now()})
+
+	violation := func(uid, name string, severity dsl.Severity, impact float64) *ViolationResult {
+		return &ViolationResult{
+			InvariantID: "pod_ready", Violated: true, Status: StatusViolated, ResourceUID: uid,
+			AffectedResource: "shop/" + name, Severity: severity, Impact: impact, DetectedAt: time.Now(),
+		}
+	}
+	results := []*ViolationResult{
+		violation("api-a", "api-7f9c6d-a", dsl.Warning, 1),
+		violation("api-b", "api-7f9c6d-b", dsl.Critical, 3),
+		violation("api-c", "api-7f9c6d-c", dsl.Warning, 1),
+		violation("db-0", "db-0", dsl.Warning, 1),
+		violation("svc-1", "api", dsl.Warning, 1),
+	}
+
+	aggregated := eng.AggregateWorkloads(results)
+	if len(aggregated) != 3 {
+		t.Fatalf("Expected the service plus two workload violations, got %d", len(aggregated))
+	}
+	if aggregated[0].ResourceUID != "svc-1" {
+		t.Errorf("Expected the service violation to pass through, got %+v", aggregated[0])
+	}
+
+	api := aggregated[1]
+	if api.Reason != "3/5 replicas of Deployment shop/api fail pod_ready" {
+		t.Errorf("Unexpected reason %q", api.Reason)
+	}
+	if api.Severity != dsl.Critical || api.LogicalResource != "Deployment/shop/api" {
+		t.Errorf("Expected the most severe member and the Deployment's identity, got %+v", api)
+	}
+	if len(api.Correlated) != 3 || api.Correlated[0] != "shop/api-7f9c6d-a" {
+		t.Errorf("Expected the failing pods as members, got %v", api.Correlated)
+	}
+	if aggregated[2].Reason != "1/1 replicas of StatefulSet shop/db fail pod_ready" {
+		t.Errorf("Unexpected reason %q", aggregated[2].Reason)
+	}
+}
@@ -23,6 +23,7 @@ func GenerateSummary(violations []*engine.ViolationResult) map[string]interface{
 		"total":       len(violations),
 		"violated":    0,
 		"satisfied":   0,
+		"unknown":     0,
 		"critical":    0,
 		"responsible": make(map[string]int),
 	}
@@ -36,6 +37,8 @@ func GenerateSummary(violations []*engine.ViolationResult) map[string]interface{
 
 			responsible := summary["responsible"].(map[string]int)
 			responsible[v.ResponsibleActor]++
+		} else if v.Status == engine.StatusUnknown {
+			summary["unknown"] = summary["unknown"].(int) + 1
 		} else {
 			summary["satisfied"] = summary["satisfied"].(int) + 1
 		}
@@ -54,7 +57,11 @@ func FormatExplanation(violation *engine.ViolationResult) string {
 
 	output.WriteString("CAUSE\n")
 	output.WriteString("────────────────────────\n")
-	output.WriteString(fmt.Sprintf("%s\n\n", violation.Reason))
+	output.WriteString(fmt.Sprintf("%s\n", violation.Reason))
+	for _, finding := range violation.Evidence {
+		output.WriteString(fmt.Sprintf("- %s\n", finding))
+	}
+	output.WriteString("\n")
 
 	output.WriteString("RESPONSIBILITY\n")
 	output.WriteString("────────────────────────\n")
@@ -72,6 +79,12 @@ func FormatExplanation(violation *engine.ViolationResult) string {
 	output.WriteString("NEXT ACTION\n")
 	output.WriteString("────────────────────────\n")
 	output.WriteString(fmt.Sprintf("Inspect %s and related components\n", violation.ResponsibleActor))
+	if violation.RunbookURL != "" {
+		output.WriteString(fmt.Sprintf("Runbook: %s\n", violation.RunbookURL))
+	}
+	if violation.Docs != "" {
+		output.WriteString(fmt.Sprintf("%s\n", violation.Docs))
+	}
 
 	return output.String()
 }
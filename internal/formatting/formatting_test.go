@@ -62,6 +62,32 @@ func TestFormatExplanation(t *testing.T) {
 	}
 }
 
+func TestFormatExplanation_Runbook(t *testing.T) {
+	violation := &engine.ViolationResult{
+		InvariantID:      "node_ready",
+		Violated:         true,
+		ResponsibleActor: "node-controller",
+		Severity:         dsl.Critical,
+		RunbookURL:       "https://wiki.example.com/runbooks/node-not-ready",
+		Docs:             "Check kubelet logs on the node before cordoning it.",
+	}
+
+	explanation := FormatExplanation(violation)
+	nextAction := explanation[strings.Index(explanation, "NEXT ACTION"):]
+
+	if !strings.Contains(nextAction, "Runbook: https://wiki.example.com/runbooks/node-not-ready") {
+		t.Error("Expected runbook link in NEXT ACTION section")
+	}
+	if !strings.Contains(nextAction, "Check kubelet logs") {
+		t.Error("Expected docs in NEXT ACTION section")
+	}
+
+	violation.RunbookURL = ""
+	if strings.Contains(FormatExplanation(violation), "Runbook:") {
+		t.Error("Expected no runbook line without a runbook URL")
+	}
+}
+
 func TestFormatMultipleExplanations(t *testing.T) {
 	violations := []*engine.ViolationResult{
 		{
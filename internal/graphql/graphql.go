@@ -0,0 +1,474 @@
+// Package graphql executes read-only GraphQL queries against a schema of Go
+// resolvers. It covers what dashboard queries need: nested selections,
+// aliases, arguments, variables and fragments. Mutations, subscriptions,
+// directives and introspection beyond __typename are not supported.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// MaxDepth bounds the nesting of a query's selections
+const MaxDepth = 12
+
+// Type is an output or argument type: a *Scalar, an *Object, a List or a
+// NonNull
+type Type interface {
+	String() string
+}
+
+// Scalar values are returned as resolved and must encode to JSON
+type Scalar struct {
+	Name string
+}
+
+func (s *Scalar) String() string { return s.Name }
+
+// Built-in scalars. JSON passes any value through, e.g. a resource's
+// fields.
+var (
+	String  = &Scalar{Name: "String"}
+	Int     = &Scalar{Name: "Int"}
+	Float   = &Scalar{Name: "Float"}
+	Boolean = &Scalar{Name: "Boolean"}
+	ID      = &Scalar{Name: "ID"}
+	JSON    = &Scalar{Name: "JSON"}
+)
+
+// Object is a type with fields. Fields may be added after creation, so
+// object types can refer to each other.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+func (o *Object) String() string { return o.Name }
+
+// List wraps a type whose values are slices
+type List struct {
+	Of Type
+}
+
+func (l List) String() string { return "[" + l.Of.String() + "]" }
+
+// NonNull marks a required argument
+type NonNull struct {
+	Of Type
+}
+
+func (n NonNull) String() string { return n.Of.String() + "!" }
+
+// Field is one field of an object type. Resolve computes its value from the
+// parent's; without it, the field is read from a map[string]interface{}
+// parent by name.
+type Field struct {
+	Type    Type
+	Args    map[string]Type
+	Resolve ResolveFunc
+}
+
+// ResolveFunc computes a field value. Args holds every declared argument
+// that was given, coerced to string, int, float64, bool or a slice of them.
+type ResolveFunc func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error)
+
+// Schema is the root of the queries a server answers
+type Schema struct {
+	Query *Object
+}
+
+// Request is a GraphQL request as POSTed or passed as query parameters
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response carries the data and the errors met producing it. Fields that
+// failed are null in the data.
+type Response struct {
+	Data   interface{} `json:"data"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+// Error is a query or field error. Path locates failed fields.
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Execute parses and runs a query. Syntax and validation errors leave the
+// data null.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return &Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	if op.kind != "query" {
+		return &Response{Errors: []Error{{Message: op.kind + " operations are not supported"}}}
+	}
+	variables, err := op.coerceVariables(req.Variables)
+	if err != nil {
+		return &Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	ex := &executor{ctx: ctx, doc: doc, variables: variables}
+	data := ex.selectionSet(s.Query, nil, op.selections, nil, 0)
+	return &Response{Data: data, Errors: ex.errors}
+}
+
+// operation picks the operation to run: the named one, or the only one
+func (d *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for a document with %d operations", len(d.operations))
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// coerceVariables applies defaults and checks required variables
+func (op *operation) coerceVariables(given map[string]interface{}) (map[string]interface{}, error) {
+	variables := make(map[string]interface{}, len(op.variables))
+	for _, def := range op.variables {
+		v, ok := given[def.name]
+		if !ok || v == nil {
+			if def.defaultVal != nil {
+				v, ok = def.defaultVal, true
+			}
+		}
+		if (!ok || v == nil) && strings.HasSuffix(def.typ, "!") {
+			return nil, fmt.Errorf("variable $%s of type %s is required", def.name, def.typ)
+		}
+		if ok {
+			variables[def.name] = v
+		}
+	}
+	return variables, nil
+}
+
+type executor struct {
+	ctx       context.Context
+	doc       *document
+	variables map[string]interface{}
+	errors    []Error
+}
+
+func (ex *executor) fail(path []interface{}, format string, args ...interface{}) {
+	ex.errors = append(ex.errors, Error{Message: fmt.Sprintf(format, args...), Path: append([]interface{}(nil), path...)})
+}
+
+// selectionSet resolves the selected fields of an object
+func (ex *executor) selectionSet(obj *Object, source interface{}, selections []selection, path []interface{}, depth int) *orderedMap {
+	result := &orderedMap{values: make(map[string]interface{})}
+	if depth > MaxDepth {
+		ex.fail(path, "query is nested deeper than %d levels", MaxDepth)
+		return result
+	}
+	// Fields selected more than once under one key, e.g. directly and
+	// through a fragment, are resolved once with their subfields merged
+	var keys []string
+	merged := make(map[string]*field)
+	for _, f := range ex.collect(obj, selections, make(map[string]bool)) {
+		key := f.responseKey()
+		if m, ok := merged[key]; ok {
+			m.selections = append(m.selections, f.selections...)
+			continue
+		}
+		copied := *f
+		copied.selections = append([]selection(nil), f.selections...)
+		merged[key] = &copied
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		result.set(key, ex.field(obj, source, merged[key], append(path, key), depth))
+	}
+	return result
+}
+
+// collect flattens fragments into the fields selected on obj
+func (ex *executor) collect(obj *Object, selections []selection, visited map[string]bool) []*field {
+	var fields []*field
+	for _, sel := range selections {
+		switch s := sel.(type) {
+		case *field:
+			fields = append(fields, s)
+		case *inlineFragment:
+			if s.on == "" || s.on == obj.Name {
+				fields = append(fields, ex.collect(obj, s.selections, visited)...)
+			}
+		case *fragmentSpread:
+			frag, ok := ex.doc.fragments[s.name]
+			if !ok {
+				ex.fail(nil, "unknown fragment %s", s.name)
+				continue
+			}
+			if visited[s.name] {
+				continue
+			}
+			visited[s.name] = true
+			if frag.on == obj.Name {
+				fields = append(fields, ex.collect(obj, frag.selections, visited)...)
+			}
+		}
+	}
+	return fields
+}
+
+func (ex *executor) field(obj *Object, source interface{}, f *field, path []interface{}, depth int) interface{} {
+	if f.name == "__typename" {
+		return obj.Name
+	}
+	def, ok := obj.Fields[f.name]
+	if !ok {
+		ex.fail(path, "cannot query field %q on type %s", f.name, obj.Name)
+		return nil
+	}
+	args, err := ex.arguments(def, f)
+	if err != nil {
+		ex.fail(path, "%v", err)
+		return nil
+	}
+
+	var value interface{}
+	if def.Resolve != nil {
+		value, err = def.Resolve(ex.ctx, source, args)
+		if err != nil {
+			ex.fail(path, "%v", err)
+			return nil
+		}
+	} else if m, ok := source.(map[string]interface{}); ok {
+		value = m[f.name]
+	}
+	return ex.complete(def.Type, value, f, path, depth)
+}
+
+// complete shapes a resolved value by its type and the selections on it
+func (ex *executor) complete(typ Type, value interface{}, f *field, path []interface{}, depth int) interface{} {
+	if isNil(value) {
+		return nil
+	}
+	switch t := typ.(type) {
+	case NonNull:
+		return ex.complete(t.Of, value, f, path, depth)
+	case List:
+		items, err := toSlice(value)
+		if err != nil {
+			ex.fail(path, "%v", err)
+			return nil
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			list[i] = ex.complete(t.Of, item, f, append(path, i), depth)
+		}
+		return list
+	case *Object:
+		if len(f.selections) == 0 {
+			ex.fail(path, "field %q of type %s needs a selection of subfields", f.name, t.Name)
+			return nil
+		}
+		return ex.selectionSet(t, value, f.selections, path, depth+1)
+	default:
+		if len(f.selections) > 0 {
+			ex.fail(path, "field %q of type %s has no subfields", f.name, typ)
+			return nil
+		}
+		return value
+	}
+}
+
+// arguments resolves variables and coerces the field's arguments
+func (ex *executor) arguments(def *Field, f *field) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	for name := range f.args {
+		if _, ok := def.Args[name]; !ok {
+			return nil, fmt.Errorf("unknown argument %q on field %q", name, f.name)
+		}
+	}
+	names := make([]string, 0, len(def.Args))
+	for name := range def.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		typ := def.Args[name]
+		raw, given := f.args[name]
+		v, err := ex.resolveValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !given || v == nil {
+			if _, required := typ.(NonNull); required {
+				return nil, fmt.Errorf("argument %q of type %s is required", name, typ)
+			}
+			continue
+		}
+		coerced, err := coerce(typ, v)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %v", name, err)
+		}
+		args[name] = coerced
+	}
+	return args, nil
+}
+
+// resolveValue substitutes variables in an argument value
+func (ex *executor) resolveValue(v value) (interface{}, error) {
+	switch v := v.(type) {
+	case variable:
+		return ex.variables[v.name], nil
+	case enumValue:
+		return string(v), nil
+	case []value:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := ex.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = resolved
+		}
+		return list, nil
+	case map[string]value:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved, err := ex.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			object[key] = resolved
+		}
+		return object, nil
+	}
+	return v, nil
+}
+
+// coerce converts an argument to the Go type of its GraphQL type
+func coerce(typ Type, v interface{}) (interface{}, error) {
+	switch t := typ.(type) {
+	case NonNull:
+		return coerce(t.Of, v)
+	case List:
+		items, ok := v.([]interface{})
+		if !ok {
+			// A single value stands for a list of one
+			items = []interface{}{v}
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerce(t.Of, item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = coerced
+		}
+		return list, nil
+	}
+	switch typ {
+	case String, ID:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case Int:
+		switch n := v.(type) {
+		case int:
+			return n, nil
+		case float64:
+			if n == float64(int(n)) {
+				return int(n), nil
+			}
+		case json.Number:
+			if i, err := n.Int64(); err == nil {
+				return int(i), nil
+			}
+		}
+	case Float:
+		switch n := v.(type) {
+		case int:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+	case Boolean:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case JSON:
+		return v, nil
+	}
+	return nil, fmt.Errorf("expected %s, got %v", typ, v)
+}
+
+// toSlice returns the elements of any slice or array value
+func toSlice(value interface{}) ([]interface{}, error) {
+	if items, ok := value.([]interface{}); ok {
+		return items, nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", value)
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, nil
+}
+
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// orderedMap encodes fields in the order they were selected
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	m.keys = append(m.keys, key)
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
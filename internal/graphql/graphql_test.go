@@ -0,0 +1,165 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type book struct {
+	Title  string
+	Author string
+	Year   int
+}
+
+func testSchema() *Schema {
+	books := []book{
+		{Title: "Dune", Author: "Herbert", Year: 1965},
+		{Title: "Neuromancer", Author: "Gibson", Year: 1984},
+	}
+	bookType := &Object{Name: "Book", Fields: map[string]*Field{
+		"title": {Type: String, Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+			return source.(book).Title, nil
+		}},
+		"year": {Type: Int, Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+			return source.(book).Year, nil
+		}},
+		"author": {Type: JSON, Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+			return map[string]interface{}{"name": source.(book).Author}, nil
+		}},
+		"broken": {Type: String, Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+			return nil, errors.New("unavailable")
+		}},
+	}}
+	query := &Object{Name: "Query", Fields: map[string]*Field{
+		"books": {
+			Type: List{Of: bookType},
+			Args: map[string]Type{"after": Int, "titles": List{Of: String}},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				var matched []book
+				for _, b := range books {
+					if after, ok := args["after"].(int); ok && b.Year <= after {
+						continue
+					}
+					if titles, ok := args["titles"].([]interface{}); ok && !contains(titles, b.Title) {
+						continue
+					}
+					matched = append(matched, b)
+				}
+				return matched, nil
+			},
+		},
+		"book": {
+			Type: bookType,
+			Args: map[string]Type{"title": NonNull{Of: String}},
+			Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+				for _, b := range books {
+					if b.Title == args["title"] {
+						return b, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+	}}
+	return &Schema{Query: query}
+}
+
+func contains(items []interface{}, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func execute(t *testing.T, req Request) (string, []Error) {
+	t.Helper()
+	resp := testSchema().Execute(context.Background(), req)
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		t.Fatalf("Failed to encode data: %v", err)
+	}
+	return string(data), resp.Errors
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			name: "nested selection keeps the requested order",
+			req:  Request{Query: `{ books { year title } }`},
+			want: `{"books":[{"year":1965,"title":"Dune"},{"year":1984,"title":"Neuromancer"}]}`,
+		},
+		{
+			name: "aliases and arguments",
+			req:  Request{Query: `{ recent: books(after: 1970) { title } dune: book(title: "Dune") { year } }`},
+			want: `{"recent":[{"title":"Neuromancer"}],"dune":{"year":1965}}`,
+		},
+		{
+			name: "variables with defaults and list coercion",
+			req: Request{
+				Query:     `query Find($after: Int = 1900, $titles: [String]) { books(after: $after, titles: $titles) { title } }`,
+				Variables: map[string]interface{}{"titles": "Dune"},
+			},
+			want: `{"books":[{"title":"Dune"}]}`,
+		},
+		{
+			name: "fragments merge into the selection",
+			req: Request{Query: `
+				query { book(title: "Dune") { title ...Details ... on Book { __typename } } }
+				fragment Details on Book { year title }`},
+			want: `{"book":{"title":"Dune","year":1965,"__typename":"Book"}}`,
+		},
+		{
+			name: "missing objects are null",
+			req:  Request{Query: `{ book(title: "Solaris") { title } }`},
+			want: `{"book":null}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, errs := execute(t, tt.req)
+			if len(errs) > 0 {
+				t.Fatalf("Unexpected errors: %+v", errs)
+			}
+			if data != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, data)
+			}
+		})
+	}
+}
+
+func TestExecute_Errors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`{ books { title `, "syntax error"},
+		{`mutation { books { title } }`, "mutation operations are not supported"},
+		{`{ books { isbn } }`, `cannot query field "isbn" on type Book`},
+		{`{ books }`, "needs a selection of subfields"},
+		{`{ book { title } }`, `argument "title" of type String! is required`},
+		{`{ books(after: "soon") { title } }`, "expected Int"},
+		{`{ book(title: "Dune") { broken } }`, "unavailable"},
+		{`query Q($title: String!) { book(title: $title) { title } }`, "variable $title of type String! is required"},
+	}
+	for _, tt := range tests {
+		_, errs := execute(t, Request{Query: tt.query})
+		if len(errs) == 0 || !strings.Contains(errs[0].Message, tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %+v", tt.query, tt.want, errs)
+		}
+	}
+
+	// Field errors null the field and report its path
+	data, errs := execute(t, Request{Query: `{ book(title: "Dune") { title broken } }`})
+	if data != `{"book":{"title":"Dune","broken":null}}` || len(errs) != 1 || len(errs[0].Path) != 2 {
+		t.Errorf("Expected a null field with a path, got %s %+v", data, errs)
+	}
+}
@@ -0,0 +1,502 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed query document
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string // query, mutation or subscription
+	name       string
+	variables  []variableDefinition
+	selections []selection
+}
+
+type variableDefinition struct {
+	name       string
+	typ        string
+	defaultVal value
+}
+
+type fragment struct {
+	name       string
+	on         string
+	selections []selection
+}
+
+// selection is a *field, a *fragmentSpread or an *inlineFragment
+type selection interface{}
+
+type field struct {
+	alias      string
+	name       string
+	args       map[string]value
+	selections []selection
+}
+
+// responseKey is the key of the field in the response, its alias if any
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	name string
+}
+
+type inlineFragment struct {
+	on         string
+	selections []selection
+}
+
+// value is an argument value: a Go literal, a variable, a []value or a
+// map[string]value
+type value interface{}
+
+type variable struct {
+	name string
+}
+
+// enumValue is an unquoted name used as a value, resolved as a string
+type enumValue string
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string // unescaped string contents
+	pos   int
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+// parse reads a query document, failing on the first syntax error
+func parse(src string) (doc *document, err error) {
+	p := &parser{src: strings.TrimPrefix(src, "\uFEFF")}
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(syntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, syntaxErr
+		}
+	}()
+
+	p.next()
+	doc = &document{fragments: make(map[string]*fragment)}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek("{"):
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: p.selectionSet()})
+		case p.peekName("query"), p.peekName("mutation"), p.peekName("subscription"):
+			doc.operations = append(doc.operations, p.operation())
+		case p.peekName("fragment"):
+			f := p.fragment()
+			if _, exists := doc.fragments[f.name]; exists {
+				p.fail("fragment %s is defined twice", f.name)
+			}
+			doc.fragments[f.name] = f
+		default:
+			p.fail("unexpected %q", p.tok.text)
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, syntaxError{msg: "document has no operation"}
+	}
+	return doc, nil
+}
+
+type syntaxError struct {
+	msg string
+	pos int
+}
+
+func (e syntaxError) Error() string {
+	return fmt.Sprintf("syntax error at offset %d: %s", e.pos, e.msg)
+}
+
+func (p *parser) fail(format string, args ...interface{}) {
+	panic(syntaxError{msg: fmt.Sprintf(format, args...), pos: p.tok.pos})
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokenPunct && p.tok.text == punct
+}
+
+func (p *parser) peekName(name string) bool {
+	return p.tok.kind == tokenName && p.tok.text == name
+}
+
+func (p *parser) expect(punct string) {
+	if !p.peek(punct) {
+		p.fail("expected %q, got %q", punct, p.tok.text)
+	}
+	p.next()
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != tokenName {
+		p.fail("expected a name, got %q", p.tok.text)
+	}
+	name := p.tok.text
+	p.next()
+	return name
+}
+
+func (p *parser) operation() *operation {
+	op := &operation{kind: p.name()}
+	if p.tok.kind == tokenName {
+		op.name = p.name()
+	}
+	if p.peek("(") {
+		p.next()
+		for !p.peek(")") {
+			p.expect("$")
+			def := variableDefinition{name: p.name()}
+			p.expect(":")
+			def.typ = p.typeRef()
+			if p.peek("=") {
+				p.next()
+				def.defaultVal = p.value(true)
+			}
+			op.variables = append(op.variables, def)
+		}
+		p.next()
+	}
+	p.directives()
+	op.selections = p.selectionSet()
+	return op
+}
+
+// typeRef reads a variable type such as [String!]! as written
+func (p *parser) typeRef() string {
+	var typ string
+	if p.peek("[") {
+		p.next()
+		typ = "[" + p.typeRef() + "]"
+		p.expect("]")
+	} else {
+		typ = p.name()
+	}
+	if p.peek("!") {
+		p.next()
+		typ += "!"
+	}
+	return typ
+}
+
+func (p *parser) fragment() *fragment {
+	p.next()
+	f := &fragment{name: p.name()}
+	if !p.peekName("on") {
+		p.fail("expected a type condition on fragment %s", f.name)
+	}
+	p.next()
+	f.on = p.name()
+	p.directives()
+	f.selections = p.selectionSet()
+	return f
+}
+
+func (p *parser) selectionSet() []selection {
+	p.expect("{")
+	var selections []selection
+	for !p.peek("}") {
+		if p.tok.kind == tokenEOF {
+			p.fail("unterminated selection set")
+		}
+		selections = append(selections, p.selection())
+	}
+	p.next()
+	if len(selections) == 0 {
+		p.fail("empty selection set")
+	}
+	return selections
+}
+
+func (p *parser) selection() selection {
+	if p.peek("...") {
+		p.next()
+		if p.peekName("on") {
+			p.next()
+			frag := &inlineFragment{on: p.name()}
+			p.directives()
+			frag.selections = p.selectionSet()
+			return frag
+		}
+		if p.peek("{") {
+			return &inlineFragment{selections: p.selectionSet()}
+		}
+		spread := &fragmentSpread{name: p.name()}
+		p.directives()
+		return spread
+	}
+
+	f := &field{name: p.name()}
+	if p.peek(":") {
+		p.next()
+		f.alias, f.name = f.name, p.name()
+	}
+	if p.peek("(") {
+		p.next()
+		f.args = make(map[string]value)
+		for !p.peek(")") {
+			name := p.name()
+			p.expect(":")
+			f.args[name] = p.value(false)
+		}
+		p.next()
+	}
+	p.directives()
+	if p.peek("{") {
+		f.selections = p.selectionSet()
+	}
+	return f
+}
+
+// directives rejects directives, which aren't supported
+func (p *parser) directives() {
+	if p.peek("@") {
+		p.fail("directives are not supported")
+	}
+}
+
+func (p *parser) value(constant bool) value {
+	tok := p.tok
+	switch tok.kind {
+	case tokenInt:
+		p.next()
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			p.fail("invalid integer %s", tok.text)
+		}
+		return int(n)
+	case tokenFloat:
+		p.next()
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			p.fail("invalid float %s", tok.text)
+		}
+		return f
+	case tokenString:
+		p.next()
+		return tok.value
+	case tokenName:
+		p.next()
+		switch tok.text {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return enumValue(tok.text)
+	}
+
+	switch {
+	case p.peek("$"):
+		if constant {
+			p.fail("variables are not allowed in default values")
+		}
+		p.next()
+		return variable{name: p.name()}
+	case p.peek("["):
+		p.next()
+		list := make([]value, 0)
+		for !p.peek("]") {
+			if p.tok.kind == tokenEOF {
+				p.fail("unterminated list")
+			}
+			list = append(list, p.value(constant))
+		}
+		p.next()
+		return list
+	case p.peek("{"):
+		p.next()
+		object := make(map[string]value)
+		for !p.peek("}") {
+			name := p.name()
+			p.expect(":")
+			object[name] = p.value(constant)
+		}
+		p.next()
+		return object
+	}
+	p.fail("expected a value, got %q", tok.text)
+	return nil
+}
+
+// next reads the following token, skipping whitespace, commas and comments
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+			continue
+		}
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokenEOF, pos: start}
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = token{kind: tokenPunct, text: "...", pos: start}
+	case strings.IndexByte("{}()[]:!$=@", c) >= 0:
+		p.pos++
+		p.tok = token{kind: tokenPunct, text: string(c), pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenName, text: p.src[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		p.number(start)
+	case c == '"':
+		p.string(start)
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.tok = token{kind: tokenPunct, text: string(r), pos: start}
+		p.fail("unexpected character %q", r)
+	}
+}
+
+func (p *parser) number(start int) {
+	kind := tokenInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		kind = tokenFloat
+		p.pos++
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		kind = tokenFloat
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.tok = token{kind: kind, text: p.src[start:p.pos], pos: start}
+}
+
+func (p *parser) string(start int) {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			p.tok = token{pos: start}
+			p.fail("unterminated block string")
+		}
+		text := p.src[p.pos+3 : p.pos+3+end]
+		p.pos += end + 6
+		p.tok = token{kind: tokenString, text: p.src[start:p.pos], value: strings.TrimSpace(text), pos: start}
+		return
+	}
+
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.tok = token{pos: start}
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		if p.pos+1 >= len(p.src) {
+			p.tok = token{pos: start}
+			p.fail("unterminated string")
+		}
+		switch esc := p.src[p.pos+1]; esc {
+		case '"', '\\', '/':
+			b.WriteByte(esc)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+6 > len(p.src) {
+				p.tok = token{pos: start}
+				p.fail("invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(p.src[p.pos+2:p.pos+6], 16, 32)
+			if err != nil {
+				p.tok = token{pos: start}
+				p.fail("invalid unicode escape")
+			}
+			b.WriteRune(rune(r))
+			p.pos += 4
+		default:
+			p.tok = token{pos: start}
+			p.fail("invalid escape \\%c", esc)
+		}
+		p.pos += 2
+	}
+	p.tok = token{kind: tokenString, text: p.src[start:p.pos], value: b.String(), pos: start}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
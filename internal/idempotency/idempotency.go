@@ -0,0 +1,79 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Header is the request header carrying the client-chosen key
+const Header = "Idempotency-Key"
+
+// DefaultRetention is how long a key is remembered
+const DefaultRetention = 24 * time.Hour
+
+// Response is the recorded outcome of the first request sent with a key
+type Response struct {
+	Key string
+	// Fingerprint identifies the method, path and body the key was first
+	// used with, so a key reused for another request is rejected
+	Fingerprint string
+	Status      int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+// Store remembers responses by key. Responses older than the retention are
+// ignored and may be dropped.
+type Store interface {
+	GetIdempotentResponse(key string, retention time.Duration) (*Response, bool, error)
+	SaveIdempotentResponse(resp Response, retention time.Duration) error
+}
+
+// Fingerprint hashes the parts of a request a key is bound to
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// MemoryStore keeps responses in process, for deployments without
+// PostgreSQL
+type MemoryStore struct {
+	mu        sync.Mutex
+	responses map[string]Response
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{responses: make(map[string]Response)}
+}
+
+func (s *MemoryStore) GetIdempotentResponse(key string, retention time.Duration) (*Response, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp, exists := s.responses[key]
+	if !exists || time.Since(resp.CreatedAt) > retention {
+		return nil, false, nil
+	}
+	return &resp, true, nil
+}
+
+// SaveIdempotentResponse records resp, dropping expired responses
+func (s *MemoryStore) SaveIdempotentResponse(resp Response, retention time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := time.Now().Add(-retention)
+	for key, r := range s.responses {
+		if r.CreatedAt.Before(cutoff) {
+			delete(s.responses, key)
+		}
+	}
+	s.responses[resp.Key] = resp
+	return nil
+}
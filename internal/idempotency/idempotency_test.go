@@ -0,0 +1,30 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	old := Response{Key: "old", Status: 201, CreatedAt: time.Now().Add(-2 * time.Hour)}
+	store.SaveIdempotentResponse(old, 3*time.Hour)
+	store.SaveIdempotentResponse(Response{Key: "new", Status: 200, CreatedAt: time.Now()}, time.Hour)
+
+	if _, found, _ := store.GetIdempotentResponse("new", time.Hour); !found {
+		t.Error("Expected the new key to be remembered")
+	}
+	if _, found, _ := store.GetIdempotentResponse("old", 3*time.Hour); found {
+		t.Error("Expected the expired key to be dropped on save")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := Fingerprint("POST", "/api/v1/invariants", []byte(`{}`))
+	if a != Fingerprint("POST", "/api/v1/invariants", []byte(`{}`)) {
+		t.Error("Expected equal requests to share a fingerprint")
+	}
+	if a == Fingerprint("PUT", "/api/v1/invariants", []byte(`{}`)) || a == Fingerprint("POST", "/api/v1/invariants", []byte(`{"id":"x"}`)) {
+		t.Error("Expected the method and body to change the fingerprint")
+	}
+}
@@ -0,0 +1,346 @@
+// Package invtest runs golden-file regression tests for invariants: YAML
+// files holding resource fixtures and the violations they must produce.
+package invtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"sigs.k8s.io/yaml"
+)
+
+// File is a test file. Invariants declared in it are under test in its
+// cases alongside the shared ones; a file may declare only invariants.
+type File struct {
+	Path       string          `json:"-"`
+	Invariants []dsl.Invariant `json:"invariants,omitempty"`
+	Cases      []Case          `json:"cases,omitempty"`
+}
+
+// Case records resources in an empty store and expects exactly the listed
+// findings from the invariants under test
+type Case struct {
+	Name      string             `json:"name"`
+	Resources []types.StateEvent `json:"resources"`
+	Expect    []Expectation      `json:"expect,omitempty"`
+}
+
+// Expectation is a finding a case must produce. Status defaults to
+// violated, and Reason, if set, must be contained in the finding's reason.
+type Expectation struct {
+	Invariant string `json:"invariant"`
+	Resource  string `json:"resource"`
+	Status    string `json:"status,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+func (e Expectation) String() string {
+	s := fmt.Sprintf("%s %s on %s", e.Invariant, e.status(), e.Resource)
+	if e.Reason != "" {
+		s += fmt.Sprintf(" (reason containing %q)", e.Reason)
+	}
+	return s
+}
+
+func (e Expectation) status() string {
+	if e.Status == "" {
+		return string(engine.StatusViolated)
+	}
+	return e.Status
+}
+
+// matches reports whether result is the finding e expects
+func (e Expectation) matches(result *engine.ViolationResult) bool {
+	return result.InvariantID == e.Invariant &&
+		string(status(result)) == e.status() &&
+		sameResource(e.Resource, result.AffectedResource) &&
+		strings.Contains(result.Reason, e.Reason)
+}
+
+// status is the status of a finding, violated for those that only set
+// Violated
+func status(result *engine.ViolationResult) engine.EvaluationStatus {
+	if result.Status == "" && result.Violated {
+		return engine.StatusViolated
+	}
+	return result.Status
+}
+
+// sameResource compares a resource written as namespace/name, or as name
+// for cluster-scoped ones, to a finding's affected resource
+func sameResource(want, affected string) bool {
+	return want == affected || "/"+want == affected
+}
+
+// Load reads every .yaml, .yml and .json file under paths
+func Load(paths ...string) ([]File, error) {
+	var files []File
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !isTestFile(path) {
+				return nil
+			}
+			file, err := ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files = append(files, file)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func isTestFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// ReadFile reads and validates a test file
+func ReadFile(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	var file File
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return File{}, fmt.Errorf("%s: %w", path, err)
+	}
+	file.Path = path
+	if err := file.Validate(); err != nil {
+		return File{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Validate checks the file's invariants and cases
+func (f File) Validate() error {
+	for _, inv := range f.Invariants {
+		// Invariants extending another are validated once resolved, when
+		// the cases run
+		if inv.Extends != "" && inv.ID != "" {
+			continue
+		}
+		if err := inv.Validate(); err != nil {
+			return fmt.Errorf("invariant %q: %w", inv.ID, err)
+		}
+	}
+	for i, c := range f.Cases {
+		if c.Name == "" {
+			return fmt.Errorf("case %d: name is required", i)
+		}
+		for j, r := range c.Resources {
+			if r.Kind == "" || r.Name == "" {
+				return fmt.Errorf("case %q: resource %d: kind and name are required", c.Name, j)
+			}
+		}
+		for _, e := range c.Expect {
+			if e.Invariant == "" || e.Resource == "" {
+				return fmt.Errorf("case %q: expectations need an invariant and a resource", c.Name)
+			}
+			switch engine.EvaluationStatus(e.status()) {
+			case engine.StatusViolated, engine.StatusUnknown, engine.StatusEvaluationError:
+			default:
+				return fmt.Errorf("case %q: invalid status %q, want violated, unknown or evaluation_error", c.Name, e.Status)
+			}
+		}
+	}
+	return nil
+}
+
+// Options selects the invariants under test
+type Options struct {
+	// Invariants are under test in every case
+	Invariants []dsl.Invariant
+	// Builtin puts the builtin invariants under test too
+	Builtin bool
+}
+
+// Result is the outcome of a case
+type Result struct {
+	File string
+	Case string
+	// Missing are the expected findings the case didn't produce
+	Missing []Expectation
+	// Unexpected are the findings no expectation covers
+	Unexpected []*engine.ViolationResult
+	Err        error
+}
+
+// Passed reports whether the case produced exactly the expected findings
+func (r Result) Passed() bool {
+	return r.Err == nil && len(r.Missing) == 0 && len(r.Unexpected) == 0
+}
+
+// Run runs the cases of every file
+func Run(files []File, opts Options) []Result {
+	var results []Result
+	for _, f := range files {
+		invariants := append(append([]dsl.Invariant(nil), opts.Invariants...), f.Invariants...)
+		for _, c := range f.Cases {
+			result := runCase(c, invariants, opts.Builtin)
+			result.File = f.Path
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+func runCase(c Case, invariants []dsl.Invariant, builtin bool) Result {
+	result := Result{Case: c.Name}
+	if len(invariants) == 0 && !builtin {
+		result.Err = errors.New("no invariants under test")
+		return result
+	}
+
+	store := state.NewMemoryStore()
+	now := time.Now()
+	for _, r := range fixtures(c.Resources, now) {
+		if err := store.Record(r); err != nil {
+			result.Err = fmt.Errorf("failed to record %s %s/%s: %w", r.Kind, r.Namespace, r.Name, err)
+			return result
+		}
+	}
+	// Invariants may extend the builtin ones even when those aren't under
+	// test, so they are removed only once the others are resolved
+	eng := engine.NewInvariantEngine(store)
+	builtins := eng.GetInvariants()
+	underTest := make(map[string]bool, len(invariants))
+	for _, inv := range invariants {
+		resolved, err := eng.ResolveExtends(inv)
+		if err == nil {
+			err = resolved.Validate()
+		}
+		if err != nil {
+			result.Err = fmt.Errorf("invariant %q: %w", inv.ID, err)
+			return result
+		}
+		eng.UpsertInvariant(resolved)
+		underTest[inv.ID] = true
+	}
+	if !builtin {
+		for _, inv := range builtins {
+			if !underTest[inv.ID] {
+				eng.DeleteInvariant(inv.ID)
+			}
+		}
+	}
+
+	findings := eng.EvaluateAll()
+	covered := make([]bool, len(findings))
+	for _, e := range c.Expect {
+		found := false
+		for i, f := range findings {
+			if e.matches(f) {
+				covered[i], found = true, true
+			}
+		}
+		if !found {
+			result.Missing = append(result.Missing, e)
+		}
+	}
+	for i, f := range findings {
+		if !covered[i] {
+			result.Unexpected = append(result.Unexpected, f)
+		}
+	}
+	sort.Slice(result.Unexpected, func(i, j int) bool {
+		a, b := result.Unexpected[i], result.Unexpected[j]
+		if a.InvariantID != b.InvariantID {
+			return a.InvariantID < b.InvariantID
+		}
+		return a.AffectedResource < b.AffectedResource
+	})
+	return result
+}
+
+// fixtures fills in the identity and timestamps fixtures leave out and
+// flattens their full state into dotted field paths
+func fixtures(resources []types.StateEvent, now time.Time) []types.StateEvent {
+	events := make([]types.StateEvent, len(resources))
+	for i, r := range resources {
+		if r.UID == "" {
+			r.UID = fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+		}
+		if r.Timestamp.IsZero() {
+			r.Timestamp = now
+		}
+		if r.Actor == "" {
+			r.Actor = "fixture"
+		}
+		fields := make(map[string]interface{}, len(r.FieldDiff))
+		if full, ok := r.FullState.(map[string]interface{}); ok {
+			flatten("", full, fields)
+		}
+		for path, value := range r.FieldDiff {
+			fields[path] = value
+		}
+		r.FieldDiff = fields
+		events[i] = r
+	}
+	return events
+}
+
+func flatten(prefix string, tree map[string]interface{}, fields map[string]interface{}) {
+	for key, value := range tree {
+		if child, ok := value.(map[string]interface{}); ok {
+			flatten(prefix+key+".", child, fields)
+			continue
+		}
+		fields[prefix+key] = value
+	}
+}
+
+// WriteText writes a PASS or FAIL line per case, what failed cases got
+// wrong, and a summary
+func WriteText(w io.Writer, results []Result) {
+	failed := 0
+	for _, r := range results {
+		if r.Passed() {
+			fmt.Fprintf(w, "PASS  %s: %s\n", r.File, r.Case)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "FAIL  %s: %s\n", r.File, r.Case)
+		if r.Err != nil {
+			fmt.Fprintf(w, "      error: %v\n", r.Err)
+		}
+		for _, e := range r.Missing {
+			fmt.Fprintf(w, "      missing: %s\n", e)
+		}
+		for _, f := range r.Unexpected {
+			fmt.Fprintf(w, "      unexpected: %s %s on %s: %s\n", f.InvariantID, status(f), f.AffectedResource, f.Reason)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d failed\n", len(results)-failed, failed)
+}
+
+// Failed reports whether any case failed
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if !r.Passed() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package invtest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestRun(t *testing.T) {
+	files, err := Load("testdata")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	results := Run(files, Options{})
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for _, r := range results[:2] {
+		if !r.Passed() {
+			t.Errorf("case %q failed: missing %v, unexpected %d, error %v", r.Case, r.Missing, len(r.Unexpected), r.Err)
+		}
+	}
+	failed := results[2]
+	if failed.Passed() {
+		t.Fatal("wrong expectation passed")
+	}
+	if len(failed.Missing) != 1 || len(failed.Unexpected) != 1 || failed.Unexpected[0].AffectedResource != "shop/web" {
+		t.Errorf("got missing %v and unexpected %v, want shop/api missing and shop/web unexpected", failed.Missing, failed.Unexpected)
+	}
+	if !Failed(results) {
+		t.Error("Failed = false, want true")
+	}
+
+	var out bytes.Buffer
+	WriteText(&out, results)
+	for _, want := range []string{"PASS  testdata/replicas.yaml: single replica", "FAIL  testdata/replicas.yaml: wrong expectation", "missing: web_replicas violated on shop/api", "2 passed, 1 failed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRun_NoInvariants(t *testing.T) {
+	results := Run([]File{{Path: "empty.yaml", Cases: []Case{{Name: "nothing"}}}}, Options{})
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("got %+v, want an error for a case without invariants under test", results)
+	}
+}
+
+func TestFile_Validate(t *testing.T) {
+	f := File{Cases: []Case{{Name: "bad", Expect: []Expectation{{Invariant: "x", Resource: "a/b", Status: "satisfied"}}}}}
+	if err := f.Validate(); err == nil {
+		t.Error("Validate accepted an expected satisfied status")
+	}
+}
+
+func TestRun_Extends(t *testing.T) {
+	// Extending a builtin doesn't put it under test, and extensions chain
+	file := File{
+		Path: "extends.yaml",
+		Invariants: []dsl.Invariant{
+			{ID: "prod_pod_ready", Extends: "pod_ready", Subject: dsl.Subject{Namespace: "prod"}},
+			{ID: "prod_pod_running", Extends: "prod_pod_ready", Predicate: &dsl.Predicate{Field: "status.phase", Operator: dsl.Equals, Value: "Running"}},
+		},
+		Cases: []Case{{
+			Name: "unready prod pod",
+			Resources: []types.StateEvent{
+				{Kind: "Pod", Namespace: "prod", Name: "api", FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False", "status.phase": "Pending"}},
+				{Kind: "Pod", Namespace: "dev", Name: "api", FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False", "status.phase": "Pending"}},
+			},
+			Expect: []Expectation{
+				{Invariant: "prod_pod_ready", Resource: "prod/api"},
+				{Invariant: "prod_pod_running", Resource: "prod/api"},
+			},
+		}},
+	}
+	if err := file.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	results := Run([]File{file}, Options{})
+	if len(results) != 1 || !results[0].Passed() {
+		t.Fatalf("got %+v, want the extensions to pass", results)
+	}
+
+	file.Invariants = []dsl.Invariant{{ID: "orphan", Extends: "missing"}}
+	if results := Run([]File{file}, Options{}); results[0].Err == nil {
+		t.Error("Run accepted an invariant extending a missing one")
+	}
+}
@@ -495,6 +495,9 @@ func (c *MockKubernetesCluster) ToStateEvents() []types.StateEvent {
 			if pod.ContainerStatus.WaitingReason != "" {
 				event.FieldDiff["status.containerStatuses.waiting.reason"] = pod.ContainerStatus.WaitingReason
 			}
+			if pod.ContainerStatus.WaitingReason == "OOMKilled" {
+				event.FieldDiff["status.containerStatuses.lastTermination.reason"] = "OOMKilled"
+			}
 		}
 
 		events = append(events, event)
@@ -1066,7 +1069,7 @@ func RunAPIIntegrationTests(apiAddr string) {
 			Endpoint: "/health",
 			Check: func(body []byte) bool {
 				return strings.Contains(string(body), "healthy") ||
-					strings.Contains(string(body), "unhealthy")
+					strings.Contains(string(body), "degraded")
 			},
 		},
 		{
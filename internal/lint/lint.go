@@ -0,0 +1,357 @@
+// Package lint evaluates the spec-level invariants against Kubernetes
+// manifests offline, without a cluster.
+package lint
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/dsl/invariants"
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/scan"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// Fields derived from manifests for the manifest invariants
+const (
+	FieldInvalidImages           = "spec.invalidImages"
+	FieldMissingReadinessProbes  = "spec.missingReadinessProbes"
+	FieldSelectorMatchesTemplate = "spec.selectorMatchesTemplate"
+	FieldSelectorMatchesPods     = "spec.selectorMatchesPods"
+)
+
+// Object is a decoded manifest and the file declaring it
+type Object struct {
+	Object runtime.Object
+	Source string
+	// Environment names the values a chart was rendered with, if any
+	Environment string
+}
+
+// Skipped is a manifest document lint doesn't check, such as a custom
+// resource
+type Skipped struct {
+	Source string
+	Kind   string
+	Reason string
+}
+
+// Load reads every .yaml, .yml and .json file under paths. Documents of
+// kinds the Kubernetes scheme doesn't know are skipped, not failed.
+func Load(paths ...string) ([]Object, []Skipped, error) {
+	var objects []Object
+	var skipped []Skipped
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (path != root && !isManifest(path)) {
+				return nil
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			decoded, skips, err := Decode(f, path)
+			if err != nil {
+				return err
+			}
+			objects = append(objects, decoded...)
+			skipped = append(skipped, skips...)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return objects, skipped, nil
+}
+
+func isManifest(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// Decode reads the documents of a multi-document YAML or JSON stream,
+// expanding List objects
+func Decode(r io.Reader, source string) ([]Object, []Skipped, error) {
+	var objects []Object
+	var skipped []Skipped
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objects, skipped, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", source, err)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		decoded, skips, err := decodeDocument(doc, source)
+		if err != nil {
+			return nil, nil, err
+		}
+		objects = append(objects, decoded...)
+		skipped = append(skipped, skips...)
+	}
+}
+
+func decodeDocument(doc []byte, source string) ([]Object, []Skipped, error) {
+	var meta metav1.TypeMeta
+	if err := yaml.Unmarshal(doc, &meta); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", source, err)
+	}
+	if meta.Kind == "" {
+		// Comment-only documents between separators declare nothing
+		return nil, nil, nil
+	}
+
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		return nil, []Skipped{{Source: source, Kind: meta.Kind, Reason: "unknown kind"}}, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	list, ok := obj.(*corev1.List)
+	if !ok {
+		return []Object{{Object: obj, Source: source}}, nil, nil
+	}
+	var objects []Object
+	var skipped []Skipped
+	for _, item := range list.Items {
+		decoded, skips, err := decodeDocument(item.Raw, source)
+		if err != nil {
+			return nil, nil, err
+		}
+		objects = append(objects, decoded...)
+		skipped = append(skipped, skips...)
+	}
+	return objects, skipped, nil
+}
+
+// NewEngine creates an engine evaluating the builtin invariants tagged
+// manifest and the manifest-only invariants
+func NewEngine(store state.StateStore) *engine.InvariantEngine {
+	eng := engine.NewInvariantEngine(store)
+	for _, inv := range eng.GetInvariants() {
+		if !inv.HasAnyTag([]string{dsl.TagManifest}) {
+			eng.DeleteInvariant(inv.ID)
+		}
+	}
+	for _, inv := range invariants.GetManifestInvariants() {
+		eng.UpsertInvariant(inv)
+	}
+	return eng
+}
+
+// Run records objects in the engine's store and evaluates them. Objects
+// without a namespace are placed in namespace, as kubectl apply would, and
+// are updated in place.
+func Run(objects []Object, eng *engine.InvariantEngine, store state.StateStore, namespace string) (*scan.Report, error) {
+	started := time.Now()
+	events := Events(objects, namespace, started)
+
+	resources := make(map[string]int)
+	sources := make(map[string]string, len(events))
+	environments := make(map[string]string)
+	for _, e := range events {
+		if err := store.Record(e.StateEvent); err != nil {
+			return nil, fmt.Errorf("failed to record %s %s/%s: %w", e.Kind, e.Namespace, e.Name, err)
+		}
+		resources[e.Kind]++
+		sources[e.UID] = e.Source
+		if e.Environment != "" {
+			environments[e.UID] = e.Environment
+		}
+	}
+
+	report := scan.Evaluate(eng, resources, started)
+	report.Sources = sources
+	if len(environments) > 0 {
+		report.Environments = environments
+	}
+	return report, nil
+}
+
+// Event is a state event derived from a manifest
+type Event struct {
+	types.StateEvent
+	Source      string
+	Environment string
+}
+
+// template is a pod template declared by a manifest, standing in for the
+// pods a controller will create from it
+type template struct {
+	meta        metav1.ObjectMeta
+	spec        corev1.PodSpec
+	longRunning bool
+	source      string
+	env         string
+}
+
+// Events converts objects into the state events the manifest invariants
+// read. Each workload's pod template becomes a Pod named after the
+// workload; Services are matched against every template of their namespace.
+func Events(objects []Object, namespace string, now time.Time) []Event {
+	var events []Event
+	var templates []template
+
+	for _, o := range objects {
+		accessor, ok := o.Object.(metav1.Object)
+		if !ok {
+			continue
+		}
+		if accessor.GetNamespace() == "" {
+			accessor.SetNamespace(namespace)
+		}
+
+		switch obj := o.Object.(type) {
+		case *corev1.Pod:
+			longRunning := obj.Spec.RestartPolicy != corev1.RestartPolicyNever && obj.Spec.RestartPolicy != corev1.RestartPolicyOnFailure
+			templates = append(templates, template{meta: obj.ObjectMeta, spec: obj.Spec, longRunning: longRunning, source: o.Source, env: o.Environment})
+		case *appsv1.Deployment:
+			templates = append(templates, templateOf(obj.ObjectMeta, obj.Spec.Template, true, o))
+			events = append(events, deploymentEvent(obj, o, now))
+		case *appsv1.StatefulSet:
+			templates = append(templates, templateOf(obj.ObjectMeta, obj.Spec.Template, true, o))
+		case *appsv1.DaemonSet:
+			templates = append(templates, templateOf(obj.ObjectMeta, obj.Spec.Template, true, o))
+		case *appsv1.ReplicaSet:
+			templates = append(templates, templateOf(obj.ObjectMeta, obj.Spec.Template, true, o))
+		case *batchv1.Job:
+			templates = append(templates, templateOf(obj.ObjectMeta, obj.Spec.Template, false, o))
+		case *batchv1.CronJob:
+			templates = append(templates, templateOf(obj.ObjectMeta, obj.Spec.JobTemplate.Spec.Template, false, o))
+		}
+	}
+
+	for _, t := range templates {
+		events = append(events, podEvent(t, now))
+	}
+	for _, o := range objects {
+		if svc, ok := o.Object.(*corev1.Service); ok {
+			events = append(events, serviceEvent(svc, o, templates, now))
+		}
+	}
+	return events
+}
+
+// templateOf names a workload's pod template after the workload, since the
+// pods it creates get generated names
+func templateOf(owner metav1.ObjectMeta, tmpl corev1.PodTemplateSpec, longRunning bool, o Object) template {
+	meta := tmpl.ObjectMeta
+	meta.Name = owner.Name
+	meta.Namespace = owner.Namespace
+	meta.UID = owner.UID
+	return template{meta: meta, spec: tmpl.Spec, longRunning: longRunning, source: o.Source, env: o.Environment}
+}
+
+func podEvent(t template, now time.Time) Event {
+	pod := &corev1.Pod{ObjectMeta: t.meta, Spec: t.spec}
+	event := newEvent("Pod", t.meta, t.env, now)
+	for k, v := range watcher.SecurityFields(pod) {
+		event.FieldDiff[k] = v
+	}
+
+	invalid, missingProbe := false, false
+	for _, c := range append(append([]corev1.Container{}, t.spec.InitContainers...), t.spec.Containers...) {
+		if !watcher.ImageReferenceValid(c.Image) {
+			invalid = true
+		}
+	}
+	for _, c := range t.spec.Containers {
+		if c.ReadinessProbe == nil {
+			missingProbe = true
+		}
+	}
+	event.FieldDiff[FieldInvalidImages] = conditionString(invalid)
+	// Jobs run to completion and never receive traffic
+	event.FieldDiff[FieldMissingReadinessProbes] = conditionString(missingProbe && t.longRunning)
+	return Event{StateEvent: event, Source: t.source, Environment: t.env}
+}
+
+func deploymentEvent(d *appsv1.Deployment, o Object, now time.Time) Event {
+	event := newEvent("Deployment", d.ObjectMeta, o.Environment, now)
+	matches := false
+	if d.Spec.Selector != nil {
+		if selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector); err == nil && !selector.Empty() {
+			matches = selector.Matches(labels.Set(d.Spec.Template.Labels))
+		}
+	}
+	event.FieldDiff[FieldSelectorMatchesTemplate] = conditionString(matches)
+	return Event{StateEvent: event, Source: o.Source, Environment: o.Environment}
+}
+
+// serviceEvent matches a Service's selector against the templates of its
+// namespace and environment. A Service without a selector has its
+// endpoints managed elsewhere and always matches.
+func serviceEvent(svc *corev1.Service, o Object, templates []template, now time.Time) Event {
+	event := newEvent("Service", svc.ObjectMeta, o.Environment, now)
+	matches := len(svc.Spec.Selector) == 0 || svc.Spec.Type == corev1.ServiceTypeExternalName
+	selector := labels.SelectorFromSet(svc.Spec.Selector)
+	for _, t := range templates {
+		if !matches && t.env == o.Environment && t.meta.Namespace == svc.Namespace && selector.Matches(labels.Set(t.meta.Labels)) {
+			matches = true
+		}
+	}
+	event.FieldDiff[FieldSelectorMatchesPods] = conditionString(matches)
+	return Event{StateEvent: event, Source: o.Source, Environment: o.Environment}
+}
+
+// newEvent identifies a manifest by environment, kind, namespace and name,
+// as it has no UID until it is applied
+func newEvent(kind string, meta metav1.ObjectMeta, env string, now time.Time) types.StateEvent {
+	uid := fmt.Sprintf("manifest:%s/%s/%s", kind, meta.Namespace, meta.Name)
+	if env != "" {
+		uid = fmt.Sprintf("manifest:%s:%s/%s/%s", env, kind, meta.Namespace, meta.Name)
+	}
+	return types.StateEvent{
+		UID:               uid,
+		Kind:              kind,
+		Namespace:         meta.Namespace,
+		Name:              meta.Name,
+		Labels:            meta.Labels,
+		Timestamp:         now,
+		CreationTimestamp: now,
+		Actor:             "manifest",
+		FieldDiff:         make(map[string]interface{}),
+	}
+}
+
+func conditionString(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
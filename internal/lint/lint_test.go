@@ -0,0 +1,79 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+)
+
+func TestLoad(t *testing.T) {
+	objects, skipped, err := Load("testdata/manifests")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(objects) != 4 {
+		t.Errorf("Expected a deployment, two services and a job from the list, got %d objects", len(objects))
+	}
+	if len(skipped) != 1 || skipped[0].Kind != "ServiceMonitor" {
+		t.Errorf("Expected the ServiceMonitor to be skipped, got %+v", skipped)
+	}
+
+	if _, _, err := Decode(strings.NewReader("kind: Pod\nmetadata: [broken"), "bad.yaml"); err == nil {
+		t.Error("Expected malformed YAML to fail")
+	}
+}
+
+func TestRun(t *testing.T) {
+	objects, _, err := Load("testdata/manifests")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	store := state.NewMemoryStore()
+	eng := NewEngine(store)
+	for _, inv := range eng.GetInvariants() {
+		if !inv.HasAnyTag([]string{dsl.TagManifest}) {
+			t.Errorf("Expected only manifest invariants, got %s", inv.ID)
+		}
+	}
+
+	report, err := Run(objects, eng, store, "default")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Unknown != 0 {
+		t.Errorf("Expected every manifest invariant to be decidable, got %d unknown", report.Unknown)
+	}
+
+	found := make(map[string]string)
+	for _, v := range report.Violations {
+		found[v.InvariantID+" "+v.AffectedResource] = report.Sources[v.ResourceUID]
+	}
+	for _, want := range []string{
+		"pod_image_reference_valid default/web",
+		"pod_not_privileged default/web",
+		"pod_readiness_probe_set default/web",
+		"pod_resource_limits_set default/web",
+		"service_selector_matches_workload default/api",
+	} {
+		if source, ok := found[want]; !ok {
+			t.Errorf("Expected violation %s, got %v", want, found)
+		} else if !strings.HasSuffix(source, "shop.yaml") {
+			t.Errorf("Expected %s sourced from shop.yaml, got %q", want, source)
+		}
+	}
+	for _, unwanted := range []string{
+		"service_selector_matches_workload default/web",
+		"deployment_selector_matches_template default/web",
+		"pod_readiness_probe_set shop/migrate",
+		"pod_runs_as_non_root shop/migrate",
+	} {
+		if _, ok := found[unwanted]; ok {
+			t.Errorf("Unexpected violation %s", unwanted)
+		}
+	}
+	if !report.Failed(dsl.Critical) {
+		t.Error("Expected the privileged container to fail a critical gate")
+	}
+}
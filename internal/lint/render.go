@@ -0,0 +1,172 @@
+package lint
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Renderer renders kustomizations and Helm charts by running the kustomize
+// and helm binaries, so lint checks exactly what would be applied
+type Renderer struct {
+	// Kustomize is the kustomize binary; when it isn't installed
+	// "kubectl kustomize" is tried instead
+	Kustomize string
+	Kubectl   string
+	Helm      string
+}
+
+func NewRenderer() *Renderer {
+	return &Renderer{Kustomize: "kustomize", Kubectl: "kubectl", Helm: "helm"}
+}
+
+// HelmChart is a chart rendered with helm template
+type HelmChart struct {
+	Chart     string
+	Release   string
+	Namespace string
+	// Values are value files applied in order, later ones overriding
+	Values []string
+	// Set holds key=value overrides applied after the value files
+	Set []string
+}
+
+// Environment is a named set of value files layered over a chart's own,
+// such as values-staging.yaml for staging
+type Environment struct {
+	Name   string
+	Values []string
+}
+
+// ParseEnvironment parses NAME=FILE[,FILE...]
+func ParseEnvironment(s string) (Environment, error) {
+	name, files, ok := strings.Cut(s, "=")
+	if !ok || name == "" || files == "" {
+		return Environment{}, fmt.Errorf("invalid environment %q, want NAME=FILE[,FILE...]", s)
+	}
+	return Environment{Name: name, Values: strings.Split(files, ",")}, nil
+}
+
+// Kustomization renders the kustomization in dir
+func (r *Renderer) Kustomization(ctx context.Context, dir string) ([]Object, []Skipped, error) {
+	out, err := r.run(ctx, r.Kustomize, "build", dir)
+	if errors.Is(err, exec.ErrNotFound) {
+		out, err = r.run(ctx, r.Kubectl, "kustomize", dir)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render %s: %w", dir, err)
+	}
+	return Decode(bytes.NewReader(out), filepath.Join(dir, "kustomization.yaml"))
+}
+
+// Chart renders a Helm chart with env's value files over the chart's.
+// Objects are sourced from the template that produced them and carry the
+// environment's name, so the same resource rendered for several
+// environments stays apart.
+func (r *Renderer) Chart(ctx context.Context, chart HelmChart, env Environment) ([]Object, []Skipped, error) {
+	release := chart.Release
+	if release == "" {
+		release = "release"
+	}
+	args := []string{"template", release, chart.Chart}
+	if chart.Namespace != "" {
+		args = append(args, "--namespace", chart.Namespace)
+	}
+	for _, values := range append(append([]string{}, chart.Values...), env.Values...) {
+		args = append(args, "--values", values)
+	}
+	for _, set := range chart.Set {
+		args = append(args, "--set", set)
+	}
+
+	out, err := r.run(ctx, r.Helm, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render %s: %w", chart.Chart, err)
+	}
+
+	var objects []Object
+	var skipped []Skipped
+	for _, doc := range splitDocuments(out) {
+		decoded, skips, err := Decode(bytes.NewReader(doc), templateSource(doc, chart.Chart))
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range decoded {
+			decoded[i].Environment = env.Name
+		}
+		objects = append(objects, decoded...)
+		skipped = append(skipped, skips...)
+	}
+	return objects, skipped, nil
+}
+
+// run executes a renderer binary, returning its stdout or an error carrying
+// its stderr
+func (r *Renderer) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// splitDocuments splits rendered YAML at document separators
+func splitDocuments(out []byte) [][]byte {
+	var docs [][]byte
+	var current bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimRight(line, " ") == "---" {
+			if current.Len() > 0 {
+				docs = append(docs, append([]byte(nil), current.Bytes()...))
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteString(line)
+		current.WriteByte('\n')
+	}
+	if current.Len() > 0 {
+		docs = append(docs, current.Bytes())
+	}
+	return docs
+}
+
+// templateSource returns the file named by the "# Source:" comment helm
+// template writes above each document, or chart when there is none. The
+// comment starts with the chart's name, which is replaced by its directory
+// for a local chart.
+func templateSource(doc []byte, chart string) string {
+	for _, line := range strings.Split(string(doc), "\n") {
+		source, ok := strings.CutPrefix(strings.TrimSpace(line), "# Source: ")
+		if !ok {
+			continue
+		}
+		if _, rest, ok := strings.Cut(source, "/"); ok && isLocalChart(chart) {
+			return filepath.Join(chart, rest)
+		}
+		return source
+	}
+	return chart
+}
+
+// isLocalChart reports whether chart is a directory rather than a
+// repo/name reference or URL, which helm checks first too
+func isLocalChart(chart string) bool {
+	info, err := os.Stat(chart)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,96 @@
+package lint
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBinary writes a shell script standing in for helm or kustomize that
+// prints its arguments to args.txt and testdata/rendered.yaml to stdout
+func fakeBinary(t *testing.T, name string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell to run fake renderers")
+	}
+	dir := t.TempDir()
+	rendered, err := filepath.Abs("testdata/rendered.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho \"$@\" >> " + filepath.Join(dir, "args.txt") + "\ncat " + rendered + "\n"
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRenderer_Chart(t *testing.T) {
+	helm := fakeBinary(t, "helm")
+	renderer := &Renderer{Helm: helm}
+	chart := HelmChart{Chart: "testdata/chart", Release: "web", Namespace: "shop", Values: []string{"values.yaml"}, Set: []string{"replicas=2"}}
+
+	var all []Object
+	for _, env := range []string{"staging=values-staging.yaml", "prod=values-prod.yaml,values-eu.yaml"} {
+		environment, err := ParseEnvironment(env)
+		if err != nil {
+			t.Fatalf("ParseEnvironment failed: %v", err)
+		}
+		objects, _, err := renderer.Chart(context.Background(), chart, environment)
+		if err != nil {
+			t.Fatalf("Chart failed: %v", err)
+		}
+		if len(objects) != 2 || objects[0].Environment != environment.Name {
+			t.Fatalf("Expected 2 objects rendered for %s, got %+v", environment.Name, objects)
+		}
+		if want := filepath.Join("testdata", "chart", "templates", "deployment.yaml"); objects[0].Source != want {
+			t.Errorf("Expected the deployment sourced from %s, got %s", want, objects[0].Source)
+		}
+		all = append(all, objects...)
+	}
+
+	args, err := os.ReadFile(filepath.Join(filepath.Dir(helm), "args.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "template web testdata/chart --namespace shop --values values.yaml --values values-prod.yaml --values values-eu.yaml --set replicas=2"; !strings.Contains(string(args), want) {
+		t.Errorf("Expected helm to be run as %q, got:\n%s", want, args)
+	}
+
+	events := Events(all, "default", time.Now())
+	uids := make(map[string]bool)
+	for _, e := range events {
+		uids[e.UID] = true
+	}
+	if len(uids) != len(events) {
+		t.Errorf("Expected environments to keep resources apart, got %d UIDs for %d events", len(uids), len(events))
+	}
+
+	if _, err := ParseEnvironment("prod"); err == nil {
+		t.Error("Expected an environment without value files to be rejected")
+	}
+}
+
+func TestRenderer_Kustomization(t *testing.T) {
+	kubectl := fakeBinary(t, "kubectl")
+	renderer := &Renderer{Kustomize: "akari-missing-kustomize", Kubectl: kubectl}
+
+	objects, _, err := renderer.Kustomization(context.Background(), "overlays/prod")
+	if err != nil {
+		t.Fatalf("Expected kubectl kustomize as a fallback, got %v", err)
+	}
+	if len(objects) != 2 || objects[0].Source != filepath.Join("overlays/prod", "kustomization.yaml") {
+		t.Errorf("Expected 2 objects sourced from the kustomization, got %+v", objects)
+	}
+
+	renderer.Kubectl = "akari-missing-kubectl"
+	if _, _, err := renderer.Kustomization(context.Background(), "overlays/prod"); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("Expected a missing binary to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// Actor is recorded on synthetic usage events
+	Actor = "metrics-server"
+	// Kind of the synthetic resources holding pod usage
+	Kind = "PodUsage"
+
+	DefaultInterval = 60 * time.Second
+	// DefaultWindow is how far back peak usage is remembered. A pod is only
+	// over-provisioned if even its peak stays well below its requests.
+	DefaultWindow = time.Hour
+
+	podMetricsPath = "/apis/metrics.k8s.io/v1beta1/pods"
+)
+
+// Fields recorded on PodUsage resources. Ratios compare the peak usage
+// within the window to the pod's requests and are omitted when the pod
+// requests nothing.
+const (
+	FieldCPUMillis          = "usage.cpuMillis"
+	FieldMemoryBytes        = "usage.memoryBytes"
+	FieldCPURequestRatio    = "usage.cpuRequestRatio"
+	FieldMemoryRequestRatio = "usage.memoryRequestRatio"
+)
+
+// PodMetrics is the current usage of one pod summed over its containers
+type PodMetrics struct {
+	Namespace   string
+	Name        string
+	CPUMillis   int64
+	MemoryBytes int64
+}
+
+// Source reports current pod usage
+type Source interface {
+	PodMetrics(ctx context.Context) ([]PodMetrics, error)
+}
+
+// MetricsServerSource reads the metrics.k8s.io API served by metrics-server
+type MetricsServerSource struct {
+	client rest.Interface
+}
+
+func NewMetricsServerSource(config *rest.Config) (*MetricsServerSource, error) {
+	config = rest.CopyConfig(config)
+	config.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+	client, err := rest.UnversionedRESTClientFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics client: %w", err)
+	}
+	return &MetricsServerSource{client: client}, nil
+}
+
+type podMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Containers []struct {
+			Usage map[string]string `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+func (s *MetricsServerSource) PodMetrics(ctx context.Context) ([]PodMetrics, error) {
+	body, err := s.client.Get().AbsPath(podMetricsPath).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod metrics: %w", err)
+	}
+	return ParsePodMetrics(body)
+}
+
+// ParsePodMetrics decodes a metrics.k8s.io PodMetricsList
+func ParsePodMetrics(body []byte) ([]PodMetrics, error) {
+	var list podMetricsList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("invalid pod metrics: %w", err)
+	}
+
+	result := make([]PodMetrics, 0, len(list.Items))
+	for _, item := range list.Items {
+		m := PodMetrics{Namespace: item.Metadata.Namespace, Name: item.Metadata.Name}
+		for _, c := range item.Containers {
+			if q, err := resource.ParseQuantity(c.Usage["cpu"]); err == nil {
+				m.CPUMillis += q.MilliValue()
+			}
+			if q, err := resource.ParseQuantity(c.Usage["memory"]); err == nil {
+				m.MemoryBytes += q.Value()
+			}
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+type sample struct {
+	at          time.Time
+	cpuMillis   int64
+	memoryBytes int64
+}
+
+// Collector records a PodUsage resource per pod, which the
+// pod_cpu_request_utilized and pod_memory_request_utilized invariants
+// evaluate. Requests come from the pod's spec.requests.* fields in the store.
+type Collector struct {
+	store    state.StateStore
+	source   Source
+	interval time.Duration
+	window   time.Duration
+
+	mu      sync.Mutex
+	samples map[string][]sample // pod UID -> samples within the window
+}
+
+func NewCollector(store state.StateStore, source Source, interval, window time.Duration) *Collector {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Collector{
+		store:    store,
+		source:   source,
+		interval: interval,
+		window:   window,
+		samples:  make(map[string][]sample),
+	}
+}
+
+// Run collects on every interval until ctx is cancelled
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.CollectOnce(ctx); err != nil {
+			log.Printf("Metrics collection failed: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// CollectOnce records the usage of every pod known to both the store and
+// the metrics source
+func (c *Collector) CollectOnce(ctx context.Context) error {
+	metrics, err := c.source.PodMetrics(ctx)
+	if err != nil {
+		return err
+	}
+
+	pods := make(map[string]types.StateEvent)
+	for _, pod := range c.store.GetLatestByKind("Pod") {
+		pods[pod.Namespace+"/"+pod.Name] = pod
+	}
+
+	now := time.Now()
+	live := make(map[string]bool, len(metrics))
+	for _, m := range metrics {
+		pod, ok := pods[m.Namespace+"/"+m.Name]
+		if !ok {
+			continue
+		}
+		live[pod.UID] = true
+		event := c.usageEvent(pod, m, now)
+		if err := c.store.Record(event); err != nil {
+			log.Printf("Failed to record usage of %s/%s: %v", m.Namespace, m.Name, err)
+		}
+	}
+
+	// Forget pods that have gone away
+	c.mu.Lock()
+	for uid := range c.samples {
+		if !live[uid] {
+			delete(c.samples, uid)
+		}
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Collector) usageEvent(pod types.StateEvent, m PodMetrics, now time.Time) types.StateEvent {
+	peakCPU, peakMemory, covered := c.observe(pod.UID, sample{at: now, cpuMillis: m.CPUMillis, memoryBytes: m.MemoryBytes})
+
+	event := types.StateEvent{
+		UID:       "usage:" + pod.UID,
+		Kind:      Kind,
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Labels:    pod.Labels,
+		Tier:      pod.Tier,
+		Version:   strconv.FormatInt(now.UnixNano(), 10),
+		Timestamp: now,
+		FieldDiff: map[string]interface{}{
+			FieldCPUMillis:   m.CPUMillis,
+			FieldMemoryBytes: m.MemoryBytes,
+		},
+		Actor:             Actor,
+		CreationTimestamp: pod.CreationTimestamp,
+	}
+	if deployer, ok := pod.FieldDiff[watcher.FieldDeployedBy]; ok {
+		event.FieldDiff[watcher.FieldDeployedBy] = deployer
+	}
+	if !covered {
+		return event
+	}
+	if request, ok := number(pod.FieldDiff[watcher.FieldCPURequestMillis]); ok && request > 0 {
+		event.FieldDiff[FieldCPURequestRatio] = float64(peakCPU) / request
+	}
+	if request, ok := number(pod.FieldDiff[watcher.FieldMemoryRequestBytes]); ok && request > 0 {
+		event.FieldDiff[FieldMemoryRequestRatio] = float64(peakMemory) / request
+	}
+	return event
+}
+
+// observe adds s to the pod's history and returns the peak usage within
+// the window. covered is false until the history spans half the window, so
+// a pod is not judged on the few samples taken after it or akari started.
+func (c *Collector) observe(uid string, s sample) (cpuMillis, memoryBytes int64, covered bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	kept := c.samples[uid][:0]
+	for _, old := range c.samples[uid] {
+		if s.at.Sub(old.at) < c.window {
+			kept = append(kept, old)
+		}
+	}
+	kept = append(kept, s)
+	c.samples[uid] = kept
+
+	for _, k := range kept {
+		cpuMillis = max(cpuMillis, k.cpuMillis)
+		memoryBytes = max(memoryBytes, k.memoryBytes)
+	}
+	return cpuMillis, memoryBytes, s.at.Sub(kept[0].at) >= c.window/2
+}
+
+// number accepts the numeric types a field takes in memory and after a
+// JSON round trip through PostgreSQL
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
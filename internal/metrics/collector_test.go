@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+type staticSource []PodMetrics
+
+func (s staticSource) PodMetrics(ctx context.Context) ([]PodMetrics, error) {
+	return s, nil
+}
+
+func TestParsePodMetrics(t *testing.T) {
+	body := []byte(`{"items":[{"metadata":{"name":"web","namespace":"default"},
+		"containers":[{"usage":{"cpu":"250m","memory":"64Mi"}},{"usage":{"cpu":"1500000n","memory":"1Ki"}}]}]}`)
+
+	pods, err := ParsePodMetrics(body)
+	if err != nil {
+		t.Fatalf("ParsePodMetrics failed: %v", err)
+	}
+	if len(pods) != 1 {
+		t.Fatalf("Expected 1 pod, got %d", len(pods))
+	}
+	if pods[0].CPUMillis != 252 || pods[0].MemoryBytes != 64<<20+1024 {
+		t.Errorf("Unexpected usage: %+v", pods[0])
+	}
+}
+
+func TestCollector_RecordsPeakRatios(t *testing.T) {
+	store := state.NewMemoryStore()
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{
+			watcher.FieldCPURequestMillis:   int64(1000),
+			watcher.FieldMemoryRequestBytes: int64(1 << 30),
+			watcher.FieldDeployedBy:         "helm",
+		},
+	})
+
+	c := NewCollector(store, staticSource{{Namespace: "default", Name: "web", CPUMillis: 50, MemoryBytes: 1 << 28}}, time.Minute, time.Hour)
+	start := time.Now()
+	c.observe("pod-1", sample{at: start.Add(-45 * time.Minute), cpuMillis: 100})
+
+	if err := c.CollectOnce(context.Background()); err != nil {
+		t.Fatalf("CollectOnce failed: %v", err)
+	}
+
+	usage, ok := store.GetByUID("usage:pod-1")
+	if !ok {
+		t.Fatal("Expected a PodUsage resource")
+	}
+	if usage.Kind != Kind || usage.FieldDiff[watcher.FieldDeployedBy] != "helm" {
+		t.Errorf("Unexpected usage event: %+v", usage)
+	}
+	if ratio := usage.FieldDiff[FieldCPURequestRatio]; ratio != 0.1 {
+		t.Errorf("Expected the CPU ratio to use the peak sample, got %v", ratio)
+	}
+	if ratio := usage.FieldDiff[FieldMemoryRequestRatio]; ratio != 0.25 {
+		t.Errorf("Expected a memory ratio of 0.25, got %v", ratio)
+	}
+}
+
+func TestCollector_WaitsForHistory(t *testing.T) {
+	store := state.NewMemoryStore()
+	store.Record(types.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{watcher.FieldCPURequestMillis: int64(1000)},
+	})
+
+	c := NewCollector(store, staticSource{{Namespace: "default", Name: "web", CPUMillis: 1}}, time.Minute, time.Hour)
+	c.CollectOnce(context.Background())
+
+	usage, _ := store.GetByUID("usage:pod-1")
+	if _, ok := usage.FieldDiff[FieldCPURequestRatio]; ok {
+		t.Error("Expected no ratio before the window is half covered")
+	}
+	if usage.FieldDiff[FieldCPUMillis] != int64(1) {
+		t.Errorf("Expected current usage to be recorded, got %v", usage.FieldDiff[FieldCPUMillis])
+	}
+}
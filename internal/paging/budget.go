@@ -0,0 +1,235 @@
+package paging
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+)
+
+const (
+	BudgetPerTeam      = "team"
+	BudgetPerNamespace = "namespace"
+
+	// DigestInvariantID identifies the incidents summarizing the triggers
+	// held back by a budget
+	DigestInvariantID = "notification_digest"
+
+	// digestListed bounds the violations a digest names
+	digestListed = 20
+	// digestInterval is how often ended windows are checked for digests
+	digestInterval = time.Minute
+)
+
+// Budget caps the incidents a routing target is paged with per team or
+// namespace in each window. Triggers over the limit are held back and sent
+// as one digest when the window ends.
+type Budget struct {
+	Per    string       `json:"per"`
+	Limit  int          `json:"limit"`
+	Window dsl.Duration `json:"window"`
+}
+
+// ParseBudget parses "team:10/1h", at most 10 incidents per team an hour
+func ParseBudget(s string) (Budget, error) {
+	per, rest, ok := strings.Cut(s, ":")
+	limit, window, ok2 := strings.Cut(rest, "/")
+	if !ok || !ok2 {
+		return Budget{}, fmt.Errorf("invalid budget %q, want team|namespace:limit/window", s)
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil {
+		return Budget{}, fmt.Errorf("invalid budget limit %q", limit)
+	}
+	d, err := dsl.ParseDuration(window)
+	if err != nil {
+		return Budget{}, fmt.Errorf("invalid budget window %q: %w", window, err)
+	}
+	budget := Budget{Per: per, Limit: n, Window: dsl.Duration(d)}
+	return budget, budget.Validate()
+}
+
+func (b Budget) Validate() error {
+	if b.Per != BudgetPerTeam && b.Per != BudgetPerNamespace {
+		return fmt.Errorf("budget per must be team or namespace, got %q", b.Per)
+	}
+	if b.Limit < 1 || b.Window <= 0 {
+		return fmt.Errorf("budget needs a positive limit and window")
+	}
+	return nil
+}
+
+// group is the team or namespace an incident counts against
+func (b Budget) group(incident Incident) string {
+	if b.Per == BudgetPerTeam {
+		if incident.Team == "" {
+			return "unassigned"
+		}
+		return incident.Team
+	}
+	namespace, _, found := strings.Cut(incident.Source, "/")
+	if !found || namespace == "" {
+		return "cluster-scoped"
+	}
+	return namespace
+}
+
+// budgetWindow counts the pages of one client and group in one window
+type budgetWindow struct {
+	group    string
+	start    time.Time
+	paged    int
+	overflow []Incident
+}
+
+// SetBudget limits the incidents the named client is paged with
+func (p *Pager) SetBudget(client string, budget Budget) {
+	p.budgetMu.Lock()
+	defer p.budgetMu.Unlock()
+	p.budgets[client] = budget
+}
+
+// budgeted returns the actions to send to a client in place of a: none
+// for triggers over budget and for the later actions of their incidents,
+// the digest when a window ends, and its resolution once every incident it
+// summarized has resolved
+func (p *Pager) budgeted(client string, a action, now time.Time) []action {
+	p.budgetMu.Lock()
+	defer p.budgetMu.Unlock()
+
+	budget, ok := p.budgets[client]
+	if !ok {
+		return []action{a}
+	}
+	heldKey := client + "|" + a.incident.Key
+	switch a.typ {
+	case actionTrigger:
+		var actions []action
+		windowKey := client + "|" + budget.group(a.incident)
+		w := p.windows[windowKey]
+		if w != nil && !now.Before(w.start.Add(time.Duration(budget.Window))) {
+			if digest, ok := p.closeWindow(client, budget, w, now); ok {
+				actions = append(actions, digest)
+			}
+			w = nil
+		}
+		if w == nil {
+			w = &budgetWindow{group: budget.group(a.incident), start: now}
+			p.windows[windowKey] = w
+		}
+		if w.paged < budget.Limit {
+			w.paged++
+			return append(actions, a)
+		}
+		w.overflow = append(w.overflow, a.incident)
+		p.held[heldKey] = ""
+		p.suppressed.Add(1)
+		return actions
+	case actionAcknowledge:
+		if _, held := p.held[heldKey]; held {
+			return nil
+		}
+	case actionResolve:
+		digestKey, held := p.held[heldKey]
+		if !held {
+			break
+		}
+		delete(p.held, heldKey)
+		if digestKey == "" {
+			if w := p.windows[client+"|"+budget.group(a.incident)]; w != nil {
+				for i, incident := range w.overflow {
+					if incident.Key == a.incident.Key {
+						w.overflow = append(w.overflow[:i], w.overflow[i+1:]...)
+						break
+					}
+				}
+			}
+			return nil
+		}
+		members := p.digests[client+"|"+digestKey]
+		delete(members, a.incident.Key)
+		if len(members) > 0 {
+			return nil
+		}
+		delete(p.digests, client+"|"+digestKey)
+		return []action{{typ: actionResolve, incident: Incident{Key: digestKey}}}
+	}
+	return []action{a}
+}
+
+// endedDigests closes the windows that ended before now and returns their
+// digests by client
+func (p *Pager) endedDigests(now time.Time) map[string][]action {
+	p.budgetMu.Lock()
+	defer p.budgetMu.Unlock()
+
+	digests := make(map[string][]action)
+	for windowKey, w := range p.windows {
+		client, _, _ := strings.Cut(windowKey, "|")
+		budget, ok := p.budgets[client]
+		if !ok || now.Before(w.start.Add(time.Duration(budget.Window))) {
+			continue
+		}
+		if digest, ok := p.closeWindow(client, budget, w, now); ok {
+			digests[client] = append(digests[client], digest)
+		}
+		delete(p.windows, windowKey)
+	}
+	return digests
+}
+
+// closeWindow turns the triggers a window held back into one digest
+// trigger. Callers hold p.budgetMu.
+func (p *Pager) closeWindow(client string, budget Budget, w *budgetWindow, now time.Time) (action, bool) {
+	if len(w.overflow) == 0 {
+		return action{}, false
+	}
+	digest := newDigest(budget, w, now)
+	members := make(map[string]bool, len(w.overflow))
+	for _, incident := range w.overflow {
+		members[incident.Key] = true
+		p.held[client+"|"+incident.Key] = digest.Key
+	}
+	p.digests[client+"|"+digest.Key] = members
+	p.digestsSent.Add(1)
+	return action{typ: actionTrigger, incident: digest}, true
+}
+
+// newDigest summarizes the incidents a window held back, at the highest
+// severity and urgency among them
+func newDigest(budget Budget, w *budgetWindow, now time.Time) Incident {
+	digest := Incident{
+		Key:         fmt.Sprintf("%s|%s:%s|%d", DigestInvariantID, budget.Per, w.group, w.start.Unix()),
+		Summary:     fmt.Sprintf("%d more violations for %s %s over its paging budget of %d per %s", len(w.overflow), budget.Per, w.group, budget.Limit, time.Duration(budget.Window)),
+		Source:      w.group,
+		InvariantID: DigestInvariantID,
+		Severity:    dsl.Warning,
+		Urgency:     dsl.UrgencyLow,
+		TriggeredAt: now,
+		Details: map[string]string{
+			"count":        strconv.Itoa(len(w.overflow)),
+			"window_start": w.start.UTC().Format(time.RFC3339),
+		},
+	}
+	if budget.Per == BudgetPerTeam {
+		digest.Team = w.group
+	}
+	summaries := make([]string, 0, min(len(w.overflow), digestListed))
+	for _, incident := range w.overflow {
+		if severityRank[incident.Severity] > severityRank[digest.Severity] {
+			digest.Severity = incident.Severity
+		}
+		if incident.Urgency == dsl.UrgencyHigh {
+			digest.Urgency = dsl.UrgencyHigh
+		}
+		if len(summaries) < digestListed {
+			summaries = append(summaries, incident.Summary)
+		}
+	}
+	digest.Details["violations"] = strings.Join(summaries, "\n")
+	return digest
+}
+
+var severityRank = map[dsl.Severity]int{dsl.Warning: 1, dsl.Degraded: 2, dsl.Critical: 3}
@@ -0,0 +1,138 @@
+package paging
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is how often an action is sent to a service
+	// before it is dead-lettered
+	DefaultMaxAttempts = 3
+	// DefaultRetryBackoff is the wait before the second attempt; it
+	// doubles for each further one
+	DefaultRetryBackoff = 2 * time.Second
+	// DefaultDeliveryRetention is how long delivery attempts are kept
+	DefaultDeliveryRetention = 7 * 24 * time.Hour
+)
+
+// ErrDeadLetterNotFound is returned when replaying a dead letter that
+// doesn't exist or was already delivered
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// Delivery is one attempt to send an incident action to a service. Key
+// is the incident key, the violation fingerprint.
+type Delivery struct {
+	Key     string    `json:"key"`
+	Client  string    `json:"client"`
+	Action  string    `json:"action"`
+	Attempt int       `json:"attempt"`
+	Error   string    `json:"error,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// DeadLetter is an action every attempt to send failed for, kept until it
+// is replayed
+type DeadLetter struct {
+	ID        int64     `json:"id"`
+	Client    string    `json:"client"`
+	Action    string    `json:"action"`
+	Incident  Incident  `json:"incident"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// DeliveryStore keeps the delivery attempts of each incident and the
+// dead letters
+type DeliveryStore interface {
+	// RecordDelivery stores an attempt and drops those older than retention
+	RecordDelivery(d Delivery, retention time.Duration) error
+	// Deliveries returns the attempts for an incident key, oldest first
+	Deliveries(key string) ([]Delivery, error)
+	// SaveDeadLetter stores a new dead letter, assigning its ID, or
+	// updates the one with the letter's ID
+	SaveDeadLetter(letter DeadLetter) (DeadLetter, error)
+	// DeadLetters returns the dead letters, oldest first
+	DeadLetters() ([]DeadLetter, error)
+	DeleteDeadLetter(id int64) error
+}
+
+// MemoryDeliveryStore keeps deliveries in process, for deployments without
+// PostgreSQL
+type MemoryDeliveryStore struct {
+	mu         sync.Mutex
+	deliveries map[string][]Delivery
+	letters    map[int64]DeadLetter
+	nextID     int64
+}
+
+func NewMemoryDeliveryStore() *MemoryDeliveryStore {
+	return &MemoryDeliveryStore{
+		deliveries: make(map[string][]Delivery),
+		letters:    make(map[int64]DeadLetter),
+	}
+}
+
+func (s *MemoryDeliveryStore) RecordDelivery(d Delivery, retention time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := time.Now().Add(-retention)
+	for key, attempts := range s.deliveries {
+		kept := attempts[:0]
+		for _, a := range attempts {
+			if !a.At.Before(cutoff) {
+				kept = append(kept, a)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.deliveries, key)
+		} else {
+			s.deliveries[key] = kept
+		}
+	}
+	s.deliveries[d.Key] = append(s.deliveries[d.Key], d)
+	return nil
+}
+
+func (s *MemoryDeliveryStore) Deliveries(key string) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Delivery(nil), s.deliveries[key]...), nil
+}
+
+func (s *MemoryDeliveryStore) SaveDeadLetter(letter DeadLetter) (DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if letter.ID == 0 {
+		s.nextID++
+		letter.ID = s.nextID
+	} else if _, ok := s.letters[letter.ID]; !ok {
+		return letter, ErrDeadLetterNotFound
+	}
+	s.letters[letter.ID] = letter
+	return letter, nil
+}
+
+func (s *MemoryDeliveryStore) DeadLetters() ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	letters := make([]DeadLetter, 0, len(s.letters))
+	for _, letter := range s.letters {
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i].ID < letters[j].ID })
+	return letters, nil
+}
+
+func (s *MemoryDeliveryStore) DeleteDeadLetter(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.letters[id]; !ok {
+		return ErrDeadLetterNotFound
+	}
+	delete(s.letters, id)
+	return nil
+}
@@ -0,0 +1,88 @@
+package paging
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aonescu/akari/internal/dsl"
+)
+
+// OpsgenieAPIURL is the default Opsgenie API; EU accounts use
+// https://api.eu.opsgenie.com
+const OpsgenieAPIURL = "https://api.opsgenie.com"
+
+// Opsgenie manages alerts through the Alert API, using the incident key as
+// the alert alias. Urgency maps to alert priority.
+type Opsgenie struct {
+	APIKey string
+	URL    string
+	Client *http.Client
+}
+
+func NewOpsgenie(apiKey string) *Opsgenie {
+	return &Opsgenie{APIKey: apiKey, URL: OpsgenieAPIURL}
+}
+
+func (og *Opsgenie) Name() string { return "opsgenie" }
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Priority    string            `json:"priority"`
+	Source      string            `json:"source"`
+	Entity      string            `json:"entity,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+func (og *Opsgenie) Trigger(ctx context.Context, incident Incident) error {
+	priority := "P3"
+	if incident.Urgency == dsl.UrgencyHigh {
+		priority = "P1"
+	}
+	details := map[string]string{"invariant": incident.InvariantID}
+	for k, v := range incident.Details {
+		details[k] = v
+	}
+	if incident.ResponsibleActor != "" {
+		details["responsible_actor"] = incident.ResponsibleActor
+	}
+	if incident.RunbookURL != "" {
+		details["runbook_url"] = incident.RunbookURL
+	}
+
+	return postJSON(ctx, og.Client, og.endpoint("/v2/alerts"), og.header(), opsgenieAlert{
+		Message:     truncate(incident.Summary, 130),
+		Alias:       truncate(incident.Key, 512),
+		Description: incident.Summary,
+		Priority:    priority,
+		Source:      "akari",
+		Entity:      incident.Source,
+		Tags:        []string{incident.InvariantID, string(incident.Severity)},
+		Details:     details,
+	})
+}
+
+func (og *Opsgenie) Acknowledge(ctx context.Context, key string) error {
+	return og.alertAction(ctx, key, "acknowledge")
+}
+
+func (og *Opsgenie) Resolve(ctx context.Context, key string) error {
+	return og.alertAction(ctx, key, "close")
+}
+
+func (og *Opsgenie) alertAction(ctx context.Context, key, action string) error {
+	path := "/v2/alerts/" + url.PathEscape(truncate(key, 512)) + "/" + action + "?identifierType=alias"
+	return postJSON(ctx, og.Client, og.endpoint(path), og.header(), map[string]string{"source": "akari"})
+}
+
+func (og *Opsgenie) endpoint(path string) string {
+	return strings.TrimSuffix(og.URL, "/") + path
+}
+
+func (og *Opsgenie) header() http.Header {
+	return http.Header{"Authorization": []string{"GenieKey " + og.APIKey}}
+}
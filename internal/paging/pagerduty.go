@@ -0,0 +1,95 @@
+package paging
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+)
+
+// PagerDutyEventsURL is the Events API v2 endpoint
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty sends incidents to a service integration through Events API
+// v2. Urgency maps to the event severity, which PagerDuty turns into
+// incident urgency when the service uses severity-based urgency rules.
+type PagerDuty struct {
+	RoutingKey string
+	URL        string
+	Client     *http.Client
+}
+
+func NewPagerDuty(routingKey string) *PagerDuty {
+	return &PagerDuty{RoutingKey: routingKey, URL: PagerDutyEventsURL}
+}
+
+func (pd *PagerDuty) Name() string { return "pagerduty" }
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp,omitempty"`
+	Component     string            `json:"component,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+func (pd *PagerDuty) Trigger(ctx context.Context, incident Incident) error {
+	severity := "warning"
+	if incident.Urgency == dsl.UrgencyHigh {
+		severity = "critical"
+	}
+	details := map[string]string{"invariant": incident.InvariantID}
+	for k, v := range incident.Details {
+		details[k] = v
+	}
+	if incident.ResponsibleActor != "" {
+		details["responsible_actor"] = incident.ResponsibleActor
+	}
+
+	event := pagerDutyEvent{
+		RoutingKey:  pd.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    incident.Key,
+		Payload: &pagerDutyPayload{
+			Summary:       truncate(incident.Summary, 1024),
+			Source:        incident.Source,
+			Severity:      severity,
+			Timestamp:     incident.TriggeredAt.UTC().Format(time.RFC3339),
+			Component:     incident.Source,
+			Class:         incident.InvariantID,
+			CustomDetails: details,
+		},
+	}
+	if incident.RunbookURL != "" {
+		event.Links = []pagerDutyLink{{Href: incident.RunbookURL, Text: "Runbook"}}
+	}
+	return pd.send(ctx, event)
+}
+
+func (pd *PagerDuty) Acknowledge(ctx context.Context, key string) error {
+	return pd.send(ctx, pagerDutyEvent{RoutingKey: pd.RoutingKey, EventAction: "acknowledge", DedupKey: key})
+}
+
+func (pd *PagerDuty) Resolve(ctx context.Context, key string) error {
+	return pd.send(ctx, pagerDutyEvent{RoutingKey: pd.RoutingKey, EventAction: "resolve", DedupKey: key})
+}
+
+func (pd *PagerDuty) send(ctx context.Context, event pagerDutyEvent) error {
+	return postJSON(ctx, pd.Client, pd.URL, nil, event)
+}
@@ -0,0 +1,586 @@
+package paging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+)
+
+const (
+	DefaultQueueSize = 1000
+	requestTimeout   = 10 * time.Second
+)
+
+// ErrNotOpen is returned when acknowledging an incident the pager did not
+// trigger or that has already resolved
+var ErrNotOpen = errors.New("no open incident for fingerprint")
+
+// DefaultUrgency pages on-call for critical violations, files degraded ones
+// as low urgency and doesn't page for warnings
+var DefaultUrgency = map[dsl.Severity]dsl.Urgency{
+	dsl.Critical: dsl.UrgencyHigh,
+	dsl.Degraded: dsl.UrgencyLow,
+	dsl.Warning:  dsl.UrgencyNone,
+}
+
+// Incident is a violation as reported to an incident management service.
+// Key is the violation fingerprint, which deduplicates triggers and
+// addresses the incident when acknowledging or resolving it.
+type Incident struct {
+	Key              string            `json:"key"`
+	Summary          string            `json:"summary"`
+	Source           string            `json:"source"`
+	InvariantID      string            `json:"invariant_id"`
+	Team             string            `json:"team,omitempty"`
+	Severity         dsl.Severity      `json:"severity"`
+	Urgency          dsl.Urgency       `json:"urgency"`
+	ResponsibleActor string            `json:"responsible_actor,omitempty"`
+	RunbookURL       string            `json:"runbook_url,omitempty"`
+	Details          map[string]string `json:"details,omitempty"`
+	CorrelationID    string            `json:"correlation_id,omitempty"`
+	TriggeredAt      time.Time         `json:"triggered_at"`
+	Acknowledged     bool              `json:"acknowledged"`
+}
+
+// Client drives the incident lifecycle in one service
+type Client interface {
+	Name() string
+	Trigger(ctx context.Context, incident Incident) error
+	Acknowledge(ctx context.Context, key string) error
+	Resolve(ctx context.Context, key string) error
+}
+
+type actionType string
+
+const (
+	actionTrigger     actionType = "trigger"
+	actionAcknowledge actionType = "acknowledge"
+	actionResolve     actionType = "resolve"
+)
+
+type action struct {
+	typ      actionType
+	incident Incident
+}
+
+// Pager turns violation transitions into incidents: opened violations
+// trigger, resolved ones resolve. Calls to the services are queued so a
+// slow API never blocks evaluation.
+type Pager struct {
+	clients []Client
+	lookup  func(id string) (dsl.Invariant, bool)
+	queue   chan action
+
+	mu      sync.Mutex
+	urgency map[dsl.Severity]dsl.Urgency
+	open    map[string]Incident
+
+	deliveries  DeliveryStore
+	maxAttempts int
+	backoff     time.Duration
+
+	budgetMu sync.Mutex
+	budgets  map[string]Budget        // client name -> budget
+	windows  map[string]*budgetWindow // client|group -> current window
+	held     map[string]string        // client|incident key -> digest key, "" until sent
+	digests  map[string]map[string]bool
+
+	sent         atomic.Uint64
+	failed       atomic.Uint64
+	dropped      atomic.Uint64
+	deadLettered atomic.Uint64
+	suppressed   atomic.Uint64
+	digestsSent  atomic.Uint64
+}
+
+// PagerStats is a point-in-time snapshot of pager metrics
+type PagerStats struct {
+	Open    int    `json:"open"`
+	Depth   int    `json:"depth"`
+	Sent    uint64 `json:"sent"`
+	Failed  uint64 `json:"failed"`
+	Dropped uint64 `json:"dropped"`
+	// DeadLettered counts actions stored for replay after every attempt
+	// failed
+	DeadLettered uint64 `json:"dead_lettered"`
+	// Suppressed counts triggers held back by a budget, and Digests the
+	// digests summarizing them
+	Suppressed uint64 `json:"suppressed"`
+	Digests    uint64 `json:"digests"`
+}
+
+// NewPager creates a pager that reports to every client. lookup resolves
+// invariant definitions for their urgency overrides.
+func NewPager(lookup func(id string) (dsl.Invariant, bool), clients ...Client) *Pager {
+	urgency := make(map[dsl.Severity]dsl.Urgency, len(DefaultUrgency))
+	for severity, u := range DefaultUrgency {
+		urgency[severity] = u
+	}
+	return &Pager{
+		clients: clients,
+		lookup:  lookup,
+		queue:   make(chan action, DefaultQueueSize),
+		urgency: urgency,
+		open:    make(map[string]Incident),
+
+		deliveries:  NewMemoryDeliveryStore(),
+		maxAttempts: DefaultMaxAttempts,
+		backoff:     DefaultRetryBackoff,
+
+		budgets: make(map[string]Budget),
+		windows: make(map[string]*budgetWindow),
+		held:    make(map[string]string),
+		digests: make(map[string]map[string]bool),
+	}
+}
+
+// SetDeliveryStore keeps delivery attempts and dead letters in store
+// instead of in process
+func (p *Pager) SetDeliveryStore(store DeliveryStore) {
+	p.deliveries = store
+}
+
+// SetRetry changes how often an action is attempted before it is
+// dead-lettered, and the wait before the first retry
+func (p *Pager) SetRetry(maxAttempts int, backoff time.Duration) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	p.maxAttempts = maxAttempts
+	p.backoff = backoff
+}
+
+// SetUrgency changes the urgency of violations of a severity whose
+// invariant doesn't set its own
+func (p *Pager) SetUrgency(severity dsl.Severity, urgency dsl.Urgency) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.urgency[severity] = urgency
+}
+
+// ParseUrgencyMapping parses "critical:high,degraded:low,warning:none"
+func ParseUrgencyMapping(s string) (map[dsl.Severity]dsl.Urgency, error) {
+	mapping := make(map[dsl.Severity]dsl.Urgency)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		severity, urgency, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid urgency mapping %q, want severity:urgency", pair)
+		}
+		switch dsl.Urgency(urgency) {
+		case dsl.UrgencyHigh, dsl.UrgencyLow, dsl.UrgencyNone:
+		default:
+			return nil, fmt.Errorf("invalid urgency %q, want high, low or none", urgency)
+		}
+		mapping[dsl.Severity(severity)] = dsl.Urgency(urgency)
+	}
+	return mapping, nil
+}
+
+// HandleTransition is a Monitor subscriber
+func (p *Pager) HandleTransition(t engine.Transition) {
+	v := t.Violation
+	key := v.Fingerprint()
+
+	p.mu.Lock()
+	switch t.Type {
+	case engine.TransitionOpened:
+		urgency := p.urgencyFor(v)
+		if urgency == dsl.UrgencyNone {
+			p.mu.Unlock()
+			return
+		}
+		incident := newIncident(v, urgency, t.At)
+		incident.Team = p.teamFor(v)
+		p.open[key] = incident
+		p.mu.Unlock()
+		p.enqueue(action{typ: actionTrigger, incident: incident})
+	case engine.TransitionResolved:
+		incident, ok := p.open[key]
+		delete(p.open, key)
+		p.mu.Unlock()
+		if ok {
+			p.enqueue(action{typ: actionResolve, incident: incident})
+		}
+	default:
+		p.mu.Unlock()
+	}
+}
+
+// Restore marks the incidents of violations that were active before a
+// restart as open without triggering them again, so they resolve when the
+// violations do
+func (p *Pager) Restore(violations []*engine.ViolationResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, v := range violations {
+		urgency := p.urgencyFor(v)
+		if urgency == dsl.UrgencyNone {
+			continue
+		}
+		incident := newIncident(v, urgency, v.DetectedAt)
+		incident.Team = p.teamFor(v)
+		p.open[v.Fingerprint()] = incident
+	}
+}
+
+// Acknowledge acknowledges an open incident in every service
+func (p *Pager) Acknowledge(key string) error {
+	p.mu.Lock()
+	incident, ok := p.open[key]
+	if ok {
+		incident.Acknowledged = true
+		p.open[key] = incident
+	}
+	p.mu.Unlock()
+	if !ok {
+		return ErrNotOpen
+	}
+	p.enqueue(action{typ: actionAcknowledge, incident: incident})
+	return nil
+}
+
+// Open returns the incidents triggered and not yet resolved, oldest first
+func (p *Pager) Open() []Incident {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	incidents := make([]Incident, 0, len(p.open))
+	for _, incident := range p.open {
+		incidents = append(incidents, incident)
+	}
+	sort.Slice(incidents, func(i, j int) bool {
+		return incidents[i].TriggeredAt.Before(incidents[j].TriggeredAt)
+	})
+	return incidents
+}
+
+// Run sends queued actions, and the digests of budget windows as they
+// end, until ctx is cancelled
+func (p *Pager) Run(ctx context.Context) {
+	ticker := time.NewTicker(digestInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case a := <-p.queue:
+			p.send(ctx, a)
+		case now := <-ticker.C:
+			p.sendDigests(ctx, now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Pager) Stats() PagerStats {
+	p.mu.Lock()
+	open := len(p.open)
+	p.mu.Unlock()
+	return PagerStats{
+		Open:    open,
+		Depth:   len(p.queue),
+		Sent:    p.sent.Load(),
+		Failed:  p.failed.Load(),
+		Dropped: p.dropped.Load(),
+
+		DeadLettered: p.deadLettered.Load(),
+		Suppressed:   p.suppressed.Load(),
+		Digests:      p.digestsSent.Load(),
+	}
+}
+
+// teamFor is the team responsible for the violation's invariant. Callers
+// hold p.mu.
+func (p *Pager) teamFor(v *engine.ViolationResult) string {
+	if p.lookup != nil {
+		if inv, ok := p.lookup(v.InvariantID); ok {
+			return inv.Responsibility.Team
+		}
+	}
+	return ""
+}
+
+// urgencyFor prefers the invariant's own urgency over the severity
+// mapping. Callers hold p.mu.
+func (p *Pager) urgencyFor(v *engine.ViolationResult) dsl.Urgency {
+	if p.lookup != nil {
+		if inv, ok := p.lookup(v.InvariantID); ok && inv.Urgency != "" {
+			return inv.Urgency
+		}
+	}
+	if urgency, ok := p.urgency[v.Severity]; ok {
+		return urgency
+	}
+	return dsl.UrgencyLow
+}
+
+func (p *Pager) enqueue(a action) {
+	select {
+	case p.queue <- a:
+	default:
+		p.dropped.Add(1)
+		log.Printf("Paging queue full, dropping %s of %s", a.typ, a.incident.Key)
+	}
+}
+
+func (p *Pager) send(ctx context.Context, a action) {
+	now := time.Now()
+	for _, client := range p.clients {
+		for _, budgeted := range p.budgeted(client.Name(), a, now) {
+			p.deliver(ctx, client, budgeted)
+		}
+	}
+}
+
+// sendDigests sends the digests of the budget windows ended by now
+func (p *Pager) sendDigests(ctx context.Context, now time.Time) {
+	digests := p.endedDigests(now)
+	for _, client := range p.clients {
+		for _, digest := range digests[client.Name()] {
+			p.deliver(ctx, client, digest)
+		}
+	}
+}
+
+// deliver sends an action to a client, retrying with backoff, and
+// dead-letters it when every attempt fails
+func (p *Pager) deliver(ctx context.Context, client Client, a action) {
+	backoff := p.backoff
+	var err error
+	for attempt := 1; attempt <= p.maxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err = p.attempt(ctx, client, a, attempt); err == nil {
+			return
+		}
+	}
+
+	letter := DeadLetter{
+		Client:    client.Name(),
+		Action:    string(a.typ),
+		Incident:  a.incident,
+		Attempts:  p.maxAttempts,
+		LastError: err.Error(),
+		FailedAt:  time.Now(),
+	}
+	if _, err := p.deliveries.SaveDeadLetter(letter); err != nil {
+		log.Printf("Failed to dead-letter %s of %s incident %s: %v", a.typ, client.Name(), a.incident.Key, err)
+		return
+	}
+	p.deadLettered.Add(1)
+	log.Printf("Dead-lettered %s of %s incident %s%s after %d attempts", a.typ, client.Name(), a.incident.Key, correlation(a.incident), p.maxAttempts)
+}
+
+// attempt calls the client once and records the attempt
+func (p *Pager) attempt(ctx context.Context, client Client, a action, n int) error {
+	callCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	var err error
+	switch a.typ {
+	case actionTrigger:
+		err = client.Trigger(callCtx, a.incident)
+	case actionAcknowledge:
+		err = client.Acknowledge(callCtx, a.incident.Key)
+	case actionResolve:
+		err = client.Resolve(callCtx, a.incident.Key)
+	default:
+		err = fmt.Errorf("unknown action %q", a.typ)
+	}
+	cancel()
+
+	delivery := Delivery{Key: a.incident.Key, Client: client.Name(), Action: string(a.typ), Attempt: n, At: time.Now()}
+	if err != nil {
+		delivery.Error = err.Error()
+		p.failed.Add(1)
+		log.Printf("Failed to %s %s incident %s%s (attempt %d): %v", a.typ, client.Name(), a.incident.Key, correlation(a.incident), n, err)
+	} else {
+		p.sent.Add(1)
+	}
+	if recordErr := p.deliveries.RecordDelivery(delivery, DefaultDeliveryRetention); recordErr != nil {
+		log.Printf("Failed to record delivery of %s incident %s: %v", client.Name(), a.incident.Key, recordErr)
+	}
+	return err
+}
+
+// Deliveries returns the attempts to send an incident's actions, oldest
+// first
+func (p *Pager) Deliveries(key string) ([]Delivery, error) {
+	return p.deliveries.Deliveries(key)
+}
+
+// DeadLetters returns the actions awaiting replay, oldest first
+func (p *Pager) DeadLetters() ([]DeadLetter, error) {
+	return p.deliveries.DeadLetters()
+}
+
+// ReplayResult counts the outcome of a replay
+type ReplayResult struct {
+	Replayed  int `json:"replayed"`
+	Failed    int `json:"failed"`
+	Remaining int `json:"remaining"`
+}
+
+// Replay sends the dead letters with the given IDs again, or all of them
+// when ids is empty, oldest first and once each. Delivered letters are
+// removed; failed ones stay with their attempt count raised. Nothing is
+// sent when an ID is unknown.
+func (p *Pager) Replay(ctx context.Context, ids []int64) (ReplayResult, error) {
+	var result ReplayResult
+	letters, err := p.deliveries.DeadLetters()
+	if err != nil {
+		return result, err
+	}
+	selected := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+	for _, letter := range letters {
+		delete(selected, letter.ID)
+	}
+	if len(selected) > 0 {
+		return result, ErrDeadLetterNotFound
+	}
+	for _, id := range ids {
+		selected[id] = true
+	}
+	clients := make(map[string]Client, len(p.clients))
+	for _, client := range p.clients {
+		clients[client.Name()] = client
+	}
+
+	for _, letter := range letters {
+		if len(ids) > 0 && !selected[letter.ID] {
+			result.Remaining++
+			continue
+		}
+		client, ok := clients[letter.Client]
+		if !ok {
+			result.Failed++
+			result.Remaining++
+			log.Printf("Cannot replay dead letter %d: %s is no longer configured", letter.ID, letter.Client)
+			continue
+		}
+		a := action{typ: actionType(letter.Action), incident: letter.Incident}
+		if err := p.attempt(ctx, client, a, letter.Attempts+1); err != nil {
+			letter.Attempts++
+			letter.LastError = err.Error()
+			letter.FailedAt = time.Now()
+			if _, err := p.deliveries.SaveDeadLetter(letter); err != nil {
+				return result, err
+			}
+			result.Failed++
+			result.Remaining++
+			continue
+		}
+		if err := p.deliveries.DeleteDeadLetter(letter.ID); err != nil {
+			return result, err
+		}
+		result.Replayed++
+	}
+	return result, nil
+}
+
+func newIncident(v *engine.ViolationResult, urgency dsl.Urgency, at time.Time) Incident {
+	details := map[string]string{"reason": v.Reason}
+	if v.ResourceUID != "" {
+		details["resource_uid"] = v.ResourceUID
+	}
+	if v.Tier != "" {
+		details["tier"] = string(v.Tier)
+	}
+	if v.CorrelationID != "" {
+		details["correlation_id"] = v.CorrelationID
+	}
+	if len(v.Correlated) > 0 {
+		details["correlated"] = strings.Join(v.Correlated, ", ")
+	}
+	if len(v.SuspectChanges) > 0 {
+		s := v.SuspectChanges[0]
+		details["top_suspect"] = fmt.Sprintf("%s %s/%s by %s at %s", s.Kind, s.Namespace, s.Name, s.Actor, s.ChangedAt.UTC().Format(time.RFC3339))
+	}
+
+	return Incident{
+		Key:              v.Fingerprint(),
+		Summary:          fmt.Sprintf("%s violated on %s: %s", v.InvariantID, v.AffectedResource, v.Reason),
+		Source:           v.AffectedResource,
+		InvariantID:      v.InvariantID,
+		Severity:         v.Severity,
+		Urgency:          urgency,
+		ResponsibleActor: v.ResponsibleActor,
+		RunbookURL:       v.RunbookURL,
+		Details:          details,
+		CorrelationID:    v.CorrelationID,
+		TriggeredAt:      at,
+	}
+}
+
+// correlation formats an incident's correlation ID for log lines
+func correlation(incident Incident) string {
+	if incident.CorrelationID == "" {
+		return ""
+	}
+	return " (correlation " + incident.CorrelationID + ")"
+}
+
+// postJSON sends body to url and fails on any non-2xx response
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return post(ctx, client, url, header, data)
+}
+
+// post sends an encoded JSON body to url and fails on any non-2xx response
+func post(ctx context.Context, client *http.Client, url string, header http.Header, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// truncate shortens s to at most n bytes, as the services cap field sizes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
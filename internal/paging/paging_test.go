@@ -0,0 +1,430 @@
+package paging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+)
+
+type recordingClient struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (c *recordingClient) Name() string { return "recording" }
+
+func (c *recordingClient) record(call string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, call)
+	return nil
+}
+
+func (c *recordingClient) Trigger(_ context.Context, incident Incident) error {
+	return c.record("trigger " + incident.Key + " " + string(incident.Urgency))
+}
+
+func (c *recordingClient) Acknowledge(_ context.Context, key string) error {
+	return c.record("acknowledge " + key)
+}
+
+func (c *recordingClient) Resolve(_ context.Context, key string) error {
+	return c.record("resolve " + key)
+}
+
+func (c *recordingClient) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.calls...)
+}
+
+func waitForCalls(t *testing.T, c *recordingClient, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if calls := c.Calls(); len(calls) >= n {
+			return calls
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d calls, got %v", n, c.Calls())
+	return nil
+}
+
+func TestPager_Lifecycle(t *testing.T) {
+	invariants := map[string]dsl.Invariant{
+		"quiet_check": {ID: "quiet_check", Severity: dsl.Critical, Urgency: dsl.UrgencyNone},
+		"batch_ready": {ID: "batch_ready", Severity: dsl.Warning, Urgency: dsl.UrgencyLow},
+	}
+	lookup := func(id string) (dsl.Invariant, bool) {
+		inv, ok := invariants[id]
+		return inv, ok
+	}
+
+	client := &recordingClient{}
+	pager := NewPager(lookup, client)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pager.Run(ctx)
+
+	now := time.Now()
+	ready := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/api", Severity: dsl.Critical, Violated: true}
+	quiet := &engine.ViolationResult{InvariantID: "quiet_check", AffectedResource: "default/api", Severity: dsl.Critical, Violated: true}
+	batch := &engine.ViolationResult{InvariantID: "batch_ready", AffectedResource: "default/batch", Severity: dsl.Warning, Violated: true}
+	warning := &engine.ViolationResult{InvariantID: "pod_image_pinned", AffectedResource: "default/api", Severity: dsl.Warning, Violated: true}
+
+	for _, v := range []*engine.ViolationResult{ready, quiet, batch, warning} {
+		pager.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: v, At: now})
+	}
+	if err := pager.Acknowledge(ready.Fingerprint()); err != nil {
+		t.Fatalf("Acknowledge failed: %v", err)
+	}
+	if err := pager.Acknowledge(quiet.Fingerprint()); err != ErrNotOpen {
+		t.Errorf("Expected ErrNotOpen for a violation that didn't page, got %v", err)
+	}
+	pager.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: ready, At: now})
+	pager.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: warning, At: now})
+
+	calls := waitForCalls(t, client, 4)
+	want := []string{
+		"trigger pod_ready|default/api high",
+		"trigger batch_ready|default/batch low",
+		"acknowledge pod_ready|default/api",
+		"resolve pod_ready|default/api",
+	}
+	for i, call := range want {
+		if calls[i] != call {
+			t.Errorf("Call %d: expected %q, got %q", i, call, calls[i])
+		}
+	}
+
+	open := pager.Open()
+	if len(open) != 1 || open[0].InvariantID != "batch_ready" {
+		t.Errorf("Expected only batch_ready to stay open, got %+v", open)
+	}
+}
+
+func TestParseUrgencyMapping(t *testing.T) {
+	mapping, err := ParseUrgencyMapping("critical:high, warning:low")
+	if err != nil {
+		t.Fatalf("ParseUrgencyMapping failed: %v", err)
+	}
+	if mapping[dsl.Critical] != dsl.UrgencyHigh || mapping[dsl.Warning] != dsl.UrgencyLow {
+		t.Errorf("Unexpected mapping %v", mapping)
+	}
+	if _, err := ParseUrgencyMapping("critical:urgent"); err == nil {
+		t.Error("Expected an unknown urgency to be rejected")
+	}
+}
+
+func TestPagerDuty_Events(t *testing.T) {
+	var events []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&event)
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	pd := NewPagerDuty("routing-key")
+	pd.URL = server.URL
+	incident := Incident{Key: "pod_ready|default/api", Summary: "pod_ready violated", Source: "default/api", Urgency: dsl.UrgencyHigh, RunbookURL: "https://runbooks/pod-ready"}
+	if err := pd.Trigger(context.Background(), incident); err != nil {
+		t.Fatalf("Trigger failed: %v", err)
+	}
+	if err := pd.Resolve(context.Background(), incident.Key); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected two events, got %d", len(events))
+	}
+	payload, _ := events[0]["payload"].(map[string]interface{})
+	if events[0]["event_action"] != "trigger" || events[0]["dedup_key"] != incident.Key || payload["severity"] != "critical" {
+		t.Errorf("Unexpected trigger event %v", events[0])
+	}
+	if events[1]["event_action"] != "resolve" || events[1]["routing_key"] != "routing-key" {
+		t.Errorf("Unexpected resolve event %v", events[1])
+	}
+}
+
+func TestOpsgenie_Alerts(t *testing.T) {
+	var paths []string
+	var alert map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey api-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		paths = append(paths, r.URL.EscapedPath()+"?"+r.URL.RawQuery)
+		if r.URL.Path == "/v2/alerts" {
+			json.NewDecoder(r.Body).Decode(&alert)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	og := NewOpsgenie("api-key")
+	og.URL = server.URL
+	incident := Incident{Key: "pod_ready|default/api", Summary: "pod_ready violated", Urgency: dsl.UrgencyLow}
+	if err := og.Trigger(context.Background(), incident); err != nil {
+		t.Fatalf("Trigger failed: %v", err)
+	}
+	if err := og.Acknowledge(context.Background(), incident.Key); err != nil {
+		t.Fatalf("Acknowledge failed: %v", err)
+	}
+	if err := og.Resolve(context.Background(), incident.Key); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if alert["alias"] != incident.Key || alert["priority"] != "P3" {
+		t.Errorf("Unexpected alert %v", alert)
+	}
+	want := []string{
+		"/v2/alerts?",
+		"/v2/alerts/pod_ready%7Cdefault%2Fapi/acknowledge?identifierType=alias",
+		"/v2/alerts/pod_ready%7Cdefault%2Fapi/close?identifierType=alias",
+	}
+	for i, path := range want {
+		if i >= len(paths) || paths[i] != path {
+			t.Errorf("Request %d: expected %s, got %v", i, path, paths)
+		}
+	}
+}
+
+func TestWebhook_SignedDeliveries(t *testing.T) {
+	var events []string
+	var delivery map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "akari" || pass != "hunter2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := VerifySignature("s3cr3t", r.Header, body, DefaultSignatureTolerance, time.Now()); err != nil {
+			t.Errorf("Signature did not verify: %v", err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		events = append(events, r.Header.Get(EventHeader))
+		if r.Header.Get(EventHeader) == "trigger" {
+			json.Unmarshal(body, &delivery)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{Target: "chatops", URL: server.URL, Secret: "s3cr3t", BasicAuth: &BasicAuth{Username: "akari", Password: "hunter2"}}
+	if err := webhook.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	incident := Incident{Key: "pod_ready|default/api", Summary: "pod_ready violated"}
+	for _, send := range []func() error{
+		func() error { return webhook.Trigger(context.Background(), incident) },
+		func() error { return webhook.Acknowledge(context.Background(), incident.Key) },
+		func() error { return webhook.Resolve(context.Background(), incident.Key) },
+	} {
+		if err := send(); err != nil {
+			t.Fatalf("Delivery failed: %v", err)
+		}
+	}
+	if strings.Join(events, ",") != "trigger,acknowledge,resolve" {
+		t.Errorf("Expected trigger, acknowledge and resolve, got %v", events)
+	}
+	if delivery["key"] != incident.Key || delivery["incident"] == nil {
+		t.Errorf("Unexpected trigger delivery %v", delivery)
+	}
+
+	webhook.BasicAuth = nil
+	webhook.BearerToken = "token"
+	if err := webhook.Resolve(context.Background(), incident.Key); err == nil {
+		t.Error("Expected the receiver to reject a delivery without its credentials")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"action":"resolve"}`)
+	now := time.Unix(1700000000, 0)
+	header := http.Header{}
+	header.Set(TimestampHeader, "1700000000")
+	header.Set(SignatureHeader, Sign("s3cr3t", "1700000000", body))
+
+	if err := VerifySignature("s3cr3t", header, body, time.Minute, now); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+	if err := VerifySignature("other", header, body, time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected another secret rejected, got %v", err)
+	}
+	if err := VerifySignature("s3cr3t", header, []byte(`{"action":"trigger"}`), time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a tampered body rejected, got %v", err)
+	}
+	if err := VerifySignature("s3cr3t", header, body, time.Minute, now.Add(time.Hour)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a stale delivery rejected, got %v", err)
+	}
+}
+
+// flakyClient fails its first failures calls
+type flakyClient struct {
+	recordingClient
+	failures int
+}
+
+func (c *flakyClient) Trigger(ctx context.Context, incident Incident) error {
+	c.mu.Lock()
+	if c.failures > 0 {
+		c.failures--
+		c.mu.Unlock()
+		return errors.New("503 Service Unavailable")
+	}
+	c.mu.Unlock()
+	return c.recordingClient.Trigger(ctx, incident)
+}
+
+func TestPager_DeadLetterReplay(t *testing.T) {
+	client := &flakyClient{failures: 4}
+	pager := NewPager(nil, client)
+	pager.SetRetry(3, time.Millisecond)
+
+	v := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/api", Severity: dsl.Critical, Violated: true}
+	key := v.Fingerprint()
+	a := action{typ: actionTrigger, incident: newIncident(v, dsl.UrgencyHigh, time.Now())}
+
+	// The first action fails every attempt and is dead-lettered
+	pager.send(context.Background(), a)
+	letters, _ := pager.DeadLetters()
+	if len(letters) != 1 || letters[0].Attempts != 3 || letters[0].Incident.Key != key || letters[0].LastError == "" {
+		t.Fatalf("Expected one dead letter after 3 attempts, got %+v", letters)
+	}
+	if stats := pager.Stats(); stats.DeadLettered != 1 || stats.Failed != 3 {
+		t.Errorf("Expected 1 dead letter and 3 failures, got %+v", stats)
+	}
+
+	if _, err := pager.Replay(context.Background(), []int64{42}); err != ErrDeadLetterNotFound {
+		t.Errorf("Expected ErrDeadLetterNotFound for an unknown id, got %v", err)
+	}
+
+	// The fourth failure keeps it dead-lettered, the next replay delivers it
+	result, err := pager.Replay(context.Background(), nil)
+	if err != nil || result.Failed != 1 || result.Remaining != 1 {
+		t.Fatalf("Expected a failed replay, got %+v, %v", result, err)
+	}
+	if letters, _ := pager.DeadLetters(); len(letters) != 1 || letters[0].Attempts != 4 {
+		t.Errorf("Expected the letter to stay with 4 attempts, got %+v", letters)
+	}
+	result, err = pager.Replay(context.Background(), []int64{letters[0].ID})
+	if err != nil || result.Replayed != 1 || result.Remaining != 0 {
+		t.Fatalf("Expected the letter to be replayed, got %+v, %v", result, err)
+	}
+	if calls := client.Calls(); len(calls) != 1 || calls[0] != "trigger "+key+" high" {
+		t.Errorf("Expected one delivered trigger, got %v", calls)
+	}
+
+	deliveries, _ := pager.Deliveries(key)
+	if len(deliveries) != 5 {
+		t.Fatalf("Expected 5 recorded attempts, got %+v", deliveries)
+	}
+	if last := deliveries[4]; last.Attempt != 5 || last.Error != "" || last.Client != "recording" {
+		t.Errorf("Expected a successful fifth attempt, got %+v", last)
+	}
+}
+
+func TestPager_Restore(t *testing.T) {
+	client := &recordingClient{}
+	pager := NewPager(nil, client)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pager.Run(ctx)
+
+	ready := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/api", Severity: dsl.Critical, Violated: true, DetectedAt: time.Now().Add(-time.Hour)}
+	warning := &engine.ViolationResult{InvariantID: "pod_image_pinned", AffectedResource: "default/api", Severity: dsl.Warning, Violated: true}
+	pager.Restore([]*engine.ViolationResult{ready, warning})
+	if open := pager.Open(); len(open) != 1 || open[0].Key != ready.Fingerprint() {
+		t.Fatalf("Expected the critical violation's incident to be open, got %+v", open)
+	}
+
+	pager.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: ready, At: time.Now()})
+	if calls := waitForCalls(t, client, 1); len(calls) != 1 || calls[0] != "resolve "+ready.Fingerprint() {
+		t.Errorf("Expected only a resolve, got %v", calls)
+	}
+}
+
+func TestParseBudget(t *testing.T) {
+	budget, err := ParseBudget("team:10/1h")
+	if err != nil || budget.Per != BudgetPerTeam || budget.Limit != 10 || time.Duration(budget.Window) != time.Hour {
+		t.Errorf("Unexpected budget %+v, %v", budget, err)
+	}
+	for _, invalid := range []string{"team:10", "owner:10/1h", "namespace:0/1h", "team:x/1h"} {
+		if _, err := ParseBudget(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestPager_Budget(t *testing.T) {
+	invariants := map[string]dsl.Invariant{
+		"pod_ready": {ID: "pod_ready", Responsibility: dsl.Responsibility{Team: "payments"}},
+	}
+	lookup := func(id string) (dsl.Invariant, bool) {
+		inv, ok := invariants[id]
+		return inv, ok
+	}
+	client := &recordingClient{}
+	pager := NewPager(lookup, client)
+	pager.SetBudget("recording", Budget{Per: BudgetPerTeam, Limit: 2, Window: dsl.Duration(time.Hour)})
+
+	ctx := context.Background()
+	now := time.Now()
+	var violations []*engine.ViolationResult
+	for i := 0; i < 5; i++ {
+		v := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: fmt.Sprintf("default/api-%d", i), Severity: dsl.Critical, Violated: true}
+		violations = append(violations, v)
+		pager.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: v, At: now})
+	}
+	pager.send(ctx, <-pager.queue)
+	pager.send(ctx, <-pager.queue)
+	pager.send(ctx, <-pager.queue)
+	pager.send(ctx, <-pager.queue)
+	pager.send(ctx, <-pager.queue)
+	if calls := client.Calls(); len(calls) != 2 {
+		t.Fatalf("Expected 2 pages within the budget, got %v", calls)
+	}
+
+	// A held incident that resolves within the window leaves the digest
+	pager.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: violations[4], At: now})
+	pager.send(ctx, <-pager.queue)
+	if calls := client.Calls(); len(calls) != 2 {
+		t.Fatalf("Expected no resolve for a held incident, got %v", calls)
+	}
+
+	pager.sendDigests(ctx, now.Add(2*time.Hour))
+	calls := client.Calls()
+	if len(calls) != 3 || !strings.HasPrefix(calls[2], "trigger notification_digest|team:payments|") {
+		t.Fatalf("Expected one digest, got %v", calls)
+	}
+	digestKey := strings.Fields(calls[2])[1]
+	if stats := pager.Stats(); stats.Suppressed != 3 || stats.Digests != 1 {
+		t.Errorf("Expected 3 suppressed triggers and 1 digest, got %+v", stats)
+	}
+
+	// The digest resolves with the last incident it summarized
+	for _, v := range violations[2:4] {
+		pager.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: v, At: now})
+		pager.send(ctx, <-pager.queue)
+	}
+	calls = client.Calls()
+	if len(calls) != 4 || calls[3] != "resolve "+digestKey {
+		t.Errorf("Expected the digest to resolve, got %v", calls)
+	}
+}
@@ -0,0 +1,178 @@
+package paging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+	// timestamp, a dot and the body, keyed with the webhook secret
+	SignatureHeader = "X-Akari-Signature"
+	// TimestampHeader carries the Unix time the delivery was signed at
+	TimestampHeader = "X-Akari-Timestamp"
+	// EventHeader names the action: trigger, acknowledge or resolve
+	EventHeader = "X-Akari-Event"
+
+	// DefaultSignatureTolerance is how old a signed delivery may be before
+	// VerifySignature rejects it as a possible replay
+	DefaultSignatureTolerance = 5 * time.Minute
+)
+
+// ErrInvalidSignature is returned by VerifySignature for deliveries that
+// were not signed with the secret, or not recently
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// BasicAuth is the username and password of a webhook receiver
+type BasicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Webhook posts the incident lifecycle as JSON to any HTTP receiver. With a
+// secret every delivery is signed; a bearer token or basic credentials
+// authenticate akari to receivers that require it.
+type Webhook struct {
+	Target      string     `json:"name"`
+	URL         string     `json:"url"`
+	Secret      string     `json:"secret,omitempty"`
+	BearerToken string     `json:"bearer_token,omitempty"`
+	BasicAuth   *BasicAuth `json:"basic_auth,omitempty"`
+	// Budget limits the incidents the receiver is paged with
+	Budget *Budget      `json:"budget,omitempty"`
+	Client *http.Client `json:"-"`
+}
+
+// WebhookConfig is the webhook declaration file
+type WebhookConfig struct {
+	Webhooks []*Webhook `json:"webhooks"`
+}
+
+// LoadWebhooks reads a YAML or JSON webhook declaration file
+func LoadWebhooks(path string) ([]*Webhook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config WebhookConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid webhook file %s: %w", path, err)
+	}
+	for _, w := range config.Webhooks {
+		if err := w.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return config.Webhooks, nil
+}
+
+// Validate checks the webhook has a target URL and at most one way to
+// authenticate
+func (w *Webhook) Validate() error {
+	if w.Target == "" {
+		return fmt.Errorf("webhook name is required")
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook %s needs an http or https url", w.Target)
+	}
+	if w.BearerToken != "" && w.BasicAuth != nil {
+		return fmt.Errorf("webhook %s sets both bearer_token and basic_auth", w.Target)
+	}
+	if w.Budget != nil {
+		if err := w.Budget.Validate(); err != nil {
+			return fmt.Errorf("webhook %s: %w", w.Target, err)
+		}
+	}
+	return nil
+}
+
+func (w *Webhook) Name() string { return "webhook " + w.Target }
+
+// webhookDelivery is the body of every delivery. Incident is set on
+// triggers; acknowledgements and resolutions carry the key only.
+type webhookDelivery struct {
+	Action   actionType `json:"action"`
+	Key      string     `json:"key"`
+	Incident *Incident  `json:"incident,omitempty"`
+	SentAt   time.Time  `json:"sent_at"`
+}
+
+func (w *Webhook) Trigger(ctx context.Context, incident Incident) error {
+	return w.deliver(ctx, webhookDelivery{Action: actionTrigger, Key: incident.Key, Incident: &incident})
+}
+
+func (w *Webhook) Acknowledge(ctx context.Context, key string) error {
+	return w.deliver(ctx, webhookDelivery{Action: actionAcknowledge, Key: key})
+}
+
+func (w *Webhook) Resolve(ctx context.Context, key string) error {
+	return w.deliver(ctx, webhookDelivery{Action: actionResolve, Key: key})
+}
+
+func (w *Webhook) deliver(ctx context.Context, delivery webhookDelivery) error {
+	now := time.Now()
+	delivery.SentAt = now.UTC()
+	body, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set(EventHeader, string(delivery.Action))
+	if w.Secret != "" {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		header.Set(TimestampHeader, timestamp)
+		header.Set(SignatureHeader, Sign(w.Secret, timestamp, body))
+	}
+	switch {
+	case w.BearerToken != "":
+		header.Set("Authorization", "Bearer "+w.BearerToken)
+	case w.BasicAuth != nil:
+		credentials := w.BasicAuth.Username + ":" + w.BasicAuth.Password
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+	return post(ctx, w.Client, w.URL, header, body)
+}
+
+// Sign returns the signature header value of a delivery body sent at
+// timestamp, in Unix seconds
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a delivery was signed with secret no longer than
+// tolerance before now. Receivers written in Go can call it with the
+// request headers and the raw body.
+func VerifySignature(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	timestamp := header.Get(TimestampHeader)
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing %s", ErrInvalidSignature, TimestampHeader)
+	}
+	if age := now.Sub(time.Unix(sent, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: signed %s ago", ErrInvalidSignature, age.Round(time.Second))
+	}
+	want := Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(want)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
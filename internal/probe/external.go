@@ -0,0 +1,213 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"sigs.k8s.io/yaml"
+)
+
+// ExternalKind is the kind of StateEvents recorded for external dependencies
+const ExternalKind = "ExternalService"
+
+const defaultExternalTimeout = 5 * time.Second
+
+// Target is one configured external dependency check
+type Target struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Type is tcp or http
+	Type    string `json:"type"`
+	Address string `json:"address,omitempty"` // host:port for tcp
+	URL     string `json:"url,omitempty"`     // for http
+	// ExpectStatus is the required HTTP status; any 2xx/3xx when unset
+	ExpectStatus int               `json:"expectStatus,omitempty"`
+	Timeout      dsl.Duration      `json:"timeout,omitempty"`
+	Interval     dsl.Duration      `json:"interval,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// ExternalConfig is the external probe configuration file
+type ExternalConfig struct {
+	Interval dsl.Duration `json:"interval,omitempty"`
+	Targets  []Target     `json:"targets"`
+}
+
+// LoadExternalConfig reads a YAML or JSON probe configuration file
+func LoadExternalConfig(path string) (ExternalConfig, error) {
+	var config ExternalConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid probe file %s: %w", path, err)
+	}
+	for _, target := range config.Targets {
+		if err := target.validate(); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+func (t Target) validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("probe target name is required")
+	}
+	switch t.Type {
+	case "tcp":
+		if t.Address == "" {
+			return fmt.Errorf("tcp probe %s needs an address", t.Name)
+		}
+	case "http":
+		if t.URL == "" {
+			return fmt.Errorf("http probe %s needs a url", t.Name)
+		}
+	default:
+		return fmt.Errorf("probe %s has unsupported type %q (want tcp or http)", t.Name, t.Type)
+	}
+	return nil
+}
+
+// ExternalProber checks external dependencies and records the results as
+// ExternalService StateEvents, so invariants and causal chains can reach
+// past the cluster boundary
+type ExternalProber struct {
+	store    state.StateStore
+	config   ExternalConfig
+	client   *http.Client
+	dialer   *net.Dialer
+	interval time.Duration
+}
+
+func NewExternalProber(store state.StateStore, config ExternalConfig) *ExternalProber {
+	interval := time.Duration(config.Interval)
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &ExternalProber{
+		store:  store,
+		config: config,
+		client: &http.Client{
+			// Report redirects as the dependency's answer instead of following them
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		dialer:   &net.Dialer{},
+		interval: interval,
+	}
+}
+
+// Run probes every target on its interval until ctx is cancelled
+func (p *ExternalProber) Run(ctx context.Context) {
+	for _, target := range p.config.Targets {
+		go p.runTarget(ctx, target)
+	}
+	<-ctx.Done()
+}
+
+func (p *ExternalProber) runTarget(ctx context.Context, target Target) {
+	interval := time.Duration(target.Interval)
+	if interval <= 0 {
+		interval = p.interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.record(p.Probe(ctx, target))
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *ExternalProber) record(event types.StateEvent) {
+	if err := p.store.Record(event); err != nil {
+		log.Printf("Failed to record probe of %s: %v", event.Name, err)
+	}
+}
+
+// Probe checks one target and returns the resulting event
+func (p *ExternalProber) Probe(ctx context.Context, target Target) types.StateEvent {
+	now := time.Now()
+	id := target.Name
+	if target.Namespace != "" {
+		id = target.Namespace + "/" + target.Name
+	}
+	event := types.StateEvent{
+		UID:       "external:" + id,
+		Kind:      ExternalKind,
+		Namespace: target.Namespace,
+		Name:      target.Name,
+		Labels:    target.Labels,
+		Version:   strconv.FormatInt(now.UnixNano(), 10),
+		Timestamp: now,
+		FieldDiff: map[string]interface{}{"probe.type": target.Type},
+		Actor:     Actor,
+	}
+
+	timeout := time.Duration(target.Timeout)
+	if timeout <= 0 {
+		timeout = defaultExternalTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var err error
+	if target.Type == "http" {
+		event.FieldDiff["probe.target"] = target.URL
+		err = p.probeHTTP(ctx, target, event.FieldDiff)
+	} else {
+		event.FieldDiff["probe.target"] = target.Address
+		err = p.probeTCP(ctx, target)
+	}
+
+	event.FieldDiff["status.latencyMs"] = time.Since(now).Milliseconds()
+	event.FieldDiff["status.reachable"] = boolStatus(err == nil)
+	if err != nil {
+		event.FieldDiff["status.error"] = err.Error()
+	}
+	return event
+}
+
+func (p *ExternalProber) probeTCP(ctx context.Context, target Target) error {
+	conn, err := p.dialer.DialContext(ctx, "tcp", target.Address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (p *ExternalProber) probeHTTP(ctx context.Context, target Target, fields map[string]interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	fields["status.httpStatus"] = resp.StatusCode
+	if target.ExpectStatus != 0 {
+		if resp.StatusCode != target.ExpectStatus {
+			return fmt.Errorf("unexpected status %d (want %d)", resp.StatusCode, target.ExpectStatus)
+		}
+	} else if resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+)
+
+func TestExternalProber_HTTP(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	prober := NewExternalProber(state.NewMemoryStore(), ExternalConfig{})
+
+	event := prober.Probe(context.Background(), Target{Name: "payments", Type: "http", URL: healthy.URL})
+	if event.Kind != ExternalKind || event.FieldDiff["status.reachable"] != "True" || event.FieldDiff["status.httpStatus"] != 200 {
+		t.Errorf("Expected reachable payments API, got %v", event.FieldDiff)
+	}
+
+	event = prober.Probe(context.Background(), Target{Name: "search", Type: "http", URL: failing.URL})
+	if event.FieldDiff["status.reachable"] != "False" || event.FieldDiff["status.error"] == nil {
+		t.Errorf("Expected unreachable search API, got %v", event.FieldDiff)
+	}
+
+	event = prober.Probe(context.Background(), Target{Name: "payments", Type: "http", URL: healthy.URL, ExpectStatus: 204})
+	if event.FieldDiff["status.reachable"] != "False" {
+		t.Errorf("Expected status mismatch to fail, got %v", event.FieldDiff)
+	}
+}
+
+func TestExternalProber_TCPInvariant(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := listener.Addr().String()
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedAddr := closed.Addr().String()
+	closed.Close()
+	defer listener.Close()
+
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	prober := NewExternalProber(store, ExternalConfig{})
+
+	prober.record(prober.Probe(context.Background(), Target{Name: "orders-db", Type: "tcp", Address: open}))
+	prober.record(prober.Probe(context.Background(), Target{Name: "cache", Namespace: "shop", Type: "tcp", Address: closedAddr}))
+
+	inv, _ := eng.GetInvariantByID("external_service_reachable")
+	violations := engine.FilterByStatus(eng.Evaluate(inv), engine.StatusViolated)
+	if len(violations) != 1 || violations[0].ResourceUID != "external:shop/cache" {
+		t.Errorf("Expected only the cache to violate, got %+v", violations)
+	}
+}
+
+func TestLoadExternalConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "probes.yaml")
+	os.WriteFile(path, []byte(`
+interval: 15s
+targets:
+  - name: orders-db
+    type: tcp
+    address: orders-db.internal:5432
+  - name: payments
+    type: http
+    url: https://payments.example.com/health
+    expectStatus: 200
+    timeout: 2s
+`), 0o644)
+
+	config, err := LoadExternalConfig(path)
+	if err != nil {
+		t.Fatalf("LoadExternalConfig failed: %v", err)
+	}
+	if len(config.Targets) != 2 || config.Targets[1].ExpectStatus != 200 {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+
+	os.WriteFile(path, []byte("targets:\n  - name: x\n    type: udp\n"), 0o644)
+	if _, err := LoadExternalConfig(path); err == nil {
+		t.Error("Expected error for unsupported probe type")
+	}
+}
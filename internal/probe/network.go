@@ -0,0 +1,179 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+const (
+	// Actor is recorded on synthetic probe events
+	Actor = "akari-probe"
+	// Kind of the synthetic resources produced by network probes
+	Kind = "NetworkProbe"
+	// Label distinguishing probes, used by invariant selectors
+	Label = "akari.io/probe"
+
+	DefaultDNSTarget = "kubernetes.default.svc.cluster.local"
+	DefaultInterval  = 30 * time.Second
+	dnsTimeout       = 5 * time.Second
+)
+
+// cniSelectors are the labels carried by the node agents of common CNI plugins
+var cniSelectors = []struct {
+	plugin string
+	key    string
+	value  string
+}{
+	{"calico", "k8s-app", "calico-node"},
+	{"cilium", "k8s-app", "cilium"},
+	{"aws-vpc-cni", "k8s-app", "aws-node"},
+	{"azure-cni", "k8s-app", "azure-cns"},
+	{"flannel", "app", "flannel"},
+	{"weave", "name", "weave-net"},
+	{"antrea", "component", "antrea-agent"},
+}
+
+var corednsSelector = map[string]string{"k8s-app": "kube-dns"}
+
+// NetworkProber records synthetic NetworkProbe resources describing
+// cluster DNS and CNI health, which the cluster_dns_resolving and
+// cni_pods_ready invariants evaluate
+type NetworkProber struct {
+	store     state.StateStore
+	resolver  *net.Resolver
+	dnsTarget string
+	interval  time.Duration
+}
+
+func NewNetworkProber(store state.StateStore, dnsTarget string, interval time.Duration) *NetworkProber {
+	if dnsTarget == "" {
+		dnsTarget = DefaultDNSTarget
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &NetworkProber{
+		store:     store,
+		resolver:  net.DefaultResolver,
+		dnsTarget: dnsTarget,
+		interval:  interval,
+	}
+}
+
+// Run probes on every interval until ctx is cancelled
+func (p *NetworkProber) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.ProbeOnce(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ProbeOnce records one DNS and one CNI probe result
+func (p *NetworkProber) ProbeOnce(ctx context.Context) {
+	for _, event := range []types.StateEvent{p.probeDNS(ctx), p.probeCNI()} {
+		if err := p.store.Record(event); err != nil {
+			log.Printf("Failed to record %s probe: %v", event.Name, err)
+		}
+	}
+}
+
+func (p *NetworkProber) probeDNS(ctx context.Context) types.StateEvent {
+	event := newProbeEvent("dns", "cluster-dns")
+
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+	start := time.Now()
+	addrs, err := p.resolver.LookupHost(ctx, p.dnsTarget)
+
+	event.FieldDiff["probe.target"] = p.dnsTarget
+	event.FieldDiff["status.latencyMs"] = time.Since(start).Milliseconds()
+	if err != nil {
+		event.FieldDiff["status.resolving"] = "False"
+		event.FieldDiff["status.error"] = err.Error()
+	} else {
+		event.FieldDiff["status.resolving"] = "True"
+		event.FieldDiff["status.addresses"] = len(addrs)
+	}
+
+	// CoreDNS pod readiness distinguishes a DNS outage from a network one
+	ready, total, _ := podReadiness(p.store.GetLatestByKind("Pod"), corednsSelector)
+	event.FieldDiff["status.readyPods"] = ready
+	event.FieldDiff["status.totalPods"] = total
+	return event
+}
+
+func (p *NetworkProber) probeCNI() types.StateEvent {
+	return CNIStatus(p.store.GetLatestByKind("Pod"))
+}
+
+// CNIStatus summarizes the readiness of the CNI node agents found among pods
+func CNIStatus(pods []types.StateEvent) types.StateEvent {
+	event := newProbeEvent("cni", "cni")
+
+	for _, cni := range cniSelectors {
+		ready, total, notReady := podReadiness(pods, map[string]string{cni.key: cni.value})
+		if total == 0 {
+			continue
+		}
+		event.FieldDiff["status.plugin"] = cni.plugin
+		event.FieldDiff["status.readyPods"] = ready
+		event.FieldDiff["status.totalPods"] = total
+		event.FieldDiff["status.notReadyPods"] = notReady
+		event.FieldDiff["status.ready"] = boolStatus(ready == total)
+		return event
+	}
+
+	// Without a recognised CNI the invariant evaluates to unknown
+	return event
+}
+
+func podReadiness(pods []types.StateEvent, selector map[string]string) (ready, total int, notReady []string) {
+	notReady = make([]string, 0)
+	for _, pod := range pods {
+		if pod.Namespace != "kube-system" || !state.MatchesSelector(selector, pod.Labels) {
+			continue
+		}
+		total++
+		if status, _ := pod.FieldDiff["status.conditions[Ready].status"].(string); status == "True" {
+			ready++
+		} else {
+			notReady = append(notReady, pod.Name)
+		}
+	}
+	return ready, total, notReady
+}
+
+func boolStatus(ok bool) string {
+	if ok {
+		return "True"
+	}
+	return "False"
+}
+
+func newProbeEvent(probe, name string) types.StateEvent {
+	now := time.Now()
+	return types.StateEvent{
+		UID:       fmt.Sprintf("probe:%s", name),
+		Kind:      Kind,
+		Name:      name,
+		Labels:    map[string]string{Label: probe},
+		Version:   strconv.FormatInt(now.UnixNano(), 10),
+		Timestamp: now,
+		FieldDiff: make(map[string]interface{}),
+		Actor:     Actor,
+	}
+}
@@ -0,0 +1,71 @@
+package probe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func cniPod(name string, ready bool) types.StateEvent {
+	status := "False"
+	if ready {
+		status = "True"
+	}
+	return types.StateEvent{
+		UID:       name,
+		Kind:      "Pod",
+		Namespace: "kube-system",
+		Name:      name,
+		Labels:    map[string]string{"k8s-app": "calico-node"},
+		Version:   "1",
+		Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": status},
+	}
+}
+
+func TestCNIStatus(t *testing.T) {
+	event := CNIStatus([]types.StateEvent{cniPod("calico-node-a", true), cniPod("calico-node-b", false)})
+
+	if event.FieldDiff["status.plugin"] != "calico" {
+		t.Errorf("Expected calico plugin, got %v", event.FieldDiff["status.plugin"])
+	}
+	if event.FieldDiff["status.ready"] != "False" {
+		t.Errorf("Expected CNI not ready, got %v", event.FieldDiff["status.ready"])
+	}
+	if notReady := event.FieldDiff["status.notReadyPods"].([]string); len(notReady) != 1 || notReady[0] != "calico-node-b" {
+		t.Errorf("Unexpected not ready pods: %v", notReady)
+	}
+
+	// No recognised CNI leaves status.ready unset
+	if _, exists := CNIStatus(nil).FieldDiff["status.ready"]; exists {
+		t.Error("Expected status.ready to be unset without CNI pods")
+	}
+}
+
+func TestNetworkProber_CNIInvariant(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	store.Record(cniPod("calico-node-a", false))
+
+	// An unresolvable target keeps the test independent of the host network
+	prober := NewNetworkProber(store, "akari-probe.invalid", time.Minute)
+	prober.ProbeOnce(context.Background())
+
+	inv, exists := eng.GetInvariantByID("cni_pods_ready")
+	if !exists {
+		t.Fatal("Expected cni_pods_ready invariant to be loaded")
+	}
+	violations := engine.FilterByStatus(eng.Evaluate(inv), engine.StatusViolated)
+	if len(violations) != 1 || violations[0].ResponsibleActor != "cni-plugin" {
+		t.Errorf("Expected one cni_pods_ready violation, got %+v", violations)
+	}
+
+	inv, _ = eng.GetInvariantByID("cluster_dns_resolving")
+	if violations := engine.FilterByStatus(eng.Evaluate(inv), engine.StatusViolated); len(violations) != 1 {
+		t.Errorf("Expected cluster_dns_resolving violation, got %d", len(violations))
+	}
+}
@@ -0,0 +1,272 @@
+package quality
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+)
+
+const (
+	// DefaultWindow is how much violation history invariants are scored on
+	DefaultWindow = 7 * 24 * time.Hour
+	// DefaultFlapWindow is how soon a violation must reopen after resolving
+	// to count as a flap
+	DefaultFlapWindow = 15 * time.Minute
+	// NoisyPerDay is the daily violation volume above which an invariant's
+	// score starts dropping
+	NoisyPerDay = 20
+
+	// flappingRate is the share of reopened violations that marks an
+	// invariant flapping
+	flappingRate = 0.5
+	// minIgnored is how many unacknowledged violations mark an invariant
+	// ignored
+	minIgnored = 5
+)
+
+// Verdict is the suggested action for an invariant
+type Verdict string
+
+const (
+	// VerdictActionable invariants fire at a reasonable rate and are acted on
+	VerdictActionable Verdict = "actionable"
+	// VerdictFlapping invariants keep resolving and reopening; tune the
+	// predicate or add a grace period
+	VerdictFlapping Verdict = "flapping"
+	// VerdictNoisy invariants fire more than NoisyPerDay times a day
+	VerdictNoisy Verdict = "noisy"
+	// VerdictIgnored invariants fire but their incidents are never
+	// acknowledged; candidates for retirement
+	VerdictIgnored Verdict = "ignored"
+	// VerdictQuiet invariants didn't fire in the window
+	VerdictQuiet Verdict = "quiet"
+)
+
+// Score is the signal quality of one invariant over the window
+type Score struct {
+	InvariantID  string       `json:"invariant_id"`
+	Severity     dsl.Severity `json:"severity,omitempty"`
+	Violations   int          `json:"violations"`
+	PerDay       float64      `json:"per_day"`
+	Open         int          `json:"open"`
+	Resolved     int          `json:"resolved"`
+	Flaps        int          `json:"flaps"`
+	FlapRate     float64      `json:"flap_rate"`
+	Acknowledged int          `json:"acknowledged"`
+	// AckRate is omitted unless acknowledgments are tracked, i.e. paging
+	// is enabled
+	AckRate *float64 `json:"ack_rate,omitempty"`
+	// MedianResolutionSeconds is the median lifetime of the resolved
+	// violations
+	MedianResolutionSeconds float64 `json:"median_resolution_seconds"`
+	// Score runs from 0, pure noise, to 1; omitted for quiet invariants
+	Score   *float64 `json:"score,omitempty"`
+	Verdict Verdict  `json:"verdict"`
+}
+
+// Report scores every invariant, lowest score first
+type Report struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Window      string    `json:"window"`
+	Invariants  []Score   `json:"invariants"`
+}
+
+// span is the lifetime of one violation
+type span struct {
+	invariantID string
+	fingerprint string
+	start, end  time.Time
+	flap        bool
+	acked       bool
+}
+
+// Tracker follows violation transitions and incident acknowledgments to
+// score invariants by how often their violations lead to action
+type Tracker struct {
+	window     time.Duration
+	flapWindow time.Duration
+
+	mu       sync.Mutex
+	spans    []*span
+	open     map[string]*span
+	resolved map[string]time.Time // fingerprint -> last resolution, for flaps
+	acks     bool
+}
+
+func NewTracker(window, flapWindow time.Duration) *Tracker {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	if flapWindow <= 0 {
+		flapWindow = DefaultFlapWindow
+	}
+	return &Tracker{
+		window:     window,
+		flapWindow: flapWindow,
+		open:       make(map[string]*span),
+		resolved:   make(map[string]time.Time),
+	}
+}
+
+// TrackAcknowledgments includes acknowledgment rates in the scores; call
+// it when incidents are paged and can be acknowledged
+func (t *Tracker) TrackAcknowledgments() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.acks = true
+}
+
+// HandleTransition opens and closes violation spans
+func (t *Tracker) HandleTransition(tr engine.Transition) {
+	key := tr.Violation.Fingerprint()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch tr.Type {
+	case engine.TransitionOpened:
+		if _, exists := t.open[key]; exists {
+			return
+		}
+		s := &span{invariantID: tr.Violation.InvariantID, fingerprint: key, start: tr.At}
+		if last, ok := t.resolved[key]; ok && tr.At.Sub(last) <= t.flapWindow {
+			s.flap = true
+		}
+		t.open[key] = s
+		t.spans = append(t.spans, s)
+	case engine.TransitionResolved:
+		if s, exists := t.open[key]; exists {
+			s.end = tr.At
+			delete(t.open, key)
+			t.resolved[key] = tr.At
+		}
+	}
+	t.prune(tr.At)
+}
+
+// Acknowledge marks the open violation with fingerprint as acted on
+func (t *Tracker) Acknowledge(fingerprint string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s, exists := t.open[fingerprint]; exists {
+		s.acked = true
+	}
+}
+
+// Report scores invariants on the violations opened in the window ending
+// at now. Invariants that didn't fire are listed as quiet.
+func (t *Tracker) Report(invariants []dsl.Invariant, now time.Time) Report {
+	from := now.Add(-t.window)
+	days := t.window.Hours() / 24
+
+	t.mu.Lock()
+	byInvariant := make(map[string][]span)
+	for _, s := range t.spans {
+		if !s.start.Before(from) && !s.start.After(now) {
+			byInvariant[s.invariantID] = append(byInvariant[s.invariantID], *s)
+		}
+	}
+	acks := t.acks
+	t.mu.Unlock()
+
+	severities := make(map[string]dsl.Severity, len(invariants))
+	for _, inv := range invariants {
+		severities[inv.ID] = inv.Severity
+		if _, ok := byInvariant[inv.ID]; !ok {
+			byInvariant[inv.ID] = nil
+		}
+	}
+
+	scores := make([]Score, 0, len(byInvariant))
+	for id, spans := range byInvariant {
+		scores = append(scores, score(id, severities[id], spans, days, acks))
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		a, b := scores[i], scores[j]
+		if (a.Score == nil) != (b.Score == nil) {
+			return b.Score == nil
+		}
+		if a.Score != nil && *a.Score != *b.Score {
+			return *a.Score < *b.Score
+		}
+		if a.Violations != b.Violations {
+			return a.Violations > b.Violations
+		}
+		return a.InvariantID < b.InvariantID
+	})
+	return Report{GeneratedAt: now, Window: t.window.String(), Invariants: scores}
+}
+
+// score rates an invariant by multiplying its stability (1 - flap rate),
+// a volume factor falling off past NoisyPerDay and, when tracked, a factor
+// rising from 0.25 with no acknowledgments to 1 with all of them
+func score(id string, severity dsl.Severity, spans []span, days float64, acks bool) Score {
+	s := Score{InvariantID: id, Severity: severity, Violations: len(spans), Verdict: VerdictQuiet}
+	if len(spans) == 0 {
+		return s
+	}
+
+	var resolutions []time.Duration
+	for _, sp := range spans {
+		if sp.end.IsZero() {
+			s.Open++
+		} else {
+			s.Resolved++
+			resolutions = append(resolutions, sp.end.Sub(sp.start))
+		}
+		if sp.flap {
+			s.Flaps++
+		}
+		if sp.acked {
+			s.Acknowledged++
+		}
+	}
+	if len(resolutions) > 0 {
+		sort.Slice(resolutions, func(i, j int) bool { return resolutions[i] < resolutions[j] })
+		s.MedianResolutionSeconds = resolutions[len(resolutions)/2].Seconds()
+	}
+	s.PerDay = float64(s.Violations) / days
+	s.FlapRate = float64(s.Flaps) / float64(s.Violations)
+
+	value := 1 - s.FlapRate
+	if s.PerDay > NoisyPerDay {
+		value *= NoisyPerDay / s.PerDay
+	}
+	if acks {
+		rate := float64(s.Acknowledged) / float64(s.Violations)
+		s.AckRate = &rate
+		value *= 0.25 + 0.75*rate
+	}
+	s.Score = &value
+
+	switch {
+	case s.FlapRate >= flappingRate:
+		s.Verdict = VerdictFlapping
+	case s.PerDay > NoisyPerDay:
+		s.Verdict = VerdictNoisy
+	case acks && s.Acknowledged == 0 && s.Violations >= minIgnored:
+		s.Verdict = VerdictIgnored
+	default:
+		s.Verdict = VerdictActionable
+	}
+	return s
+}
+
+// prune drops spans that resolved before the window. Callers hold t.mu.
+func (t *Tracker) prune(now time.Time) {
+	cutoff := now.Add(-t.window)
+	kept := t.spans[:0]
+	for _, s := range t.spans {
+		if s.end.IsZero() || !s.end.Before(cutoff) {
+			kept = append(kept, s)
+		}
+	}
+	t.spans = kept
+	for key, at := range t.resolved {
+		if now.Sub(at) > t.flapWindow {
+			delete(t.resolved, key)
+		}
+	}
+}
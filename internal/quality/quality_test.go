@@ -0,0 +1,102 @@
+package quality
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+)
+
+func violation(id, resource string) *engine.ViolationResult {
+	return &engine.ViolationResult{InvariantID: id, AffectedResource: resource, Status: engine.StatusViolated, Violated: true}
+}
+
+func transition(typ engine.TransitionType, v *engine.ViolationResult, at time.Time) engine.Transition {
+	return engine.Transition{Type: typ, Violation: v, At: at}
+}
+
+func findScore(t *testing.T, report Report, id string) Score {
+	t.Helper()
+	for _, s := range report.Invariants {
+		if s.InvariantID == id {
+			return s
+		}
+	}
+	t.Fatalf("No score for %s in %+v", id, report.Invariants)
+	return Score{}
+}
+
+func TestTracker_ScoresInvariants(t *testing.T) {
+	tracker := NewTracker(24*time.Hour, 10*time.Minute)
+	tracker.TrackAcknowledgments()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// flaky resolves and reopens within the flap window three times
+	flaky := violation("flaky", "default/api")
+	at := start
+	for i := 0; i < 4; i++ {
+		tracker.HandleTransition(transition(engine.TransitionOpened, flaky, at))
+		tracker.HandleTransition(transition(engine.TransitionResolved, flaky, at.Add(time.Minute)))
+		at = at.Add(3 * time.Minute)
+	}
+
+	// acted is acknowledged and resolved after an hour
+	acted := violation("acted", "default/db")
+	tracker.HandleTransition(transition(engine.TransitionOpened, acted, start))
+	tracker.Acknowledge(acted.Fingerprint())
+	tracker.HandleTransition(transition(engine.TransitionResolved, acted, start.Add(time.Hour)))
+
+	// ignored fires on five resources nobody acknowledges
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		tracker.HandleTransition(transition(engine.TransitionOpened, violation("ignored", "default/"+name), start))
+	}
+
+	invariants := []dsl.Invariant{{ID: "flaky", Severity: dsl.Warning}, {ID: "acted"}, {ID: "ignored"}, {ID: "silent"}}
+	report := tracker.Report(invariants, start.Add(2*time.Hour))
+
+	f := findScore(t, report, "flaky")
+	if f.Violations != 4 || f.Flaps != 3 || f.Verdict != VerdictFlapping || f.Severity != dsl.Warning {
+		t.Errorf("Expected flaky to be flapping, got %+v", f)
+	}
+	a := findScore(t, report, "acted")
+	if a.Verdict != VerdictActionable || a.Score == nil || *a.Score != 1 || a.MedianResolutionSeconds != 3600 {
+		t.Errorf("Expected acted to score 1, got %+v", a)
+	}
+	i := findScore(t, report, "ignored")
+	if i.Verdict != VerdictIgnored || i.Open != 5 || i.AckRate == nil || *i.AckRate != 0 {
+		t.Errorf("Expected ignored to be ignored, got %+v", i)
+	}
+	if s := findScore(t, report, "silent"); s.Verdict != VerdictQuiet || s.Score != nil {
+		t.Errorf("Expected silent to be quiet, got %+v", s)
+	}
+
+	// Lowest score first, quiet invariants last
+	if report.Invariants[0].InvariantID != "flaky" || report.Invariants[len(report.Invariants)-1].InvariantID != "silent" {
+		t.Errorf("Unexpected order %+v", report.Invariants)
+	}
+}
+
+func TestTracker_NoisyAndWindow(t *testing.T) {
+	tracker := NewTracker(24*time.Hour, time.Minute)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2*NoisyPerDay; i++ {
+		v := violation("noisy", "default/pod")
+		at := start.Add(time.Duration(i) * 30 * time.Minute)
+		tracker.HandleTransition(transition(engine.TransitionOpened, v, at))
+		tracker.HandleTransition(transition(engine.TransitionResolved, v, at.Add(10*time.Minute)))
+	}
+
+	report := tracker.Report(nil, start.Add(24*time.Hour))
+	s := findScore(t, report, "noisy")
+	if s.Verdict != VerdictNoisy || s.Flaps != 0 || s.AckRate != nil || s.Score == nil || *s.Score != 0.5 {
+		t.Errorf("Expected noisy to score 0.5 without acknowledgment rates, got %+v", s)
+	}
+
+	// A day later the violations have left the window
+	later := tracker.Report([]dsl.Invariant{{ID: "noisy"}}, start.Add(72*time.Hour))
+	if s := findScore(t, later, "noisy"); s.Verdict != VerdictQuiet {
+		t.Errorf("Expected old violations to age out, got %+v", s)
+	}
+}
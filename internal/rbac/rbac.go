@@ -0,0 +1,148 @@
+package rbac
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultResource is the resource a user must be able to get in a
+	// namespace to see akari's findings there
+	DefaultResource = "pods"
+	// DefaultCacheTTL bounds how long token reviews and access decisions are
+	// reused before the API server is asked again
+	DefaultCacheTTL = time.Minute
+)
+
+// Reviewer aligns akari's authorization with cluster RBAC: it authenticates
+// bearer tokens with TokenReview and checks namespace access with
+// SubjectAccessReview, caching both for a short while
+type Reviewer struct {
+	client   kubernetes.Interface
+	resource string
+	ttl      time.Duration
+
+	mu        sync.Mutex
+	users     map[[sha256.Size]byte]cachedUser
+	decisions map[decisionKey]cachedDecision
+}
+
+type cachedUser struct {
+	user          authenticationv1.UserInfo
+	authenticated bool
+	expires       time.Time
+}
+
+type decisionKey struct {
+	user      string
+	namespace string
+}
+
+type cachedDecision struct {
+	allowed bool
+	expires time.Time
+}
+
+func NewReviewer(client kubernetes.Interface) *Reviewer {
+	return &Reviewer{
+		client:    client,
+		resource:  DefaultResource,
+		ttl:       DefaultCacheTTL,
+		users:     make(map[[sha256.Size]byte]cachedUser),
+		decisions: make(map[decisionKey]cachedDecision),
+	}
+}
+
+// SetResource changes the resource whose get permission grants a namespace
+func (r *Reviewer) SetResource(resource string) {
+	r.resource = resource
+}
+
+// Authenticate returns the user a bearer token belongs to. Tokens the API
+// server doesn't accept report false without an error.
+func (r *Reviewer) Authenticate(ctx context.Context, token string) (authenticationv1.UserInfo, bool, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+	r.mu.Lock()
+	cached, ok := r.users[key]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.user, cached.authenticated, nil
+	}
+
+	review, err := r.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return authenticationv1.UserInfo{}, false, fmt.Errorf("token review failed: %w", err)
+	}
+
+	r.mu.Lock()
+	r.prune(now)
+	r.users[key] = cachedUser{user: review.Status.User, authenticated: review.Status.Authenticated, expires: now.Add(r.ttl)}
+	r.mu.Unlock()
+	return review.Status.User, review.Status.Authenticated, nil
+}
+
+// CanRead reports whether user may get the reviewed resource in namespace.
+// An empty namespace asks for access across every namespace.
+func (r *Reviewer) CanRead(ctx context.Context, user authenticationv1.UserInfo, namespace string) (bool, error) {
+	key := decisionKey{user: user.Username, namespace: namespace}
+	now := time.Now()
+	r.mu.Lock()
+	cached, ok := r.decisions[key]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.allowed, nil
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review, err := r.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "get",
+				Resource:  r.resource,
+			},
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("subject access review failed: %w", err)
+	}
+
+	r.mu.Lock()
+	r.prune(now)
+	r.decisions[key] = cachedDecision{allowed: review.Status.Allowed, expires: now.Add(r.ttl)}
+	r.mu.Unlock()
+	return review.Status.Allowed, nil
+}
+
+// prune drops expired entries so the caches stay bounded by the users of
+// the last TTL. Callers hold r.mu.
+func (r *Reviewer) prune(now time.Time) {
+	for key, user := range r.users {
+		if !now.Before(user.expires) {
+			delete(r.users, key)
+		}
+	}
+	for key, decision := range r.decisions {
+		if !now.Before(decision.expires) {
+			delete(r.decisions, key)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeCluster answers token and access reviews: "team-token" belongs to
+// team-a, which may read the team-a namespace only
+func fakeCluster() (*fake.Clientset, *int) {
+	client := fake.NewSimpleClientset()
+	reviews := 0
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "team-token" {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: "system:serviceaccount:team-a:ci"}
+		}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = review.Spec.User == "system:serviceaccount:team-a:ci" &&
+			attrs.Namespace == "team-a" && attrs.Verb == "get" && attrs.Resource == "pods"
+		return true, review, nil
+	})
+	return client, &reviews
+}
+
+func TestReviewer(t *testing.T) {
+	client, reviews := fakeCluster()
+	reviewer := NewReviewer(client)
+	ctx := context.Background()
+
+	if _, ok, err := reviewer.Authenticate(ctx, "stolen"); ok || err != nil {
+		t.Errorf("Expected an unknown token rejected without an error, got %v, %v", ok, err)
+	}
+	user, ok, err := reviewer.Authenticate(ctx, "team-token")
+	if !ok || err != nil || user.Username != "system:serviceaccount:team-a:ci" {
+		t.Fatalf("Expected the team-a service account, got %+v, %v, %v", user, ok, err)
+	}
+
+	for namespace, want := range map[string]bool{"team-a": true, "team-b": false, "": false} {
+		if allowed, err := reviewer.CanRead(ctx, user, namespace); err != nil || allowed != want {
+			t.Errorf("CanRead(%q) = %v, %v; want %v", namespace, allowed, err, want)
+		}
+	}
+	// Decisions are cached
+	reviewer.CanRead(ctx, user, "team-a")
+	if *reviews != 3 {
+		t.Errorf("Expected 3 access reviews, got %d", *reviews)
+	}
+}
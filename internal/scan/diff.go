@@ -0,0 +1,84 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+)
+
+// Diff is how a report compares to a baseline, typically the same lint run
+// against a pull request's base branch
+type Diff struct {
+	// Resolved are baseline violations the report no longer has
+	Resolved []*engine.ViolationResult `json:"resolved"`
+	// Unchanged counts violations present in both
+	Unchanged int `json:"unchanged"`
+}
+
+// ReadReport decodes a report written with WriteJSON
+func ReadReport(r io.Reader) (*Report, error) {
+	var report Report
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return nil, fmt.Errorf("invalid report: %w", err)
+	}
+	return &report, nil
+}
+
+// ReadReportFile decodes the JSON report at path
+func ReadReportFile(path string) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadReport(f)
+}
+
+// violationKey identifies a violation across runs. UIDs of cluster
+// resources change when they are recreated, so the resource's name and
+// environment are used instead.
+func (r *Report) violationKey(v *engine.ViolationResult) string {
+	return v.InvariantID + "|" + r.Environments[v.ResourceUID] + "|" + v.AffectedResource
+}
+
+// Compare narrows the report to the violations base doesn't have, so
+// Failed and every output format only see newly introduced ones. The rest
+// are summarized in Diff.
+func (r *Report) Compare(base *Report) {
+	known := make(map[string]bool, len(base.Violations))
+	for _, v := range base.Violations {
+		known[base.violationKey(v)] = true
+	}
+	current := make(map[string]bool, len(r.Violations))
+	for _, v := range r.Violations {
+		current[r.violationKey(v)] = true
+	}
+
+	diff := &Diff{Resolved: []*engine.ViolationResult{}}
+	introduced := make([]*engine.ViolationResult, 0)
+	for _, v := range r.Violations {
+		if known[r.violationKey(v)] {
+			diff.Unchanged++
+			continue
+		}
+		introduced = append(introduced, v)
+	}
+	for _, v := range base.Violations {
+		if !current[base.violationKey(v)] {
+			diff.Resolved = append(diff.Resolved, v)
+		}
+	}
+
+	r.Violations = introduced
+	r.Diff = diff
+	r.BySeverity = make(map[dsl.Severity]int)
+	r.ByActor = make(map[string]int)
+	for _, v := range introduced {
+		r.BySeverity[v.Severity]++
+		r.ByActor[v.ResponsibleActor]++
+	}
+}
@@ -0,0 +1,79 @@
+package scan
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+)
+
+func violation(id, resource string, severity dsl.Severity) *engine.ViolationResult {
+	return &engine.ViolationResult{
+		InvariantID:      id,
+		Violated:         true,
+		Status:           engine.StatusViolated,
+		AffectedResource: resource,
+		ResourceUID:      "manifest:" + resource,
+		Severity:         severity,
+		Reason:           "broken | badly",
+		Docs:             "Fix " + id,
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := &Report{Violations: []*engine.ViolationResult{
+		violation("pod_image_pinned", "default/web", dsl.Warning),
+		violation("pod_not_privileged", "default/api", dsl.Critical),
+	}}
+	var encoded bytes.Buffer
+	if err := base.WriteJSON(&encoded); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	base, err := ReadReport(&encoded)
+	if err != nil {
+		t.Fatalf("ReadReport failed: %v", err)
+	}
+
+	head := &Report{
+		Sources: map[string]string{"manifest:default/worker": "deploy/worker.yaml"},
+		Violations: []*engine.ViolationResult{
+			violation("pod_image_pinned", "default/web", dsl.Warning),
+			violation("pod_not_privileged", "default/worker", dsl.Critical),
+		},
+	}
+	head.Compare(base)
+
+	if len(head.Violations) != 1 || head.Violations[0].AffectedResource != "default/worker" {
+		t.Fatalf("Expected only the worker violation to be new, got %+v", head.Violations)
+	}
+	if head.Diff.Unchanged != 1 || len(head.Diff.Resolved) != 1 || head.Diff.Resolved[0].AffectedResource != "default/api" {
+		t.Errorf("Expected 1 unchanged and api resolved, got %+v", head.Diff)
+	}
+	if !head.Failed(dsl.Critical) || head.BySeverity[dsl.Warning] != 0 {
+		t.Errorf("Expected the new critical violation alone to count, got %v", head.BySeverity)
+	}
+
+	var md bytes.Buffer
+	if err := head.Write(&md, FormatMarkdown, dsl.Critical, 0); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	for _, want := range []string{
+		"akari: 1 new invariant violations",
+		"1 unchanged · 1 resolved",
+		"| critical | `pod_not_privileged` | default/worker | deploy/worker.yaml | broken \\| badly |",
+		"**`pod_not_privileged`**: Fix pod_not_privileged",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("Expected %q in the comment, got:\n%s", want, md.String())
+		}
+	}
+
+	head.Compare(head)
+	md.Reset()
+	head.WriteMarkdown(&md, 0)
+	if len(head.Violations) != 0 || !strings.Contains(md.String(), "no new invariant violations") {
+		t.Errorf("Expected nothing new against itself, got:\n%s", md.String())
+	}
+}
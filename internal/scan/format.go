@@ -0,0 +1,441 @@
+package scan
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/formatting"
+)
+
+// Output formats of a report
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatJUnit    = "junit"
+	FormatSARIF    = "sarif"
+	FormatMarkdown = "markdown"
+)
+
+// Write writes the report in format. threshold is the --fail-on severity:
+// JUnit reports violations below it as skipped rather than failed.
+func (r *Report) Write(w io.Writer, format string, threshold dsl.Severity, limit int) error {
+	switch format {
+	case FormatText:
+		r.WriteText(w, limit)
+		return nil
+	case FormatJSON:
+		return r.WriteJSON(w)
+	case FormatJUnit:
+		return r.WriteJUnit(w, threshold)
+	case FormatSARIF:
+		return r.WriteSARIF(w)
+	case FormatMarkdown:
+		return r.WriteMarkdown(w, limit)
+	}
+	return fmt.Errorf("invalid format %q, want text, json, junit, sarif or markdown", format)
+}
+
+// ValidFormat reports whether Write accepts format
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatText, FormatJSON, FormatJUnit, FormatSARIF, FormatMarkdown:
+		return true
+	}
+	return false
+}
+
+// ruleIDs returns the IDs of every evaluated invariant, sorted
+func (r *Report) ruleIDs() []string {
+	ids := make([]string, 0, len(r.invariants))
+	for id := range r.invariants {
+		ids = append(ids, id)
+	}
+	for _, v := range r.Violations {
+		if _, ok := r.invariants[v.InvariantID]; !ok {
+			ids = append(ids, v.InvariantID)
+		}
+	}
+	sort.Strings(ids)
+	return dedupe(ids)
+}
+
+func dedupe(sorted []string) []string {
+	out := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// resourceName names the violating resource as kind/namespace/name when the
+// invariant's subject kind is known, suffixed with its environment
+func (r *Report) resourceName(v *engine.ViolationResult) string {
+	name := v.AffectedResource
+	if inv, ok := r.invariants[v.InvariantID]; ok && inv.Subject.Kind != "" {
+		name = inv.Subject.Kind + "/" + name
+	}
+	if env := r.Environments[v.ResourceUID]; env != "" {
+		name += " [" + env + "]"
+	}
+	return name
+}
+
+// remediation lists the hints for fixing a violation: its evidence, the
+// actor to inspect, and the invariant's runbook and docs
+func remediation(v *engine.ViolationResult) string {
+	var b strings.Builder
+	for _, finding := range v.Evidence {
+		fmt.Fprintf(&b, "- %s\n", finding)
+	}
+	if v.ResponsibleActor != "" {
+		fmt.Fprintf(&b, "Inspect %s and related components\n", v.ResponsibleActor)
+	}
+	if v.RunbookURL != "" {
+		fmt.Fprintf(&b, "Runbook: %s\n", v.RunbookURL)
+	}
+	if v.Docs != "" {
+		fmt.Fprintf(&b, "%s\n", v.Docs)
+	}
+	return b.String()
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML with one suite per invariant and
+// one failing test case per violation. An invariant without violations is a
+// single passing case, so the pipeline shows what was checked.
+func (r *Report) WriteJUnit(w io.Writer, threshold dsl.Severity) error {
+	byInvariant := make(map[string][]*engine.ViolationResult)
+	for _, v := range r.Violations {
+		byInvariant[v.InvariantID] = append(byInvariant[v.InvariantID], v)
+	}
+	min := severityRank[threshold]
+
+	suites := junitTestSuites{
+		Name: "akari",
+		Time: r.FinishedAt.Sub(r.StartedAt).Seconds(),
+	}
+	for _, id := range r.ruleIDs() {
+		suite := junitTestSuite{Name: id, Timestamp: r.StartedAt.UTC().Format("2006-01-02T15:04:05")}
+		violations := byInvariant[id]
+		if len(violations) == 0 {
+			suite.Cases = append(suite.Cases, junitTestCase{Name: "all resources", ClassName: id})
+		}
+		for _, v := range violations {
+			message := &junitMessage{
+				Message: v.Reason,
+				Type:    string(v.Severity),
+				Body:    formatting.FormatExplanation(v),
+			}
+			tc := junitTestCase{Name: r.resourceName(v), ClassName: id, File: r.Sources[v.ResourceUID]}
+			if min > 0 && severityRank[v.Severity] >= min {
+				tc.Failure = message
+				suite.Failures++
+			} else {
+				message.Message = fmt.Sprintf("%s below the %s threshold: %s", v.Severity, threshold, v.Reason)
+				tc.Skipped = message
+				suite.Skipped++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Tests = len(suite.Cases)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteMarkdown writes the report as a Markdown summary meant for a pull
+// request comment: a table of up to limit violations (0 lists all) with
+// their remediation folded away below
+func (r *Report) WriteMarkdown(w io.Writer, limit int) error {
+	var b strings.Builder
+	subject := "invariant violations"
+	if r.Diff != nil {
+		subject = "new invariant violations"
+	}
+	if len(r.Violations) == 0 {
+		fmt.Fprintf(&b, "### :white_check_mark: akari: no %s\n", subject)
+	} else {
+		fmt.Fprintf(&b, "### :x: akari: %d %s\n", len(r.Violations), subject)
+	}
+	fmt.Fprintf(&b, "\n%d critical, %d degraded, %d warning", r.BySeverity[dsl.Critical], r.BySeverity[dsl.Degraded], r.BySeverity[dsl.Warning])
+	if r.Diff != nil {
+		fmt.Fprintf(&b, " · %d unchanged · %d resolved", r.Diff.Unchanged, len(r.Diff.Resolved))
+	}
+	b.WriteString("\n")
+
+	if len(r.Violations) > 0 {
+		b.WriteString("\n| Severity | Invariant | Resource | Source | Reason |\n|---|---|---|---|---|\n")
+		for i, v := range r.Violations {
+			if limit > 0 && i >= limit {
+				fmt.Fprintf(&b, "\n_... and %d more_\n", len(r.Violations)-limit)
+				break
+			}
+			fmt.Fprintf(&b, "| %s | `%s` | %s | %s | %s |\n", v.Severity, v.InvariantID, markdownCell(r.resourceName(v)), markdownCell(r.Sources[v.ResourceUID]), markdownCell(v.Reason))
+		}
+
+		b.WriteString("\n<details><summary>How to fix</summary>\n\n")
+		seen := make(map[string]bool)
+		for _, v := range r.Violations {
+			if seen[v.InvariantID] {
+				continue
+			}
+			seen[v.InvariantID] = true
+			fmt.Fprintf(&b, "**`%s`**", v.InvariantID)
+			if v.Docs != "" {
+				fmt.Fprintf(&b, ": %s", v.Docs)
+			}
+			if v.RunbookURL != "" {
+				fmt.Fprintf(&b, " ([runbook](%s))", v.RunbookURL)
+			}
+			b.WriteString("\n\n")
+		}
+		b.WriteString("</details>\n")
+	}
+
+	if r.Diff != nil && len(r.Diff.Resolved) > 0 {
+		fmt.Fprintf(&b, "\n<details><summary>%d resolved</summary>\n\n", len(r.Diff.Resolved))
+		for _, v := range r.Diff.Resolved {
+			fmt.Fprintf(&b, "- `%s` %s\n", v.InvariantID, markdownCell(v.AffectedResource))
+		}
+		b.WriteString("\n</details>\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes text for a Markdown table cell
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// SARIF 2.1.0, the subset code scanning in GitHub and GitLab reads
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string                 `json:"id"`
+	ShortDescription     sarifText              `json:"shortDescription"`
+	FullDescription      *sarifText             `json:"fullDescription,omitempty"`
+	HelpURI              string                 `json:"helpUri,omitempty"`
+	Help                 *sarifText             `json:"help,omitempty"`
+	DefaultConfiguration sarifConfiguration     `json:"defaultConfiguration"`
+	Properties           map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string                 `json:"ruleId"`
+	RuleIndex           int                    `json:"ruleIndex"`
+	Level               string                 `json:"level"`
+	Message             sarifText              `json:"message"`
+	Locations           []sarifLocation        `json:"locations"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevel maps a severity to a SARIF result level
+func sarifLevel(severity dsl.Severity) string {
+	switch severity {
+	case dsl.Critical:
+		return "error"
+	case dsl.Degraded:
+		return "warning"
+	}
+	return "note"
+}
+
+// WriteSARIF writes the report as a SARIF log with one rule per invariant
+// and one result per violation, located at the violating resource and, for
+// manifests, the file declaring it
+func (r *Report) WriteSARIF(w io.Writer) error {
+	driver := sarifDriver{Name: "akari", InformationURI: "https://github.com/aonescu/akari"}
+	index := make(map[string]int)
+	for _, id := range r.ruleIDs() {
+		rule := sarifRule{ID: id, ShortDescription: sarifText{Text: id}}
+		if inv, ok := r.invariants[id]; ok {
+			if inv.Description != "" {
+				rule.ShortDescription.Text = inv.Description
+			}
+			if inv.Docs != "" {
+				rule.FullDescription = &sarifText{Text: inv.Docs}
+				rule.Help = &sarifText{Text: inv.Docs}
+			}
+			rule.HelpURI = inv.RunbookURL
+			rule.DefaultConfiguration.Level = sarifLevel(inv.Severity)
+			if len(inv.Tags) > 0 {
+				rule.Properties = map[string]interface{}{"tags": inv.Tags}
+			}
+		} else {
+			rule.DefaultConfiguration.Level = "warning"
+		}
+		index[id] = len(driver.Rules)
+		driver.Rules = append(driver.Rules, rule)
+	}
+
+	results := make([]sarifResult, 0, len(r.Violations))
+	for _, v := range r.Violations {
+		name := r.resourceName(v)
+		message := fmt.Sprintf("%s: %s", name, v.Reason)
+		if hints := remediation(v); hints != "" {
+			message += "\n" + hints
+		}
+		properties := map[string]interface{}{
+			"severity":          v.Severity,
+			"responsible_actor": v.ResponsibleActor,
+		}
+		if v.Tier != "" {
+			properties["tier"] = v.Tier
+		}
+		if len(v.Correlated) > 0 {
+			properties["correlated"] = v.Correlated
+		}
+
+		location := sarifLocation{LogicalLocations: []sarifLogicalLocation{{
+			Name:               v.AffectedResource,
+			FullyQualifiedName: name,
+			Kind:               "resource",
+		}}}
+		if source := r.Sources[v.ResourceUID]; source != "" {
+			location.PhysicalLocation = &sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(source)}}
+		}
+
+		results = append(results, sarifResult{
+			RuleID:    v.InvariantID,
+			RuleIndex: index[v.InvariantID],
+			Level:     sarifLevel(v.Severity),
+			Message:   sarifText{Text: strings.TrimSpace(message)},
+			Locations: []sarifLocation{location},
+			PartialFingerprints: map[string]string{
+				"akariViolation/v1": v.InvariantID + "/" + r.fingerprintResource(v),
+			},
+			Properties: properties,
+		})
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
+
+// fingerprintResource identifies a resource across scans; UIDs change when
+// a resource is recreated, so the name is preferred
+func (r *Report) fingerprintResource(v *engine.ViolationResult) string {
+	if v.AffectedResource == "" {
+		return v.ResourceUID
+	}
+	if env := r.Environments[v.ResourceUID]; env != "" {
+		return env + "/" + v.AffectedResource
+	}
+	return v.AffectedResource
+}
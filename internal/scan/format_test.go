@@ -0,0 +1,110 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func runReport(t *testing.T) *Report {
+	t.Helper()
+	eng, store := newEngine(dsl.Degraded)
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:             "web_running",
+		Subject:        dsl.Subject{Kind: "Pod", Selector: map[string]string{"app": "web"}},
+		Predicate:      &dsl.Predicate{Field: "status.phase", Operator: dsl.Equals, Value: "Running"},
+		Responsibility: dsl.Responsibility{Primary: "kubelet"},
+		Severity:       dsl.Critical,
+	})
+	client := fake.NewSimpleClientset(pod("web-1", "True"), pod("web-2", "False"))
+
+	report, err := Run(context.Background(), client, eng, store, "")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for _, v := range report.Violations {
+		v.RunbookURL = "https://runbooks.example.com/web"
+	}
+	return report
+}
+
+func TestWriteJUnit(t *testing.T) {
+	report := runReport(t)
+
+	var out bytes.Buffer
+	if err := report.Write(&out, FormatJUnit, dsl.Critical, 0); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
+		t.Fatalf("Invalid JUnit XML: %v\n%s", err, out.String())
+	}
+	if suites.Tests != 2 || suites.Failures != 0 || suites.Skipped != 1 {
+		t.Errorf("Expected 2 tests with the degraded violation skipped under a critical gate, got %+v", suites)
+	}
+
+	out.Reset()
+	if err := report.Write(&out, FormatJUnit, dsl.Degraded, 0); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	suites = junitTestSuites{}
+	if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
+		t.Fatalf("Invalid JUnit XML: %v", err)
+	}
+	if suites.Failures != 1 {
+		t.Fatalf("Expected the degraded violation to fail a degraded gate, got %+v", suites)
+	}
+	for _, suite := range suites.Suites {
+		if suite.Name != "web_ready" {
+			continue
+		}
+		tc := suite.Cases[0]
+		if tc.Name != "Pod/default/web-2" || tc.Failure == nil || !strings.Contains(tc.Failure.Body, "runbooks.example.com") {
+			t.Errorf("Expected a failure for Pod/default/web-2 with its runbook, got %+v", tc)
+		}
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	report := runReport(t)
+
+	var out bytes.Buffer
+	if err := report.Write(&out, FormatSARIF, dsl.Critical, 0); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatalf("Invalid SARIF: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected one SARIF 2.1.0 run, got %+v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || len(run.Results) != 1 {
+		t.Fatalf("Expected 2 rules and 1 result, got %+v", run)
+	}
+
+	result := run.Results[0]
+	if result.RuleID != "web_ready" || run.Tool.Driver.Rules[result.RuleIndex].ID != "web_ready" {
+		t.Errorf("Expected the result to reference web_ready, got %+v", result)
+	}
+	if result.Level != "warning" {
+		t.Errorf("Expected degraded to map to warning, got %s", result.Level)
+	}
+	if name := result.Locations[0].LogicalLocations[0].FullyQualifiedName; name != "Pod/default/web-2" {
+		t.Errorf("Expected the result located at Pod/default/web-2, got %s", name)
+	}
+	if !strings.Contains(result.Message.Text, "Runbook: https://runbooks.example.com/web") {
+		t.Errorf("Expected remediation hints in the message, got %q", result.Message.Text)
+	}
+
+	if err := report.Write(&out, "html", dsl.Critical, 0); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
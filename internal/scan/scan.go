@@ -0,0 +1,268 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/watcher"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SeverityNone as a fail threshold never fails the scan
+const SeverityNone dsl.Severity = "none"
+
+var severityRank = map[dsl.Severity]int{
+	dsl.Warning:  1,
+	dsl.Degraded: 2,
+	dsl.Critical: 3,
+}
+
+// Report is the outcome of a one-shot scan
+type Report struct {
+	StartedAt  time.Time                 `json:"started_at"`
+	FinishedAt time.Time                 `json:"finished_at"`
+	Resources  map[string]int            `json:"resources"`
+	Health     engine.HealthScore        `json:"health"`
+	Violations []*engine.ViolationResult `json:"violations"`
+	BySeverity map[dsl.Severity]int      `json:"by_severity"`
+	ByActor    map[string]int            `json:"by_actor"`
+	// Unknown counts results that couldn't be decided from the discovered
+	// fields; they never fail the scan
+	Unknown int `json:"unknown"`
+	// Sources maps resource UIDs to the files they were read from, when
+	// the report comes from manifests rather than a cluster
+	Sources map[string]string `json:"sources,omitempty"`
+	// Environments maps resource UIDs to the environment whose values
+	// rendered them
+	Environments map[string]string `json:"environments,omitempty"`
+	// Diff is set when the report was compared to a baseline; Violations
+	// then only holds the newly introduced ones
+	Diff *Diff `json:"diff,omitempty"`
+
+	// invariants describe the rules of the JUnit and SARIF reports
+	invariants map[string]dsl.Invariant
+}
+
+// Converters turns the discovered objects into state events. Registering
+// a converter changes how its kind is recorded.
+var Converters = watcher.DefaultConverters()
+
+// Discover lists the cluster's resources once and records them in store.
+// An empty namespace scans every namespace; nodes are always included.
+func Discover(ctx context.Context, client kubernetes.Interface, store state.StateStore, namespace string) (map[string]int, error) {
+	now := time.Now()
+	counts := make(map[string]int)
+	opts := metav1.ListOptions{}
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	services, err := client.CoreV1().Services(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	endpoints, err := client.CoreV1().Endpoints(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list endpoints: %w", err)
+	}
+	deployments, err := client.AppsV1().Deployments(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	replicaSets, err := client.AppsV1().ReplicaSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicasets: %w", err)
+	}
+	claims, err := client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persistentvolumeclaims: %w", err)
+	}
+
+	record := func(kind string, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to record %s: %w", kind, err)
+		}
+		counts[kind]++
+		return nil
+	}
+
+	cluster := &watcher.Cluster{
+		Nodes:       nodes.Items,
+		Pods:        pods.Items,
+		Services:    services.Items,
+		Endpoints:   endpoints.Items,
+		ReplicaSets: replicaSets.Items,
+	}
+	// The API server reports the control plane version of managed clusters,
+	// whose control-plane nodes aren't listed
+	if info, err := client.Discovery().ServerVersion(); err == nil {
+		cluster.ServerVersion = info.GitVersion
+	}
+	var objects []runtime.Object
+	for i := range nodes.Items {
+		objects = append(objects, &nodes.Items[i])
+	}
+	for i := range pods.Items {
+		objects = append(objects, &pods.Items[i])
+	}
+	for i := range services.Items {
+		objects = append(objects, &services.Items[i])
+	}
+	for i := range deployments.Items {
+		objects = append(objects, &deployments.Items[i])
+	}
+	for i := range claims.Items {
+		objects = append(objects, &claims.Items[i])
+	}
+	for _, obj := range objects {
+		event, err := Converters.Convert(obj, cluster, now)
+		if err != nil {
+			return nil, err
+		}
+		if err := record(event.Kind, store.Record(event)); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// Run discovers the cluster into the engine's store and evaluates every
+// invariant once
+func Run(ctx context.Context, client kubernetes.Interface, eng *engine.InvariantEngine, store state.StateStore, namespace string) (*Report, error) {
+	started := time.Now()
+	resources, err := Discover(ctx, client, store, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return Evaluate(eng, resources, started), nil
+}
+
+// Evaluate evaluates every invariant against the resources already recorded
+// in the engine's store
+func Evaluate(eng *engine.InvariantEngine, resources map[string]int, started time.Time) *Report {
+	report := &Report{
+		StartedAt:  started,
+		Resources:  resources,
+		BySeverity: make(map[dsl.Severity]int),
+		ByActor:    make(map[string]int),
+		invariants: make(map[string]dsl.Invariant),
+	}
+	for _, inv := range eng.GetInvariants() {
+		report.invariants[inv.ID] = inv
+	}
+
+	results := eng.EvaluateAll()
+	report.Health = eng.HealthScore(results)
+	report.Unknown = len(engine.FilterByStatus(results, engine.StatusUnknown))
+
+	violations := eng.CollapseFloods(eng.CorrelateRegistries(engine.FilterByStatus(results, engine.StatusViolated)))
+	report.Violations = engine.RankByImpact(violations)
+	for _, v := range report.Violations {
+		report.BySeverity[v.Severity]++
+		report.ByActor[v.ResponsibleActor]++
+	}
+	report.FinishedAt = time.Now()
+	return report
+}
+
+// ParseThreshold validates a --fail-on value
+func ParseThreshold(s string) (dsl.Severity, error) {
+	severity := dsl.Severity(strings.ToLower(s))
+	if _, ok := severityRank[severity]; ok || severity == SeverityNone {
+		return severity, nil
+	}
+	return "", fmt.Errorf("invalid severity %q, want critical, degraded, warning or none", s)
+}
+
+// Failed reports whether any violation is at least as severe as threshold
+func (r *Report) Failed(threshold dsl.Severity) bool {
+	min, ok := severityRank[threshold]
+	if !ok {
+		return false
+	}
+	for _, v := range r.Violations {
+		if severityRank[v.Severity] >= min {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes a human-readable report listing up to limit violations
+// per severity; limit <= 0 lists all of them
+func (r *Report) WriteText(w io.Writer, limit int) {
+	kinds := make([]string, 0, len(r.Resources))
+	total := 0
+	for kind, n := range r.Resources {
+		kinds = append(kinds, fmt.Sprintf("%d %s", n, kind))
+		total += n
+	}
+	sort.Strings(kinds)
+
+	fmt.Fprintf(w, "Scanned %d resources (%s) in %v\n", total, strings.Join(kinds, ", "), r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond))
+	fmt.Fprintf(w, "Health score: %.1f%%\n", r.Health.Score)
+	fmt.Fprintf(w, "Violations: %d critical, %d degraded, %d warning", r.BySeverity[dsl.Critical], r.BySeverity[dsl.Degraded], r.BySeverity[dsl.Warning])
+	if r.Unknown > 0 {
+		fmt.Fprintf(w, " (%d undecided)", r.Unknown)
+	}
+	if r.Diff != nil {
+		fmt.Fprintf(w, " new since the baseline, %d unchanged, %d resolved", r.Diff.Unchanged, len(r.Diff.Resolved))
+	}
+	fmt.Fprintln(w)
+
+	for _, severity := range []dsl.Severity{dsl.Critical, dsl.Degraded, dsl.Warning} {
+		var matching []*engine.ViolationResult
+		for _, v := range r.Violations {
+			if v.Severity == severity {
+				matching = append(matching, v)
+			}
+		}
+		if len(matching) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "\n%s (%d):\n", strings.ToUpper(string(severity)), len(matching))
+		for i, v := range matching {
+			if limit > 0 && i >= limit {
+				fmt.Fprintf(w, "  ... and %d more\n", len(matching)-limit)
+				break
+			}
+			fmt.Fprintf(w, "  %s %s: %s\n", v.InvariantID, r.resourceName(v), v.Reason)
+			if source := r.Sources[v.ResourceUID]; source != "" {
+				if env := r.Environments[v.ResourceUID]; env != "" {
+					source += " (" + env + ")"
+				}
+				fmt.Fprintf(w, "    in %s\n", source)
+			}
+			fmt.Fprintf(w, "    responsible: %s\n", v.ResponsibleActor)
+			if v.RunbookURL != "" {
+				fmt.Fprintf(w, "    runbook: %s\n", v.RunbookURL)
+			}
+			if v.Docs != "" {
+				fmt.Fprintf(w, "    %s\n", v.Docs)
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func pod(name, ready string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: k8stypes.UID("uid-" + name), Labels: map[string]string{"app": "web"}},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionStatus(ready)}},
+		},
+	}
+}
+
+func newEngine(severity dsl.Severity) (*engine.InvariantEngine, state.StateStore) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	for _, inv := range eng.GetInvariants() {
+		eng.DeleteInvariant(inv.ID)
+	}
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:             "web_ready",
+		Subject:        dsl.Subject{Kind: "Pod", Selector: map[string]string{"app": "web"}},
+		Predicate:      &dsl.Predicate{Field: "status.conditions[Ready].status", Operator: dsl.Equals, Value: "True"},
+		Responsibility: dsl.Responsibility{Primary: "kubelet"},
+		Severity:       severity,
+	})
+	return eng, store
+}
+
+func TestRun(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", UID: "uid-node-1"}},
+		pod("web-1", "True"),
+		pod("web-2", "False"),
+	)
+	eng, store := newEngine(dsl.Critical)
+
+	report, err := Run(context.Background(), client, eng, store, "")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Resources["Pod"] != 2 || report.Resources["Node"] != 1 {
+		t.Errorf("Expected 2 pods and 1 node discovered, got %v", report.Resources)
+	}
+	if len(report.Violations) != 1 || report.Violations[0].AffectedResource != "default/web-2" {
+		t.Fatalf("Expected web-2 to violate web_ready, got %+v", report.Violations)
+	}
+	if report.BySeverity[dsl.Critical] != 1 || report.ByActor["kubelet"] != 1 {
+		t.Errorf("Unexpected breakdown: %v %v", report.BySeverity, report.ByActor)
+	}
+
+	for threshold, want := range map[dsl.Severity]bool{
+		dsl.Critical: true,
+		dsl.Warning:  true,
+		SeverityNone: false,
+	} {
+		if got := report.Failed(threshold); got != want {
+			t.Errorf("Failed(%s) = %v, want %v", threshold, got, want)
+		}
+	}
+
+	var text bytes.Buffer
+	report.WriteText(&text, 5)
+	if !strings.Contains(text.String(), "CRITICAL (1)") || !strings.Contains(text.String(), "web-2") {
+		t.Errorf("Expected the text report to list web-2 as critical, got:\n%s", text.String())
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded["violations"] == nil {
+		t.Errorf("Expected a JSON report with violations, got %s (%v)", out.String(), err)
+	}
+}
+
+func TestRun_BelowThreshold(t *testing.T) {
+	client := fake.NewSimpleClientset(pod("web-1", "False"))
+	eng, store := newEngine(dsl.Warning)
+
+	report, err := Run(context.Background(), client, eng, store, "default")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Failed(dsl.Critical) || report.Failed(dsl.Degraded) {
+		t.Error("Expected a warning not to fail a critical or degraded gate")
+	}
+	if !report.Failed(dsl.Warning) {
+		t.Error("Expected a warning to fail a warning gate")
+	}
+}
+
+func TestParseThreshold(t *testing.T) {
+	for _, s := range []string{"critical", "Degraded", "warning", "none"} {
+		if _, err := ParseThreshold(s); err != nil {
+			t.Errorf("ParseThreshold(%q) failed: %v", s, err)
+		}
+	}
+	if _, err := ParseThreshold("fatal"); err == nil {
+		t.Error("Expected an unknown severity to be rejected")
+	}
+}
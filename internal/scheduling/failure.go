@@ -0,0 +1,95 @@
+package scheduling
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+// FieldScheduledMessage holds the scheduler's explanation, taken from the
+// PodScheduled condition or the latest FailedScheduling event of the pod
+const FieldScheduledMessage = "status.conditions[PodScheduled].message"
+
+// ReasonCount is one entry of the scheduler's breakdown, e.g. 3 nodes
+// rejected for "Insufficient cpu"
+type ReasonCount struct {
+	Nodes  int    `json:"nodes"`
+	Reason string `json:"reason"`
+}
+
+// Failure is a parsed FailedScheduling message:
+//
+//	0/5 nodes are available: 3 Insufficient cpu, 2 node(s) had untolerated
+//	taint {dedicated: gpu}. preemption: 0/5 nodes are available: 5
+//	Preemption is not helpful for scheduling.
+type Failure struct {
+	Available  int           `json:"available"`
+	Total      int           `json:"total"`
+	Reasons    []ReasonCount `json:"reasons"`
+	Preemption string        `json:"preemption,omitempty"`
+}
+
+var (
+	failurePattern = regexp.MustCompile(`^(\d+)/(\d+) nodes are available:\s*(.*)$`)
+	countPattern   = regexp.MustCompile(`^(\d+) (.+)$`)
+)
+
+// ParseFailure parses the scheduler's message. ok is false for messages
+// in any other format.
+func ParseFailure(message string) (Failure, bool) {
+	message = strings.TrimSpace(message)
+	main, preemption, _ := strings.Cut(message, ". preemption: ")
+
+	m := failurePattern.FindStringSubmatch(main)
+	if m == nil {
+		return Failure{}, false
+	}
+	f := Failure{
+		Preemption: strings.TrimSuffix(strings.TrimSpace(preemption), "."),
+		Reasons:    make([]ReasonCount, 0),
+	}
+	f.Available, _ = strconv.Atoi(m[1])
+	f.Total, _ = strconv.Atoi(m[2])
+
+	// Reasons are comma separated, but a reason may itself contain commas
+	// (taint lists), so a piece not starting with a count continues the
+	// previous reason
+	for _, piece := range strings.Split(strings.TrimSuffix(m[3], "."), ", ") {
+		if c := countPattern.FindStringSubmatch(piece); c != nil {
+			nodes, _ := strconv.Atoi(c[1])
+			f.Reasons = append(f.Reasons, ReasonCount{Nodes: nodes, Reason: c[2]})
+		} else if n := len(f.Reasons); n > 0 {
+			f.Reasons[n-1].Reason += ", " + piece
+		}
+	}
+	return f, true
+}
+
+// Evidence renders the breakdown as the scheduler's own reasoning
+func (f Failure) Evidence() []string {
+	evidence := []string{fmt.Sprintf("scheduler: %d/%d nodes are available", f.Available, f.Total)}
+	for _, r := range f.Reasons {
+		evidence = append(evidence, fmt.Sprintf("scheduler: %d node(s) rejected: %s", r.Nodes, r.Reason))
+	}
+	if f.Preemption != "" {
+		evidence = append(evidence, "scheduler preemption: "+f.Preemption)
+	}
+	return evidence
+}
+
+// FailureEvidence returns the scheduler's parsed reasoning for an
+// Unschedulable pod followed by the constraint analysis of Explain
+func FailureEvidence(pod types.StateEvent, nodes []types.StateEvent) []string {
+	var evidence []string
+	if message, _ := pod.FieldDiff[FieldScheduledMessage].(string); message != "" {
+		if f, ok := ParseFailure(message); ok {
+			evidence = f.Evidence()
+		} else {
+			evidence = []string{"scheduler: " + message}
+		}
+	}
+	return append(evidence, Explain(pod, nodes)...)
+}
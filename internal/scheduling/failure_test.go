@@ -0,0 +1,61 @@
+package scheduling
+
+import (
+	"testing"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestParseFailure(t *testing.T) {
+	message := "0/5 nodes are available: 3 Insufficient cpu, 1 node(s) had untolerated taint {node-role.kubernetes.io/control-plane: }, " +
+		"1 node(s) didn't match Pod's node affinity/selector. preemption: 0/5 nodes are available: 5 Preemption is not helpful for scheduling."
+
+	f, ok := ParseFailure(message)
+	if !ok {
+		t.Fatal("Expected the message to parse")
+	}
+	if f.Available != 0 || f.Total != 5 {
+		t.Errorf("Unexpected counts: %d/%d", f.Available, f.Total)
+	}
+	want := []ReasonCount{
+		{3, "Insufficient cpu"},
+		{1, "node(s) had untolerated taint {node-role.kubernetes.io/control-plane: }"},
+		{1, "node(s) didn't match Pod's node affinity/selector"},
+	}
+	if len(f.Reasons) != len(want) {
+		t.Fatalf("Expected %d reasons, got %+v", len(want), f.Reasons)
+	}
+	for i := range want {
+		if f.Reasons[i] != want[i] {
+			t.Errorf("Reason %d: got %+v, want %+v", i, f.Reasons[i], want[i])
+		}
+	}
+	if f.Preemption != "0/5 nodes are available: 5 Preemption is not helpful for scheduling" {
+		t.Errorf("Unexpected preemption: %q", f.Preemption)
+	}
+
+	if _, ok := ParseFailure("pod has unbound immediate PersistentVolumeClaims"); ok {
+		t.Error("Expected other messages not to parse")
+	}
+}
+
+func TestParseFailure_ReasonWithCommas(t *testing.T) {
+	f, ok := ParseFailure("0/2 nodes are available: 2 node(s) had untolerated taint {a: b}, {c: d}.")
+	if !ok || len(f.Reasons) != 1 || f.Reasons[0].Reason != "node(s) had untolerated taint {a: b}, {c: d}" {
+		t.Errorf("Expected a single reason spanning the comma, got %+v", f.Reasons)
+	}
+}
+
+func TestFailureEvidence(t *testing.T) {
+	pod := unschedulablePod(map[string]interface{}{
+		FieldScheduledMessage: "0/1 nodes are available: 1 Insufficient memory.",
+	})
+	nodes := []types.StateEvent{node("worker-1", nil, nil)}
+
+	evidence := FailureEvidence(pod, nodes)
+	if len(evidence) != 3 ||
+		evidence[0] != "scheduler: 0/1 nodes are available" ||
+		evidence[1] != "scheduler: 1 node(s) rejected: Insufficient memory" {
+		t.Errorf("Unexpected evidence: %v", evidence)
+	}
+}
@@ -0,0 +1,291 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// Fields describing scheduling constraints. Pods carry the first three,
+// nodes the last two; node labels come from the event's Labels.
+const (
+	FieldNodeSelector  = "spec.nodeSelector"
+	FieldTolerations   = "spec.tolerations"
+	FieldNodeAffinity  = "spec.affinity.nodeAffinity.required"
+	FieldTaints        = "spec.taints"
+	FieldUnschedulable = "spec.unschedulable"
+
+	// FieldScheduledReason is "Unschedulable" once the scheduler gave up
+	FieldScheduledReason = "status.conditions[PodScheduled].reason"
+	ReasonUnschedulable  = "Unschedulable"
+)
+
+// Toleration mirrors corev1.Toleration
+type Toleration struct {
+	Key      string `json:"key,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Effect   string `json:"effect,omitempty"`
+}
+
+// Taint mirrors corev1.Taint
+type Taint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
+func (t Taint) String() string {
+	if t.Value == "" {
+		return fmt.Sprintf("%s:%s", t.Key, t.Effect)
+	}
+	return fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect)
+}
+
+// Requirement mirrors corev1.NodeSelectorRequirement
+type Requirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case "Exists", "DoesNotExist":
+		return fmt.Sprintf("%s %s", r.Key, r.Operator)
+	}
+	return fmt.Sprintf("%s %s [%s]", r.Key, r.Operator, strings.Join(r.Values, ", "))
+}
+
+// Term mirrors corev1.NodeSelectorTerm: its requirements are ANDed, and a
+// node satisfies required affinity when it matches any term
+type Term struct {
+	MatchExpressions []Requirement `json:"matchExpressions"`
+}
+
+func (t Term) String() string {
+	parts := make([]string, len(t.MatchExpressions))
+	for i, r := range t.MatchExpressions {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// Constraints are the node-related scheduling requirements of a pod
+type Constraints struct {
+	NodeSelector map[string]string
+	Tolerations  []Toleration
+	Affinity     []Term
+}
+
+// PodConstraints reads a pod's constraints from its recorded fields
+func PodConstraints(pod types.StateEvent) Constraints {
+	var c Constraints
+	decode(pod.FieldDiff[FieldNodeSelector], &c.NodeSelector)
+	decode(pod.FieldDiff[FieldTolerations], &c.Tolerations)
+	decode(pod.FieldDiff[FieldNodeAffinity], &c.Affinity)
+	return c
+}
+
+// Explain lists why the recorded nodes cannot host an Unschedulable pod,
+// naming the constraint no node satisfies ("no node matches
+// kubernetes.io/arch=arm64"). It returns nil for pods the scheduler has
+// not given up on.
+func Explain(pod types.StateEvent, nodes []types.StateEvent) []string {
+	if reason, _ := pod.FieldDiff[FieldScheduledReason].(string); reason != ReasonUnschedulable {
+		return nil
+	}
+	if len(nodes) == 0 {
+		return []string{"no nodes are recorded"}
+	}
+
+	c := PodConstraints(pod)
+	var evidence []string
+
+	// Each nodeSelector entry on its own first: the usual culprit is one
+	// label, such as an architecture no node has
+	keys := make([]string, 0, len(c.NodeSelector))
+	for key := range c.NodeSelector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		label := map[string]string{key: c.NodeSelector[key]}
+		if countNodes(nodes, func(n types.StateEvent) bool { return state.MatchesSelector(label, n.Labels) }) == 0 {
+			evidence = append(evidence, fmt.Sprintf("no node matches %s=%s", key, c.NodeSelector[key]))
+		}
+	}
+	if len(evidence) == 0 && len(c.NodeSelector) > 1 &&
+		countNodes(nodes, func(n types.StateEvent) bool { return state.MatchesSelector(c.NodeSelector, n.Labels) }) == 0 {
+		evidence = append(evidence, fmt.Sprintf("no single node matches all of nodeSelector %s", formatSelector(c.NodeSelector)))
+	}
+
+	if len(c.Affinity) > 0 &&
+		countNodes(nodes, func(n types.StateEvent) bool { return matchesAffinity(n.Labels, c.Affinity) }) == 0 {
+		terms := make([]string, len(c.Affinity))
+		for i, term := range c.Affinity {
+			terms[i] = "(" + term.String() + ")"
+		}
+		evidence = append(evidence, fmt.Sprintf("no node satisfies required node affinity %s", strings.Join(terms, " OR ")))
+	}
+
+	// Among nodes that pass the label checks, report what else rejects them
+	var cordoned int
+	taints := make(map[string]int)
+	feasible := 0
+	for _, node := range nodes {
+		if !state.MatchesSelector(c.NodeSelector, node.Labels) || (len(c.Affinity) > 0 && !matchesAffinity(node.Labels, c.Affinity)) {
+			continue
+		}
+		if unschedulable, _ := node.FieldDiff[FieldUnschedulable].(bool); unschedulable {
+			cordoned++
+			continue
+		}
+		if taint, ok := untolerated(node, c.Tolerations); ok {
+			taints[taint.String()]++
+			continue
+		}
+		feasible++
+	}
+	if cordoned > 0 {
+		evidence = append(evidence, fmt.Sprintf("%d matching node(s) are cordoned", cordoned))
+	}
+	taintKeys := make([]string, 0, len(taints))
+	for taint := range taints {
+		taintKeys = append(taintKeys, taint)
+	}
+	sort.Strings(taintKeys)
+	for _, taint := range taintKeys {
+		evidence = append(evidence, fmt.Sprintf("%d matching node(s) have untolerated taint %s", taints[taint], taint))
+	}
+
+	if feasible > 0 {
+		evidence = append(evidence, fmt.Sprintf("%d node(s) satisfy the selector, affinity and tolerations; the pod is likely blocked by resources or another scheduler predicate", feasible))
+	}
+	return evidence
+}
+
+func countNodes(nodes []types.StateEvent, fn func(types.StateEvent) bool) int {
+	n := 0
+	for _, node := range nodes {
+		if fn(node) {
+			n++
+		}
+	}
+	return n
+}
+
+func matchesAffinity(labels map[string]string, terms []Term) bool {
+	for _, term := range terms {
+		if matchesTerm(labels, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesTerm(labels map[string]string, term Term) bool {
+	for _, r := range term.MatchExpressions {
+		value, exists := labels[r.Key]
+		switch r.Operator {
+		case "In":
+			if !exists || !contains(r.Values, value) {
+				return false
+			}
+		case "NotIn":
+			if exists && contains(r.Values, value) {
+				return false
+			}
+		case "Exists":
+			if !exists {
+				return false
+			}
+		case "DoesNotExist":
+			if exists {
+				return false
+			}
+		case "Gt", "Lt":
+			if !exists || len(r.Values) != 1 {
+				return false
+			}
+			have, err1 := strconv.ParseInt(value, 10, 64)
+			want, err2 := strconv.ParseInt(r.Values[0], 10, 64)
+			if err1 != nil || err2 != nil || (r.Operator == "Gt" && have <= want) || (r.Operator == "Lt" && have >= want) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// untolerated returns the first NoSchedule or NoExecute taint of the node
+// that none of the tolerations cover
+func untolerated(node types.StateEvent, tolerations []Toleration) (Taint, bool) {
+	var taints []Taint
+	decode(node.FieldDiff[FieldTaints], &taints)
+	for _, taint := range taints {
+		if taint.Effect == "PreferNoSchedule" {
+			continue
+		}
+		if !tolerated(taint, tolerations) {
+			return taint, true
+		}
+	}
+	return Taint{}, false
+}
+
+func tolerated(taint Taint, tolerations []Toleration) bool {
+	for _, t := range tolerations {
+		if t.Effect != "" && t.Effect != taint.Effect {
+			continue
+		}
+		if t.Key == "" && t.Operator == "Exists" {
+			return true
+		}
+		if t.Key != taint.Key {
+			continue
+		}
+		if t.Operator == "Exists" || t.Value == taint.Value {
+			return true
+		}
+	}
+	return false
+}
+
+func formatSelector(selector map[string]string) string {
+	parts := make([]string, 0, len(selector))
+	for k, v := range selector {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// decode converts a field recorded in memory or read back from JSON into
+// its typed form; missing or malformed fields leave target untouched
+func decode(value interface{}, target interface{}) {
+	if value == nil {
+		return
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	json.Unmarshal(data, target)
+}
@@ -0,0 +1,77 @@
+package scheduling
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func node(name string, labels map[string]string, fields map[string]interface{}) types.StateEvent {
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	return types.StateEvent{UID: name, Kind: "Node", Name: name, Labels: labels, FieldDiff: fields}
+}
+
+func unschedulablePod(fields map[string]interface{}) types.StateEvent {
+	fields[FieldScheduledReason] = ReasonUnschedulable
+	return types.StateEvent{UID: "pod-1", Kind: "Pod", Name: "api", FieldDiff: fields}
+}
+
+func TestExplain_NodeSelectorMismatch(t *testing.T) {
+	nodes := []types.StateEvent{
+		node("amd-1", map[string]string{"kubernetes.io/arch": "amd64"}, nil),
+		node("amd-2", map[string]string{"kubernetes.io/arch": "amd64"}, nil),
+	}
+	pod := unschedulablePod(map[string]interface{}{
+		FieldNodeSelector: map[string]string{"kubernetes.io/arch": "arm64"},
+	})
+
+	evidence := Explain(pod, nodes)
+	if len(evidence) != 1 || evidence[0] != "no node matches kubernetes.io/arch=arm64" {
+		t.Errorf("Unexpected evidence: %v", evidence)
+	}
+}
+
+func TestExplain_AffinityAndTaints(t *testing.T) {
+	nodes := []types.StateEvent{
+		node("gpu-1", map[string]string{"pool": "gpu"}, map[string]interface{}{
+			FieldTaints: []interface{}{map[string]interface{}{"key": "dedicated", "value": "gpu", "effect": "NoSchedule"}},
+		}),
+		node("gpu-2", map[string]string{"pool": "gpu"}, map[string]interface{}{FieldUnschedulable: true}),
+		node("cpu-1", map[string]string{"pool": "cpu"}, nil),
+	}
+
+	// Decoded JSON shapes, as read back from PostgreSQL
+	pod := unschedulablePod(map[string]interface{}{
+		FieldNodeAffinity: []interface{}{map[string]interface{}{
+			"matchExpressions": []interface{}{map[string]interface{}{"key": "pool", "operator": "In", "values": []interface{}{"gpu"}}},
+		}},
+	})
+	evidence := Explain(pod, nodes)
+	if len(evidence) != 2 ||
+		evidence[0] != "1 matching node(s) are cordoned" ||
+		evidence[1] != "1 matching node(s) have untolerated taint dedicated=gpu:NoSchedule" {
+		t.Errorf("Unexpected evidence: %v", evidence)
+	}
+
+	pod.FieldDiff[FieldTolerations] = []Toleration{{Key: "dedicated", Operator: "Equal", Value: "gpu", Effect: "NoSchedule"}}
+	evidence = Explain(pod, nodes)
+	if len(evidence) != 2 || !strings.Contains(evidence[1], "likely blocked by resources") {
+		t.Errorf("Expected a tolerated taint to leave a feasible node, got %v", evidence)
+	}
+
+	pod.FieldDiff[FieldNodeAffinity] = []Term{{MatchExpressions: []Requirement{{Key: "pool", Operator: "In", Values: []string{"tpu"}}}}}
+	evidence = Explain(pod, nodes)
+	if len(evidence) == 0 || evidence[0] != "no node satisfies required node affinity (pool In [tpu])" {
+		t.Errorf("Unexpected evidence: %v", evidence)
+	}
+}
+
+func TestExplain_OnlyUnschedulablePods(t *testing.T) {
+	pod := types.StateEvent{FieldDiff: map[string]interface{}{FieldNodeSelector: map[string]string{"a": "b"}}}
+	if evidence := Explain(pod, nil); evidence != nil {
+		t.Errorf("Expected no evidence for a pod the scheduler has not given up on, got %v", evidence)
+	}
+}
@@ -0,0 +1,176 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/linkedin/goavro/v2"
+)
+
+// Format selects how records are serialized
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatAvro Format = "avro"
+)
+
+// Encoder serializes exported records
+type Encoder interface {
+	EncodeEvent(event types.StateEvent) ([]byte, error)
+	EncodeTransition(transition engine.Transition) ([]byte, error)
+}
+
+// TransitionRecord is the wire form of a violation transition
+type TransitionRecord struct {
+	Transition       engine.TransitionType `json:"transition"`
+	InvariantID      string                `json:"invariant_id"`
+	InvariantVersion int                   `json:"invariant_version"`
+	AffectedResource string                `json:"affected_resource"`
+	Severity         string                `json:"severity"`
+	Reason           string                `json:"reason"`
+	ResponsibleActor string                `json:"responsible_actor"`
+	RunbookURL       string                `json:"runbook_url,omitempty"`
+	DetectedAt       time.Time             `json:"detected_at"`
+	At               time.Time             `json:"at"`
+}
+
+func newTransitionRecord(t engine.Transition) TransitionRecord {
+	return TransitionRecord{
+		Transition:       t.Type,
+		InvariantID:      t.Violation.InvariantID,
+		InvariantVersion: t.Violation.InvariantVersion,
+		AffectedResource: t.Violation.AffectedResource,
+		Severity:         string(t.Violation.Severity),
+		Reason:           t.Violation.Reason,
+		ResponsibleActor: t.Violation.ResponsibleActor,
+		RunbookURL:       t.Violation.RunbookURL,
+		DetectedAt:       t.Violation.DetectedAt,
+		At:               t.At,
+	}
+}
+
+func NewEncoder(format Format) (Encoder, error) {
+	switch format {
+	case FormatJSON, "":
+		return JSONEncoder{}, nil
+	case FormatAvro:
+		return NewAvroEncoder()
+	default:
+		return nil, fmt.Errorf("unsupported sink format: %s", format)
+	}
+}
+
+// JSONEncoder writes StateEvents as-is and transitions as TransitionRecords
+type JSONEncoder struct{}
+
+func (JSONEncoder) EncodeEvent(event types.StateEvent) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+func (JSONEncoder) EncodeTransition(transition engine.Transition) ([]byte, error) {
+	return json.Marshal(newTransitionRecord(transition))
+}
+
+// StateEventSchema is the Avro schema of exported state events. Field diff
+// values are JSON-encoded since their types vary by field.
+const StateEventSchema = `{
+	"type": "record",
+	"name": "StateEvent",
+	"namespace": "io.akari",
+	"fields": [
+		{"name": "uid", "type": "string"},
+		{"name": "kind", "type": "string"},
+		{"name": "namespace", "type": "string"},
+		{"name": "name", "type": "string"},
+		{"name": "version", "type": "string"},
+		{"name": "timestamp", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "actor", "type": "string"},
+		{"name": "labels", "type": {"type": "map", "values": "string"}},
+		{"name": "field_diff", "type": {"type": "map", "values": "string"}}
+	]
+}`
+
+// TransitionSchema is the Avro schema of exported violation transitions
+const TransitionSchema = `{
+	"type": "record",
+	"name": "ViolationTransition",
+	"namespace": "io.akari",
+	"fields": [
+		{"name": "transition", "type": "string"},
+		{"name": "invariant_id", "type": "string"},
+		{"name": "invariant_version", "type": "int"},
+		{"name": "affected_resource", "type": "string"},
+		{"name": "severity", "type": "string"},
+		{"name": "reason", "type": "string"},
+		{"name": "responsible_actor", "type": "string"},
+		{"name": "detected_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "runbook_url", "type": "string", "default": ""}
+	]
+}`
+
+// AvroEncoder writes records using Avro single-object encoding, which
+// prefixes each payload with the fingerprint of its schema
+type AvroEncoder struct {
+	events      *goavro.Codec
+	transitions *goavro.Codec
+}
+
+func NewAvroEncoder() (*AvroEncoder, error) {
+	events, err := goavro.NewCodec(StateEventSchema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state event schema: %w", err)
+	}
+	transitions, err := goavro.NewCodec(TransitionSchema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transition schema: %w", err)
+	}
+	return &AvroEncoder{events: events, transitions: transitions}, nil
+}
+
+func (e *AvroEncoder) EncodeEvent(event types.StateEvent) ([]byte, error) {
+	labels := make(map[string]interface{}, len(event.Labels))
+	for k, v := range event.Labels {
+		labels[k] = v
+	}
+	diff := make(map[string]interface{}, len(event.FieldDiff))
+	for field, value := range event.FieldDiff {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode field %s: %w", field, err)
+		}
+		diff[field] = string(encoded)
+	}
+
+	return e.events.SingleFromNative(nil, map[string]interface{}{
+		"uid":        event.UID,
+		"kind":       event.Kind,
+		"namespace":  event.Namespace,
+		"name":       event.Name,
+		"version":    event.Version,
+		"timestamp":  event.Timestamp,
+		"actor":      event.Actor,
+		"labels":     labels,
+		"field_diff": diff,
+	})
+}
+
+func (e *AvroEncoder) EncodeTransition(transition engine.Transition) ([]byte, error) {
+	record := newTransitionRecord(transition)
+	return e.transitions.SingleFromNative(nil, map[string]interface{}{
+		"transition":        string(record.Transition),
+		"invariant_id":      record.InvariantID,
+		"invariant_version": int32(record.InvariantVersion),
+		"affected_resource": record.AffectedResource,
+		"severity":          record.Severity,
+		"reason":            record.Reason,
+		"responsible_actor": record.ResponsibleActor,
+		"detected_at":       record.DetectedAt,
+		"at":                record.At,
+		"runbook_url":       record.RunbookURL,
+	})
+}
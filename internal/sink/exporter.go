@@ -0,0 +1,159 @@
+package sink
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/types"
+)
+
+const (
+	DefaultStateTopic      = "akari.state-events"
+	DefaultViolationTopic  = "akari.violations"
+	DefaultExportQueueSize = 10000
+	publishTimeout         = 10 * time.Second
+	exportBatchSize        = 500
+)
+
+// Config describes where and how records are exported
+type Config struct {
+	StateTopic     string
+	ViolationTopic string
+	QueueSize      int
+}
+
+// Exporter publishes recorded StateEvents and violation transitions.
+// Publishing is asynchronous so a slow broker never blocks ingestion;
+// records are dropped once the buffer is full.
+type Exporter struct {
+	publisher Publisher
+	encoder   Encoder
+	config    Config
+	queue     chan Message
+
+	published    atomic.Uint64
+	dropped      atomic.Uint64
+	encodeErrors atomic.Uint64
+	publishErrs  atomic.Uint64
+}
+
+// ExporterStats is a point-in-time snapshot of exporter metrics
+type ExporterStats struct {
+	Depth         int    `json:"depth"`
+	Published     uint64 `json:"published"`
+	Dropped       uint64 `json:"dropped"`
+	EncodeErrors  uint64 `json:"encode_errors"`
+	PublishErrors uint64 `json:"publish_errors"`
+}
+
+func NewExporter(publisher Publisher, encoder Encoder, config Config) *Exporter {
+	if config.StateTopic == "" {
+		config.StateTopic = DefaultStateTopic
+	}
+	if config.ViolationTopic == "" {
+		config.ViolationTopic = DefaultViolationTopic
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultExportQueueSize
+	}
+	return &Exporter{
+		publisher: publisher,
+		encoder:   encoder,
+		config:    config,
+		queue:     make(chan Message, config.QueueSize),
+	}
+}
+
+// PublishEvent queues a recorded StateEvent, keyed by UID
+func (e *Exporter) PublishEvent(event types.StateEvent) {
+	value, err := e.encoder.EncodeEvent(event)
+	if err != nil {
+		e.encodeErrors.Add(1)
+		log.Printf("Failed to encode event %s: %v", event.UID, err)
+		return
+	}
+	e.enqueue(Message{Topic: e.config.StateTopic, Key: event.UID, Value: value})
+}
+
+// PublishTransition queues a violation transition, keyed by fingerprint
+func (e *Exporter) PublishTransition(transition engine.Transition) {
+	value, err := e.encoder.EncodeTransition(transition)
+	if err != nil {
+		e.encodeErrors.Add(1)
+		log.Printf("Failed to encode transition for %s: %v", transition.Violation.InvariantID, err)
+		return
+	}
+	e.enqueue(Message{Topic: e.config.ViolationTopic, Key: transition.Violation.Fingerprint(), Value: value})
+}
+
+func (e *Exporter) enqueue(r Message) {
+	select {
+	case e.queue <- r:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Run publishes queued records until ctx is cancelled. Records already
+// waiting in the queue are drained together, up to exportBatchSize, and
+// handed to the publisher as one batch when it supports it.
+func (e *Exporter) Run(ctx context.Context) {
+	batch := make([]Message, 0, exportBatchSize)
+	for {
+		select {
+		case m := <-e.queue:
+			batch = append(batch[:0], m)
+		drain:
+			for len(batch) < exportBatchSize {
+				select {
+				case m := <-e.queue:
+					batch = append(batch, m)
+				default:
+					break drain
+				}
+			}
+			e.publish(ctx, batch)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *Exporter) publish(ctx context.Context, batch []Message) {
+	if bp, ok := e.publisher.(BatchPublisher); ok {
+		pubCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+		failed, err := bp.PublishBatch(pubCtx, batch)
+		cancel()
+		if err != nil {
+			e.publishErrs.Add(uint64(failed))
+			log.Printf("Failed to publish %d of %d records: %v", failed, len(batch), err)
+		}
+		e.published.Add(uint64(len(batch) - failed))
+		return
+	}
+
+	for _, m := range batch {
+		pubCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+		err := e.publisher.Publish(pubCtx, m.Topic, m.Key, m.Value)
+		cancel()
+		if err != nil {
+			e.publishErrs.Add(1)
+			log.Printf("Failed to publish to %s: %v", m.Topic, err)
+			continue
+		}
+		e.published.Add(1)
+	}
+}
+
+func (e *Exporter) Stats() ExporterStats {
+	return ExporterStats{
+		Depth:         len(e.queue),
+		Published:     e.published.Load(),
+		Dropped:       e.dropped.Load(),
+		EncodeErrors:  e.encodeErrors.Load(),
+		PublishErrors: e.publishErrs.Load(),
+	}
+}
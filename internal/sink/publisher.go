@@ -0,0 +1,132 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+// Publisher delivers encoded records to a message broker
+type Publisher interface {
+	Publish(ctx context.Context, topic, key string, value []byte) error
+	Close() error
+}
+
+// Message is a single encoded record bound for a topic
+type Message struct {
+	Topic string
+	Key   string
+	Value []byte
+}
+
+// BatchPublisher is implemented by publishers that can deliver several
+// records in one round trip; the exporter prefers it when available.
+// It returns the number of messages that failed alongside the error.
+type BatchPublisher interface {
+	PublishBatch(ctx context.Context, messages []Message) (int, error)
+}
+
+// Backend selects the message broker
+type Backend string
+
+const (
+	BackendKafka Backend = "kafka"
+	BackendNATS  Backend = "nats"
+)
+
+// Open connects to the configured broker. For Kafka, address is a
+// comma-separated broker list; for NATS it is a server URL.
+func Open(backend Backend, address string) (Publisher, error) {
+	switch backend {
+	case BackendKafka:
+		return NewKafkaPublisher(strings.Split(address, ","))
+	case BackendNATS:
+		return NewNATSPublisher(address)
+	default:
+		return nil, fmt.Errorf("unsupported sink backend: %s", backend)
+	}
+}
+
+// KafkaPublisher writes records to Kafka topics, keyed so that all records
+// for a resource land on the same partition
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaPublisher(brokers []string) (*KafkaPublisher, error) {
+	if len(brokers) == 0 || brokers[0] == "" {
+		return nil, fmt.Errorf("no kafka brokers configured")
+	}
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Balancer:               &kafka.Hash{},
+			BatchSize:              exportBatchSize,
+			BatchTimeout:           10 * time.Millisecond,
+			AllowAutoTopicCreation: true,
+		},
+	}, nil
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, topic, key string, value []byte) error {
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Topic: topic,
+		Key:   []byte(key),
+		Value: value,
+	})
+}
+
+// PublishBatch writes messages with a single WriteMessages call so the
+// writer can fill its batches instead of lingering on one record at a time
+func (p *KafkaPublisher) PublishBatch(ctx context.Context, messages []Message) (int, error) {
+	batch := make([]kafka.Message, len(messages))
+	for i, m := range messages {
+		batch[i] = kafka.Message{Topic: m.Topic, Key: []byte(m.Key), Value: m.Value}
+	}
+	err := p.writer.WriteMessages(ctx, batch...)
+	if err == nil {
+		return 0, nil
+	}
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) {
+		return writeErrs.Count(), err
+	}
+	return len(messages), err
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
+
+// NATSPublisher publishes records to NATS subjects, carrying the record key
+// in the Akari-Key header
+type NATSPublisher struct {
+	conn *nats.Conn
+}
+
+func NewNATSPublisher(url string) (*NATSPublisher, error) {
+	if url == "" {
+		url = nats.DefaultURL
+	}
+	conn, err := nats.Connect(url, nats.Name("akari"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return &NATSPublisher{conn: conn}, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, topic, key string, value []byte) error {
+	msg := nats.NewMsg(topic)
+	msg.Header.Set("Akari-Key", key)
+	msg.Data = value
+	return p.conn.PublishMsg(msg)
+}
+
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,197 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/linkedin/goavro/v2"
+)
+
+type capturePublisher struct {
+	mu       sync.Mutex
+	messages map[string][][]byte
+	keys     []string
+}
+
+func (p *capturePublisher) Publish(ctx context.Context, topic, key string, value []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.messages == nil {
+		p.messages = make(map[string][][]byte)
+	}
+	p.messages[topic] = append(p.messages[topic], value)
+	p.keys = append(p.keys, key)
+	return nil
+}
+
+func (p *capturePublisher) Close() error { return nil }
+
+func (p *capturePublisher) count(topic string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.messages[topic])
+}
+
+func testEvent() types.StateEvent {
+	return types.StateEvent{
+		UID:       "pod-1",
+		Kind:      "Pod",
+		Namespace: "default",
+		Name:      "web",
+		Version:   "42",
+		Timestamp: time.Now().UTC().Truncate(time.Millisecond),
+		Labels:    map[string]string{"app": "web"},
+		FieldDiff: map[string]interface{}{"status.phase": "Running", "spec.replicas": 3},
+		Actor:     "kubelet",
+	}
+}
+
+func testTransition() engine.Transition {
+	return engine.Transition{
+		Type: engine.TransitionOpened,
+		Violation: &engine.ViolationResult{
+			InvariantID:      "pod_ready",
+			InvariantVersion: 2,
+			AffectedResource: "default/web",
+			Severity:         "critical",
+			Status:           engine.StatusViolated,
+			DetectedAt:       time.Now(),
+		},
+		At: time.Now(),
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	enc := JSONEncoder{}
+
+	data, err := enc.EncodeTransition(testTransition())
+	if err != nil {
+		t.Fatalf("EncodeTransition failed: %v", err)
+	}
+	var record TransitionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if record.Transition != engine.TransitionOpened || record.InvariantVersion != 2 {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+}
+
+func TestAvroEncoder_RoundTrip(t *testing.T) {
+	enc, err := NewAvroEncoder()
+	if err != nil {
+		t.Fatalf("NewAvroEncoder failed: %v", err)
+	}
+
+	event := testEvent()
+	data, err := enc.EncodeEvent(event)
+	if err != nil {
+		t.Fatalf("EncodeEvent failed: %v", err)
+	}
+
+	codec, _ := goavro.NewCodec(StateEventSchema)
+	native, _, err := codec.NativeFromSingle(data)
+	if err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	decoded := native.(map[string]interface{})
+	if decoded["uid"] != "pod-1" {
+		t.Errorf("Expected uid pod-1, got %v", decoded["uid"])
+	}
+	if ts := decoded["timestamp"].(time.Time); !ts.Equal(event.Timestamp) {
+		t.Errorf("Expected timestamp %v, got %v", event.Timestamp, ts)
+	}
+	diff := decoded["field_diff"].(map[string]interface{})
+	if diff["spec.replicas"] != "3" || diff["status.phase"] != `"Running"` {
+		t.Errorf("Unexpected field diff: %v", diff)
+	}
+
+	if _, err := enc.EncodeTransition(testTransition()); err != nil {
+		t.Errorf("EncodeTransition failed: %v", err)
+	}
+}
+
+func TestNewEncoder_UnknownFormat(t *testing.T) {
+	if _, err := NewEncoder("xml"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestExporter_PublishesToTopics(t *testing.T) {
+	pub := &capturePublisher{}
+	exporter := NewExporter(pub, JSONEncoder{}, Config{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go exporter.Run(ctx)
+
+	exporter.PublishEvent(testEvent())
+	exporter.PublishTransition(testTransition())
+
+	deadline := time.Now().Add(time.Second)
+	for exporter.Stats().Published < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if pub.count(DefaultStateTopic) != 1 || pub.count(DefaultViolationTopic) != 1 {
+		t.Fatalf("Expected one record per topic, got %v", pub.messages)
+	}
+	if pub.keys[0] != "pod-1" || pub.keys[1] != "pod_ready|default/web" {
+		t.Errorf("Unexpected keys: %v", pub.keys)
+	}
+}
+
+func TestExporter_DropsWhenFull(t *testing.T) {
+	exporter := NewExporter(&capturePublisher{}, JSONEncoder{}, Config{QueueSize: 1})
+
+	exporter.PublishEvent(testEvent())
+	exporter.PublishEvent(testEvent())
+
+	if stats := exporter.Stats(); stats.Depth != 1 || stats.Dropped != 1 {
+		t.Errorf("Expected depth 1 and 1 dropped, got %+v", stats)
+	}
+}
+
+type batchPublisher struct {
+	capturePublisher
+	batches []int
+}
+
+func (p *batchPublisher) PublishBatch(ctx context.Context, messages []Message) (int, error) {
+	p.mu.Lock()
+	p.batches = append(p.batches, len(messages))
+	p.mu.Unlock()
+	for _, m := range messages {
+		p.Publish(ctx, m.Topic, m.Key, m.Value)
+	}
+	return 0, nil
+}
+
+func TestExporter_DrainsQueueInBatches(t *testing.T) {
+	pub := &batchPublisher{}
+	exporter := NewExporter(pub, JSONEncoder{}, Config{})
+
+	for i := 0; i < 10; i++ {
+		exporter.PublishEvent(testEvent())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go exporter.Run(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for exporter.Stats().Published < 10 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+	if len(pub.batches) != 1 || pub.batches[0] != 10 {
+		t.Errorf("Expected queued records in one batch of 10, got %v", pub.batches)
+	}
+}
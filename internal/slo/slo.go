@@ -0,0 +1,359 @@
+package slo
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+)
+
+const (
+	// DefaultWindow is the compliance window used when an SLO sets none
+	DefaultWindow = dsl.Duration(30 * 24 * time.Hour)
+	// BucketSize is the resolution of recorded compliance samples
+	BucketSize = 5 * time.Minute
+
+	// Burn rate thresholds from the multiwindow alerting recommendations:
+	// 14.4x over 1h spends 2% of a 30d budget, 6x over 6h spends 5%
+	fastBurnThreshold = 14.4
+	slowBurnThreshold = 6
+)
+
+// SLO states that an invariant holds for at least Target percent of the
+// resource samples taken over Window
+type SLO struct {
+	ID          string       `json:"id"`
+	Description string       `json:"description,omitempty"`
+	InvariantID string       `json:"invariant_id"`
+	Target      float64      `json:"target"`
+	Window      dsl.Duration `json:"window,omitempty"`
+}
+
+// State summarizes an SLO's error budget
+type State string
+
+const (
+	StateOK        State = "ok"
+	StateSlowBurn  State = "slow_burn"
+	StateFastBurn  State = "fast_burn"
+	StateExhausted State = "exhausted"
+	StateNoData    State = "no_data"
+)
+
+// Status is the computed compliance of an SLO at a point in time
+type Status struct {
+	SLO
+	Good            uint64  `json:"good"`
+	Total           uint64  `json:"total"`
+	Compliance      float64 `json:"compliance"`
+	ErrorBudget     float64 `json:"error_budget"`
+	BudgetRemaining float64 `json:"budget_remaining"`
+	BurnRate1h      float64 `json:"burn_rate_1h"`
+	BurnRate6h      float64 `json:"burn_rate_6h"`
+	State           State   `json:"state"`
+}
+
+// Bucket counts resource samples recorded for one invariant in one
+// BucketSize interval
+type Bucket struct {
+	InvariantID string    `json:"invariant_id"`
+	Start       time.Time `json:"start"`
+	Good        uint64    `json:"good"`
+	Total       uint64    `json:"total"`
+}
+
+// Persister stores SLO definitions and compliance buckets so error budgets
+// survive restarts
+type Persister interface {
+	SaveSLO(s SLO) error
+	DeleteSLO(id string) error
+	SaveBucket(b Bucket) error
+}
+
+// Tracker samples invariant compliance after every evaluation pass and
+// computes SLO error budgets from the recorded history
+type Tracker struct {
+	engine    *engine.InvariantEngine
+	store     state.StateStore
+	persister Persister
+
+	mu      sync.RWMutex
+	slos    map[string]SLO
+	buckets map[string]map[int64]*Bucket // invariant -> bucket start -> counts
+}
+
+func NewTracker(eng *engine.InvariantEngine, store state.StateStore) *Tracker {
+	return &Tracker{
+		engine:  eng,
+		store:   store,
+		slos:    make(map[string]SLO),
+		buckets: make(map[string]map[int64]*Bucket),
+	}
+}
+
+// SetPersister enables durable storage of definitions and samples
+func (t *Tracker) SetPersister(p Persister) {
+	t.persister = p
+}
+
+// Validate checks an SLO definition
+func Validate(s SLO) error {
+	if s.ID == "" {
+		return fmt.Errorf("slo id is required")
+	}
+	if s.InvariantID == "" {
+		return fmt.Errorf("invariant_id is required")
+	}
+	if s.Target <= 0 || s.Target >= 100 {
+		return fmt.Errorf("target must be a percentage between 0 and 100 (exclusive)")
+	}
+	if s.Window < 0 {
+		return fmt.Errorf("window must be positive")
+	}
+	return nil
+}
+
+// Define adds or replaces an SLO
+func (t *Tracker) Define(s SLO) (SLO, error) {
+	if s.Window == 0 {
+		s.Window = DefaultWindow
+	}
+	if err := Validate(s); err != nil {
+		return s, err
+	}
+	if _, exists := t.engine.GetInvariantByID(s.InvariantID); !exists {
+		return s, fmt.Errorf("unknown invariant: %s", s.InvariantID)
+	}
+	if t.persister != nil {
+		if err := t.persister.SaveSLO(s); err != nil {
+			return s, err
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.slos[s.ID] = s
+	return s, nil
+}
+
+// Restore loads previously persisted definitions and buckets
+func (t *Tracker) Restore(slos []SLO, buckets []Bucket) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, s := range slos {
+		t.slos[s.ID] = s
+	}
+	for _, b := range buckets {
+		t.bucketsFor(b.InvariantID)[b.Start.Unix()] = &b
+	}
+}
+
+func (t *Tracker) Remove(id string) (SLO, bool, error) {
+	t.mu.RLock()
+	s, exists := t.slos[id]
+	t.mu.RUnlock()
+	if !exists {
+		return s, false, nil
+	}
+
+	if t.persister != nil {
+		if err := t.persister.DeleteSLO(id); err != nil {
+			return s, true, err
+		}
+	}
+
+	t.mu.Lock()
+	delete(t.slos, id)
+	t.mu.Unlock()
+	return s, true, nil
+}
+
+func (t *Tracker) Get(id string) (SLO, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	s, exists := t.slos[id]
+	return s, exists
+}
+
+func (t *Tracker) List() []SLO {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	slos := make([]SLO, 0, len(t.slos))
+	for _, s := range t.slos {
+		slos = append(slos, s)
+	}
+	sort.Slice(slos, func(i, j int) bool { return slos[i].ID < slos[j].ID })
+	return slos
+}
+
+func (t *Tracker) bucketsFor(invariantID string) map[int64]*Bucket {
+	buckets, exists := t.buckets[invariantID]
+	if !exists {
+		buckets = make(map[int64]*Bucket)
+		t.buckets[invariantID] = buckets
+	}
+	return buckets
+}
+
+// Observe records one sample per matching resource for every invariant
+// with an SLO. Unknown and errored results count toward neither good nor
+// total, so missing data does not burn the budget.
+func (t *Tracker) Observe(results []*engine.ViolationResult, at time.Time) {
+	violated := make(map[string]uint64)
+	undetermined := make(map[string]uint64)
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		switch r.Status {
+		case engine.StatusViolated:
+			violated[r.InvariantID]++
+		case engine.StatusUnknown, engine.StatusEvaluationError:
+			undetermined[r.InvariantID]++
+		}
+	}
+
+	start := at.Truncate(BucketSize)
+	var updated []Bucket
+
+	t.mu.Lock()
+	observed := make(map[string]bool)
+	for _, s := range t.slos {
+		if observed[s.InvariantID] {
+			continue
+		}
+		observed[s.InvariantID] = true
+
+		inv, exists := t.engine.GetInvariantByID(s.InvariantID)
+		if !exists {
+			continue
+		}
+		var subjects uint64
+		for _, resource := range t.store.GetLatestByKind(inv.Subject.Kind) {
+			if t.engine.Matches(inv.Subject, resource) {
+				subjects++
+			}
+		}
+		total := subjects - min(subjects, undetermined[inv.ID])
+		bad := min(total, violated[inv.ID])
+
+		buckets := t.bucketsFor(inv.ID)
+		bucket, exists := buckets[start.Unix()]
+		if !exists {
+			bucket = &Bucket{InvariantID: inv.ID, Start: start}
+			buckets[start.Unix()] = bucket
+		}
+		bucket.Good += total - bad
+		bucket.Total += total
+		updated = append(updated, *bucket)
+	}
+	t.prune(at)
+	t.mu.Unlock()
+
+	if t.persister != nil {
+		for _, b := range updated {
+			if err := t.persister.SaveBucket(b); err != nil {
+				log.Printf("Failed to persist SLO samples for %s: %v", b.InvariantID, err)
+				break
+			}
+		}
+	}
+}
+
+// prune drops buckets older than the longest window in use. Callers hold t.mu.
+func (t *Tracker) prune(now time.Time) {
+	longest := time.Duration(DefaultWindow)
+	for _, s := range t.slos {
+		longest = max(longest, time.Duration(s.Window))
+	}
+	cutoff := now.Add(-longest).Unix()
+	for _, buckets := range t.buckets {
+		for start := range buckets {
+			if start < cutoff {
+				delete(buckets, start)
+			}
+		}
+	}
+}
+
+func (t *Tracker) sum(invariantID string, since time.Time) (good, total uint64) {
+	for start, b := range t.buckets[invariantID] {
+		if start >= since.Truncate(BucketSize).Unix() {
+			good += b.Good
+			total += b.Total
+		}
+	}
+	return good, total
+}
+
+// Status computes the compliance and burn rates of an SLO at now
+func (t *Tracker) Status(id string, now time.Time) (Status, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	s, exists := t.slos[id]
+	if !exists {
+		return Status{}, false
+	}
+	return t.status(s, now), true
+}
+
+// Statuses computes every SLO's status at now
+func (t *Tracker) Statuses(now time.Time) []Status {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(t.slos))
+	for _, s := range t.slos {
+		statuses = append(statuses, t.status(s, now))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
+
+func (t *Tracker) status(s SLO, now time.Time) Status {
+	status := Status{SLO: s, ErrorBudget: 1 - s.Target/100, State: StateNoData}
+
+	good, total := t.sum(s.InvariantID, now.Add(-time.Duration(s.Window)))
+	status.Good, status.Total = good, total
+	if total == 0 {
+		status.BudgetRemaining = 1
+		return status
+	}
+
+	badFraction := float64(total-good) / float64(total)
+	status.Compliance = 100 * float64(good) / float64(total)
+	status.BudgetRemaining = 1 - badFraction/status.ErrorBudget
+	status.BurnRate1h = t.burnRate(s, now, time.Hour)
+	status.BurnRate6h = t.burnRate(s, now, 6*time.Hour)
+
+	switch {
+	case status.BudgetRemaining <= 0:
+		status.State = StateExhausted
+	case status.BurnRate1h >= fastBurnThreshold:
+		status.State = StateFastBurn
+	case status.BurnRate6h >= slowBurnThreshold:
+		status.State = StateSlowBurn
+	default:
+		status.State = StateOK
+	}
+	return status
+}
+
+// burnRate is how many times faster than sustainable the budget was spent
+// over the trailing period
+func (t *Tracker) burnRate(s SLO, now time.Time, period time.Duration) float64 {
+	good, total := t.sum(s.InvariantID, now.Add(-period))
+	if total == 0 {
+		return 0
+	}
+	badFraction := float64(total-good) / float64(total)
+	return badFraction / (1 - s.Target/100)
+}
@@ -0,0 +1,125 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func setupTracker(t *testing.T) (*Tracker, *state.MemoryStore) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	eng.UpsertInvariant(dsl.Invariant{
+		ID:        "widget_ready",
+		Subject:   dsl.Subject{Kind: "Widget"},
+		Severity:  dsl.Warning,
+		Predicate: &dsl.Predicate{Field: "status.ready", Operator: dsl.Equals, Value: "True"},
+	})
+
+	tracker := NewTracker(eng, store)
+	if _, err := tracker.Define(SLO{ID: "widgets", InvariantID: "widget_ready", Target: 99}); err != nil {
+		t.Fatalf("Define failed: %v", err)
+	}
+	return tracker, store
+}
+
+func recordWidgets(store *state.MemoryStore, count int) {
+	for i := 0; i < count; i++ {
+		name := string(rune('a' + i))
+		store.Record(types.StateEvent{
+			UID:       "widget-" + name,
+			Kind:      "Widget",
+			Name:      name,
+			Version:   "1",
+			Timestamp: time.Now(),
+			FieldDiff: map[string]interface{}{"status.ready": "True"},
+		})
+	}
+}
+
+func violation(resource string) *engine.ViolationResult {
+	return &engine.ViolationResult{InvariantID: "widget_ready", AffectedResource: resource, Status: engine.StatusViolated}
+}
+
+func TestTracker_Define_Validation(t *testing.T) {
+	tracker, _ := setupTracker(t)
+
+	if _, err := tracker.Define(SLO{ID: "x", InvariantID: "widget_ready", Target: 100}); err == nil {
+		t.Error("Expected error for target of 100")
+	}
+	if _, err := tracker.Define(SLO{ID: "x", InvariantID: "missing", Target: 99}); err == nil {
+		t.Error("Expected error for unknown invariant")
+	}
+
+	def, _ := tracker.Get("widgets")
+	if def.Window != DefaultWindow {
+		t.Errorf("Expected default window, got %v", def.Window)
+	}
+}
+
+func TestTracker_ErrorBudget(t *testing.T) {
+	tracker, store := setupTracker(t)
+	recordWidgets(store, 10)
+
+	now := time.Now()
+	if status, _ := tracker.Status("widgets", now); status.State != StateNoData {
+		t.Errorf("Expected no_data before any samples, got %s", status.State)
+	}
+
+	// 99 healthy passes, then one pass with a single violated widget:
+	// 1 bad sample out of 1000 uses 10% of a 1% budget
+	for i := 0; i < 99; i++ {
+		tracker.Observe(nil, now.Add(-2*time.Hour))
+	}
+	tracker.Observe([]*engine.ViolationResult{violation("a")}, now)
+
+	status, _ := tracker.Status("widgets", now)
+	if status.Total != 1000 || status.Good != 999 {
+		t.Fatalf("Expected 999/1000 good samples, got %d/%d", status.Good, status.Total)
+	}
+	if status.Compliance < 99.89 || status.Compliance > 99.91 {
+		t.Errorf("Expected compliance 99.9, got %f", status.Compliance)
+	}
+	if status.BudgetRemaining < 0.89 || status.BudgetRemaining > 0.91 {
+		t.Errorf("Expected 90%% budget remaining, got %f", status.BudgetRemaining)
+	}
+	// The last hour only holds the bad pass: 10% bad against a 1% budget
+	if status.BurnRate1h < 9.9 || status.BurnRate1h > 10.1 {
+		t.Errorf("Expected 1h burn rate 10, got %f", status.BurnRate1h)
+	}
+	// Below the 14.4x fast burn threshold, and diluted to 0.1x over 6h
+	if status.State != StateOK {
+		t.Errorf("Expected ok, got %s", status.State)
+	}
+}
+
+func TestTracker_UnknownResultsExcluded(t *testing.T) {
+	tracker, store := setupTracker(t)
+	recordWidgets(store, 2)
+
+	tracker.Observe([]*engine.ViolationResult{
+		{InvariantID: "widget_ready", AffectedResource: "a", Status: engine.StatusUnknown},
+	}, time.Now())
+
+	status, _ := tracker.Status("widgets", time.Now())
+	if status.Total != 1 || status.Good != 1 {
+		t.Errorf("Expected 1/1 samples with unknown excluded, got %d/%d", status.Good, status.Total)
+	}
+}
+
+func TestTracker_Exhausted(t *testing.T) {
+	tracker, store := setupTracker(t)
+	recordWidgets(store, 1)
+
+	now := time.Now()
+	tracker.Observe([]*engine.ViolationResult{violation("a")}, now)
+
+	status, _ := tracker.Status("widgets", now)
+	if status.State != StateExhausted || status.BudgetRemaining > 0 {
+		t.Errorf("Expected exhausted budget, got %+v", status)
+	}
+}
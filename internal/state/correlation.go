@@ -0,0 +1,14 @@
+package state
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewCorrelationID returns a random ID that ties a recorded event to the
+// evaluations, violations and notifications it leads to
+func NewCorrelationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
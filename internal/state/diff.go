@@ -0,0 +1,41 @@
+package state
+
+import (
+	"reflect"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+// DiffFields returns the fields of next whose values differ from prev.
+// Fields present in prev but absent from next are reported with a nil value.
+func DiffFields(prev, next map[string]interface{}) map[string]interface{} {
+	diff := make(map[string]interface{})
+	for field, value := range next {
+		if old, exists := prev[field]; !exists || !reflect.DeepEqual(old, value) {
+			diff[field] = value
+		}
+	}
+	for field := range prev {
+		if _, exists := next[field]; !exists {
+			diff[field] = nil
+		}
+	}
+	return diff
+}
+
+// Unchanged reports whether next carries no meaningful change over prev,
+// as produced by informer resyncs of an object that did not change
+func Unchanged(prev, next types.StateEvent) bool {
+	if prev.Kind != next.Kind || prev.Namespace != next.Namespace || prev.Name != next.Name || prev.OwnerUID != next.OwnerUID {
+		return false
+	}
+	if !reflect.DeepEqual(prev.Labels, next.Labels) && (len(prev.Labels) > 0 || len(next.Labels) > 0) {
+		return false
+	}
+	if prev.FieldDiff == nil {
+		// Identity-only cache entries (e.g. loaded from the database) cannot
+		// prove that nothing changed
+		return false
+	}
+	return len(DiffFields(prev.FieldDiff, next.FieldDiff)) == 0
+}
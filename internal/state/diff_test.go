@@ -0,0 +1,56 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestDiffFields(t *testing.T) {
+	prev := map[string]interface{}{"status.phase": "Running", "spec.nodeName": "node-1"}
+	next := map[string]interface{}{"status.phase": "Failed", "status.reason": "Evicted"}
+
+	diff := DiffFields(prev, next)
+	if len(diff) != 3 {
+		t.Fatalf("Expected 3 changed fields, got %d: %v", len(diff), diff)
+	}
+	if diff["status.phase"] != "Failed" {
+		t.Errorf("Expected changed phase, got %v", diff["status.phase"])
+	}
+	if v, exists := diff["spec.nodeName"]; !exists || v != nil {
+		t.Errorf("Expected removed field to be reported as nil, got %v", v)
+	}
+}
+
+func TestMemoryStore_SkipUnchanged(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetSkipUnchanged(true)
+
+	event := types.StateEvent{
+		UID:       "pod-1",
+		Kind:      "Pod",
+		Name:      "pod-1",
+		Version:   "1",
+		FieldDiff: map[string]interface{}{"status.phase": "Running", "status.containerStatuses": []interface{}{true}},
+	}
+	store.Record(event)
+
+	// A resync with a new resource version but identical fields
+	event.Version = "2"
+	event.FieldDiff = map[string]interface{}{"status.phase": "Running", "status.containerStatuses": []interface{}{true}}
+	store.Record(event)
+
+	if store.SkippedUnchanged() != 1 {
+		t.Errorf("Expected 1 skipped event, got %d", store.SkippedUnchanged())
+	}
+	if latest, _ := store.GetByUID("pod-1"); latest.Version != "1" {
+		t.Errorf("Expected unchanged event not to replace version 1, got %s", latest.Version)
+	}
+
+	event.Version = "3"
+	event.FieldDiff = map[string]interface{}{"status.phase": "Failed"}
+	store.Record(event)
+	if latest, _ := store.GetByUID("pod-1"); latest.Version != "3" {
+		t.Errorf("Expected changed event to be recorded, got version %s", latest.Version)
+	}
+}
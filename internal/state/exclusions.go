@@ -0,0 +1,151 @@
+package state
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+// FieldExclusions lists field paths that are never recorded, such as
+// annotations carrying tokens. A * matches any run of characters, dots and
+// slashes included. Labels are matched as metadata.labels.<key>, and
+// nested keys of a full state as their dotted path.
+type FieldExclusions struct {
+	patterns []string
+	matchers []*regexp.Regexp
+}
+
+// FieldExcluder is implemented by stores that drop excluded fields from
+// events before recording them
+type FieldExcluder interface {
+	SetFieldExclusions(exclusions *FieldExclusions)
+	FieldExclusions() *FieldExclusions
+}
+
+// ParseFieldExclusions reads a comma-separated list of field path patterns
+func ParseFieldExclusions(spec string) (*FieldExclusions, error) {
+	patterns := make([]string, 0)
+	for _, pattern := range strings.Split(spec, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return NewFieldExclusions(patterns)
+}
+
+func NewFieldExclusions(patterns []string) (*FieldExclusions, error) {
+	f := &FieldExclusions{}
+	for _, pattern := range patterns {
+		if strings.Trim(pattern, "*") == "" {
+			return nil, fmt.Errorf("field exclusion %q would exclude every field", pattern)
+		}
+		expr := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `.*`)
+		f.patterns = append(f.patterns, pattern)
+		f.matchers = append(f.matchers, regexp.MustCompile(`^`+expr+`$`))
+	}
+	return f, nil
+}
+
+// Patterns returns the configured patterns, none for a nil list
+func (f *FieldExclusions) Patterns() []string {
+	if f == nil {
+		return []string{}
+	}
+	return append([]string{}, f.patterns...)
+}
+
+// Excludes reports whether path must not be recorded
+func (f *FieldExclusions) Excludes(path string) bool {
+	if f == nil {
+		return false
+	}
+	for _, m := range f.matchers {
+		if m.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns event without its excluded fields. The event's maps are
+// copied rather than modified, since callers may hold on to them.
+func (f *FieldExclusions) Apply(event types.StateEvent) types.StateEvent {
+	if f == nil || len(f.matchers) == 0 {
+		return event
+	}
+	if fields, ok := f.scrub("", event.FieldDiff); ok {
+		event.FieldDiff = fields
+	}
+	if len(event.Labels) > 0 {
+		var labels map[string]string
+		for key := range event.Labels {
+			if !f.Excludes("metadata.labels." + key) {
+				continue
+			}
+			if labels == nil {
+				labels = make(map[string]string, len(event.Labels))
+				for k, v := range event.Labels {
+					labels[k] = v
+				}
+			}
+			delete(labels, key)
+		}
+		if labels != nil {
+			event.Labels = labels
+		}
+	}
+	if full, ok := event.FullState.(map[string]interface{}); ok {
+		if scrubbed, changed := f.scrubTree("", full); changed {
+			event.FullState = scrubbed
+		}
+	}
+	return event
+}
+
+// scrub drops the excluded keys of a flat field map, reporting whether any
+// were dropped
+func (f *FieldExclusions) scrub(prefix string, fields map[string]interface{}) (map[string]interface{}, bool) {
+	var kept map[string]interface{}
+	for key := range fields {
+		if !f.Excludes(prefix + key) {
+			continue
+		}
+		if kept == nil {
+			kept = make(map[string]interface{}, len(fields))
+			for k, v := range fields {
+				kept[k] = v
+			}
+		}
+		delete(kept, key)
+	}
+	return kept, kept != nil
+}
+
+// scrubTree drops excluded keys at any depth of a nested map
+func (f *FieldExclusions) scrubTree(prefix string, tree map[string]interface{}) (map[string]interface{}, bool) {
+	kept, changed := f.scrub(prefix, tree)
+	if !changed {
+		kept = tree
+	}
+	for key, value := range kept {
+		child, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		scrubbed, childChanged := f.scrubTree(prefix+key+".", child)
+		if !childChanged {
+			continue
+		}
+		if !changed {
+			kept = make(map[string]interface{}, len(tree))
+			for k, v := range tree {
+				kept[k] = v
+			}
+			changed = true
+		}
+		kept[key] = scrubbed
+	}
+	return kept, changed
+}
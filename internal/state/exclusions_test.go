@@ -0,0 +1,69 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestFieldExclusions(t *testing.T) {
+	exclusions, err := ParseFieldExclusions("metadata.labels.owner-email, metadata.annotations.vault.hashicorp.com/*,spec.env[*].token")
+	if err != nil {
+		t.Fatalf("ParseFieldExclusions failed: %v", err)
+	}
+
+	fields := map[string]interface{}{
+		"status.phase": "Running",
+		"metadata.annotations.vault.hashicorp.com/agent-token": "s3cr3t",
+		"spec.env[API].token": "s3cr3t",
+	}
+	labels := map[string]string{"app": "web", "owner-email": "dev@example.com"}
+	full := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{"vault.hashicorp.com/agent-token": "s3cr3t", "team": "shop"},
+		},
+	}
+	event := exclusions.Apply(types.StateEvent{UID: "pod-1", FieldDiff: fields, Labels: labels, FullState: full})
+
+	if len(event.FieldDiff) != 1 || event.FieldDiff["status.phase"] != "Running" {
+		t.Errorf("Expected only status.phase kept, got %v", event.FieldDiff)
+	}
+	if len(event.Labels) != 1 || event.Labels["app"] != "web" {
+		t.Errorf("Expected only the app label kept, got %v", event.Labels)
+	}
+	annotations := event.FullState.(map[string]interface{})["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+	if len(annotations) != 1 || annotations["team"] != "shop" {
+		t.Errorf("Expected the token annotation dropped from the full state, got %v", annotations)
+	}
+	// The caller's maps are left alone
+	if len(fields) != 3 || len(labels) != 2 || len(full["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})) != 2 {
+		t.Error("Expected Apply to copy the maps it changes")
+	}
+
+	if _, err := ParseFieldExclusions("status.phase,*"); err == nil {
+		t.Error("Expected a pattern excluding every field to be rejected")
+	}
+	var none *FieldExclusions
+	if got := none.Apply(types.StateEvent{FieldDiff: fields}); len(got.FieldDiff) != 3 {
+		t.Error("Expected no exclusions to keep every field")
+	}
+}
+
+func TestMemoryStore_FieldExclusions(t *testing.T) {
+	store := NewMemoryStore()
+	exclusions, _ := NewFieldExclusions([]string{"metadata.annotations.*"})
+	store.SetFieldExclusions(exclusions)
+
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", FieldDiff: map[string]interface{}{
+		"status.phase":               "Running",
+		"metadata.annotations.token": "s3cr3t",
+	}})
+	latest, _ := store.GetByUID("pod-1")
+	if _, recorded := latest.FieldDiff["metadata.annotations.token"]; recorded {
+		t.Errorf("Expected the annotation excluded, got %v", latest.FieldDiff)
+	}
+	history, _ := store.GetHistory("pod-1", 10)
+	if _, recorded := history[0].FieldDiff["metadata.annotations.token"]; recorded {
+		t.Errorf("Expected the annotation excluded from history, got %v", history[0].FieldDiff)
+	}
+}
@@ -0,0 +1,38 @@
+package state
+
+import (
+	"sync"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+// RecordObserver is implemented by stores that can notify subscribers of
+// every event they record
+type RecordObserver interface {
+	OnRecord(fn func(types.StateEvent))
+}
+
+// RecordHooks is embedded by stores to implement RecordObserver
+type RecordHooks struct {
+	mu    sync.RWMutex
+	hooks []func(types.StateEvent)
+}
+
+// OnRecord registers fn to be called after each successfully recorded event.
+// Hooks run synchronously on the recording goroutine and must not block.
+func (h *RecordHooks) OnRecord(fn func(types.StateEvent)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks = append(h.hooks, fn)
+}
+
+// NotifyRecorded calls every registered hook with event
+func (h *RecordHooks) NotifyRecorded(event types.StateEvent) {
+	h.mu.RLock()
+	hooks := h.hooks
+	h.mu.RUnlock()
+
+	for _, fn := range hooks {
+		fn(event)
+	}
+}
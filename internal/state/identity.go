@@ -0,0 +1,114 @@
+package state
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+// FieldController holds "Kind/name" of the controller owning a resource
+const FieldController = "metadata.ownerReferences[controller]"
+
+// LogicalKey derives an identity for event that survives recreation:
+//
+//	Pod/shop/web-0                  StatefulSet and static pods keep their name
+//	Pod/shop/Deployment/api         pods of a Deployment's ReplicaSets
+//	Pod/shop/DaemonSet/agent@node-1 a DaemonSet's pod on one node
+//	Pod/shop/Job/migrate            pods of other controllers
+//	Service/shop/api                resources without a controller
+func LogicalKey(event types.StateEvent) string {
+	base := event.Kind + "/" + event.Namespace + "/"
+	ref, _ := event.FieldDiff[FieldController].(string)
+	ownerKind, ownerName, ok := strings.Cut(ref, "/")
+	if !ok {
+		return base + event.Name
+	}
+
+	switch ownerKind {
+	case "StatefulSet", "Node":
+		// Ordinal and static pod names survive recreation
+		return base + event.Name
+	case "ReplicaSet":
+		if hash := event.Labels["pod-template-hash"]; hash != "" {
+			if deployment, ok := strings.CutSuffix(ownerName, "-"+hash); ok {
+				return base + "Deployment/" + deployment
+			}
+		}
+	case "DaemonSet":
+		if node, _ := event.FieldDiff["spec.nodeName"].(string); node != "" {
+			return base + "DaemonSet/" + ownerName + "@" + node
+		}
+	}
+	return base + ownerKind + "/" + ownerName
+}
+
+// IdentityIndex maps UIDs to logical identities and back
+type IdentityIndex struct {
+	mu   sync.RWMutex
+	keys map[string]string   // uid -> logical key
+	uids map[string][]string // logical key -> uids in the order seen
+}
+
+func NewIdentityIndex() *IdentityIndex {
+	return &IdentityIndex{
+		keys: make(map[string]string),
+		uids: make(map[string][]string),
+	}
+}
+
+// Observe records the logical identity of event's UID and returns it. An
+// event without a controller reference doesn't override one derived from
+// an earlier event that had it.
+func (idx *IdentityIndex) Observe(event types.StateEvent) string {
+	key := LogicalKey(event)
+	_, controlled := event.FieldDiff[FieldController]
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if current, exists := idx.keys[event.UID]; exists && (current == key || !controlled) {
+		return current
+	}
+	idx.set(event.UID, key)
+	return key
+}
+
+// Set records a known logical identity, such as one loaded from storage
+func (idx *IdentityIndex) Set(uid, key string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.set(uid, key)
+}
+
+func (idx *IdentityIndex) set(uid, key string) {
+	if previous, exists := idx.keys[uid]; exists {
+		idx.uids[previous] = removeUID(idx.uids[previous], uid)
+		if len(idx.uids[previous]) == 0 {
+			delete(idx.uids, previous)
+		}
+	}
+	idx.keys[uid] = key
+	idx.uids[key] = append(idx.uids[key], uid)
+}
+
+func (idx *IdentityIndex) LogicalKey(uid string) (string, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	key, ok := idx.keys[uid]
+	return key, ok
+}
+
+func (idx *IdentityIndex) UIDsForKey(key string) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return append([]string(nil), idx.uids[key]...)
+}
+
+func removeUID(uids []string, uid string) []string {
+	for i, u := range uids {
+		if u == uid {
+			return append(uids[:i:i], uids[i+1:]...)
+		}
+	}
+	return uids
+}
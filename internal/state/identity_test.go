@@ -0,0 +1,86 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestLogicalKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		event types.StateEvent
+		want  string
+	}{
+		{
+			name:  "no controller",
+			event: types.StateEvent{Kind: "Service", Namespace: "shop", Name: "api"},
+			want:  "Service/shop/api",
+		},
+		{
+			name: "deployment pod",
+			event: types.StateEvent{
+				Kind: "Pod", Namespace: "shop", Name: "api-7f9c6d-x2k4p",
+				Labels:    map[string]string{"pod-template-hash": "7f9c6d"},
+				FieldDiff: map[string]interface{}{FieldController: "ReplicaSet/api-7f9c6d"},
+			},
+			want: "Pod/shop/Deployment/api",
+		},
+		{
+			name: "statefulset pod",
+			event: types.StateEvent{
+				Kind: "Pod", Namespace: "shop", Name: "db-0",
+				FieldDiff: map[string]interface{}{FieldController: "StatefulSet/db"},
+			},
+			want: "Pod/shop/db-0",
+		},
+		{
+			name: "daemonset pod",
+			event: types.StateEvent{
+				Kind: "Pod", Namespace: "kube-system", Name: "agent-abcde",
+				FieldDiff: map[string]interface{}{FieldController: "DaemonSet/agent", "spec.nodeName": "node-1"},
+			},
+			want: "Pod/kube-system/DaemonSet/agent@node-1",
+		},
+		{
+			name: "job pod",
+			event: types.StateEvent{
+				Kind: "Pod", Namespace: "shop", Name: "migrate-q8z7r",
+				FieldDiff: map[string]interface{}{FieldController: "Job/migrate"},
+			},
+			want: "Pod/shop/Job/migrate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LogicalKey(tt.event); got != tt.want {
+				t.Errorf("LogicalKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_FollowsRecreatedPods(t *testing.T) {
+	store := NewMemoryStore()
+	pod := func(uid, name, hash string) types.StateEvent {
+		return types.StateEvent{
+			UID: uid, Kind: "Pod", Namespace: "shop", Name: name, Version: "1", Timestamp: time.Now(),
+			Labels:    map[string]string{"pod-template-hash": hash},
+			FieldDiff: map[string]interface{}{FieldController: "ReplicaSet/api-" + hash},
+		}
+	}
+	store.Record(pod("uid-1", "api-aaa-1", "aaa"))
+	store.Record(pod("uid-2", "api-bbb-1", "bbb"))
+
+	// A later event without the owner reference keeps the known identity
+	store.Record(types.StateEvent{UID: "uid-1", Kind: "Pod", Namespace: "shop", Name: "api-aaa-1", Version: "2", Timestamp: time.Now()})
+
+	key, ok := store.LogicalKey("uid-2")
+	if !ok || key != "Pod/shop/Deployment/api" {
+		t.Fatalf("Expected uid-2 to map to the api Deployment, got %q", key)
+	}
+	if uids := store.UIDsForKey(key); len(uids) != 2 || uids[0] != "uid-1" || uids[1] != "uid-2" {
+		t.Errorf("Expected both incarnations in order, got %v", uids)
+	}
+}
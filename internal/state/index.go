@@ -0,0 +1,257 @@
+package state
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+// LatestIndex holds the most recent event per UID, sharded by kind so that
+// recording Pods does not block evaluation reads of Nodes or Services.
+type LatestIndex struct {
+	mu     sync.RWMutex // guards the shards map only
+	shards map[string]*kindShard
+	kinds  sync.Map // uid -> kind
+
+	hits, misses atomic.Uint64
+}
+
+type kindShard struct {
+	mu     sync.RWMutex
+	latest map[string]types.StateEvent
+	uids   []string // insertion order, keeps GetLatestByKind stable
+	// labels maps each key=value label to the UIDs carrying it
+	labels map[string]map[string]bool
+}
+
+func NewLatestIndex() *LatestIndex {
+	return &LatestIndex{
+		shards: make(map[string]*kindShard),
+	}
+}
+
+func (idx *LatestIndex) shard(kind string, create bool) *kindShard {
+	idx.mu.RLock()
+	shard, exists := idx.shards[kind]
+	idx.mu.RUnlock()
+	if exists || !create {
+		return shard
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if shard, exists = idx.shards[kind]; !exists {
+		shard = &kindShard{latest: make(map[string]types.StateEvent), labels: make(map[string]map[string]bool)}
+		idx.shards[kind] = shard
+	}
+	return shard
+}
+
+// Put stores event as the latest state for its UID
+func (idx *LatestIndex) Put(event types.StateEvent) {
+	if previous, loaded := idx.kinds.Swap(event.UID, event.Kind); loaded && previous.(string) != event.Kind {
+		idx.remove(previous.(string), event.UID)
+	}
+
+	shard := idx.shard(event.Kind, true)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if previous, exists := shard.latest[event.UID]; !exists {
+		shard.uids = append(shard.uids, event.UID)
+	} else {
+		shard.unlabel(previous)
+	}
+	shard.latest[event.UID] = event
+	for key, value := range event.Labels {
+		label := key + "=" + value
+		if shard.labels[label] == nil {
+			shard.labels[label] = make(map[string]bool)
+		}
+		shard.labels[label][event.UID] = true
+	}
+}
+
+// unlabel drops event from the label index. Callers hold shard.mu.
+func (shard *kindShard) unlabel(event types.StateEvent) {
+	for key, value := range event.Labels {
+		label := key + "=" + value
+		delete(shard.labels[label], event.UID)
+		if len(shard.labels[label]) == 0 {
+			delete(shard.labels, label)
+		}
+	}
+}
+
+// Remove drops uid, so the latest state no longer includes it
+func (idx *LatestIndex) Remove(uid string) {
+	if kind, loaded := idx.kinds.LoadAndDelete(uid); loaded {
+		idx.remove(kind.(string), uid)
+	}
+}
+
+func (idx *LatestIndex) remove(kind, uid string) {
+	shard := idx.shard(kind, false)
+	if shard == nil {
+		return
+	}
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if previous, exists := shard.latest[uid]; exists {
+		shard.unlabel(previous)
+	}
+	delete(shard.latest, uid)
+	for i, existing := range shard.uids {
+		if existing == uid {
+			shard.uids = append(shard.uids[:i:i], shard.uids[i+1:]...)
+			break
+		}
+	}
+}
+
+// LatestByKind returns the latest event of every UID of the given kind
+func (idx *LatestIndex) LatestByKind(kind string) []types.StateEvent {
+	shard := idx.shard(kind, false)
+	if shard == nil {
+		return nil
+	}
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	results := make([]types.StateEvent, 0, len(shard.uids))
+	for _, uid := range shard.uids {
+		results = append(results, shard.latest[uid])
+	}
+	return results
+}
+
+// BySelector returns the latest event of every UID of the given kind
+// whose labels carry every label of selector, ordered by namespace and
+// name. An empty selector matches nothing, as for a Service without one.
+func (idx *LatestIndex) BySelector(kind string, selector map[string]string) []types.StateEvent {
+	if len(selector) == 0 {
+		return nil
+	}
+	shard := idx.shard(kind, false)
+	if shard == nil {
+		return nil
+	}
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	// Walk the UIDs of the rarest label, checking the others on each
+	var candidates map[string]bool
+	for key, value := range selector {
+		uids := shard.labels[key+"="+value]
+		if len(uids) == 0 {
+			return nil
+		}
+		if candidates == nil || len(uids) < len(candidates) {
+			candidates = uids
+		}
+	}
+	var results []types.StateEvent
+	for uid := range candidates {
+		if event := shard.latest[uid]; MatchesSelector(selector, event.Labels) {
+			results = append(results, event)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Namespace != results[j].Namespace {
+			return results[i].Namespace < results[j].Namespace
+		}
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
+
+// MatchesSelector reports whether labels carry every key/value of selector,
+// as an equality-based label selector matches. An empty selector matches
+// any labels.
+func MatchesSelector(selector, labels map[string]string) bool {
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the latest event for a UID
+func (idx *LatestIndex) Get(uid string) (types.StateEvent, bool) {
+	event, exists := idx.get(uid)
+	if exists {
+		idx.hits.Add(1)
+	} else {
+		idx.misses.Add(1)
+	}
+	return event, exists
+}
+
+func (idx *LatestIndex) get(uid string) (types.StateEvent, bool) {
+	kind, exists := idx.kinds.Load(uid)
+	if !exists {
+		return types.StateEvent{}, false
+	}
+	shard := idx.shard(kind.(string), false)
+	if shard == nil {
+		return types.StateEvent{}, false
+	}
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	event, exists := shard.latest[uid]
+	return event, exists
+}
+
+// Kinds returns every kind with at least one tracked object
+func (idx *LatestIndex) Kinds() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	kinds := make([]string, 0, len(idx.shards))
+	for kind := range idx.shards {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// Len returns the number of tracked UIDs
+func (idx *LatestIndex) Len() int {
+	total := 0
+	for _, kind := range idx.Kinds() {
+		shard := idx.shard(kind, false)
+		shard.mu.RLock()
+		total += len(shard.latest)
+		shard.mu.RUnlock()
+	}
+	return total
+}
+
+// IndexStats describes the contents and lookups of a LatestIndex
+type IndexStats struct {
+	Objects  int            `json:"objects"`
+	ByKind   map[string]int `json:"by_kind"`
+	Hits     uint64         `json:"hits"`
+	Misses   uint64         `json:"misses"`
+	HitRatio float64        `json:"hit_ratio"`
+}
+
+// Stats counts the tracked objects per kind and the UID lookups that found
+// or missed an object
+func (idx *LatestIndex) Stats() IndexStats {
+	stats := IndexStats{ByKind: make(map[string]int), Hits: idx.hits.Load(), Misses: idx.misses.Load()}
+	for _, kind := range idx.Kinds() {
+		shard := idx.shard(kind, false)
+		shard.mu.RLock()
+		stats.ByKind[kind] = len(shard.latest)
+		shard.mu.RUnlock()
+		stats.Objects += stats.ByKind[kind]
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
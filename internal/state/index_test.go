@@ -0,0 +1,154 @@
+package state
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestLatestIndex_KindChange(t *testing.T) {
+	idx := NewLatestIndex()
+
+	idx.Put(types.StateEvent{UID: "uid-1", Kind: "Pod", Name: "a"})
+	idx.Put(types.StateEvent{UID: "uid-1", Kind: "Node", Name: "a"})
+
+	if pods := idx.LatestByKind("Pod"); len(pods) != 0 {
+		t.Errorf("Expected no pods after kind change, got %d", len(pods))
+	}
+	if nodes := idx.LatestByKind("Node"); len(nodes) != 1 {
+		t.Errorf("Expected 1 node, got %d", len(nodes))
+	}
+	if idx.Len() != 1 {
+		t.Errorf("Expected 1 tracked UID, got %d", idx.Len())
+	}
+}
+
+func TestLatestIndex_PreservesInsertionOrder(t *testing.T) {
+	idx := NewLatestIndex()
+
+	for i := 0; i < 5; i++ {
+		idx.Put(types.StateEvent{UID: fmt.Sprintf("pod-%d", i), Kind: "Pod"})
+	}
+	// Updating an existing UID must not move it
+	idx.Put(types.StateEvent{UID: "pod-0", Kind: "Pod", Version: "2"})
+
+	pods := idx.LatestByKind("Pod")
+	for i, pod := range pods {
+		if pod.UID != fmt.Sprintf("pod-%d", i) {
+			t.Errorf("Expected pod-%d at position %d, got %s", i, i, pod.UID)
+		}
+	}
+	if pods[0].Version != "2" {
+		t.Errorf("Expected updated version 2, got %s", pods[0].Version)
+	}
+}
+
+func TestLatestIndex_BySelector(t *testing.T) {
+	idx := NewLatestIndex()
+
+	idx.Put(types.StateEvent{UID: "pod-2", Kind: "Pod", Namespace: "default", Name: "web-b", Labels: map[string]string{"app": "web", "tier": "front"}})
+	idx.Put(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web-a", Labels: map[string]string{"app": "web"}})
+	idx.Put(types.StateEvent{UID: "pod-3", Kind: "Pod", Namespace: "default", Name: "worker", Labels: map[string]string{"app": "worker"}})
+	idx.Put(types.StateEvent{UID: "rs-1", Kind: "ReplicaSet", Namespace: "default", Name: "web", Labels: map[string]string{"app": "web"}})
+
+	names := func(events []types.StateEvent) []string {
+		var names []string
+		for _, e := range events {
+			names = append(names, e.Name)
+		}
+		return names
+	}
+	if got := names(idx.BySelector("Pod", map[string]string{"app": "web"})); !slices.Equal(got, []string{"web-a", "web-b"}) {
+		t.Errorf("Expected the web pods ordered by name, got %v", got)
+	}
+	if got := names(idx.BySelector("Pod", map[string]string{"app": "web", "tier": "front"})); !slices.Equal(got, []string{"web-b"}) {
+		t.Errorf("Expected only the pod carrying both labels, got %v", got)
+	}
+	if got := idx.BySelector("Pod", map[string]string{"app": "db"}); len(got) != 0 {
+		t.Errorf("Expected no pods for an unused label, got %v", names(got))
+	}
+	if got := idx.BySelector("Pod", nil); len(got) != 0 {
+		t.Errorf("Expected an empty selector to match no pod, got %v", names(got))
+	}
+
+	// Relabelling or changing kind moves the UID out of the old labels
+	idx.Put(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web-a", Labels: map[string]string{"app": "worker"}})
+	idx.Put(types.StateEvent{UID: "pod-2", Kind: "Node", Name: "web-b", Labels: map[string]string{"app": "web"}})
+	if got := idx.BySelector("Pod", map[string]string{"app": "web"}); len(got) != 0 {
+		t.Errorf("Expected no web pods left, got %v", names(got))
+	}
+	if got := names(idx.BySelector("Pod", map[string]string{"app": "worker"})); !slices.Equal(got, []string{"web-a", "worker"}) {
+		t.Errorf("Expected the relabelled pod among the workers, got %v", got)
+	}
+}
+
+// BenchmarkMemoryStore_RecordWhileReading measures evaluation-style reads
+// of one kind while another kind is being ingested concurrently.
+func BenchmarkMemoryStore_RecordWhileReading(b *testing.B) {
+	store := NewMemoryStore()
+	for i := 0; i < 1000; i++ {
+		store.Record(types.StateEvent{UID: fmt.Sprintf("node-%d", i), Kind: "Node", Timestamp: time.Now()})
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				store.Record(types.StateEvent{UID: fmt.Sprintf("pod-%d", i%5000), Kind: "Pod"})
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = store.GetLatestByKind("Node")
+		}
+	})
+	b.StopTimer()
+
+	close(stop)
+	wg.Wait()
+}
+
+func BenchmarkMemoryStore_ParallelRecord(b *testing.B) {
+	store := NewMemoryStore()
+	kinds := []string{"Pod", "Node", "Service", "Deployment"}
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			kind := kinds[i%len(kinds)]
+			store.Record(types.StateEvent{UID: fmt.Sprintf("%s-%d", kind, i%1000), Kind: kind})
+			i++
+		}
+	})
+}
+
+func TestLatestIndex_Stats(t *testing.T) {
+	idx := NewLatestIndex()
+	idx.Put(types.StateEvent{UID: "pod-1", Kind: "Pod"})
+	idx.Put(types.StateEvent{UID: "pod-2", Kind: "Pod"})
+	idx.Put(types.StateEvent{UID: "node-1", Kind: "Node"})
+	idx.Get("pod-1")
+	idx.Get("node-1")
+	idx.Get("missing")
+
+	stats := idx.Stats()
+	if stats.Objects != 3 || stats.ByKind["Pod"] != 2 || stats.ByKind["Node"] != 1 {
+		t.Errorf("Unexpected object counts %+v", stats)
+	}
+	if stats.Hits != 2 || stats.Misses != 1 || stats.HitRatio < 0.66 || stats.HitRatio > 0.67 {
+		t.Errorf("Unexpected lookup counts %+v", stats)
+	}
+}
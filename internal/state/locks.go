@@ -0,0 +1,25 @@
+package state
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// uidLockStripes is how many locks UIDLocks spreads UIDs over
+const uidLockStripes = 64
+
+// UIDLocks serializes the recording of each UID without one lock for the
+// whole store, so a UID's events reach the latest index in the order they
+// were persisted. The zero value is ready to use.
+type UIDLocks struct {
+	stripes [uidLockStripes]sync.Mutex
+}
+
+// Lock locks uid's stripe and returns its unlock
+func (l *UIDLocks) Lock(uid string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(uid))
+	m := &l.stripes[h.Sum32()%uidLockStripes]
+	m.Lock()
+	return m.Unlock
+}
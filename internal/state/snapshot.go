@@ -0,0 +1,120 @@
+package state
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+// DefaultSnapshotInterval is how often RunSnapshots saves the store
+const DefaultSnapshotInterval = 5 * time.Minute
+
+// WriteSnapshot writes every recorded event, oldest first, as
+// gzip-compressed JSON lines
+func (s *MemoryStore) WriteSnapshot(w io.Writer) error {
+	s.mu.Lock()
+	events := make([]types.StateEvent, len(s.events))
+	copy(events, s.events)
+	s.mu.Unlock()
+
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// LoadSnapshot replays the events of a snapshot into the store. Record
+// hooks are not notified; the events were exported when first recorded.
+func (s *MemoryStore) LoadSnapshot(r io.Reader) (int, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("invalid snapshot: %w", err)
+	}
+	dec := json.NewDecoder(bufio.NewReader(zr))
+
+	loaded := 0
+	for {
+		var event types.StateEvent
+		if err := dec.Decode(&event); err == io.EOF {
+			return loaded, nil
+		} else if err != nil {
+			return loaded, fmt.Errorf("corrupt snapshot after %d events: %w", loaded, err)
+		}
+		s.mu.Lock()
+		s.events = append(s.events, event)
+		s.mu.Unlock()
+		s.latest.Put(event)
+		s.identities.Observe(event)
+		loaded++
+	}
+}
+
+// SaveSnapshotFile writes a snapshot to path, replacing it atomically so a
+// crash mid-write leaves the previous snapshot intact
+func (s *MemoryStore) SaveSnapshotFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := s.WriteSnapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshotFile loads the snapshot at path; a missing file loads nothing
+func (s *MemoryStore) LoadSnapshotFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return s.LoadSnapshot(f)
+}
+
+// RunSnapshots saves the store to path every interval until ctx is done,
+// then once more
+func (s *MemoryStore) RunSnapshots(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if err := s.SaveSnapshotFile(path); err != nil {
+				log.Printf("Failed to snapshot state: %v", err)
+			}
+			return
+		}
+		if err := s.SaveSnapshotFile(path); err != nil {
+			log.Printf("Failed to snapshot state: %v", err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestMemoryStore_SnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "akari.snapshot")
+	store := NewMemoryStore()
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, phase := range []string{"Pending", "Running"} {
+		store.Record(types.StateEvent{
+			UID: "pod-1", Kind: "Pod", Namespace: "shop", Name: "api-7f9c-x2x", Version: phase,
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			FieldDiff: map[string]interface{}{"status.phase": phase},
+		})
+	}
+	if err := store.SaveSnapshotFile(path); err != nil {
+		t.Fatalf("SaveSnapshotFile failed: %v", err)
+	}
+
+	restored := NewMemoryStore()
+	notified := 0
+	restored.OnRecord(func(types.StateEvent) { notified++ })
+	n, err := restored.LoadSnapshotFile(path)
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 events loaded, got %d: %v", n, err)
+	}
+	if notified != 0 {
+		t.Errorf("Expected restored events not to reach record hooks, got %d", notified)
+	}
+	latest, exists := restored.GetByUID("pod-1")
+	if !exists || latest.FieldDiff["status.phase"] != "Running" {
+		t.Errorf("Expected the latest state restored, got %+v", latest)
+	}
+	if history, _ := restored.GetHistory("pod-1", 10); len(history) != 2 {
+		t.Errorf("Expected the history restored, got %d events", len(history))
+	}
+
+	if n, err := NewMemoryStore().LoadSnapshotFile(filepath.Join(t.TempDir(), "missing")); n != 0 || err != nil {
+		t.Errorf("Expected a missing snapshot to load nothing, got %d: %v", n, err)
+	}
+}
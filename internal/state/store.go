@@ -1,69 +1,274 @@
 package state
 
 import (
+	"errors"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aonescu/akari/internal/types"
 )
 
+// ErrReadOnly is returned by stores opened in read-only mode
+var ErrReadOnly = errors.New("store is read-only")
+
 type StateStore interface {
 	Record(event types.StateEvent) error
 	GetLatestByKind(kind string) []types.StateEvent
 	GetByUID(uid string) (types.StateEvent, bool)
 }
 
+// SelectorReader is implemented by stores that index labels, so finding
+// the resources a Service or Deployment selects doesn't scan every
+// resource of the kind
+type SelectorReader interface {
+	// GetBySelector returns the latest state of the resources of kind
+	// whose labels include every label of selector. An empty selector
+	// selects nothing.
+	GetBySelector(kind string, selector map[string]string) []types.StateEvent
+}
+
+// GetBySelector returns the resources of kind selected by selector, through
+// the store's label index when it has one. An empty selector selects
+// nothing, as for a Service without one.
+func GetBySelector(store StateStore, kind string, selector map[string]string) []types.StateEvent {
+	if len(selector) == 0 {
+		return nil
+	}
+	if reader, ok := store.(SelectorReader); ok {
+		return reader.GetBySelector(kind, selector)
+	}
+	var selected []types.StateEvent
+	for _, resource := range store.GetLatestByKind(kind) {
+		if MatchesSelector(selector, resource.Labels) {
+			selected = append(selected, resource)
+		}
+	}
+	return selected
+}
+
+// ChangeDetector is implemented by stores that can skip events identical to
+// the latest recorded state of the same UID
+type ChangeDetector interface {
+	SetSkipUnchanged(enabled bool)
+	SkippedUnchanged() uint64
+}
+
+// Forgetter is implemented by stores that can drop a deleted resource from
+// the latest state, keeping its history
+type Forgetter interface {
+	Forget(uid string) error
+}
+
+// HistoryReader is implemented by stores that keep every recorded event,
+// not just the latest state
+type HistoryReader interface {
+	// GetHistory returns up to limit events for uid, newest first
+	GetHistory(uid string, limit int) ([]types.StateEvent, error)
+}
+
+// SnapshotReader is implemented by stores that can reconstruct past cluster
+// state from their recorded events. Both methods cover every UID unless
+// uids are given.
+type SnapshotReader interface {
+	// SnapshotAt returns the latest event of every UID recorded at or
+	// before t
+	SnapshotAt(t time.Time, uids ...string) ([]types.StateEvent, error)
+	// EventsBetween returns the events recorded after from and at or
+	// before to, oldest first
+	EventsBetween(from, to time.Time, uids ...string) ([]types.StateEvent, error)
+}
+
+// ConsistentReader is implemented by stores that serve reads from a cache
+// and can bypass it, reading the latest state from durable storage
+type ConsistentReader interface {
+	ReadLatestByKind(kind string) ([]types.StateEvent, error)
+	ReadByUID(uid string) (types.StateEvent, bool, error)
+}
+
+// KindLister is implemented by stores that can enumerate the kinds they
+// hold state for
+type KindLister interface {
+	Kinds() []string
+}
+
+// IdentityResolver is implemented by stores that follow resources across
+// recreation, when they come back with a new UID
+type IdentityResolver interface {
+	// LogicalKey returns the logical identity recorded for uid
+	LogicalKey(uid string) (string, bool)
+	// UIDsForKey returns every UID seen with a logical identity
+	UIDsForKey(key string) []string
+}
+
 // In-memory implementation for fallback
 type MemoryStore struct {
-	mu          sync.RWMutex
-	events      []types.StateEvent
-	latestByUID map[string]types.StateEvent
-	uidsByKind  map[string][]string
+	mu         sync.Mutex // guards events only; latest state lives in the sharded index
+	events     []types.StateEvent
+	latest     *LatestIndex
+	identities *IdentityIndex
+	// recording holds a UID's lock across the append and the index update
+	recording UIDLocks
+
+	skipUnchanged atomic.Bool
+	skipped       atomic.Uint64
+	exclusions    atomic.Pointer[FieldExclusions]
+
+	RecordHooks
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		events:      make([]types.StateEvent, 0),
-		latestByUID: make(map[string]types.StateEvent),
-		uidsByKind:  make(map[string][]string),
+		events:     make([]types.StateEvent, 0),
+		latest:     NewLatestIndex(),
+		identities: NewIdentityIndex(),
 	}
 }
 
+// SetSkipUnchanged enables dropping events whose fields are identical to
+// the latest recorded state of the same UID
+func (s *MemoryStore) SetSkipUnchanged(enabled bool) {
+	s.skipUnchanged.Store(enabled)
+}
+
+// SkippedUnchanged returns how many events were dropped as unchanged
+func (s *MemoryStore) SkippedUnchanged() uint64 {
+	return s.skipped.Load()
+}
+
+// SetFieldExclusions drops the matching fields of every event recorded
+// from now on
+func (s *MemoryStore) SetFieldExclusions(exclusions *FieldExclusions) {
+	s.exclusions.Store(exclusions)
+}
+
+// FieldExclusions returns the fields dropped before recording
+func (s *MemoryStore) FieldExclusions() *FieldExclusions {
+	return s.exclusions.Load()
+}
+
 func (s *MemoryStore) Record(event types.StateEvent) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	event = s.exclusions.Load().Apply(event)
+	if event.CorrelationID == "" {
+		event.CorrelationID = NewCorrelationID()
+	}
 
+	unlock := s.recording.Lock(event.UID)
+	if s.skipUnchanged.Load() {
+		if latest, exists := s.latest.Get(event.UID); exists && Unchanged(latest, event) {
+			unlock()
+			s.skipped.Add(1)
+			return nil
+		}
+	}
+	s.mu.Lock()
 	s.events = append(s.events, event)
-	s.latestByUID[event.UID] = event
+	s.mu.Unlock()
+	s.latest.Put(event)
+	s.identities.Observe(event)
+	unlock()
+
+	s.NotifyRecorded(event)
+	return nil
+}
+
+// Forget drops uid from the latest state once its resource is deleted, so
+// it is neither listed nor evaluated again. Its history is kept.
+func (s *MemoryStore) Forget(uid string) error {
+	unlock := s.recording.Lock(uid)
+	defer unlock()
+	s.latest.Remove(uid)
+	return nil
+}
+
+func (s *MemoryStore) GetHistory(uid string, limit int) ([]types.StateEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	found := false
-	for _, uid := range s.uidsByKind[event.Kind] {
-		if uid == event.UID {
-			found = true
-			break
+	history := make([]types.StateEvent, 0)
+	for i := len(s.events) - 1; i >= 0 && len(history) < limit; i-- {
+		if s.events[i].UID == uid {
+			history = append(history, s.events[i])
 		}
 	}
-	if !found {
-		s.uidsByKind[event.Kind] = append(s.uidsByKind[event.Kind], event.UID)
+	return history, nil
+}
+
+func (s *MemoryStore) SnapshotAt(t time.Time, uids ...string) ([]types.StateEvent, error) {
+	wanted := uidFilter(uids)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := make(map[string]int)
+	snapshot := make([]types.StateEvent, 0)
+	for _, event := range s.events {
+		if event.Timestamp.After(t) || !wanted(event.UID) {
+			continue
+		}
+		if i, ok := index[event.UID]; ok {
+			if !event.Timestamp.Before(snapshot[i].Timestamp) {
+				snapshot[i] = event
+			}
+			continue
+		}
+		index[event.UID] = len(snapshot)
+		snapshot = append(snapshot, event)
 	}
-	return nil
+	return snapshot, nil
 }
 
-func (s *MemoryStore) GetLatestByKind(kind string) []types.StateEvent {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *MemoryStore) EventsBetween(from, to time.Time, uids ...string) ([]types.StateEvent, error) {
+	wanted := uidFilter(uids)
 
-	var results []types.StateEvent
-	for _, uid := range s.uidsByKind[kind] {
-		if event, exists := s.latestByUID[uid]; exists {
-			results = append(results, event)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]types.StateEvent, 0)
+	for _, event := range s.events {
+		if event.Timestamp.After(from) && !event.Timestamp.After(to) && wanted(event.UID) {
+			events = append(events, event)
 		}
 	}
-	return results
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events, nil
+}
+
+// uidFilter matches every UID when uids is empty
+func uidFilter(uids []string) func(string) bool {
+	if len(uids) == 0 {
+		return func(string) bool { return true }
+	}
+	set := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		set[uid] = true
+	}
+	return func(uid string) bool { return set[uid] }
+}
+
+func (s *MemoryStore) LogicalKey(uid string) (string, bool) {
+	return s.identities.LogicalKey(uid)
+}
+
+func (s *MemoryStore) UIDsForKey(key string) []string {
+	return s.identities.UIDsForKey(key)
+}
+
+func (s *MemoryStore) GetLatestByKind(kind string) []types.StateEvent {
+	return s.latest.LatestByKind(kind)
+}
+
+func (s *MemoryStore) GetBySelector(kind string, selector map[string]string) []types.StateEvent {
+	return s.latest.BySelector(kind, selector)
 }
 
 func (s *MemoryStore) GetByUID(uid string) (types.StateEvent, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	event, exists := s.latestByUID[uid]
-	return event, exists
+	return s.latest.Get(uid)
+}
+
+func (s *MemoryStore) Kinds() []string {
+	return s.latest.Kinds()
 }
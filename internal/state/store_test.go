@@ -1,6 +1,8 @@
 package state
 
 import (
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -182,3 +184,133 @@ func TestMemoryStore_ConcurrentAccess(t *testing.T) {
 		t.Errorf("Expected 10 pods, got %d", len(pods))
 	}
 }
+
+func TestMemoryStore_GetHistory(t *testing.T) {
+	store := NewMemoryStore()
+	for i, actor := range []string{"a", "b", "c"} {
+		store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Version: strconv.Itoa(i), Actor: actor})
+		store.Record(types.StateEvent{UID: "pod-2", Kind: "Pod", Version: strconv.Itoa(i), Actor: actor})
+	}
+
+	history, err := store.GetHistory("pod-1", 2)
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 2 || history[0].Actor != "c" || history[1].Actor != "b" {
+		t.Errorf("Expected the two newest events first, got %+v", history)
+	}
+}
+
+func TestMemoryStore_Snapshot(t *testing.T) {
+	store := NewMemoryStore()
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	for i, ready := range []string{"True", "False"} {
+		store.Record(types.StateEvent{
+			UID: "pod-1", Kind: "Pod", Name: "web", Version: strconv.Itoa(i + 1),
+			Timestamp: base.Add(time.Duration(i) * time.Hour),
+			FieldDiff: map[string]interface{}{"status.conditions[Ready].status": ready},
+			Actor:     "kubelet",
+		})
+	}
+	store.Record(types.StateEvent{
+		UID: "deploy-1", Kind: "Deployment", Name: "web", Version: "1",
+		Timestamp: base.Add(30 * time.Minute), Actor: "alice",
+	})
+
+	snapshot, err := store.SnapshotAt(base.Add(45 * time.Minute))
+	if err != nil {
+		t.Fatalf("SnapshotAt failed: %v", err)
+	}
+	if len(snapshot) != 2 {
+		t.Fatalf("Expected 2 resources in the snapshot, got %d", len(snapshot))
+	}
+	for _, event := range snapshot {
+		if event.UID == "pod-1" && event.Version != "1" {
+			t.Errorf("Expected the pod as of version 1, got version %s", event.Version)
+		}
+	}
+
+	events, err := store.EventsBetween(base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("EventsBetween failed: %v", err)
+	}
+	if len(events) != 2 || events[0].Actor != "alice" || events[1].Version != "2" {
+		t.Errorf("Expected the deployment change then pod version 2, got %+v", events)
+	}
+}
+
+func TestMemoryStore_CorrelationID(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Name: "a", Version: "1"})
+	store.Record(types.StateEvent{UID: "pod-2", Kind: "Pod", Name: "b", Version: "1", CorrelationID: "req-42"})
+
+	generated, _ := store.GetByUID("pod-1")
+	if generated.CorrelationID == "" {
+		t.Error("Expected a correlation ID to be generated")
+	}
+	given, _ := store.GetByUID("pod-2")
+	if given.CorrelationID != "req-42" {
+		t.Errorf("Expected the given correlation ID to be kept, got %q", given.CorrelationID)
+	}
+}
+
+func TestMemoryStore_ConcurrentRecordsOfOneUID(t *testing.T) {
+	store := NewMemoryStore()
+	for round := 0; round < 50; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Name: "api", Version: strconv.Itoa(round*8 + i), Timestamp: time.Now()})
+			}()
+		}
+		wg.Wait()
+
+		history, _ := store.GetHistory("pod-1", 1)
+		if latest, _ := store.GetByUID("pod-1"); latest.Version != history[0].Version {
+			t.Fatalf("Expected the latest state to be the last event recorded, got %s after %s", latest.Version, history[0].Version)
+		}
+	}
+}
+
+func TestMemoryStore_Forget(t *testing.T) {
+	store := NewMemoryStore()
+	pod := types.StateEvent{UID: "pod-1", Kind: "Pod", Name: "web", Namespace: "default", Labels: map[string]string{"app": "web"}, Version: "1", Timestamp: time.Now()}
+	store.Record(pod)
+
+	if err := store.Forget("pod-1"); err != nil {
+		t.Fatalf("Forget() failed: %v", err)
+	}
+	if _, exists := store.GetByUID("pod-1"); exists {
+		t.Error("Expected a forgotten UID not found")
+	}
+	if len(store.GetLatestByKind("Pod")) != 0 || len(store.GetBySelector("Pod", map[string]string{"app": "web"})) != 0 {
+		t.Error("Expected a forgotten UID neither listed nor selected")
+	}
+	if history, _ := store.GetHistory("pod-1", 10); len(history) != 1 {
+		t.Errorf("Expected the history kept, got %d events", len(history))
+	}
+}
+
+// indexlessStore hides the label index of the store it wraps
+type indexlessStore struct {
+	StateStore
+}
+
+func TestGetBySelector(t *testing.T) {
+	store := NewMemoryStore()
+	store.Record(types.StateEvent{UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web", Labels: map[string]string{"app": "web"}, Timestamp: time.Now()})
+	store.Record(types.StateEvent{UID: "pod-2", Kind: "Pod", Namespace: "default", Name: "worker", Labels: map[string]string{"app": "worker"}, Timestamp: time.Now()})
+
+	for name, s := range map[string]StateStore{"indexed": store, "scanned": indexlessStore{store}} {
+		if got := GetBySelector(s, "Pod", map[string]string{"app": "web"}); len(got) != 1 || got[0].UID != "pod-1" {
+			t.Errorf("%s: expected only the web pod, got %+v", name, got)
+		}
+		if got := GetBySelector(s, "Pod", nil); len(got) != 0 {
+			t.Errorf("%s: expected an empty selector to select nothing, got %+v", name, got)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package suggest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+const (
+	// DefaultWindow is how much history is searched for patterns
+	DefaultWindow = 7 * 24 * time.Hour
+	// DefaultMinOccurrences is how often a failure must be recorded to
+	// count as recurring
+	DefaultMinOccurrences = 3
+
+	// maxExamples bounds the resources listed per suggestion
+	maxExamples = 5
+)
+
+// ErrNoHistory is returned by stores that can't replay recorded events
+var ErrNoHistory = errors.New("suggestions need a store that keeps history")
+
+// signal is a field whose values name a failure
+type signal struct {
+	name  string
+	field string
+	// describe words the draft invariant's description
+	describe func(kind, value string) string
+	// benign values are part of normal operation
+	benign map[string]bool
+}
+
+var signals = []signal{
+	{
+		name:  "waiting",
+		field: watcher.FieldWaitingReason,
+		describe: func(kind, value string) string {
+			return fmt.Sprintf("%s containers should not be waiting in %s", kind, value)
+		},
+		benign: map[string]bool{"ContainerCreating": true, "PodInitializing": true},
+	},
+	{
+		name:  "terminated",
+		field: watcher.FieldLastTerminationReason,
+		describe: func(kind, value string) string {
+			return fmt.Sprintf("%s containers should not terminate with %s", kind, value)
+		},
+		benign: map[string]bool{"Completed": true},
+	},
+	{
+		name:  "status",
+		field: watcher.FieldStatusReason,
+		describe: func(kind, value string) string {
+			return fmt.Sprintf("%s should not be %s", kind, value)
+		},
+	},
+}
+
+// Suggestion is a recurring failure no invariant covers, with a draft
+// invariant that would catch it
+type Suggestion struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	Field       string    `json:"field"`
+	Value       string    `json:"value"`
+	Occurrences int       `json:"occurrences"`
+	Resources   int       `json:"resources"`
+	Examples    []string  `json:"examples"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	// Invariant is the draft that accepting the suggestion activates
+	Invariant dsl.Invariant `json:"invariant"`
+}
+
+// pattern is a failure value seen on a kind
+type pattern struct {
+	signal int // index into signals
+	kind   string
+	value  string
+}
+
+type tally struct {
+	occurrences int
+	uids        map[string]bool
+	examples    []string
+	actors      map[string]int
+	first, last time.Time
+}
+
+// Suggest searches the events recorded in the window ending at now for
+// failures seen at least minOccurrences times that none of invariants
+// checks, most frequent first
+func Suggest(store state.StateStore, invariants []dsl.Invariant, now time.Time, window time.Duration, minOccurrences int) ([]Suggestion, error) {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	if minOccurrences <= 0 {
+		minOccurrences = DefaultMinOccurrences
+	}
+	reader, ok := store.(state.SnapshotReader)
+	if !ok {
+		return nil, ErrNoHistory
+	}
+	events, err := reader.EventsBetween(now.Add(-window), now)
+	if err != nil {
+		return nil, err
+	}
+	return Analyze(events, invariants, minOccurrences), nil
+}
+
+// Analyze finds the uncovered recurring failures in events
+func Analyze(events []types.StateEvent, invariants []dsl.Invariant, minOccurrences int) []Suggestion {
+	tallies := make(map[pattern]*tally)
+	for _, event := range events {
+		for i, sig := range signals {
+			value, _ := event.FieldDiff[sig.field].(string)
+			if value == "" || sig.benign[value] {
+				continue
+			}
+			p := pattern{signal: i, kind: event.Kind, value: value}
+			t := tallies[p]
+			if t == nil {
+				t = &tally{uids: make(map[string]bool), actors: make(map[string]int), first: event.Timestamp}
+				tallies[p] = t
+			}
+			t.occurrences++
+			if !t.uids[event.UID] {
+				t.uids[event.UID] = true
+				if len(t.examples) < maxExamples {
+					t.examples = append(t.examples, strings.TrimPrefix(event.Namespace+"/"+event.Name, "/"))
+				}
+			}
+			if actor, _, _ := strings.Cut(event.Actor, "/"); actor != "" {
+				t.actors[actor]++
+			}
+			if event.Timestamp.Before(t.first) {
+				t.first = event.Timestamp
+			}
+			if event.Timestamp.After(t.last) {
+				t.last = event.Timestamp
+			}
+		}
+	}
+
+	suggestions := make([]Suggestion, 0)
+	signalOf := make([]int, 0)
+	ids := make(map[string]int)
+	for p, t := range tallies {
+		if t.occurrences < minOccurrences || covered(p, invariants) {
+			continue
+		}
+		s := Suggestion{
+			Kind:        p.kind,
+			Field:       signals[p.signal].field,
+			Value:       p.value,
+			Occurrences: t.occurrences,
+			Resources:   len(t.uids),
+			Examples:    t.examples,
+			FirstSeen:   t.first,
+			LastSeen:    t.last,
+		}
+		s.Invariant = draft(p, t)
+		s.ID = s.Invariant.ID
+		ids[s.ID]++
+		suggestions = append(suggestions, s)
+		signalOf = append(signalOf, p.signal)
+	}
+	// The same value may fail in several ways, e.g. a waiting and a
+	// terminated Error; tell them apart by signal
+	for i := range suggestions {
+		if ids[suggestions[i].ID] > 1 {
+			suggestions[i].ID += "_" + signals[signalOf[i]].name
+			suggestions[i].Invariant.ID = suggestions[i].ID
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Occurrences != suggestions[j].Occurrences {
+			return suggestions[i].Occurrences > suggestions[j].Occurrences
+		}
+		return suggestions[i].ID < suggestions[j].ID
+	})
+	return suggestions
+}
+
+// covered reports whether an invariant on the pattern's kind checks its
+// field for the value, or for any value with exists and not_exists
+func covered(p pattern, invariants []dsl.Invariant) bool {
+	for _, inv := range invariants {
+		pred := inv.Predicate
+		if inv.Subject.Kind != p.kind || pred == nil || pred.Field != signals[p.signal].field {
+			continue
+		}
+		switch pred.Operator {
+		case dsl.Exists, dsl.NotExists:
+			return true
+		case dsl.Equals, dsl.NotEquals, dsl.Contains:
+			if fmt.Sprint(pred.Value) == p.value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// draft writes the invariant a suggestion proposes, blaming the actor
+// that recorded the failure most often
+func draft(p pattern, t *tally) dsl.Invariant {
+	primary := ""
+	for actor, n := range t.actors {
+		if n > t.actors[primary] || (n == t.actors[primary] && actor < primary) {
+			primary = actor
+		}
+	}
+	return dsl.Invariant{
+		ID:          strings.ToLower(p.kind) + "_no_" + snake(p.value),
+		Version:     1,
+		Description: signals[p.signal].describe(p.kind, p.value),
+		Subject:     dsl.Subject{Kind: p.kind},
+		Predicate: &dsl.Predicate{
+			Field:    signals[p.signal].field,
+			Operator: dsl.NotEquals,
+			Value:    p.value,
+		},
+		Responsibility: dsl.Responsibility{Primary: primary},
+		Severity:       dsl.Warning,
+		Docs:           fmt.Sprintf("Suggested after %s was recorded %d times on %d resources with no invariant checking for it.", p.value, t.occurrences, len(t.uids)),
+		Tags:           []string{dsl.TagAvailability},
+	}
+}
+
+// snake turns a reason such as CrashLoopBackOff or OOMKilled into
+// crash_loop_back_off or oom_killed
+func snake(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteByte('_')
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 && b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
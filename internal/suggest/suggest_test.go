@@ -0,0 +1,103 @@
+package suggest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+func podEvent(uid string, at time.Time, fields map[string]interface{}) types.StateEvent {
+	return types.StateEvent{UID: uid, Kind: "Pod", Namespace: "prod", Name: uid, Version: at.String(), Timestamp: at, Actor: "kubelet/node-1", FieldDiff: fields}
+}
+
+func TestAnalyze_SuggestsUncoveredFailures(t *testing.T) {
+	now := time.Now()
+	var events []types.StateEvent
+	for i, uid := range []string{"api-1", "api-2", "api-1", "worker-1"} {
+		events = append(events, podEvent(uid, now.Add(time.Duration(i)*time.Minute), map[string]interface{}{watcher.FieldWaitingReason: "CrashLoopBackOff"}))
+	}
+	for i := 0; i < 3; i++ {
+		events = append(events, podEvent("batch-1", now, map[string]interface{}{watcher.FieldLastTerminationReason: "OOMKilled"}))
+		events = append(events, podEvent("init-1", now, map[string]interface{}{watcher.FieldWaitingReason: "ContainerCreating"}))
+	}
+	events = append(events, podEvent("evicted-1", now, map[string]interface{}{watcher.FieldStatusReason: "Evicted"}))
+
+	oomChecked := dsl.Invariant{
+		ID:        "no_oom_killed",
+		Subject:   dsl.Subject{Kind: "Pod"},
+		Predicate: &dsl.Predicate{Field: watcher.FieldLastTerminationReason, Operator: dsl.NotEquals, Value: "OOMKilled"},
+	}
+	suggestions := Analyze(events, []dsl.Invariant{oomChecked}, 3)
+	if len(suggestions) != 1 {
+		t.Fatalf("Expected only CrashLoopBackOff to be suggested, got %+v", suggestions)
+	}
+	s := suggestions[0]
+	if s.ID != "pod_no_crash_loop_back_off" || s.Occurrences != 4 || s.Resources != 3 || len(s.Examples) != 3 {
+		t.Errorf("Unexpected suggestion %+v", s)
+	}
+	if !s.LastSeen.Equal(now.Add(3 * time.Minute)) {
+		t.Errorf("Expected the last occurrence at %v, got %v", now.Add(3*time.Minute), s.LastSeen)
+	}
+
+	inv := s.Invariant
+	if err := inv.Validate(); err != nil {
+		t.Fatalf("Expected a valid draft, got %v", err)
+	}
+	if inv.Predicate.Operator != dsl.NotEquals || inv.Predicate.Value != "CrashLoopBackOff" || inv.Responsibility.Primary != "kubelet" {
+		t.Errorf("Unexpected draft %+v", inv)
+	}
+
+	// Once the draft is active the pattern is covered
+	if again := Analyze(events, []dsl.Invariant{oomChecked, inv}, 3); len(again) != 0 {
+		t.Errorf("Expected no suggestions once covered, got %+v", again)
+	}
+}
+
+func TestAnalyze_DistinguishesSignalsWithTheSameValue(t *testing.T) {
+	now := time.Now()
+	var events []types.StateEvent
+	for i := 0; i < 3; i++ {
+		events = append(events, podEvent("a", now, map[string]interface{}{
+			watcher.FieldWaitingReason:         "Error",
+			watcher.FieldLastTerminationReason: "Error",
+		}))
+	}
+	suggestions := Analyze(events, nil, 3)
+	if len(suggestions) != 2 || suggestions[0].ID != "pod_no_error_terminated" || suggestions[1].ID != "pod_no_error_waiting" {
+		t.Errorf("Expected suggestions told apart by signal, got %+v", suggestions)
+	}
+}
+
+func TestSuggest_ReadsHistoryWindow(t *testing.T) {
+	store := state.NewMemoryStore()
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		store.Record(podEvent("old", now.Add(-48*time.Hour+time.Duration(i)*time.Minute), map[string]interface{}{watcher.FieldStatusReason: "Evicted"}))
+	}
+
+	if suggestions, err := Suggest(store, nil, now, 24*time.Hour, 0); err != nil || len(suggestions) != 0 {
+		t.Errorf("Expected nothing within a day, got %+v, %v", suggestions, err)
+	}
+	suggestions, err := Suggest(store, nil, now, 0, 0)
+	if err != nil || len(suggestions) != 1 || suggestions[0].ID != "pod_no_evicted" {
+		t.Errorf("Expected evictions within the default window, got %+v, %v", suggestions, err)
+	}
+}
+
+func TestSnake(t *testing.T) {
+	for in, want := range map[string]string{
+		"CrashLoopBackOff": "crash_loop_back_off",
+		"OOMKilled":        "oom_killed",
+		"ErrImagePull":     "err_image_pull",
+		"Evicted":          "evicted",
+		"Init:Error":       "init_error",
+	} {
+		if got := snake(in); got != want {
+			t.Errorf("snake(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
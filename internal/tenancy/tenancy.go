@@ -0,0 +1,158 @@
+package tenancy
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Header names the tenant of a request when an authenticating proxy in
+// front of the API is trusted to set it
+const Header = "X-Akari-Tenant"
+
+// Tenant is a team sharing the API. Its namespaces are the only ones it may
+// read or record events for.
+type Tenant struct {
+	Name string `json:"name"`
+	// Namespaces lists the namespaces the tenant owns. A trailing * matches
+	// a prefix; "*" alone matches every namespace and cluster-scoped
+	// resources, for operators of the shared service.
+	Namespaces []string `json:"namespaces"`
+	// Tokens authenticate the tenant as bearer tokens
+	Tokens []string `json:"tokens,omitempty"`
+}
+
+// Unrestricted reports whether the tenant sees every namespace
+func (t *Tenant) Unrestricted() bool {
+	for _, pattern := range t.Namespaces {
+		if pattern == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Owns reports whether namespace belongs to the tenant. Cluster-scoped
+// resources, with no namespace, belong to unrestricted tenants only.
+func (t *Tenant) Owns(namespace string) bool {
+	for _, pattern := range t.Namespaces {
+		if pattern == "*" || matches(pattern, namespace) && namespace != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(pattern, namespace string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(namespace, prefix)
+	}
+	return pattern == namespace
+}
+
+// overlaps reports whether two namespace patterns can match the same
+// namespace
+func overlaps(a, b string) bool {
+	pa, wa := strings.CutSuffix(a, "*")
+	pb, wb := strings.CutSuffix(b, "*")
+	switch {
+	case wa && wb:
+		return strings.HasPrefix(pa, pb) || strings.HasPrefix(pb, pa)
+	case wa:
+		return strings.HasPrefix(b, pa)
+	case wb:
+		return strings.HasPrefix(a, pb)
+	}
+	return a == b
+}
+
+// Config is the tenant declaration file
+type Config struct {
+	Tenants []Tenant `json:"tenants"`
+}
+
+// Registry resolves requests to tenants
+type Registry struct {
+	byName  map[string]*Tenant
+	byToken map[[sha256.Size]byte]*Tenant
+}
+
+// LoadFile reads a YAML or JSON tenant declaration file
+func LoadFile(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid tenants file %s: %w", path, err)
+	}
+	return NewRegistry(config.Tenants)
+}
+
+// NewRegistry validates tenants. Restricted tenants may not share a
+// namespace, and no two tenants may share a token.
+func NewRegistry(tenants []Tenant) (*Registry, error) {
+	registry := &Registry{
+		byName:  make(map[string]*Tenant, len(tenants)),
+		byToken: make(map[[sha256.Size]byte]*Tenant),
+	}
+	restricted := make([]*Tenant, 0, len(tenants))
+	for i := range tenants {
+		tenant := &tenants[i]
+		if tenant.Name == "" {
+			return nil, fmt.Errorf("tenant name is required")
+		}
+		if _, dup := registry.byName[tenant.Name]; dup {
+			return nil, fmt.Errorf("tenant %s declared twice", tenant.Name)
+		}
+		if len(tenant.Namespaces) == 0 {
+			return nil, fmt.Errorf("tenant %s needs at least one namespace", tenant.Name)
+		}
+		for _, token := range tenant.Tokens {
+			if token == "" {
+				return nil, fmt.Errorf("tenant %s has an empty token", tenant.Name)
+			}
+			key := sha256.Sum256([]byte(token))
+			if other, dup := registry.byToken[key]; dup {
+				return nil, fmt.Errorf("tenants %s and %s share a token", other.Name, tenant.Name)
+			}
+			registry.byToken[key] = tenant
+		}
+		if !tenant.Unrestricted() {
+			for _, other := range restricted {
+				for _, a := range tenant.Namespaces {
+					for _, b := range other.Namespaces {
+						if overlaps(a, b) {
+							return nil, fmt.Errorf("tenants %s and %s both own namespaces matching %s", other.Name, tenant.Name, a)
+						}
+					}
+				}
+			}
+			restricted = append(restricted, tenant)
+		}
+		registry.byName[tenant.Name] = tenant
+	}
+	return registry, nil
+}
+
+// Len returns the number of tenants
+func (r *Registry) Len() int {
+	return len(r.byName)
+}
+
+// ByName returns the named tenant
+func (r *Registry) ByName(name string) (*Tenant, bool) {
+	tenant, ok := r.byName[name]
+	return tenant, ok
+}
+
+// ByToken returns the tenant a bearer token authenticates. Tokens are
+// looked up by hash so lookups don't leak them through timing.
+func (r *Registry) ByToken(token string) (*Tenant, bool) {
+	tenant, ok := r.byToken[sha256.Sum256([]byte(token))]
+	return tenant, ok
+}
@@ -0,0 +1,68 @@
+package tenancy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTenant_Owns(t *testing.T) {
+	payments := &Tenant{Name: "payments", Namespaces: []string{"payments", "payments-*"}}
+	tests := []struct {
+		namespace string
+		want      bool
+	}{
+		{"payments", true},
+		{"payments-eu", true},
+		{"paymentsx", false},
+		{"shop", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := payments.Owns(tt.namespace); got != tt.want {
+			t.Errorf("Owns(%q) = %v, want %v", tt.namespace, got, tt.want)
+		}
+	}
+
+	operators := &Tenant{Name: "platform", Namespaces: []string{"*"}}
+	if !operators.Unrestricted() || !operators.Owns("") || !operators.Owns("shop") {
+		t.Error("Expected * to own every namespace and cluster-scoped resources")
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	registry, err := NewRegistry([]Tenant{
+		{Name: "payments", Namespaces: []string{"payments-*"}, Tokens: []string{"pay"}},
+		{Name: "shop", Namespaces: []string{"shop"}, Tokens: []string{"shop"}},
+		{Name: "platform", Namespaces: []string{"*"}, Tokens: []string{"ops"}},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	if tenant, ok := registry.ByToken("pay"); !ok || tenant.Name != "payments" {
+		t.Errorf("Expected the payments tenant, got %v", tenant)
+	}
+	if _, ok := registry.ByToken("nope"); ok {
+		t.Error("Expected an unknown token to match no tenant")
+	}
+	if tenant, ok := registry.ByName("shop"); !ok || tenant.Name != "shop" {
+		t.Errorf("Expected the shop tenant, got %v", tenant)
+	}
+
+	invalid := []struct {
+		name    string
+		tenants []Tenant
+		err     string
+	}{
+		{"duplicate name", []Tenant{{Name: "a", Namespaces: []string{"a"}}, {Name: "a", Namespaces: []string{"b"}}}, "declared twice"},
+		{"no namespaces", []Tenant{{Name: "a"}}, "at least one namespace"},
+		{"shared token", []Tenant{{Name: "a", Namespaces: []string{"a"}, Tokens: []string{"t"}}, {Name: "b", Namespaces: []string{"b"}, Tokens: []string{"t"}}}, "share a token"},
+		{"shared namespace", []Tenant{{Name: "a", Namespaces: []string{"team-*"}}, {Name: "b", Namespaces: []string{"team-b"}}}, "both own"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRegistry(tt.tenants); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
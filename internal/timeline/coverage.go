@@ -0,0 +1,79 @@
+package timeline
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+)
+
+// CoverageSample is the endpoint coverage of one Service after an
+// evaluation pass
+type CoverageSample struct {
+	At      time.Time `json:"at"`
+	Pods    int       `json:"pods"`
+	Ready   int       `json:"ready"`
+	Percent float64   `json:"percent"`
+}
+
+// CoverageHistory keeps per-Service endpoint coverage samples, taken after
+// every evaluation pass through Monitor.OnEvaluation
+type CoverageHistory struct {
+	engine    *engine.InvariantEngine
+	retention time.Duration
+
+	mu      sync.RWMutex
+	samples map[string][]CoverageSample // namespace/name -> samples, oldest first
+}
+
+func NewCoverageHistory(eng *engine.InvariantEngine, retention time.Duration) *CoverageHistory {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &CoverageHistory{
+		engine:    eng,
+		retention: retention,
+		samples:   make(map[string][]CoverageSample),
+	}
+}
+
+// Observe samples the coverage of every Service
+func (h *CoverageHistory) Observe(_ []*engine.ViolationResult, at time.Time) {
+	coverage := h.engine.ServiceCoverage()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, c := range coverage {
+		key := c.Namespace + "/" + c.Name
+		h.samples[key] = append(h.samples[key], CoverageSample{At: at, Pods: c.Pods, Ready: c.Ready, Percent: c.Percent})
+	}
+
+	cutoff := at.Add(-h.retention)
+	for key, samples := range h.samples {
+		drop := sort.Search(len(samples), func(i int) bool { return !samples[i].At.Before(cutoff) })
+		if drop == len(samples) {
+			delete(h.samples, key)
+			continue
+		}
+		h.samples[key] = append(samples[:0], samples[drop:]...)
+	}
+}
+
+// Samples returns the samples of a Service taken between from and to,
+// oldest first
+func (h *CoverageHistory) Samples(namespace, name string, from, to time.Time) []CoverageSample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	samples := h.samples[namespace+"/"+name]
+	start := sort.Search(len(samples), func(i int) bool { return !samples[i].At.Before(from) })
+	result := make([]CoverageSample, 0)
+	for _, s := range samples[start:] {
+		if s.At.After(to) {
+			break
+		}
+		result = append(result, s)
+	}
+	return result
+}
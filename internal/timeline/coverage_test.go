@@ -0,0 +1,46 @@
+package timeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestCoverageHistory(t *testing.T) {
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	history := NewCoverageHistory(eng, time.Hour)
+
+	store.Record(types.StateEvent{
+		UID: "svc-api", Kind: "Service", Namespace: "shop", Name: "api", Version: "1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"spec.selector": map[string]string{"app": "api"}},
+	})
+	pod := func(version, ready string) types.StateEvent {
+		return types.StateEvent{
+			UID: "api-1", Kind: "Pod", Namespace: "shop", Name: "api-1", Version: version, Timestamp: time.Now(),
+			Labels:    map[string]string{"app": "api"},
+			FieldDiff: map[string]interface{}{"status.conditions[Ready].status": ready},
+		}
+	}
+
+	start := time.Now()
+	store.Record(pod("1", "True"))
+	history.Observe(nil, start.Add(-2*time.Hour))
+	history.Observe(nil, start)
+	store.Record(pod("2", "False"))
+	history.Observe(nil, start.Add(time.Minute))
+
+	samples := history.Samples("shop", "api", start.Add(-3*time.Hour), start.Add(time.Hour))
+	if len(samples) != 2 {
+		t.Fatalf("Expected the sample past retention to be pruned, got %d samples", len(samples))
+	}
+	if samples[0].Percent != 100 || samples[1].Percent != 0 {
+		t.Errorf("Expected coverage to drop from 100%% to 0%%, got %+v", samples)
+	}
+	if got := history.Samples("shop", "web", start, start.Add(time.Hour)); len(got) != 0 {
+		t.Errorf("Expected no samples for an unknown service, got %+v", got)
+	}
+}
@@ -0,0 +1,194 @@
+package timeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+)
+
+const (
+	DefaultQueueSize = 1000
+	requestTimeout   = 10 * time.Second
+)
+
+// GrafanaAnnotator pushes violations to Grafana's annotations API: a region
+// annotation is created when a violation opens and its end is set when it
+// resolves. Requests are queued so a slow Grafana never blocks evaluation.
+type GrafanaAnnotator struct {
+	URL          string
+	Token        string
+	DashboardUID string
+	Client       *http.Client
+
+	queue chan Event
+
+	mu  sync.Mutex
+	ids map[string]int64 // fingerprint -> annotation ID
+
+	sent    atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// AnnotatorStats is a point-in-time snapshot of annotator metrics
+type AnnotatorStats struct {
+	Open    int    `json:"open"`
+	Depth   int    `json:"depth"`
+	Sent    uint64 `json:"sent"`
+	Failed  uint64 `json:"failed"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// NewGrafanaAnnotator creates an annotator for the Grafana at url, using a
+// service account token. Without a dashboard UID annotations are
+// organization-wide and show on any dashboard querying them by tag.
+func NewGrafanaAnnotator(url, token, dashboardUID string) *GrafanaAnnotator {
+	return &GrafanaAnnotator{
+		URL:          strings.TrimSuffix(url, "/"),
+		Token:        token,
+		DashboardUID: dashboardUID,
+		queue:        make(chan Event, DefaultQueueSize),
+		ids:          make(map[string]int64),
+	}
+}
+
+// HandleTransition is a Monitor subscriber
+func (g *GrafanaAnnotator) HandleTransition(t engine.Transition) {
+	event := Event{At: t.At, Violation: t.Violation}
+	switch t.Type {
+	case engine.TransitionOpened:
+		event.Type = EventStarted
+	case engine.TransitionResolved:
+		event.Type = EventResolved
+	default:
+		return
+	}
+
+	select {
+	case g.queue <- event:
+	default:
+		g.dropped.Add(1)
+		log.Printf("Grafana annotation queue full, dropping %s of %s", event.Type, t.Violation.Fingerprint())
+	}
+}
+
+// Run sends queued annotations until ctx is cancelled
+func (g *GrafanaAnnotator) Run(ctx context.Context) {
+	for {
+		select {
+		case event := <-g.queue:
+			callCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+			err := g.send(callCtx, event)
+			cancel()
+			if err != nil {
+				g.failed.Add(1)
+				log.Printf("Failed to annotate %s of %s in Grafana: %v", event.Type, event.Violation.Fingerprint(), err)
+				continue
+			}
+			g.sent.Add(1)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (g *GrafanaAnnotator) Stats() AnnotatorStats {
+	g.mu.Lock()
+	open := len(g.ids)
+	g.mu.Unlock()
+	return AnnotatorStats{
+		Open:    open,
+		Depth:   len(g.queue),
+		Sent:    g.sent.Load(),
+		Failed:  g.failed.Load(),
+		Dropped: g.dropped.Load(),
+	}
+}
+
+type grafanaAnnotation struct {
+	DashboardUID string   `json:"dashboardUID,omitempty"`
+	Time         int64    `json:"time,omitempty"`
+	TimeEnd      int64    `json:"timeEnd,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	Text         string   `json:"text,omitempty"`
+}
+
+func (g *GrafanaAnnotator) send(ctx context.Context, event Event) error {
+	v := event.Violation
+	key := v.Fingerprint()
+
+	if event.Type == EventStarted {
+		var created struct {
+			ID int64 `json:"id"`
+		}
+		err := g.do(ctx, http.MethodPost, "/api/annotations", grafanaAnnotation{
+			DashboardUID: g.DashboardUID,
+			Time:         event.At.UnixMilli(),
+			Tags:         Tags(v),
+			Text:         fmt.Sprintf("%s violated on %s: %s", v.InvariantID, v.AffectedResource, v.Reason),
+		}, &created)
+		if err != nil {
+			return err
+		}
+		g.mu.Lock()
+		g.ids[key] = created.ID
+		g.mu.Unlock()
+		return nil
+	}
+
+	g.mu.Lock()
+	id, ok := g.ids[key]
+	delete(g.ids, key)
+	g.mu.Unlock()
+	if !ok {
+		// Opened before startup or never annotated
+		return nil
+	}
+	return g.do(ctx, http.MethodPatch, fmt.Sprintf("/api/annotations/%d", id), grafanaAnnotation{
+		TimeEnd: event.At.UnixMilli(),
+	}, nil)
+}
+
+// do sends body to Grafana and decodes the response into out when set
+func (g *GrafanaAnnotator) do(ctx context.Context, method, path string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, g.URL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.Token)
+	}
+
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
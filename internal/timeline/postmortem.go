@@ -0,0 +1,159 @@
+package timeline
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/internal/engine"
+)
+
+// EntryType classifies a line of a postmortem timeline
+type EntryType string
+
+const (
+	EntryDetected EntryType = "detected"
+	EntrySpread   EntryType = "spread"
+	EntryChange   EntryType = "change"
+	EntryResolved EntryType = "resolved"
+)
+
+// Entry is one line of a postmortem timeline
+type Entry struct {
+	At   time.Time `json:"at"`
+	Type EntryType `json:"type"`
+	Text string    `json:"text"`
+}
+
+// Postmortem is the chronological account of one incident: its first
+// violation, the violations that opened while it was open, the changes
+// around it and its resolution. End is zero while it is still open.
+type Postmortem struct {
+	Key       string                  `json:"key"`
+	Violation *engine.ViolationResult `json:"violation"`
+	Start     time.Time               `json:"start"`
+	End       time.Time               `json:"end,omitempty"`
+	Entries   []Entry                 `json:"entries"`
+}
+
+// Postmortem assembles the timeline of the latest incident with the given
+// violation fingerprint. Violations opening while it was open count as its
+// spread; changes are added by the caller with AddChanges.
+func (r *Recorder) Postmortem(key string, now time.Time) (*Postmortem, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var incident *Span
+	for _, span := range r.spans {
+		if span.Violation.Fingerprint() == key {
+			incident = span
+		}
+	}
+	if incident == nil {
+		return nil, false
+	}
+
+	end := incident.End
+	if end.IsZero() {
+		end = now
+	}
+	pm := &Postmortem{Key: key, Violation: incident.Violation, Start: incident.Start, End: incident.End}
+	pm.add(incident.Start, EntryDetected, "First violation: %s", describe(incident.Violation))
+	for _, span := range r.spans {
+		if span == incident || span.Start.Before(incident.Start) || span.Start.After(end) {
+			continue
+		}
+		pm.add(span.Start, EntrySpread, "Also violated: %s", describe(span.Violation))
+		if !span.End.IsZero() && !span.End.After(end) {
+			pm.add(span.End, EntryResolved, "Resolved: %s on %s", span.Violation.InvariantID, span.Violation.AffectedResource)
+		}
+	}
+	if !incident.End.IsZero() {
+		pm.add(incident.End, EntryResolved, "Incident resolved: %s on %s", incident.Violation.InvariantID, incident.Violation.AffectedResource)
+	}
+	pm.sort()
+	return pm, true
+}
+
+// AddChanges adds recorded changes to the timeline
+func (p *Postmortem) AddChanges(changes []engine.SuspectChange) {
+	for _, c := range changes {
+		fields := make([]string, 0, len(c.Fields))
+		for field := range c.Fields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		resource := c.Kind + " " + c.Name
+		if c.Namespace != "" {
+			resource = c.Kind + " " + c.Namespace + "/" + c.Name
+		}
+		actor := c.Actor
+		if actor == "" {
+			actor = "unknown actor"
+		}
+		p.add(c.ChangedAt, EntryChange, "%s changed by %s: %s", resource, actor, strings.Join(fields, ", "))
+	}
+	p.sort()
+}
+
+// WriteMarkdown renders the postmortem as a Markdown summary and timeline,
+// with times in UTC
+func (p *Postmortem) WriteMarkdown(w io.Writer) error {
+	v := p.Violation
+	resolved, duration := "ongoing", ""
+	if !p.End.IsZero() {
+		resolved = formatTime(p.End)
+		duration = p.End.Sub(p.Start).Round(time.Second).String()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Incident: %s on %s\n\n", v.InvariantID, v.AffectedResource)
+	fmt.Fprintf(&b, "- **Severity:** %s\n", v.Severity)
+	if v.ResponsibleActor != "" {
+		fmt.Fprintf(&b, "- **Responsible actor:** %s\n", v.ResponsibleActor)
+	}
+	fmt.Fprintf(&b, "- **Started:** %s\n", formatTime(p.Start))
+	fmt.Fprintf(&b, "- **Resolved:** %s\n", resolved)
+	if duration != "" {
+		fmt.Fprintf(&b, "- **Duration:** %s\n", duration)
+	}
+	if v.RunbookURL != "" {
+		fmt.Fprintf(&b, "- **Runbook:** %s\n", v.RunbookURL)
+	}
+
+	b.WriteString("\n## Timeline (UTC)\n\n")
+	b.WriteString("| Time | Event | Details |\n|---|---|---|\n")
+	for _, e := range p.Entries {
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", formatTime(e.At), e.Type, escapeCell(e.Text))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (p *Postmortem) add(at time.Time, typ EntryType, format string, args ...interface{}) {
+	p.Entries = append(p.Entries, Entry{At: at, Type: typ, Text: fmt.Sprintf(format, args...)})
+}
+
+func (p *Postmortem) sort() {
+	sort.SliceStable(p.Entries, func(i, j int) bool { return p.Entries[i].At.Before(p.Entries[j].At) })
+}
+
+func describe(v *engine.ViolationResult) string {
+	text := fmt.Sprintf("%s on %s (%s)", v.InvariantID, v.AffectedResource, v.Severity)
+	if v.Reason != "" {
+		text += " - " + v.Reason
+	}
+	return text
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// escapeCell keeps text from breaking out of a Markdown table cell
+func escapeCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
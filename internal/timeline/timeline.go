@@ -0,0 +1,224 @@
+package timeline
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+)
+
+// DefaultRetention is how long samples and resolved spans are kept
+const DefaultRetention = 24 * time.Hour
+
+// Sample counts the violations of one evaluation pass by severity
+type Sample struct {
+	At     time.Time            `json:"at"`
+	Counts map[dsl.Severity]int `json:"counts"`
+}
+
+// Total returns the number of violations across severities
+func (s Sample) Total() int {
+	total := 0
+	for _, n := range s.Counts {
+		total += n
+	}
+	return total
+}
+
+// Span is the lifetime of one violation. End is zero while it is open.
+type Span struct {
+	Violation *engine.ViolationResult `json:"violation"`
+	Start     time.Time               `json:"start"`
+	End       time.Time               `json:"end,omitempty"`
+}
+
+// EventType marks whether an annotation event opens or closes a violation
+type EventType string
+
+const (
+	EventStarted  EventType = "started"
+	EventResolved EventType = "resolved"
+)
+
+// Event is one edge of a violation span, the unit of the annotations feed
+type Event struct {
+	Type      EventType               `json:"type"`
+	At        time.Time               `json:"at"`
+	Violation *engine.ViolationResult `json:"violation"`
+	Tags      []string                `json:"tags"`
+}
+
+// Tags labels a violation for annotation filtering: "akari", the severity,
+// the invariant ID, the namespace and the tier when known
+func Tags(v *engine.ViolationResult) []string {
+	tags := []string{"akari", string(v.Severity), v.InvariantID}
+	if namespace, _, ok := strings.Cut(v.AffectedResource, "/"); ok && namespace != "" {
+		tags = append(tags, "namespace:"+namespace)
+	}
+	if v.Tier != "" {
+		tags = append(tags, "tier:"+string(v.Tier))
+	}
+	return tags
+}
+
+// Recorder keeps a short in-memory history of violations for dashboards:
+// per-pass severity counts from Monitor.OnEvaluation and violation spans
+// from Monitor.Subscribe
+type Recorder struct {
+	retention time.Duration
+
+	mu      sync.RWMutex
+	samples []Sample
+	spans   []*Span
+	open    map[string]*Span // fingerprint -> open span
+}
+
+func NewRecorder(retention time.Duration) *Recorder {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &Recorder{
+		retention: retention,
+		open:      make(map[string]*Span),
+	}
+}
+
+// Observe records the severity counts of an evaluation pass
+func (r *Recorder) Observe(results []*engine.ViolationResult, at time.Time) {
+	counts := make(map[dsl.Severity]int)
+	for _, v := range engine.FilterByStatus(results, engine.StatusViolated) {
+		counts[v.Severity]++
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = append(r.samples, Sample{At: at, Counts: counts})
+	r.prune(at)
+}
+
+// HandleTransition opens and closes violation spans
+func (r *Recorder) HandleTransition(t engine.Transition) {
+	key := t.Violation.Fingerprint()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch t.Type {
+	case engine.TransitionOpened:
+		if _, exists := r.open[key]; exists {
+			return
+		}
+		span := &Span{Violation: t.Violation, Start: t.At}
+		r.open[key] = span
+		r.spans = append(r.spans, span)
+	case engine.TransitionResolved:
+		if span, exists := r.open[key]; exists {
+			span.End = t.At
+			delete(r.open, key)
+		}
+	}
+}
+
+// Samples returns the samples taken between from and to, oldest first
+func (r *Recorder) Samples(from, to time.Time) []Sample {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	start := sort.Search(len(r.samples), func(i int) bool { return !r.samples[i].At.Before(from) })
+	result := make([]Sample, 0)
+	for _, s := range r.samples[start:] {
+		if s.At.After(to) {
+			break
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// Spans returns copies of the spans overlapping from..to, oldest first
+func (r *Recorder) Spans(from, to time.Time) []Span {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]Span, 0)
+	for _, span := range r.spans {
+		if span.Start.After(to) || (!span.End.IsZero() && span.End.Before(from)) {
+			continue
+		}
+		result = append(result, *span)
+	}
+	return result
+}
+
+// Events returns the starts and resolves between from and to, oldest
+// first. With tags set only violations carrying all of them are included.
+func (r *Recorder) Events(from, to time.Time, tags ...string) []Event {
+	events := make([]Event, 0)
+	for _, span := range r.Spans(from, to) {
+		spanTags := Tags(span.Violation)
+		if !hasAll(spanTags, tags) {
+			continue
+		}
+		if !span.Start.Before(from) && !span.Start.After(to) {
+			events = append(events, Event{Type: EventStarted, At: span.Start, Violation: span.Violation, Tags: append(spanTags, string(EventStarted))})
+		}
+		if !span.End.IsZero() && !span.End.Before(from) && !span.End.After(to) {
+			events = append(events, Event{Type: EventResolved, At: span.End, Violation: span.Violation, Tags: append(Tags(span.Violation), string(EventResolved))})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	return events
+}
+
+// Active returns the violations currently open, oldest first
+func (r *Recorder) Active() []*engine.ViolationResult {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	spans := make([]*Span, 0, len(r.open))
+	for _, span := range r.open {
+		spans = append(spans, span)
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+
+	active := make([]*engine.ViolationResult, len(spans))
+	for i, span := range spans {
+		active[i] = span.Violation
+	}
+	return active
+}
+
+// prune drops samples and resolved spans older than the retention. Callers
+// hold r.mu.
+func (r *Recorder) prune(now time.Time) {
+	cutoff := now.Add(-r.retention)
+
+	drop := sort.Search(len(r.samples), func(i int) bool { return !r.samples[i].At.Before(cutoff) })
+	r.samples = append(r.samples[:0], r.samples[drop:]...)
+
+	kept := r.spans[:0]
+	for _, span := range r.spans {
+		if span.End.IsZero() || !span.End.Before(cutoff) {
+			kept = append(kept, span)
+		}
+	}
+	r.spans = kept
+}
+
+func hasAll(tags, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, tag := range tags {
+			if tag == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package timeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+)
+
+func TestRecorder_SamplesAndSpans(t *testing.T) {
+	rec := NewRecorder(time.Hour)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	critical := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/api", Severity: dsl.Critical, Status: engine.StatusViolated, Violated: true}
+	warning := &engine.ViolationResult{InvariantID: "pod_image_pinned", AffectedResource: "default/api", Severity: dsl.Warning, Status: engine.StatusViolated, Violated: true}
+
+	rec.Observe([]*engine.ViolationResult{critical, warning}, start)
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: critical, At: start})
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: warning, At: start.Add(time.Minute)})
+
+	rec.Observe([]*engine.ViolationResult{critical}, start.Add(2*time.Minute))
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: warning, At: start.Add(2 * time.Minute)})
+
+	samples := rec.Samples(start, start.Add(time.Hour))
+	if len(samples) != 2 {
+		t.Fatalf("Expected 2 samples, got %d", len(samples))
+	}
+	if samples[0].Counts[dsl.Critical] != 1 || samples[0].Counts[dsl.Warning] != 1 || samples[1].Total() != 1 {
+		t.Errorf("Unexpected samples %+v", samples)
+	}
+
+	spans := rec.Spans(start, start.Add(time.Hour))
+	if len(spans) != 2 || !spans[0].End.IsZero() || !spans[1].End.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Unexpected spans %+v", spans)
+	}
+	if active := rec.Active(); len(active) != 1 || active[0].InvariantID != "pod_ready" {
+		t.Errorf("Expected only pod_ready to be active, got %+v", active)
+	}
+
+	// Past the retention the resolved span and old samples are dropped,
+	// while the open span stays
+	rec.Observe(nil, start.Add(2*time.Hour))
+	if samples := rec.Samples(start, start.Add(3*time.Hour)); len(samples) != 1 {
+		t.Errorf("Expected old samples to be pruned, got %d", len(samples))
+	}
+	if spans := rec.Spans(start, start.Add(3*time.Hour)); len(spans) != 1 || spans[0].Violation.InvariantID != "pod_ready" {
+		t.Errorf("Expected only the open span to survive pruning, got %+v", spans)
+	}
+}
+
+func TestRecorder_Events(t *testing.T) {
+	rec := NewRecorder(time.Hour)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	api := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/api", Severity: dsl.Critical}
+	batch := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "jobs/batch", Severity: dsl.Critical}
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: api, At: start})
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: batch, At: start.Add(time.Minute)})
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: api, At: start.Add(2 * time.Minute)})
+
+	events := rec.Events(start, start.Add(time.Hour))
+	if len(events) != 3 || events[1].Violation != batch || events[2].Type != EventResolved {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+
+	events = rec.Events(start, start.Add(time.Hour), "namespace:default")
+	if len(events) != 2 || events[0].Type != EventStarted || events[1].Tags[len(events[1].Tags)-1] != "resolved" {
+		t.Errorf("Expected the start and resolve of default/api, got %+v", events)
+	}
+}
+
+func TestGrafanaAnnotator(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	var created map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodPost {
+			json.NewDecoder(r.Body).Decode(&created)
+			w.Write([]byte(`{"id": 42, "message": "Annotation added"}`))
+		}
+	}))
+	defer server.Close()
+
+	annotator := NewGrafanaAnnotator(server.URL+"/", "token", "dash")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go annotator.Run(ctx)
+
+	v := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/api", Severity: dsl.Critical}
+	now := time.Now()
+	annotator.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: v, At: now})
+	annotator.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: v, At: now.Add(time.Minute)})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for annotator.Stats().Sent < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"POST /api/annotations", "PATCH /api/annotations/42"}
+	if len(requests) != 2 || requests[0] != want[0] || requests[1] != want[1] {
+		t.Fatalf("Expected %v, got %v", want, requests)
+	}
+	if created["dashboardUID"] != "dash" || created["time"] != float64(now.UnixMilli()) {
+		t.Errorf("Unexpected annotation %v", created)
+	}
+	if stats := annotator.Stats(); stats.Open != 0 || stats.Failed != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestRecorder_Postmortem(t *testing.T) {
+	rec := NewRecorder(time.Hour)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	incident := &engine.ViolationResult{InvariantID: "pod_ready", AffectedResource: "default/api", Severity: dsl.Critical, Reason: "pod not ready"}
+	spread := &engine.ViolationResult{InvariantID: "service_has_endpoints", AffectedResource: "default/api", Severity: dsl.Critical}
+	earlier := &engine.ViolationResult{InvariantID: "pod_image_pinned", AffectedResource: "default/api", Severity: dsl.Warning}
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: earlier, At: start.Add(-time.Minute)})
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: incident, At: start})
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionOpened, Violation: spread, At: start.Add(time.Minute)})
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: spread, At: start.Add(3 * time.Minute)})
+	rec.HandleTransition(engine.Transition{Type: engine.TransitionResolved, Violation: incident, At: start.Add(4 * time.Minute)})
+
+	if _, ok := rec.Postmortem("pod_ready|default/other", start); ok {
+		t.Error("Expected no postmortem for an unknown fingerprint")
+	}
+	pm, ok := rec.Postmortem(incident.Fingerprint(), start.Add(time.Hour))
+	if !ok {
+		t.Fatal("Expected a postmortem")
+	}
+	pm.AddChanges([]engine.SuspectChange{{Kind: "Deployment", Namespace: "default", Name: "api", Actor: "alice", ChangedAt: start.Add(-30 * time.Second),
+		Fields: map[string]interface{}{"spec.image": "api:v2"}}})
+
+	types := make([]EntryType, len(pm.Entries))
+	for i, e := range pm.Entries {
+		types[i] = e.Type
+	}
+	want := []EntryType{EntryChange, EntryDetected, EntrySpread, EntryResolved, EntryResolved}
+	if len(types) != len(want) {
+		t.Fatalf("Expected entries %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("Expected entries %v, got %v", want, types)
+		}
+	}
+
+	var b strings.Builder
+	if err := pm.WriteMarkdown(&b); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	for _, line := range []string{
+		"- **Duration:** 4m0s",
+		"| 2026-01-01 11:59:30 | change | Deployment default/api changed by alice: spec.image |",
+		"| 2026-01-01 12:00:00 | detected | First violation: pod_ready on default/api (critical) - pod not ready |",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("Expected %q in:\n%s", line, b.String())
+		}
+	}
+}
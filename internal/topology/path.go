@@ -0,0 +1,203 @@
+package topology
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/aonescu/akari/internal/dsl"
+)
+
+// EdgeBlocks links a resource violating an invariant to one violating an
+// invariant it declares it blocks, e.g. a failing DNS probe to an unready pod
+const EdgeBlocks = "blocks"
+
+// maxPaths bounds the routes enumerated before ranking
+const maxPaths = 100
+
+// Hop is one step of a causal path, from the resource whose failure can
+// propagate to the one it affects
+type Hop struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+	// Invariants lists the invariant dependencies that back the hop, e.g.
+	// "pod_ready requires node_ready"
+	Invariants []string `json:"invariants,omitempty"`
+}
+
+// Path is a causal route between two resources
+type Path struct {
+	Resources []*Node `json:"resources"`
+	Hops      []Hop   `json:"hops"`
+	// Violated counts the resources on the path currently violating an
+	// invariant, when the graph has violations overlaid
+	Violated int `json:"violated"`
+}
+
+// relationEdges maps the relation of an invariant requirement to the edge
+// type linking the required resource to the requiring one
+var relationEdges = map[dsl.Relation]string{
+	dsl.Node:     EdgeScheduledOn,
+	dsl.Selector: EdgeSelects,
+	dsl.Owner:    EdgeOwner,
+}
+
+// CausalPaths returns up to limit shortest routes along which a failure of
+// from can propagate to to. Every edge points from a dependent to what it
+// depends on, so failures travel against it: from a Node to the pods
+// scheduled on it, from a pod to the Services selecting it. Invariants
+// add the blocks links between violated resources and annotate the hops
+// their requirements explain. Routes through more violated resources and
+// backed by more invariants come first.
+func (g *Graph) CausalPaths(from, to string, invariants []dsl.Invariant, limit int) []Path {
+	nodes := make(map[string]*Node, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes[n.ID] = n
+	}
+	paths := make([]Path, 0)
+	if nodes[from] == nil || nodes[to] == nil || from == to {
+		return paths
+	}
+
+	hops := make(map[string][]Hop)
+	for _, e := range g.Edges {
+		hops[e.To] = append(hops[e.To], Hop{From: e.To, To: e.From, Type: e.Type})
+	}
+	kinds := make(map[string]string, len(invariants))
+	for _, inv := range invariants {
+		kinds[inv.ID] = inv.Subject.Kind
+	}
+	for _, out := range hops {
+		for i := range out {
+			out[i].Invariants = requirements(nodes[out[i].From], nodes[out[i].To], out[i].Type, invariants, kinds)
+		}
+	}
+	// A blocks link between related resources backs the relationship
+	// rather than adding a route beside it
+	for _, h := range blockHops(g.Nodes, invariants) {
+		out := hops[h.From]
+		i := slices.IndexFunc(out, func(o Hop) bool { return o.To == h.To })
+		if i >= 0 {
+			out[i].Invariants = append(out[i].Invariants, h.Invariants...)
+		} else {
+			hops[h.From] = append(out, h)
+		}
+	}
+
+	// Breadth-first search keeps every hop that reaches a resource at its
+	// shortest distance, so all shortest routes can be walked back
+	dist := map[string]int{from: 0}
+	via := make(map[string][]Hop)
+	queue := []string{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if _, done := dist[to]; done && dist[id] >= dist[to] {
+			break
+		}
+		for _, h := range hops[id] {
+			d, seen := dist[h.To]
+			if !seen {
+				dist[h.To] = dist[id] + 1
+				queue = append(queue, h.To)
+			} else if d != dist[id]+1 {
+				continue
+			}
+			via[h.To] = append(via[h.To], h)
+		}
+	}
+	if _, ok := dist[to]; !ok {
+		return paths
+	}
+
+	var walk func(id string, route []Hop)
+	walk = func(id string, route []Hop) {
+		if len(paths) >= maxPaths {
+			return
+		}
+		if id == from {
+			paths = append(paths, newPath(nodes, route))
+			return
+		}
+		for _, h := range via[id] {
+			walk(h.From, append([]Hop{h}, route...))
+		}
+	}
+	walk(to, nil)
+
+	sort.SliceStable(paths, func(i, j int) bool {
+		if paths[i].Violated != paths[j].Violated {
+			return paths[i].Violated > paths[j].Violated
+		}
+		return backed(paths[i]) > backed(paths[j])
+	})
+	if limit > 0 && len(paths) > limit {
+		paths = paths[:limit]
+	}
+	return paths
+}
+
+func newPath(nodes map[string]*Node, hops []Hop) Path {
+	path := Path{Resources: []*Node{nodes[hops[0].From]}, Hops: hops}
+	for _, h := range hops {
+		path.Resources = append(path.Resources, nodes[h.To])
+	}
+	for _, n := range path.Resources {
+		if len(n.Violations) > 0 {
+			path.Violated++
+		}
+	}
+	return path
+}
+
+func backed(p Path) int {
+	n := 0
+	for _, h := range p.Hops {
+		if len(h.Invariants) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// blockHops links every resource violating an invariant to every resource
+// violating one it blocks
+func blockHops(nodes []*Node, invariants []dsl.Invariant) []Hop {
+	violating := make(map[string][]*Node)
+	for _, n := range nodes {
+		for _, id := range n.Violations {
+			violating[id] = append(violating[id], n)
+		}
+	}
+	var hops []Hop
+	for _, upstream := range invariants {
+		for _, blocked := range upstream.Blocks {
+			for _, cause := range violating[upstream.ID] {
+				for _, effect := range violating[blocked] {
+					if cause.ID != effect.ID {
+						hops = append(hops, Hop{From: cause.ID, To: effect.ID, Type: EdgeBlocks, Invariants: []string{upstream.ID + " blocks " + blocked}})
+					}
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// requirements names the invariants on the affected resource that require
+// one on the cause through the relation the hop follows. kinds maps
+// invariant IDs to their subject kinds.
+func requirements(cause, effect *Node, typ string, invariants []dsl.Invariant, kinds map[string]string) []string {
+	var backing []string
+	for _, inv := range invariants {
+		if inv.Subject.Kind != effect.Kind {
+			continue
+		}
+		for _, req := range inv.Requires {
+			if relationEdges[req.Scope.Relation] == typ && kinds[req.Invariant] == cause.Kind {
+				backing = append(backing, inv.ID+" requires "+req.Invariant)
+			}
+		}
+	}
+	return backing
+}
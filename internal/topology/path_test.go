@@ -0,0 +1,91 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+var pathInvariants = []dsl.Invariant{
+	{ID: "node_ready", Subject: dsl.Subject{Kind: "Node"}},
+	{ID: "pod_ready", Subject: dsl.Subject{Kind: "Pod"}, Requires: []dsl.Requirement{
+		{Invariant: "node_ready", Scope: dsl.Scope{Relation: dsl.Node}},
+	}},
+	{ID: "service_has_endpoints", Subject: dsl.Subject{Kind: "Service"}, Requires: []dsl.Requirement{
+		{Invariant: "pod_ready", Scope: dsl.Scope{Relation: dsl.Selector}},
+	}},
+	{ID: "dns_resolving", Subject: dsl.Subject{Kind: "NetworkProbe"}, Blocks: []string{"pod_ready"}},
+}
+
+func pathGraph() *Graph {
+	return Build([]types.StateEvent{
+		{UID: "node-1", Kind: "Node", Name: "node-1", FieldDiff: map[string]interface{}{}},
+		{UID: "node-2", Kind: "Node", Name: "node-2", FieldDiff: map[string]interface{}{}},
+		{UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api-1", Labels: map[string]string{"app": "api"}, FieldDiff: map[string]interface{}{watcher.FieldNodeName: "node-1"}},
+		{UID: "pod-2", Kind: "Pod", Namespace: "prod", Name: "api-2", Labels: map[string]string{"app": "api"}, FieldDiff: map[string]interface{}{watcher.FieldNodeName: "node-1"}},
+		{UID: "pod-3", Kind: "Pod", Namespace: "prod", Name: "web", Labels: map[string]string{"app": "web"}, FieldDiff: map[string]interface{}{watcher.FieldNodeName: "node-2"}},
+		{UID: "svc-1", Kind: "Service", Namespace: "prod", Name: "api", FieldDiff: map[string]interface{}{watcher.FieldSelector: map[string]string{"app": "api"}}},
+		{UID: "probe-1", Kind: "NetworkProbe", Name: "dns", FieldDiff: map[string]interface{}{}},
+	})
+}
+
+func TestCausalPaths(t *testing.T) {
+	graph := pathGraph()
+	graph.Overlay([]*engine.ViolationResult{
+		{InvariantID: "node_ready", ResourceUID: "node-1", Violated: true},
+		{InvariantID: "pod_ready", ResourceUID: "pod-2", Violated: true},
+	})
+
+	paths := graph.CausalPaths("node-1", "svc-1", pathInvariants, 0)
+	if len(paths) != 2 {
+		t.Fatalf("Expected a route through each api pod, got %+v", paths)
+	}
+	top := paths[0]
+	if len(top.Resources) != 3 || top.Resources[1].ID != "pod-2" || top.Violated != 2 {
+		t.Errorf("Expected the route through the unready pod first, got %+v", top)
+	}
+	want := []Hop{
+		{From: "node-1", To: "pod-2", Type: EdgeScheduledOn, Invariants: []string{"pod_ready requires node_ready"}},
+		{From: "pod-2", To: "svc-1", Type: EdgeSelects, Invariants: []string{"service_has_endpoints requires pod_ready"}},
+	}
+	for i, h := range want {
+		got := top.Hops[i]
+		if got.From != h.From || got.To != h.To || got.Type != h.Type || len(got.Invariants) != 1 || got.Invariants[0] != h.Invariants[0] {
+			t.Errorf("Hop %d: expected %+v, got %+v", i, h, got)
+		}
+	}
+	if limited := graph.CausalPaths("node-1", "svc-1", pathInvariants, 1); len(limited) != 1 {
+		t.Errorf("Expected the limit to apply, got %d paths", len(limited))
+	}
+
+	// Failures don't travel from a Service back to its node, nor between
+	// unrelated resources
+	if paths := graph.CausalPaths("svc-1", "node-1", pathInvariants, 0); len(paths) != 0 {
+		t.Errorf("Expected no route against the dependencies, got %+v", paths)
+	}
+	if paths := graph.CausalPaths("node-2", "svc-1", pathInvariants, 0); len(paths) != 0 {
+		t.Errorf("Expected no route from node-2, got %+v", paths)
+	}
+}
+
+func TestCausalPaths_FollowsBlocks(t *testing.T) {
+	graph := pathGraph()
+	if paths := graph.CausalPaths("probe-1", "svc-1", pathInvariants, 0); len(paths) != 0 {
+		t.Fatalf("Expected no route while the probe passes, got %+v", paths)
+	}
+
+	graph.Overlay([]*engine.ViolationResult{
+		{InvariantID: "dns_resolving", ResourceUID: "probe-1", Violated: true},
+		{InvariantID: "pod_ready", ResourceUID: "pod-1", Violated: true},
+	})
+	paths := graph.CausalPaths("probe-1", "svc-1", pathInvariants, 0)
+	if len(paths) != 1 || len(paths[0].Hops) != 2 {
+		t.Fatalf("Expected one route through pod-1, got %+v", paths)
+	}
+	if h := paths[0].Hops[0]; h.Type != EdgeBlocks || h.To != "pod-1" || h.Invariants[0] != "dns_resolving blocks pod_ready" {
+		t.Errorf("Expected the probe to block pod-1, got %+v", h)
+	}
+}
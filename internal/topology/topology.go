@@ -0,0 +1,141 @@
+package topology
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+// Edge types, each pointing from the dependent resource to the one it
+// depends on or, for selects, from the selector to the selected pod
+const (
+	EdgeOwner       = "owner"
+	EdgeScheduledOn = "scheduled-on"
+	EdgeSelects     = "selects"
+	EdgeMounts      = "mounts"
+)
+
+// Node is one recorded resource. Violations lists the invariants it
+// currently violates, when the caller overlays them.
+type Node struct {
+	ID         string   `json:"id"`
+	Kind       string   `json:"kind"`
+	Namespace  string   `json:"namespace,omitempty"`
+	Name       string   `json:"name"`
+	Violations []string `json:"violations,omitempty"`
+}
+
+// Edge links two nodes by UID
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// Graph is the object graph of a set of resources
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []Edge  `json:"edges"`
+}
+
+// Build links resources by the relationships recorded in their fields:
+// controller owner references, the node a pod is scheduled on, the pods a
+// Service or Deployment selector matches, and the ConfigMaps, Secrets and
+// claims a pod mounts. Only edges between the given resources are kept.
+func Build(resources []types.StateEvent) *Graph {
+	graph := &Graph{Nodes: make([]*Node, 0, len(resources)), Edges: make([]Edge, 0)}
+	byRef := make(map[string]types.StateEvent, len(resources)) // kind/namespace/name -> resource
+	var pods []types.StateEvent
+	for _, r := range resources {
+		graph.Nodes = append(graph.Nodes, &Node{ID: r.UID, Kind: r.Kind, Namespace: r.Namespace, Name: r.Name})
+		byRef[ref(r.Kind, r.Namespace, r.Name)] = r
+		if r.Kind == "Pod" {
+			pods = append(pods, r)
+		}
+	}
+	link := func(from types.StateEvent, kind, namespace, name, typ string) {
+		if to, ok := byRef[ref(kind, namespace, name)]; ok {
+			graph.Edges = append(graph.Edges, Edge{From: from.UID, To: to.UID, Type: typ})
+		}
+	}
+
+	for _, r := range resources {
+		if controller, _ := r.FieldDiff[state.FieldController].(string); controller != "" {
+			kind, name, _ := strings.Cut(controller, "/")
+			if _, ok := byRef[ref(kind, r.Namespace, name)]; ok {
+				link(r, kind, r.Namespace, name, EdgeOwner)
+			} else if w, ok := engine.WorkloadOf(r); ok {
+				// Stand in the Deployment for an unrecorded ReplicaSet
+				link(r, w.Kind, w.Namespace, w.Name, EdgeOwner)
+			}
+		}
+		if node, _ := r.FieldDiff[watcher.FieldNodeName].(string); node != "" && r.Kind == "Pod" {
+			link(r, "Node", "", node, EdgeScheduledOn)
+		}
+		for _, mount := range watcher.Mounts(r.FieldDiff) {
+			if kind, name, ok := strings.Cut(mount, "/"); ok {
+				link(r, kind, r.Namespace, name, EdgeMounts)
+			}
+		}
+		if selector := watcher.Selector(r.FieldDiff); len(selector) > 0 {
+			for _, pod := range pods {
+				if pod.Namespace == r.Namespace && state.MatchesSelector(selector, pod.Labels) {
+					graph.Edges = append(graph.Edges, Edge{From: r.UID, To: pod.UID, Type: EdgeSelects})
+				}
+			}
+		}
+	}
+
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.To < b.To
+	})
+	return graph
+}
+
+// Overlay attaches each violation to the node of its resource
+func (g *Graph) Overlay(violations []*engine.ViolationResult) {
+	byID := make(map[string]*Node, len(g.Nodes))
+	for _, n := range g.Nodes {
+		byID[n.ID] = n
+	}
+	for _, v := range violations {
+		if n, ok := byID[v.ResourceUID]; ok && v.Violated {
+			n.Violations = append(n.Violations, v.InvariantID)
+		}
+	}
+	for _, n := range g.Nodes {
+		sort.Strings(n.Violations)
+	}
+}
+
+// DropUnlinkedClusterScoped removes the cluster-scoped nodes no edge
+// touches, such as the Nodes no pod in a namespace runs on
+func (g *Graph) DropUnlinkedClusterScoped() {
+	linked := make(map[string]bool)
+	for _, e := range g.Edges {
+		linked[e.From], linked[e.To] = true, true
+	}
+	kept := g.Nodes[:0]
+	for _, n := range g.Nodes {
+		if n.Namespace != "" || linked[n.ID] {
+			kept = append(kept, n)
+		}
+	}
+	g.Nodes = kept
+}
+
+func ref(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
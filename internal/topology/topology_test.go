@@ -0,0 +1,58 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	"github.com/aonescu/akari/internal/watcher"
+)
+
+func TestBuild(t *testing.T) {
+	resources := []types.StateEvent{
+		{UID: "deploy-1", Kind: "Deployment", Namespace: "prod", Name: "api", FieldDiff: map[string]interface{}{
+			watcher.FieldSelector: map[string]interface{}{"app": "api"},
+		}},
+		{UID: "svc-1", Kind: "Service", Namespace: "prod", Name: "api", FieldDiff: map[string]interface{}{
+			watcher.FieldSelector: map[string]string{"app": "api"},
+		}},
+		{UID: "pod-1", Kind: "Pod", Namespace: "prod", Name: "api-7d9f-x1", Labels: map[string]string{"app": "api", "pod-template-hash": "7d9f"}, FieldDiff: map[string]interface{}{
+			state.FieldController: "ReplicaSet/api-7d9f",
+			watcher.FieldNodeName: "node-1",
+			watcher.FieldMounts:   []interface{}{"PersistentVolumeClaim/data", "Secret/unrecorded"},
+		}},
+		{UID: "pod-2", Kind: "Pod", Namespace: "staging", Name: "api", Labels: map[string]string{"app": "api"}, FieldDiff: map[string]interface{}{}},
+		{UID: "pvc-1", Kind: "PersistentVolumeClaim", Namespace: "prod", Name: "data", FieldDiff: map[string]interface{}{}},
+		{UID: "node-1", Kind: "Node", Name: "node-1", FieldDiff: map[string]interface{}{}},
+		{UID: "node-2", Kind: "Node", Name: "node-2", FieldDiff: map[string]interface{}{}},
+	}
+
+	graph := Build(resources)
+	want := []Edge{
+		{From: "deploy-1", To: "pod-1", Type: EdgeSelects},
+		{From: "pod-1", To: "pvc-1", Type: EdgeMounts},
+		{From: "pod-1", To: "deploy-1", Type: EdgeOwner},
+		{From: "pod-1", To: "node-1", Type: EdgeScheduledOn},
+		{From: "svc-1", To: "pod-1", Type: EdgeSelects},
+	}
+	if len(graph.Edges) != len(want) {
+		t.Fatalf("Expected %d edges, got %+v", len(want), graph.Edges)
+	}
+	for i, e := range want {
+		if graph.Edges[i] != e {
+			t.Errorf("Edge %d: expected %+v, got %+v", i, e, graph.Edges[i])
+		}
+	}
+
+	graph.Overlay([]*engine.ViolationResult{{InvariantID: "pod_ready", ResourceUID: "pod-1", Violated: true}})
+	graph.DropUnlinkedClusterScoped()
+	if len(graph.Nodes) != 6 {
+		t.Errorf("Expected the unused node-2 to be dropped, got %d nodes", len(graph.Nodes))
+	}
+	for _, n := range graph.Nodes {
+		if n.ID == "pod-1" && (len(n.Violations) != 1 || n.Violations[0] != "pod_ready") {
+			t.Errorf("Expected pod_ready on pod-1, got %v", n.Violations)
+		}
+	}
+}
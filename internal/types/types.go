@@ -4,15 +4,24 @@ import "time"
 
 // StateEvent represents a state change event for a Kubernetes resource
 type StateEvent struct {
-	UID       string                 `json:"uid"`
-	Kind      string                 `json:"kind"`
-	Namespace string                 `json:"namespace"`
-	Name      string                 `json:"name"`
-	Version   string                 `json:"version"`
-	Timestamp time.Time              `json:"timestamp"`
-	FieldDiff map[string]interface{} `json:"field_diff"`
-	Actor     string                 `json:"actor"`
-	FullState interface{}            `json:"full_state,omitempty"`
+	UID       string            `json:"uid"`
+	Kind      string            `json:"kind"`
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	// OwnerUID is the UID of the controller in the resource's
+	// ownerReferences, followed by owner requirements
+	OwnerUID          string                 `json:"owner_uid,omitempty"`
+	Tier              string                 `json:"tier,omitempty"`
+	Version           string                 `json:"version"`
+	Timestamp         time.Time              `json:"timestamp"`
+	CreationTimestamp time.Time              `json:"creation_timestamp,omitzero"`
+	FieldDiff         map[string]interface{} `json:"field_diff"`
+	Actor             string                 `json:"actor"`
+	FullState         interface{}            `json:"full_state,omitempty"`
+	// CorrelationID traces the event through evaluation, violations and
+	// notifications; stores assign one when it is empty
+	CorrelationID string `json:"correlation_id,omitempty"`
 }
 
 // EvaluationContext provides context for invariant evaluation
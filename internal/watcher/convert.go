@@ -0,0 +1,191 @@
+package watcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Fields recorded by the conversions below and read by the built-in
+// invariants
+const (
+	FieldNodeName          = "spec.nodeName"
+	FieldPhase             = "status.phase"
+	FieldDeletionTimestamp = "metadata.deletionTimestamp"
+	FieldContainersRunning = "status.containerStatuses[*].state.running"
+	FieldEndpointAddresses = "endpoints[*].addresses"
+	FieldReplicas          = "spec.replicas"
+	FieldAvailableReplicas = "status.availableReplicas"
+)
+
+// NodeEvent converts a node into the state event the node invariants read
+func NodeEvent(node *corev1.Node, now time.Time) types.StateEvent {
+	event := newEvent("Node", node.ObjectMeta, now)
+	event.Actor = "node-controller"
+	for _, cond := range node.Status.Conditions {
+		event.FieldDiff[fmt.Sprintf("status.conditions[%s].status", cond.Type)] = string(cond.Status)
+		setTransitionTime(event.FieldDiff, string(cond.Type), cond.LastTransitionTime)
+	}
+	if v := node.Status.NodeInfo.KubeletVersion; v != "" {
+		event.FieldDiff[FieldKubeletVersion] = v
+	}
+	merge(event.FieldDiff, NodeSchedulingFields(node))
+	merge(event.FieldDiff, NodeResourceFields(node))
+	return event
+}
+
+// NamespaceEvent converts a namespace, whose labels invariant subjects can
+// select on
+func NamespaceEvent(ns *corev1.Namespace, now time.Time) types.StateEvent {
+	event := newEvent("Namespace", ns.ObjectMeta, now)
+	event.Actor = "namespace-controller"
+	event.FieldDiff[FieldPhase] = string(ns.Status.Phase)
+	return event
+}
+
+// PodEvent converts a pod, deriving the scheduling, termination, security
+// and request fields
+func PodEvent(pod *corev1.Pod, now time.Time) types.StateEvent {
+	event := newEvent("Pod", pod.ObjectMeta, now)
+	event.Actor = "kubelet"
+	if pod.Spec.NodeName != "" {
+		event.Actor = "kubelet/" + pod.Spec.NodeName
+		event.FieldDiff[FieldNodeName] = pod.Spec.NodeName
+	}
+	if pod.DeletionTimestamp != nil {
+		event.FieldDiff[FieldDeletionTimestamp] = pod.DeletionTimestamp.UTC().Format(time.RFC3339)
+	}
+	event.FieldDiff[FieldPhase] = string(pod.Status.Phase)
+
+	for _, cond := range pod.Status.Conditions {
+		event.FieldDiff[fmt.Sprintf("status.conditions[%s].status", cond.Type)] = string(cond.Status)
+		if cond.Reason != "" {
+			event.FieldDiff[fmt.Sprintf("status.conditions[%s].reason", cond.Type)] = cond.Reason
+		}
+		setTransitionTime(event.FieldDiff, string(cond.Type), cond.LastTransitionTime)
+	}
+	if len(pod.Status.ContainerStatuses) > 0 {
+		running := make([]interface{}, len(pod.Status.ContainerStatuses))
+		for i, cs := range pod.Status.ContainerStatuses {
+			running[i] = cs.State.Running != nil
+		}
+		event.FieldDiff[FieldContainersRunning] = running
+	}
+	for _, c := range pod.Spec.Containers {
+		event.FieldDiff[fmt.Sprintf("spec.containers[%s].image", c.Name)] = c.Image
+	}
+	if mounts := MountRefs(pod); len(mounts) > 0 {
+		event.FieldDiff[FieldMounts] = mounts
+	}
+
+	merge(event.FieldDiff, SchedulingFields(pod))
+	merge(event.FieldDiff, TerminationFields(pod, now))
+	merge(event.FieldDiff, SecurityFields(pod))
+	merge(event.FieldDiff, RequestFields(pod))
+	return event
+}
+
+// ServiceEvent converts a service and the ready addresses of its
+// endpoints, which may be nil when none exist yet
+func ServiceEvent(svc *corev1.Service, endpoints *corev1.Endpoints, now time.Time) types.StateEvent {
+	event := newEvent("Service", svc.ObjectMeta, now)
+	event.Actor = "service-controller"
+	if len(svc.Spec.Selector) > 0 {
+		event.FieldDiff[FieldSelector] = svc.Spec.Selector
+	}
+
+	addresses := make([]interface{}, 0)
+	if endpoints != nil {
+		for _, subset := range endpoints.Subsets {
+			addresses = append(addresses, len(subset.Addresses) > 0)
+		}
+	}
+	event.FieldDiff[FieldEndpointAddresses] = addresses
+	return event
+}
+
+// DeploymentEvent converts a deployment. services and readyEndpoints are
+// as for DeploymentIdle.
+func DeploymentEvent(d *appsv1.Deployment, replicaSets []appsv1.ReplicaSet, services []corev1.Service, readyEndpoints map[string]int, now time.Time) types.StateEvent {
+	event := newEvent("Deployment", d.ObjectMeta, now)
+	event.Actor = "deployment-controller"
+	if actor := DeployingActor(d.ObjectMeta); actor != "" {
+		event.FieldDiff[FieldDeployedBy] = actor
+	}
+	if d.Spec.Selector != nil && len(d.Spec.Selector.MatchLabels) > 0 {
+		event.FieldDiff[FieldSelector] = d.Spec.Selector.MatchLabels
+	}
+	if d.Spec.Replicas != nil {
+		event.FieldDiff[FieldReplicas] = int(*d.Spec.Replicas)
+	}
+	event.FieldDiff[FieldAvailableReplicas] = int(d.Status.AvailableReplicas)
+	if hash := CurrentTemplateHash(d, replicaSets); hash != "" {
+		event.FieldDiff[FieldTemplateHash] = hash
+	}
+	for _, c := range d.Spec.Template.Spec.Containers {
+		event.FieldDiff[fmt.Sprintf("spec.template.spec.containers[%s].image", c.Name)] = c.Image
+	}
+	event.FieldDiff[FieldIdle] = conditionString(DeploymentIdle(d, services, readyEndpoints))
+	return event
+}
+
+// PVCEvent converts a persistent volume claim, marking it orphaned when no
+// pod mounts it
+func PVCEvent(pvc *corev1.PersistentVolumeClaim, pods []corev1.Pod, now time.Time) types.StateEvent {
+	event := newEvent("PersistentVolumeClaim", pvc.ObjectMeta, now)
+	event.Actor = "persistentvolume-controller"
+	event.FieldDiff[FieldPhase] = string(pvc.Status.Phase)
+	event.FieldDiff[FieldOrphaned] = conditionString(PVCOrphaned(pvc, pods))
+	return event
+}
+
+// ReadyEndpoints counts the ready addresses of each endpoints object,
+// keyed by "namespace/name" as DeploymentIdle expects
+func ReadyEndpoints(endpoints []corev1.Endpoints) map[string]int {
+	ready := make(map[string]int, len(endpoints))
+	for _, ep := range endpoints {
+		for _, subset := range ep.Subsets {
+			ready[ep.Namespace+"/"+ep.Name] += len(subset.Addresses)
+		}
+	}
+	return ready
+}
+
+func newEvent(kind string, meta metav1.ObjectMeta, now time.Time) types.StateEvent {
+	event := types.StateEvent{
+		UID:               string(meta.UID),
+		Kind:              kind,
+		Namespace:         meta.Namespace,
+		Name:              meta.Name,
+		Labels:            meta.Labels,
+		Version:           meta.ResourceVersion,
+		Timestamp:         now,
+		CreationTimestamp: meta.CreationTimestamp.Time,
+		FieldDiff:         make(map[string]interface{}),
+	}
+	// The controller lets recreated resources be followed across UIDs
+	if ref := metav1.GetControllerOf(&meta); ref != nil {
+		event.OwnerUID = string(ref.UID)
+		event.FieldDiff[state.FieldController] = ref.Kind + "/" + ref.Name
+	}
+	return event
+}
+
+// setTransitionTime records when a condition last changed as RFC3339, for
+// older_than and newer_than predicates on stuck conditions
+func setTransitionTime(fields map[string]interface{}, condition string, at metav1.Time) {
+	if !at.IsZero() {
+		fields[fmt.Sprintf("status.conditions[%s].lastTransitionTime", condition)] = at.UTC().Format(time.RFC3339)
+	}
+}
+
+func merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
@@ -0,0 +1,285 @@
+package watcher
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// Converter turns objects of one kind into the state events invariants
+// read. Adding a resource kind means registering one.
+type Converter interface {
+	GVK() schema.GroupVersionKind
+	Convert(obj runtime.Object, cluster *Cluster, now time.Time) (types.StateEvent, error)
+}
+
+// Cluster holds the related objects some conversions derive fields from,
+// e.g. a Service's endpoints or the pods mounting a claim. Any may be
+// empty.
+type Cluster struct {
+	Nodes       []corev1.Node
+	Pods        []corev1.Pod
+	Services    []corev1.Service
+	Endpoints   []corev1.Endpoints
+	ReplicaSets []appsv1.ReplicaSet
+	// ServerVersion is the API server's version, for clusters whose
+	// control-plane nodes aren't visible
+	ServerVersion string
+
+	readyOnce    sync.Once
+	ready        map[string]int
+	versionsOnce sync.Once
+	controlPlane string
+	poolMinors   map[string]uint
+}
+
+// readyEndpoints counts ready endpoint addresses once per cluster
+func (c *Cluster) readyEndpoints() map[string]int {
+	c.readyOnce.Do(func() { c.ready = ReadyEndpoints(c.Endpoints) })
+	return c.ready
+}
+
+// versions finds the control plane version and the newest minor version
+// of each node pool once per cluster
+func (c *Cluster) versions() (string, map[string]uint) {
+	c.versionsOnce.Do(func() {
+		c.controlPlane = ControlPlaneVersion(c.Nodes, c.ServerVersion)
+		c.poolMinors = NewestPoolMinors(c.Nodes)
+	})
+	return c.controlPlane, c.poolMinors
+}
+
+// endpointsOf returns the endpoints of a service, nil when it has none
+func (c *Cluster) endpointsOf(svc *corev1.Service) *corev1.Endpoints {
+	for i, ep := range c.Endpoints {
+		if ep.Namespace == svc.Namespace && ep.Name == svc.Name {
+			return &c.Endpoints[i]
+		}
+	}
+	return nil
+}
+
+// ConverterRegistry picks the converter of an object by its group, version
+// and kind, falling back to GenericConverter
+type ConverterRegistry struct {
+	mu         sync.RWMutex
+	converters map[schema.GroupVersionKind]Converter
+}
+
+func NewConverterRegistry(converters ...Converter) *ConverterRegistry {
+	r := &ConverterRegistry{converters: make(map[schema.GroupVersionKind]Converter)}
+	for _, c := range converters {
+		r.Register(c)
+	}
+	return r
+}
+
+// DefaultConverters converts Nodes, Namespaces, Pods, Services,
+// Deployments and PersistentVolumeClaims
+func DefaultConverters() *ConverterRegistry {
+	return NewConverterRegistry(
+		typedConverter[*corev1.Node]{gvk: corev1.SchemeGroupVersion.WithKind("Node"), convert: func(node *corev1.Node, c *Cluster, now time.Time) types.StateEvent {
+			event := NodeEvent(node, now)
+			controlPlane, poolMinors := c.versions()
+			merge(event.FieldDiff, VersionFields(node, controlPlane, poolMinors))
+			return event
+		}},
+		typedConverter[*corev1.Namespace]{gvk: corev1.SchemeGroupVersion.WithKind("Namespace"), convert: func(ns *corev1.Namespace, _ *Cluster, now time.Time) types.StateEvent {
+			return NamespaceEvent(ns, now)
+		}},
+		typedConverter[*corev1.Pod]{gvk: corev1.SchemeGroupVersion.WithKind("Pod"), convert: func(pod *corev1.Pod, _ *Cluster, now time.Time) types.StateEvent {
+			return PodEvent(pod, now)
+		}},
+		typedConverter[*corev1.Service]{gvk: corev1.SchemeGroupVersion.WithKind("Service"), convert: func(svc *corev1.Service, c *Cluster, now time.Time) types.StateEvent {
+			return ServiceEvent(svc, c.endpointsOf(svc), now)
+		}},
+		typedConverter[*appsv1.Deployment]{gvk: appsv1.SchemeGroupVersion.WithKind("Deployment"), convert: func(d *appsv1.Deployment, c *Cluster, now time.Time) types.StateEvent {
+			return DeploymentEvent(d, c.ReplicaSets, c.Services, c.readyEndpoints(), now)
+		}},
+		typedConverter[*corev1.PersistentVolumeClaim]{gvk: corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"), convert: func(pvc *corev1.PersistentVolumeClaim, c *Cluster, now time.Time) types.StateEvent {
+			return PVCEvent(pvc, c.Pods, now)
+		}},
+	)
+}
+
+// Register adds a converter, replacing any for the same kind
+func (r *ConverterRegistry) Register(c Converter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.converters[c.GVK()] = c
+}
+
+func (r *ConverterRegistry) Get(gvk schema.GroupVersionKind) (Converter, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, exists := r.converters[gvk]
+	return c, exists
+}
+
+// Kinds lists the registered kinds as group/version/kind strings
+func (r *ConverterRegistry) Kinds() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	kinds := make([]string, 0, len(r.converters))
+	for gvk := range r.converters {
+		kinds = append(kinds, strings.TrimPrefix(gvk.Group+"/"+gvk.Version+"/"+gvk.Kind, "/"))
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Convert converts obj with the converter registered for its kind, or
+// with GenericConverter when there is none. Typed objects, whose kind
+// lists leave empty, are identified through the client-go scheme.
+func (r *ConverterRegistry) Convert(obj runtime.Object, cluster *Cluster, now time.Time) (types.StateEvent, error) {
+	if cluster == nil {
+		cluster = &Cluster{}
+	}
+	gvk, err := kindOf(obj)
+	if err != nil {
+		return types.StateEvent{}, err
+	}
+	if c, exists := r.Get(gvk); exists {
+		return c.Convert(obj, cluster, now)
+	}
+	return GenericConverter{Kind: gvk}.Convert(obj, cluster, now)
+}
+
+func kindOf(obj runtime.Object) (schema.GroupVersionKind, error) {
+	if gvk := obj.GetObjectKind().GroupVersionKind(); !gvk.Empty() {
+		return gvk, nil
+	}
+	kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil || len(kinds) == 0 {
+		return schema.GroupVersionKind{}, fmt.Errorf("unknown kind of %T: %v", obj, err)
+	}
+	return kinds[0], nil
+}
+
+// typedConverter adapts a conversion of a typed object
+type typedConverter[T runtime.Object] struct {
+	gvk     schema.GroupVersionKind
+	convert func(obj T, cluster *Cluster, now time.Time) types.StateEvent
+}
+
+func (c typedConverter[T]) GVK() schema.GroupVersionKind { return c.gvk }
+
+func (c typedConverter[T]) Convert(obj runtime.Object, cluster *Cluster, now time.Time) (types.StateEvent, error) {
+	typed, ok := obj.(T)
+	if !ok {
+		// Unstructured objects of a typed kind, e.g. from a dynamic client
+		u, isUnstructured := obj.(*unstructured.Unstructured)
+		if !isUnstructured {
+			return types.StateEvent{}, fmt.Errorf("%s converter cannot convert %T", c.gvk.Kind, obj)
+		}
+		typed = c.new()
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+			return types.StateEvent{}, fmt.Errorf("failed to decode %s: %w", c.gvk.Kind, err)
+		}
+	}
+	return c.convert(typed, cluster, now), nil
+}
+
+// new allocates the object T points to
+func (c typedConverter[T]) new() T {
+	obj, err := scheme.Scheme.New(c.gvk)
+	if err != nil {
+		panic(fmt.Sprintf("%s is not in the client-go scheme", c.gvk))
+	}
+	return obj.(T)
+}
+
+// GenericConverter converts any object by reading the fields most kinds
+// share: metadata, the controller, status.phase, status conditions,
+// replica counts, a label selector and container images
+type GenericConverter struct {
+	Kind schema.GroupVersionKind
+	// Actor is recorded on the events, "<kind>-controller" by default
+	Actor string
+}
+
+func (c GenericConverter) GVK() schema.GroupVersionKind { return c.Kind }
+
+func (c GenericConverter) Convert(obj runtime.Object, _ *Cluster, now time.Time) (types.StateEvent, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return types.StateEvent{}, fmt.Errorf("failed to read %s: %w", c.Kind.Kind, err)
+	}
+	u := &unstructured.Unstructured{Object: content}
+
+	meta := metav1.ObjectMeta{
+		UID:               u.GetUID(),
+		Namespace:         u.GetNamespace(),
+		Name:              u.GetName(),
+		Labels:            u.GetLabels(),
+		ResourceVersion:   u.GetResourceVersion(),
+		CreationTimestamp: u.GetCreationTimestamp(),
+		OwnerReferences:   u.GetOwnerReferences(),
+	}
+	event := newEvent(c.Kind.Kind, meta, now)
+	event.Actor = c.Actor
+	if event.Actor == "" {
+		event.Actor = strings.ToLower(c.Kind.Kind) + "-controller"
+	}
+	if ts := u.GetDeletionTimestamp(); ts != nil {
+		event.FieldDiff[FieldDeletionTimestamp] = ts.UTC().Format(time.RFC3339)
+	}
+
+	if phase, found, _ := unstructured.NestedString(content, "status", "phase"); found {
+		event.FieldDiff[FieldPhase] = phase
+	}
+	conditions, _, _ := unstructured.NestedSlice(content, "status", "conditions")
+	for _, item := range conditions {
+		cond, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		condType, _ := cond["type"].(string)
+		if condType == "" {
+			continue
+		}
+		if status, ok := cond["status"].(string); ok {
+			event.FieldDiff[fmt.Sprintf("status.conditions[%s].status", condType)] = status
+		}
+		if reason, ok := cond["reason"].(string); ok && reason != "" {
+			event.FieldDiff[fmt.Sprintf("status.conditions[%s].reason", condType)] = reason
+		}
+		if at, ok := cond["lastTransitionTime"].(string); ok {
+			if t, err := time.Parse(time.RFC3339, at); err == nil {
+				setTransitionTime(event.FieldDiff, condType, metav1.NewTime(t))
+			}
+		}
+	}
+
+	if replicas, found, _ := unstructured.NestedInt64(content, "spec", "replicas"); found {
+		event.FieldDiff[FieldReplicas] = int(replicas)
+	}
+	if available, found, _ := unstructured.NestedInt64(content, "status", "availableReplicas"); found {
+		event.FieldDiff[FieldAvailableReplicas] = int(available)
+	}
+	if selector, found, _ := unstructured.NestedStringMap(content, "spec", "selector", "matchLabels"); found && len(selector) > 0 {
+		event.FieldDiff[FieldSelector] = selector
+	}
+	containers, _, _ := unstructured.NestedSlice(content, "spec", "template", "spec", "containers")
+	for _, item := range containers {
+		if container, ok := item.(map[string]interface{}); ok {
+			name, _ := container["name"].(string)
+			image, _ := container["image"].(string)
+			if name != "" && image != "" {
+				event.FieldDiff[fmt.Sprintf("spec.template.spec.containers[%s].image", name)] = image
+			}
+		}
+	}
+	return event, nil
+}
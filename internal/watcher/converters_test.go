@@ -0,0 +1,129 @@
+package watcher
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestConverterRegistry_Typed(t *testing.T) {
+	now := time.Now()
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{UID: "pod-1", Namespace: "prod", Name: "api"},
+		Spec:       corev1.PodSpec{NodeName: "node-1", Containers: []corev1.Container{{Name: "app", Image: "api:1"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	registry := DefaultConverters()
+
+	event, err := registry.Convert(pod, nil, now)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if want := PodEvent(pod, now); !reflect.DeepEqual(event, want) {
+		t.Errorf("Expected the Pod conversion, got %+v", event)
+	}
+
+	// A dynamic client's unstructured pod decodes into the typed converter
+	content, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+	u := &unstructured.Unstructured{Object: content}
+	u.SetAPIVersion("v1")
+	u.SetKind("Pod")
+	event, err = registry.Convert(u, nil, now)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if event.Kind != "Pod" || event.FieldDiff[FieldNodeName] != "node-1" || event.Actor != "kubelet/node-1" {
+		t.Errorf("Expected the unstructured pod to convert as a Pod, got %+v", event)
+	}
+}
+
+func TestConverterRegistry_Generic(t *testing.T) {
+	replicas := int32(3)
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			UID: "sts-1", Namespace: "prod", Name: "db",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Database", Name: "main", UID: "database-1", Controller: &[]bool{true}[0]}},
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres", Image: "postgres:16"}}}},
+		},
+		Status: appsv1.StatefulSetStatus{AvailableReplicas: 2},
+	}
+	event, err := DefaultConverters().Convert(sts, nil, time.Now())
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	want := map[string]interface{}{
+		state.FieldController:  "Database/main",
+		FieldReplicas:          3,
+		FieldAvailableReplicas: 2,
+		FieldSelector:          map[string]string{"app": "db"},
+		"spec.template.spec.containers[postgres].image": "postgres:16",
+	}
+	if event.Kind != "StatefulSet" || event.Actor != "statefulset-controller" || !reflect.DeepEqual(event.FieldDiff, want) {
+		t.Errorf("Expected the generic fields, got %s %s %+v", event.Kind, event.Actor, event.FieldDiff)
+	}
+	if event.OwnerUID != "database-1" {
+		t.Errorf("Expected the controller's UID as owner, got %q", event.OwnerUID)
+	}
+
+	// Custom resources are read through their conditions
+	cert := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata":   map[string]interface{}{"uid": "cert-1", "namespace": "prod", "name": "tls"},
+		"status": map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "False", "reason": "Expired", "lastTransitionTime": "2025-01-01T00:00:00Z"},
+		}},
+	}}
+	event, err = DefaultConverters().Convert(cert, nil, time.Now())
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if event.FieldDiff["status.conditions[Ready].status"] != "False" || event.FieldDiff["status.conditions[Ready].reason"] != "Expired" ||
+		event.FieldDiff["status.conditions[Ready].lastTransitionTime"] != "2025-01-01T00:00:00Z" {
+		t.Errorf("Expected the Ready condition, got %+v", event.FieldDiff)
+	}
+}
+
+type certificateConverter struct{}
+
+func (certificateConverter) GVK() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+}
+
+func (certificateConverter) Convert(obj runtime.Object, _ *Cluster, now time.Time) (types.StateEvent, error) {
+	u := obj.(*unstructured.Unstructured)
+	return types.StateEvent{UID: string(u.GetUID()), Kind: "Certificate", Actor: "cert-manager", Timestamp: now}, nil
+}
+
+func TestConverterRegistry_Register(t *testing.T) {
+	registry := DefaultConverters()
+	registry.Register(certificateConverter{})
+
+	cert := &unstructured.Unstructured{}
+	cert.SetAPIVersion("cert-manager.io/v1")
+	cert.SetKind("Certificate")
+	cert.SetUID("cert-1")
+	event, err := registry.Convert(cert, nil, time.Now())
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if event.Actor != "cert-manager" {
+		t.Errorf("Expected the registered converter, got %+v", event)
+	}
+	if kinds := registry.Kinds(); kinds[0] != "apps/v1/Deployment" || kinds[1] != "cert-manager.io/v1/Certificate" {
+		t.Errorf("Expected sorted kinds, got %v", kinds)
+	}
+}
@@ -0,0 +1,107 @@
+package watcher
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Derived fields evaluated by the cost invariant pack
+const (
+	// FieldCPURequestMillis and FieldMemoryRequestBytes total the requests
+	// of a pod's app containers; the metrics collector compares usage to them
+	FieldCPURequestMillis   = "spec.requests.cpuMillis"
+	FieldMemoryRequestBytes = "spec.requests.memoryBytes"
+	// FieldIdle is "True" on a Deployment scaled above zero that no
+	// Service routes traffic to
+	FieldIdle = "efficiency.idle"
+	// FieldOrphaned is "True" on a PersistentVolumeClaim no pod mounts
+	FieldOrphaned = "efficiency.orphaned"
+
+	// Node resources, which pod requests are scheduled against
+	FieldAllocatableCPUMillis   = "status.allocatable.cpuMillis"
+	FieldAllocatableMemoryBytes = "status.allocatable.memoryBytes"
+	FieldCapacityCPUMillis      = "status.capacity.cpuMillis"
+	FieldCapacityMemoryBytes    = "status.capacity.memoryBytes"
+)
+
+// RequestFields totals the CPU and memory requests of a pod. Containers
+// without a request contribute nothing, so the fields are omitted when no
+// container sets one.
+func RequestFields(pod *corev1.Pod) map[string]interface{} {
+	var cpu, memory int64
+	for _, c := range pod.Spec.Containers {
+		if q, ok := c.Resources.Requests[corev1.ResourceCPU]; ok {
+			cpu += q.MilliValue()
+		}
+		if q, ok := c.Resources.Requests[corev1.ResourceMemory]; ok {
+			memory += q.Value()
+		}
+	}
+
+	fields := make(map[string]interface{})
+	if cpu > 0 {
+		fields[FieldCPURequestMillis] = cpu
+	}
+	if memory > 0 {
+		fields[FieldMemoryRequestBytes] = memory
+	}
+	return fields
+}
+
+// NodeResourceFields records the CPU and memory a node has and can give to
+// pods
+func NodeResourceFields(node *corev1.Node) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if q, ok := node.Status.Allocatable[corev1.ResourceCPU]; ok {
+		fields[FieldAllocatableCPUMillis] = q.MilliValue()
+	}
+	if q, ok := node.Status.Allocatable[corev1.ResourceMemory]; ok {
+		fields[FieldAllocatableMemoryBytes] = q.Value()
+	}
+	if q, ok := node.Status.Capacity[corev1.ResourceCPU]; ok {
+		fields[FieldCapacityCPUMillis] = q.MilliValue()
+	}
+	if q, ok := node.Status.Capacity[corev1.ResourceMemory]; ok {
+		fields[FieldCapacityMemoryBytes] = q.Value()
+	}
+	return fields
+}
+
+// DeploymentIdle reports whether a Deployment runs replicas that receive
+// no traffic: no Service in its namespace selecting its pods has a ready
+// endpoint. readyEndpoints is keyed by "namespace/service".
+func DeploymentIdle(d *appsv1.Deployment, services []corev1.Service, readyEndpoints map[string]int) bool {
+	if d.Spec.Replicas != nil && *d.Spec.Replicas == 0 {
+		return false
+	}
+
+	podLabels := labels.Set(d.Spec.Template.Labels)
+	for _, svc := range services {
+		if svc.Namespace != d.Namespace || len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		if !labels.SelectorFromSet(svc.Spec.Selector).Matches(podLabels) {
+			continue
+		}
+		if readyEndpoints[svc.Namespace+"/"+svc.Name] > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// PVCOrphaned reports whether no pod in the claim's namespace mounts it
+func PVCOrphaned(pvc *corev1.PersistentVolumeClaim, pods []corev1.Pod) bool {
+	for _, pod := range pods {
+		if pod.Namespace != pvc.Namespace {
+			continue
+		}
+		for _, v := range pod.Spec.Volumes {
+			if v.PersistentVolumeClaim != nil && v.PersistentVolumeClaim.ClaimName == pvc.Name {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package watcher
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRequestFields(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("250m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		}}},
+		{Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("1"),
+		}}},
+	}}}
+
+	fields := RequestFields(pod)
+	if fields[FieldCPURequestMillis] != int64(1250) || fields[FieldMemoryRequestBytes] != int64(128<<20) {
+		t.Errorf("Unexpected request fields: %v", fields)
+	}
+	if len(RequestFields(&corev1.Pod{})) != 0 {
+		t.Error("Expected no request fields for a pod without requests")
+	}
+}
+
+func TestDeploymentIdle(t *testing.T) {
+	replicas := int32(2)
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web", "tier": "frontend"}}},
+		},
+	}
+	services := []corev1.Service{{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+	}}
+
+	if !DeploymentIdle(d, services, nil) {
+		t.Error("Expected a deployment behind a service without endpoints to be idle")
+	}
+	if DeploymentIdle(d, services, map[string]int{"default/web": 2}) {
+		t.Error("Expected a deployment with ready endpoints not to be idle")
+	}
+
+	zero := int32(0)
+	d.Spec.Replicas = &zero
+	if DeploymentIdle(d, nil, nil) {
+		t.Error("Expected a deployment scaled to zero not to be idle")
+	}
+}
+
+func TestPVCOrphaned(t *testing.T) {
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default"}}
+	mounting := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+		Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+			Name:         "data",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}},
+		}}},
+	}
+
+	if PVCOrphaned(pvc, []corev1.Pod{mounting}) {
+		t.Error("Expected a mounted claim not to be orphaned")
+	}
+	mounting.Namespace = "other"
+	if !PVCOrphaned(pvc, []corev1.Pod{mounting}) {
+		t.Error("Expected a claim mounted only from another namespace to be orphaned")
+	}
+}
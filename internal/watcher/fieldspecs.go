@@ -0,0 +1,163 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/types"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+)
+
+// FieldSpec declares fields to record for one kind, on top of those its
+// converter records, so invariants can read them without recompiling
+type FieldSpec struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Fields     []ExtractedField `json:"fields"`
+}
+
+// ExtractedField records the value at a JSONPath expression, such as
+// {.status.notAfter} or .spec.containers[*].name, under Name. Paths
+// matching several values record them as a list; paths matching nothing
+// leave the field unset.
+type ExtractedField struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// FieldSpecFile is the field extraction file
+type FieldSpecFile struct {
+	Kinds []FieldSpec `json:"kinds"`
+}
+
+// LoadFieldSpecs reads and validates a YAML or JSON field extraction file
+func LoadFieldSpecs(path string) ([]FieldSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file FieldSpecFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid field spec file %s: %w", path, err)
+	}
+	for _, spec := range file.Kinds {
+		if _, err := spec.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return file.Kinds, nil
+}
+
+func (s FieldSpec) gvk() (schema.GroupVersionKind, error) {
+	if s.APIVersion == "" || s.Kind == "" {
+		return schema.GroupVersionKind{}, fmt.Errorf("field spec needs apiVersion and kind")
+	}
+	gv, err := schema.ParseGroupVersion(s.APIVersion)
+	if err != nil {
+		return schema.GroupVersionKind{}, fmt.Errorf("field spec for %s: %w", s.Kind, err)
+	}
+	return gv.WithKind(s.Kind), nil
+}
+
+// compile parses the paths of the spec
+func (s FieldSpec) compile() (map[string]*jsonpath.JSONPath, error) {
+	if _, err := s.gvk(); err != nil {
+		return nil, err
+	}
+	if len(s.Fields) == 0 {
+		return nil, fmt.Errorf("field spec for %s lists no fields", s.Kind)
+	}
+	paths := make(map[string]*jsonpath.JSONPath, len(s.Fields))
+	for _, f := range s.Fields {
+		if f.Name == "" || f.Path == "" {
+			return nil, fmt.Errorf("field spec for %s: fields need a name and a path", s.Kind)
+		}
+		if _, dup := paths[f.Name]; dup {
+			return nil, fmt.Errorf("field spec for %s: field %s declared twice", s.Kind, f.Name)
+		}
+		path := f.Path
+		if !strings.HasPrefix(path, "{") {
+			path = "{" + path + "}"
+		}
+		parsed := jsonpath.New(f.Name).AllowMissingKeys(true)
+		if err := parsed.Parse(path); err != nil {
+			return nil, fmt.Errorf("field spec for %s: invalid path for %s: %w", s.Kind, f.Name, err)
+		}
+		paths[f.Name] = parsed
+	}
+	return paths, nil
+}
+
+// ApplyFieldSpecs wraps the converter of each spec's kind, or the generic
+// one when the kind has none, so it also records the declared fields
+func (r *ConverterRegistry) ApplyFieldSpecs(specs []FieldSpec) error {
+	for _, spec := range specs {
+		paths, err := spec.compile()
+		if err != nil {
+			return err
+		}
+		gvk, _ := spec.gvk()
+		base, exists := r.Get(gvk)
+		if !exists {
+			base = GenericConverter{Kind: gvk}
+		}
+		r.Register(&extractingConverter{Converter: base, paths: paths})
+	}
+	return nil
+}
+
+// extractingConverter adds the fields of a spec to another converter's
+type extractingConverter struct {
+	Converter
+	// mu serializes the paths, which keep state while they execute
+	mu    sync.Mutex
+	paths map[string]*jsonpath.JSONPath
+}
+
+func (c *extractingConverter) Convert(obj runtime.Object, cluster *Cluster, now time.Time) (types.StateEvent, error) {
+	event, err := c.Converter.Convert(obj, cluster, now)
+	if err != nil {
+		return event, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return event, fmt.Errorf("failed to read %s: %w", event.Kind, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, path := range c.paths {
+		results, err := path.FindResults(content)
+		if err != nil {
+			return event, fmt.Errorf("failed to extract %s from %s %s/%s: %w", name, event.Kind, event.Namespace, event.Name, err)
+		}
+		var values []interface{}
+		for _, result := range results {
+			for _, v := range result {
+				values = append(values, fieldValue(v.Interface()))
+			}
+		}
+		switch len(values) {
+		case 0:
+		case 1:
+			event.FieldDiff[name] = values[0]
+		default:
+			event.FieldDiff[name] = values
+		}
+	}
+	return event, nil
+}
+
+// fieldValue records integers as int, like the built-in conversions
+func fieldValue(v interface{}) interface{} {
+	if n, ok := v.(int64); ok {
+		return int(n)
+	}
+	return v
+}
@@ -0,0 +1,109 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func writeFieldSpecs(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fields.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyFieldSpecs(t *testing.T) {
+	specs, err := LoadFieldSpecs(writeFieldSpecs(t, `
+kinds:
+- apiVersion: v1
+  kind: Pod
+  fields:
+  - name: spec.serviceAccountName
+    path: .spec.serviceAccountName
+  - name: spec.containers[*].name
+    path: "{.spec.containers[*].name}"
+  - name: spec.priority
+    path: .spec.priority
+  - name: spec.hostname
+    path: .spec.hostname
+- apiVersion: cert-manager.io/v1
+  kind: Certificate
+  fields:
+  - name: status.notAfter
+    path: .status.notAfter
+`))
+	if err != nil {
+		t.Fatalf("LoadFieldSpecs failed: %v", err)
+	}
+	registry := DefaultConverters()
+	if err := registry.ApplyFieldSpecs(specs); err != nil {
+		t.Fatalf("ApplyFieldSpecs failed: %v", err)
+	}
+
+	priority := int32(100)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{UID: "pod-1", Namespace: "prod", Name: "api"},
+		Spec: corev1.PodSpec{
+			NodeName:           "node-1",
+			ServiceAccountName: "api",
+			Priority:           &priority,
+			Containers:         []corev1.Container{{Name: "app", Image: "api:1"}, {Name: "proxy", Image: "envoy:1"}},
+		},
+	}
+	event, err := registry.Convert(pod, nil, time.Now())
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if event.FieldDiff[FieldNodeName] != "node-1" {
+		t.Errorf("Expected the built-in fields to be kept, got %+v", event.FieldDiff)
+	}
+	if event.FieldDiff["spec.serviceAccountName"] != "api" || event.FieldDiff["spec.priority"] != 100 {
+		t.Errorf("Expected the declared scalar fields, got %+v", event.FieldDiff)
+	}
+	if names := event.FieldDiff["spec.containers[*].name"]; !reflect.DeepEqual(names, []interface{}{"app", "proxy"}) {
+		t.Errorf("Expected a list of container names, got %#v", names)
+	}
+	if _, exists := event.FieldDiff["spec.hostname"]; exists {
+		t.Errorf("Expected an unset field to stay unset, got %+v", event.FieldDiff)
+	}
+
+	cert := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata":   map[string]interface{}{"uid": "cert-1", "name": "tls"},
+		"status":     map[string]interface{}{"notAfter": "2026-01-01T00:00:00Z"},
+	}}
+	event, err = registry.Convert(cert, nil, time.Now())
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if event.FieldDiff["status.notAfter"] != "2026-01-01T00:00:00Z" {
+		t.Errorf("Expected the custom resource field, got %+v", event.FieldDiff)
+	}
+}
+
+func TestLoadFieldSpecs_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"kind: Pod": "unknown field",
+		"kinds: [{kind: Pod, fields: [{name: a, path: .a}]}]":                                      "needs apiVersion and kind",
+		"kinds: [{apiVersion: v1, kind: Pod}]":                                                     "lists no fields",
+		"kinds: [{apiVersion: v1, kind: Pod, fields: [{name: a, path: .a}, {name: a, path: .b}]}]": "declared twice",
+		"kinds: [{apiVersion: v1, kind: Pod, fields: [{name: a, path: '.a['}]}]":                   "invalid path for a",
+	}
+	for content, want := range tests {
+		_, err := LoadFieldSpecs(writeFieldSpecs(t, content))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", content, want, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package watcher
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// FieldMounts lists the ConfigMaps, Secrets and PersistentVolumeClaims a
+// pod mounts as Kind/name references
+const FieldMounts = "spec.volumes"
+
+// MountRefs returns the Kind/name of every ConfigMap, Secret and
+// PersistentVolumeClaim a pod's volumes mount, projected sources included
+func MountRefs(pod *corev1.Pod) []string {
+	seen := make(map[string]bool)
+	for _, v := range pod.Spec.Volumes {
+		switch {
+		case v.ConfigMap != nil:
+			seen["ConfigMap/"+v.ConfigMap.Name] = true
+		case v.Secret != nil:
+			seen["Secret/"+v.Secret.SecretName] = true
+		case v.PersistentVolumeClaim != nil:
+			seen["PersistentVolumeClaim/"+v.PersistentVolumeClaim.ClaimName] = true
+		case v.Projected != nil:
+			for _, source := range v.Projected.Sources {
+				if source.ConfigMap != nil {
+					seen["ConfigMap/"+source.ConfigMap.Name] = true
+				}
+				if source.Secret != nil {
+					seen["Secret/"+source.Secret.Name] = true
+				}
+			}
+		}
+	}
+	refs := make([]string, 0, len(seen))
+	for ref := range seen {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// Mounts returns the references recorded under FieldMounts, whether held in
+// memory or decoded from JSON
+func Mounts(fields map[string]interface{}) []string {
+	switch v := fields[FieldMounts].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		refs := make([]string, 0, len(v))
+		for _, ref := range v {
+			if s, ok := ref.(string); ok {
+				refs = append(refs, s)
+			}
+		}
+		return refs
+	}
+	return nil
+}
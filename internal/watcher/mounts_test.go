@@ -0,0 +1,30 @@
+package watcher
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMountRefs(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Volumes: []corev1.Volume{
+		{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"}}}},
+		{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}},
+		{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		{Name: "creds", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: []corev1.VolumeProjection{
+			{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "db-creds"}}},
+			{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"}}},
+		}}}},
+	}}}
+
+	want := []string{"ConfigMap/app-config", "PersistentVolumeClaim/data", "Secret/db-creds"}
+	refs := MountRefs(pod)
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("Expected %v, got %v", want, refs)
+	}
+	// Refs decoded from JSON read back the same
+	if got := Mounts(map[string]interface{}{FieldMounts: []interface{}{"ConfigMap/app-config", "PersistentVolumeClaim/data", "Secret/db-creds"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v from JSON, got %v", want, got)
+	}
+}
@@ -0,0 +1,203 @@
+package watcher
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// DefaultQueueCapacity bounds the number of distinct resources waiting to
+// be recorded
+const DefaultQueueCapacity = 10000
+
+// EventQueue sits between informer callbacks and the StateStore. Enqueue
+// never blocks: a newer event for a UID that is still waiting replaces the
+// older one, and events for new UIDs are dropped once the queue is full.
+type EventQueue struct {
+	mu       sync.Mutex
+	ready    chan struct{} // signalled when the queue becomes non-empty
+	pending  map[string]queued
+	order    []string // FIFO of UIDs awaiting delivery
+	capacity int
+	closed   bool
+
+	enqueued     atomic.Uint64
+	coalesced    atomic.Uint64
+	dropped      atomic.Uint64
+	processed    atomic.Uint64
+	recordErrors atomic.Uint64
+}
+
+// queued is a waiting event; deleted marks a resource's last state, after
+// which stores supporting it forget the resource
+type queued struct {
+	event   types.StateEvent
+	deleted bool
+}
+
+// QueueStats is a point-in-time snapshot of queue metrics
+type QueueStats struct {
+	Depth        int    `json:"depth"`
+	Capacity     int    `json:"capacity"`
+	Enqueued     uint64 `json:"enqueued"`
+	Coalesced    uint64 `json:"coalesced"`
+	Dropped      uint64 `json:"dropped"`
+	Processed    uint64 `json:"processed"`
+	RecordErrors uint64 `json:"record_errors"`
+}
+
+func NewEventQueue(capacity int) *EventQueue {
+	if capacity <= 0 {
+		capacity = DefaultQueueCapacity
+	}
+	return &EventQueue{
+		ready:    make(chan struct{}, 1),
+		pending:  make(map[string]queued),
+		capacity: capacity,
+	}
+}
+
+// Enqueue offers an event without blocking. It returns false when the event
+// was dropped because the queue is full or closed.
+func (q *EventQueue) Enqueue(event types.StateEvent) bool {
+	return q.enqueue(queued{event: event})
+}
+
+// EnqueueDeleted offers the last state of a deleted resource, which is
+// forgotten once recorded
+func (q *EventQueue) EnqueueDeleted(event types.StateEvent) bool {
+	return q.enqueue(queued{event: event, deleted: true})
+}
+
+func (q *EventQueue) enqueue(entry queued) bool {
+	event := entry.event
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		q.dropped.Add(1)
+		return false
+	}
+
+	if _, waiting := q.pending[event.UID]; waiting {
+		q.pending[event.UID] = entry
+		q.coalesced.Add(1)
+		return true
+	}
+
+	if len(q.order) >= q.capacity {
+		q.dropped.Add(1)
+		return false
+	}
+
+	q.pending[event.UID] = entry
+	q.order = append(q.order, event.UID)
+	q.enqueued.Add(1)
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Next blocks until an event is available, the queue is closed and
+// drained, or ctx is cancelled
+func (q *EventQueue) Next(ctx context.Context) (types.StateEvent, bool) {
+	entry, ok := q.next(ctx)
+	return entry.event, ok
+}
+
+func (q *EventQueue) next(ctx context.Context) (queued, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.order) > 0 {
+			uid := q.order[0]
+			q.order = q.order[1:]
+			entry := q.pending[uid]
+			delete(q.pending, uid)
+			more := len(q.order) > 0
+			q.mu.Unlock()
+
+			// Keep waking consumers while work remains
+			if more {
+				select {
+				case q.ready <- struct{}{}:
+				default:
+				}
+			}
+			return entry, true
+		}
+		closed := q.closed
+		q.mu.Unlock()
+
+		if closed {
+			return queued{}, false
+		}
+
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			return queued{}, false
+		}
+	}
+}
+
+// Run records queued events into store until ctx is cancelled or the queue
+// is closed and drained. Deleted resources are then forgotten by stores
+// implementing state.Forgetter.
+func (q *EventQueue) Run(ctx context.Context, store state.StateStore) {
+	forgetter, _ := store.(state.Forgetter)
+	for {
+		entry, ok := q.next(ctx)
+		if !ok {
+			return
+		}
+		event := entry.event
+		if err := store.Record(event); err != nil {
+			q.recordErrors.Add(1)
+			log.Printf("Failed to record %s %s/%s: %v", event.Kind, event.Namespace, event.Name, err)
+			continue
+		}
+		if entry.deleted && forgetter != nil {
+			if err := forgetter.Forget(event.UID); err != nil {
+				q.recordErrors.Add(1)
+				log.Printf("Failed to forget deleted %s %s/%s: %v", event.Kind, event.Namespace, event.Name, err)
+				continue
+			}
+		}
+		q.processed.Add(1)
+	}
+}
+
+// Close stops accepting events; Run returns once the backlog is drained
+func (q *EventQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *EventQueue) Stats() QueueStats {
+	q.mu.Lock()
+	depth := len(q.order)
+	q.mu.Unlock()
+
+	return QueueStats{
+		Depth:        depth,
+		Capacity:     q.capacity,
+		Enqueued:     q.enqueued.Load(),
+		Coalesced:    q.coalesced.Load(),
+		Dropped:      q.dropped.Load(),
+		Processed:    q.processed.Load(),
+		RecordErrors: q.recordErrors.Load(),
+	}
+}
@@ -0,0 +1,96 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+func TestEventQueue_CoalescesSameUID(t *testing.T) {
+	q := NewEventQueue(10)
+
+	for i := 1; i <= 5; i++ {
+		q.Enqueue(types.StateEvent{UID: "pod-1", Kind: "Pod", Version: fmt.Sprintf("%d", i)})
+	}
+
+	stats := q.Stats()
+	if stats.Depth != 1 {
+		t.Errorf("Expected depth 1, got %d", stats.Depth)
+	}
+	if stats.Coalesced != 4 {
+		t.Errorf("Expected 4 coalesced events, got %d", stats.Coalesced)
+	}
+
+	event, ok := q.Next(context.Background())
+	if !ok {
+		t.Fatal("Expected an event")
+	}
+	if event.Version != "5" {
+		t.Errorf("Expected latest version 5, got %s", event.Version)
+	}
+}
+
+func TestEventQueue_DropsWhenFull(t *testing.T) {
+	q := NewEventQueue(2)
+
+	q.Enqueue(types.StateEvent{UID: "pod-1"})
+	q.Enqueue(types.StateEvent{UID: "pod-2"})
+	if q.Enqueue(types.StateEvent{UID: "pod-3"}) {
+		t.Error("Expected enqueue to fail when the queue is full")
+	}
+	// Updates to waiting UIDs are still accepted
+	if !q.Enqueue(types.StateEvent{UID: "pod-1", Version: "2"}) {
+		t.Error("Expected update for a waiting UID to be coalesced")
+	}
+
+	stats := q.Stats()
+	if stats.Dropped != 1 {
+		t.Errorf("Expected 1 dropped event, got %d", stats.Dropped)
+	}
+	if stats.Depth != 2 {
+		t.Errorf("Expected depth 2, got %d", stats.Depth)
+	}
+}
+
+func TestEventQueue_RunRecordsIntoStore(t *testing.T) {
+	q := NewEventQueue(100)
+	store := state.NewMemoryStore()
+
+	for i := 0; i < 10; i++ {
+		q.Enqueue(types.StateEvent{UID: fmt.Sprintf("pod-%d", i), Kind: "Pod"})
+	}
+	q.Close()
+
+	done := make(chan struct{})
+	go func() {
+		q.Run(context.Background(), store)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after the queue was closed and drained")
+	}
+
+	if pods := store.GetLatestByKind("Pod"); len(pods) != 10 {
+		t.Errorf("Expected 10 pods recorded, got %d", len(pods))
+	}
+	if q.Stats().Processed != 10 {
+		t.Errorf("Expected 10 processed events, got %d", q.Stats().Processed)
+	}
+}
+
+func TestEventQueue_NextHonoursContext(t *testing.T) {
+	q := NewEventQueue(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, ok := q.Next(ctx); ok {
+		t.Error("Expected Next to return false when the context expires")
+	}
+}
@@ -0,0 +1,63 @@
+package watcher
+
+import (
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// Deployment fields used to compare desired and running pods
+const (
+	// FieldTemplateHash holds the pod-template-hash of the Deployment's
+	// newest ReplicaSet, i.e. the hash every pod should eventually carry
+	FieldTemplateHash = "status.templateHash"
+	// FieldSelector holds spec.selector.matchLabels
+	FieldSelector = "spec.selector"
+
+	revisionAnnotation = "deployment.kubernetes.io/revision"
+)
+
+// CurrentTemplateHash returns the pod-template-hash of the newest
+// ReplicaSet owned by d, or "" when none has been created yet
+func CurrentTemplateHash(d *appsv1.Deployment, replicaSets []appsv1.ReplicaSet) string {
+	var hash string
+	newest := int64(-1)
+	for _, rs := range replicaSets {
+		if rs.Namespace != d.Namespace || !ownedBy(rs, d) {
+			continue
+		}
+		revision, _ := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+		if revision > newest {
+			newest = revision
+			hash = rs.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+		}
+	}
+	return hash
+}
+
+// Selector returns the matchLabels recorded under FieldSelector, whether
+// held in memory or decoded from JSON
+func Selector(fields map[string]interface{}) map[string]string {
+	switch v := fields[FieldSelector].(type) {
+	case map[string]string:
+		return v
+	case map[string]interface{}:
+		labels := make(map[string]string, len(v))
+		for key, val := range v {
+			if s, ok := val.(string); ok {
+				labels[key] = s
+			}
+		}
+		return labels
+	}
+	return nil
+}
+
+func ownedBy(rs appsv1.ReplicaSet, d *appsv1.Deployment) bool {
+	for _, ref := range rs.OwnerReferences {
+		if ref.Kind == "Deployment" && ref.UID == d.UID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package watcher
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCurrentTemplateHash(t *testing.T) {
+	d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "prod", UID: "deploy-1"}}
+	owner := []metav1.OwnerReference{{Kind: "Deployment", UID: "deploy-1"}}
+	replicaSet := func(hash, revision string, owners []metav1.OwnerReference) appsv1.ReplicaSet {
+		return appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "prod",
+			Labels:          map[string]string{"pod-template-hash": hash},
+			Annotations:     map[string]string{"deployment.kubernetes.io/revision": revision},
+			OwnerReferences: owners,
+		}}
+	}
+
+	replicaSets := []appsv1.ReplicaSet{
+		replicaSet("aaa", "1", owner),
+		replicaSet("ccc", "3", owner),
+		replicaSet("bbb", "2", owner),
+		replicaSet("zzz", "9", nil),
+	}
+	if hash := CurrentTemplateHash(d, replicaSets); hash != "ccc" {
+		t.Errorf("Expected the newest owned revision, got %q", hash)
+	}
+	if hash := CurrentTemplateHash(d, nil); hash != "" {
+		t.Errorf("Expected no hash without ReplicaSets, got %q", hash)
+	}
+}
@@ -0,0 +1,69 @@
+package watcher
+
+import (
+	"github.com/aonescu/akari/internal/scheduling"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SchedulingFields records the pod's node constraints for the
+// schedulability analysis of Unschedulable pods
+func SchedulingFields(pod *corev1.Pod) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if len(pod.Spec.NodeSelector) > 0 {
+		fields[scheduling.FieldNodeSelector] = pod.Spec.NodeSelector
+	}
+	if len(pod.Spec.Tolerations) > 0 {
+		tolerations := make([]scheduling.Toleration, len(pod.Spec.Tolerations))
+		for i, t := range pod.Spec.Tolerations {
+			tolerations[i] = scheduling.Toleration{
+				Key:      t.Key,
+				Operator: string(t.Operator),
+				Value:    t.Value,
+				Effect:   string(t.Effect),
+			}
+		}
+		fields[scheduling.FieldTolerations] = tolerations
+	}
+	if a := pod.Spec.Affinity; a != nil && a.NodeAffinity != nil && a.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		var terms []scheduling.Term
+		for _, term := range a.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+			t := scheduling.Term{MatchExpressions: make([]scheduling.Requirement, len(term.MatchExpressions))}
+			for i, r := range term.MatchExpressions {
+				t.MatchExpressions[i] = scheduling.Requirement{Key: r.Key, Operator: string(r.Operator), Values: r.Values}
+			}
+			terms = append(terms, t)
+		}
+		fields[scheduling.FieldNodeAffinity] = terms
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Reason != "" {
+			fields[scheduling.FieldScheduledReason] = cond.Reason
+			if cond.Message != "" {
+				fields[scheduling.FieldScheduledMessage] = cond.Message
+			}
+		}
+	}
+	return fields
+}
+
+// NodeSchedulingFields records the taints and cordon state of a node
+func NodeSchedulingFields(node *corev1.Node) map[string]interface{} {
+	taints := make([]scheduling.Taint, len(node.Spec.Taints))
+	for i, t := range node.Spec.Taints {
+		taints[i] = scheduling.Taint{Key: t.Key, Value: t.Value, Effect: string(t.Effect)}
+	}
+	return map[string]interface{}{
+		scheduling.FieldTaints:        taints,
+		scheduling.FieldUnschedulable: node.Spec.Unschedulable,
+	}
+}
+
+// FailedSchedulingMessage returns the pod a FailedScheduling event is about
+// and the scheduler's message, to be recorded as the pod's
+// status.conditions[PodScheduled].message
+func FailedSchedulingMessage(event *corev1.Event) (podUID, message string, ok bool) {
+	if event.Reason != "FailedScheduling" || event.InvolvedObject.Kind != "Pod" {
+		return "", "", false
+	}
+	return string(event.InvolvedObject.UID), event.Message, true
+}
@@ -0,0 +1,48 @@
+package watcher
+
+import (
+	"testing"
+
+	"github.com/aonescu/akari/internal/scheduling"
+	"github.com/aonescu/akari/internal/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSchedulingFields(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			NodeSelector: map[string]string{"kubernetes.io/arch": "arm64"},
+			Tolerations:  []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}},
+			Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"gpu"}}},
+				}}},
+			}},
+		},
+		Status: corev1.PodStatus{Conditions: []corev1.PodCondition{
+			{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "Unschedulable"},
+		}},
+	}
+
+	fields := SchedulingFields(pod)
+	if fields[scheduling.FieldScheduledReason] != scheduling.ReasonUnschedulable {
+		t.Errorf("Expected the PodScheduled reason, got %v", fields[scheduling.FieldScheduledReason])
+	}
+
+	c := scheduling.PodConstraints(types.StateEvent{FieldDiff: fields})
+	if c.NodeSelector["kubernetes.io/arch"] != "arm64" || len(c.Tolerations) != 1 || len(c.Affinity) != 1 {
+		t.Errorf("Expected the constraints to round-trip, got %+v", c)
+	}
+	if c.Affinity[0].String() != "pool In [gpu]" {
+		t.Errorf("Unexpected affinity term: %s", c.Affinity[0])
+	}
+
+	node := &corev1.Node{Spec: corev1.NodeSpec{
+		Unschedulable: true,
+		Taints:        []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}},
+	}}
+	nodeFields := NodeSchedulingFields(node)
+	if nodeFields[scheduling.FieldUnschedulable] != true || len(nodeFields[scheduling.FieldTaints].([]scheduling.Taint)) != 1 {
+		t.Errorf("Unexpected node fields: %v", nodeFields)
+	}
+}
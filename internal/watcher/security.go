@@ -0,0 +1,146 @@
+package watcher
+
+import (
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Derived pod fields evaluated by the security invariant pack. Each holds
+// "True" when at least one container (init containers included) or volume
+// of the pod has the property.
+const (
+	FieldRunsAsRoot            = "security.runsAsRoot"
+	FieldPrivileged            = "security.privileged"
+	FieldMissingResourceLimits = "security.missingResourceLimits"
+	FieldHostPathMounts        = "security.hostPathMounts"
+	FieldUnpinnedImages        = "security.unpinnedImages"
+)
+
+// FieldDeployedBy holds the field manager that last wrote the workload's
+// spec. Security findings are attributed to it rather than to kubelet.
+const FieldDeployedBy = "metadata.deployedBy"
+
+// SecurityFields derives the security.* fields from a pod spec
+func SecurityFields(pod *corev1.Pod) map[string]interface{} {
+	var root, privileged, missingLimits, unpinned bool
+
+	containers := make([]corev1.Container, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	containers = append(containers, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+
+	for _, c := range containers {
+		if runsAsRoot(pod.Spec.SecurityContext, c.SecurityContext) {
+			root = true
+		}
+		if c.SecurityContext != nil && c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged {
+			privileged = true
+		}
+		if _, ok := c.Resources.Limits[corev1.ResourceCPU]; !ok {
+			missingLimits = true
+		}
+		if _, ok := c.Resources.Limits[corev1.ResourceMemory]; !ok {
+			missingLimits = true
+		}
+		if !ImagePinned(c.Image) {
+			unpinned = true
+		}
+	}
+
+	hostPath := false
+	for _, v := range pod.Spec.Volumes {
+		if v.HostPath != nil {
+			hostPath = true
+		}
+	}
+
+	return map[string]interface{}{
+		FieldRunsAsRoot:            conditionString(root),
+		FieldPrivileged:            conditionString(privileged),
+		FieldMissingResourceLimits: conditionString(missingLimits),
+		FieldHostPathMounts:        conditionString(hostPath),
+		FieldUnpinnedImages:        conditionString(unpinned),
+	}
+}
+
+// runsAsRoot applies the container security context over the pod's. A
+// container is only known not to be root when runAsNonRoot is set or it
+// runs as an explicit non-zero UID.
+func runsAsRoot(pod *corev1.PodSecurityContext, c *corev1.SecurityContext) bool {
+	var nonRoot *bool
+	var user *int64
+	if pod != nil {
+		nonRoot, user = pod.RunAsNonRoot, pod.RunAsUser
+	}
+	if c != nil {
+		if c.RunAsNonRoot != nil {
+			nonRoot = c.RunAsNonRoot
+		}
+		if c.RunAsUser != nil {
+			user = c.RunAsUser
+		}
+	}
+
+	if user != nil {
+		return *user == 0
+	}
+	return nonRoot == nil || !*nonRoot
+}
+
+// ImagePinned reports whether an image reference names a specific tag or
+// digest. Untagged references and :latest float to whatever was pushed last.
+func ImagePinned(image string) bool {
+	if strings.Contains(image, "@") {
+		return true
+	}
+	// A colon after the last slash separates the tag; earlier colons
+	// belong to a registry port
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	if i < 0 {
+		return false
+	}
+	return name[i+1:] != "latest"
+}
+
+// imageReference is the grammar of docker/distribution references:
+// [registry[:port]/]path[:tag][@digest], with lowercase path components
+var imageReference = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?(?:@[a-z0-9]+(?:[+._-][a-z0-9]+)*:[a-fA-F0-9]{32,})?$`)
+
+// ImageReferenceValid reports whether image parses as an image reference.
+// The kubelet refuses invalid ones with InvalidImageName.
+func ImageReferenceValid(image string) bool {
+	return len(image) <= 255 && imageReference.MatchString(image)
+}
+
+// DeployingActor returns the manager of the most recent managedFields
+// entry that wrote the object's spec, or "" when none did. Pods created
+// by a controller should take this from their owning workload instead.
+func DeployingActor(meta metav1.ObjectMeta) string {
+	var actor string
+	var latest metav1.Time
+	for _, mf := range meta.ManagedFields {
+		if mf.FieldsV1 == nil || !strings.Contains(string(mf.FieldsV1.Raw), `"f:spec"`) {
+			continue
+		}
+		if mf.Time != nil && mf.Time.Before(&latest) {
+			continue
+		}
+		actor = mf.Manager
+		if mf.Time != nil {
+			latest = *mf.Time
+		}
+	}
+	return actor
+}
+
+func conditionString(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
@@ -0,0 +1,109 @@
+package watcher
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSecurityFields(t *testing.T) {
+	privileged := true
+	nonRoot := true
+	limits := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("100m"),
+		corev1.ResourceMemory: resource.MustParse("64Mi"),
+	}
+
+	hardened := &corev1.Pod{Spec: corev1.PodSpec{
+		SecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: &nonRoot},
+		Containers: []corev1.Container{
+			{Name: "app", Image: "registry:5000/team/app:1.4.2", Resources: corev1.ResourceRequirements{Limits: limits}},
+		},
+	}}
+	for field, value := range SecurityFields(hardened) {
+		if value != "False" {
+			t.Errorf("Expected %s to be False for a hardened pod, got %v", field, value)
+		}
+	}
+
+	risky := &corev1.Pod{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init", Image: "busybox"}},
+		Containers: []corev1.Container{{
+			Name:            "app",
+			Image:           "app@sha256:abc",
+			SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+			Resources:       corev1.ResourceRequirements{Limits: limits},
+		}},
+		Volumes: []corev1.Volume{{Name: "host", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run"}}}},
+	}}
+	for field, value := range SecurityFields(risky) {
+		if value != "True" {
+			t.Errorf("Expected %s to be True for a risky pod, got %v", field, value)
+		}
+	}
+}
+
+func TestRunsAsRoot_ContainerOverridesPod(t *testing.T) {
+	nonRoot := true
+	rootUID := int64(0)
+	pod := &corev1.PodSecurityContext{RunAsNonRoot: &nonRoot}
+	if runsAsRoot(pod, nil) {
+		t.Error("Expected runAsNonRoot on the pod to apply to containers")
+	}
+	if !runsAsRoot(pod, &corev1.SecurityContext{RunAsUser: &rootUID}) {
+		t.Error("Expected an explicit UID 0 to run as root")
+	}
+}
+
+func TestImagePinned(t *testing.T) {
+	cases := map[string]bool{
+		"nginx":                      false,
+		"nginx:latest":               false,
+		"localhost:5000/nginx":       false,
+		"nginx:1.27":                 true,
+		"localhost:5000/nginx:1.27":  true,
+		"nginx@sha256:0123456789abc": true,
+	}
+	for image, want := range cases {
+		if got := ImagePinned(image); got != want {
+			t.Errorf("ImagePinned(%q) = %v, want %v", image, got, want)
+		}
+	}
+}
+
+func TestImageReferenceValid(t *testing.T) {
+	cases := map[string]bool{
+		"nginx":                          true,
+		"nginx:1.27":                     true,
+		"localhost:5000/team/nginx:1.27": true,
+		"ghcr.io/org/app@sha256:" + strings.Repeat("a", 64): true,
+		"":             false,
+		"Nginx:1.27":   false,
+		"nginx:":       false,
+		"nginx:1.27 ":  false,
+		"nginx@sha256": false,
+		"-nginx":       false,
+	}
+	for image, want := range cases {
+		if got := ImageReferenceValid(image); got != want {
+			t.Errorf("ImageReferenceValid(%q) = %v, want %v", image, got, want)
+		}
+	}
+}
+
+func TestDeployingActor(t *testing.T) {
+	older := metav1.NewTime(time.Now().Add(-time.Hour))
+	newer := metav1.NewTime(time.Now())
+	meta := metav1.ObjectMeta{ManagedFields: []metav1.ManagedFieldsEntry{
+		{Manager: "helm", Time: &older, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{}}`)}},
+		{Manager: "argocd-controller", Time: &newer, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{}}`)}},
+		{Manager: "kube-controller-manager", Time: &newer, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:status":{}}`)}},
+	}}
+	if actor := DeployingActor(meta); actor != "argocd-controller" {
+		t.Errorf("Expected argocd-controller, got %q", actor)
+	}
+}
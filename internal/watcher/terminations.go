@@ -0,0 +1,71 @@
+package watcher
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Pod fields describing evictions and container terminations
+const (
+	// FieldStatusReason and FieldStatusMessage mirror pod.status.reason and
+	// message, which the kubelet sets to "Evicted" and the pressure cause
+	FieldStatusReason  = "status.reason"
+	FieldStatusMessage = "status.message"
+
+	FieldLastTerminationReason    = "status.containerStatuses.lastTermination.reason"
+	FieldLastTerminationContainer = "status.containerStatuses.lastTermination.container"
+	FieldLastTerminationAt        = "status.containerStatuses.lastTermination.finishedAt"
+	FieldRestartCount             = "status.containerStatuses.restartCount"
+	// FieldWaitingReason is why the first waiting container isn't running,
+	// e.g. CrashLoopBackOff or ImagePullBackOff
+	FieldWaitingReason    = "status.containerStatuses.waiting.reason"
+	FieldWaitingContainer = "status.containerStatuses.waiting.container"
+
+	ReasonEvicted   = "Evicted"
+	ReasonOOMKilled = "OOMKilled"
+)
+
+// RecentTerminationWindow is how long a container termination is reported
+// after it happened. Informer resyncs re-derive the fields, so a container
+// that has run cleanly since drops out of no_oom_killed.
+const RecentTerminationWindow = time.Hour
+
+// TerminationFields derives eviction, waiting and last-termination fields
+// from a pod
+func TerminationFields(pod *corev1.Pod, now time.Time) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if pod.Status.Reason != "" {
+		fields[FieldStatusReason] = pod.Status.Reason
+		fields[FieldStatusMessage] = pod.Status.Message
+	}
+
+	var restarts int32
+	var last *corev1.ContainerStateTerminated
+	var lastContainer string
+	for _, cs := range pod.Status.ContainerStatuses {
+		restarts += cs.RestartCount
+		if waiting := cs.State.Waiting; waiting != nil && waiting.Reason != "" {
+			if _, seen := fields[FieldWaitingReason]; !seen {
+				fields[FieldWaitingReason] = waiting.Reason
+				fields[FieldWaitingContainer] = cs.Name
+			}
+		}
+		terminated := cs.LastTerminationState.Terminated
+		if cs.State.Terminated != nil {
+			terminated = cs.State.Terminated
+		}
+		if terminated == nil || (last != nil && !terminated.FinishedAt.After(last.FinishedAt.Time)) {
+			continue
+		}
+		last, lastContainer = terminated, cs.Name
+	}
+	fields[FieldRestartCount] = int(restarts)
+
+	if last != nil && now.Sub(last.FinishedAt.Time) < RecentTerminationWindow {
+		fields[FieldLastTerminationReason] = last.Reason
+		fields[FieldLastTerminationContainer] = lastContainer
+		fields[FieldLastTerminationAt] = last.FinishedAt.UTC().Format(time.RFC3339)
+	}
+	return fields
+}
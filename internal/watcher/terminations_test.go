@@ -0,0 +1,57 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTerminationFields(t *testing.T) {
+	now := time.Now()
+	pod := &corev1.Pod{Status: corev1.PodStatus{
+		ContainerStatuses: []corev1.ContainerStatus{
+			{
+				Name:         "app",
+				RestartCount: 3,
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					Reason: "OOMKilled", FinishedAt: metav1.NewTime(now.Add(-5 * time.Minute)),
+				}},
+			},
+			{
+				Name:         "sidecar",
+				RestartCount: 1,
+				State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					Reason: "Error", FinishedAt: metav1.NewTime(now.Add(-30 * time.Minute)),
+				}},
+			},
+		},
+	}}
+
+	fields := TerminationFields(pod, now)
+	if fields[FieldLastTerminationReason] != ReasonOOMKilled || fields[FieldLastTerminationContainer] != "app" {
+		t.Errorf("Expected the most recent termination, got %v", fields)
+	}
+	if fields[FieldRestartCount] != 4 {
+		t.Errorf("Expected 4 restarts, got %v", fields[FieldRestartCount])
+	}
+	if fields[FieldWaitingReason] != "CrashLoopBackOff" || fields[FieldWaitingContainer] != "sidecar" {
+		t.Errorf("Expected the sidecar to be waiting, got %v", fields)
+	}
+	if _, ok := fields[FieldStatusReason]; ok {
+		t.Error("Expected no status reason for a running pod")
+	}
+
+	later := TerminationFields(pod, now.Add(2*time.Hour))
+	if _, ok := later[FieldLastTerminationReason]; ok {
+		t.Error("Expected old terminations to drop out")
+	}
+
+	pod.Status.Reason = ReasonEvicted
+	pod.Status.Message = "The node was low on resource: memory."
+	if fields := TerminationFields(pod, now); fields[FieldStatusReason] != ReasonEvicted || fields[FieldStatusMessage] == "" {
+		t.Errorf("Expected eviction fields, got %v", fields)
+	}
+}
@@ -0,0 +1,134 @@
+package watcher
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// Version fields recorded on Nodes and evaluated by the version skew
+// invariants
+const (
+	FieldKubeletVersion = "status.nodeInfo.kubeletVersion"
+	// FieldControlPlaneVersion is the control plane's version as the node
+	// was last converted
+	FieldControlPlaneVersion = "version.controlPlane"
+	// FieldSkewSupported is "True" when the kubelet is no newer than the
+	// control plane and at most MaxKubeletSkew minor versions older
+	FieldSkewSupported = "version.skewSupported"
+	// FieldPoolMinorConsistent is "True" when the kubelet runs the newest
+	// minor version of its node pool
+	FieldPoolMinorConsistent = "version.poolMinorConsistent"
+	FieldNodePool            = "version.nodePool"
+)
+
+// MaxKubeletSkew is how many minor versions a kubelet may trail the
+// control plane by, per the Kubernetes version skew policy
+const MaxKubeletSkew = 3
+
+// nodePoolLabels name a node's pool on the common managed platforms and
+// autoscalers, in order of preference
+var nodePoolLabels = []string{
+	"karpenter.sh/nodepool",
+	"eks.amazonaws.com/nodegroup",
+	"cloud.google.com/gke-nodepool",
+	"kubernetes.azure.com/agentpool",
+}
+
+// controlPlaneLabels mark the nodes running the control plane
+var controlPlaneLabels = []string{
+	"node-role.kubernetes.io/control-plane",
+	"node-role.kubernetes.io/master",
+}
+
+// NodePool returns the pool a node belongs to, "" when no label names one
+func NodePool(node *corev1.Node) string {
+	for _, label := range nodePoolLabels {
+		if pool := node.Labels[label]; pool != "" {
+			return pool
+		}
+	}
+	return ""
+}
+
+// ControlPlaneVersion returns the newest kubelet version of the
+// control-plane nodes, whose components run the same release, or
+// serverVersion when set, as reported by the API server on managed
+// clusters without visible control-plane nodes
+func ControlPlaneVersion(nodes []corev1.Node, serverVersion string) string {
+	if serverVersion != "" {
+		return serverVersion
+	}
+	var newest *version.Version
+	var newestRaw string
+	for _, node := range nodes {
+		if !isControlPlane(&node) {
+			continue
+		}
+		v, err := version.ParseGeneric(node.Status.NodeInfo.KubeletVersion)
+		if err == nil && (newest == nil || v.GreaterThan(newest)) {
+			newest, newestRaw = v, node.Status.NodeInfo.KubeletVersion
+		}
+	}
+	return newestRaw
+}
+
+// NewestPoolMinors returns the newest kubelet minor version of each node
+// pool. Nodes without a pool label are pooled together under "".
+func NewestPoolMinors(nodes []corev1.Node) map[string]uint {
+	newest := make(map[string]uint)
+	for _, node := range nodes {
+		if isControlPlane(&node) {
+			continue
+		}
+		v, err := version.ParseGeneric(node.Status.NodeInfo.KubeletVersion)
+		if err != nil {
+			continue
+		}
+		pool := NodePool(&node)
+		if minor, seen := newest[pool]; !seen || v.Minor() > minor {
+			newest[pool] = v.Minor()
+		}
+	}
+	return newest
+}
+
+// VersionFields derives the skew fields of a node. controlPlane is as
+// returned by ControlPlaneVersion and newestMinors by NewestPoolMinors;
+// fields that can't be decided, e.g. without a control plane version, are
+// omitted.
+func VersionFields(node *corev1.Node, controlPlane string, newestMinors map[string]uint) map[string]interface{} {
+	fields := make(map[string]interface{})
+	kubelet, err := version.ParseGeneric(node.Status.NodeInfo.KubeletVersion)
+	if err != nil {
+		return fields
+	}
+	pool := NodePool(node)
+	if pool != "" {
+		fields[FieldNodePool] = pool
+	}
+
+	if cp, err := version.ParseGeneric(controlPlane); err == nil {
+		fields[FieldControlPlaneVersion] = controlPlane
+		fields[FieldSkewSupported] = conditionString(skewSupported(kubelet, cp))
+	}
+	if newest, ok := newestMinors[pool]; ok && !isControlPlane(node) {
+		fields[FieldPoolMinorConsistent] = conditionString(kubelet.Minor() >= newest)
+	}
+	return fields
+}
+
+func skewSupported(kubelet, controlPlane *version.Version) bool {
+	if kubelet.Major() != controlPlane.Major() || kubelet.Minor() > controlPlane.Minor() {
+		return false
+	}
+	return controlPlane.Minor()-kubelet.Minor() <= MaxKubeletSkew
+}
+
+func isControlPlane(node *corev1.Node) bool {
+	for _, label := range controlPlaneLabels {
+		if _, ok := node.Labels[label]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVersionFields(t *testing.T) {
+	node := func(name, kubelet string, labels map[string]string) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Status:     corev1.NodeStatus{NodeInfo: corev1.NodeSystemInfo{KubeletVersion: kubelet}},
+		}
+	}
+	pool := func(name string) map[string]string { return map[string]string{"eks.amazonaws.com/nodegroup": name} }
+	nodes := []corev1.Node{
+		node("cp-1", "v1.30.2", map[string]string{"node-role.kubernetes.io/control-plane": ""}),
+		node("web-1", "v1.30.1", pool("web")),
+		node("web-2", "v1.29.6", pool("web")),
+		node("batch-1", "v1.26.0", pool("batch")),
+		node("edge-1", "v1.31.0", pool("edge")),
+	}
+	cluster := &Cluster{Nodes: nodes}
+	registry := DefaultConverters()
+
+	tests := map[string]struct {
+		skew, consistent string
+	}{
+		"cp-1":    {skew: "True"},
+		"web-1":   {skew: "True", consistent: "True"},
+		"web-2":   {skew: "True", consistent: "False"},
+		"batch-1": {skew: "False", consistent: "True"},
+		"edge-1":  {skew: "False", consistent: "True"},
+	}
+	for i := range nodes {
+		event, err := registry.Convert(&nodes[i], cluster, time.Now())
+		if err != nil {
+			t.Fatalf("Convert failed: %v", err)
+		}
+		want := tests[event.Name]
+		if event.FieldDiff[FieldControlPlaneVersion] != "v1.30.2" || event.FieldDiff[FieldKubeletVersion] != nodes[i].Status.NodeInfo.KubeletVersion {
+			t.Errorf("%s: expected the kubelet and control plane versions, got %+v", event.Name, event.FieldDiff)
+		}
+		if got := event.FieldDiff[FieldSkewSupported]; got != want.skew {
+			t.Errorf("%s: expected skew supported %q, got %v", event.Name, want.skew, got)
+		}
+		if got, _ := event.FieldDiff[FieldPoolMinorConsistent].(string); got != want.consistent {
+			t.Errorf("%s: expected pool consistent %q, got %q", event.Name, want.consistent, got)
+		}
+	}
+
+	// The API server's version wins over control-plane nodes, and without
+	// either the skew is left undecided
+	if v := ControlPlaneVersion(nodes, "v1.31.1"); v != "v1.31.1" {
+		t.Errorf("Expected the server version, got %s", v)
+	}
+	fields := VersionFields(&nodes[1], ControlPlaneVersion(nodes[1:], ""), NewestPoolMinors(nodes))
+	if _, exists := fields[FieldSkewSupported]; exists {
+		t.Errorf("Expected no skew without a control plane version, got %+v", fields)
+	}
+}
@@ -0,0 +1,251 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DefaultResync is how often informers replay their caches. Replays that
+// change no field aren't recorded.
+const DefaultResync = 10 * time.Minute
+
+// Options tune a KubernetesWatcher
+type Options struct {
+	// Namespace limits the watch to one namespace; Nodes and Namespaces
+	// are always watched
+	Namespace     string
+	Resync        time.Duration
+	QueueCapacity int
+	// Converters defaults to DefaultConverters
+	Converters *ConverterRegistry
+}
+
+// KubernetesWatcher records the live state of Nodes, Namespaces, Pods,
+// Services and Deployments through client-go informers. Endpoints and
+// ReplicaSets are watched for the fields Services and Deployments derive
+// from them. Events pass through an EventQueue so slow stores never block
+// the informers.
+type KubernetesWatcher struct {
+	client     kubernetes.Interface
+	store      state.StateStore
+	queue      *EventQueue
+	factory    informers.SharedInformerFactory
+	converters *ConverterRegistry
+
+	nodes       corelisters.NodeLister
+	services    corelisters.ServiceLister
+	endpoints   corelisters.EndpointsLister
+	replicaSets appslisters.ReplicaSetLister
+	deployments appslisters.DeploymentLister
+
+	serverVersion string
+
+	mu sync.Mutex
+	// recorded holds the fields last queued per UID, so resyncs and
+	// status updates that change nothing recorded are skipped
+	recorded map[string]recordedState
+}
+
+type recordedState struct {
+	labels map[string]string
+	fields map[string]interface{}
+}
+
+func NewKubernetesWatcher(client kubernetes.Interface, store state.StateStore, opts Options) *KubernetesWatcher {
+	if opts.Resync <= 0 {
+		opts.Resync = DefaultResync
+	}
+	if opts.Converters == nil {
+		opts.Converters = DefaultConverters()
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(client, opts.Resync, informers.WithNamespace(opts.Namespace))
+	return &KubernetesWatcher{
+		client:      client,
+		store:       store,
+		queue:       NewEventQueue(opts.QueueCapacity),
+		factory:     factory,
+		converters:  opts.Converters,
+		nodes:       factory.Core().V1().Nodes().Lister(),
+		services:    factory.Core().V1().Services().Lister(),
+		endpoints:   factory.Core().V1().Endpoints().Lister(),
+		replicaSets: factory.Apps().V1().ReplicaSets().Lister(),
+		deployments: factory.Apps().V1().Deployments().Lister(),
+		recorded:    make(map[string]recordedState),
+	}
+}
+
+// Start syncs the informer caches, records every existing resource and
+// keeps recording changes until ctx is cancelled. It returns once the
+// caches are synced.
+func (w *KubernetesWatcher) Start(ctx context.Context) error {
+	// The API server reports the control plane version of managed clusters,
+	// whose control-plane nodes aren't listed
+	if info, err := w.client.Discovery().ServerVersion(); err == nil {
+		w.serverVersion = info.GitVersion
+	}
+
+	core, apps := w.factory.Core().V1(), w.factory.Apps().V1()
+	watched := []cache.SharedIndexInformer{
+		core.Nodes().Informer(),
+		core.Namespaces().Informer(),
+		core.Pods().Informer(),
+		core.Services().Informer(),
+		apps.Deployments().Informer(),
+	}
+	endpoints := core.Endpoints().Informer()
+	replicaSets := apps.ReplicaSets().Informer()
+
+	w.factory.Start(ctx.Done())
+	for informerType, synced := range w.factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync %v informer", informerType)
+		}
+	}
+
+	// Handlers are added once the caches are synced, so the replayed adds
+	// of existing resources see their Endpoints and ReplicaSets
+	for _, informer := range watched {
+		if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { w.handle(obj, false) },
+			UpdateFunc: func(_, obj interface{}) { w.handle(obj, false) },
+			DeleteFunc: func(obj interface{}) { w.handle(obj, true) },
+		}); err != nil {
+			return err
+		}
+	}
+	related := cache.ResourceEventHandlerFuncs{
+		AddFunc:    w.handleRelated,
+		UpdateFunc: func(_, obj interface{}) { w.handleRelated(obj) },
+		DeleteFunc: w.handleRelated,
+	}
+	for _, informer := range []cache.SharedIndexInformer{endpoints, replicaSets} {
+		if _, err := informer.AddEventHandler(related); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		w.queue.Close()
+	}()
+	go w.queue.Run(ctx, w.store)
+	return nil
+}
+
+// Stats reports the queue between the informers and the store
+func (w *KubernetesWatcher) Stats() QueueStats {
+	return w.queue.Stats()
+}
+
+// handle converts an added, updated or deleted resource and queues it. A
+// deleted resource is recorded one last time with a deletion timestamp,
+// for its history, then forgotten so it is no longer evaluated.
+func (w *KubernetesWatcher) handle(obj interface{}, deleted bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object, ok := obj.(runtime.Object)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	event, err := w.converters.Convert(object, w.cluster(object), now)
+	if err != nil {
+		log.Printf("Failed to convert %T: %v", obj, err)
+		return
+	}
+
+	w.mu.Lock()
+	if deleted {
+		delete(w.recorded, event.UID)
+	} else {
+		last, seen := w.recorded[event.UID]
+		if seen && maps.Equal(last.labels, event.Labels) && reflect.DeepEqual(last.fields, event.FieldDiff) {
+			w.mu.Unlock()
+			return
+		}
+		w.recorded[event.UID] = recordedState{labels: event.Labels, fields: event.FieldDiff}
+	}
+	w.mu.Unlock()
+
+	if _, deleting := event.FieldDiff[FieldDeletionTimestamp]; deleted && !deleting {
+		event.FieldDiff[FieldDeletionTimestamp] = now.UTC().Format(time.RFC3339)
+	}
+	enqueue := w.queue.Enqueue
+	if deleted {
+		enqueue = w.queue.EnqueueDeleted
+	}
+	if !enqueue(event) {
+		// Forget the dropped state, so the next resync queues it again
+		w.mu.Lock()
+		delete(w.recorded, event.UID)
+		w.mu.Unlock()
+		log.Printf("Event queue full, dropped %s %s/%s", event.Kind, event.Namespace, event.Name)
+	}
+}
+
+// handleRelated re-records the Service whose Endpoints or the Deployment
+// whose ReplicaSet changed
+func (w *KubernetesWatcher) handleRelated(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	switch o := obj.(type) {
+	case *corev1.Endpoints:
+		if svc, err := w.services.Services(o.Namespace).Get(o.Name); err == nil {
+			w.handle(svc, false)
+		}
+	case *appsv1.ReplicaSet:
+		if ref := metav1.GetControllerOf(o); ref != nil && ref.Kind == "Deployment" {
+			if d, err := w.deployments.Deployments(o.Namespace).Get(ref.Name); err == nil {
+				w.handle(d, false)
+			}
+		}
+	}
+}
+
+// cluster gathers from the caches the related objects the conversion of
+// obj reads
+func (w *KubernetesWatcher) cluster(obj runtime.Object) *Cluster {
+	c := &Cluster{ServerVersion: w.serverVersion}
+	switch o := obj.(type) {
+	case *corev1.Node:
+		nodes, _ := w.nodes.List(labels.Everything())
+		c.Nodes = values(nodes)
+	case *corev1.Service:
+		if ep, err := w.endpoints.Endpoints(o.Namespace).Get(o.Name); err == nil {
+			c.Endpoints = []corev1.Endpoints{*ep}
+		}
+	case *appsv1.Deployment:
+		replicaSets, _ := w.replicaSets.ReplicaSets(o.Namespace).List(labels.Everything())
+		services, _ := w.services.Services(o.Namespace).List(labels.Everything())
+		endpoints, _ := w.endpoints.Endpoints(o.Namespace).List(labels.Everything())
+		c.ReplicaSets, c.Services, c.Endpoints = values(replicaSets), values(services), values(endpoints)
+	}
+	return c
+}
+
+func values[T any](items []*T) []T {
+	out := make([]T, len(items))
+	for i, item := range items {
+		out[i] = *item
+	}
+	return out
+}
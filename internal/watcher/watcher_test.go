@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubernetesWatcher_RecordsLiveState(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", UID: "node-1"},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+		}},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "pod-1"},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "svc-1"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+	}
+	client := fake.NewSimpleClientset(node, pod, svc)
+	store := state.NewMemoryStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := NewKubernetesWatcher(client, store, Options{})
+	if err := w.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	waitFor(t, store, "pod-1", func(e types.StateEvent) bool { return e.FieldDiff[FieldNodeName] == "node-1" })
+	waitFor(t, store, "node-1", func(e types.StateEvent) bool { return e.FieldDiff["status.conditions[Ready].status"] == "True" })
+	waitFor(t, store, "svc-1", func(e types.StateEvent) bool {
+		return len(e.FieldDiff[FieldEndpointAddresses].([]interface{})) == 0
+	})
+
+	// New endpoints re-record the Service they belong to
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+	}
+	if _, err := client.CoreV1().Endpoints("default").Create(ctx, endpoints, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, store, "svc-1", func(e types.StateEvent) bool {
+		return len(e.FieldDiff[FieldEndpointAddresses].([]interface{})) == 1
+	})
+
+	// Deleted resources are recorded one last time as going away, then
+	// forgotten
+	if err := client.CoreV1().Pods("default").Delete(ctx, "web", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for _, exists := store.GetByUID("pod-1"); exists && time.Now().Before(deadline); _, exists = store.GetByUID("pod-1") {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(store.GetLatestByKind("Pod")) != 0 {
+		t.Errorf("Expected the deleted pod forgotten, got %v", store.GetLatestByKind("Pod"))
+	}
+	history, _ := store.GetHistory("pod-1", 1)
+	if _, deleting := history[0].FieldDiff[FieldDeletionTimestamp]; !deleting {
+		t.Errorf("Expected the deletion kept in history, got %+v", history[0])
+	}
+
+	if stats := w.Stats(); stats.Dropped != 0 || stats.RecordErrors != 0 {
+		t.Errorf("Expected no dropped or failed events, got %+v", stats)
+	}
+}
+
+func TestKubernetesWatcher_SkipsUnchangedUpdates(t *testing.T) {
+	w := NewKubernetesWatcher(fake.NewSimpleClientset(), state.NewMemoryStore(), Options{})
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "pod-1", ResourceVersion: "1"}}
+
+	w.handle(pod, false)
+	resynced := pod.DeepCopy()
+	resynced.ResourceVersion = "2"
+	w.handle(resynced, false)
+	if stats := w.Stats(); stats.Enqueued != 1 {
+		t.Errorf("Expected an update changing no field to be skipped, got %d enqueued", stats.Enqueued)
+	}
+
+	changed := pod.DeepCopy()
+	changed.Status.Phase = corev1.PodFailed
+	w.handle(changed, false)
+	if stats := w.Stats(); stats.Coalesced != 1 {
+		t.Errorf("Expected the phase change to replace the queued event, got %+v", stats)
+	}
+}
+
+func TestKubernetesWatcher_RetriesDroppedEvents(t *testing.T) {
+	w := NewKubernetesWatcher(fake.NewSimpleClientset(), state.NewMemoryStore(), Options{QueueCapacity: 1})
+	w.handle(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default", UID: "pod-0"}}, false)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "pod-1"}}
+
+	// The queue is full, so the pod is dropped
+	w.handle(pod, false)
+	if stats := w.Stats(); stats.Dropped != 1 {
+		t.Fatalf("Expected the pod dropped, got %+v", stats)
+	}
+
+	// Once there is room, the resync of the unchanged pod is queued
+	w.queue.Next(context.Background())
+	w.handle(pod.DeepCopy(), false)
+	if stats := w.Stats(); stats.Enqueued != 2 {
+		t.Errorf("Expected the dropped pod queued on resync, got %+v", stats)
+	}
+}
+
+func waitFor(t *testing.T, store state.StateStore, uid string, ready func(types.StateEvent) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if event, ok := store.GetByUID(uid); ok && ready(event) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	event, _ := store.GetByUID(uid)
+	t.Fatalf("Timed out waiting for %s, last recorded %+v", uid, event)
+}
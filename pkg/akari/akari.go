@@ -0,0 +1,244 @@
+// Package akari embeds the invariant engine in other Go programs without
+// running the HTTP server.
+//
+// An Engine keeps the latest state of every resource recorded through
+// RecordEvent and evaluates its invariants against it:
+//
+//	eng, err := akari.New(akari.WithoutBuiltins(), akari.WithInvariants(webReady))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	eng.Subscribe(func(t akari.Transition) {
+//		log.Printf("%s %s on %s", t.Violation.InvariantID, t.Type, t.Violation.AffectedResource)
+//	})
+//	eng.RecordEvent(event)
+//	go eng.Run(ctx)
+//
+// Evaluate returns the current violations on demand; Run re-evaluates on an
+// interval and reports violations as they open and resolve.
+package akari
+
+import (
+	"context"
+	"time"
+
+	"github.com/aonescu/akari/internal/dsl"
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/internal/types"
+)
+
+// Invariant DSL
+type (
+	Invariant      = dsl.Invariant
+	Subject        = dsl.Subject
+	Predicate      = dsl.Predicate
+	FieldRef       = dsl.FieldRef
+	Requirement    = dsl.Requirement
+	Scope          = dsl.Scope
+	Responsibility = dsl.Responsibility
+	Operator       = dsl.Operator
+	Relation       = dsl.Relation
+	Severity       = dsl.Severity
+	Urgency        = dsl.Urgency
+	Duration       = dsl.Duration
+)
+
+const (
+	Equals         = dsl.Equals
+	NotEquals      = dsl.NotEquals
+	Exists         = dsl.Exists
+	NotExists      = dsl.NotExists
+	GreaterThan    = dsl.GreaterThan
+	GreaterOrEqual = dsl.GreaterOrEqual
+	LessThan       = dsl.LessThan
+	LessOrEqual    = dsl.LessOrEqual
+	Contains       = dsl.Contains
+	AnyTrue        = dsl.AnyTrue
+	AllTrue        = dsl.AllTrue
+	OlderThan      = dsl.OlderThan
+	NewerThan      = dsl.NewerThan
+
+	Same     = dsl.Same
+	Owner    = dsl.Owner
+	Selector = dsl.Selector
+	Node     = dsl.Node
+	Any      = dsl.Any
+
+	Critical = dsl.Critical
+	Degraded = dsl.Degraded
+	Warning  = dsl.Warning
+
+	UrgencyHigh = dsl.UrgencyHigh
+	UrgencyLow  = dsl.UrgencyLow
+	UrgencyNone = dsl.UrgencyNone
+)
+
+// State and results
+type (
+	// StateEvent is the state of one resource at a point in time
+	StateEvent = types.StateEvent
+	// Store holds the latest state of every resource
+	Store = state.StateStore
+	// Violation is the outcome of evaluating an invariant on a resource
+	Violation = engine.ViolationResult
+	// Transition reports a violation opening or resolving
+	Transition     = engine.Transition
+	TransitionType = engine.TransitionType
+	// EventEvaluation is what RecordAndEvaluate re-checked and found
+	EventEvaluation = engine.EventEvaluation
+)
+
+const (
+	TransitionOpened   = engine.TransitionOpened
+	TransitionResolved = engine.TransitionResolved
+)
+
+// NewMemoryStore returns the in-memory store New uses by default
+func NewMemoryStore() Store {
+	return state.NewMemoryStore()
+}
+
+type config struct {
+	store      Store
+	interval   time.Duration
+	builtins   bool
+	invariants []Invariant
+}
+
+// Option configures an Engine
+type Option func(*config)
+
+// WithStore evaluates against store instead of a new in-memory store
+func WithStore(store Store) Option {
+	return func(c *config) { c.store = store }
+}
+
+// WithInterval sets how often Run re-evaluates. The default is 30s.
+func WithInterval(d time.Duration) Option {
+	return func(c *config) { c.interval = d }
+}
+
+// WithoutBuiltins drops the built-in Kubernetes invariants so only the
+// invariants added by the caller are evaluated
+func WithoutBuiltins() Option {
+	return func(c *config) { c.builtins = false }
+}
+
+// WithInvariants registers invariants when the engine is created
+func WithInvariants(invariants ...Invariant) Option {
+	return func(c *config) { c.invariants = append(c.invariants, invariants...) }
+}
+
+// Engine evaluates invariants against recorded resource state
+type Engine struct {
+	store   Store
+	engine  *engine.InvariantEngine
+	monitor *engine.Monitor
+}
+
+// New creates an engine. It fails if any invariant from WithInvariants is
+// invalid.
+func New(opts ...Option) (*Engine, error) {
+	c := config{builtins: true}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.store == nil {
+		c.store = state.NewMemoryStore()
+	}
+
+	eng := engine.NewInvariantEngine(c.store)
+	if !c.builtins {
+		for _, inv := range eng.GetInvariants() {
+			eng.DeleteInvariant(inv.ID)
+		}
+	}
+
+	e := &Engine{
+		store:   c.store,
+		engine:  eng,
+		monitor: engine.NewMonitor(eng, c.interval),
+	}
+	for _, inv := range c.invariants {
+		if _, err := e.AddInvariant(inv); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Store returns the store the engine evaluates against
+func (e *Engine) Store() Store {
+	return e.store
+}
+
+// RecordEvent records the latest state of a resource
+func (e *Engine) RecordEvent(event StateEvent) error {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	return e.store.Record(event)
+}
+
+// RecordAndEvaluate records event and re-evaluates only the invariants
+// and resources its changed fields can affect. Its results go to the
+// caller only: subscribers hear of transitions from Tick or Run, which
+// also catch time-based predicates.
+func (e *Engine) RecordAndEvaluate(event StateEvent) (EventEvaluation, error) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if err := e.store.Record(event); err != nil {
+		return EventEvaluation{}, err
+	}
+	return e.engine.EvaluateEvent(event), nil
+}
+
+// AddInvariant validates and registers an invariant, replacing any with
+// the same ID, after inheriting from the invariant it extends. It returns
+// the registered definition with its version.
+func (e *Engine) AddInvariant(inv Invariant) (Invariant, error) {
+	inv, err := e.engine.ResolveExtends(inv)
+	if err != nil {
+		return Invariant{}, err
+	}
+	if err := inv.Validate(); err != nil {
+		return Invariant{}, err
+	}
+	return e.engine.UpsertInvariant(inv), nil
+}
+
+// RemoveInvariant stops evaluating an invariant. It reports whether the
+// invariant existed.
+func (e *Engine) RemoveInvariant(id string) bool {
+	_, ok := e.engine.DeleteInvariant(id)
+	return ok
+}
+
+// Invariants returns the registered invariants
+func (e *Engine) Invariants() []Invariant {
+	return e.engine.GetInvariants()
+}
+
+// Evaluate evaluates every invariant against the current state and returns
+// the violations
+func (e *Engine) Evaluate() []*Violation {
+	return engine.FilterByStatus(e.engine.EvaluateAll(), engine.StatusViolated)
+}
+
+// Subscribe registers fn to receive every transition found by Tick or Run
+func (e *Engine) Subscribe(fn func(Transition)) {
+	e.monitor.Subscribe(fn)
+}
+
+// Tick runs one evaluation pass, notifies subscribers and returns the
+// transitions since the previous pass
+func (e *Engine) Tick() []Transition {
+	return e.monitor.Tick()
+}
+
+// Run evaluates on every interval until ctx is cancelled
+func (e *Engine) Run(ctx context.Context) {
+	e.monitor.Run(ctx)
+}
@@ -0,0 +1,90 @@
+package akari
+
+import (
+	"fmt"
+	"testing"
+)
+
+var webReady = Invariant{
+	ID:             "web_ready",
+	Subject:        Subject{Kind: "Pod", Selector: map[string]string{"app": "web"}},
+	Predicate:      &Predicate{Field: "status.conditions[Ready].status", Operator: Equals, Value: "True"},
+	Responsibility: Responsibility{Primary: "kubelet"},
+	Severity:       Critical,
+}
+
+func webPod(ready string) StateEvent {
+	return StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web-1",
+		Labels:    map[string]string{"app": "web"},
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": ready},
+	}
+}
+
+func TestEngine(t *testing.T) {
+	if _, err := New(WithInvariants(Invariant{ID: "broken"})); err == nil {
+		t.Fatal("Expected an invalid invariant to be rejected")
+	}
+
+	eng, err := New(WithoutBuiltins(), WithInvariants(webReady))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if invariants := eng.Invariants(); len(invariants) != 1 {
+		t.Fatalf("Expected only web_ready without builtins, got %d invariants", len(invariants))
+	}
+
+	var transitions []Transition
+	eng.Subscribe(func(t Transition) { transitions = append(transitions, t) })
+
+	eng.RecordEvent(webPod("False"))
+	if violations := eng.Evaluate(); len(violations) != 1 || violations[0].ResponsibleActor != "kubelet" {
+		t.Fatalf("Expected web_ready to be violated by kubelet, got %+v", violations)
+	}
+	eng.Tick()
+
+	eng.RecordEvent(webPod("True"))
+	eng.Tick()
+	if len(transitions) != 2 || transitions[0].Type != TransitionOpened || transitions[1].Type != TransitionResolved {
+		t.Errorf("Expected the violation to open and resolve, got %+v", transitions)
+	}
+
+	if !eng.RemoveInvariant("web_ready") || eng.RemoveInvariant("web_ready") {
+		t.Error("Expected web_ready to be removed exactly once")
+	}
+}
+
+func TestRecordAndEvaluate(t *testing.T) {
+	eng, err := New(WithoutBuiltins(), WithInvariants(webReady))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := eng.RecordAndEvaluate(webPod("True")); err != nil {
+		t.Fatalf("RecordAndEvaluate failed: %v", err)
+	}
+	got, _ := eng.RecordAndEvaluate(webPod("False"))
+	if len(got.Results) != 1 || got.Results[0].InvariantID != "web_ready" || !got.Results[0].Violated {
+		t.Errorf("Expected web_ready violated by the readiness change, got %+v", got)
+	}
+	if got, _ := eng.RecordAndEvaluate(webPod("False")); len(got.Evaluated) != 0 {
+		t.Errorf("Expected an unchanged pod to evaluate nothing, got %+v", got)
+	}
+}
+
+func Example() {
+	eng, err := New(WithoutBuiltins(), WithInvariants(webReady))
+	if err != nil {
+		panic(err)
+	}
+	eng.Subscribe(func(t Transition) {
+		fmt.Println(t.Violation.InvariantID, t.Type, "on", t.Violation.AffectedResource)
+	})
+
+	eng.RecordEvent(webPod("False"))
+	eng.Tick()
+	eng.RecordEvent(webPod("True"))
+	eng.Tick()
+	// Output:
+	// web_ready opened on default/web-1
+	// web_ready resolved on default/web-1
+}
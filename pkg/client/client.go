@@ -0,0 +1,362 @@
+// Package client is a Go client for the akari REST API.
+//
+//	c := client.New("http://akari:8080")
+//	violations, err := c.ListViolations(ctx, client.ListOptions{Severity: akari.Critical})
+//
+// Every call is read-only, so failed requests are retried with exponential
+// backoff on network errors, 429 and 5xx responses.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aonescu/akari/pkg/akari"
+)
+
+const (
+	DefaultMaxRetries = 3
+	DefaultBackoff    = 200 * time.Millisecond
+	maxBackoff        = 10 * time.Second
+)
+
+// APIError is a non-2xx response from the server
+type APIError struct {
+	StatusCode int
+	// Code is the machine-readable reason, such as resource_not_found or
+	// invalid_invariant. It is empty if the body was not a problem.
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("akari: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// retryable reports whether the request may succeed if sent again
+func (e *APIError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Client calls an akari server
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// MaxRetries is how many times a failed request is retried
+	MaxRetries int
+	// Backoff is the delay before the first retry; it doubles on each
+	// following one
+	Backoff time.Duration
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		MaxRetries: DefaultMaxRetries,
+		Backoff:    DefaultBackoff,
+	}
+}
+
+// ListOptions filters ListViolations
+type ListOptions struct {
+	Severity akari.Severity
+	Tags     []string
+	Limit    int
+	// Active lists the violations open in the monitor instead of a fresh
+	// evaluation
+	Active bool
+	// SortByImpact puts violations on tier-1 workloads first
+	SortByImpact bool
+}
+
+// ListViolations returns the current violations
+func (c *Client) ListViolations(ctx context.Context, opts ListOptions) ([]*akari.Violation, error) {
+	path := "/api/v1/violations"
+	if opts.Active {
+		path += "/active"
+	}
+	query := url.Values{}
+	if opts.Severity != "" {
+		query.Set("severity", string(opts.Severity))
+	}
+	if len(opts.Tags) > 0 {
+		query.Set("tags", strings.Join(opts.Tags, ","))
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.SortByImpact {
+		query.Set("sort", "impact")
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var violations []*akari.Violation
+	if err := c.do(ctx, http.MethodGet, path, nil, &violations); err != nil {
+		return nil, err
+	}
+	return violations, nil
+}
+
+// Resource identifies a recorded resource
+type Resource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	UID       string `json:"uid,omitempty"`
+}
+
+// Explanation is why a resource is unhealthy
+type Explanation struct {
+	Resource     Resource           `json:"resource"`
+	Violations   []*akari.Violation `json:"violations"`
+	Explanations []string           `json:"explanations"`
+}
+
+// Explain returns the violations of a resource with human-readable
+// explanations
+func (c *Client) Explain(ctx context.Context, kind, namespace, name string) (*Explanation, error) {
+	var explanation Explanation
+	body := Resource{Kind: kind, Namespace: namespace, Name: name}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/explain", body, &explanation); err != nil {
+		return nil, err
+	}
+	return &explanation, nil
+}
+
+// ResourceOptions filters ListResources; empty fields match everything
+type ResourceOptions struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Limit     int
+}
+
+// ListResources returns the latest recorded state of resources, ordered
+// by kind, namespace and name
+func (c *Client) ListResources(ctx context.Context, opts ResourceOptions) ([]akari.StateEvent, error) {
+	query := url.Values{}
+	for key, value := range map[string]string{"kind": opts.Kind, "namespace": opts.Namespace, "name": opts.Name} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	path := "/api/v1/resources"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var resources []akari.StateEvent
+	if err := c.do(ctx, http.MethodGet, path, nil, &resources); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// GetResource returns the latest recorded state of the resource with uid
+func (c *Client) GetResource(ctx context.Context, uid string) (*akari.StateEvent, error) {
+	var resource akari.StateEvent
+	if err := c.do(ctx, http.MethodGet, "/api/v1/resources/"+url.PathEscape(uid), nil, &resource); err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+// Evaluation is the outcome of evaluating a resource's state
+type Evaluation struct {
+	// Invariants are the IDs of the invariants matching the resource
+	Invariants []string `json:"invariants"`
+	// Results holds violations, unknown outcomes and evaluation errors;
+	// satisfied invariants have no result
+	Results    []*akari.Violation `json:"results"`
+	Violations []*akari.Violation `json:"violations"`
+}
+
+// EvaluateResource evaluates the invariants matching resource against the
+// given state without recording it
+func (c *Client) EvaluateResource(ctx context.Context, resource akari.StateEvent) (*Evaluation, error) {
+	var evaluation Evaluation
+	if err := c.do(ctx, http.MethodPost, "/api/v1/evaluate/resource", resource, &evaluation); err != nil {
+		return nil, err
+	}
+	return &evaluation, nil
+}
+
+// StreamViolations calls fn with every violation transition until ctx is
+// cancelled, reconnecting with backoff when the stream drops. Transitions
+// that happen while disconnected are missed; use ListViolations with
+// Active to resynchronize.
+func (c *Client) StreamViolations(ctx context.Context, fn func(akari.Transition)) error {
+	failures := 0
+	for {
+		connected, err := c.stream(ctx, fn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && !apiErr.retryable() {
+			return err
+		}
+		if connected {
+			failures = 0
+		}
+		if err := c.sleep(ctx, failures); err != nil {
+			return err
+		}
+		failures++
+	}
+}
+
+// stream reads one server-sent event connection. It reports whether the
+// connection was established.
+func (c *Client) stream(ctx context.Context, fn func(akari.Transition)) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/api/v1/violations/stream", nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, responseError(resp)
+	}
+
+	var data []string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				var t akari.Transition
+				if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &t); err == nil {
+					fn(t)
+				}
+				data = data[:0]
+			}
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return true, err
+	}
+	return true, io.EOF
+}
+
+// do sends a request, retrying transient failures, and decodes the JSON
+// response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = c.once(ctx, method, path, payload, out); err == nil {
+			return nil
+		}
+		var apiErr *APIError
+		if ctx.Err() != nil || (errors.As(err, &apiErr) && !apiErr.retryable()) || attempt >= c.MaxRetries {
+			return err
+		}
+		if sleepErr := c.sleep(ctx, attempt); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) once(ctx context.Context, method, path string, payload []byte, out interface{}) error {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// sleep waits out the backoff for the given retry attempt
+func (c *Client) sleep(ctx context.Context, attempt int) error {
+	delay := c.Backoff
+	if delay <= 0 {
+		delay = DefaultBackoff
+	}
+	for i := 0; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func responseError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	var problem struct {
+		Detail string `json:"detail"`
+		Code   string `json:"code"`
+	}
+	if json.Unmarshal(message, &problem) == nil && problem.Code != "" {
+		apiErr.Code = problem.Code
+		apiErr.Message = problem.Detail
+	}
+	return apiErr
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aonescu/akari/cmd/server"
+	"github.com/aonescu/akari/internal/engine"
+	"github.com/aonescu/akari/internal/state"
+	"github.com/aonescu/akari/pkg/akari"
+)
+
+var webReady = akari.Invariant{
+	ID:             "web_ready",
+	Subject:        akari.Subject{Kind: "Pod"},
+	Predicate:      &akari.Predicate{Field: "status.conditions[Ready].status", Operator: akari.Equals, Value: "True"},
+	Responsibility: akari.Responsibility{Primary: "kubelet"},
+	Severity:       akari.Critical,
+}
+
+func newServer(t *testing.T) (*server.APIServer, *httptest.Server) {
+	t.Helper()
+	store := state.NewMemoryStore()
+	eng := engine.NewInvariantEngine(store)
+	for _, inv := range eng.GetInvariants() {
+		eng.DeleteInvariant(inv.ID)
+	}
+	eng.UpsertInvariant(webReady)
+	store.Record(akari.StateEvent{
+		UID: "pod-1", Kind: "Pod", Namespace: "default", Name: "web-1", Timestamp: time.Now(),
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "False"},
+	})
+
+	api := server.NewAPIServer(store, eng)
+	ts := httptest.NewServer(api.Handler())
+	t.Cleanup(ts.Close)
+	return api, ts
+}
+
+func TestClient_Queries(t *testing.T) {
+	_, ts := newServer(t)
+	c := New(ts.URL)
+	ctx := context.Background()
+
+	violations, err := c.ListViolations(ctx, ListOptions{Severity: akari.Critical})
+	if err != nil {
+		t.Fatalf("ListViolations failed: %v", err)
+	}
+	if len(violations) != 1 || violations[0].InvariantID != "web_ready" {
+		t.Errorf("Expected web_ready to be violated, got %+v", violations)
+	}
+
+	explanation, err := c.Explain(ctx, "Pod", "default", "web-1")
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if explanation.Resource.UID != "pod-1" || len(explanation.Explanations) != 1 {
+		t.Errorf("Unexpected explanation %+v", explanation)
+	}
+
+	evaluation, err := c.EvaluateResource(ctx, akari.StateEvent{
+		UID: "pod-2", Kind: "Pod", Namespace: "default", Name: "web-2",
+		FieldDiff: map[string]interface{}{"status.conditions[Ready].status": "True"},
+	})
+	if err != nil {
+		t.Fatalf("EvaluateResource failed: %v", err)
+	}
+	if len(evaluation.Invariants) != 1 || len(evaluation.Violations) != 0 {
+		t.Errorf("Expected web-2 to satisfy web_ready, got %+v", evaluation)
+	}
+
+	resources, err := c.ListResources(ctx, ResourceOptions{Kind: "Pod", Namespace: "default"})
+	if err != nil {
+		t.Fatalf("ListResources failed: %v", err)
+	}
+	if len(resources) != 1 || resources[0].UID != "pod-1" {
+		t.Errorf("Expected pod-1, got %+v", resources)
+	}
+	resource, err := c.GetResource(ctx, "pod-1")
+	if err != nil {
+		t.Fatalf("GetResource failed: %v", err)
+	}
+	if resource.FieldDiff["status.conditions[Ready].status"] != "False" {
+		t.Errorf("Expected pod-1's recorded fields, got %+v", resource)
+	}
+
+	var apiErr *APIError
+	if _, err := c.Explain(ctx, "Pod", "default", "missing"); err == nil || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "resource_not_found" {
+		t.Errorf("Expected a 404 APIError, got %v", err)
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "warming up", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL)
+	c.Backoff = time.Millisecond
+	if _, err := c.ListViolations(context.Background(), ListOptions{}); err != nil {
+		t.Fatalf("Expected the third attempt to succeed, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls.Load())
+	}
+
+	c.MaxRetries = 0
+	calls.Store(0)
+	if _, err := c.ListViolations(context.Background(), ListOptions{}); err == nil {
+		t.Error("Expected the error to surface without retries")
+	}
+}
+
+func TestClient_StreamViolations(t *testing.T) {
+	api, ts := newServer(t)
+	c := New(ts.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	received := make(chan akari.Transition, 1)
+	go c.StreamViolations(ctx, func(t akari.Transition) { received <- t })
+
+	v := &akari.Violation{InvariantID: "web_ready", AffectedResource: "default/web-1", Violated: true}
+	for {
+		// Publish until the stream has connected and delivers one
+		api.PublishTransition(akari.Transition{Type: akari.TransitionOpened, Violation: v, At: time.Now()})
+		select {
+		case tr := <-received:
+			if tr.Type != akari.TransitionOpened || tr.Violation.InvariantID != "web_ready" {
+				t.Errorf("Unexpected transition %+v", tr)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("No transition received from the stream")
+		}
+	}
+}